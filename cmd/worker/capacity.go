@@ -0,0 +1,22 @@
+package main
+
+import "flowctl/internal/core"
+
+// SetCapacity sets the CPU and memory the worker has for tasks. It then only
+// dequeues tasks whose resources fit beside those of the tasks it is
+// running. Nil leaves both unlimited.
+func (w *Worker) SetCapacity(capacity *core.ResourceRequest) {
+	w.capacity = capacity
+}
+
+// capacityFreed wakes a waiting task loop once a task has finished, since a
+// task that did not fit before may fit now.
+func (w *Worker) capacityFreed() {
+	if w.capacity == nil {
+		return
+	}
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DefaultConcurrency is how many tasks a worker runs at once unless
+// configured otherwise.
+const DefaultConcurrency = 1
+
+// SetConcurrency sets how many tasks the worker runs at once across all of
+// its task types, and caps how many of those slots individual types may
+// take. Types without a cap may take them all.
+func (w *Worker) SetConcurrency(concurrency int, typeSlots map[string]int) {
+	w.concurrency = concurrency
+	w.typeSlots = typeSlots
+}
+
+// Slots returns how many tasks of each of its types the worker runs at
+// once.
+func (w *Worker) Slots() map[string]int {
+	slots := make(map[string]int, len(w.taskTypes))
+	for _, taskType := range w.taskTypes {
+		slots[taskType] = w.concurrency
+		if limit, ok := w.typeSlots[taskType]; ok && limit < w.concurrency {
+			slots[taskType] = limit
+		}
+	}
+	return slots
+}
+
+// reserveType takes one of a task type's slots for a task loop about to
+// dequeue one of its tasks. It returns false if the type holds all the
+// slots it may. The caller gives the slot back with releaseType once the
+// task is done, or at once if none was dequeued.
+func (w *Worker) reserveType(taskType string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if limit, ok := w.typeSlots[taskType]; ok && w.typeBusy[taskType] >= limit {
+		return false
+	}
+	w.typeBusy[taskType]++
+	return true
+}
+
+func (w *Worker) releaseType(taskType string) {
+	w.mu.Lock()
+	w.typeBusy[taskType]--
+	w.mu.Unlock()
+}
+
+// parseTypeConcurrency parses per-type slot caps written as
+// "etl=2,ci=1".
+func parseTypeConcurrency(s string) (map[string]int, error) {
+	return parseTypeCounts(s, "slots")
+}
+
+// parseTypeCounts parses positive counts per task type written as
+// "etl=2,ci=1". what names the counts in errors.
+func parseTypeCounts(s, what string) (map[string]int, error) {
+	counts := make(map[string]int)
+	if strings.TrimSpace(s) == "" {
+		return counts, nil
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		taskType, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		taskType = strings.TrimSpace(taskType)
+		if !ok || taskType == "" {
+			return nil, fmt.Errorf("%q is not type=%s", pair, what)
+		}
+		if _, seen := counts[taskType]; seen {
+			return nil, fmt.Errorf("task type %s is listed twice", taskType)
+		}
+		count, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || count < 1 {
+			return nil, fmt.Errorf("%s for task type %s must be a positive number", what, taskType)
+		}
+		counts[taskType] = count
+	}
+	return counts, nil
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"flowctl/internal/core"
+)
+
+func TestDuplicateDeliveryIsRequeuedNotAcked(t *testing.T) {
+	broker := newFakeBroker()
+	w := newTestWorker()
+	w.queue = broker
+
+	task := &core.Task{ID: "task-1", Type: "etl", RetryCount: 1}
+	w.deferDuplicate(context.Background(), task, fmt.Errorf("%w: token", core.ErrEffectInProgress))
+
+	if len(broker.acked) != 0 || len(broker.nacked) != 0 {
+		t.Errorf("duplicate delivery was acked %v or nacked %v", broker.acked, broker.nacked)
+	}
+	if delay, ok := broker.requeued["task-1"]; !ok || delay != effectInProgressDelay {
+		t.Errorf("duplicate delivery requeued = %v, %v; want it requeued in %s", delay, ok, effectInProgressDelay)
+	}
+}
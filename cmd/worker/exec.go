@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"flowctl/internal/core"
+)
+
+// maxCapturedOutput bounds how much of each of a command's stdout and
+// stderr is kept for its result. The end of the output is kept, since that
+// is where errors usually are.
+const maxCapturedOutput = 64 << 10
+
+// commandWaitDelay is how long a killed command's output may still be read
+// before its pipes are closed, in case a process it started holds them.
+const commandWaitDelay = 5 * time.Second
+
+// commandEnvNames are the variables of the worker's environment every
+// command gets. Anything else, such as the worker's credentials, only
+// reaches commands when named by SetCommandExecution.
+var commandEnvNames = []string{"PATH", "HOME", "LANG", "LC_ALL", "TZ", "TMPDIR"}
+
+// defaultCommandPath is the PATH of commands when the worker has none.
+const defaultCommandPath = "/usr/local/bin:/usr/bin:/bin"
+
+// SetCommandExecution makes the worker run the commands of generic and ci
+// tasks, and script tasks, for real when their executor is shell, passing them the worker's
+// variables named in passEnv on top of commandEnvNames. Otherwise, as by
+// default, generic and ci commands are only simulated and scripts fail.
+func (w *Worker) SetCommandExecution(enabled bool, passEnv []string) {
+	w.execCommands = enabled
+	w.execEnv = passEnv
+}
+
+// runsCommands reports whether the worker runs task's command: it must have
+// been started with -exec-commands, and the task must ask for the shell
+// executor, which the scheduler only dispatches where the namespace's
+// sandbox policy allows it.
+func (w *Worker) runsCommands(task *core.Task) bool {
+	return w.execCommands && task.Executor == core.ExecutorShell
+}
+
+// commandSpec is a command a task runs: the program name with args, in dir
+// with env added to its minimal environment. command is how the task wrote
+// it, for its result.
+type commandSpec struct {
+	command string
+	name    string
+	args    []string
+	env     map[string]string
+	dir     string
+	timeout time.Duration
+}
+
+// commandSpecFrom reads a command run through the shell from a task's
+// payload: command, and optionally args, passed to the shell as positional
+// parameters, env, working_dir and timeout.
+func commandSpecFrom(payload map[string]interface{}) (*commandSpec, error) {
+	command, ok := payload["command"].(string)
+	if !ok || strings.TrimSpace(command) == "" {
+		return nil, fmt.Errorf("missing or invalid command")
+	}
+
+	args, err := payloadArgs(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	spec := &commandSpec{command: command}
+	spec.name, spec.args = shellCommand(command, args)
+	if err := spec.readOptions(payload); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// payloadArgs reads the args list of a task's payload.
+func payloadArgs(payload map[string]interface{}) ([]string, error) {
+	raw, ok := payload["args"]
+	if !ok {
+		return nil, nil
+	}
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("args must be a list")
+	}
+
+	args := make([]string, 0, len(list))
+	for _, arg := range list {
+		switch v := arg.(type) {
+		case string:
+			args = append(args, v)
+		case float64, bool:
+			args = append(args, fmt.Sprint(v))
+		default:
+			return nil, fmt.Errorf("args must be strings, numbers or booleans")
+		}
+	}
+	return args, nil
+}
+
+// readOptions reads the env, working_dir and timeout of a task's payload.
+func (s *commandSpec) readOptions(payload map[string]interface{}) error {
+	s.env = make(map[string]string)
+	if raw, ok := payload["env"]; ok {
+		env, ok := raw.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("env must be an object")
+		}
+		for name, value := range env {
+			if name == "" || strings.ContainsAny(name, "=\x00") {
+				return fmt.Errorf("invalid environment variable name %q", name)
+			}
+			switch v := value.(type) {
+			case string:
+				s.env[name] = v
+			case float64, bool:
+				s.env[name] = fmt.Sprint(v)
+			default:
+				return fmt.Errorf("environment variable %s must be a string, number or boolean", name)
+			}
+		}
+	}
+
+	if raw, ok := payload["working_dir"]; ok {
+		dir, ok := raw.(string)
+		if !ok {
+			return fmt.Errorf("working_dir must be a string")
+		}
+		s.dir = dir
+	}
+
+	if raw, ok := payload["timeout"]; ok {
+		value, ok := raw.(string)
+		if !ok {
+			return fmt.Errorf("timeout must be a duration such as 10m")
+		}
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			return fmt.Errorf("timeout must be a duration such as 10m")
+		}
+		s.timeout = timeout
+	}
+
+	return nil
+}
+
+// runCommand runs a task's command to the end and returns its exit code and
+// the end of its stdout and stderr as the result. Both streams are also
+// streamed as the task's output. A command that exits with a non-zero
+// status, or is killed, fails the task. Cancelling ctx kills the command
+// along with every process it started.
+func (w *Worker) runCommand(taskCtx context.Context, task *core.Task, spec *commandSpec) (map[string]interface{}, error) {
+	ctx := taskCtx
+	if spec.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, spec.timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, spec.name, spec.args...)
+	cmd.Dir = spec.dir
+	cmd.Env = w.commandEnv(task, spec)
+	killProcessGroup(cmd)
+	cmd.WaitDelay = commandWaitDelay
+
+	output := core.TaskOutputFrom(ctx)
+	stdout := &tailBuffer{max: maxCapturedOutput}
+	stderr := &tailBuffer{max: maxCapturedOutput}
+	cmd.Stdout = io.MultiWriter(stdout, output.Stdout)
+	cmd.Stderr = io.MultiWriter(stderr, output.Stderr)
+
+	started := time.Now()
+	err := cmd.Run()
+	duration := time.Since(started)
+	commandUsageFrom(taskCtx).record(cmd.ProcessState)
+
+	if taskCtx.Err() != nil {
+		return nil, taskCtx.Err()
+	}
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("command timed out after %s", spec.timeout)
+	}
+
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return nil, fmt.Errorf("failed to run command: %w", err)
+	}
+
+	exitCode := cmd.ProcessState.ExitCode()
+	if exitCode != 0 {
+		if line := lastLine(stderr.String()); line != "" {
+			return nil, fmt.Errorf("command exited with status %d: %s", exitCode, line)
+		}
+		return nil, fmt.Errorf("command exited with status %d", exitCode)
+	}
+
+	result := map[string]interface{}{
+		"command":   spec.command,
+		"exit_code": exitCode,
+		"stdout":    stdout.String(),
+		"stderr":    stderr.String(),
+		"duration":  duration.Round(time.Millisecond).String(),
+	}
+	if stdout.truncated {
+		result["stdout_truncated"] = true
+	}
+	if stderr.truncated {
+		result["stderr_truncated"] = true
+	}
+	return result, nil
+}
+
+// commandEnv returns the environment of a task's command: the worker's
+// variables named by commandEnvNames and -exec-env, the task's identity and
+// the payload's env. The worker's environment is otherwise left out.
+func (w *Worker) commandEnv(task *core.Task, spec *commandSpec) []string {
+	var env []string
+	for _, names := range [][]string{commandEnvNames, w.execEnv} {
+		for _, name := range names {
+			if value, ok := os.LookupEnv(name); ok {
+				env = append(env, name+"="+value)
+			}
+		}
+	}
+	if _, ok := os.LookupEnv("PATH"); !ok {
+		env = append(env, "PATH="+defaultCommandPath)
+	}
+
+	env = append(env,
+		"FLOWCTL_TASK_ID="+task.ID,
+		"FLOWCTL_WORKFLOW_ID="+task.WorkflowID,
+		"FLOWCTL_ATTEMPT="+strconv.Itoa(task.RetryCount+1),
+	)
+	for name, value := range spec.env {
+		env = append(env, name+"="+value)
+	}
+	return env
+}
+
+// tailBuffer keeps the last max bytes written to it. It lets buf grow to
+// twice max before dropping what is older, so a command that writes a lot
+// costs a copy every max bytes rather than one on every write.
+type tailBuffer struct {
+	max       int
+	buf       []byte
+	truncated bool
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if n > b.max {
+		p = p[n-b.max:]
+		b.buf = b.buf[:0]
+		b.truncated = true
+	}
+	if over := len(b.buf) + len(p) - 2*b.max; over > 0 {
+		// Keep max bytes once p is appended.
+		over += b.max
+		b.buf = b.buf[:copy(b.buf, b.buf[over:])]
+	}
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.max {
+		b.truncated = true
+	}
+	return n, nil
+}
+
+func (b *tailBuffer) String() string {
+	if over := len(b.buf) - b.max; over > 0 {
+		return string(b.buf[over:])
+	}
+	return string(b.buf)
+}
+
+// lastLine returns the last non-empty line of s.
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimRight(s, "\r\n"), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
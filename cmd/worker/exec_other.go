@@ -0,0 +1,14 @@
+//go:build !unix
+
+package main
+
+import "os/exec"
+
+// shellCommand runs command with cmd.exe, which takes args after it.
+func shellCommand(command string, args []string) (string, []string) {
+	return "cmd", append([]string{"/C", command}, args...)
+}
+
+// killProcessGroup is not supported on this platform; cancelling a command
+// kills only the process it started.
+func killProcessGroup(cmd *exec.Cmd) {}
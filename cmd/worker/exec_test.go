@@ -0,0 +1,94 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"flowctl/internal/core"
+)
+
+func TestRunsCommandsOnlyWhenEnabledForShellTasks(t *testing.T) {
+	shell := &core.Task{Executor: core.ExecutorShell}
+	docker := &core.Task{Executor: core.ExecutorDocker}
+	unset := &core.Task{}
+
+	w := &Worker{}
+	if w.runsCommands(shell) {
+		t.Error("a worker without -exec-commands runs commands")
+	}
+
+	w.SetCommandExecution(true, nil)
+	if !w.runsCommands(shell) {
+		t.Error("a worker with -exec-commands does not run a shell task's command")
+	}
+	if w.runsCommands(docker) || w.runsCommands(unset) {
+		t.Error("a worker runs the command of a task whose executor is not shell")
+	}
+}
+
+func TestCommandEnvLeavesOutTheWorkersEnvironment(t *testing.T) {
+	t.Setenv("PATH", "/usr/bin:/bin")
+	t.Setenv("FLOWCTL_TEST_SECRET", "hunter2")
+	t.Setenv("FLOWCTL_TEST_PASSED", "yes")
+
+	w := &Worker{}
+	w.SetCommandExecution(true, []string{"FLOWCTL_TEST_PASSED"})
+	task := &core.Task{ID: "task-1", WorkflowID: "wf-1", RetryCount: 1}
+	spec := &commandSpec{env: map[string]string{"GREETING": "hello"}}
+
+	env := strings.Join(w.commandEnv(task, spec), "\n")
+	for _, want := range []string{"PATH=/usr/bin:/bin", "FLOWCTL_TEST_PASSED=yes", "FLOWCTL_TASK_ID=task-1", "FLOWCTL_ATTEMPT=2", "GREETING=hello"} {
+		if !strings.Contains(env, want) {
+			t.Errorf("command environment lacks %s:\n%s", want, env)
+		}
+	}
+	if strings.Contains(env, "hunter2") {
+		t.Errorf("command environment leaks FLOWCTL_TEST_SECRET:\n%s", env)
+	}
+}
+
+func TestTailBufferKeepsTheLastBytes(t *testing.T) {
+	tests := []struct {
+		name          string
+		writes        []string
+		want          string
+		wantTruncated bool
+	}{
+		{"short", []string{"ab", "cd"}, "abcd", false},
+		{"exactly max", []string{"abcde", "fghij"}, "abcdefghij", false},
+		{"one over", []string{"abcdefghij", "k"}, "bcdefghijk", true},
+		{"past twice max", []string{"abcdefgh", "ijklmnop", "qrstuv"}, "mnopqrstuv", true},
+		{"single write over max", []string{"ab", "0123456789xyz"}, "3456789xyz", true},
+		{"many small writes", strings.Split("the quick brown fox jumps", ""), " fox jumps", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &tailBuffer{max: 10}
+			for _, w := range tt.writes {
+				if n, err := b.Write([]byte(w)); n != len(w) || err != nil {
+					t.Fatalf("Write(%q) = %d, %v", w, n, err)
+				}
+			}
+			if got := b.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+			if b.truncated != tt.wantTruncated {
+				t.Errorf("truncated = %v, want %v", b.truncated, tt.wantTruncated)
+			}
+		})
+	}
+}
+
+func TestTailBufferDoesNotReallocateOnceFull(t *testing.T) {
+	b := &tailBuffer{max: 1024}
+	line := []byte(strings.Repeat("x", 99) + "\n")
+	for i := 0; i < 100; i++ {
+		b.Write(line)
+	}
+	if allocs := testing.AllocsPerRun(1000, func() { b.Write(line) }); allocs != 0 {
+		t.Errorf("Write allocates %.1f times per call once full, want 0", allocs)
+	}
+	if len(b.String()) != 1024 {
+		t.Errorf("kept %d bytes, want 1024", len(b.String()))
+	}
+}
@@ -0,0 +1,23 @@
+//go:build unix
+
+package main
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// shellCommand runs command with /bin/sh, passing args to it as "$@".
+func shellCommand(command string, args []string) (string, []string) {
+	return "/bin/sh", append([]string{"-c", command, "sh"}, args...)
+}
+
+// killProcessGroup starts cmd in a process group of its own and makes
+// cancelling it kill the whole group, so processes the command started do
+// not outlive it.
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"flowctl/internal/core"
+	"flowctl/internal/queue"
+)
+
+// fakeBroker records what a worker does with its tasks. Methods a test does
+// not expect to be called are left to the embedded nil interface, so
+// calling one panics.
+type fakeBroker struct {
+	queue.WorkerBroker
+
+	mu       sync.Mutex
+	acked    []string
+	nacked   []string
+	requeued map[string]time.Duration
+	// calls records the order of requeues, deregistrations and dequeues.
+	calls []string
+	// queued holds the tasks DequeueTask hands out, by type.
+	queued map[string][]*core.Task
+}
+
+func newFakeBroker() *fakeBroker {
+	return &fakeBroker{requeued: make(map[string]time.Duration)}
+}
+
+func (b *fakeBroker) DequeueTask(ctx context.Context, workerID, taskType string, timeout time.Duration) (*core.Task, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.calls = append(b.calls, "dequeue "+taskType)
+	tasks := b.queued[taskType]
+	if len(tasks) == 0 {
+		return nil, nil
+	}
+	b.queued[taskType] = tasks[1:]
+	return tasks[0], nil
+}
+
+func (b *fakeBroker) AckTask(ctx context.Context, task *core.Task) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.acked = append(b.acked, task.ID)
+	return nil
+}
+
+func (b *fakeBroker) NackTask(ctx context.Context, task *core.Task, errorMsg string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nacked = append(b.nacked, task.ID)
+	return nil
+}
+
+func (b *fakeBroker) RequeueTask(ctx context.Context, task *core.Task, delay time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.requeued[task.ID] = delay
+	b.calls = append(b.calls, "requeue "+task.ID)
+	return nil
+}
+
+func (b *fakeBroker) DeregisterWorker(ctx context.Context, workerID string, taskTypes []string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.calls = append(b.calls, "deregister "+workerID)
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"flowctl/internal/core"
+)
+
+// A worker renews the lease of each task it runs every
+// DefaultLeaseRenewInterval. DefaultLeaseTimeout matches the scheduler's
+// default visibility timeout, after which the reaper requeues a task whose
+// lease was not renewed.
+const (
+	DefaultLeaseRenewInterval = 30 * time.Second
+	DefaultLeaseTimeout       = core.DefaultVisibilityTimeout
+)
+
+// SetLeaseRenewal sets how often the worker renews the leases of the tasks
+// it runs, and the visibility timeout the scheduler's reaper applies to them.
+// A task whose lease could not be renewed before the timeout is stopped.
+func (w *Worker) SetLeaseRenewal(interval, timeout time.Duration) {
+	w.leaseRenewInterval = interval
+	w.leaseTimeout = timeout
+}
+
+// renewLease keeps the task leased to this worker while it runs, so the
+// scheduler's reaper only requeues tasks whose worker has died. Once the
+// lease has ended, or renewals have failed for so long that the reaper may
+// requeue the task before the next one, it calls lost to stop the task
+// here, since another delivery of it may already be running.
+func (w *Worker) renewLease(ctx context.Context, task *core.Task, lost func()) {
+	ticker := time.NewTicker(w.leaseRenewInterval)
+	defer ticker.Stop()
+
+	renewed := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := w.queue.TouchTask(ctx, task)
+			if err == nil {
+				renewed = time.Now()
+				continue
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			if errors.Is(err, core.ErrLeaseExpired) {
+				w.logger.Warnf("Lease of task %s expired, stopping it as it may be delivered to another worker", task.ID)
+				lost()
+				return
+			}
+
+			w.logger.Errorf("Failed to renew lease of task %s: %v", task.ID, err)
+			if since := time.Since(renewed); since+w.leaseRenewInterval > w.leaseTimeout {
+				w.logger.Warnf("Lease of task %s was last renewed %s ago, stopping it before the reaper requeues it", task.ID, since.Round(time.Second))
+				lost()
+				return
+			}
+		}
+	}
+}
@@ -1,19 +1,24 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"math/rand"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"flowctl/internal/core"
 	"flowctl/internal/queue"
+	"flowctl/internal/telemetry"
 
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
@@ -23,44 +28,267 @@ type Worker struct {
 	id           string
 	address      string
 	taskTypes    []string
-	queue        *queue.RedisQueue
+	queue        queue.WorkerBroker
 	logger       *logrus.Logger
 	stopCh       chan struct{}
 	schedulerURL string
+	mu           sync.Mutex
+	running      map[string]context.CancelFunc
+	// cancelled holds, under mu, when cancellations arrived for tasks
+	// this worker was not running, in case it has just dequeued one.
+	cancelled    map[string]time.Time
+	results      *resultCache
+	metrics      *workerMetrics
+	// spool holds the status updates the scheduler could not be reached
+	// for until they are delivered.
+	spool        *statusSpool
+
+	// The worker runs concurrency task loops, each holding one slot and
+	// polling the task types in the order picker gives them by their
+	// typeWeights. typeSlots caps how many slots a task type may hold, and
+	// typeBusy counts, under mu, the slots each holds.
+	concurrency int
+	typeSlots   map[string]int
+	typeWeights map[string]int
+	typeBusy    map[string]int
+	picker      *typePicker
+
+	// capacity is the CPU and memory the worker has for tasks, nil if it
+	// takes tasks regardless of the resources they request.
+	capacity *core.ResourceRequest
+
+	// execCommands is set when the worker runs the commands of shell tasks
+	// rather than simulating them, with the worker's variables named in
+	// execEnv passed through to them.
+	execCommands bool
+	execEnv      []string
+
+	// hostname and handlerVersion are reported with every attempt.
+	hostname       string
+	handlerVersion string
+
+	// inflight tracks the task loops, so Stop can wait for the tasks they
+	// are running. aborted is set, under mu, once the shutdown timeout has
+	// elapsed and the remaining tasks are being cancelled. The heartbeat
+	// keeps the worker registered until drained is closed, and heartbeats
+	// tracks it so Stop can deregister the worker once it has returned.
+	inflight        sync.WaitGroup
+	aborted         bool
+	shutdownTimeout time.Duration
+	drained         chan struct{}
+	heartbeats      sync.WaitGroup
+
+	// leaseRenewInterval is how often the lease of a running task is
+	// renewed, and leaseTimeout how long it may go unrenewed before the
+	// reaper requeues the task.
+	leaseRenewInterval time.Duration
+	leaseTimeout       time.Duration
+
+	// A task loop that has found every queue empty for idleAfter polls
+	// them every idlePollInterval instead, or sooner when wake is nudged.
+	// idleLoops counts the loops that are idle.
+	wake             chan struct{}
+	idleAfter        time.Duration
+	idlePollInterval time.Duration
+	idleLoops        int
+	reportIdle       bool
 }
 
-func NewWorker(address string, taskTypes []string, redisQueue *queue.RedisQueue, schedulerURL string, logger *logrus.Logger) *Worker {
-	return &Worker{
+// DefaultShutdownTimeout is how long Stop waits for in-flight tasks before
+// requeueing them.
+const DefaultShutdownTimeout = 30 * time.Second
+
+// deregisterTimeout bounds how long a stopped worker tries to deregister.
+const deregisterTimeout = 5 * time.Second
+
+// Default idle backoff: how long a queue must stay empty before the worker
+// polls it less often, and how often it is polled then.
+const (
+	DefaultIdleAfter        = time.Minute
+	DefaultIdlePollInterval = 10 * time.Second
+)
+
+// cancellationMemory is how long a cancellation for a task the worker is
+// not running is remembered, covering a task dequeued as it was cancelled.
+const cancellationMemory = time.Minute
+
+// A worker that fails to dequeue waits between minDequeueBackoff and
+// maxDequeueBackoff before trying again.
+const (
+	minDequeueBackoff = 200 * time.Millisecond
+	maxDequeueBackoff = 10 * time.Second
+)
+
+// effectInProgressDelay is how long a delivery that found another delivery
+// of its task performing an effect waits before it is run again.
+const effectInProgressDelay = 30 * time.Second
+
+func NewWorker(address string, taskTypes []string, broker queue.WorkerBroker, schedulerURL string, logger *logrus.Logger) *Worker {
+	hostname, err := os.Hostname()
+	if err != nil {
+		logger.Warnf("Failed to get hostname: %v", err)
+	}
+
+	w := &Worker{
 		id:           uuid.New().String(),
 		address:      address,
 		taskTypes:    taskTypes,
-		queue:        redisQueue,
+		queue:        broker,
 		logger:       logger,
 		stopCh:       make(chan struct{}),
+		drained:      make(chan struct{}),
 		schedulerURL: schedulerURL,
+		running:      make(map[string]context.CancelFunc),
+		cancelled:    make(map[string]time.Time),
+		metrics:      newWorkerMetrics(),
+		concurrency:  DefaultConcurrency,
+		typeBusy:     make(map[string]int),
+
+		hostname:       hostname,
+		handlerVersion: buildVersion(),
+
+		shutdownTimeout: DefaultShutdownTimeout,
+
+		leaseRenewInterval: DefaultLeaseRenewInterval,
+		leaseTimeout:       DefaultLeaseTimeout,
+
+		idleAfter:        DefaultIdleAfter,
+		idlePollInterval: DefaultIdlePollInterval,
 	}
+	w.spool = newStatusSpool(w.deliverStatus, logger)
+	w.metrics.watch(w)
+	return w
+}
+
+// SetHandlerVersion sets the task handler version reported with each
+// attempt, in place of the one read from the binary's build information.
+func (w *Worker) SetHandlerVersion(version string) {
+	w.handlerVersion = version
+}
+
+// SetShutdownTimeout sets how long Stop waits for in-flight tasks to finish
+// before cancelling and nacking them. Zero nacks them immediately.
+func (w *Worker) SetShutdownTimeout(timeout time.Duration) {
+	w.shutdownTimeout = timeout
+}
+
+// SetIdleBackoff sets how long a queue must stay empty before the worker
+// polls it only every pollInterval, waking early when the scheduler enqueues
+// a task for it. Zero idleAfter keeps polling continuously. With report set,
+// the worker registers as idle while all of its queues are.
+func (w *Worker) SetIdleBackoff(idleAfter, pollInterval time.Duration, report bool) {
+	w.idleAfter = idleAfter
+	w.idlePollInterval = pollInterval
+	w.reportIdle = report
 }
 
 func (w *Worker) Start(ctx context.Context) {
 	w.logger.Infof("Starting worker %s on %s for task types %v", w.id, w.address, w.taskTypes)
+	if namespaces := w.queue.WorkerNamespaces(); len(namespaces) > 0 {
+		w.logger.Infof("Worker %s only runs tasks of namespaces %v", w.id, namespaces)
+	}
+	if labels := w.queue.WorkerLabels(); len(labels) > 0 {
+		w.logger.Infof("Worker %s also runs tasks whose selector matches labels %s", w.id, core.FormatSelector(labels))
+	}
 
 	if err := w.queue.RegisterWorker(ctx, w.id, w.address, w.taskTypes); err != nil {
 		w.logger.Errorf("Failed to register worker: %v", err)
 		return
 	}
+	w.metrics.heartbeat()
+
+	w.wake = make(chan struct{}, w.concurrency)
+	w.picker = newTypePicker(w.taskTypes, w.typeWeights)
+	w.logger.Infof("Worker %s runs up to %d tasks at once, by type %v", w.id, w.concurrency, w.Slots())
+	if len(w.taskTypes) > 1 {
+		w.logger.Infof("Worker %s polls its task types by weight %v", w.id, w.picker.weightsByType())
+	}
+	if w.capacity != nil {
+		w.logger.Infof("Worker %s only takes tasks that fit in %.2f CPU and %d MB memory (0 is unlimited)", w.id, w.capacity.CPU, w.capacity.MemoryMB)
+	}
+
+	w.heartbeats.Add(1)
+	go func() {
+		defer w.heartbeats.Done()
+		w.heartbeat(ctx)
+	}()
+	go w.listenForCancellations(ctx)
+	go w.listenForWakeups(ctx)
+	go w.spool.run(ctx)
 
-	go w.heartbeat(ctx)
+	// Dequeuing stops as soon as the worker does, while tasks already
+	// dequeued keep running on ctx until they finish or Stop gives up.
+	dequeueCtx, stopDequeue := context.WithCancel(ctx)
+	defer stopDequeue()
+	go func() {
+		select {
+		case <-w.stopCh:
+			stopDequeue()
+		case <-dequeueCtx.Done():
+		}
+	}()
 
-	for _, taskType := range w.taskTypes {
-		go w.processTaskType(ctx, taskType)
+	for i := 0; i < w.concurrency; i++ {
+		w.inflight.Add(1)
+		go func() {
+			defer w.inflight.Done()
+			w.processTasks(ctx, dequeueCtx)
+		}()
 	}
 
 	<-w.stopCh
-	w.logger.Info("Worker stopped")
+	w.logger.Info("Worker stopped dequeuing tasks")
 }
 
+// Stop stops dequeuing and waits up to the shutdown timeout for in-flight
+// tasks to finish. Tasks still running after that are cancelled and
+// requeued, so they run again instead of being abandoned mid-flight. The worker
+// keeps heartbeating while it drains, tries to deliver the status updates
+// it has spooled, and deregisters once every task is acked, nacked or
+// requeued.
 func (w *Worker) Stop() {
 	close(w.stopCh)
+	w.drain()
+	w.spool.flush(spoolFlushTimeout)
+
+	close(w.drained)
+	w.heartbeats.Wait()
+
+	ctx, cancel := context.WithTimeout(context.Background(), deregisterTimeout)
+	defer cancel()
+	if err := w.queue.DeregisterWorker(ctx, w.id, w.taskTypes); err != nil {
+		w.logger.Errorf("Failed to deregister worker: %v", err)
+	}
+}
+
+// drain waits for the task loops to finish their tasks, cancelling the
+// tasks still running once the shutdown timeout elapses.
+func (w *Worker) drain() {
+	done := make(chan struct{})
+	go func() {
+		w.inflight.Wait()
+		close(done)
+	}()
+
+	timer := time.NewTimer(w.shutdownTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		w.logger.Info("Worker drained its in-flight tasks")
+		return
+	case <-timer.C:
+	}
+
+	w.mu.Lock()
+	w.aborted = true
+	w.logger.Warnf("Shutdown timeout of %s elapsed, requeueing %d in-flight tasks", w.shutdownTimeout, len(w.running))
+	for _, cancel := range w.running {
+		cancel()
+	}
+	w.mu.Unlock()
+
+	<-done
 }
 
 func (w *Worker) heartbeat(ctx context.Context) {
@@ -71,80 +299,334 @@ func (w *Worker) heartbeat(ctx context.Context) {
 		select {
 		case <-ctx.Done():
 			return
-		case <-w.stopCh:
+		case <-w.drained:
 			return
 		case <-ticker.C:
 			if err := w.queue.UpdateWorkerHeartbeat(ctx, w.id); err != nil {
 				w.logger.Errorf("Failed to update heartbeat: %v", err)
+				continue
 			}
+			w.metrics.heartbeat()
 		}
 	}
 }
 
-func (w *Worker) processTaskType(ctx context.Context, taskType string) {
-	for {
+// listenForCancellations cancels the context of a running task when the
+// scheduler publishes its cancellation. A cancellation for a task the
+// worker is not running is remembered for a while, so a task dequeued just
+// before it was cancelled is dropped instead of started.
+func (w *Worker) listenForCancellations(ctx context.Context) {
+	for taskID := range w.queue.SubscribeCancellations(ctx) {
+		now := time.Now()
+
+		w.mu.Lock()
+		cancel, ok := w.running[taskID]
+		if !ok {
+			for id, at := range w.cancelled {
+				if now.Sub(at) > cancellationMemory {
+					delete(w.cancelled, id)
+				}
+			}
+			w.cancelled[taskID] = now
+		}
+		w.mu.Unlock()
+
+		if ok {
+			w.logger.Infof("Received cancellation for task %s", taskID)
+			cancel()
+		}
+	}
+}
+
+func (w *Worker) listenForWakeups(ctx context.Context) {
+	for range w.queue.SubscribeWakeups(ctx, w.taskTypes) {
 		select {
-		case <-ctx.Done():
-			return
-		case <-w.stopCh:
-			return
+		case w.wake <- struct{}{}:
 		default:
-			task, err := w.queue.DequeueTask(ctx, taskType, time.Second*30)
-			if err != nil {
-				w.logger.Errorf("Failed to dequeue task: %v", err)
-				time.Sleep(time.Second * 5)
-				continue
-			}
+		}
+	}
+}
 
-			if task == nil {
-				continue
-			}
+// setIdle records a task loop becoming idle or active again. The worker is
+// idle while all of its loops are.
+func (w *Worker) setIdle(ctx context.Context, idle bool) {
+	w.mu.Lock()
+	wasIdle := w.idleLoops == w.concurrency
+	if idle {
+		w.idleLoops++
+	} else {
+		w.idleLoops--
+	}
+	isIdle := w.idleLoops == w.concurrency
+	w.mu.Unlock()
 
-			w.executeTask(ctx, task)
+	if idle {
+		w.logger.Debugf("Queues for %v tasks are empty, polling every %s", w.taskTypes, w.idlePollInterval)
+	}
+	if wasIdle == isIdle {
+		return
+	}
+
+	status := core.WorkerStatusActive
+	if isIdle {
+		status = core.WorkerStatusIdle
+	}
+	w.logger.Infof("Worker is %s", status)
+
+	if w.reportIdle {
+		if err := w.queue.SetWorkerStatus(ctx, w.id, status); err != nil {
+			w.logger.Errorf("Failed to report worker status %s: %v", status, err)
 		}
 	}
 }
 
+// taskContext returns the context a task runs in, which ends after timeout
+// when it is set. cancel ends it early and releases everything it holds.
+func taskContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	taskCtx, cancelTask := context.WithCancel(ctx)
+	if timeout <= 0 {
+		return taskCtx, cancelTask
+	}
+
+	timeoutCtx, cancelTimeout := context.WithTimeout(taskCtx, timeout)
+	return timeoutCtx, func() {
+		cancelTimeout()
+		cancelTask()
+	}
+}
+
 func (w *Worker) executeTask(ctx context.Context, task *core.Task) {
+	if w.completeFromCachedResult(ctx, task) {
+		return
+	}
+
+	if task.IdempotencyKey != "" && w.completeFromPriorExecution(ctx, task) {
+		return
+	}
+
+	if w.dropExpiredTask(ctx, task) {
+		return
+	}
+
+	taskCtx, cancel := taskContext(ctx, task.Timeout)
+	defer cancel()
+
+	w.mu.Lock()
+	w.running[task.ID] = cancel
+	if w.aborted {
+		// Dequeued after Stop gave up on in-flight tasks.
+		cancel()
+	}
+	_, cancelled := w.cancelled[task.ID]
+	delete(w.cancelled, task.ID)
+	w.mu.Unlock()
+
+	defer func() {
+		w.mu.Lock()
+		delete(w.running, task.ID)
+		w.mu.Unlock()
+	}()
+
+	if cancelled {
+		// The scheduler has already marked it cancelled and ended its
+		// lease.
+		w.logger.Infof("Task %s was cancelled before it started, dropping it", task.ID)
+		w.queue.AckTask(ctx, task)
+		return
+	}
+
 	w.logger.Infof("Executing task %s of type %s", task.ID, task.Type)
 
-	w.notifyTaskStatus(task.ID, "running", nil, "")
+	w.notifyTaskStarted(task)
+
+	var leaseLost atomic.Bool
+	go w.renewLease(taskCtx, task, func() {
+		leaseLost.Store(true)
+		cancel()
+	})
+
+	started := time.Now()
+	commands := &commandUsage{}
+
+	deadline, _ := taskCtx.Deadline()
+	handlerCtx := core.WithExecutionContext(taskCtx, task.ExecutionContext(deadline))
+	handlerCtx = withCommandUsage(handlerCtx, commands)
+
+	var output *outputStreamer
+	if task.StreamsOutput() {
+		output = newOutputStreamer(ctx, w.queue, w.logger, task)
+		handlerCtx = core.WithTaskOutput(handlerCtx, output.taskOutput())
+	}
+
+	w.metrics.tasksRunning.Add(1, task.Type)
+	result, err := w.runTask(handlerCtx, task)
+	w.metrics.tasksRunning.Add(-1, task.Type)
+	output.close()
+
+	usage := &core.ResourceUsage{WallTime: time.Since(started)}
+	commands.apply(usage)
+
+	if leaseLost.Load() {
+		// The reaper has requeued the task, or will shortly, and its next
+		// delivery reports the outcome.
+		w.logger.Warnf("Dropping task %s after losing its lease", task.ID)
+		w.metrics.recordRun(task.Type, "lease_lost", usage.WallTime)
+		return
+	}
 
-	result, err := w.runTask(task)
 	if err != nil {
+		if errors.Is(err, core.ErrEffectInProgress) {
+			w.metrics.recordRun(task.Type, "duplicate", usage.WallTime)
+			w.deferDuplicate(ctx, task, err)
+			return
+		}
+
+		if taskCtx.Err() == context.Canceled && w.shuttingDown() {
+			w.metrics.recordRun(task.Type, "interrupted", usage.WallTime)
+			w.requeueInterrupted(ctx, task)
+			return
+		}
+		if taskCtx.Err() == context.Canceled && ctx.Err() == nil {
+			w.logger.Infof("Task %s was cancelled", task.ID)
+			w.metrics.recordRun(task.Type, "cancelled", usage.WallTime)
+			w.queue.AckTask(ctx, task)
+			w.notifyTaskStatus(task, "cancelled", nil, "task cancelled", usage)
+			return
+		}
+
+		if taskCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+			err = fmt.Errorf("task timed out after %s", task.Timeout)
+		}
+
 		w.logger.Errorf("Task %s failed: %v", task.ID, err)
-		
+		w.metrics.recordRun(task.Type, "failed", usage.WallTime)
+
+		nackErr := w.queue.NackTask(ctx, task, err.Error())
+		if errors.Is(nackErr, core.ErrRetryShed) {
+			w.notifyTaskStatus(task, "failed", nil, fmt.Sprintf("%v; not retried: %v", err, nackErr), usage)
+			return
+		}
+		if nackErr != nil {
+			w.logger.Errorf("Failed to nack task %s: %v", task.ID, nackErr)
+		}
+
 		if task.RetryCount < task.MaxRetries {
-			w.queue.NackTask(ctx, task)
-			w.notifyTaskStatus(task.ID, "retrying", nil, err.Error())
+			w.notifyTaskStatus(task, "retrying", nil, err.Error(), usage)
 		} else {
-			w.queue.AckTask(ctx, task)
-			w.notifyTaskStatus(task.ID, "failed", nil, err.Error())
+			w.notifyTaskStatus(task, "failed", nil, err.Error(), usage)
 		}
 		return
 	}
 
+	w.metrics.recordRun(task.Type, "completed", usage.WallTime)
+	w.results.put(task.ID, task.RetryCount, result)
+
+	if task.IdempotencyKey != "" {
+		if err := w.queue.SaveIdempotentResult(ctx, task.IdempotencyNamespace(), task.IdempotencyKey, result); err != nil {
+			w.logger.Errorf("Failed to save result for idempotency key %s: %v", task.IdempotencyKey, err)
+		}
+	}
+
 	w.queue.AckTask(ctx, task)
-	w.notifyTaskStatus(task.ID, "completed", result, "")
+	w.notifyTaskStatus(task, "completed", result, "", usage)
 	w.logger.Infof("Task %s completed successfully", task.ID)
 }
 
-func (w *Worker) runTask(task *core.Task) (map[string]interface{}, error) {
+// shuttingDown reports whether Stop has cancelled the in-flight tasks.
+func (w *Worker) shuttingDown() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.aborted
+}
+
+// completeFromCachedResult acks a duplicate delivery of an attempt this
+// worker already completed, reporting the cached result instead of running
+// the task again.
+func (w *Worker) completeFromCachedResult(ctx context.Context, task *core.Task) bool {
+	result, found := w.results.get(task.ID, task.RetryCount)
+	if !found {
+		return false
+	}
+
+	w.queue.AckTask(ctx, task)
+	w.notifyTaskStatus(task, "completed", result, "", nil)
+	w.logger.Infof("Task %s attempt %d already completed on this worker, reusing its result", task.ID, task.RetryCount)
+	return true
+}
+
+// completeFromPriorExecution acks a redelivered task whose idempotency key
+// already has a stored result, reporting that result instead of running the
+// task's side effects again.
+func (w *Worker) completeFromPriorExecution(ctx context.Context, task *core.Task) bool {
+	result, found, err := w.queue.GetIdempotentResult(ctx, task.IdempotencyNamespace(), task.IdempotencyKey)
+	if err != nil {
+		w.logger.Errorf("Failed to look up idempotency key %s: %v", task.IdempotencyKey, err)
+		return false
+	}
+	if !found {
+		return false
+	}
+
+	w.queue.AckTask(ctx, task)
+	w.notifyTaskStatus(task, "completed", result, "", nil)
+	w.logger.Infof("Task %s already ran under idempotency key %s, reusing its result", task.ID, task.IdempotencyKey)
+	return true
+}
+
+// dropExpiredTask acks a task dequeued after its expires_at, before the
+// scheduler's sweep reached it, without running it. The task is left queued
+// for the sweep to mark expired.
+func (w *Worker) dropExpiredTask(ctx context.Context, task *core.Task) bool {
+	if !task.Expired(time.Now()) {
+		return false
+	}
+
+	w.queue.AckTask(ctx, task)
+	w.logger.Warnf("Dropped task %s, which expired at %s", task.ID, task.ExpiresAt.Format(time.RFC3339))
+	return true
+}
+
+// requeueInterrupted puts back a task cancelled because the worker is
+// shutting down, to be run again at once without counting an attempt: the
+// task did not fail, so the shutdown must not use up one of its retries.
+// Its status is left for its next delivery to report.
+func (w *Worker) requeueInterrupted(ctx context.Context, task *core.Task) {
+	w.logger.Warnf("Task %s was interrupted by shutdown, requeueing it", task.ID)
+	if err := w.queue.RequeueTask(ctx, task, 0); err != nil {
+		w.logger.Errorf("Failed to requeue task %s: %v", task.ID, err)
+	}
+}
+
+// deferDuplicate puts back a delivery that found another delivery of its
+// task performing one of its effects, to be run again after
+// effectInProgressDelay without counting an attempt. If the other delivery
+// dies, this one then finishes the task; if it completed the effect, the
+// effect's recorded result is reused.
+func (w *Worker) deferDuplicate(ctx context.Context, task *core.Task, err error) {
+	w.logger.Warnf("Task %s is already running elsewhere, running this delivery again in %s: %v", task.ID, effectInProgressDelay, err)
+	if err := w.queue.RequeueTask(ctx, task, effectInProgressDelay); err != nil {
+		w.logger.Errorf("Failed to requeue task %s: %v", task.ID, err)
+	}
+}
+
+func (w *Worker) runTask(ctx context.Context, task *core.Task) (map[string]interface{}, error) {
 	switch task.Type {
 	case "etl":
-		return w.runETLTask(task)
+		return w.runETLTask(ctx, task)
 	case "ml_training":
-		return w.runMLTrainingTask(task)
+		return w.runMLTrainingTask(ctx, task)
 	case "ci":
-		return w.runCITask(task)
+		return w.runCITask(ctx, task)
 	case "generic":
-		return w.runGenericTask(task)
+		return w.runGenericTask(ctx, task)
+	case core.ScriptTaskType:
+		return w.runScriptTask(ctx, task)
 	default:
 		return nil, fmt.Errorf("unknown task type: %s", task.Type)
 	}
 }
 
-func (w *Worker) runETLTask(task *core.Task) (map[string]interface{}, error) {
+func (w *Worker) runETLTask(ctx context.Context, task *core.Task) (map[string]interface{}, error) {
 	sourceURL, ok := task.Payload["source_url"].(string)
 	if !ok {
 		return nil, fmt.Errorf("missing or invalid source_url")
@@ -155,32 +637,42 @@ func (w *Worker) runETLTask(task *core.Task) (map[string]interface{}, error) {
 		return nil, fmt.Errorf("missing or invalid target_url")
 	}
 
-	w.logger.Infof("Processing ETL task: %s -> %s", sourceURL, targetURL)
-	
-	time.Sleep(time.Second * 5)
+	logged := w.redactedPayload(ctx, task)
+	w.logger.Infof("Processing ETL task: %v -> %v", logged["source_url"], logged["target_url"])
 
-	return map[string]interface{}{
-		"records_processed": 1000,
-		"processing_time":   "5s",
-		"source":           sourceURL,
-		"target":           targetURL,
-	}, nil
+	// Loading into the target is the task's side effect, so a duplicate
+	// delivery of the attempt must not load the records twice.
+	return w.queue.RunEffect(ctx, core.EffectToken(task, "load"), func(ctx context.Context, token string) (map[string]interface{}, error) {
+		if err := sleepContext(ctx, time.Second*5); err != nil {
+			return nil, err
+		}
+
+		return map[string]interface{}{
+			"records_processed": 1000,
+			"processing_time":   "5s",
+			"source":            sourceURL,
+			"target":            targetURL,
+			"load_token":        token,
+		}, nil
+	})
 }
 
-func (w *Worker) runMLTrainingTask(task *core.Task) (map[string]interface{}, error) {
+func (w *Worker) runMLTrainingTask(ctx context.Context, task *core.Task) (map[string]interface{}, error) {
 	modelName, ok := task.Payload["model_name"].(string)
 	if !ok {
 		return nil, fmt.Errorf("missing or invalid model_name")
 	}
 
-	datasetURL, ok := task.Payload["dataset_url"].(string)
-	if !ok {
+	if _, ok := task.Payload["dataset_url"].(string); !ok {
 		return nil, fmt.Errorf("missing or invalid dataset_url")
 	}
 
-	w.logger.Infof("Training ML model: %s with dataset: %s", modelName, datasetURL)
+	logged := w.redactedPayload(ctx, task)
+	w.logger.Infof("Training ML model: %v with dataset: %v", logged["model_name"], logged["dataset_url"])
 	
-	time.Sleep(time.Second * 10)
+	if err := sleepContext(ctx, time.Second*10); err != nil {
+		return nil, err
+	}
 
 	return map[string]interface{}{
 		"model_name":      modelName,
@@ -190,20 +682,59 @@ func (w *Worker) runMLTrainingTask(task *core.Task) (map[string]interface{}, err
 	}, nil
 }
 
-func (w *Worker) runCITask(task *core.Task) (map[string]interface{}, error) {
+func (w *Worker) runCITask(ctx context.Context, task *core.Task) (map[string]interface{}, error) {
 	repoURL, ok := task.Payload["repo_url"].(string)
 	if !ok {
 		return nil, fmt.Errorf("missing or invalid repo_url")
 	}
 
+	if !w.runsCommands(task) {
+		return w.simulateCITask(ctx, task, repoURL)
+	}
+
+	spec, err := commandSpecFrom(task.Payload)
+	if err != nil {
+		return nil, err
+	}
+	// The build finds what it is building in its environment.
+	spec.env["FLOWCTL_REPO_URL"] = repoURL
+	if branch, ok := task.Payload["branch"].(string); ok {
+		spec.env["FLOWCTL_BRANCH"] = branch
+	}
+
+	logged := w.redactedPayload(ctx, task)
+	w.logger.Infof("Running CI task: %v on %v", logged["command"], logged["repo_url"])
+
+	output := core.TaskOutputFrom(ctx)
+	fmt.Fprintf(output.Stdout, "$ %v\n", logged["command"])
+
+	result, err := w.runCommand(ctx, task, spec)
+	if err != nil {
+		return nil, err
+	}
+	result["repo_url"] = repoURL
+	return result, nil
+}
+
+// simulateCITask reports a passing build without running the task's
+// command, for workers that do not run commands.
+func (w *Worker) simulateCITask(ctx context.Context, task *core.Task, repoURL string) (map[string]interface{}, error) {
 	command, ok := task.Payload["command"].(string)
 	if !ok {
 		return nil, fmt.Errorf("missing or invalid command")
 	}
 
-	w.logger.Infof("Running CI task: %s on %s", command, repoURL)
-	
-	time.Sleep(time.Second * 8)
+	logged := w.redactedPayload(ctx, task)
+	w.logger.Infof("Simulating CI task: %v on %v", logged["command"], logged["repo_url"])
+
+	output := core.TaskOutputFrom(ctx)
+	fmt.Fprintf(output.Stdout, "$ %v\n", logged["command"])
+
+	if err := sleepContext(ctx, time.Second*8); err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintln(output.Stdout, "42 passed, 0 failed")
 
 	return map[string]interface{}{
 		"repo_url":     repoURL,
@@ -212,71 +743,199 @@ func (w *Worker) runCITask(task *core.Task) (map[string]interface{}, error) {
 		"build_time":   "8s",
 		"tests_passed": 42,
 		"tests_failed": 0,
+		"simulated":    true,
 	}, nil
 }
 
-func (w *Worker) runGenericTask(task *core.Task) (map[string]interface{}, error) {
+func (w *Worker) runGenericTask(ctx context.Context, task *core.Task) (map[string]interface{}, error) {
+	if !w.runsCommands(task) {
+		return w.simulateGenericTask(ctx, task)
+	}
+
+	spec, err := commandSpecFrom(task.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	logged := w.redactedPayload(ctx, task)
+	w.logger.Infof("Running generic task: %v", logged["command"])
+
+	output := core.TaskOutputFrom(ctx)
+	fmt.Fprintf(output.Stdout, "$ %v\n", logged["command"])
+
+	return w.runCommand(ctx, task, spec)
+}
+
+// simulateGenericTask sleeps for the payload's sleep_duration, in seconds,
+// in place of running its command, for workers that do not run commands.
+func (w *Worker) simulateGenericTask(ctx context.Context, task *core.Task) (map[string]interface{}, error) {
 	command, ok := task.Payload["command"].(string)
 	if !ok {
 		return nil, fmt.Errorf("missing or invalid command")
 	}
 
-	w.logger.Infof("Running generic task: %s", command)
-	
+	logged := w.redactedPayload(ctx, task)
+	w.logger.Infof("Simulating generic task: %v", logged["command"])
+
+	output := core.TaskOutputFrom(ctx)
+	fmt.Fprintf(output.Stdout, "$ %v\n", logged["command"])
+
 	sleepDuration := time.Second * 3
 	if duration, ok := task.Payload["sleep_duration"].(float64); ok {
 		sleepDuration = time.Duration(duration) * time.Second
 	}
 
-	time.Sleep(sleepDuration)
+	if err := sleepContext(ctx, sleepDuration); err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintln(output.Stdout, "Task completed successfully")
 
 	return map[string]interface{}{
-		"command":      command,
-		"exit_code":    0,
-		"output":       "Task completed successfully",
-		"duration":     sleepDuration.String(),
+		"command":   command,
+		"exit_code": 0,
+		"output":    "Task completed successfully",
+		"duration":  sleepDuration.String(),
+		"simulated": true,
 	}, nil
 }
 
-func (w *Worker) notifyTaskStatus(taskID, status string, result map[string]interface{}, errorMsg string) {
-	if w.schedulerURL == "" {
-		return
+func (w *Worker) redactedPayload(ctx context.Context, task *core.Task) map[string]interface{} {
+	return w.queue.Redactor(ctx).Redact(task.Type, task.Payload)
+}
+
+// dequeueBackoff returns how long to wait after the given number of
+// consecutive failed dequeues: doubling from minDequeueBackoff up to
+// maxDequeueBackoff, with jitter so that workers do not all retry at once
+// when Redis comes back.
+func dequeueBackoff(failures int) time.Duration {
+	backoff := maxDequeueBackoff
+	if failures < 16 {
+		backoff = minDequeueBackoff << (failures - 1)
+		if backoff > maxDequeueBackoff {
+			backoff = maxDequeueBackoff
+		}
 	}
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+}
 
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// notifyTaskStarted reports a task as running, together with where this
+// attempt of it runs.
+func (w *Worker) notifyTaskStarted(task *core.Task) {
+	// The built-in handlers run every task in the worker process.
+	executor := string(task.Executor)
+	if executor == "" {
+		executor = "builtin"
+	}
+
+	w.postTaskStatus(task, map[string]interface{}{
+		"task_id": task.ID,
+		"status":  "running",
+		"environment": core.ExecutionEnvironment{
+			Attempt:        task.RetryCount + 1,
+			WorkerID:       w.id,
+			Hostname:       w.hostname,
+			Pool:           w.queue.WorkerPool(),
+			HandlerVersion: w.handlerVersion,
+			Executor:       executor,
+			StartedAt:      time.Now(),
+		},
+	})
+}
+
+func (w *Worker) notifyTaskStatus(task *core.Task, status string, result map[string]interface{}, errorMsg string, usage *core.ResourceUsage) {
 	payload := map[string]interface{}{
-		"task_id": taskID,
+		"task_id": task.ID,
 		"status":  status,
 		"result":  result,
 		"error":   errorMsg,
 	}
-
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		w.logger.Errorf("Failed to marshal task status: %v", err)
-		return
+	if usage != nil {
+		payload["usage"] = usage
 	}
 
-	url := fmt.Sprintf("%s/api/v1/tasks/%s/status", w.schedulerURL, taskID)
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(jsonData))
-	if err != nil {
-		w.logger.Errorf("Failed to notify task status: %v", err)
+	w.postTaskStatus(task, payload)
+}
+
+// postTaskStatus reports the status of the task's current attempt to the
+// scheduler, spooling the update while the scheduler cannot be reached.
+// The scheduler drops a report that arrives after a later attempt started.
+func (w *Worker) postTaskStatus(task *core.Task, payload map[string]interface{}) {
+	if w.schedulerURL == "" {
 		return
 	}
-	defer resp.Body.Close()
+	payload["attempt"] = task.RetryCount + 1
+	w.spool.post(&statusUpdate{TaskID: task.ID, Payload: payload})
+}
+
+// serveReadiness answers 503 while Redis cannot be reached.
+func (w *Worker) serveReadiness(rw http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
 
-	if resp.StatusCode != http.StatusOK {
-		w.logger.Errorf("Failed to notify task status, status code: %d", resp.StatusCode)
+	health := w.queue.CheckBroker(ctx)
+	status := http.StatusOK
+	if health.Status != core.BrokerStatusUp {
+		status = http.StatusServiceUnavailable
 	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	json.NewEncoder(rw).Encode(health)
 }
 
 func main() {
 	var (
-		redisAddr    = flag.String("redis", "localhost:6379", "Redis address")
-		redisPass    = flag.String("redis-pass", "", "Redis password")
-		redisDB      = flag.Int("redis-db", 0, "Redis database")
-		workerAddr   = flag.String("addr", "localhost:9000", "Worker address")
-		schedulerURL = flag.String("scheduler", "http://localhost:8080", "Scheduler URL")
-		taskTypes    = flag.String("types", "generic", "Comma-separated task types")
+		redisAddr        = flag.String("redis", "localhost:6379", "Redis address")
+		redisPass        = flag.String("redis-pass", "", "Redis password")
+		redisDB          = flag.Int("redis-db", 0, "Redis database")
+		workerAddr       = flag.String("addr", "localhost:9000", "Worker address")
+		schedulerURL     = flag.String("scheduler", "http://localhost:8080", "Scheduler URL")
+		taskTypes        = flag.String("types", "generic", "Comma-separated task types")
+		concurrency      = flag.Int("concurrency", DefaultConcurrency, "How many tasks the worker runs at once across all of its task types")
+		typeWeightList   = flag.String("type-weights", "", "Comma-separated weights by which task types are polled first, such as etl=3,ci=1 (default 1 each)")
+		cpuCapacity      = flag.Float64("cpu", 0, "CPU the worker has for tasks; it only takes tasks whose cpu request fits, 0 for no limit")
+		memoryCapacity   = flag.Int64("memory-mb", 0, "Memory in MB the worker has for tasks; it only takes tasks whose memory_mb request fits, 0 for no limit")
+		typeConcurrency  = flag.String("type-concurrency", "", "Comma-separated caps on how many of the worker's slots a task type may take, such as etl=2,ci=1")
+		archiveDir       = flag.String("dlq-archive-dir", "", "Directory that receives dead letter entries evicted by the archive overflow policy")
+		archiveBucket    = flag.String("dlq-archive-bucket", "", "S3-compatible bucket that receives dead letter entries evicted by the archive overflow policy, in place of -dlq-archive-dir; credentials come from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN")
+		archivePrefix    = flag.String("dlq-archive-prefix", "", "Key prefix of the objects written to -dlq-archive-bucket")
+		archiveRegion    = flag.String("dlq-archive-region", "us-east-1", "Region of -dlq-archive-bucket")
+		archiveEndpoint  = flag.String("dlq-archive-endpoint", "", "Endpoint URL of the object storage service holding -dlq-archive-bucket (default: AWS S3 in -dlq-archive-region)")
+		alertURL         = flag.String("dlq-alert-url", "", "Webhook URL notified when a task is moved to a dead letter queue")
+		maxDeliveries    = flag.Int("max-deliveries", queue.DefaultMaxDeliveries, "Deliveries without an ack or nack before a task is quarantined, 0 for no limit")
+		cacheSize        = flag.Int("result-cache-size", 1000, "Completed task attempts whose results are kept to answer duplicate deliveries, 0 to disable")
+		cacheTTL         = flag.Duration("result-cache-ttl", time.Minute*10, "How long a cached task result is reused")
+		metricsAddr      = flag.String("metrics-addr", "", "Address serving Prometheus metrics at /metrics and /readyz (default: the port of -addr; off to disable)")
+		metricsLabels    = flag.String("metrics-labels", "", "Comma-separated name=value labels, such as cluster=eu-1, added to every metric the worker serves")
+		profiling        = flag.Bool("pprof", false, "Serve Go runtime profiles at /debug/pprof/ next to the metrics")
+		pool             = flag.String("pool", "", "Worker pool to join; pooled workers only run tasks of workflows pinned to the pool")
+		namespaces       = flag.String("namespaces", "", "Comma-separated workflow namespaces to dedicate the worker to; it then only runs their tasks, and only it and other workers of those namespaces do")
+		workerLabels     = flag.String("labels", "", "Comma-separated name=value labels, such as gpu=true,zone=us-east-1; the worker also runs tasks whose selector they match")
+		execCommands     = flag.Bool("exec-commands", false, "Run the commands of generic and ci tasks, and script tasks, whose executor is shell; without it commands are simulated and scripts fail")
+		execEnv          = flag.String("exec-env", "", "Comma-separated names of the worker's environment variables passed to task commands besides PATH, HOME, LANG, LC_ALL, TZ and TMPDIR")
+		statusSpoolDir   = flag.String("status-spool-dir", "", "Directory keeping task status updates the scheduler could not be reached for across restarts; empty keeps them in memory")
+		shutdownTimeout  = flag.Duration("shutdown-timeout", DefaultShutdownTimeout, "How long to wait for in-flight tasks on shutdown before requeueing them")
+		leaseRenew       = flag.Duration("lease-renew-interval", DefaultLeaseRenewInterval, "How often to renew the lease of a running task")
+		leaseTimeout     = flag.Duration("lease-timeout", DefaultLeaseTimeout, "The scheduler's -visibility-timeout; a task whose lease could not be renewed within it is stopped")
+		idleAfter        = flag.Duration("idle-after", DefaultIdleAfter, "How long all queues must stay empty before they are polled less often, 0 to always poll")
+		idlePoll         = flag.Duration("idle-poll-interval", DefaultIdlePollInterval, "How often an idle queue is polled when no wake-up arrives")
+		handlerVersion   = flag.String("handler-version", "", "Task handler version reported with each attempt (default: the binary's build version)")
+		reportIdle       = flag.Bool("report-idle", false, "Register the worker as idle while all of its queues are idle")
+		breakerThreshold = flag.Int("redis-breaker-threshold", queue.DefaultBreakerThreshold, "Consecutive failed Redis commands that make the worker fail fast until Redis recovers, 0 to disable")
+		breakerCooldown  = flag.Duration("redis-breaker-cooldown", queue.DefaultBreakerCooldown, "How long to fail fast before probing Redis again; doubles while Redis stays down")
 	)
 	flag.Parse()
 
@@ -289,14 +948,150 @@ func main() {
 	}
 	defer redisQueue.Close()
 
-	var types []string
-	if *taskTypes != "" {
-		types = []string{*taskTypes}
-	} else {
+	switch {
+	case *archiveDir != "" && *archiveBucket != "":
+		logger.Fatalf("Invalid -dlq-archive-bucket: set it or -dlq-archive-dir, not both")
+	case *archiveDir != "":
+		archiver, err := queue.NewFileArchiver(*archiveDir)
+		if err != nil {
+			logger.Fatalf("Failed to create dead letter archiver: %v", err)
+		}
+		redisQueue.SetDeadLetterArchiver(archiver)
+	case *archiveBucket != "":
+		store, err := queue.NewS3Store(*archiveEndpoint, *archiveBucket, *archiveRegion, queue.S3Credentials{
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		})
+		if err != nil {
+			logger.Fatalf("Invalid -dlq-archive-bucket: %v", err)
+		}
+		redisQueue.SetDeadLetterArchiver(queue.NewObjectArchiver(store, *archivePrefix))
+	}
+
+	if *alertURL != "" {
+		redisQueue.SetDeadLetterAlerter(queue.NewWebhookAlerter(*alertURL, *schedulerURL))
+	}
+
+	redisQueue.SetCircuitBreaker(*breakerThreshold, *breakerCooldown)
+	redisQueue.SetMaxDeliveries(*maxDeliveries)
+
+	if err := core.ValidatePool(*pool); err != nil {
+		logger.Fatalf("Invalid worker pool: %v", err)
+	}
+	redisQueue.SetWorkerPool(*pool)
+
+	if *namespaces != "" {
+		if *pool != "" {
+			logger.Fatalf("A worker cannot join a pool and be dedicated to namespaces")
+		}
+		var dedicated []string
+		for _, namespace := range strings.Split(*namespaces, ",") {
+			namespace = strings.TrimSpace(namespace)
+			if namespace == "" {
+				logger.Fatalf("Invalid worker namespaces: empty namespace in %q", *namespaces)
+			}
+			dedicated = append(dedicated, namespace)
+		}
+		redisQueue.SetWorkerNamespaces(dedicated)
+	}
+
+	routingLabels, err := telemetry.ParseLabels(*workerLabels)
+	if err == nil {
+		err = core.ValidateWorkerLabels(routingLabels)
+	}
+	if err != nil {
+		logger.Fatalf("Invalid -labels: %v", err)
+	}
+	redisQueue.SetWorkerLabels(routingLabels)
+
+	types := parseTaskTypes(*taskTypes)
+	if len(types) == 0 {
 		types = []string{"generic"}
 	}
 
+	if *concurrency < 1 {
+		logger.Fatalf("Invalid -concurrency: must be at least 1")
+	}
+	typeSlots, err := parseTypeConcurrency(*typeConcurrency)
+	if err != nil {
+		logger.Fatalf("Invalid -type-concurrency: %v", err)
+	}
+	if taskType, ok := unservedType(typeSlots, types); !ok {
+		logger.Fatalf("Invalid -type-concurrency: %s is not one of -types", taskType)
+	}
+	typeWeights, err := parseTypeWeights(*typeWeightList)
+	if err != nil {
+		logger.Fatalf("Invalid -type-weights: %v", err)
+	}
+	if taskType, ok := unservedType(typeWeights, types); !ok {
+		logger.Fatalf("Invalid -type-weights: %s is not one of -types", taskType)
+	}
+
+	if *cpuCapacity < 0 || *memoryCapacity < 0 {
+		logger.Fatalf("Invalid -cpu or -memory-mb: must not be negative")
+	}
+	var capacity *core.ResourceRequest
+	if *cpuCapacity > 0 || *memoryCapacity > 0 {
+		capacity = &core.ResourceRequest{CPU: *cpuCapacity, MemoryMB: *memoryCapacity}
+	}
+
+	if *leaseRenew <= 0 {
+		logger.Fatalf("Invalid -lease-renew-interval: must be positive")
+	}
+	if *leaseTimeout <= *leaseRenew {
+		logger.Fatalf("Invalid -lease-timeout: must be longer than -lease-renew-interval")
+	}
+
 	worker := NewWorker(*workerAddr, types, redisQueue, *schedulerURL, logger)
+	worker.results = newResultCache(*cacheSize, *cacheTTL)
+	worker.SetShutdownTimeout(*shutdownTimeout)
+	if *statusSpoolDir != "" {
+		if err := worker.SetStatusSpoolDir(*statusSpoolDir); err != nil {
+			logger.Fatalf("Invalid -status-spool-dir: %v", err)
+		}
+	}
+	var passEnv []string
+	for _, name := range strings.Split(*execEnv, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			passEnv = append(passEnv, name)
+		}
+	}
+	if len(passEnv) > 0 && !*execCommands {
+		logger.Fatalf("Invalid -exec-env: only applies with -exec-commands")
+	}
+	worker.SetCommandExecution(*execCommands, passEnv)
+	worker.SetLeaseRenewal(*leaseRenew, *leaseTimeout)
+	worker.SetConcurrency(*concurrency, typeSlots)
+	worker.SetTypeWeights(typeWeights)
+	redisQueue.SetWorkerSlots(worker.Slots())
+	worker.SetCapacity(capacity)
+	redisQueue.SetWorkerCapacity(capacity)
+	if *handlerVersion != "" {
+		worker.SetHandlerVersion(*handlerVersion)
+	}
+	worker.SetIdleBackoff(*idleAfter, *idlePoll, *reportIdle)
+
+	labels, err := telemetry.ParseLabels(*metricsLabels)
+	if err != nil {
+		logger.Fatalf("Invalid -metrics-labels: %v", err)
+	}
+	if _, ok := labels["worker"]; !ok {
+		labels["worker"] = worker.id
+	}
+	if err := worker.metrics.registry.SetConstLabels(labels); err != nil {
+		logger.Fatalf("Invalid -metrics-labels: %v", err)
+	}
+
+	endpoint, err := endpointAddr(*metricsAddr, *workerAddr)
+	if err != nil {
+		logger.Fatalf("Invalid -addr: %v", err)
+	}
+	if endpoint != "" {
+		go worker.serve(endpoint, *profiling)
+	} else if *profiling {
+		logger.Warnf("Ignoring -pprof: the worker's HTTP endpoint is off")
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
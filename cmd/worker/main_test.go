@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"flowctl/internal/core"
+)
+
+func TestTaskContextCancelEndsTimedTask(t *testing.T) {
+	ctx, cancel := taskContext(context.Background(), time.Hour)
+	if _, ok := ctx.Deadline(); !ok {
+		t.Fatal("task context has no deadline")
+	}
+
+	cancel()
+	select {
+	case <-ctx.Done():
+	default:
+		t.Fatal("cancel did not end the task context")
+	}
+}
+
+func TestTaskContextTimesOut(t *testing.T) {
+	ctx, cancel := taskContext(context.Background(), time.Millisecond)
+	defer cancel()
+
+	select {
+	case <-ctx.Done():
+		if ctx.Err() != context.DeadlineExceeded {
+			t.Errorf("task context ended with %v, want its deadline", ctx.Err())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("task context did not time out")
+	}
+}
+
+func TestTaskContextWithoutTimeout(t *testing.T) {
+	ctx, cancel := taskContext(context.Background(), 0)
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("task without a timeout has a deadline")
+	}
+	cancel()
+	if ctx.Err() != context.Canceled {
+		t.Errorf("task context ended with %v, want it cancelled", ctx.Err())
+	}
+}
+
+func TestInterruptedTaskIsRequeuedWithoutCountingAnAttempt(t *testing.T) {
+	broker := newFakeBroker()
+	w := newTestWorker()
+	w.queue = broker
+
+	task := &core.Task{ID: "task-1", Type: "etl", RetryCount: 2, MaxRetries: 2}
+	w.requeueInterrupted(context.Background(), task)
+
+	if len(broker.nacked) != 0 {
+		t.Errorf("interrupted task was nacked: %v", broker.nacked)
+	}
+	if delay, ok := broker.requeued["task-1"]; !ok || delay != 0 {
+		t.Errorf("interrupted task requeued = %v, %v; want it requeued at once", delay, ok)
+	}
+	if task.RetryCount != 2 {
+		t.Errorf("retry count = %d, want 2", task.RetryCount)
+	}
+}
+
+func TestStopRequeuesAbortedTasksBeforeDeregistering(t *testing.T) {
+	broker := newFakeBroker()
+	w := newTestWorker()
+	w.id = "w1"
+	w.queue = broker
+	w.stopCh = make(chan struct{})
+	w.drained = make(chan struct{})
+	w.running = make(map[string]context.CancelFunc)
+	w.shutdownTimeout = 10 * time.Millisecond
+	w.spool = newStatusSpool(nil, w.logger)
+
+	// A task loop running a task that outlives the shutdown timeout.
+	task := &core.Task{ID: "task-1", Type: "etl"}
+	taskCtx, cancel := context.WithCancel(context.Background())
+	w.running[task.ID] = cancel
+	w.inflight.Add(1)
+	go func() {
+		defer w.inflight.Done()
+		<-taskCtx.Done()
+		if w.shuttingDown() {
+			w.requeueInterrupted(context.Background(), task)
+		}
+	}()
+
+	w.Stop()
+
+	want := []string{"requeue task-1", "deregister w1"}
+	if len(broker.calls) != len(want) || broker.calls[0] != want[0] || broker.calls[1] != want[1] {
+		t.Errorf("calls = %v, want %v", broker.calls, want)
+	}
+	if len(broker.nacked) != 0 {
+		t.Errorf("aborted task was nacked: %v", broker.nacked)
+	}
+}
+
+func TestTypePickerPollsFirstByWeight(t *testing.T) {
+	p := newTypePicker([]string{"etl", "ci", "ml"}, map[string]int{"etl": 3})
+
+	firsts := make(map[string]int)
+	for i := 0; i < 50; i++ {
+		order := p.round()
+		if len(order) != 3 {
+			t.Fatalf("round %d polls %v, want every type", i, order)
+		}
+		firsts[order[0]]++
+	}
+	if want := map[string]int{"etl": 30, "ci": 10, "ml": 10}; !reflect.DeepEqual(firsts, want) {
+		t.Errorf("types polled first %v times, want %v", firsts, want)
+	}
+
+	// Smooth round-robin spreads the heavy type out instead of polling it
+	// first three rounds in a row.
+	p = newTypePicker([]string{"etl", "ci"}, map[string]int{"etl": 2})
+	var sequence []string
+	for i := 0; i < 6; i++ {
+		sequence = append(sequence, p.round()[0])
+	}
+	if want := []string{"etl", "ci", "etl", "etl", "ci", "etl"}; !reflect.DeepEqual(sequence, want) {
+		t.Errorf("polled first %v, want %v", sequence, want)
+	}
+}
+
+func TestParseTypeWeights(t *testing.T) {
+	weights, err := parseTypeWeights(" etl=3, ci=1 ")
+	if err != nil || !reflect.DeepEqual(weights, map[string]int{"etl": 3, "ci": 1}) {
+		t.Errorf("parseTypeWeights() = %v, %v", weights, err)
+	}
+	for _, s := range []string{"etl", "etl=0", "etl=-1", "etl=x", "=2", "etl=1,etl=2"} {
+		if _, err := parseTypeWeights(s); err == nil {
+			t.Errorf("parseTypeWeights(%q) accepted an invalid weight", s)
+		}
+	}
+
+	if taskType, ok := unservedType(map[string]int{"etl": 3, "gpu": 1}, []string{"etl", "ci"}); ok || taskType != "gpu" {
+		t.Errorf("unservedType() = %q, %v, want gpu not served", taskType, ok)
+	}
+}
+
+func TestPollTypesSkipsEmptyQueuesAndFullTypes(t *testing.T) {
+	broker := newFakeBroker()
+	broker.queued = map[string][]*core.Task{
+		"ci": {{ID: "ci-1", Type: "ci"}, {ID: "ci-2", Type: "ci"}},
+	}
+	w := newTestWorker()
+	w.queue = broker
+	w.typeSlots = map[string]int{"ci": 1}
+	w.typeBusy = make(map[string]int)
+	w.picker = newTypePicker([]string{"etl", "ci"}, nil)
+
+	task, err := w.pollTypes(context.Background())
+	if err != nil || task == nil || task.ID != "ci-1" {
+		t.Fatalf("pollTypes() = %v, %v, want ci-1 past the empty etl queue", task, err)
+	}
+	if w.typeBusy["ci"] != 1 || w.typeBusy["etl"] != 0 {
+		t.Errorf("slots held %v, want only ci's", w.typeBusy)
+	}
+
+	// ci holds all the slots it may, so only etl is polled.
+	broker.calls = nil
+	task, err = w.pollTypes(context.Background())
+	if err != nil || task != nil {
+		t.Fatalf("pollTypes() = %v, %v, want nothing while ci is at its cap", task, err)
+	}
+	if want := []string{"dequeue etl"}; !reflect.DeepEqual(broker.calls, want) {
+		t.Errorf("polled %v, want %v", broker.calls, want)
+	}
+}
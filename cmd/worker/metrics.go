@@ -0,0 +1,64 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+
+	"flowctl/internal/telemetry"
+)
+
+type workerMetrics struct {
+	registry      *telemetry.Registry
+	tasksDequeued *telemetry.CounterVec
+	taskRuns      *telemetry.CounterVec
+	taskDuration  *telemetry.HistogramVec
+	tasksRunning  *telemetry.GaugeVec
+	lastHeartbeat atomic.Int64
+}
+
+func newWorkerMetrics() *workerMetrics {
+	registry := telemetry.NewRegistry()
+	metrics := &workerMetrics{
+		registry:      registry,
+		tasksDequeued: registry.Counter("flowctl_worker_tasks_dequeued_total", "Tasks this worker dequeued.", "type"),
+		taskRuns:      registry.Counter("flowctl_worker_task_runs_total", "Task runs on this worker by outcome: completed, failed, cancelled, duplicate, lease_lost or interrupted.", "type", "outcome"),
+		taskDuration:  registry.Histogram("flowctl_worker_task_duration_seconds", "Wall time of task runs on this worker.", telemetry.DefaultBuckets, "type"),
+		tasksRunning:  registry.Gauge("flowctl_worker_tasks_running", "Tasks running on this worker.", "type"),
+	}
+
+	registry.GaugeFunc("flowctl_worker_heartbeat_age_seconds", "Time since this worker last heartbeated successfully.", nil, func() []telemetry.Sample {
+		last := metrics.lastHeartbeat.Load()
+		if last == 0 {
+			return nil
+		}
+		return []telemetry.Sample{{Value: time.Since(time.Unix(0, last)).Seconds()}}
+	})
+
+	return metrics
+}
+
+func (m *workerMetrics) heartbeat() {
+	m.lastHeartbeat.Store(time.Now().UnixNano())
+}
+
+// watch exports the worker's slots per task type, to compare with the tasks
+// running, and how many status updates are waiting in its spool.
+func (m *workerMetrics) watch(w *Worker) {
+	m.registry.GaugeFunc("flowctl_worker_slots", "Tasks of each type this worker can run at once.", []string{"type"}, func() []telemetry.Sample {
+		slots := w.Slots()
+		samples := make([]telemetry.Sample, 0, len(w.taskTypes))
+		for _, taskType := range w.taskTypes {
+			samples = append(samples, telemetry.Sample{LabelValues: []string{taskType}, Value: float64(slots[taskType])})
+		}
+		return samples
+	})
+	m.registry.GaugeFunc("flowctl_worker_status_updates_spooled", "Task status updates waiting to be delivered to the scheduler.", nil, func() []telemetry.Sample {
+		return []telemetry.Sample{{Value: float64(w.spool.len())}}
+	})
+}
+
+// recordRun counts a finished task run.
+func (m *workerMetrics) recordRun(taskType, outcome string, duration time.Duration) {
+	m.taskRuns.Inc(taskType, outcome)
+	m.taskDuration.Observe(duration.Seconds(), taskType)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"flowctl/internal/core"
+	"flowctl/internal/queue"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Output of an attempt is sent to the broker every outputFlushInterval.
+// Up to outputBufferChunks chunks wait to be sent; while the buffer is
+// full, because the broker is slow or down, further output is dropped and
+// counted instead of slowing the task down.
+const (
+	outputFlushInterval = time.Millisecond * 250
+	outputBufferChunks  = 256
+)
+
+// outputStreamer streams the stdout and stderr a handler writes for one
+// attempt of a task to the broker, where the API serves them live. A nil
+// streamer streams nothing.
+type outputStreamer struct {
+	broker queue.WorkerBroker
+	logger *logrus.Logger
+	ctx    context.Context
+
+	workflowID string
+	taskID     string
+	attempt    int
+
+	chunks chan core.TaskOutputChunk
+	done   chan struct{}
+
+	// written counts the bytes buffered so far, toward MaxTaskOutputSize.
+	mu        sync.Mutex
+	closed    bool
+	written   int64
+	dropped   int64
+	truncated bool
+}
+
+// newOutputStreamer starts streaming output of the task's current attempt.
+// ctx should outlive the attempt, so that its last output is still sent
+// after the attempt is cancelled.
+func newOutputStreamer(ctx context.Context, broker queue.WorkerBroker, logger *logrus.Logger, task *core.Task) *outputStreamer {
+	o := &outputStreamer{
+		broker:     broker,
+		logger:     logger,
+		ctx:        ctx,
+		workflowID: task.WorkflowID,
+		taskID:     task.ID,
+		attempt:    task.RetryCount + 1,
+		chunks:     make(chan core.TaskOutputChunk, outputBufferChunks),
+		done:       make(chan struct{}),
+	}
+	go o.run()
+	return o
+}
+
+func (o *outputStreamer) taskOutput() *core.TaskOutput {
+	return &core.TaskOutput{
+		Stdout: outputWriter{o, core.OutputStdout},
+		Stderr: outputWriter{o, core.OutputStderr},
+	}
+}
+
+type outputWriter struct {
+	streamer *outputStreamer
+	stream   core.OutputStream
+}
+
+// Write never fails: output that cannot be streamed is dropped.
+func (w outputWriter) Write(p []byte) (int, error) {
+	w.streamer.write(w.stream, p)
+	return len(p), nil
+}
+
+func (o *outputStreamer) write(stream core.OutputStream, p []byte) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.closed {
+		return
+	}
+
+	for len(p) > 0 {
+		remaining := core.MaxTaskOutputSize - o.written
+		if remaining <= 0 {
+			o.truncated = true
+			o.dropped += int64(len(p))
+			return
+		}
+
+		n := len(p)
+		if n > core.MaxTaskOutputChunkSize {
+			n = core.MaxTaskOutputChunkSize
+		}
+		if int64(n) > remaining {
+			n = int(remaining)
+		}
+
+		chunk := o.newChunk()
+		chunk.Stream = stream
+		chunk.Data = string(p[:n])
+
+		select {
+		case o.chunks <- chunk:
+			o.written += int64(n)
+		default:
+			o.dropped += int64(n)
+		}
+		p = p[n:]
+	}
+}
+
+func (o *outputStreamer) newChunk() core.TaskOutputChunk {
+	return core.TaskOutputChunk{
+		WorkflowID: o.workflowID,
+		TaskID:     o.taskID,
+		Attempt:    o.attempt,
+		Timestamp:  time.Now(),
+	}
+}
+
+func (o *outputStreamer) run() {
+	defer close(o.done)
+
+	ticker := time.NewTicker(outputFlushInterval)
+	defer ticker.Stop()
+
+	var batch []core.TaskOutputChunk
+	for {
+		select {
+		case chunk, ok := <-o.chunks:
+			if !ok {
+				o.send(batch)
+				return
+			}
+			batch = append(batch, chunk)
+		case <-ticker.C:
+			o.send(batch)
+			batch = nil
+		}
+	}
+}
+
+func (o *outputStreamer) send(batch []core.TaskOutputChunk) {
+	if len(batch) == 0 {
+		return
+	}
+
+	if err := o.broker.AppendTaskOutput(o.ctx, batch); err != nil {
+		o.logger.Warnf("Failed to stream output of task %s: %v", o.taskID, err)
+
+		var lost int64
+		for _, chunk := range batch {
+			lost += int64(len(chunk.Data))
+		}
+		o.mu.Lock()
+		o.dropped += lost
+		o.mu.Unlock()
+	}
+}
+
+// close sends the output still buffered, then the attempt's final chunk.
+// Output written after close is discarded.
+func (o *outputStreamer) close() {
+	if o == nil {
+		return
+	}
+
+	o.mu.Lock()
+	o.closed = true
+	close(o.chunks)
+	o.mu.Unlock()
+
+	<-o.done
+
+	o.mu.Lock()
+	final := o.newChunk()
+	final.EOF = true
+	final.Dropped = o.dropped
+	final.Truncated = o.truncated
+	o.mu.Unlock()
+
+	if final.Dropped > 0 {
+		o.logger.Warnf("Dropped %d bytes of output of task %s", final.Dropped, o.taskID)
+	}
+	if err := o.broker.AppendTaskOutput(o.ctx, []core.TaskOutputChunk{final}); err != nil {
+		o.logger.Warnf("Failed to end output of task %s: %v", o.taskID, err)
+	}
+}
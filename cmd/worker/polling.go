@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"flowctl/internal/core"
+)
+
+// DefaultTypeWeight is the polling weight of a task type given none.
+const DefaultTypeWeight = 1
+
+// A task loop that finds all of its worker's queues empty polls them again
+// after roundInterval, or sooner when the scheduler nudges the worker.
+const roundInterval = 200 * time.Millisecond
+
+// SetTypeWeights sets how often each task type is polled first, relative to
+// the worker's other types. Types without a weight get DefaultTypeWeight.
+func (w *Worker) SetTypeWeights(weights map[string]int) {
+	w.typeWeights = weights
+}
+
+// parseTypeWeights parses polling weights written as "etl=3,ci=1".
+func parseTypeWeights(s string) (map[string]int, error) {
+	return parseTypeCounts(s, "weight")
+}
+
+// parseTaskTypes parses a comma-separated list of task types, dropping
+// blanks and repeats.
+func parseTaskTypes(s string) []string {
+	var types []string
+	seen := make(map[string]bool)
+	for _, taskType := range strings.Split(s, ",") {
+		taskType = strings.TrimSpace(taskType)
+		if taskType == "" || seen[taskType] {
+			continue
+		}
+		seen[taskType] = true
+		types = append(types, taskType)
+	}
+	return types
+}
+
+// unservedType returns a task type given a count that is not among types,
+// and false, if there is one.
+func unservedType(counts map[string]int, types []string) (string, bool) {
+	for taskType := range counts {
+		served := false
+		for _, t := range types {
+			served = served || t == taskType
+		}
+		if !served {
+			return taskType, false
+		}
+	}
+	return "", true
+}
+
+// typePicker orders a worker's task types for each polling round by smooth
+// weighted round-robin. Over any run of rounds each type is polled first in
+// proportion to its weight, and the other types follow it in turn, so a
+// type whose queue is empty never keeps a loop from the others.
+type typePicker struct {
+	mu      sync.Mutex
+	types   []string
+	weights []int
+	current []int
+	total   int
+}
+
+func newTypePicker(types []string, weights map[string]int) *typePicker {
+	p := &typePicker{
+		types:   types,
+		weights: make([]int, len(types)),
+		current: make([]int, len(types)),
+	}
+	for i, taskType := range types {
+		p.weights[i] = DefaultTypeWeight
+		if weight, ok := weights[taskType]; ok {
+			p.weights[i] = weight
+		}
+		p.total += p.weights[i]
+	}
+	return p
+}
+
+// weightsByType returns the weight of each task type.
+func (p *typePicker) weightsByType() map[string]int {
+	weights := make(map[string]int, len(p.types))
+	for i, taskType := range p.types {
+		weights[taskType] = p.weights[i]
+	}
+	return weights
+}
+
+// round returns the task types in the order to poll them for one task.
+func (p *typePicker) round() []string {
+	p.mu.Lock()
+	first := 0
+	for i := range p.types {
+		p.current[i] += p.weights[i]
+		if p.current[i] > p.current[first] {
+			first = i
+		}
+	}
+	p.current[first] -= p.total
+	p.mu.Unlock()
+
+	order := make([]string, len(p.types))
+	for i := range order {
+		order[i] = p.types[(first+i)%len(p.types)]
+	}
+	return order
+}
+
+// pollTypes polls the worker's task types once each, in the order of the
+// next round, skipping types that hold all the slots they may, and returns
+// the first task dequeued with its type's slot reserved. It returns nil if
+// every queue polled was empty. A quarantined task is returned with an
+// error wrapping core.ErrTaskQuarantined and no slot reserved.
+func (w *Worker) pollTypes(dequeueCtx context.Context) (*core.Task, error) {
+	for _, taskType := range w.picker.round() {
+		if !w.reserveType(taskType) {
+			continue
+		}
+
+		task, err := w.queue.DequeueTask(dequeueCtx, w.id, taskType, 0)
+		if err == nil && task != nil {
+			return task, nil
+		}
+		w.releaseType(taskType)
+		if err != nil {
+			return task, err
+		}
+	}
+	return nil, nil
+}
+
+// waitForWork blocks a task loop until the scheduler nudges the worker or
+// wait passes. It returns false once dequeuing has stopped.
+func (w *Worker) waitForWork(dequeueCtx context.Context, wait time.Duration) bool {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-dequeueCtx.Done():
+		return false
+	case <-w.wake:
+	case <-timer.C:
+	}
+	return true
+}
+
+// processTasks is a task loop: it holds one of the worker's slots, polling
+// its task types for a task and running it, until the worker stops. A loop
+// that has found every queue empty for idleAfter polls them only every
+// idlePollInterval, or when nudged.
+func (w *Worker) processTasks(ctx, dequeueCtx context.Context) {
+	lastTask := time.Now()
+	idle := false
+	failures := 0
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.stopCh:
+			return
+		default:
+		}
+
+		task, err := w.pollTypes(dequeueCtx)
+		if errors.Is(err, core.ErrTaskQuarantined) {
+			w.logger.Warnf("Skipping poison task: %v", err)
+			if task != nil {
+				w.notifyTaskStatus(task, "failed", nil, err.Error(), nil)
+			}
+			continue
+		}
+		if err != nil && dequeueCtx.Err() != nil {
+			return
+		}
+		if err != nil {
+			// Only the first failure of an outage is logged; the queue
+			// logs when Redis becomes unavailable and recovers.
+			if failures == 0 {
+				w.logger.Errorf("Failed to dequeue task: %v", err)
+			}
+			failures++
+			if sleepContext(dequeueCtx, dequeueBackoff(failures)) != nil {
+				return
+			}
+			continue
+		}
+		if failures > 0 {
+			w.logger.Infof("Dequeueing tasks again after %d failed attempts", failures)
+			failures = 0
+		}
+
+		if task == nil {
+			if !idle && w.idleAfter > 0 && time.Since(lastTask) >= w.idleAfter {
+				idle = true
+				w.setIdle(ctx, true)
+			}
+			wait := roundInterval
+			if idle {
+				wait = w.idlePollInterval
+			}
+			if !w.waitForWork(dequeueCtx, wait) {
+				return
+			}
+			continue
+		}
+
+		if idle {
+			idle = false
+			w.setIdle(ctx, false)
+		}
+		lastTask = time.Now()
+
+		w.metrics.tasksDequeued.Inc(task.Type)
+		w.executeTask(ctx, task)
+		w.releaseType(task.Type)
+		w.capacityFreed()
+	}
+}
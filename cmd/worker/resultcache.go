@@ -0,0 +1,95 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// resultKey identifies one attempt of a task. A retry increments the
+// attempt, so only redeliveries of an attempt that already completed hit
+// the cache.
+type resultKey struct {
+	taskID  string
+	attempt int
+}
+
+type cachedResult struct {
+	key      resultKey
+	result   map[string]interface{}
+	storedAt time.Time
+}
+
+// resultCache remembers the results of recently completed task attempts,
+// evicting the least recently used once it holds size entries. Entries
+// older than ttl are ignored. A nil cache stores nothing.
+type resultCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	order   *list.List
+	entries map[resultKey]*list.Element
+}
+
+// newResultCache returns nil, disabling the cache, when size is not
+// positive.
+func newResultCache(size int, ttl time.Duration) *resultCache {
+	if size <= 0 {
+		return nil
+	}
+	return &resultCache{
+		size:    size,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: make(map[resultKey]*list.Element),
+	}
+}
+
+func (c *resultCache) get(taskID string, attempt int) (map[string]interface{}, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[resultKey{taskID, attempt}]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*cachedResult)
+	if c.ttl > 0 && time.Since(entry.storedAt) > c.ttl {
+		c.order.Remove(elem)
+		delete(c.entries, entry.key)
+		return nil, false
+	}
+
+	c.order.MoveToFront(elem)
+	return entry.result, true
+}
+
+func (c *resultCache) put(taskID string, attempt int, result map[string]interface{}) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := resultKey{taskID, attempt}
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*cachedResult)
+		entry.result = result
+		entry.storedAt = time.Now()
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&cachedResult{key: key, result: result, storedAt: time.Now()})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cachedResult).key)
+	}
+}
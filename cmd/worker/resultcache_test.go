@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"flowctl/internal/core"
+)
+
+func TestResultCacheKeysByAttempt(t *testing.T) {
+	c := newResultCache(10, time.Minute)
+	c.put("task-1", 0, map[string]interface{}{"rows": 10})
+
+	if result, ok := c.get("task-1", 0); !ok || result["rows"] != 10 {
+		t.Errorf("get(task-1, 0) = %v, %v; want the stored result", result, ok)
+	}
+	if _, ok := c.get("task-1", 1); ok {
+		t.Error("a retry hit the result of the attempt before it")
+	}
+}
+
+func TestResultCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newResultCache(2, 0)
+	c.put("a", 0, nil)
+	c.put("b", 0, nil)
+	c.get("a", 0)
+	c.put("c", 0, nil)
+
+	if _, ok := c.get("b", 0); ok {
+		t.Error("least recently used entry b was kept")
+	}
+	for _, id := range []string{"a", "c"} {
+		if _, ok := c.get(id, 0); !ok {
+			t.Errorf("entry %s was evicted", id)
+		}
+	}
+	if c.order.Len() != 2 || len(c.entries) != 2 {
+		t.Errorf("cache holds %d entries in order and %d by key, want 2", c.order.Len(), len(c.entries))
+	}
+}
+
+func TestResultCacheIgnoresExpiredEntries(t *testing.T) {
+	c := newResultCache(10, time.Minute)
+	c.put("task-1", 0, nil)
+	c.entries[resultKey{"task-1", 0}].Value.(*cachedResult).storedAt = time.Now().Add(-2 * time.Minute)
+
+	if _, ok := c.get("task-1", 0); ok {
+		t.Error("expired entry was returned")
+	}
+	if len(c.entries) != 0 {
+		t.Errorf("expired entry was kept: %d entries", len(c.entries))
+	}
+}
+
+func TestResultCacheDisabled(t *testing.T) {
+	c := newResultCache(0, time.Minute)
+	if c != nil {
+		t.Fatalf("newResultCache(0) = %v, want nil", c)
+	}
+	c.put("task-1", 0, nil)
+	if _, ok := c.get("task-1", 0); ok {
+		t.Error("disabled cache returned a result")
+	}
+}
+
+func TestResultCacheStaysWithinSize(t *testing.T) {
+	c := newResultCache(5, 0)
+	for i := 0; i < 50; i++ {
+		c.put(strconv.Itoa(i), 0, nil)
+	}
+	if c.order.Len() != 5 || len(c.entries) != 5 {
+		t.Errorf("cache holds %d entries in order and %d by key, want 5", c.order.Len(), len(c.entries))
+	}
+	for i := 45; i < 50; i++ {
+		if _, ok := c.get(strconv.Itoa(i), 0); !ok {
+			t.Errorf("newest entry %d was evicted", i)
+		}
+	}
+}
+
+func TestDuplicateOfCompletedAttemptIsAckedFromCache(t *testing.T) {
+	broker := newFakeBroker()
+	w := newTestWorker()
+	w.queue = broker
+	w.results = newResultCache(10, time.Minute)
+
+	task := &core.Task{ID: "task-1", Type: "etl", RetryCount: 1}
+	if w.completeFromCachedResult(context.Background(), task) {
+		t.Fatal("attempt that never completed was answered from the cache")
+	}
+
+	w.results.put("task-1", 1, map[string]interface{}{"rows": 10})
+	if !w.completeFromCachedResult(context.Background(), task) {
+		t.Fatal("duplicate delivery of a completed attempt was not answered from the cache")
+	}
+	if len(broker.acked) != 1 || broker.acked[0] != "task-1" {
+		t.Errorf("acked = %v, want task-1", broker.acked)
+	}
+}
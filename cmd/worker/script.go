@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"flowctl/internal/core"
+)
+
+// scriptInterpreter is a language scripts may be written in: the program
+// that runs them and the extension their files are given.
+type scriptInterpreter struct {
+	program   string
+	extension string
+}
+
+var scriptInterpreters = map[string]scriptInterpreter{
+	"bash":   {program: "bash", extension: ".sh"},
+	"python": {program: "python3", extension: ".py"},
+}
+
+// defaultScriptInterpreter runs scripts whose payload names none.
+const defaultScriptInterpreter = "bash"
+
+// runScriptTask writes the payload's script to a temporary file and runs it
+// with its interpreter, like a generic task's command: args are passed to
+// the script, and env, working_dir and timeout apply. The file is removed
+// once the script has finished. A script is never simulated: a worker that
+// does not run the task's commands fails it.
+func (w *Worker) runScriptTask(ctx context.Context, task *core.Task) (map[string]interface{}, error) {
+	if !w.runsCommands(task) {
+		return nil, fmt.Errorf("script tasks need the shell executor and a worker started with -exec-commands")
+	}
+
+	script, ok := task.Payload["script"].(string)
+	if !ok || strings.TrimSpace(script) == "" {
+		return nil, fmt.Errorf("missing or invalid script")
+	}
+
+	language := defaultScriptInterpreter
+	if raw, ok := task.Payload["interpreter"]; ok {
+		language, ok = raw.(string)
+		if !ok {
+			return nil, fmt.Errorf("interpreter must be a string")
+		}
+	}
+	interpreter, ok := scriptInterpreters[language]
+	if !ok {
+		return nil, fmt.Errorf("unknown interpreter %q; use bash or python", language)
+	}
+
+	args, err := payloadArgs(task.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.CreateTemp("", "flowctl-script-*"+interpreter.extension)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create script file: %w", err)
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.WriteString(script)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write script file: %w", err)
+	}
+
+	spec := &commandSpec{
+		command: language + " script",
+		name:    interpreter.program,
+		args:    append([]string{file.Name()}, args...),
+	}
+	if err := spec.readOptions(task.Payload); err != nil {
+		return nil, err
+	}
+
+	w.logger.Infof("Running %s script of task %s", language, task.ID)
+
+	output := core.TaskOutputFrom(ctx)
+	stdout := &lineWriter{w: output.Stdout}
+	stderr := &lineWriter{w: output.Stderr}
+	ctx = core.WithTaskOutput(ctx, &core.TaskOutput{Stdout: stdout, Stderr: stderr})
+
+	result, err := w.runCommand(ctx, task, spec)
+	stdout.Flush()
+	stderr.Flush()
+	if err != nil {
+		return nil, err
+	}
+
+	delete(result, "command")
+	result["interpreter"] = language
+	return result, nil
+}
+
+// lineWriter passes what is written to it on a line at a time, so each line
+// of output is streamed as soon as it is complete. A line longer than a
+// stream chunk is passed on in pieces. Flush passes on an unfinished last
+// line.
+type lineWriter struct {
+	w   io.Writer
+	buf []byte
+}
+
+func (l *lineWriter) Write(p []byte) (int, error) {
+	l.buf = append(l.buf, p...)
+
+	rest := l.buf
+	for {
+		i := bytes.IndexByte(rest, '\n')
+		if i < 0 {
+			break
+		}
+		l.w.Write(rest[:i+1])
+		rest = rest[i+1:]
+	}
+	for len(rest) >= core.MaxTaskOutputChunkSize {
+		l.w.Write(rest[:core.MaxTaskOutputChunkSize])
+		rest = rest[core.MaxTaskOutputChunkSize:]
+	}
+
+	l.buf = append(l.buf[:0], rest...)
+	return len(p), nil
+}
+
+func (l *lineWriter) Flush() {
+	if len(l.buf) > 0 {
+		l.w.Write(l.buf)
+		l.buf = l.buf[:0]
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"flowctl/internal/core"
+)
+
+func newTestWorker() *Worker {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return &Worker{logger: logger}
+}
+
+func TestScriptTaskNeedsCommandExecution(t *testing.T) {
+	task := &core.Task{
+		ID:      "task-1",
+		Type:    core.ScriptTaskType,
+		Payload: map[string]interface{}{"script": "echo hi"},
+	}
+
+	w := newTestWorker()
+	if _, err := w.runScriptTask(context.Background(), withExecutor(task, core.ExecutorShell)); err == nil || !strings.Contains(err.Error(), "-exec-commands") {
+		t.Errorf("script ran on a worker without -exec-commands: err = %v", err)
+	}
+
+	w.SetCommandExecution(true, nil)
+	if _, err := w.runScriptTask(context.Background(), task); err == nil {
+		t.Error("script ran without the shell executor")
+	}
+}
+
+func TestScriptTaskGetsMinimalEnvironment(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash is not installed")
+	}
+	t.Setenv("FLOWCTL_TEST_SECRET", "hunter2")
+
+	w := newTestWorker()
+	w.SetCommandExecution(true, nil)
+	task := withExecutor(&core.Task{
+		ID:      "task-1",
+		Type:    core.ScriptTaskType,
+		Payload: map[string]interface{}{"script": `echo "secret=$FLOWCTL_TEST_SECRET task=$FLOWCTL_TASK_ID"`},
+	}, core.ExecutorShell)
+
+	result, err := w.runScriptTask(context.Background(), task)
+	if err != nil {
+		t.Fatalf("runScriptTask: %v", err)
+	}
+	if stdout := result["stdout"]; stdout != "secret= task=task-1\n" {
+		t.Errorf("stdout = %q, want the worker's variable left out", stdout)
+	}
+}
+
+func withExecutor(task *core.Task, executor core.Executor) *core.Task {
+	copied := *task
+	copied.Executor = executor
+	return &copied
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+)
+
+// metricsOff is the -metrics-addr that turns the worker's HTTP endpoint
+// off.
+const metricsOff = "off"
+
+// endpointAddr returns the address the worker's HTTP endpoint listens on
+// for its -metrics-addr and -addr, or "" when it is turned off.
+func endpointAddr(metricsAddr, workerAddr string) (string, error) {
+	switch metricsAddr {
+	case metricsOff:
+		return "", nil
+	case "":
+		return listenAddr(workerAddr)
+	}
+	return metricsAddr, nil
+}
+
+// listenAddr returns the address the worker's HTTP endpoint listens on for
+// the address it registers: the same port, on every interface.
+func listenAddr(addr string) (string, error) {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", err
+	}
+	if port == "" {
+		return "", fmt.Errorf("address %q has no port", addr)
+	}
+	return ":" + port, nil
+}
+
+// handler serves the worker's HTTP endpoint: Prometheus metrics at
+// /metrics, the broker's health at /readyz and, with profiling set, the
+// Go runtime's profiles at /debug/pprof/.
+func (w *Worker) handler(profiling bool) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", w.metrics.registry)
+	mux.HandleFunc("/readyz", w.serveReadiness)
+
+	if profiling {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+	return mux
+}
+
+// serve runs the worker's HTTP endpoint on addr until the process exits.
+func (w *Worker) serve(addr string, profiling bool) {
+	w.logger.Infof("Serving metrics on %s", addr)
+	if profiling {
+		w.logger.Warnf("Serving Go profiles at /debug/pprof/ on %s", addr)
+	}
+	if err := http.ListenAndServe(addr, w.handler(profiling)); err != nil {
+		w.logger.Errorf("Worker HTTP endpoint failed: %v", err)
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestEndpointAddr(t *testing.T) {
+	tests := []struct {
+		name        string
+		metricsAddr string
+		workerAddr  string
+		want        string
+	}{
+		{"port of -addr", "", "worker-etl:9000", ":9000"},
+		{"explicit address", ":9100", "worker-etl:9000", ":9100"},
+		{"off", "off", "worker-etl:9000", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := endpointAddr(tt.metricsAddr, tt.workerAddr)
+			if err != nil || got != tt.want {
+				t.Errorf("endpointAddr(%q, %q) = %q, %v, want %q", tt.metricsAddr, tt.workerAddr, got, err, tt.want)
+			}
+		})
+	}
+
+	if _, err := endpointAddr("", "worker-etl"); err == nil {
+		t.Error("endpointAddr accepted an -addr without a port")
+	}
+}
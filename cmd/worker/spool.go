@@ -0,0 +1,379 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxSpooledUpdates bounds how many undelivered status updates a worker
+// keeps. Past it the oldest are dropped.
+const maxSpooledUpdates = 10000
+
+// statusTimeout bounds one attempt to deliver a status update.
+const statusTimeout = 10 * time.Second
+
+// maxStatusAttempts is how many times the spool delivers an update the
+// scheduler answers with a server error before setting it aside. Updates
+// the scheduler could not be reached for are retried without a limit.
+const maxStatusAttempts = 20
+
+// rejectedSpoolDir is the subdirectory of the spool directory that updates
+// set aside are moved to, for an operator to look at.
+const rejectedSpoolDir = "rejected"
+
+// spoolFlushTimeout is how long a stopping worker keeps trying to deliver
+// spooled status updates.
+const spoolFlushTimeout = 10 * time.Second
+
+var statusClient = &http.Client{Timeout: statusTimeout}
+
+// spoolBackoff is how long the spool waits after failures failed
+// deliveries in a row.
+var spoolBackoff = dequeueBackoff
+
+// errStatusRejected is returned for a status update the scheduler refused,
+// which delivering again would not change.
+var errStatusRejected = errors.New("scheduler rejected the task status update")
+
+// errStatusFailed is returned for a status update the scheduler failed to
+// apply, which it may yet apply if delivered again.
+var errStatusFailed = errors.New("scheduler failed to apply the task status update")
+
+// statusUpdate is one task status report for the scheduler. file is where
+// the spool keeps it on disk, if anywhere, and failures counts the
+// deliveries the scheduler failed to apply.
+type statusUpdate struct {
+	TaskID   string                 `json:"task_id"`
+	Payload  map[string]interface{} `json:"payload"`
+	file     string
+	failures int
+}
+
+// statusSpool holds the task status updates the scheduler could not be
+// reached for, and delivers them in the order they were made, backing off
+// while delivery fails. With a directory, each update is also kept there
+// until it is delivered, so updates spooled before a restart are delivered
+// after it.
+type statusSpool struct {
+	mu      sync.Mutex
+	dir     string
+	pending []*statusUpdate
+	seq     uint64
+	wake    chan struct{}
+	deliver func(*statusUpdate) error
+	logger  *logrus.Logger
+}
+
+func newStatusSpool(deliver func(*statusUpdate) error, logger *logrus.Logger) *statusSpool {
+	return &statusSpool{
+		wake:    make(chan struct{}, 1),
+		deliver: deliver,
+		logger:  logger,
+	}
+}
+
+// SetStatusSpoolDir keeps the worker's undelivered status updates in dir,
+// and spools the updates left there by an earlier run for delivery.
+func (w *Worker) SetStatusSpoolDir(dir string) error {
+	return w.spool.open(dir)
+}
+
+func (s *statusSpool) open(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create status spool directory: %w", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read status spool directory: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.dir = dir
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || filepath.Ext(name) != ".json" {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read spooled status update %s: %w", name, err)
+		}
+		var update statusUpdate
+		if err := json.Unmarshal(data, &update); err != nil || update.TaskID == "" {
+			s.logger.Errorf("Dropping unreadable spooled status update %s", name)
+			os.Remove(path)
+			continue
+		}
+		update.file = path
+		s.pending = append(s.pending, &update)
+	}
+
+	if len(s.pending) > 0 {
+		s.logger.Infof("Delivering %d task status updates spooled in %s", len(s.pending), dir)
+		s.nudge()
+	}
+	return nil
+}
+
+// post delivers an update at once unless earlier ones are still spooled,
+// and spools it if it cannot be delivered now.
+func (s *statusSpool) post(update *statusUpdate) {
+	s.mu.Lock()
+	queued := len(s.pending) > 0
+	s.mu.Unlock()
+
+	if !queued {
+		err := s.deliver(update)
+		if err == nil {
+			return
+		}
+		if errors.Is(err, errStatusRejected) {
+			s.logger.Errorf("Failed to notify status of task %s: %v", update.TaskID, err)
+			return
+		}
+		s.logger.Warnf("Failed to notify status of task %s, spooling it: %v", update.TaskID, err)
+	}
+
+	s.push(update)
+}
+
+func (s *statusSpool) push(update *statusUpdate) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.dir != "" {
+		if err := s.write(update); err != nil {
+			s.logger.Errorf("Failed to write status update of task %s to the spool, keeping it in memory: %v", update.TaskID, err)
+		}
+	}
+	s.pending = append(s.pending, update)
+
+	if over := len(s.pending) - maxSpooledUpdates; over > 0 {
+		for _, dropped := range s.pending[:over] {
+			s.logger.Errorf("Status spool is full, dropping status update of task %s", dropped.TaskID)
+			s.removeFile(dropped)
+		}
+		s.pending = append([]*statusUpdate(nil), s.pending[over:]...)
+	}
+	s.nudge()
+}
+
+// write stores an update in the spool directory. It is written to a
+// temporary file first, so a crash never leaves half an update behind.
+func (s *statusSpool) write(update *statusUpdate) error {
+	data, err := json.Marshal(update)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, ".status-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	s.seq++
+	path := filepath.Join(s.dir, fmt.Sprintf("%020d-%06d.json", time.Now().UnixNano(), s.seq))
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	update.file = path
+	return nil
+}
+
+func (s *statusSpool) removeFile(update *statusUpdate) {
+	if update.file == "" {
+		return
+	}
+	if err := os.Remove(update.file); err != nil && !os.IsNotExist(err) {
+		s.logger.Errorf("Failed to remove spooled status update %s: %v", update.file, err)
+	}
+}
+
+func (s *statusSpool) nudge() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *statusSpool) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pending)
+}
+
+func (s *statusSpool) head() *statusUpdate {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.pending) == 0 {
+		return nil
+	}
+	return s.pending[0]
+}
+
+// done removes a delivered or rejected update, unless the spool dropped
+// it while it was being delivered.
+func (s *statusSpool) done(update *statusUpdate) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.pending) > 0 && s.pending[0] == update {
+		s.pending = s.pending[1:]
+		s.removeFile(update)
+	}
+}
+
+// run delivers spooled updates until ctx is cancelled. While the scheduler
+// cannot be reached it backs off like a failing dequeue.
+func (s *statusSpool) run(ctx context.Context) {
+	failures := 0
+	for {
+		update := s.head()
+		if update == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.wake:
+			}
+			continue
+		}
+
+		err := s.deliver(update)
+		if errors.Is(err, errStatusFailed) {
+			// The scheduler is up but keeps failing on this update, which
+			// would otherwise hold back every update behind it.
+			update.failures++
+			if update.failures >= maxStatusAttempts {
+				s.reject(update, err)
+				continue
+			}
+		}
+		if err != nil && !errors.Is(err, errStatusRejected) {
+			if failures == 0 {
+				s.logger.Warnf("Failed to deliver %d spooled task status updates, retrying: %v", s.len(), err)
+			}
+			failures++
+			if sleepContext(ctx, spoolBackoff(failures)) != nil {
+				return
+			}
+			continue
+		}
+		if err != nil {
+			s.logger.Errorf("Dropping spooled status update of task %s: %v", update.TaskID, err)
+		}
+		s.done(update)
+
+		if failures > 0 && s.len() == 0 {
+			s.logger.Infof("Delivered spooled task status updates after %d failed attempts", failures)
+			failures = 0
+		}
+	}
+}
+
+// reject sets aside the update at the head of the spool after the
+// scheduler failed to apply it maxStatusAttempts times. With a spool
+// directory it is moved to its rejected subdirectory, otherwise it is
+// dropped.
+func (s *statusSpool) reject(update *statusUpdate, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.pending) == 0 || s.pending[0] != update {
+		return
+	}
+	s.pending = s.pending[1:]
+
+	if update.file == "" {
+		s.logger.Errorf("Dropping status update of task %s after %d failed deliveries: %v", update.TaskID, update.failures, err)
+		return
+	}
+
+	dir := filepath.Join(s.dir, rejectedSpoolDir)
+	path := filepath.Join(dir, filepath.Base(update.file))
+	if mkErr := os.MkdirAll(dir, 0o755); mkErr != nil {
+		s.logger.Errorf("Failed to create %s, dropping status update of task %s: %v", dir, update.TaskID, mkErr)
+		s.removeFile(update)
+		return
+	}
+	if mvErr := os.Rename(update.file, path); mvErr != nil {
+		s.logger.Errorf("Failed to set aside status update of task %s, dropping it: %v", update.TaskID, mvErr)
+		s.removeFile(update)
+		return
+	}
+	s.logger.Errorf("Set aside status update of task %s in %s after %d failed deliveries: %v", update.TaskID, path, update.failures, err)
+}
+
+// flush waits up to timeout for the spool to empty, and reports what is
+// left in it.
+func (s *statusSpool) flush(timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for s.len() > 0 && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	left := s.len()
+	switch {
+	case left == 0:
+	case s.dir != "":
+		s.logger.Warnf("%d task status updates remain spooled in %s and will be delivered when the worker restarts", left, s.dir)
+	default:
+		s.logger.Errorf("Dropping %d undelivered task status updates", left)
+	}
+}
+
+// deliverStatus posts a status update to the scheduler. It returns an error
+// wrapping errStatusRejected when the scheduler answers with a client
+// error other than a timeout or rate limit, such as 404 for a task that no
+// longer exists or 409 for a report on an earlier attempt or on a task that
+// has already finished, and one wrapping
+// errStatusFailed when it answers with a server error other than one a
+// proxy or a restarting scheduler gives.
+func (w *Worker) deliverStatus(update *statusUpdate) error {
+	jsonData, err := json.Marshal(update.Payload)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errStatusRejected, err)
+	}
+
+	url := fmt.Sprintf("%s/api/v1/tasks/%s/status", w.schedulerURL, update.TaskID)
+	resp, err := statusClient.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return nil
+	case resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests,
+		resp.StatusCode == http.StatusBadGateway, resp.StatusCode == http.StatusServiceUnavailable,
+		resp.StatusCode == http.StatusGatewayTimeout:
+		return fmt.Errorf("status code %d", resp.StatusCode)
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return fmt.Errorf("%w: status code %d", errStatusRejected, resp.StatusCode)
+	case resp.StatusCode >= 500:
+		return fmt.Errorf("%w: status code %d", errStatusFailed, resp.StatusCode)
+	default:
+		return fmt.Errorf("status code %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingDeliver answers every delivery of the update for task "bad" with
+// err, delivers every other update, and records the order it saw them in.
+type countingDeliver struct {
+	mu        sync.Mutex
+	err       error
+	bad       int
+	delivered []string
+}
+
+func (d *countingDeliver) deliver(update *statusUpdate) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if update.TaskID == "bad" {
+		d.bad++
+		return d.err
+	}
+	d.delivered = append(d.delivered, update.TaskID)
+	return nil
+}
+
+func TestSpoolSetsAsideUpdatesTheSchedulerFailsOn(t *testing.T) {
+	origBackoff := spoolBackoff
+	spoolBackoff = func(int) time.Duration { return time.Millisecond }
+	defer func() { spoolBackoff = origBackoff }()
+
+	d := &countingDeliver{err: fmt.Errorf("%w: status code 500", errStatusFailed)}
+	spool := newStatusSpool(d.deliver, newTestWorker().logger)
+	dir := t.TempDir()
+	if err := spool.open(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	spool.push(&statusUpdate{TaskID: "bad", Payload: map[string]interface{}{"status": "completed"}})
+	spool.push(&statusUpdate{TaskID: "good", Payload: map[string]interface{}{"status": "completed"}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go spool.run(ctx)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for spool.len() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := spool.len(); n != 0 {
+		t.Fatalf("%d updates still spooled, want the failing one set aside", n)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.bad != maxStatusAttempts {
+		t.Errorf("failing update delivered %d times, want %d", d.bad, maxStatusAttempts)
+	}
+	if len(d.delivered) != 1 || d.delivered[0] != "good" {
+		t.Errorf("delivered %v, want the update behind the failing one", d.delivered)
+	}
+	rejected, err := os.ReadDir(filepath.Join(dir, rejectedSpoolDir))
+	if err != nil || len(rejected) != 1 {
+		t.Errorf("rejected directory holds %d files (%v), want the failing update", len(rejected), err)
+	}
+}
+
+func TestSpoolRetriesUnreachableSchedulerWithoutLimit(t *testing.T) {
+	origBackoff := spoolBackoff
+	spoolBackoff = func(int) time.Duration { return time.Millisecond }
+	defer func() { spoolBackoff = origBackoff }()
+
+	d := &countingDeliver{err: fmt.Errorf("status code 503")}
+	spool := newStatusSpool(d.deliver, newTestWorker().logger)
+	spool.push(&statusUpdate{TaskID: "bad"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		spool.run(ctx)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		d.mu.Lock()
+		n := d.bad
+		d.mu.Unlock()
+		if n > 2*maxStatusAttempts {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if spool.len() != 1 {
+		t.Errorf("update dropped after %d deliveries to an unreachable scheduler", d.bad)
+	}
+}
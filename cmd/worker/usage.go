@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"flowctl/internal/core"
+)
+
+// commandUsage adds up the resources used by the commands a task runs.
+// Built-in handlers run inside the worker process alongside other tasks,
+// so only the usage of commands, each a process of its own, is reported.
+type commandUsage struct {
+	mu      sync.Mutex
+	ran     bool
+	cpuTime time.Duration
+	maxRSS  int64
+}
+
+type commandUsageKey struct{}
+
+func withCommandUsage(ctx context.Context, usage *commandUsage) context.Context {
+	return context.WithValue(ctx, commandUsageKey{}, usage)
+}
+
+// commandUsageFrom returns the usage the commands run under ctx are added
+// to, or nil outside a task.
+func commandUsageFrom(ctx context.Context) *commandUsage {
+	usage, _ := ctx.Value(commandUsageKey{}).(*commandUsage)
+	return usage
+}
+
+// record adds the usage of a command that has exited.
+func (u *commandUsage) record(state *os.ProcessState) {
+	if u == nil || state == nil {
+		return
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.ran = true
+	u.cpuTime += state.UserTime() + state.SystemTime()
+	if rss := processMaxRSS(state); rss > u.maxRSS {
+		u.maxRSS = rss
+	}
+}
+
+// apply sets the CPU time and peak RSS of usage to those of the task's
+// commands, and leaves them unset if it ran none.
+func (u *commandUsage) apply(usage *core.ResourceUsage) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if !u.ran {
+		return
+	}
+	usage.CPUTime = u.cpuTime
+	usage.MaxRSS = u.maxRSS
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// processMaxRSS is not supported on this platform; commands report CPU
+// time only.
+func processMaxRSS(state *os.ProcessState) int64 {
+	return 0
+}
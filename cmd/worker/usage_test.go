@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"os/exec"
+	"runtime"
+	"testing"
+	"time"
+
+	"flowctl/internal/core"
+)
+
+func TestCommandUsageReportsOnlyCommands(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not installed")
+	}
+
+	commands := &commandUsage{}
+	ctx := withCommandUsage(context.Background(), commands)
+
+	// Work done by the worker process itself is not the task's.
+	for deadline := time.Now().Add(20 * time.Millisecond); time.Now().Before(deadline); {
+	}
+	usage := &core.ResourceUsage{}
+	commands.apply(usage)
+	if usage.CPUTime != 0 || usage.MaxRSS != 0 {
+		t.Errorf("usage without a command = %+v, want none reported", usage)
+	}
+
+	w := newTestWorker()
+	spec := &commandSpec{command: "exit 0", name: "sh", args: []string{"-c", "exit 0"}, env: map[string]string{}}
+	if _, err := w.runCommand(ctx, &core.Task{ID: "task-1"}, spec); err != nil {
+		t.Fatalf("runCommand: %v", err)
+	}
+
+	commands.apply(usage)
+	if runtime.GOOS == "linux" && usage.MaxRSS <= 0 {
+		t.Errorf("usage of a command = %+v, want its peak RSS", usage)
+	}
+	if usage.MaxRSS > 1<<30 {
+		t.Errorf("usage of a command = %+v, want the command's RSS rather than the worker's", usage)
+	}
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// adminTokenSubject is what audit events of requests made with the admin
+// token are attributed to when no trusted proxy names the caller.
+const adminTokenSubject = "admin-token"
+
+// SetAdminToken sets the bearer token that the admin routes showing or
+// changing raw queue contents require. Without one, those routes are
+// refused.
+func (s *Server) SetAdminToken(token string) {
+	s.adminToken = token
+}
+
+// requireAdmin rejects a request unless it carries the admin token in its
+// Authorization header.
+func (s *Server) requireAdmin(c *gin.Context) {
+	if s.adminToken == "" {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "This route requires the scheduler to be started with -admin-token"})
+		return
+	}
+
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) != 1 {
+		c.Header("WWW-Authenticate", `Bearer realm="flowctl admin"`)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "A valid admin token is required"})
+		return
+	}
+
+	c.Next()
+}
+
+// adminSubject is who an admin request is audited as: the subject a
+// trusted proxy vouches for, or the admin token itself.
+func (s *Server) adminSubject(c *gin.Context) string {
+	if subject := s.subject(c); subject != "" {
+		return subject
+	}
+	return adminTokenSubject
+}
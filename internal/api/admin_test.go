@@ -0,0 +1,102 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func adminGet(s *Server, headers map[string]string) int {
+	return adminRequest(s, http.MethodGet, "/api/v1/admin/queues/etl/peek", headers)
+}
+
+func adminRequest(s *Server, method, path string, headers map[string]string) int {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	s.router.ServeHTTP(w, req)
+	return w.Code
+}
+
+// assertRequiresAdmin checks that a route is refused without a configured
+// admin token and answers 401 to requests that don't carry it.
+func assertRequiresAdmin(t *testing.T, method, path string) {
+	t.Helper()
+	s := newTestServer()
+	if code := adminRequest(s, method, path, map[string]string{SubjectHeader: "admin"}); code != http.StatusForbidden {
+		t.Errorf("%s %s without an admin token configured answered %d, want 403", method, path, code)
+	}
+
+	s.SetAdminToken("s3cret")
+	for _, headers := range []map[string]string{
+		nil,
+		{SubjectHeader: "admin"},
+		{"Authorization": "Bearer wrong"},
+	} {
+		if code := adminRequest(s, method, path, headers); code != http.StatusUnauthorized {
+			t.Errorf("%s %s with %v answered %d, want 401", method, path, headers, code)
+		}
+	}
+}
+
+func TestQueuePeekRequiresAdminToken(t *testing.T) {
+	s := newTestServer()
+	if code := adminGet(s, map[string]string{SubjectHeader: "admin"}); code != http.StatusForbidden {
+		t.Errorf("peek without an admin token configured answered %d, want 403", code)
+	}
+
+	s.SetAdminToken("s3cret")
+	for _, headers := range []map[string]string{
+		nil,
+		{SubjectHeader: "admin"},
+		{"Authorization": "Bearer wrong"},
+		{"Authorization": "s3cret"},
+	} {
+		if code := adminGet(s, headers); code != http.StatusUnauthorized {
+			t.Errorf("peek with %v answered %d, want 401", headers, code)
+		}
+	}
+}
+
+func TestAdminSubjectIgnoresUntrustedHeader(t *testing.T) {
+	s := newTestServer()
+	s.SetAdminToken("s3cret")
+
+	var got string
+	s.router.GET("/test/subject", s.requireAdmin, func(c *gin.Context) {
+		got = s.adminSubject(c)
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/test/subject", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	req.Header.Set(SubjectHeader, "someone-else")
+	s.router.ServeHTTP(w, req)
+
+	if got != adminTokenSubject {
+		t.Errorf("admin request audited as %q, want %q", got, adminTokenSubject)
+	}
+}
+
+func TestSandboxPolicyRequiresAdminToken(t *testing.T) {
+	for _, method := range []string{http.MethodGet, http.MethodPut, http.MethodDelete} {
+		assertRequiresAdmin(t, method, "/api/v1/admin/namespaces/team-a/sandbox-policy")
+	}
+}
+
+func TestQueueControlRequiresAdminToken(t *testing.T) {
+	assertRequiresAdmin(t, http.MethodGet, "/api/v1/admin/scheduler/dry-run")
+	assertRequiresAdmin(t, http.MethodPost, "/api/v1/admin/queues/etl/pause")
+	assertRequiresAdmin(t, http.MethodPost, "/api/v1/admin/queues/etl/resume")
+}
+
+func TestDecisionLogRequiresAdminToken(t *testing.T) {
+	for _, method := range []string{http.MethodGet, http.MethodPut, http.MethodDelete} {
+		assertRequiresAdmin(t, method, "/api/v1/admin/scheduler/decision-log")
+	}
+	assertRequiresAdmin(t, http.MethodGet, "/api/v1/admin/scheduler/decisions")
+}
@@ -0,0 +1,367 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"flowctl/internal/core"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+// SubjectHeader carries the identity of the caller, for example the service
+// name set by an authenticating proxy in front of the API. flowctl does not
+// verify it, so it is only believed on requests from trusted proxies; see
+// SetTrustedProxies.
+const SubjectHeader = "X-Flowctl-Subject"
+
+// AuthzRequest describes an API mutation for an Authorizer to allow or deny.
+type AuthzRequest struct {
+	Subject    string            `json:"subject"`
+	RemoteAddr string            `json:"remote_addr"`
+	Method     string            `json:"method"`
+	Path       string            `json:"path"`
+	Route      string            `json:"route"`
+	Params     map[string]string `json:"params"`
+	Namespace  string            `json:"namespace"`
+	Body       json.RawMessage   `json:"body,omitempty"`
+}
+
+type AuthzDecision struct {
+	Allow  bool   `json:"allow"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Authorizer is consulted before every request that changes state. A
+// returned error rejects the request as well, so a policy outage fails
+// closed.
+type Authorizer interface {
+	Authorize(ctx context.Context, req *AuthzRequest) (*AuthzDecision, error)
+}
+
+// HTTPAuthorizer posts each request as {"input": ...} to a policy endpoint,
+// which answers {"result": true|false} or {"result": {"allow": ..., "reason":
+// ...}}. This is the shape of OPA's data API, so url can point straight at a
+// policy decision such as http://opa:8181/v1/data/flowctl/authz.
+type HTTPAuthorizer struct {
+	url    string
+	client *http.Client
+}
+
+func NewHTTPAuthorizer(url string, timeout time.Duration) *HTTPAuthorizer {
+	return &HTTPAuthorizer{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (a *HTTPAuthorizer) Authorize(ctx context.Context, authzReq *AuthzRequest) (*AuthzDecision, error) {
+	body, err := json.Marshal(map[string]interface{}{"input": authzReq})
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize authorization request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build authorization request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query policy endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("policy endpoint returned status %d", resp.StatusCode)
+	}
+
+	var response struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode policy response: %w", err)
+	}
+
+	// OPA leaves result out when the policy is undefined for the input,
+	// which denies the request.
+	decision := &AuthzDecision{}
+	if len(response.Result) == 0 || json.Unmarshal(response.Result, &decision.Allow) == nil {
+		return decision, nil
+	}
+	if err := json.Unmarshal(response.Result, decision); err != nil {
+		return nil, fmt.Errorf("failed to decode policy result: %w", err)
+	}
+	return decision, nil
+}
+
+// SetAuthorizer installs the hook consulted on API mutations. Without one,
+// every request is allowed.
+func (s *Server) SetAuthorizer(authorizer Authorizer) {
+	s.authorizer = authorizer
+}
+
+// authorizedReads are the reads that still go through the authorizer,
+// because they show raw queue contents meant for admins only.
+var authorizedReads = map[string]bool{
+	"/api/v1/admin/queues/:type/peek":  true,
+	"/api/v1/admin/injected-tasks/:id": true,
+}
+
+// authorize runs the authorizer on every request that is not a read, and on
+// authorizedReads, rejecting it with 403 when denied.
+func (s *Server) authorize(c *gin.Context) {
+	if s.authorizer == nil {
+		c.Next()
+		return
+	}
+
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		if !authorizedReads[c.FullPath()] {
+			c.Next()
+			return
+		}
+	}
+
+	authzReq, err := s.newAuthzRequest(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// A request on an existing resource is authorized in the resource's
+	// namespace and, when it moves the resource, in the one it moves it
+	// to as well.
+	namespaces := []string{authzReq.Namespace}
+	if lookup, ok := namespaceLookups[authzReq.Route]; ok {
+		current, err := lookup(s, c)
+		if err != nil {
+			s.logger.Errorf("Failed to authorize %s %s: %v", authzReq.Method, authzReq.Path, err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to authorize request"})
+			return
+		}
+		if current != "" {
+			namespaces[0] = current
+			if authzReq.Namespace != "" && authzReq.Namespace != current {
+				namespaces = append(namespaces, authzReq.Namespace)
+			}
+		}
+	}
+
+	for _, namespace := range namespaces {
+		authzReq.Namespace = namespace
+		decision, err := s.authorizer.Authorize(c.Request.Context(), authzReq)
+		if err != nil {
+			s.logger.Errorf("Failed to authorize %s %s: %v", authzReq.Method, authzReq.Path, err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to authorize request"})
+			return
+		}
+
+		if !decision.Allow {
+			reason := decision.Reason
+			if reason == "" {
+				reason = "Request denied by policy"
+			}
+			s.logger.Warnf("Denied %s %s for subject %q: %s", authzReq.Method, authzReq.Path, authzReq.Subject, reason)
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": reason})
+			return
+		}
+	}
+
+	c.Next()
+}
+
+// namespaceLookups find the namespace of the resource a route acts on, for
+// routes that name it only by ID. They return "" for a resource that does
+// not exist, which the handler then reports.
+var namespaceLookups = map[string]func(s *Server, c *gin.Context) (string, error){
+	"/api/v1/workflows/:id":        lookupWorkflowNamespace,
+	"/api/v1/workflows/:id/cancel": lookupWorkflowNamespace,
+	"/api/v1/workflows/:id/rerun":  lookupWorkflowNamespace,
+	"/api/v1/tasks/:id/status":     lookupTaskNamespace,
+	"/api/v1/tasks/:id/retry":      lookupTaskNamespace,
+	"/api/v1/tasks/:id/cancel":     lookupTaskNamespace,
+	"/api/v1/tasks/:id/skip":       lookupTaskNamespace,
+	"/api/v1/schedules/:id":        lookupScheduleNamespace,
+	"/api/v1/templates/:name":      lookupTemplateNamespace,
+	"/api/v1/templates/:name/run":  lookupTemplateNamespace,
+	"/api/v1/webhooks":             lookupWebhookWorkflowNamespace,
+	"/api/v1/webhooks/:id":         lookupWebhookNamespace,
+}
+
+func lookupWorkflowNamespace(s *Server, c *gin.Context) (string, error) {
+	namespace, _, err := s.scheduler.WorkflowNamespace(c.Param("id"))
+	return namespace, err
+}
+
+func lookupTaskNamespace(s *Server, c *gin.Context) (string, error) {
+	namespace, _, err := s.scheduler.TaskNamespace(c.Param("id"))
+	return namespace, err
+}
+
+// lookupScheduleNamespace returns the namespace of the workflow a schedule
+// runs. A schedule that cannot be read is left to the handler.
+func lookupScheduleNamespace(s *Server, c *gin.Context) (string, error) {
+	schedule, err := s.scheduler.GetSchedule(c.Param("id"))
+	if err != nil {
+		return "", nil
+	}
+	return definitionNamespace(schedule.Workflow), nil
+}
+
+// lookupTemplateNamespace returns the namespace of the workflow a template
+// builds, from its latest version. A template that cannot be read is left
+// to the handler.
+func lookupTemplateNamespace(s *Server, c *gin.Context) (string, error) {
+	tmpl, err := s.scheduler.GetWorkflowTemplate(c.Param("name"), 0)
+	if err != nil {
+		return "", nil
+	}
+	return definitionNamespace(tmpl.Workflow), nil
+}
+
+// lookupWebhookWorkflowNamespace returns the namespace of the workflow a
+// new webhook is registered on, if any.
+func lookupWebhookWorkflowNamespace(s *Server, c *gin.Context) (string, error) {
+	var fields struct {
+		WorkflowID string `json:"workflow_id"`
+	}
+	if c.Request.Body == nil {
+		return "", nil
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return "", err
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	if json.Unmarshal(body, &fields) != nil || fields.WorkflowID == "" {
+		return "", nil
+	}
+	namespace, _, err := s.scheduler.WorkflowNamespace(fields.WorkflowID)
+	return namespace, err
+}
+
+// lookupWebhookNamespace returns the namespace of a webhook, which is ""
+// for a cluster-wide one. A webhook that cannot be read is left to the
+// handler.
+func lookupWebhookNamespace(s *Server, c *gin.Context) (string, error) {
+	webhook, err := s.scheduler.GetWebhook(c.Request.Context(), c.Param("id"))
+	if err != nil || webhook == nil {
+		return "", nil
+	}
+	return webhook.Namespace, nil
+}
+
+func definitionNamespace(definition core.WorkflowDefinition) string {
+	if definition.Namespace == "" {
+		return core.DefaultNamespace
+	}
+	return definition.Namespace
+}
+
+// authorizeItem runs the authorizer on one item of a batch request, as a
+// method request to route in namespace with body. It returns the status to
+// reject the item with and why, or a nil error when it is allowed.
+func (s *Server) authorizeItem(c *gin.Context, method, route, namespace string, body json.RawMessage) (int, error) {
+	if s.authorizer == nil {
+		return 0, nil
+	}
+
+	authzReq := &AuthzRequest{
+		Subject:    s.subject(c),
+		RemoteAddr: c.ClientIP(),
+		Method:     method,
+		Path:       route,
+		Route:      route,
+		Params:     make(map[string]string),
+		Namespace:  namespace,
+	}
+	if json.Valid(body) {
+		authzReq.Body = body
+	}
+
+	decision, err := s.authorizer.Authorize(c.Request.Context(), authzReq)
+	if err != nil {
+		s.logger.Errorf("Failed to authorize %s %s: %v", method, route, err)
+		return http.StatusInternalServerError, errors.New("Failed to authorize request")
+	}
+	if !decision.Allow {
+		reason := decision.Reason
+		if reason == "" {
+			reason = "Request denied by policy"
+		}
+		s.logger.Warnf("Denied %s %s in namespace %q for subject %q: %s", method, route, namespace, authzReq.Subject, reason)
+		return http.StatusForbidden, errors.New(reason)
+	}
+	return 0, nil
+}
+
+// newAuthzRequest describes the request to the authorizer. The body is read
+// and put back for the handler; the namespace is taken from the route or,
+// for workflow and schedule bodies, from the workflow being submitted.
+func (s *Server) newAuthzRequest(c *gin.Context) (*AuthzRequest, error) {
+	authzReq := &AuthzRequest{
+		Subject:    s.subject(c),
+		RemoteAddr: c.ClientIP(),
+		Method:     c.Request.Method,
+		Path:       c.Request.URL.Path,
+		Route:      c.FullPath(),
+		Params:     make(map[string]string),
+		Namespace:  c.Param("namespace"),
+	}
+	for _, param := range c.Params {
+		authzReq.Params[param.Key] = param.Value
+	}
+
+	if c.Request.Body == nil {
+		return authzReq, nil
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	if len(body) == 0 {
+		return authzReq, nil
+	}
+	if json.Valid(body) {
+		authzReq.Body = body
+	}
+
+	if authzReq.Namespace == "" {
+		var fields struct {
+			Namespace string `json:"namespace"`
+			Workflow  struct {
+				Namespace string `json:"namespace"`
+			} `json:"workflow"`
+		}
+		if json.Unmarshal(body, &fields) == nil {
+			authzReq.Namespace = fields.Namespace
+			if authzReq.Namespace == "" {
+				authzReq.Namespace = fields.Workflow.Namespace
+			}
+		}
+	}
+
+	if authzReq.Namespace == "" && isYAMLRequest(c) {
+		var fields struct {
+			Namespace string `yaml:"namespace"`
+		}
+		if yaml.Unmarshal(body, &fields) == nil {
+			authzReq.Namespace = fields.Namespace
+		}
+	}
+
+	return authzReq, nil
+}
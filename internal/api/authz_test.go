@@ -0,0 +1,62 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"flowctl/internal/core"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// namespaceStore knows the namespaces of a few workflows and tasks. Any
+// other call panics on the nil embedded Store.
+type namespaceStore struct {
+	core.Store
+	workflows map[string]string
+	tasks     map[string]string
+}
+
+func (st *namespaceStore) WorkflowNamespace(id string) (string, bool, error) {
+	namespace, ok := st.workflows[id]
+	return namespace, ok, nil
+}
+
+func (st *namespaceStore) TaskNamespace(id string) (string, bool, error) {
+	namespace, ok := st.tasks[id]
+	return namespace, ok, nil
+}
+
+func TestAuthorizeLooksUpNamespaceOfExistingResources(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	store := &namespaceStore{
+		workflows: map[string]string{"wf-1": "team-b"},
+		tasks:     map[string]string{"task-1": "team-b"},
+	}
+	s := NewServer(core.NewScheduler(store, nil, logger), logger)
+	authorizer := &namespaceAuthorizer{allow: "team-a"}
+	s.SetAuthorizer(authorizer)
+
+	for _, req := range []struct{ method, path string }{
+		{http.MethodPut, "/api/v1/workflows/wf-1/cancel"},
+		{http.MethodDelete, "/api/v1/workflows/wf-1"},
+		{http.MethodPost, "/api/v1/workflows/wf-1/rerun"},
+		{http.MethodPost, "/api/v1/tasks/task-1/retry"},
+	} {
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, httptest.NewRequest(req.method, req.path, nil))
+		if w.Code != http.StatusForbidden {
+			t.Errorf("%s %s answered %d, want 403", req.method, req.path, w.Code)
+		}
+
+		last := authorizer.requests[len(authorizer.requests)-1]
+		if last.Namespace != "team-b" {
+			t.Errorf("%s %s authorized in namespace %q, want team-b", req.method, req.path, last.Namespace)
+		}
+	}
+}
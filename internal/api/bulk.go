@@ -0,0 +1,137 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"flowctl/internal/core"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+const maxBulkWorkflows = 1000
+
+// BulkWorkflowRequest holds the workflows of a bulk submission. Each item
+// has the shape of a Create Workflow body and is decoded on its own, so one
+// malformed item does not reject the rest.
+type BulkWorkflowRequest struct {
+	Workflows []json.RawMessage `json:"workflows" binding:"required"`
+}
+
+// BulkWorkflowResult reports the outcome of one item, in request order.
+// Status is the HTTP status the item would have got on its own.
+type BulkWorkflowResult struct {
+	Index      int    `json:"index"`
+	Status     int    `json:"status"`
+	WorkflowID string `json:"workflow_id,omitempty"`
+	Error      string `json:"error,omitempty"`
+
+	// retryAfter is how long an item answered 429 should wait before it
+	// is submitted again.
+	retryAfter time.Duration
+}
+
+type BulkWorkflowResponse struct {
+	Submitted int                  `json:"submitted"`
+	Failed    int                  `json:"failed"`
+	Results   []BulkWorkflowResult `json:"results"`
+}
+
+// createWorkflowsBulk submits each workflow in turn. Every workflow is
+// stored in its own transaction: a failed item leaves nothing behind and
+// does not affect the others. When items are answered 429, Retry-After
+// holds the longest of their waits.
+func (s *Server) createWorkflowsBulk(c *gin.Context) {
+	var req BulkWorkflowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(req.Workflows) == 0 || len(req.Workflows) > maxBulkWorkflows {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("workflows must hold 1 to %d items", maxBulkWorkflows)})
+		return
+	}
+
+	response := BulkWorkflowResponse{Results: make([]BulkWorkflowResult, len(req.Workflows))}
+	var retryAfter time.Duration
+	for i, item := range req.Workflows {
+		var result BulkWorkflowResult
+		// The request itself paid for its first item; each further one
+		// costs a token of its own, as if it had been submitted alone.
+		if allowed, wait := s.chargeRateLimit(c, i > 0); allowed {
+			result = s.submitBulkItem(c, item)
+		} else {
+			result = BulkWorkflowResult{
+				Status:     http.StatusTooManyRequests,
+				Error:      fmt.Sprintf("Rate limit exceeded, retry after %s", wait.Round(time.Second)),
+				retryAfter: wait,
+			}
+		}
+		if result.retryAfter > retryAfter {
+			retryAfter = result.retryAfter
+		}
+		result.Index = i
+		if result.Status == http.StatusCreated {
+			response.Submitted++
+		} else {
+			response.Failed++
+		}
+		response.Results[i] = result
+	}
+
+	if retryAfter > 0 {
+		setRetryAfter(c, retryAfter)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func (s *Server) submitBulkItem(c *gin.Context, item json.RawMessage) BulkWorkflowResult {
+	var req CreateWorkflowRequest
+	if err := json.Unmarshal(item, &req); err != nil {
+		return BulkWorkflowResult{Status: http.StatusBadRequest, Error: err.Error()}
+	}
+	if err := binding.Validator.ValidateStruct(&req); err != nil {
+		return BulkWorkflowResult{Status: http.StatusBadRequest, Error: err.Error()}
+	}
+
+	definition := req.toDefinition()
+	if err := definition.Validate(); err != nil {
+		return BulkWorkflowResult{Status: http.StatusBadRequest, Error: err.Error()}
+	}
+
+	// The request as a whole has no namespace, so each item is authorized
+	// as the Create Workflow request it stands for.
+	if status, err := s.authorizeItem(c, http.MethodPost, "/api/v1/workflows", definition.Namespace, item); err != nil {
+		return BulkWorkflowResult{Status: status, Error: err.Error()}
+	}
+
+	workflow, err := definition.NewWorkflow()
+	if err != nil {
+		return BulkWorkflowResult{Status: http.StatusBadRequest, Error: err.Error()}
+	}
+
+	if err := s.scheduler.SubmitWorkflow(c.Request.Context(), workflow); err != nil {
+		result := BulkWorkflowResult{WorkflowID: workflow.ID, Error: err.Error()}
+		switch {
+		case errors.Is(err, core.ErrDuplicateID):
+			result.Status = http.StatusConflict
+		case errors.Is(err, core.ErrSandboxViolation) || errors.Is(err, core.ErrAdmissionDenied):
+			result.Status = http.StatusForbidden
+		case errors.Is(err, core.ErrQueueFull):
+			result.Status = http.StatusTooManyRequests
+			result.retryAfter, _ = backpressureRetryAfter(err)
+		default:
+			s.logger.Errorf("Failed to submit workflow %s in bulk: %v", workflow.ID, err)
+			result.Status = http.StatusInternalServerError
+			result.Error = "Failed to create workflow"
+		}
+		return result
+	}
+
+	return BulkWorkflowResult{Status: http.StatusCreated, WorkflowID: workflow.ID}
+}
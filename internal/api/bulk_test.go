@@ -0,0 +1,112 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"flowctl/internal/core"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// namespaceAuthorizer allows requests in one namespace and records every
+// request it is asked about.
+type namespaceAuthorizer struct {
+	allow string
+
+	mu       sync.Mutex
+	requests []AuthzRequest
+}
+
+func (a *namespaceAuthorizer) Authorize(ctx context.Context, req *AuthzRequest) (*AuthzDecision, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.requests = append(a.requests, *req)
+	if req.Route == "/api/v1/workflows/bulk" || req.Namespace == a.allow {
+		return &AuthzDecision{Allow: true}, nil
+	}
+	return &AuthzDecision{Allow: false, Reason: "namespace " + req.Namespace + " is not allowed"}, nil
+}
+
+func newTestServer() *Server {
+	gin.SetMode(gin.TestMode)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return NewServer(core.NewScheduler(nil, nil, logger), logger)
+}
+
+func postBulk(t *testing.T, s *Server, namespaces ...string) (BulkWorkflowResponse, http.Header) {
+	t.Helper()
+	var items []string
+	for _, namespace := range namespaces {
+		items = append(items, `{"name": "wf", "namespace": "`+namespace+`", "tasks": [{"name": "a", "type": "etl"}]}`)
+	}
+	body := `{"workflows": [` + strings.Join(items, ",") + `]}`
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/workflows/bulk", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	s.router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("bulk submission answered %d: %s", w.Code, w.Body)
+	}
+
+	var response BulkWorkflowResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	return response, w.Header()
+}
+
+func TestBulkItemsAreAuthorizedInTheirNamespace(t *testing.T) {
+	s := newTestServer()
+	authorizer := &namespaceAuthorizer{allow: "team-a"}
+	s.SetAuthorizer(authorizer)
+
+	response, header := postBulk(t, s, "team-b", "team-c")
+
+	for _, result := range response.Results {
+		if result.Status != http.StatusForbidden {
+			t.Errorf("item %d answered %d, want 403", result.Index, result.Status)
+		}
+	}
+	if retryAfter := header.Get("Retry-After"); retryAfter != "" {
+		t.Errorf("Retry-After = %q, want none without a 429", retryAfter)
+	}
+	var namespaces []string
+	for _, req := range authorizer.requests[1:] {
+		namespaces = append(namespaces, req.Namespace)
+		if req.Route != "/api/v1/workflows" {
+			t.Errorf("item authorized as route %q, want /api/v1/workflows", req.Route)
+		}
+	}
+	if strings.Join(namespaces, ",") != "team-b,team-c" {
+		t.Errorf("items authorized in namespaces %v, want team-b and team-c", namespaces)
+	}
+}
+
+func TestBulkItemsAreRateLimitedOneTokenEach(t *testing.T) {
+	s := newTestServer()
+	s.SetAuthorizer(&namespaceAuthorizer{allow: "team-a"})
+	s.SetRateLimiter(NewRateLimiter(0.001, 2))
+
+	response, header := postBulk(t, s, "team-b", "team-b", "team-b")
+
+	want := []int{http.StatusForbidden, http.StatusForbidden, http.StatusTooManyRequests}
+	for i, result := range response.Results {
+		if result.Status != want[i] {
+			t.Errorf("item %d answered %d, want %d", i, result.Status, want[i])
+		}
+	}
+	// One token comes back every 1000s.
+	if retryAfter := header.Get("Retry-After"); retryAfter != "1000" {
+		t.Errorf("Retry-After = %q, want the rate limited item's wait of 1000", retryAfter)
+	}
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"flowctl/internal/core"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DeadLetterPolicyRequest struct {
+	MaxSize  int64                   `json:"max_size"`
+	Overflow core.DeadLetterOverflow `json:"overflow"`
+	MaxAge   string                  `json:"max_age"`
+}
+
+func (s *Server) getDeadLetterStats(c *gin.Context) {
+	taskType := c.Param("type")
+
+	stats, err := s.scheduler.GetDeadLetterStats(c.Request.Context(), taskType)
+	if err != nil {
+		s.logger.Errorf("Failed to get dead letter stats for %s: %v", taskType, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get dead letter stats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+func (s *Server) getDeadLetterEntry(c *gin.Context) {
+	taskType := c.Param("type")
+	taskID := c.Param("task_id")
+
+	task, err := s.scheduler.GetDeadLetterEntry(c.Request.Context(), taskType, taskID)
+	if err != nil {
+		s.logger.Errorf("Failed to get dead letter entry %s for %s: %v", taskID, taskType, err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dead letter entry not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, s.redactTask(c, *task))
+}
+
+func (s *Server) setDeadLetterPolicy(c *gin.Context) {
+	taskType := c.Param("type")
+
+	var req DeadLetterPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	policy := core.DeadLetterPolicy{
+		TaskType: taskType,
+		MaxSize:  req.MaxSize,
+		Overflow: req.Overflow,
+	}
+	if req.MaxAge != "" {
+		maxAge, err := time.ParseDuration(req.MaxAge)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "max_age must be a duration such as 168h"})
+			return
+		}
+		policy.MaxAge = maxAge
+	}
+	if err := policy.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.scheduler.SetDeadLetterPolicy(c.Request.Context(), policy); err != nil {
+		s.logger.Errorf("Failed to set dead letter policy for %s: %v", taskType, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set dead letter policy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, policy)
+}
+
+func (s *Server) deleteDeadLetterPolicy(c *gin.Context) {
+	taskType := c.Param("type")
+
+	if err := s.scheduler.DeleteDeadLetterPolicy(c.Request.Context(), taskType); err != nil {
+		s.logger.Errorf("Failed to delete dead letter policy for %s: %v", taskType, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete dead letter policy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Dead letter policy deleted"})
+}
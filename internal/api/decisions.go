@@ -0,0 +1,110 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"flowctl/internal/core"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DecisionLogRequest struct {
+	SampleRate float64 `json:"sample_rate" binding:"required"`
+	Retention  string  `json:"retention"`
+}
+
+// SchedulingPassList is the recorded scheduling passes, newest first.
+type SchedulingPassList struct {
+	Passes []core.SchedulingPass `json:"passes"`
+}
+
+func (s *Server) getDecisionLogConfig(c *gin.Context) {
+	config, err := s.scheduler.GetDecisionLogConfig(c.Request.Context())
+	if err != nil {
+		s.logger.Errorf("Failed to get decision log config: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get decision log config"})
+		return
+	}
+	if config == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Decision log is off"})
+		return
+	}
+
+	c.JSON(http.StatusOK, config)
+}
+
+func (s *Server) setDecisionLogConfig(c *gin.Context) {
+	var req DecisionLogRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	config := core.DecisionLogConfig{
+		SampleRate: req.SampleRate,
+		Retention:  core.DefaultDecisionRetention,
+	}
+	if req.Retention != "" {
+		retention, err := time.ParseDuration(req.Retention)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "retention must be a duration such as 30m"})
+			return
+		}
+		config.Retention = retention
+	}
+
+	if err := config.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.scheduler.SetDecisionLogConfig(c.Request.Context(), config); err != nil {
+		s.logger.Errorf("Failed to set decision log config: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set decision log config"})
+		return
+	}
+
+	c.JSON(http.StatusOK, config)
+}
+
+func (s *Server) deleteDecisionLogConfig(c *gin.Context) {
+	if err := s.scheduler.DeleteDecisionLogConfig(c.Request.Context()); err != nil {
+		s.logger.Errorf("Failed to delete decision log config: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete decision log config"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Decision log turned off"})
+}
+
+func (s *Server) listSchedulingPasses(c *gin.Context) {
+	limit := core.DefaultSchedulingPassCount
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 || parsed > core.MaxSchedulingPassCount {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(core.MaxSchedulingPassCount)})
+			return
+		}
+		limit = parsed
+	}
+
+	since, ok := queryTime(c, "since")
+	if !ok {
+		return
+	}
+	var after time.Time
+	if since != nil {
+		after = *since
+	}
+
+	passes, err := s.scheduler.GetSchedulingPasses(c.Request.Context(), after, int64(limit))
+	if err != nil {
+		s.logger.Errorf("Failed to get scheduling passes: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get scheduling passes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, SchedulingPassList{Passes: passes})
+}
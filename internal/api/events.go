@@ -0,0 +1,165 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"flowctl/internal/core"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) listEvents(c *gin.Context) {
+	after := c.DefaultQuery("after", "0")
+	workflowID := c.Query("workflow_id")
+
+	count, err := strconv.ParseInt(c.DefaultQuery("count", "100"), 10, 64)
+	if err != nil || count <= 0 || count > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "count must be between 1 and 1000"})
+		return
+	}
+
+	var block time.Duration
+	if blockParam := c.Query("block"); blockParam != "" {
+		block, err = time.ParseDuration(blockParam)
+		if err != nil || block < 0 || block > time.Minute {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "block must be a duration of at most 1m"})
+			return
+		}
+	}
+
+	var events []core.Event
+	if workflowID != "" {
+		events, err = s.scheduler.ReadWorkflowEventsAfter(c.Request.Context(), workflowID, after, count, block)
+	} else {
+		events, err = s.scheduler.ReadEvents(c.Request.Context(), after, count, block)
+	}
+	if err != nil {
+		s.logger.Errorf("Failed to read events: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read events"})
+		return
+	}
+
+	next := after
+	if len(events) > 0 {
+		next = events[len(events)-1].ID
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events": events,
+		"next":   next,
+	})
+}
+
+// streamKeepAlive is how long a workflow event stream may stay silent before
+// a comment line is sent to keep proxies from closing it.
+const streamKeepAlive = time.Second * 15
+
+// streamWorkflowEvents pushes a workflow's events to the client as
+// server-sent events. A new stream starts with a "snapshot" event holding
+// the workflow and its tasks; a client reconnecting with Last-Event-ID
+// resumes after that event instead. The stream ends once the workflow
+// finishes.
+func (s *Server) streamWorkflowEvents(c *gin.Context) {
+	workflowID := c.Param("id")
+	ctx := c.Request.Context()
+
+	after := c.GetHeader("Last-Event-ID")
+	if after == "" {
+		after = c.Query("after")
+	}
+
+	resuming := after != ""
+	if !resuming {
+		// Read the stream position before the workflow, so no transition
+		// between the two is missed.
+		lastID, err := s.scheduler.LastEventID(ctx)
+		if err != nil {
+			s.logger.Errorf("Failed to read events: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read events"})
+			return
+		}
+		after = lastID
+	}
+
+	workflow, err := s.scheduler.GetWorkflow(workflowID)
+	if err != nil {
+		s.logger.Errorf("Failed to get workflow %s: %v", workflowID, err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Workflow not found"})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	if !resuming {
+		if err := writeServerSentEvent(c, after, "snapshot", s.redactWorkflow(c, workflow)); err != nil {
+			return
+		}
+	}
+
+	// A finished workflow publishes no more events. A resuming client still
+	// gets those it missed.
+	finished := workflow.Status.IsTerminal()
+	if finished && !resuming {
+		return
+	}
+
+	block := streamKeepAlive
+	if finished {
+		block = 0
+	}
+
+	for {
+		events, err := s.scheduler.ReadWorkflowEventsAfter(ctx, workflowID, after, 100, block)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			s.logger.Errorf("Failed to read events for workflow %s: %v", workflowID, err)
+			return
+		}
+
+		if len(events) == 0 {
+			if finished {
+				return
+			}
+			if _, err := fmt.Fprint(c.Writer, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+			continue
+		}
+
+		for _, event := range events {
+			after = event.ID
+			if err := writeServerSentEvent(c, event.ID, string(event.Type), event); err != nil {
+				return
+			}
+
+			switch event.Type {
+			case core.EventWorkflowCompleted, core.EventWorkflowFailed, core.EventWorkflowCancelled:
+				return
+			}
+		}
+	}
+}
+
+func writeServerSentEvent(c *gin.Context, id, name string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to serialize %s event: %w", name, err)
+	}
+
+	if _, err := fmt.Fprintf(c.Writer, "id: %s\nevent: %s\ndata: %s\n\n", id, name, payload); err != nil {
+		return err
+	}
+	c.Writer.Flush()
+	return nil
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"flowctl/internal/core"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// workflowEventBroker holds the events of one workflow's stream. Reading
+// the whole event stream panics on the nil embedded Broker.
+type workflowEventBroker struct {
+	core.Broker
+	workflowID string
+	events     []core.Event
+}
+
+func (b *workflowEventBroker) ReadWorkflowEventsAfter(ctx context.Context, workflowID, after string, count int64, block time.Duration) ([]core.Event, error) {
+	if workflowID != b.workflowID {
+		return []core.Event{}, nil
+	}
+	events := []core.Event{}
+	for _, event := range b.events {
+		if event.ID > after && int64(len(events)) < count {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (b *workflowEventBroker) LastEventID(ctx context.Context) (string, error) {
+	return "1-0", nil
+}
+
+func (b *workflowEventBroker) Redactor(ctx context.Context) core.Redactor {
+	redactor, _ := core.NewPatternRedactor(nil)
+	return redactor
+}
+
+type workflowStore struct {
+	core.Store
+	workflow *core.Workflow
+}
+
+func (st *workflowStore) GetWorkflow(id string) (*core.Workflow, error) {
+	copied := *st.workflow
+	return &copied, nil
+}
+
+func newEventTestServer() *Server {
+	gin.SetMode(gin.TestMode)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	workflow := &core.Workflow{ID: "wf-1", Name: "nightly", Status: core.WorkflowStatusRunning}
+	broker := &workflowEventBroker{
+		workflowID: "wf-1",
+		events: []core.Event{
+			{ID: "2-0", Type: core.EventTaskCompleted, WorkflowID: "wf-1", TaskID: "extract", Status: "completed"},
+			{ID: "3-0", Type: core.EventWorkflowCompleted, WorkflowID: "wf-1", Status: "completed"},
+		},
+	}
+	return NewServer(core.NewScheduler(&workflowStore{workflow: workflow}, broker, logger), logger)
+}
+
+func TestStreamWorkflowEventsReadsTheWorkflowsStream(t *testing.T) {
+	s := newEventTestServer()
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/workflows/wf-1/events", nil)
+	s.router.ServeHTTP(w, req)
+
+	body := w.Body.String()
+	for _, want := range []string{"id: 1-0\nevent: snapshot\n", "id: 2-0\nevent: task.completed\n", "id: 3-0\nevent: workflow.completed\n"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("stream is missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestListEventsOfOneWorkflowReadsTheWorkflowsStream(t *testing.T) {
+	s := newEventTestServer()
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/events?workflow_id=wf-1&after=2-0", nil)
+	s.router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("listing events answered %d: %s", w.Code, w.Body)
+	}
+
+	var response struct {
+		Events []core.Event `json:"events"`
+		Next   string       `json:"next"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Events) != 1 || response.Events[0].ID != "3-0" || response.Next != "3-0" {
+		t.Errorf("events = %+v, next = %s, want the workflow's event after 2-0", response.Events, response.Next)
+	}
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"net/http"
+
+	"flowctl/internal/core"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DispatchFairnessPolicyRequest struct {
+	Mode       core.DispatchFairnessMode `json:"mode" binding:"required"`
+	RetryShare int                       `json:"retry_share"`
+}
+
+func (s *Server) getDispatchFairnessPolicy(c *gin.Context) {
+	taskType := c.Param("type")
+
+	policy, err := s.scheduler.GetDispatchFairnessPolicy(c.Request.Context(), taskType)
+	if err != nil {
+		s.logger.Errorf("Failed to get dispatch fairness policy for %s: %v", taskType, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get dispatch fairness policy"})
+		return
+	}
+	if policy == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dispatch fairness policy not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, policy)
+}
+
+func (s *Server) setDispatchFairnessPolicy(c *gin.Context) {
+	taskType := c.Param("type")
+
+	var req DispatchFairnessPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	policy := core.DispatchFairnessPolicy{
+		TaskType:   taskType,
+		Mode:       req.Mode,
+		RetryShare: req.RetryShare,
+	}
+	if err := policy.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.scheduler.SetDispatchFairnessPolicy(c.Request.Context(), policy); err != nil {
+		s.logger.Errorf("Failed to set dispatch fairness policy for %s: %v", taskType, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set dispatch fairness policy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, policy)
+}
+
+func (s *Server) deleteDispatchFairnessPolicy(c *gin.Context) {
+	taskType := c.Param("type")
+
+	if err := s.scheduler.DeleteDispatchFairnessPolicy(c.Request.Context(), taskType); err != nil {
+		s.logger.Errorf("Failed to delete dispatch fairness policy for %s: %v", taskType, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete dispatch fairness policy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Dispatch fairness policy deleted"})
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"flowctl/internal/core"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getWorkflowAsOf answers GET /workflows/:id?as_of= with the workflow as it
+// stood at that time, rebuilt from the event log.
+func (s *Server) getWorkflowAsOf(c *gin.Context, workflow *core.Workflow, value string) {
+	asOf, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "as_of must be an RFC 3339 time"})
+		return
+	}
+
+	snapshot, err := s.scheduler.WorkflowAsOf(c.Request.Context(), workflow, asOf)
+	if err != nil {
+		if errors.Is(err, core.ErrWorkflowNotCreated) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Workflow did not exist at as_of"})
+			return
+		}
+		s.logger.Errorf("Failed to rebuild workflow %s as of %s: %v", workflow.ID, value, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rebuild workflow history"})
+		return
+	}
+
+	snapshot.Tasks = s.redactTasks(c, snapshot.Tasks)
+	c.JSON(http.StatusOK, snapshot)
+}
@@ -0,0 +1,512 @@
+package api
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"flowctl/internal/core"
+
+	"github.com/gin-gonic/gin"
+)
+
+//go:embed swagger.html
+var swaggerUI []byte
+
+// apiParam is a query parameter of an operation. Path parameters are taken
+// from the route.
+type apiParam struct {
+	Name        string
+	Type        string
+	Description string
+}
+
+// apiOperation documents one route. Request and Response are zero values of
+// the types the handler binds and returns; their schemas are derived from
+// the types' JSON tags. YAML marks routes that also accept a YAML workflow
+// file as the request body.
+type apiOperation struct {
+	Method   string
+	Path     string
+	Tag      string
+	Summary  string
+	Query    []apiParam
+	Request  interface{}
+	Response interface{}
+	Status   int
+	Stream   bool
+	YAML     bool
+}
+
+// ErrorResponse is the body of every 4xx and 5xx response.
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+type messageResponse struct {
+	Message string `json:"message"`
+}
+
+type schedulePreviewResponse struct {
+	Cron      string      `json:"cron"`
+	Timezone  string      `json:"timezone"`
+	Enabled   *bool       `json:"enabled,omitempty"`
+	FireTimes []time.Time `json:"fire_times"`
+}
+
+// apiOperations lists every route under /api/v1. Keep it in step with
+// setupRoutes; routes missing here are logged when the server starts.
+var apiOperations = []apiOperation{
+	{Method: "POST", Path: "/workflows", Tag: "Workflows", Summary: "Submit a workflow", Request: CreateWorkflowRequest{}, Response: core.Workflow{}, Status: http.StatusCreated, YAML: true,
+		Query: []apiParam{{"async", "boolean", "Accept the workflow and store its tasks in the background, answering 202 with a submission"}}},
+	{Method: "POST", Path: "/workflows/bulk", Tag: "Workflows", Summary: "Submit many workflows", Request: struct {
+		Workflows []CreateWorkflowRequest `json:"workflows"`
+	}{}, Response: BulkWorkflowResponse{}},
+	{Method: "GET", Path: "/workflows", Tag: "Workflows", Summary: "List workflows", Response: struct {
+		Workflows []core.Workflow `json:"workflows"`
+		Total     int             `json:"total"`
+		Page      int             `json:"page"`
+		Limit     int             `json:"limit"`
+	}{},
+		Query: []apiParam{
+			{"status", "string", "Only workflows in this status"},
+			{"name", "string", "Only workflows whose name contains this, ignoring case"},
+			{"created_after", "string", "RFC 3339 time"},
+			{"created_before", "string", "RFC 3339 time"},
+			{"page", "integer", "Page number, from 1"},
+			{"limit", "integer", "Workflows per page, 1 to 100"},
+		}},
+	{Method: "GET", Path: "/workflows/:id", Tag: "Workflows", Summary: "Get a workflow", Response: core.Workflow{},
+		Query: []apiParam{{"as_of", "string", "RFC 3339 time; rebuild task statuses at that moment from the event log"}}},
+	{Method: "PUT", Path: "/workflows/:id/cancel", Tag: "Workflows", Summary: "Cancel a workflow", Response: messageResponse{}},
+	{Method: "POST", Path: "/workflows/:id/rerun", Tag: "Workflows", Summary: "Start a fresh run of a workflow", Request: RerunWorkflowRequest{}, Response: core.Workflow{}, Status: http.StatusCreated},
+	{Method: "DELETE", Path: "/workflows/:id", Tag: "Workflows", Summary: "Delete a workflow and purge its tasks", Response: core.WorkflowDeletion{}},
+	{Method: "GET", Path: "/workflows/:id/tasks", Tag: "Workflows", Summary: "List a workflow's tasks", Response: struct {
+		Tasks []core.Task `json:"tasks"`
+	}{}},
+	{Method: "GET", Path: "/workflows/:id/usage", Tag: "Workflows", Summary: "Get a workflow's resource usage", Response: core.WorkflowUsage{}},
+	{Method: "GET", Path: "/workflows/:id/events", Tag: "Workflows", Summary: "Stream a workflow's events as server-sent events", Stream: true,
+		Query: []apiParam{{"after", "string", "Resume after this event ID"}}},
+	{Method: "GET", Path: "/submissions/:id", Tag: "Workflows", Summary: "Get an asynchronous submission", Response: core.Submission{}},
+
+	{Method: "GET", Path: "/tasks/:id", Tag: "Tasks", Summary: "Get a task", Response: core.Task{}},
+	{Method: "GET", Path: "/tasks/:id/output", Tag: "Tasks", Summary: "Stream the stdout and stderr of a task attempt as server-sent events", Stream: true,
+		Query: []apiParam{{"attempt", "integer", "Attempt to stream, the latest if omitted"}, {"after", "string", "Resume after this chunk ID"}}},
+	{Method: "GET", Path: "/tasks/:id/why", Tag: "Tasks", Summary: "Explain why a task has not run", Response: core.TaskExplanation{}},
+	{Method: "POST", Path: "/tasks/:id/status", Tag: "Tasks", Summary: "Report a task's status", Request: TaskStatusRequest{}, Response: messageResponse{}},
+	{Method: "POST", Path: "/tasks/:id/retry", Tag: "Tasks", Summary: "Retry a failed task", Request: RetryTaskRequest{}, Response: core.Task{}},
+	{Method: "GET", Path: "/tasks/:id/impact", Tag: "Tasks", Summary: "Preview the downstream tasks cancelling or skipping a task would skip", Response: core.DownstreamImpact{}},
+	{Method: "POST", Path: "/tasks/:id/cancel", Tag: "Tasks", Summary: "Cancel an unfinished task", Request: StopTaskRequest{}, Response: StopTaskResponse{}},
+	{Method: "POST", Path: "/tasks/:id/skip", Tag: "Tasks", Summary: "Skip a task that has not started", Request: StopTaskRequest{}, Response: StopTaskResponse{}},
+
+	{Method: "GET", Path: "/health", Tag: "System", Summary: "Health check", Response: struct {
+		Status    string `json:"status"`
+		Timestamp string `json:"timestamp"`
+	}{}},
+	{Method: "GET", Path: "/metrics", Tag: "System", Summary: "Get workflow, task, queue and worker counts", Response: core.Metrics{}},
+	{Method: "GET", Path: "/overview", Tag: "System", Summary: "Get a system overview", Response: core.Overview{}},
+	{Method: "GET", Path: "/leader", Tag: "System", Summary: "Get the scheduler leader", Response: core.LeaderStatus{}},
+	{Method: "GET", Path: "/workers", Tag: "System", Summary: "List workers", Response: struct {
+		Workers []core.WorkerInfo `json:"workers"`
+	}{},
+		Query: []apiParam{{"type", "string", "Only workers that handle this task type"}}},
+	{Method: "GET", Path: "/workers/summary", Tag: "System", Summary: "Summarize workers by task type", Response: core.WorkerSummary{},
+		Query: []apiParam{{"pool", "string", "Only workers of this pool"}}},
+	{Method: "GET", Path: "/workers/:id", Tag: "System", Summary: "Get a worker", Response: core.WorkerInfo{}},
+
+	{Method: "POST", Path: "/schedules", Tag: "Schedules", Summary: "Create a schedule", Request: ScheduleRequest{}, Response: core.Schedule{}, Status: http.StatusCreated},
+	{Method: "GET", Path: "/schedules", Tag: "Schedules", Summary: "List schedules", Response: struct {
+		Schedules []core.Schedule `json:"schedules"`
+	}{}},
+	{Method: "POST", Path: "/schedules/preview", Tag: "Schedules", Summary: "Preview a cron expression", Request: SchedulePreviewRequest{}, Response: schedulePreviewResponse{}},
+	{Method: "GET", Path: "/schedules/:id", Tag: "Schedules", Summary: "Get a schedule", Response: core.Schedule{}},
+	{Method: "GET", Path: "/schedules/:id/preview", Tag: "Schedules", Summary: "Preview a schedule's upcoming runs", Response: schedulePreviewResponse{},
+		Query: []apiParam{{"count", "integer", "Number of runs"}}},
+	{Method: "PUT", Path: "/schedules/:id", Tag: "Schedules", Summary: "Update a schedule", Request: ScheduleRequest{}, Response: core.Schedule{}},
+	{Method: "DELETE", Path: "/schedules/:id", Tag: "Schedules", Summary: "Delete a schedule", Response: messageResponse{}},
+
+	{Method: "POST", Path: "/templates", Tag: "Templates", Summary: "Create a workflow template", Request: WorkflowTemplateRequest{}, Response: core.WorkflowTemplate{}, Status: http.StatusCreated},
+	{Method: "GET", Path: "/templates", Tag: "Templates", Summary: "List the latest version of every workflow template", Response: struct {
+		Templates []core.WorkflowTemplate `json:"templates"`
+	}{}},
+	{Method: "GET", Path: "/templates/:name", Tag: "Templates", Summary: "Get a workflow template", Response: core.WorkflowTemplate{},
+		Query: []apiParam{{"version", "integer", "Template version, the latest if omitted"}}},
+	{Method: "GET", Path: "/templates/:name/versions", Tag: "Templates", Summary: "List a workflow template's versions", Response: struct {
+		Versions []core.WorkflowTemplate `json:"versions"`
+	}{}},
+	{Method: "PUT", Path: "/templates/:name", Tag: "Templates", Summary: "Publish a new version of a workflow template", Request: WorkflowTemplateRequest{}, Response: core.WorkflowTemplate{}},
+	{Method: "DELETE", Path: "/templates/:name", Tag: "Templates", Summary: "Delete a workflow template and all its versions", Response: messageResponse{}},
+	{Method: "POST", Path: "/templates/:name/run", Tag: "Templates", Summary: "Start a workflow from a template", Request: RunWorkflowTemplateRequest{}, Response: core.Workflow{}, Status: http.StatusCreated},
+
+	{Method: "GET", Path: "/events", Tag: "Events", Summary: "Read events", Response: struct {
+		Events []core.Event `json:"events"`
+		Next   string       `json:"next"`
+	}{},
+		Query: []apiParam{
+			{"after", "string", "Return events after this ID"},
+			{"count", "integer", "Maximum events, 1 to 1000"},
+			{"block", "string", "Wait up to this duration for new events, at most 1m"},
+			{"workflow_id", "string", "Only events of this workflow"},
+		}},
+
+	{Method: "GET", Path: "/redaction-rules", Tag: "Redaction", Summary: "List redaction rules", Response: struct {
+		Defaults []string             `json:"defaults"`
+		Rules    []core.RedactionRule `json:"rules"`
+	}{}},
+	{Method: "PUT", Path: "/redaction-rules/:type", Tag: "Redaction", Summary: "Set a task type's redaction rule", Request: RedactionRuleRequest{}, Response: core.RedactionRule{}},
+	{Method: "DELETE", Path: "/redaction-rules/:type", Tag: "Redaction", Summary: "Delete a task type's redaction rule", Response: messageResponse{}},
+
+	{Method: "POST", Path: "/webhooks", Tag: "Webhooks", Summary: "Register a webhook", Request: WebhookRequest{}, Response: core.Webhook{}, Status: http.StatusCreated},
+	{Method: "GET", Path: "/webhooks", Tag: "Webhooks", Summary: "List webhooks", Response: struct {
+		Webhooks []core.Webhook `json:"webhooks"`
+	}{},
+		Query: []apiParam{{"workflow_id", "string", "Only webhooks registered for this workflow"}}},
+	{Method: "GET", Path: "/webhooks/:id", Tag: "Webhooks", Summary: "Get a webhook", Response: core.Webhook{}},
+	{Method: "DELETE", Path: "/webhooks/:id", Tag: "Webhooks", Summary: "Delete a webhook", Response: messageResponse{}},
+	{Method: "GET", Path: "/webhooks/:id/deliveries", Tag: "Webhooks", Summary: "Get a webhook's delivery log", Response: struct {
+		Deliveries []core.WebhookAttempt `json:"deliveries"`
+	}{}},
+
+	{Method: "GET", Path: "/slos", Tag: "Queues", Summary: "List queue latency SLOs", Response: struct {
+		SLOs []core.QueueLatencyStats `json:"slos"`
+	}{}},
+	{Method: "PUT", Path: "/slos/:type", Tag: "Queues", Summary: "Set a task type's latency SLO", Request: LatencySLORequest{}, Response: core.LatencySLO{}},
+	{Method: "DELETE", Path: "/slos/:type", Tag: "Queues", Summary: "Delete a task type's latency SLO", Response: messageResponse{}},
+	{Method: "GET", Path: "/queues/:type/latency", Tag: "Queues", Summary: "Get a queue's latency", Response: core.QueueLatencyStats{}},
+	{Method: "GET", Path: "/queues/:type/scaling", Tag: "Queues", Summary: "Get a task type's backlog and workers for autoscaling", Response: core.QueueScalingSignal{}},
+	{Method: "GET", Path: "/scaling", Tag: "Queues", Summary: "Get every task type's backlog and workers for autoscaling", Response: struct {
+		Queues []core.QueueScalingSignal `json:"queues"`
+	}{}},
+
+	{Method: "GET", Path: "/dead-letters/:type", Tag: "Dead Letters", Summary: "Get dead letter queue stats", Response: core.DeadLetterStats{}},
+	{Method: "GET", Path: "/dead-letters/:type/entries/:task_id", Tag: "Dead Letters", Summary: "Get a dead-lettered task", Response: core.Task{}},
+	{Method: "PUT", Path: "/dead-letters/:type/policy", Tag: "Dead Letters", Summary: "Set a dead letter policy", Request: DeadLetterPolicyRequest{}, Response: core.DeadLetterPolicy{}},
+	{Method: "DELETE", Path: "/dead-letters/:type/policy", Tag: "Dead Letters", Summary: "Delete a dead letter policy", Response: messageResponse{}},
+
+	{Method: "GET", Path: "/retries/:type/policy", Tag: "Queues", Summary: "Get a task type's retry overload policy", Response: core.RetryOverloadPolicy{}},
+	{Method: "PUT", Path: "/retries/:type/policy", Tag: "Queues", Summary: "Set a task type's retry overload policy", Request: RetryOverloadPolicyRequest{}, Response: core.RetryOverloadPolicy{}},
+	{Method: "DELETE", Path: "/retries/:type/policy", Tag: "Queues", Summary: "Delete a task type's retry overload policy", Response: messageResponse{}},
+	{Method: "GET", Path: "/queues/:type/fairness", Tag: "Queues", Summary: "Get a task type's dispatch fairness policy", Response: core.DispatchFairnessPolicy{}},
+	{Method: "PUT", Path: "/queues/:type/fairness", Tag: "Queues", Summary: "Set how a task type's retries interleave with its new tasks", Request: DispatchFairnessPolicyRequest{}, Response: core.DispatchFairnessPolicy{}},
+	{Method: "DELETE", Path: "/queues/:type/fairness", Tag: "Queues", Summary: "Delete a task type's dispatch fairness policy", Response: messageResponse{}},
+	{Method: "GET", Path: "/queues/:type/rate-limit", Tag: "Queues", Summary: "Get a task type's rate limit", Response: core.TaskRateLimit{}},
+	{Method: "PUT", Path: "/queues/:type/rate-limit", Tag: "Queues", Summary: "Cap how many tasks of a type are dispatched per interval", Request: TaskRateLimitRequest{}, Response: core.TaskRateLimit{}},
+	{Method: "DELETE", Path: "/queues/:type/rate-limit", Tag: "Queues", Summary: "Delete a task type's rate limit", Response: messageResponse{}},
+	{Method: "GET", Path: "/queues/:type/max-length", Tag: "Queues", Summary: "Get a task type's queue length limit", Response: core.QueueLengthLimit{}},
+	{Method: "PUT", Path: "/queues/:type/max-length", Tag: "Queues", Summary: "Cap how many tasks of a type may wait in Redis", Request: QueueLengthLimitRequest{}, Response: core.QueueLengthLimit{}},
+	{Method: "DELETE", Path: "/queues/:type/max-length", Tag: "Queues", Summary: "Delete a task type's queue length limit", Response: messageResponse{}},
+
+	{Method: "GET", Path: "/admin/scheduler/dry-run", Tag: "Admin", Summary: "Dry-run a scheduling pass", Response: core.DispatchReport{}},
+	{Method: "GET", Path: "/admin/scheduler/decision-log", Tag: "Admin", Summary: "Get the scheduler decision log settings", Response: core.DecisionLogConfig{}},
+	{Method: "PUT", Path: "/admin/scheduler/decision-log", Tag: "Admin", Summary: "Turn on the scheduler decision log", Request: DecisionLogRequest{}, Response: core.DecisionLogConfig{}},
+	{Method: "DELETE", Path: "/admin/scheduler/decision-log", Tag: "Admin", Summary: "Turn off the scheduler decision log", Response: messageResponse{}},
+	{Method: "GET", Path: "/admin/scheduler/decisions", Tag: "Admin", Summary: "List recorded scheduling passes", Response: SchedulingPassList{},
+		Query: []apiParam{
+			{"limit", "integer", "How many passes to return, newest first, 1 to 100 (default 20)"},
+			{"since", "string", "RFC 3339 time; only passes that ran since then"},
+		}},
+	{Method: "POST", Path: "/admin/queues/:type/pause", Tag: "Admin", Summary: "Pause a queue", Response: messageResponse{}},
+	{Method: "POST", Path: "/admin/queues/:type/resume", Tag: "Admin", Summary: "Resume a queue", Response: messageResponse{}},
+	{Method: "GET", Path: "/admin/queues/:type/peek", Tag: "Admin", Summary: "Peek at the head of a queue", Response: core.QueuePeek{},
+		Query: []apiParam{
+			{"count", "integer", "How many tasks to return, 1 to 100 (default 10)"},
+			{"pool", "string", "Read this worker pool's queue instead of the shared one"},
+			{"namespace", "string", "Read this dedicated namespace's queue instead of the shared one"},
+		}},
+	{Method: "POST", Path: "/admin/queues/:type/inject", Tag: "Admin", Summary: "Inject a task into a queue", Request: InjectTaskRequest{}, Response: core.InjectedTask{}, Status: http.StatusCreated},
+	{Method: "GET", Path: "/admin/injected-tasks/:id", Tag: "Admin", Summary: "Get an injected task and its reported outcome", Response: core.InjectedTask{}},
+	{Method: "GET", Path: "/admin/redis/memory", Tag: "Admin", Summary: "Get Redis memory use and its eviction policy", Response: core.RedisMemoryReport{}},
+	{Method: "GET", Path: "/admin/namespaces/:namespace/sandbox-policy", Tag: "Admin", Summary: "Get a namespace's sandbox policy", Response: core.SandboxPolicy{}},
+	{Method: "PUT", Path: "/admin/namespaces/:namespace/sandbox-policy", Tag: "Admin", Summary: "Set a namespace's sandbox policy", Request: SandboxPolicyRequest{}, Response: core.SandboxPolicy{}},
+	{Method: "DELETE", Path: "/admin/namespaces/:namespace/sandbox-policy", Tag: "Admin", Summary: "Delete a namespace's sandbox policy", Response: messageResponse{}},
+
+	{Method: "GET", Path: "/openapi.json", Tag: "System", Summary: "Get this OpenAPI specification"},
+	{Method: "GET", Path: "/docs", Tag: "System", Summary: "Browse the API in Swagger UI"},
+}
+
+var (
+	openAPIOnce sync.Once
+	openAPISpec []byte
+	openAPIErr  error
+)
+
+func (s *Server) getOpenAPISpec(c *gin.Context) {
+	openAPIOnce.Do(func() {
+		openAPISpec, openAPIErr = json.Marshal(buildOpenAPISpec(apiOperations))
+	})
+	if openAPIErr != nil {
+		s.logger.Errorf("Failed to build OpenAPI specification: %v", openAPIErr)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build OpenAPI specification"})
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json", openAPISpec)
+}
+
+func (s *Server) getSwaggerUI(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", swaggerUI)
+}
+
+// checkOpenAPICoverage logs API routes that apiOperations does not
+// document, so the specification does not silently fall behind.
+func (s *Server) checkOpenAPICoverage() {
+	documented := make(map[string]bool, len(apiOperations))
+	for _, op := range apiOperations {
+		documented[op.Method+" /api/v1"+op.Path] = true
+	}
+
+	for _, route := range s.router.Routes() {
+		if !strings.HasPrefix(route.Path, "/api/v1/") {
+			continue
+		}
+		if !documented[route.Method+" "+route.Path] {
+			s.logger.Warnf("Route %s %s is missing from the OpenAPI specification", route.Method, route.Path)
+		}
+	}
+}
+
+var pathParamPattern = regexp.MustCompile(`:([A-Za-z_]+)`)
+
+func buildOpenAPISpec(operations []apiOperation) map[string]interface{} {
+	schemas := newSchemaRegistry()
+	schemas.schemaOf(reflect.TypeOf(ErrorResponse{}))
+
+	paths := make(map[string]map[string]interface{})
+	for _, op := range operations {
+		path := pathParamPattern.ReplaceAllString(op.Path, "{$1}")
+		if paths[path] == nil {
+			paths[path] = make(map[string]interface{})
+		}
+
+		var parameters []map[string]interface{}
+		for _, match := range pathParamPattern.FindAllStringSubmatch(op.Path, -1) {
+			parameters = append(parameters, map[string]interface{}{
+				"name":     match[1],
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]interface{}{"type": "string"},
+			})
+		}
+		for _, param := range op.Query {
+			parameters = append(parameters, map[string]interface{}{
+				"name":        param.Name,
+				"in":          "query",
+				"description": param.Description,
+				"schema":      map[string]interface{}{"type": param.Type},
+			})
+		}
+
+		status := op.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		response := map[string]interface{}{"description": http.StatusText(status)}
+		switch {
+		case op.Stream:
+			response["content"] = map[string]interface{}{
+				"text/event-stream": map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+			}
+		case op.Response != nil:
+			response["content"] = jsonContent(schemas.schemaOf(reflect.TypeOf(op.Response)))
+		}
+
+		operation := map[string]interface{}{
+			"tags":        []string{op.Tag},
+			"summary":     op.Summary,
+			"operationId": operationID(op),
+			"responses": map[string]interface{}{
+				strconv.Itoa(status): response,
+				"default": map[string]interface{}{
+					"description": "Error",
+					"content":     jsonContent(map[string]interface{}{"$ref": "#/components/schemas/ErrorResponse"}),
+				},
+			},
+		}
+		if len(parameters) > 0 {
+			operation["parameters"] = parameters
+		}
+		if op.Request != nil {
+			content := jsonContent(schemas.schemaOf(reflect.TypeOf(op.Request)))
+			if op.YAML {
+				content["application/yaml"] = map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}
+			}
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  content,
+			}
+		}
+
+		paths[path][strings.ToLower(op.Method)] = operation
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "FlowCtl API",
+			"version": "v1",
+		},
+		"servers":    []map[string]interface{}{{"url": "/api/v1"}},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": schemas.named},
+	}
+}
+
+func jsonContent(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{"schema": schema},
+	}
+}
+
+// operationID names an operation for generated clients, e.g. "getWorkflowsId".
+func operationID(op apiOperation) string {
+	var id strings.Builder
+	id.WriteString(strings.ToLower(op.Method))
+	for _, part := range strings.FieldsFunc(op.Path, func(r rune) bool {
+		return r == '/' || r == '-' || r == '_' || r == ':' || r == '.'
+	}) {
+		id.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return id.String()
+}
+
+// schemaRegistry turns Go types into OpenAPI schemas. Named struct types are
+// added to components once and referenced; anonymous structs are inlined.
+type schemaRegistry struct {
+	named map[string]interface{}
+	types map[reflect.Type]string
+}
+
+func newSchemaRegistry() *schemaRegistry {
+	return &schemaRegistry{
+		named: make(map[string]interface{}),
+		types: make(map[reflect.Type]string),
+	}
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	durationType   = reflect.TypeOf(time.Duration(0))
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+func (r *schemaRegistry) schemaOf(t reflect.Type) map[string]interface{} {
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case durationType:
+		return map[string]interface{}{"type": "integer", "format": "int64", "description": "Duration in nanoseconds"}
+	case rawMessageType:
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := r.schemaOf(t.Elem())
+		if _, isRef := schema["$ref"]; isRef {
+			return schema
+		}
+		schema["nullable"] = true
+		return schema
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": r.schemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": r.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return r.structSchema(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + r.register(t)}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// register adds a named struct to components and returns its schema name.
+// A name shared by types of different packages is prefixed with the
+// package.
+func (r *schemaRegistry) register(t reflect.Type) string {
+	if name, ok := r.types[t]; ok {
+		return name
+	}
+
+	name := strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+	if _, taken := r.named[name]; taken {
+		pkg := t.PkgPath()
+		pkg = pkg[strings.LastIndex(pkg, "/")+1:]
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+
+	r.types[t] = name
+	// Reserve the name before building the schema, so that recursive types
+	// refer to it rather than recursing forever.
+	r.named[name] = map[string]interface{}{}
+	r.named[name] = r.structSchema(t)
+	return name
+}
+
+func (r *schemaRegistry) structSchema(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	var required []string
+	r.addFields(t, properties, &required)
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func (r *schemaRegistry) addFields(t reflect.Type, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				r.addFields(embedded, properties, required)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = r.schemaOf(field.Type)
+		if strings.Contains(field.Tag.Get("binding"), "required") {
+			*required = append(*required, name)
+		}
+	}
+}
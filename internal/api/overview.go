@@ -0,0 +1,18 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) getOverview(c *gin.Context) {
+	overview, err := s.scheduler.GetOverview(c.Request.Context())
+	if err != nil {
+		s.logger.Errorf("Failed to build overview: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build overview"})
+		return
+	}
+
+	c.JSON(http.StatusOK, overview)
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetTrustedProxies sets the addresses or CIDR ranges of the proxies in
+// front of the API. Only requests they forward have their X-Forwarded-For
+// and X-Flowctl-Subject headers believed; for any other request the
+// connection's address is the client and there is no subject. Without
+// trusted proxies, which is the default, no request's headers are believed.
+func (s *Server) SetTrustedProxies(proxies []string) error {
+	var cidrs []string
+	var networks []*net.IPNet
+	for _, proxy := range proxies {
+		cidr := strings.TrimSpace(proxy)
+		if !strings.Contains(cidr, "/") {
+			if ip := net.ParseIP(cidr); ip != nil && ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("invalid trusted proxy %q", strings.TrimSpace(proxy))
+		}
+		cidrs = append(cidrs, cidr)
+		networks = append(networks, network)
+	}
+
+	if err := s.router.SetTrustedProxies(cidrs); err != nil {
+		return err
+	}
+	s.trustedProxies = networks
+	return nil
+}
+
+// fromTrustedProxy reports whether the request was forwarded by one of the
+// trusted proxies.
+func (s *Server) fromTrustedProxy(c *gin.Context) bool {
+	ip := net.ParseIP(c.RemoteIP())
+	if ip == nil {
+		return false
+	}
+	for _, network := range s.trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// subject returns the caller's identity from SubjectHeader, as set by a
+// trusted authenticating proxy, or "" when the request did not come through
+// one.
+func (s *Server) subject(c *gin.Context) string {
+	if !s.fromTrustedProxy(c) {
+		return ""
+	}
+	return c.GetHeader(SubjectHeader)
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"flowctl/internal/core"
+
+	"github.com/gin-gonic/gin"
+)
+
+type QueueLengthLimitRequest struct {
+	MaxLength int64 `json:"max_length" binding:"required"`
+}
+
+// backpressureResponse is returned with 429 when a task type's queue is
+// full. RetryAfter is also sent, in whole seconds, as Retry-After.
+type backpressureResponse struct {
+	Error string `json:"error"`
+	core.BackpressureError
+}
+
+// respondBackpressure answers 429 with a retry hint if err is a full queue,
+// reporting whether it did. A full queue reported without the details of a
+// *core.BackpressureError gets the default hint.
+func (s *Server) respondBackpressure(c *gin.Context, err error) bool {
+	wait, full := backpressureRetryAfter(err)
+	if !full {
+		return false
+	}
+	setRetryAfter(c, wait)
+
+	var backpressure *core.BackpressureError
+	if !errors.As(err, &backpressure) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return true
+	}
+	c.JSON(http.StatusTooManyRequests, backpressureResponse{Error: err.Error(), BackpressureError: *backpressure})
+	return true
+}
+
+// backpressureRetryAfter returns how long to wait before retrying work
+// rejected with err, and whether err is a full queue at all.
+func backpressureRetryAfter(err error) (time.Duration, bool) {
+	var backpressure *core.BackpressureError
+	if errors.As(err, &backpressure) {
+		return backpressure.RetryAfter, true
+	}
+	if errors.Is(err, core.ErrQueueFull) {
+		return core.DefaultBackpressureRetryAfter, true
+	}
+	return 0, false
+}
+
+// setRetryAfter sends wait, rounded up to whole seconds, as Retry-After.
+func setRetryAfter(c *gin.Context, wait time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+}
+
+func (s *Server) getQueueLengthLimit(c *gin.Context) {
+	taskType := c.Param("type")
+
+	limit, err := s.scheduler.GetQueueLengthLimit(c.Request.Context(), taskType)
+	if err != nil {
+		s.logger.Errorf("Failed to get queue length limit for %s: %v", taskType, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get queue length limit"})
+		return
+	}
+	if limit == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Queue length limit not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, limit)
+}
+
+func (s *Server) setQueueLengthLimit(c *gin.Context) {
+	taskType := c.Param("type")
+
+	var req QueueLengthLimitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	limit := core.QueueLengthLimit{TaskType: taskType, MaxLength: req.MaxLength}
+	if err := limit.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.scheduler.SetQueueLengthLimit(c.Request.Context(), limit); err != nil {
+		s.logger.Errorf("Failed to set queue length limit for %s: %v", taskType, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set queue length limit"})
+		return
+	}
+
+	c.JSON(http.StatusOK, limit)
+}
+
+func (s *Server) deleteQueueLengthLimit(c *gin.Context) {
+	taskType := c.Param("type")
+
+	if err := s.scheduler.DeleteQueueLengthLimit(c.Request.Context(), taskType); err != nil {
+		s.logger.Errorf("Failed to delete queue length limit for %s: %v", taskType, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete queue length limit"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Queue length limit deleted"})
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"flowctl/internal/core"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRespondBackpressureAnswersEveryFullQueue(t *testing.T) {
+	s := newTestServer()
+	tests := []struct {
+		name       string
+		err        error
+		responded  bool
+		retryAfter string
+	}{
+		{
+			name:       "backpressure error",
+			err:        fmt.Errorf("submit: %w", &core.BackpressureError{TaskType: "etl", Length: 10, MaxLength: 10, RetryAfter: 1500 * time.Millisecond}),
+			responded:  true,
+			retryAfter: "2",
+		},
+		{
+			name:       "bare full queue",
+			err:        fmt.Errorf("submit: %w", core.ErrQueueFull),
+			responded:  true,
+			retryAfter: "30",
+		},
+		{
+			name: "other error",
+			err:  errors.New("connection refused"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+
+			if responded := s.respondBackpressure(c, tt.err); responded != tt.responded {
+				t.Fatalf("respondBackpressure() = %v, want %v", responded, tt.responded)
+			}
+			if !tt.responded {
+				return
+			}
+			if w.Code != http.StatusTooManyRequests {
+				t.Errorf("status = %d, want 429", w.Code)
+			}
+			if got := w.Header().Get("Retry-After"); got != tt.retryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.retryAfter)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"flowctl/internal/core"
+
+	"github.com/gin-gonic/gin"
+)
+
+// InjectTaskRequest is a task to put straight onto a queue. The task type
+// comes from the route.
+type InjectTaskRequest struct {
+	Name     string                 `json:"name"`
+	Payload  map[string]interface{} `json:"payload" binding:"required"`
+	Priority int                    `json:"priority"`
+	Pool     string                 `json:"pool"`
+	Timeout  time.Duration          `json:"timeout"`
+}
+
+func (s *Server) peekQueue(c *gin.Context) {
+	taskType := c.Param("type")
+
+	count := core.DefaultQueuePeekCount
+	if raw := c.Query("count"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 || parsed > core.MaxQueuePeekCount {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "count must be between 1 and " + strconv.Itoa(core.MaxQueuePeekCount)})
+			return
+		}
+		count = parsed
+	}
+
+	pool, namespace := c.Query("pool"), c.Query("namespace")
+	if pool != "" && namespace != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "pool and namespace cannot both be set"})
+		return
+	}
+
+	peek, err := s.scheduler.PeekQueue(c.Request.Context(), taskType, pool, namespace, count, s.adminSubject(c))
+	if err != nil {
+		s.logger.Errorf("Failed to peek at queue %s: %v", taskType, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to peek at queue"})
+		return
+	}
+
+	for i := range peek.Entries {
+		if peek.Entries[i].Task != nil {
+			redacted := s.redactTask(c, *peek.Entries[i].Task)
+			peek.Entries[i].Task = &redacted
+		}
+	}
+
+	c.JSON(http.StatusOK, peek)
+}
+
+func (s *Server) injectTask(c *gin.Context) {
+	var req InjectTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	task := core.NewInjectedTask(c.Param("type"), req.Name, req.Payload)
+	task.Priority = req.Priority
+	task.Pool = req.Pool
+	task.Timeout = req.Timeout
+
+	injected, err := s.scheduler.InjectTask(c.Request.Context(), task, s.adminSubject(c))
+	if err != nil {
+		if errors.Is(err, core.ErrQueueInjectionDisabled) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if s.respondBackpressure(c, err) {
+			return
+		}
+		s.logger.Errorf("Failed to inject task into queue %s: %v", task.Type, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to inject task"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, s.redactInjectedTask(c, injected))
+}
+
+func (s *Server) getInjectedTask(c *gin.Context) {
+	taskID := c.Param("id")
+
+	injected, err := s.scheduler.GetInjectedTask(c.Request.Context(), taskID)
+	if err != nil {
+		s.logger.Errorf("Failed to get injected task %s: %v", taskID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get injected task"})
+		return
+	}
+	if injected == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Injected task not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, s.redactInjectedTask(c, injected))
+}
+
+// getRedisMemory reports Redis's memory use and eviction policy, with an
+// estimate of the memory each task type's queues hold.
+func (s *Server) getRedisMemory(c *gin.Context) {
+	report, err := s.scheduler.RedisMemoryReport(c.Request.Context())
+	if err != nil {
+		s.logger.Errorf("Failed to get Redis memory report: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get Redis memory report"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
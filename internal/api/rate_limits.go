@@ -0,0 +1,83 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"flowctl/internal/core"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TaskRateLimitRequest struct {
+	Limit     int                 `json:"limit" binding:"required"`
+	Interval  string              `json:"interval" binding:"required"`
+	EnforceAt core.RateLimitStage `json:"enforce_at"`
+}
+
+func (s *Server) getTaskRateLimit(c *gin.Context) {
+	taskType := c.Param("type")
+
+	limit, err := s.scheduler.GetTaskRateLimit(c.Request.Context(), taskType)
+	if err != nil {
+		s.logger.Errorf("Failed to get rate limit for %s: %v", taskType, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get rate limit"})
+		return
+	}
+	if limit == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Rate limit not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, limit)
+}
+
+func (s *Server) setTaskRateLimit(c *gin.Context) {
+	taskType := c.Param("type")
+
+	var req TaskRateLimitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	interval, err := time.ParseDuration(req.Interval)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "interval must be a duration such as 1m"})
+		return
+	}
+
+	limit := core.TaskRateLimit{
+		TaskType:  taskType,
+		Limit:     req.Limit,
+		Interval:  interval,
+		EnforceAt: req.EnforceAt,
+	}
+	if limit.EnforceAt == "" {
+		limit.EnforceAt = core.RateLimitAtDequeue
+	}
+	if err := limit.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.scheduler.SetTaskRateLimit(c.Request.Context(), limit); err != nil {
+		s.logger.Errorf("Failed to set rate limit for %s: %v", taskType, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set rate limit"})
+		return
+	}
+
+	c.JSON(http.StatusOK, limit)
+}
+
+func (s *Server) deleteTaskRateLimit(c *gin.Context) {
+	taskType := c.Param("type")
+
+	if err := s.scheduler.DeleteTaskRateLimit(c.Request.Context(), taskType); err != nil {
+		s.logger.Errorf("Failed to delete rate limit for %s: %v", taskType, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete rate limit"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Rate limit deleted"})
+}
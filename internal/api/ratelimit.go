@@ -0,0 +1,141 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rateLimitSweepInterval is how often buckets that have refilled, and so
+// carry no state worth keeping, are dropped, as are buckets of clients idle
+// for rateLimitIdleTimeout.
+const (
+	rateLimitSweepInterval = time.Minute
+	rateLimitIdleTimeout   = 10 * time.Minute
+)
+
+// maxRateLimitBuckets is how many clients the limiter tracks before it
+// sweeps early.
+const maxRateLimitBuckets = 100000
+
+// rateLimitExempt are the routes workers call to report on tasks. Rejecting
+// them would lose task outcomes rather than slow a submitter down.
+var rateLimitExempt = map[string]bool{
+	"/api/v1/tasks/:id/status": true,
+}
+
+// RateLimiter is a token bucket per client: each client may make burst
+// requests at once, refilled at rate requests per second.
+type RateLimiter struct {
+	rate  float64
+	burst float64
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:      rate,
+		burst:     float64(burst),
+		buckets:   make(map[string]*tokenBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow takes a token from the client's bucket. When the bucket is empty it
+// returns false and how long until the next token.
+func (l *RateLimiter) Allow(client string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	bucket, ok := l.buckets[client]
+	if !ok {
+		bucket = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[client] = bucket
+	}
+
+	bucket.tokens = math.Min(l.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate)
+	bucket.last = now
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second))
+	return false, wait
+}
+
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < rateLimitSweepInterval && len(l.buckets) < maxRateLimitBuckets {
+		return
+	}
+	l.lastSweep = now
+
+	for client, bucket := range l.buckets {
+		idle := now.Sub(bucket.last)
+		if idle >= rateLimitIdleTimeout || bucket.tokens+idle.Seconds()*l.rate >= l.burst {
+			delete(l.buckets, client)
+		}
+	}
+}
+
+// SetRateLimiter limits how fast each client may call the API. Without
+// one, requests are not limited.
+func (s *Server) SetRateLimiter(limiter *RateLimiter) {
+	s.rateLimiter = limiter
+}
+
+// chargeRateLimit takes a token from the bucket of the client making the
+// request, when charge is set and requests are limited.
+func (s *Server) chargeRateLimit(c *gin.Context, charge bool) (bool, time.Duration) {
+	if !charge || s.rateLimiter == nil || rateLimitExempt[c.FullPath()] {
+		return true, 0
+	}
+
+	return s.rateLimiter.Allow(s.rateLimitClient(c))
+}
+
+// rateLimitClient names the bucket of the client making the request: its
+// subject when a trusted proxy vouches for one, otherwise its address.
+// Headers the client sets itself, such as an API key, are not used, since
+// a client could change them on every request.
+func (s *Server) rateLimitClient(c *gin.Context) string {
+	if subject := s.subject(c); subject != "" {
+		return "subject:" + subject
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// rateLimit rejects a client's request with 429 once it has used up its
+// bucket, telling it when to try again.
+func (s *Server) rateLimit(c *gin.Context) {
+	if s.rateLimiter == nil || rateLimitExempt[c.FullPath()] {
+		c.Next()
+		return
+	}
+
+	allowed, wait := s.chargeRateLimit(c, true)
+	if !allowed {
+		setRetryAfter(c, wait)
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Rate limit exceeded"})
+		return
+	}
+
+	c.Next()
+}
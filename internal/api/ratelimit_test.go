@@ -0,0 +1,81 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// rateLimitedGet sends a GET from remoteAddr with headers and returns the
+// status it got.
+func rateLimitedGet(s *Server, remoteAddr string, headers map[string]string) int {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/leader", nil)
+	req.RemoteAddr = remoteAddr
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	s.router.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestRateLimitIgnoresClientChosenHeaders(t *testing.T) {
+	s := newTestServer()
+	s.SetRateLimiter(NewRateLimiter(0.001, 1))
+
+	rateLimitedGet(s, "203.0.113.7:1000", nil)
+	// Neither a fresh API key, a forged X-Forwarded-For nor a subject
+	// from an untrusted address gets the client a new bucket.
+	for _, headers := range []map[string]string{
+		{"X-API-Key": "another-key"},
+		{"X-Forwarded-For": "198.51.100.1"},
+		{SubjectHeader: "someone-else"},
+	} {
+		if code := rateLimitedGet(s, "203.0.113.7:1001", headers); code != http.StatusTooManyRequests {
+			t.Errorf("request with %v answered %d, want 429", headers, code)
+		}
+	}
+}
+
+func TestRateLimitKeysOnSubjectFromTrustedProxy(t *testing.T) {
+	s := newTestServer()
+	s.SetRateLimiter(NewRateLimiter(0.001, 1))
+	if err := s.SetTrustedProxies([]string{"10.0.0.0/8"}); err != nil {
+		t.Fatal(err)
+	}
+
+	proxy := "10.1.2.3:1000"
+	rateLimitedGet(s, proxy, map[string]string{SubjectHeader: "alice"})
+	if code := rateLimitedGet(s, proxy, map[string]string{SubjectHeader: "alice"}); code != http.StatusTooManyRequests {
+		t.Errorf("second request of alice answered %d, want 429", code)
+	}
+	if code := rateLimitedGet(s, proxy, map[string]string{SubjectHeader: "bob"}); code == http.StatusTooManyRequests {
+		t.Error("bob was limited by alice's requests through the same proxy")
+	}
+}
+
+func TestSetTrustedProxiesRejectsInvalidRanges(t *testing.T) {
+	s := newTestServer()
+	if err := s.SetTrustedProxies([]string{"10.0.0.0/8", "not-an-ip"}); err == nil {
+		t.Error("SetTrustedProxies() accepted an invalid proxy")
+	}
+}
+
+func TestRateLimiterEvictsIdleBuckets(t *testing.T) {
+	l := NewRateLimiter(0.0001, 5)
+	l.Allow("busy")
+	l.Allow("idle")
+
+	// Neither bucket has refilled, but one has been idle long enough.
+	now := time.Now()
+	l.buckets["idle"].last = now.Add(-rateLimitIdleTimeout)
+	l.sweep(now.Add(rateLimitSweepInterval))
+
+	if _, ok := l.buckets["idle"]; ok {
+		t.Error("idle bucket was kept")
+	}
+	if _, ok := l.buckets["busy"]; !ok {
+		t.Error("busy bucket was dropped")
+	}
+}
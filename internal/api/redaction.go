@@ -0,0 +1,119 @@
+package api
+
+import (
+	"net/http"
+
+	"flowctl/internal/core"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RedactionRuleRequest struct {
+	Fields []string `json:"fields" binding:"required"`
+}
+
+func (s *Server) listRedactionRules(c *gin.Context) {
+	rules, err := s.scheduler.GetRedactionRules(c.Request.Context())
+	if err != nil {
+		s.logger.Errorf("Failed to list redaction rules: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list redaction rules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"defaults": core.DefaultRedactedFields,
+		"rules":    rules,
+	})
+}
+
+func (s *Server) setRedactionRule(c *gin.Context) {
+	var req RedactionRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule := core.RedactionRule{
+		TaskType: c.Param("type"),
+		Fields:   req.Fields,
+	}
+
+	if err := rule.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.scheduler.SetRedactionRule(c.Request.Context(), rule); err != nil {
+		s.logger.Errorf("Failed to set redaction rule for %s: %v", rule.TaskType, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set redaction rule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+func (s *Server) deleteRedactionRule(c *gin.Context) {
+	taskType := c.Param("type")
+
+	if err := s.scheduler.DeleteRedactionRule(c.Request.Context(), taskType); err != nil {
+		s.logger.Errorf("Failed to delete redaction rule for %s: %v", taskType, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete redaction rule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Redaction rule deleted"})
+}
+
+func (s *Server) redactTask(c *gin.Context, task core.Task) core.Task {
+	return core.RedactTask(s.scheduler.Redactor(c.Request.Context()), task)
+}
+
+func (s *Server) redactTasks(c *gin.Context, tasks []core.Task) []core.Task {
+	redactor := s.scheduler.Redactor(c.Request.Context())
+
+	redacted := make([]core.Task, len(tasks))
+	for i, task := range tasks {
+		redacted[i] = core.RedactTask(redactor, task)
+	}
+	return redacted
+}
+
+func (s *Server) redactWorkflow(c *gin.Context, workflow *core.Workflow) *core.Workflow {
+	redacted := *workflow
+	redacted.Params = s.scheduler.Redactor(c.Request.Context()).Redact("", workflow.Params)
+	redacted.Tasks = s.redactTasks(c, workflow.Tasks)
+	return &redacted
+}
+
+func (s *Server) redactSchedule(c *gin.Context, schedule *core.Schedule) *core.Schedule {
+	redactor := s.scheduler.Redactor(c.Request.Context())
+
+	redacted := *schedule
+	redacted.Workflow.Tasks = make([]core.TaskDefinition, len(schedule.Workflow.Tasks))
+	for i, taskDef := range schedule.Workflow.Tasks {
+		taskDef.Payload = redactor.Redact(taskDef.Type, taskDef.Payload)
+		redacted.Workflow.Tasks[i] = taskDef
+	}
+	return &redacted
+}
+
+func (s *Server) redactWorkflowTemplate(c *gin.Context, tmpl *core.WorkflowTemplate) *core.WorkflowTemplate {
+	redactor := s.scheduler.Redactor(c.Request.Context())
+
+	redacted := *tmpl
+	redacted.Workflow.Tasks = make([]core.TaskDefinition, len(tmpl.Workflow.Tasks))
+	for i, taskDef := range tmpl.Workflow.Tasks {
+		taskDef.Payload = redactor.Redact(taskDef.Type, taskDef.Payload)
+		redacted.Workflow.Tasks[i] = taskDef
+	}
+	return &redacted
+}
+
+func (s *Server) redactInjectedTask(c *gin.Context, injected *core.InjectedTask) *core.InjectedTask {
+	redactor := s.scheduler.Redactor(c.Request.Context())
+
+	redacted := *injected
+	redacted.Task = core.RedactTask(redactor, injected.Task)
+	redacted.Result = redactor.Redact(injected.Task.Type, injected.Result)
+	return &redacted
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"flowctl/internal/core"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RerunWorkflowRequest optionally replaces top-level payload fields of the
+// rerun's tasks, keyed by task name.
+type RerunWorkflowRequest struct {
+	Overrides map[string]map[string]interface{} `json:"overrides"`
+}
+
+func (s *Server) rerunWorkflow(c *gin.Context) {
+	workflowID := c.Param("id")
+
+	var req RerunWorkflowRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	original, err := s.scheduler.GetWorkflow(workflowID)
+	if err != nil {
+		s.logger.Errorf("Failed to get workflow %s: %v", workflowID, err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Workflow not found"})
+		return
+	}
+
+	workflow, err := original.Rerun(req.Overrides)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.scheduler.SubmitWorkflow(c.Request.Context(), workflow); err != nil {
+		if errors.Is(err, core.ErrSandboxViolation) || errors.Is(err, core.ErrAdmissionDenied) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if s.respondBackpressure(c, err) {
+			return
+		}
+		s.logger.Errorf("Failed to submit rerun of workflow %s: %v", workflowID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create workflow"})
+		return
+	}
+
+	s.logger.Infof("Workflow %s rerun as %s", workflowID, workflow.ID)
+	c.JSON(http.StatusCreated, s.redactWorkflow(c, workflow))
+}
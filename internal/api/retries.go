@@ -0,0 +1,78 @@
+package api
+
+import (
+	"net/http"
+
+	"flowctl/internal/core"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RetryOverloadPolicyRequest struct {
+	MaxSize         int64                    `json:"max_size" binding:"required"`
+	Action          core.RetryOverloadAction `json:"action" binding:"required"`
+	ProtectPriority int                      `json:"protect_priority"`
+}
+
+func (s *Server) getRetryOverloadPolicy(c *gin.Context) {
+	taskType := c.Param("type")
+
+	policy, err := s.scheduler.GetRetryOverloadPolicy(c.Request.Context(), taskType)
+	if err != nil {
+		s.logger.Errorf("Failed to get retry overload policy for %s: %v", taskType, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get retry overload policy"})
+		return
+	}
+	if policy == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Retry overload policy not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, policy)
+}
+
+func (s *Server) setRetryOverloadPolicy(c *gin.Context) {
+	taskType := c.Param("type")
+
+	var req RetryOverloadPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.MaxSize <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "max_size must be positive"})
+		return
+	}
+	if !req.Action.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "action must be one of evict, fast_fail"})
+		return
+	}
+
+	policy := core.RetryOverloadPolicy{
+		TaskType:        taskType,
+		MaxSize:         req.MaxSize,
+		Action:          req.Action,
+		ProtectPriority: req.ProtectPriority,
+	}
+
+	if err := s.scheduler.SetRetryOverloadPolicy(c.Request.Context(), policy); err != nil {
+		s.logger.Errorf("Failed to set retry overload policy for %s: %v", taskType, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set retry overload policy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, policy)
+}
+
+func (s *Server) deleteRetryOverloadPolicy(c *gin.Context) {
+	taskType := c.Param("type")
+
+	if err := s.scheduler.DeleteRetryOverloadPolicy(c.Request.Context(), taskType); err != nil {
+		s.logger.Errorf("Failed to delete retry overload policy for %s: %v", taskType, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete retry overload policy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Retry overload policy deleted"})
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"net/http"
+
+	"flowctl/internal/core"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SandboxPolicyRequest struct {
+	AllowedExecutors []core.Executor `json:"allowed_executors" binding:"required"`
+	MaxCPU           float64         `json:"max_cpu"`
+	MaxMemoryMB      int64           `json:"max_memory_mb"`
+	DefaultCPU       float64         `json:"default_cpu"`
+	DefaultMemoryMB  int64           `json:"default_memory_mb"`
+}
+
+func (s *Server) getSandboxPolicy(c *gin.Context) {
+	namespace := c.Param("namespace")
+
+	policy, err := s.scheduler.GetSandboxPolicy(c.Request.Context(), namespace)
+	if err != nil {
+		s.logger.Errorf("Failed to get sandbox policy for %s: %v", namespace, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get sandbox policy"})
+		return
+	}
+	if policy == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sandbox policy not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, policy)
+}
+
+func (s *Server) setSandboxPolicy(c *gin.Context) {
+	var req SandboxPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	policy := core.SandboxPolicy{
+		Namespace:        c.Param("namespace"),
+		AllowedExecutors: req.AllowedExecutors,
+		MaxCPU:           req.MaxCPU,
+		MaxMemoryMB:      req.MaxMemoryMB,
+		DefaultCPU:       req.DefaultCPU,
+		DefaultMemoryMB:  req.DefaultMemoryMB,
+	}
+
+	if err := policy.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.scheduler.SetSandboxPolicy(c.Request.Context(), policy); err != nil {
+		s.logger.Errorf("Failed to set sandbox policy for %s: %v", policy.Namespace, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set sandbox policy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, policy)
+}
+
+func (s *Server) deleteSandboxPolicy(c *gin.Context) {
+	namespace := c.Param("namespace")
+
+	if err := s.scheduler.DeleteSandboxPolicy(c.Request.Context(), namespace); err != nil {
+		s.logger.Errorf("Failed to delete sandbox policy for %s: %v", namespace, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete sandbox policy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Sandbox policy deleted"})
+}
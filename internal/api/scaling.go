@@ -0,0 +1,33 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getQueueScalingSignal serves a task type's scaling signal as a flat JSON
+// object, the shape KEDA's metrics-api scaler reads a value from.
+func (s *Server) getQueueScalingSignal(c *gin.Context) {
+	taskType := c.Param("type")
+
+	signal, err := s.scheduler.QueueScalingSignal(c.Request.Context(), taskType)
+	if err != nil {
+		s.logger.Errorf("Failed to get scaling signal for %s: %v", taskType, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get scaling signal"})
+		return
+	}
+
+	c.JSON(http.StatusOK, signal)
+}
+
+func (s *Server) listQueueScalingSignals(c *gin.Context) {
+	signals, err := s.scheduler.QueueScalingSignals(c.Request.Context())
+	if err != nil {
+		s.logger.Errorf("Failed to get scaling signals: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get scaling signals"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"queues": signals})
+}
@@ -0,0 +1,201 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"flowctl/internal/core"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ScheduleRequest struct {
+	Name     string                `json:"name" binding:"required"`
+	Cron     string                `json:"cron" binding:"required"`
+	Timezone string                `json:"timezone"`
+	Enabled  *bool                 `json:"enabled,omitempty"`
+	Calendar *core.Calendar        `json:"calendar,omitempty"`
+	Workflow CreateWorkflowRequest `json:"workflow" binding:"required"`
+}
+
+type SchedulePreviewRequest struct {
+	Cron     string     `json:"cron" binding:"required"`
+	Timezone string     `json:"timezone"`
+	Count    int        `json:"count,omitempty"`
+	After    *time.Time `json:"after,omitempty"`
+}
+
+const defaultSchedulePreviewRuns = 5
+
+func (s *Server) createSchedule(c *gin.Context) {
+	var req ScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	schedule := core.NewSchedule(req.Name, req.Cron, req.Timezone, *req.Workflow.toDefinition())
+	if req.Enabled != nil {
+		schedule.Enabled = *req.Enabled
+	}
+	schedule.Calendar = req.Calendar
+
+	if err := schedule.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.scheduler.CreateSchedule(c.Request.Context(), schedule); err != nil {
+		s.logger.Errorf("Failed to create schedule: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create schedule"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, s.redactSchedule(c, schedule))
+}
+
+func (s *Server) listSchedules(c *gin.Context) {
+	schedules, err := s.scheduler.ListSchedules()
+	if err != nil {
+		s.logger.Errorf("Failed to list schedules: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list schedules"})
+		return
+	}
+
+	for i := range schedules {
+		schedules[i] = *s.redactSchedule(c, &schedules[i])
+	}
+
+	c.JSON(http.StatusOK, gin.H{"schedules": schedules})
+}
+
+func (s *Server) getSchedule(c *gin.Context) {
+	scheduleID := c.Param("id")
+
+	schedule, err := s.scheduler.GetSchedule(scheduleID)
+	if err != nil {
+		s.logger.Errorf("Failed to get schedule %s: %v", scheduleID, err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Schedule not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, s.redactSchedule(c, schedule))
+}
+
+func (s *Server) updateSchedule(c *gin.Context) {
+	scheduleID := c.Param("id")
+
+	var req ScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	schedule, err := s.scheduler.GetSchedule(scheduleID)
+	if err != nil {
+		s.logger.Errorf("Failed to get schedule %s: %v", scheduleID, err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Schedule not found"})
+		return
+	}
+
+	schedule.Name = req.Name
+	schedule.Cron = req.Cron
+	if req.Timezone != "" {
+		schedule.Timezone = req.Timezone
+	}
+	if req.Enabled != nil {
+		schedule.Enabled = *req.Enabled
+	}
+	schedule.Calendar = req.Calendar
+	schedule.Workflow = *req.Workflow.toDefinition()
+
+	if err := schedule.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.scheduler.UpdateSchedule(c.Request.Context(), schedule); err != nil {
+		s.logger.Errorf("Failed to update schedule %s: %v", scheduleID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update schedule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, s.redactSchedule(c, schedule))
+}
+
+func (s *Server) deleteSchedule(c *gin.Context) {
+	scheduleID := c.Param("id")
+
+	if err := s.scheduler.DeleteSchedule(c.Request.Context(), scheduleID); err != nil {
+		s.logger.Errorf("Failed to delete schedule %s: %v", scheduleID, err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Schedule not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Schedule deleted"})
+}
+
+func (s *Server) previewSchedule(c *gin.Context) {
+	var req SchedulePreviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Count == 0 {
+		req.Count = defaultSchedulePreviewRuns
+	}
+
+	after := time.Now()
+	if req.After != nil {
+		after = *req.After
+	}
+
+	schedule := core.NewSchedule("", req.Cron, req.Timezone, core.WorkflowDefinition{})
+	runs, err := schedule.Preview(after, req.Count)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"cron":       schedule.Cron,
+		"timezone":   schedule.Timezone,
+		"fire_times": runs,
+	})
+}
+
+func (s *Server) previewSavedSchedule(c *gin.Context) {
+	scheduleID := c.Param("id")
+
+	count := defaultSchedulePreviewRuns
+	if value := c.Query("count"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid count"})
+			return
+		}
+		count = parsed
+	}
+
+	schedule, err := s.scheduler.GetSchedule(scheduleID)
+	if err != nil {
+		s.logger.Errorf("Failed to get schedule %s: %v", scheduleID, err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Schedule not found"})
+		return
+	}
+
+	runs, err := schedule.UpcomingRuns(time.Now(), count)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"cron":       schedule.Cron,
+		"timezone":   schedule.Timezone,
+		"enabled":    schedule.Enabled,
+		"fire_times": runs,
+	})
+}
@@ -1,8 +1,12 @@
 package api
 
 import (
+	"context"
+	"errors"
+	"net"
 	"net/http"
 	"strconv"
+	"time"
 
 	"flowctl/internal/core"
 
@@ -11,14 +15,21 @@ import (
 )
 
 type Server struct {
-	scheduler *core.Scheduler
-	logger    *logrus.Logger
-	router    *gin.Engine
+	scheduler      *core.Scheduler
+	logger         *logrus.Logger
+	router         *gin.Engine
+	authorizer     Authorizer
+	rateLimiter    *RateLimiter
+	trustedProxies []*net.IPNet
+	adminToken     string
 }
 
 func NewServer(scheduler *core.Scheduler, logger *logrus.Logger) *Server {
 	router := gin.New()
 	router.Use(gin.Logger(), gin.Recovery())
+	// gin trusts X-Forwarded-For from anyone by default; only proxies set
+	// with SetTrustedProxies are.
+	router.SetTrustedProxies(nil)
 
 	server := &Server{
 		scheduler: scheduler,
@@ -27,22 +38,118 @@ func NewServer(scheduler *core.Scheduler, logger *logrus.Logger) *Server {
 	}
 
 	server.setupRoutes()
+	server.checkOpenAPICoverage()
 	return server
 }
 
 func (s *Server) setupRoutes() {
 	api := s.router.Group("/api/v1")
-	
+	api.Use(s.rateLimit, s.authorize)
+
 	api.POST("/workflows", s.createWorkflow)
+	api.POST("/workflows/bulk", s.createWorkflowsBulk)
 	api.GET("/workflows/:id", s.getWorkflow)
 	api.PUT("/workflows/:id/cancel", s.cancelWorkflow)
+	api.POST("/workflows/:id/rerun", s.rerunWorkflow)
+	api.DELETE("/workflows/:id", s.deleteWorkflow)
 	api.GET("/workflows", s.listWorkflows)
-	
+	api.GET("/submissions/:id", s.getSubmission)
+
 	api.GET("/tasks/:id", s.getTask)
+	api.GET("/tasks/:id/why", s.explainTask)
+	api.GET("/tasks/:id/output", s.streamTaskOutput)
+	api.POST("/tasks/:id/status", s.updateTaskStatus)
+	api.POST("/tasks/:id/retry", s.retryTask)
+	api.GET("/tasks/:id/impact", s.getTaskImpact)
+	api.POST("/tasks/:id/cancel", s.cancelTask)
+	api.POST("/tasks/:id/skip", s.skipTask)
 	api.GET("/workflows/:id/tasks", s.getWorkflowTasks)
-	
+	api.GET("/workflows/:id/usage", s.getWorkflowUsage)
+	api.GET("/workflows/:id/events", s.streamWorkflowEvents)
+
 	api.GET("/health", s.healthCheck)
 	api.GET("/metrics", s.getMetrics)
+	api.GET("/overview", s.getOverview)
+	api.GET("/leader", s.getLeader)
+	api.GET("/workers", s.listWorkers)
+	api.GET("/workers/summary", s.getWorkerSummary)
+	api.GET("/workers/:id", s.getWorker)
+
+	api.POST("/schedules", s.createSchedule)
+	api.GET("/schedules", s.listSchedules)
+	api.POST("/schedules/preview", s.previewSchedule)
+	api.GET("/schedules/:id", s.getSchedule)
+	api.GET("/schedules/:id/preview", s.previewSavedSchedule)
+	api.PUT("/schedules/:id", s.updateSchedule)
+	api.DELETE("/schedules/:id", s.deleteSchedule)
+
+	api.POST("/templates", s.createWorkflowTemplate)
+	api.GET("/templates", s.listWorkflowTemplates)
+	api.GET("/templates/:name", s.getWorkflowTemplate)
+	api.GET("/templates/:name/versions", s.listWorkflowTemplateVersions)
+	api.PUT("/templates/:name", s.updateWorkflowTemplate)
+	api.DELETE("/templates/:name", s.deleteWorkflowTemplate)
+	api.POST("/templates/:name/run", s.runWorkflowTemplate)
+
+	api.GET("/events", s.listEvents)
+
+	api.GET("/redaction-rules", s.listRedactionRules)
+	api.PUT("/redaction-rules/:type", s.setRedactionRule)
+	api.DELETE("/redaction-rules/:type", s.deleteRedactionRule)
+
+	api.POST("/webhooks", s.createWebhook)
+	api.GET("/webhooks", s.listWebhooks)
+	api.GET("/webhooks/:id", s.getWebhook)
+	api.DELETE("/webhooks/:id", s.deleteWebhook)
+	api.GET("/webhooks/:id/deliveries", s.getWebhookDeliveries)
+
+	api.GET("/slos", s.listLatencySLOs)
+	api.PUT("/slos/:type", s.setLatencySLO)
+	api.DELETE("/slos/:type", s.deleteLatencySLO)
+	api.GET("/queues/:type/latency", s.getQueueLatency)
+	api.GET("/queues/:type/scaling", s.getQueueScalingSignal)
+	api.GET("/scaling", s.listQueueScalingSignals)
+
+	api.GET("/dead-letters/:type", s.getDeadLetterStats)
+	api.GET("/dead-letters/:type/entries/:task_id", s.getDeadLetterEntry)
+	api.PUT("/dead-letters/:type/policy", s.setDeadLetterPolicy)
+	api.DELETE("/dead-letters/:type/policy", s.deleteDeadLetterPolicy)
+
+	api.GET("/retries/:type/policy", s.getRetryOverloadPolicy)
+	api.PUT("/retries/:type/policy", s.setRetryOverloadPolicy)
+	api.DELETE("/retries/:type/policy", s.deleteRetryOverloadPolicy)
+
+	api.GET("/queues/:type/fairness", s.getDispatchFairnessPolicy)
+	api.PUT("/queues/:type/fairness", s.setDispatchFairnessPolicy)
+	api.DELETE("/queues/:type/fairness", s.deleteDispatchFairnessPolicy)
+	api.GET("/queues/:type/rate-limit", s.getTaskRateLimit)
+	api.PUT("/queues/:type/rate-limit", s.setTaskRateLimit)
+	api.DELETE("/queues/:type/rate-limit", s.deleteTaskRateLimit)
+	api.GET("/queues/:type/max-length", s.getQueueLengthLimit)
+	api.PUT("/queues/:type/max-length", s.setQueueLengthLimit)
+	api.DELETE("/queues/:type/max-length", s.deleteQueueLengthLimit)
+
+	admin := api.Group("/admin")
+	admin.GET("/scheduler/dry-run", s.requireAdmin, s.dryRunSchedule)
+	admin.GET("/scheduler/decision-log", s.requireAdmin, s.getDecisionLogConfig)
+	admin.PUT("/scheduler/decision-log", s.requireAdmin, s.setDecisionLogConfig)
+	admin.DELETE("/scheduler/decision-log", s.requireAdmin, s.deleteDecisionLogConfig)
+	admin.GET("/scheduler/decisions", s.requireAdmin, s.listSchedulingPasses)
+	admin.POST("/queues/:type/pause", s.requireAdmin, s.pauseQueue)
+	admin.POST("/queues/:type/resume", s.requireAdmin, s.resumeQueue)
+	admin.GET("/queues/:type/peek", s.requireAdmin, s.peekQueue)
+	admin.POST("/queues/:type/inject", s.requireAdmin, s.injectTask)
+	admin.GET("/injected-tasks/:id", s.requireAdmin, s.getInjectedTask)
+	admin.GET("/redis/memory", s.getRedisMemory)
+	admin.GET("/namespaces/:namespace/sandbox-policy", s.requireAdmin, s.getSandboxPolicy)
+	admin.PUT("/namespaces/:namespace/sandbox-policy", s.requireAdmin, s.setSandboxPolicy)
+	admin.DELETE("/namespaces/:namespace/sandbox-policy", s.requireAdmin, s.deleteSandboxPolicy)
+
+	api.GET("/openapi.json", s.getOpenAPISpec)
+	api.GET("/docs", s.getSwaggerUI)
+
+	s.router.GET("/metrics", gin.WrapH(s.scheduler.MetricsHandler()))
+	s.router.GET("/readyz", s.readiness)
 
 	s.router.Static("/static", "./web/dashboard/build/static")
 	s.router.StaticFile("/", "./web/dashboard/build/index.html")
@@ -52,61 +159,148 @@ func (s *Server) setupRoutes() {
 }
 
 type CreateWorkflowRequest struct {
-	Name        string                   `json:"name" binding:"required"`
-	Description string                   `json:"description"`
-	Tasks       []CreateTaskRequest      `json:"tasks" binding:"required"`
-	Config      *core.WorkflowConfig     `json:"config,omitempty"`
+	ID          string                    `json:"id,omitempty"`
+	Name        string                    `json:"name" binding:"required"`
+	Description string                    `json:"description"`
+	Namespace   string                    `json:"namespace,omitempty"`
+	Priority    int                       `json:"priority,omitempty"`
+	Pool        string                    `json:"pool,omitempty"`
+	Owner       string                    `json:"owner,omitempty"`
+	DocsURL     string                    `json:"docs_url,omitempty"`
+	RunbookURL  string                    `json:"runbook_url,omitempty"`
+	Tasks       []CreateTaskRequest       `json:"tasks" binding:"required"`
+	Config      *core.WorkflowConfig      `json:"config,omitempty"`
+	Parameters  map[string]core.ParamSpec `json:"parameters,omitempty"`
+	Params      map[string]interface{}    `json:"params,omitempty"`
+	Labels      map[string]string         `json:"labels,omitempty"`
 }
 
 type CreateTaskRequest struct {
-	Name         string                 `json:"name" binding:"required"`
-	Type         string                 `json:"type" binding:"required"`
-	Payload      map[string]interface{} `json:"payload"`
-	MaxRetries   int                    `json:"max_retries,omitempty"`
-	Priority     int                    `json:"priority,omitempty"`
-	Dependencies []string               `json:"dependencies,omitempty"`
+	ID                   string                 `json:"id,omitempty"`
+	Name                 string                 `json:"name" binding:"required"`
+	Type                 string                 `json:"type" binding:"required"`
+	Payload              map[string]interface{} `json:"payload"`
+	MaxRetries           int                    `json:"max_retries,omitempty"`
+	Priority             int                    `json:"priority,omitempty"`
+	Dependencies         []string               `json:"dependencies,omitempty"`
+	Timeout              time.Duration          `json:"timeout,omitempty"`
+	RunAt                *time.Time             `json:"run_at,omitempty"`
+	ExpiresAt            *time.Time             `json:"expires_at,omitempty"`
+	RunOnUpstreamFailure bool                   `json:"run_on_upstream_failure,omitempty"`
+	Executor             core.Executor          `json:"executor,omitempty"`
+	Resources            *core.ResourceRequest  `json:"resources,omitempty"`
+	Selector             map[string]string      `json:"selector,omitempty"`
+	IdempotencyKey       string                 `json:"idempotency_key,omitempty"`
+	Dedupe               *core.DedupeConfig     `json:"dedupe,omitempty"`
+	Owner                string                 `json:"owner,omitempty"`
+	DocsURL              string                 `json:"docs_url,omitempty"`
+	RunbookURL           string                 `json:"runbook_url,omitempty"`
+	Group                string                 `json:"group,omitempty"`
 }
 
-func (s *Server) createWorkflow(c *gin.Context) {
-	var req CreateWorkflowRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+func (r *CreateWorkflowRequest) toDefinition() *core.WorkflowDefinition {
+	definition := &core.WorkflowDefinition{
+		ID:          r.ID,
+		Name:        r.Name,
+		Description: r.Description,
+		Namespace:   r.Namespace,
+		Priority:    r.Priority,
+		Pool:        r.Pool,
+		Owner:       r.Owner,
+		DocsURL:     r.DocsURL,
+		RunbookURL:  r.RunbookURL,
+		Config:      r.Config,
+		Parameters:  r.Parameters,
+		Params:      r.Params,
+		Labels:      r.Labels,
 	}
 
-	workflow := core.NewWorkflow(req.Name, req.Description)
-	if req.Config != nil {
-		workflow.Config = *req.Config
+	for _, taskReq := range r.Tasks {
+		definition.Tasks = append(definition.Tasks, core.TaskDefinition{
+			ID:                   taskReq.ID,
+			Name:                 taskReq.Name,
+			Type:                 taskReq.Type,
+			Payload:              taskReq.Payload,
+			MaxRetries:           taskReq.MaxRetries,
+			Priority:             taskReq.Priority,
+			Dependencies:         taskReq.Dependencies,
+			Timeout:              taskReq.Timeout,
+			RunAt:                taskReq.RunAt,
+			ExpiresAt:            taskReq.ExpiresAt,
+			RunOnUpstreamFailure: taskReq.RunOnUpstreamFailure,
+			Executor:             taskReq.Executor,
+			Resources:            taskReq.Resources,
+			Selector:             taskReq.Selector,
+			IdempotencyKey:       taskReq.IdempotencyKey,
+			Dedupe:               taskReq.Dedupe,
+			Owner:                taskReq.Owner,
+			DocsURL:              taskReq.DocsURL,
+			RunbookURL:           taskReq.RunbookURL,
+			Group:                taskReq.Group,
+		})
 	}
 
-	for _, taskReq := range req.Tasks {
-		task := core.NewTask(workflow.ID, taskReq.Name, taskReq.Type, taskReq.Payload)
-		
-		if taskReq.MaxRetries > 0 {
-			task.MaxRetries = taskReq.MaxRetries
+	return definition
+}
+
+func (s *Server) createWorkflow(c *gin.Context) {
+	var workflow *core.Workflow
+	if isYAMLRequest(c) {
+		var err error
+		workflow, err = parseWorkflowYAML(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
-		if taskReq.Priority > 0 {
-			task.Priority = taskReq.Priority
+	} else {
+		var req CreateWorkflowRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
-		if taskReq.Dependencies != nil {
-			task.Dependencies = taskReq.Dependencies
+
+		definition := req.toDefinition()
+		if err := definition.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var err error
+		workflow, err = definition.NewWorkflow()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
-		
-		workflow.Tasks = append(workflow.Tasks, *task)
+	}
+
+	if async, _ := strconv.ParseBool(c.Query("async")); async {
+		s.createWorkflowAsync(c, workflow)
+		return
 	}
 
 	if err := s.scheduler.SubmitWorkflow(c.Request.Context(), workflow); err != nil {
+		if errors.Is(err, core.ErrDuplicateID) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, core.ErrSandboxViolation) || errors.Is(err, core.ErrAdmissionDenied) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if s.respondBackpressure(c, err) {
+			return
+		}
 		s.logger.Errorf("Failed to submit workflow: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create workflow"})
 		return
 	}
 
-	c.JSON(http.StatusCreated, workflow)
+	c.JSON(http.StatusCreated, s.redactWorkflow(c, workflow))
 }
 
 func (s *Server) getWorkflow(c *gin.Context) {
 	workflowID := c.Param("id")
-	
+
 	workflow, err := s.scheduler.GetWorkflow(workflowID)
 	if err != nil {
 		s.logger.Errorf("Failed to get workflow %s: %v", workflowID, err)
@@ -114,12 +308,36 @@ func (s *Server) getWorkflow(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, workflow)
+	if asOf := c.Query("as_of"); asOf != "" {
+		s.getWorkflowAsOf(c, workflow, asOf)
+		return
+	}
+
+	c.JSON(http.StatusOK, s.redactWorkflow(c, workflow))
+}
+
+func (s *Server) deleteWorkflow(c *gin.Context) {
+	workflowID := c.Param("id")
+
+	if _, err := s.scheduler.GetWorkflow(workflowID); err != nil {
+		s.logger.Errorf("Failed to get workflow %s: %v", workflowID, err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Workflow not found"})
+		return
+	}
+
+	deletion, err := s.scheduler.DeleteWorkflow(c.Request.Context(), workflowID)
+	if err != nil {
+		s.logger.Errorf("Failed to delete workflow %s: %v", workflowID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete workflow"})
+		return
+	}
+
+	c.JSON(http.StatusOK, deletion)
 }
 
 func (s *Server) cancelWorkflow(c *gin.Context) {
 	workflowID := c.Param("id")
-	
+
 	if err := s.scheduler.CancelWorkflow(c.Request.Context(), workflowID); err != nil {
 		s.logger.Errorf("Failed to cancel workflow %s: %v", workflowID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel workflow"})
@@ -130,25 +348,71 @@ func (s *Server) cancelWorkflow(c *gin.Context) {
 }
 
 func (s *Server) listWorkflows(c *gin.Context) {
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
-	status := c.Query("status")
-	
-	_ = page
-	_ = limit
-	_ = status
-	
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "page must be a positive integer"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 || limit > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 100"})
+		return
+	}
+
+	filter := core.WorkflowFilter{
+		Status: core.WorkflowStatus(c.Query("status")),
+		Name:   c.Query("name"),
+		Page:   page,
+		Limit:  limit,
+	}
+
+	var ok bool
+	if filter.CreatedAfter, ok = queryTime(c, "created_after"); !ok {
+		return
+	}
+	if filter.CreatedBefore, ok = queryTime(c, "created_before"); !ok {
+		return
+	}
+
+	workflows, total, err := s.scheduler.ListWorkflows(filter)
+	if err != nil {
+		s.logger.Errorf("Failed to list workflows: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list workflows"})
+		return
+	}
+
+	for i := range workflows {
+		workflows[i] = *s.redactWorkflow(c, &workflows[i])
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"workflows": []core.Workflow{},
-		"total":     0,
+		"workflows": workflows,
+		"total":     total,
 		"page":      page,
 		"limit":     limit,
 	})
 }
 
+// queryTime parses an optional RFC 3339 query parameter, responding with
+// 400 and returning false if it is malformed.
+func queryTime(c *gin.Context, param string) (*time.Time, bool) {
+	value := c.Query(param)
+	if value == "" {
+		return nil, true
+	}
+
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": param + " must be an RFC 3339 timestamp"})
+		return nil, false
+	}
+	return &parsed, true
+}
+
 func (s *Server) getTask(c *gin.Context) {
 	taskID := c.Param("id")
-	
+
 	task, err := s.scheduler.GetTask(taskID)
 	if err != nil {
 		s.logger.Errorf("Failed to get task %s: %v", taskID, err)
@@ -156,12 +420,125 @@ func (s *Server) getTask(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, task)
+	c.JSON(http.StatusOK, s.redactTask(c, *task))
+}
+
+type TaskStatusRequest struct {
+	Status core.TaskStatus        `json:"status" binding:"required"`
+	Result map[string]interface{} `json:"result"`
+	Error  string                 `json:"error"`
+	Usage  *core.ResourceUsage    `json:"usage,omitempty"`
+	// Attempt is the attempt, counted from 1, the report is on. Reports on
+	// an attempt older than the task's latest are rejected.
+	Attempt int `json:"attempt,omitempty"`
+	// Environment is reported with the running status of each attempt.
+	Environment *core.ExecutionEnvironment `json:"environment,omitempty"`
+}
+
+func (s *Server) updateTaskStatus(c *gin.Context) {
+	taskID := c.Param("id")
+
+	var req TaskStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Only the scheduler expires tasks, from their expires_at, so a worker
+	// cannot report one expired.
+	switch req.Status {
+	case core.TaskStatusRunning, core.TaskStatusCompleted, core.TaskStatusFailed, core.TaskStatusRetrying, core.TaskStatusCancelled:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid task status"})
+		return
+	}
+
+	if err := s.scheduler.ReportTaskStatus(c.Request.Context(), taskID, req.Attempt, req.Status, req.Result, req.Error); err != nil {
+		if errors.Is(err, core.ErrTaskNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+			return
+		}
+		if errors.Is(err, core.ErrStaleTaskStatus) || errors.Is(err, core.ErrTaskFinished) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		s.logger.Errorf("Failed to update task %s status: %v", taskID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update task status"})
+		return
+	}
+
+	if req.Environment != nil {
+		if err := s.scheduler.RecordTaskAttempt(taskID, *req.Environment); err != nil {
+			s.logger.Errorf("Failed to record attempt of task %s: %v", taskID, err)
+		}
+	}
+
+	if req.Usage != nil {
+		if err := s.scheduler.RecordTaskUsage(taskID, *req.Usage); err != nil {
+			s.logger.Errorf("Failed to record usage of task %s: %v", taskID, err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Task status updated"})
+}
+
+type RetryTaskRequest struct {
+	ResetRetries bool `json:"reset_retries"`
+}
+
+func (s *Server) retryTask(c *gin.Context) {
+	taskID := c.Param("id")
+
+	var req RetryTaskRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if _, err := s.scheduler.GetTask(taskID); err != nil {
+		s.logger.Errorf("Failed to get task %s: %v", taskID, err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+
+	task, err := s.scheduler.RetryTask(c.Request.Context(), taskID, req.ResetRetries)
+	if errors.Is(err, core.ErrTaskNotRetryable) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		s.logger.Errorf("Failed to retry task %s: %v", taskID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retry task"})
+		return
+	}
+
+	c.JSON(http.StatusOK, s.redactTask(c, *task))
+}
+
+func (s *Server) explainTask(c *gin.Context) {
+	taskID := c.Param("id")
+
+	if _, err := s.scheduler.GetTask(taskID); err != nil {
+		s.logger.Errorf("Failed to get task %s: %v", taskID, err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+
+	explanation, err := s.scheduler.ExplainTask(c.Request.Context(), taskID)
+	if err != nil {
+		s.logger.Errorf("Failed to explain task %s: %v", taskID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to explain task"})
+		return
+	}
+
+	c.JSON(http.StatusOK, explanation)
 }
 
 func (s *Server) getWorkflowTasks(c *gin.Context) {
 	workflowID := c.Param("id")
-	
+
 	tasks, err := s.scheduler.GetWorkflowTasks(workflowID)
 	if err != nil {
 		s.logger.Errorf("Failed to get tasks for workflow %s: %v", workflowID, err)
@@ -169,7 +546,7 @@ func (s *Server) getWorkflowTasks(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"tasks": tasks})
+	c.JSON(http.StatusOK, gin.H{"tasks": s.redactTasks(c, tasks)})
 }
 
 func (s *Server) healthCheck(c *gin.Context) {
@@ -179,26 +556,79 @@ func (s *Server) healthCheck(c *gin.Context) {
 	})
 }
 
+// readinessTimeout bounds the pings behind /readyz, so a probe gets an
+// answer before its own timeout.
+const readinessTimeout = time.Second * 2
+
+// readiness answers 503 while Redis or Postgres cannot be reached, so a
+// load balancer stops routing to this scheduler until they recover.
+func (s *Server) readiness(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+	defer cancel()
+
+	readiness := s.scheduler.CheckReadiness(ctx)
+	if !readiness.Ready {
+		c.JSON(http.StatusServiceUnavailable, readiness)
+		return
+	}
+	c.JSON(http.StatusOK, readiness)
+}
+
+func (s *Server) getLeader(c *gin.Context) {
+	status, err := s.scheduler.GetLeaderStatus(c.Request.Context())
+	if err != nil {
+		s.logger.Errorf("Failed to get leader status: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get leader status"})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
 func (s *Server) getMetrics(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"workflows": gin.H{
-			"total":     0,
-			"running":   0,
-			"completed": 0,
-			"failed":    0,
-		},
-		"tasks": gin.H{
-			"total":     0,
-			"pending":   0,
-			"running":   0,
-			"completed": 0,
-			"failed":    0,
-		},
-		"workers": gin.H{
-			"active": 0,
-			"idle":   0,
-		},
-	})
+	metrics, err := s.scheduler.GetMetrics(c.Request.Context())
+	if err != nil {
+		s.logger.Errorf("Failed to get metrics: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get metrics"})
+		return
+	}
+
+	c.JSON(http.StatusOK, metrics)
+}
+
+func (s *Server) dryRunSchedule(c *gin.Context) {
+	report, err := s.scheduler.DryRun(c.Request.Context())
+	if err != nil {
+		s.logger.Errorf("Failed to run scheduler dry run: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to run scheduler dry run"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+func (s *Server) pauseQueue(c *gin.Context) {
+	taskType := c.Param("type")
+
+	if err := s.scheduler.PauseQueue(c.Request.Context(), taskType); err != nil {
+		s.logger.Errorf("Failed to pause queue %s: %v", taskType, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to pause queue"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Queue paused"})
+}
+
+func (s *Server) resumeQueue(c *gin.Context) {
+	taskType := c.Param("type")
+
+	if err := s.scheduler.ResumeQueue(c.Request.Context(), taskType); err != nil {
+		s.logger.Errorf("Failed to resume queue %s: %v", taskType, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resume queue"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Queue resumed"})
 }
 
 func (s *Server) Start(addr string) error {
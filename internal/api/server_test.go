@@ -0,0 +1,40 @@
+package api
+
+import (
+	"bytes"
+	"go/format"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// Most API changes add a route to server.go, so keep it formatted as gofmt
+// would.
+func TestServerIsGofmted(t *testing.T) {
+	source, err := os.ReadFile("server.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	formatted, err := format.Source(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(source, formatted) {
+		t.Error("server.go is not gofmt-clean; run gofmt -w internal/api/server.go")
+	}
+}
+
+func TestTaskStatusRefusesExpired(t *testing.T) {
+	s := newTestServer()
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks/task-1/status", strings.NewReader(`{"status": "expired"}`))
+	req.Header.Set("Content-Type", "application/json")
+	s.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("reporting a task expired answered %d, want 400", w.Code)
+	}
+}
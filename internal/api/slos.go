@@ -0,0 +1,98 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"flowctl/internal/core"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LatencySLORequest struct {
+	Threshold string  `json:"threshold" binding:"required"`
+	Objective float64 `json:"objective" binding:"required"`
+}
+
+func (s *Server) listLatencySLOs(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	slos, err := s.scheduler.GetLatencySLOs(ctx)
+	if err != nil {
+		s.logger.Errorf("Failed to list latency SLOs: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list latency SLOs"})
+		return
+	}
+
+	stats := make([]*core.QueueLatencyStats, 0, len(slos))
+	for _, slo := range slos {
+		taskStats, err := s.scheduler.GetQueueLatencyStats(ctx, slo.TaskType)
+		if err != nil {
+			s.logger.Errorf("Failed to get queue latency for %s: %v", slo.TaskType, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get queue latency"})
+			return
+		}
+		stats = append(stats, taskStats)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"slos": stats})
+}
+
+func (s *Server) setLatencySLO(c *gin.Context) {
+	taskType := c.Param("type")
+
+	var req LatencySLORequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	threshold, err := time.ParseDuration(req.Threshold)
+	if err != nil || threshold <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "threshold must be a positive duration"})
+		return
+	}
+	if req.Objective <= 0 || req.Objective >= 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "objective must be between 0 and 1"})
+		return
+	}
+
+	slo := core.LatencySLO{
+		TaskType:  taskType,
+		Threshold: threshold,
+		Objective: req.Objective,
+	}
+
+	if err := s.scheduler.SetLatencySLO(c.Request.Context(), slo); err != nil {
+		s.logger.Errorf("Failed to set latency SLO for %s: %v", taskType, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set latency SLO"})
+		return
+	}
+
+	c.JSON(http.StatusOK, slo)
+}
+
+func (s *Server) deleteLatencySLO(c *gin.Context) {
+	taskType := c.Param("type")
+
+	if err := s.scheduler.DeleteLatencySLO(c.Request.Context(), taskType); err != nil {
+		s.logger.Errorf("Failed to delete latency SLO for %s: %v", taskType, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete latency SLO"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Latency SLO deleted"})
+}
+
+func (s *Server) getQueueLatency(c *gin.Context) {
+	taskType := c.Param("type")
+
+	stats, err := s.scheduler.GetQueueLatencyStats(c.Request.Context(), taskType)
+	if err != nil {
+		s.logger.Errorf("Failed to get queue latency for %s: %v", taskType, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get queue latency"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"flowctl/internal/core"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) createWorkflowAsync(c *gin.Context, workflow *core.Workflow) {
+	submission, err := s.scheduler.SubmitWorkflowAsync(c.Request.Context(), workflow)
+	if err != nil {
+		if errors.Is(err, core.ErrDuplicateID) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, core.ErrSandboxViolation) || errors.Is(err, core.ErrAdmissionDenied) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if s.respondBackpressure(c, err) {
+			return
+		}
+		s.logger.Errorf("Failed to submit workflow asynchronously: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create workflow"})
+		return
+	}
+
+	c.Header("Location", fmt.Sprintf("/api/v1/submissions/%s", submission.ID))
+	c.JSON(http.StatusAccepted, submission)
+}
+
+func (s *Server) getSubmission(c *gin.Context) {
+	submissionID := c.Param("id")
+
+	submission, err := s.scheduler.GetSubmission(c.Request.Context(), submissionID)
+	if err != nil {
+		s.logger.Errorf("Failed to get submission %s: %v", submissionID, err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Submission not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, submission)
+}
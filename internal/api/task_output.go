@@ -0,0 +1,101 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"flowctl/internal/core"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamTaskOutput pushes the stdout and stderr of one attempt of a task to
+// the client as server-sent events, the latest attempt unless the attempt
+// query parameter names another. Output already written is sent first; a
+// client reconnecting with Last-Event-ID resumes after that chunk. Chunks
+// are read from Redis only as fast as the client takes them. The stream
+// ends with the attempt's final chunk, or once the attempt is over if it
+// never wrote one.
+func (s *Server) streamTaskOutput(c *gin.Context) {
+	taskID := c.Param("id")
+	ctx := c.Request.Context()
+
+	task, err := s.scheduler.GetTask(taskID)
+	if err != nil {
+		s.logger.Errorf("Failed to get task %s: %v", taskID, err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+
+	if !task.StreamsOutput() {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("task %s does not stream output; only script tasks and tasks with a shell, docker or k8s executor do", taskID)})
+		return
+	}
+
+	attempt := task.RetryCount + 1
+	if value := c.Query("attempt"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > attempt {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("attempt must be between 1 and %d", attempt)})
+			return
+		}
+		attempt = parsed
+	}
+
+	after := c.GetHeader("Last-Event-ID")
+	if after == "" {
+		after = c.DefaultQuery("after", "0")
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	block := streamKeepAlive
+	for {
+		chunks, err := s.scheduler.ReadTaskOutput(ctx, taskID, attempt, after, 100, block)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			s.logger.Errorf("Failed to read output of task %s: %v", taskID, err)
+			return
+		}
+
+		for _, chunk := range chunks {
+			after = chunk.ID
+			if err := writeServerSentEvent(c, chunk.ID, string(core.EventTaskOutput), chunk); err != nil {
+				return
+			}
+			if chunk.EOF {
+				return
+			}
+		}
+		if len(chunks) > 0 {
+			continue
+		}
+
+		// An attempt whose worker died never writes its final chunk.
+		// Once it is over, drain what it did write and stop.
+		if block == 0 {
+			return
+		}
+		task, err := s.scheduler.GetTask(taskID)
+		if err != nil {
+			s.logger.Errorf("Failed to get task %s: %v", taskID, err)
+			return
+		}
+		if task.Status.IsTerminal() || task.RetryCount+1 > attempt {
+			block = 0
+			continue
+		}
+
+		if _, err := fmt.Fprint(c.Writer, ": keep-alive\n\n"); err != nil {
+			return
+		}
+		c.Writer.Flush()
+	}
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"flowctl/internal/core"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StopTaskRequest confirms cancelling or skipping a task whose downstream
+// tasks would be skipped as a result.
+type StopTaskRequest struct {
+	Confirm bool `json:"confirm"`
+}
+
+// StopTaskResponse is the stopped task and what stopping it did to the rest
+// of its workflow.
+type StopTaskResponse struct {
+	Task   core.Task             `json:"task"`
+	Impact core.DownstreamImpact `json:"impact"`
+}
+
+// impactResponse is returned with 409 when a stop that would skip
+// downstream tasks was not confirmed.
+type impactResponse struct {
+	Error  string                `json:"error"`
+	Impact core.DownstreamImpact `json:"impact"`
+}
+
+func (s *Server) getTaskImpact(c *gin.Context) {
+	taskID := c.Param("id")
+
+	if _, err := s.scheduler.GetTask(taskID); err != nil {
+		s.logger.Errorf("Failed to get task %s: %v", taskID, err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+
+	impact, err := s.scheduler.TaskImpact(taskID)
+	if err != nil {
+		s.logger.Errorf("Failed to work out impact of task %s: %v", taskID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get task impact"})
+		return
+	}
+
+	c.JSON(http.StatusOK, impact)
+}
+
+func (s *Server) cancelTask(c *gin.Context) {
+	s.stopTask(c, s.scheduler.CancelTask)
+}
+
+func (s *Server) skipTask(c *gin.Context) {
+	s.stopTask(c, s.scheduler.SkipTask)
+}
+
+// stopTask cancels or skips a task. Unless the request confirms it, a stop
+// that would skip downstream tasks is refused with 409 and the list of
+// those tasks, so the caller sees the blast radius before acting.
+func (s *Server) stopTask(c *gin.Context, stop func(ctx context.Context, taskID string) (*core.DownstreamImpact, error)) {
+	taskID := c.Param("id")
+
+	var req StopTaskRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if _, err := s.scheduler.GetTask(taskID); err != nil {
+		s.logger.Errorf("Failed to get task %s: %v", taskID, err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+
+	if !req.Confirm {
+		impact, err := s.scheduler.TaskImpact(taskID)
+		if err != nil {
+			s.logger.Errorf("Failed to work out impact of task %s: %v", taskID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get task impact"})
+			return
+		}
+		if len(impact.Skipped) > 0 {
+			c.JSON(http.StatusConflict, impactResponse{
+				Error:  fmt.Sprintf("%d downstream tasks would be skipped; set confirm to proceed", len(impact.Skipped)),
+				Impact: *impact,
+			})
+			return
+		}
+	}
+
+	impact, err := stop(c.Request.Context(), taskID)
+	if errors.Is(err, core.ErrTaskNotStoppable) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		s.logger.Errorf("Failed to stop task %s: %v", taskID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to stop task"})
+		return
+	}
+
+	task, err := s.scheduler.GetTask(taskID)
+	if err != nil {
+		s.logger.Errorf("Failed to get task %s: %v", taskID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get task"})
+		return
+	}
+
+	c.JSON(http.StatusOK, StopTaskResponse{Task: s.redactTask(c, *task), Impact: *impact})
+}
@@ -0,0 +1,203 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"flowctl/internal/core"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WorkflowTemplateRequest struct {
+	Name        string                `json:"name,omitempty"`
+	Description string                `json:"description"`
+	Workflow    CreateWorkflowRequest `json:"workflow" binding:"required"`
+}
+
+type RunWorkflowTemplateRequest struct {
+	Version int                    `json:"version,omitempty"`
+	Params  map[string]interface{} `json:"params,omitempty"`
+}
+
+func (s *Server) createWorkflowTemplate(c *gin.Context) {
+	var req WorkflowTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tmpl := core.NewWorkflowTemplate(req.Name, req.Description, *req.Workflow.toDefinition())
+	if err := tmpl.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.scheduler.CreateWorkflowTemplate(c.Request.Context(), tmpl); err != nil {
+		if errors.Is(err, core.ErrTemplateExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		s.logger.Errorf("Failed to create workflow template: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create workflow template"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, s.redactWorkflowTemplate(c, tmpl))
+}
+
+func (s *Server) listWorkflowTemplates(c *gin.Context) {
+	templates, err := s.scheduler.ListWorkflowTemplates()
+	if err != nil {
+		s.logger.Errorf("Failed to list workflow templates: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list workflow templates"})
+		return
+	}
+
+	for i := range templates {
+		templates[i] = *s.redactWorkflowTemplate(c, &templates[i])
+	}
+
+	c.JSON(http.StatusOK, gin.H{"templates": templates})
+}
+
+func (s *Server) getWorkflowTemplate(c *gin.Context) {
+	name := c.Param("name")
+
+	version := 0
+	if value := c.Query("version"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid version"})
+			return
+		}
+		version = parsed
+	}
+
+	tmpl, err := s.scheduler.GetWorkflowTemplate(name, version)
+	if err != nil {
+		s.logger.Errorf("Failed to get workflow template %s: %v", name, err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Workflow template not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, s.redactWorkflowTemplate(c, tmpl))
+}
+
+func (s *Server) listWorkflowTemplateVersions(c *gin.Context) {
+	name := c.Param("name")
+
+	templates, err := s.scheduler.ListWorkflowTemplateVersions(name)
+	if err != nil {
+		s.logger.Errorf("Failed to list versions of workflow template %s: %v", name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list workflow template versions"})
+		return
+	}
+	if len(templates) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Workflow template not found"})
+		return
+	}
+
+	for i := range templates {
+		templates[i] = *s.redactWorkflowTemplate(c, &templates[i])
+	}
+
+	c.JSON(http.StatusOK, gin.H{"versions": templates})
+}
+
+// updateWorkflowTemplate publishes a new version; earlier versions stay
+// available to runs that ask for them.
+func (s *Server) updateWorkflowTemplate(c *gin.Context) {
+	name := c.Param("name")
+
+	var req WorkflowTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := s.scheduler.GetWorkflowTemplate(name, 0); err != nil {
+		s.logger.Errorf("Failed to get workflow template %s: %v", name, err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Workflow template not found"})
+		return
+	}
+
+	tmpl := core.NewWorkflowTemplate(name, req.Description, *req.Workflow.toDefinition())
+	if err := tmpl.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.scheduler.PublishWorkflowTemplate(c.Request.Context(), tmpl); err != nil {
+		s.logger.Errorf("Failed to publish workflow template %s: %v", name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update workflow template"})
+		return
+	}
+
+	c.JSON(http.StatusOK, s.redactWorkflowTemplate(c, tmpl))
+}
+
+func (s *Server) deleteWorkflowTemplate(c *gin.Context) {
+	name := c.Param("name")
+
+	if err := s.scheduler.DeleteWorkflowTemplate(c.Request.Context(), name); err != nil {
+		s.logger.Errorf("Failed to delete workflow template %s: %v", name, err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Workflow template not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Workflow template deleted"})
+}
+
+// runWorkflowTemplate submits a new workflow built from a template version,
+// the latest unless the body names one, with the body's params laid over
+// the template's defaults.
+func (s *Server) runWorkflowTemplate(c *gin.Context) {
+	name := c.Param("name")
+
+	var req RunWorkflowTemplateRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.Version < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid version"})
+		return
+	}
+
+	tmpl, err := s.scheduler.GetWorkflowTemplate(name, req.Version)
+	if err != nil {
+		s.logger.Errorf("Failed to get workflow template %s: %v", name, err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Workflow template not found"})
+		return
+	}
+
+	workflow, err := tmpl.Instantiate(req.Params)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.scheduler.SubmitWorkflow(c.Request.Context(), workflow); err != nil {
+		if errors.Is(err, core.ErrDuplicateID) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, core.ErrSandboxViolation) || errors.Is(err, core.ErrAdmissionDenied) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if s.respondBackpressure(c, err) {
+			return
+		}
+		s.logger.Errorf("Failed to submit workflow from template %s: %v", name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create workflow"})
+		return
+	}
+
+	s.logger.Infof("Template %s version %d started workflow %s", tmpl.Name, tmpl.Version, workflow.ID)
+	c.JSON(http.StatusCreated, s.redactWorkflow(c, workflow))
+}
@@ -0,0 +1,26 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) getWorkflowUsage(c *gin.Context) {
+	workflowID := c.Param("id")
+
+	if _, err := s.scheduler.GetWorkflow(workflowID); err != nil {
+		s.logger.Errorf("Failed to get workflow %s: %v", workflowID, err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Workflow not found"})
+		return
+	}
+
+	usage, err := s.scheduler.GetWorkflowUsage(workflowID)
+	if err != nil {
+		s.logger.Errorf("Failed to get usage of workflow %s: %v", workflowID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get workflow usage"})
+		return
+	}
+
+	c.JSON(http.StatusOK, usage)
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"flowctl/internal/core"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WebhookRequest struct {
+	URL        string           `json:"url" binding:"required"`
+	Namespace  string           `json:"namespace,omitempty"`
+	WorkflowID string           `json:"workflow_id,omitempty"`
+	Events     []core.EventType `json:"events,omitempty"`
+	Secret     string           `json:"secret,omitempty"`
+}
+
+func (s *Server) createWebhook(c *gin.Context) {
+	var req WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// A webhook on one workflow is in that workflow's namespace.
+	if req.WorkflowID != "" {
+		namespace, found, err := s.scheduler.WorkflowNamespace(req.WorkflowID)
+		if err != nil {
+			s.logger.Errorf("Failed to get namespace of workflow %s: %v", req.WorkflowID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+			return
+		}
+		if !found {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Workflow not found"})
+			return
+		}
+		if req.Namespace != "" && req.Namespace != namespace {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Workflow is not in namespace " + req.Namespace})
+			return
+		}
+		req.Namespace = namespace
+	}
+
+	webhook, err := core.NewWebhook(req.URL, req.Namespace, req.WorkflowID, req.Events, req.Secret)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.scheduler.CreateWebhook(c.Request.Context(), webhook); err != nil {
+		if errors.Is(err, core.ErrWebhookDestination) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		s.logger.Errorf("Failed to create webhook: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+
+	// The secret is only returned here, for the receiver to verify
+	// signatures with.
+	c.JSON(http.StatusCreated, webhook)
+}
+
+func (s *Server) listWebhooks(c *gin.Context) {
+	webhooks, err := s.scheduler.ListWebhooks(c.Request.Context())
+	if err != nil {
+		s.logger.Errorf("Failed to list webhooks: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list webhooks"})
+		return
+	}
+
+	workflowID := c.Query("workflow_id")
+	namespace := c.Query("namespace")
+	matching := make([]core.Webhook, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		if workflowID != "" && webhook.WorkflowID != workflowID {
+			continue
+		}
+		if namespace != "" && webhook.Namespace != namespace {
+			continue
+		}
+		webhook.Secret = ""
+		matching = append(matching, webhook)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"webhooks": matching})
+}
+
+func (s *Server) getWebhook(c *gin.Context) {
+	webhookID := c.Param("id")
+
+	webhook, err := s.scheduler.GetWebhook(c.Request.Context(), webhookID)
+	if err != nil {
+		s.logger.Errorf("Failed to get webhook %s: %v", webhookID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get webhook"})
+		return
+	}
+	if webhook == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	webhook.Secret = ""
+	c.JSON(http.StatusOK, webhook)
+}
+
+func (s *Server) deleteWebhook(c *gin.Context) {
+	webhookID := c.Param("id")
+
+	deleted, err := s.scheduler.DeleteWebhook(c.Request.Context(), webhookID)
+	if err != nil {
+		s.logger.Errorf("Failed to delete webhook %s: %v", webhookID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete webhook"})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted"})
+}
+
+func (s *Server) getWebhookDeliveries(c *gin.Context) {
+	webhookID := c.Param("id")
+	ctx := c.Request.Context()
+
+	webhook, err := s.scheduler.GetWebhook(ctx, webhookID)
+	if err != nil {
+		s.logger.Errorf("Failed to get webhook %s: %v", webhookID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get webhook"})
+		return
+	}
+	if webhook == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	attempts, err := s.scheduler.GetWebhookAttempts(ctx, webhookID)
+	if err != nil {
+		s.logger.Errorf("Failed to get deliveries of webhook %s: %v", webhookID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get webhook deliveries"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deliveries": attempts})
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"flowctl/internal/core"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestCreateWebhookRejectsInternalURL(t *testing.T) {
+	s := newTestServer()
+
+	for _, url := range []string{"http://127.0.0.1:6379/", "http://169.254.169.254/latest/meta-data/"} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/webhooks", strings.NewReader(`{"url": "`+url+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		s.router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("registering %s answered %d, want 400: %s", url, w.Code, w.Body)
+		}
+	}
+}
+
+func TestCreateWebhookAuthorizedInWorkflowNamespace(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	store := &namespaceStore{workflows: map[string]string{"wf-1": "team-b"}}
+	s := NewServer(core.NewScheduler(store, nil, logger), logger)
+	authorizer := &namespaceAuthorizer{allow: "team-a"}
+	s.SetAuthorizer(authorizer)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/webhooks",
+		strings.NewReader(`{"url": "https://example.com/hook", "namespace": "team-a", "workflow_id": "wf-1"}`))
+	req.Header.Set("Content-Type", "application/json")
+	s.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("webhook on a team-b workflow answered %d, want 403", w.Code)
+	}
+	if last := authorizer.requests[len(authorizer.requests)-1]; last.Namespace != "team-b" {
+		t.Errorf("webhook authorized in namespace %q, want team-b", last.Namespace)
+	}
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"net/http"
+
+	"flowctl/internal/core"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) listWorkers(c *gin.Context) {
+	taskType := c.Query("type")
+
+	workers, err := s.scheduler.ListWorkers(c.Request.Context())
+	if err != nil {
+		s.logger.Errorf("Failed to list workers: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list workers"})
+		return
+	}
+
+	if taskType != "" {
+		matching := []core.WorkerInfo{}
+		for _, worker := range workers {
+			for _, workerType := range worker.TaskTypes {
+				if workerType == taskType {
+					matching = append(matching, worker)
+					break
+				}
+			}
+		}
+		workers = matching
+	}
+
+	c.JSON(http.StatusOK, gin.H{"workers": workers})
+}
+
+func (s *Server) getWorkerSummary(c *gin.Context) {
+	summary, err := s.scheduler.WorkerSummary(c.Request.Context(), c.Query("pool"))
+	if err != nil {
+		s.logger.Errorf("Failed to summarize workers: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to summarize workers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+func (s *Server) getWorker(c *gin.Context) {
+	workerID := c.Param("id")
+
+	worker, err := s.scheduler.GetWorker(c.Request.Context(), workerID)
+	if err != nil {
+		s.logger.Errorf("Failed to get worker %s: %v", workerID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get worker"})
+		return
+	}
+	if worker == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Worker not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, worker)
+}
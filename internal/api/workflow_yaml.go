@@ -0,0 +1,37 @@
+package api
+
+import (
+	"fmt"
+	"io"
+
+	"flowctl/internal/core"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxWorkflowYAMLSize caps the size of a YAML workflow definition.
+const maxWorkflowYAMLSize = 4 << 20
+
+// isYAMLRequest reports whether the request body is YAML, so a workflow
+// file can be submitted as is.
+func isYAMLRequest(c *gin.Context) bool {
+	switch c.ContentType() {
+	case "application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml":
+		return true
+	}
+	return false
+}
+
+// parseWorkflowYAML builds a workflow from a YAML definition in the request
+// body, the format accepted by ParseWorkflowFromYAMLBytes.
+func parseWorkflowYAML(c *gin.Context) (*core.Workflow, error) {
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWorkflowYAMLSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	if len(data) > maxWorkflowYAMLSize {
+		return nil, fmt.Errorf("workflow definition is larger than %d bytes", maxWorkflowYAMLSize)
+	}
+
+	return core.ParseWorkflowFromYAMLBytes(data)
+}
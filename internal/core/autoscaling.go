@@ -0,0 +1,29 @@
+package core
+
+import "time"
+
+// QueueScalingSignal is the demand on one task type's workers, flat so that
+// external autoscalers such as KEDA's metrics-api scaler can read any field
+// as a metric. Backlog counts tasks ready to run and waiting for a worker,
+// InFlight the tasks workers hold, and OldestTaskAgeSeconds how long the
+// longest-waiting ready task has waited, or zero when none is waiting.
+// Workers counts the live workers of the type and BusyWorkers those holding
+// at least one task. A Paused queue hands out nothing however many workers
+// run, so autoscalers should not scale up for its backlog.
+//
+// UnmatchedBacklog is the part of Backlog whose label selector no live
+// worker's labels match, listed in UnmatchedSelectors. Those tasks wait
+// until a worker with matching labels starts; more workers without them do
+// not drain it.
+type QueueScalingSignal struct {
+	TaskType             string    `json:"task_type"`
+	Backlog              int64     `json:"backlog"`
+	UnmatchedBacklog     int64     `json:"unmatched_backlog"`
+	UnmatchedSelectors   []string  `json:"unmatched_selectors,omitempty"`
+	InFlight             int64     `json:"in_flight"`
+	OldestTaskAgeSeconds float64   `json:"oldest_task_age_seconds"`
+	Workers              int       `json:"workers"`
+	BusyWorkers          int       `json:"busy_workers"`
+	Paused               bool      `json:"paused"`
+	GeneratedAt          time.Time `json:"generated_at"`
+}
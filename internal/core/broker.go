@@ -0,0 +1,233 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrBrokerUnavailable is returned without contacting Redis while its
+// circuit breaker is open after repeated connection failures.
+var ErrBrokerUnavailable = errors.New("task broker is unavailable")
+
+// UnserializableTasksError is returned by EnqueueTasks when some of the
+// tasks could not be serialized. They were left out and the rest of the
+// batch was queued. Errors holds the error of each task left out, by ID.
+type UnserializableTasksError struct {
+	Errors map[string]error
+}
+
+func (e *UnserializableTasksError) Error() string {
+	return fmt.Sprintf("failed to serialize %d tasks", len(e.Errors))
+}
+
+type BrokerStatus string
+
+const (
+	// BrokerStatusUp means the last Redis command got through.
+	BrokerStatusUp BrokerStatus = "up"
+	// BrokerStatusDegraded means recent commands failed, but not enough
+	// in a row to open the circuit breaker.
+	BrokerStatusDegraded BrokerStatus = "degraded"
+	// BrokerStatusDown means the circuit breaker is open and commands fail
+	// fast until the next probe.
+	BrokerStatusDown BrokerStatus = "down"
+)
+
+// BrokerHealth describes the connection to Redis as seen by this process.
+type BrokerHealth struct {
+	Status              BrokerStatus `json:"status"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	LastError           string       `json:"last_error,omitempty"`
+	LastFailureAt       *time.Time   `json:"last_failure_at,omitempty"`
+	DownSince           *time.Time   `json:"down_since,omitempty"`
+	NextProbeAt         *time.Time   `json:"next_probe_at,omitempty"`
+}
+
+// Readiness reports whether a scheduler can serve requests: Redis answered
+// a ping and Postgres is reachable.
+type Readiness struct {
+	Ready         bool         `json:"ready"`
+	Broker        BrokerHealth `json:"broker"`
+	DatabaseError string       `json:"database_error,omitempty"`
+}
+
+// Queue is the task broker contract: moving tasks onto queues and through
+// their leases, reporting queue sizes, and keeping the registry of live
+// workers. The queue package's RedisQueue implements it; another broker can
+// stand in for it by implementing Queue, and Broker for the scheduler.
+type Queue interface {
+	// EnqueueTask queues a task, or parks it until its RunAt.
+	EnqueueTask(ctx context.Context, task *Task) error
+	// EnqueueTasks queues tasks together, so that after an error none of
+	// them is queued, except for an *UnserializableTasksError, which
+	// leaves out only the tasks it names.
+	EnqueueTasks(ctx context.Context, tasks []*Task) error
+	// DequeueTask leases the next task of a type to workerID, waiting up
+	// to timeout. It returns nil, nil if none arrived.
+	DequeueTask(ctx context.Context, workerID, taskType string, timeout time.Duration) (*Task, error)
+	// AckTask ends the lease of a finished task.
+	AckTask(ctx context.Context, task *Task) error
+	// NackTask ends the lease of a failed task, scheduling its retry or
+	// dead-lettering it.
+	NackTask(ctx context.Context, task *Task, errorMsg string) error
+	// RequeueTask ends the lease of a task its worker did not finish and
+	// queues it again after delay without counting an attempt.
+	RequeueTask(ctx context.Context, task *Task, delay time.Duration) error
+	// TouchTask extends the lease of a running task.
+	TouchTask(ctx context.Context, task *Task) error
+
+	// GetQueueStats returns the number of tasks of a type in each state,
+	// such as pending, processing and retry.
+	GetQueueStats(ctx context.Context, taskType string) (map[string]int64, error)
+	// GetTaskTypes returns the task types with a queue.
+	GetTaskTypes(ctx context.Context) ([]string, error)
+
+	WorkerRegistry
+
+	Close() error
+}
+
+// WorkerRegistry tracks the workers that are alive and what they handle.
+type WorkerRegistry interface {
+	RegisterWorker(ctx context.Context, workerID, address string, taskTypes []string) error
+	DeregisterWorker(ctx context.Context, workerID string, taskTypes []string) error
+	UpdateWorkerHeartbeat(ctx context.Context, workerID string) error
+	SetWorkerStatus(ctx context.Context, workerID, status string) error
+	GetActiveWorkers(ctx context.Context, taskType string) ([]WorkerInfo, error)
+	ListWorkers(ctx context.Context) ([]WorkerInfo, error)
+	GetWorker(ctx context.Context, workerID string) (*WorkerInfo, error)
+}
+
+// Broker is what the scheduler needs from its task broker on top of Queue:
+// moving retries, delayed tasks and expired leases along, the policies
+// that shape dispatch, leader election, the event stream, webhooks and
+// the janitor's housekeeping.
+type Broker interface {
+	Queue
+
+	CheckBroker(ctx context.Context) BrokerHealth
+	Redactor(ctx context.Context) Redactor
+
+	// Retries, delayed tasks, expired leases and dead workers.
+	ProcessRetries(ctx context.Context, taskType string) error
+	GetRetryTime(ctx context.Context, task *Task) (*time.Time, error)
+	ProcessDelayedTasks(ctx context.Context, taskType string) error
+	RequeueExpiredTasks(ctx context.Context, visibilityTimeout time.Duration) ([]Task, error)
+	// ExpireStaleWorkers returns the tasks of dead workers it requeued and
+	// those it dead-lettered instead, the latter with status failed.
+	ExpireStaleWorkers(ctx context.Context) (int, []Task, error)
+	TrackedTaskIDs(ctx context.Context, taskType string) (map[string]bool, error)
+	PurgeTasks(ctx context.Context, tasks []Task) (int, []string, error)
+	PublishCancellation(ctx context.Context, taskID string) error
+	GetIdempotentResult(ctx context.Context, namespace, key string) (map[string]interface{}, bool, error)
+	ClaimDedupeKey(ctx context.Context, fingerprint, taskID string, window time.Duration, stale string) (string, error)
+
+	// Queue control and sizes.
+	IsQueuePaused(ctx context.Context, taskType string) (bool, error)
+	PauseQueue(ctx context.Context, taskType string) error
+	ResumeQueue(ctx context.Context, taskType string) error
+	GetQueueLength(ctx context.Context, taskType string) (int64, error)
+	GetOldestEnqueuedAt(ctx context.Context, taskType string) (*time.Time, error)
+	// GetSelectorBacklog counts the ready tasks of a type waiting for each
+	// selector, as written by FormatSelector.
+	GetSelectorBacklog(ctx context.Context, taskType string) (map[string]int64, error)
+	GetQueueLatencyStats(ctx context.Context, taskType string) (*QueueLatencyStats, error)
+	GetQueueMemoryUsage(ctx context.Context, taskType string) (*QueueMemoryUsage, error)
+	GetRedisMemory(ctx context.Context) (*RedisMemory, error)
+
+	// Per-type and per-namespace policies.
+	GetQueueLengthLimit(ctx context.Context, taskType string) (*QueueLengthLimit, error)
+	GetQueueLengthLimits(ctx context.Context) (map[string]QueueLengthLimit, error)
+	SetQueueLengthLimit(ctx context.Context, limit QueueLengthLimit) error
+	DeleteQueueLengthLimit(ctx context.Context, taskType string) error
+	CheckQueueCapacity(ctx context.Context, limit QueueLengthLimit, adding int64) error
+	GetTaskRateLimit(ctx context.Context, taskType string) (*TaskRateLimit, error)
+	GetTaskRateLimits(ctx context.Context) (map[string]TaskRateLimit, error)
+	SetTaskRateLimit(ctx context.Context, limit TaskRateLimit) error
+	DeleteTaskRateLimit(ctx context.Context, taskType string) error
+	AvailableRateTokens(ctx context.Context, limit TaskRateLimit) (int, error)
+	TakeRateTokens(ctx context.Context, limit TaskRateLimit, n int) (int, error)
+	GetDispatchFairnessPolicy(ctx context.Context, taskType string) (*DispatchFairnessPolicy, error)
+	SetDispatchFairnessPolicy(ctx context.Context, policy DispatchFairnessPolicy) error
+	DeleteDispatchFairnessPolicy(ctx context.Context, taskType string) error
+	GetRetryOverloadPolicy(ctx context.Context, taskType string) (*RetryOverloadPolicy, error)
+	GetRetryOverloadPolicies(ctx context.Context) ([]RetryOverloadPolicy, error)
+	SetRetryOverloadPolicy(ctx context.Context, policy RetryOverloadPolicy) error
+	DeleteRetryOverloadPolicy(ctx context.Context, taskType string) error
+	EvictRetries(ctx context.Context, policy RetryOverloadPolicy) ([]Task, error)
+	GetLatencySLOs(ctx context.Context) ([]LatencySLO, error)
+	SetLatencySLO(ctx context.Context, slo LatencySLO) error
+	DeleteLatencySLO(ctx context.Context, taskType string) error
+	GetSandboxPolicy(ctx context.Context, namespace string) (*SandboxPolicy, error)
+	SetSandboxPolicy(ctx context.Context, policy SandboxPolicy) error
+	DeleteSandboxPolicy(ctx context.Context, namespace string) error
+	GetRedactionRules(ctx context.Context) ([]RedactionRule, error)
+	SetRedactionRule(ctx context.Context, rule RedactionRule) error
+	DeleteRedactionRule(ctx context.Context, taskType string) error
+
+	// Dead letters.
+	GetDeadLetterEntry(ctx context.Context, taskType, taskID string) (*Task, error)
+	GetDeadLetterStats(ctx context.Context, taskType string) (*DeadLetterStats, error)
+	GetDeadLetterPolicies(ctx context.Context) (map[string]DeadLetterPolicy, error)
+	SetDeadLetterPolicy(ctx context.Context, policy DeadLetterPolicy) error
+	DeleteDeadLetterPolicy(ctx context.Context, taskType string) error
+	PurgeDeadLetters(ctx context.Context, policy DeadLetterPolicy) (int64, int64, error)
+
+	// Leader election.
+	AcquireLeadership(ctx context.Context, instanceID string, ttl time.Duration) (bool, error)
+	ReleaseLeadership(ctx context.Context, instanceID string) error
+	GetLeader(ctx context.Context) (string, time.Duration, error)
+
+	// Events, task output and wait timers.
+	PublishEvent(ctx context.Context, event *Event) error
+	// PublishEvents publishes events in one round trip, skipping any that
+	// cannot be serialized.
+	PublishEvents(ctx context.Context, events []*Event) error
+	LastEventID(ctx context.Context) (string, error)
+	ReadEvents(ctx context.Context, after string, count int64, block time.Duration) ([]Event, error)
+	ReadWorkflowEvents(ctx context.Context, workflowID string, start, end time.Time) ([]Event, error)
+	// ReadWorkflowEventsAfter is ReadEvents for one workflow's events,
+	// without reading those of other workflows.
+	ReadWorkflowEventsAfter(ctx context.Context, workflowID, after string, count int64, block time.Duration) ([]Event, error)
+	ReadTaskOutput(ctx context.Context, taskID string, attempt int, after string, count int64, block time.Duration) ([]TaskOutputChunk, error)
+	AddTimer(ctx context.Context, taskID string, fireAt time.Time) error
+	GetDueTimers(ctx context.Context, now time.Time) ([]string, error)
+	RemoveTimers(ctx context.Context, taskIDs ...string) (int, error)
+
+	// Webhooks.
+	ListWebhooks(ctx context.Context) ([]Webhook, error)
+	GetWebhook(ctx context.Context, webhookID string) (*Webhook, error)
+	SaveWebhook(ctx context.Context, webhook *Webhook) error
+	DeleteWebhook(ctx context.Context, webhookID string) (bool, error)
+	ScheduleWebhookDelivery(ctx context.Context, delivery *WebhookDelivery, at time.Time) error
+	ClaimDueWebhookDeliveries(ctx context.Context, now time.Time, limit int64) ([]WebhookDelivery, error)
+	CompleteWebhookDelivery(ctx context.Context, delivery *WebhookDelivery) error
+	RecordWebhookAttempt(ctx context.Context, attempt *WebhookAttempt) error
+	GetWebhookAttempts(ctx context.Context, webhookID string) ([]WebhookAttempt, error)
+
+	// Submissions, decision logs and injected tasks.
+	SaveSubmission(ctx context.Context, submission *Submission) error
+	GetSubmission(ctx context.Context, id string) (*Submission, error)
+	GetDecisionLogConfig(ctx context.Context) (*DecisionLogConfig, error)
+	SetDecisionLogConfig(ctx context.Context, config DecisionLogConfig) error
+	DeleteDecisionLogConfig(ctx context.Context) error
+	RecordSchedulingPass(ctx context.Context, pass *SchedulingPass, retention time.Duration) error
+	GetSchedulingPasses(ctx context.Context, since time.Time, limit int64) ([]SchedulingPass, error)
+	PeekQueue(ctx context.Context, taskType, pool, namespace string, count int64) (*QueuePeek, error)
+	InjectTask(ctx context.Context, injected *InjectedTask) error
+	GetInjectedTask(ctx context.Context, taskID string) (*InjectedTask, error)
+	RecordInjectedTaskStatus(ctx context.Context, taskID string, status TaskStatus, result map[string]interface{}, errorMsg string) (bool, error)
+
+	// Janitor.
+	HeldTasks(ctx context.Context) ([]Task, error)
+	InjectedTaskIDs(ctx context.Context) (map[string]bool, error)
+	PruneInjectedTasks(ctx context.Context, cutoff time.Time) (int, error)
+	DeliveryCountTaskIDs(ctx context.Context) ([]string, error)
+	ClearDeliveryCounts(ctx context.Context, taskIDs []string) error
+	PruneWorkerSets(ctx context.Context) (int, error)
+	PrunePools(ctx context.Context) (int, error)
+	PruneNamespaces(ctx context.Context) (int, error)
+	PruneSelectors(ctx context.Context) (int, error)
+}
@@ -0,0 +1,204 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	calendarDateLayout = "2006-01-02"
+	calendarCacheTTL   = time.Hour * 6
+	maxShiftDays       = 366
+)
+
+// CalendarAction decides what happens to a schedule run that falls on a
+// holiday. Runs are skipped unless the calendar says otherwise.
+type CalendarAction string
+
+const (
+	// CalendarSkip drops the run; the schedule continues with its next
+	// regular fire time.
+	CalendarSkip CalendarAction = "skip"
+	// CalendarShift moves the run to the same time on the next business day.
+	CalendarShift CalendarAction = "shift"
+)
+
+// Calendar lists the days a schedule must not run on. Dates are calendar
+// days in the schedule's timezone; an iCal feed contributes the start date
+// of each of its events.
+type Calendar struct {
+	Dates        []string       `json:"dates,omitempty"`
+	ICalURL      string         `json:"ical_url,omitempty"`
+	SkipWeekends bool           `json:"skip_weekends,omitempty"`
+	Action       CalendarAction `json:"action,omitempty"`
+}
+
+func (c *Calendar) Validate() error {
+	for _, date := range c.Dates {
+		if _, err := time.Parse(calendarDateLayout, date); err != nil {
+			return fmt.Errorf("invalid calendar date %q, expected YYYY-MM-DD", date)
+		}
+	}
+
+	if c.ICalURL != "" {
+		parsed, err := url.Parse(c.ICalURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			return fmt.Errorf("invalid calendar ical_url %q", c.ICalURL)
+		}
+	}
+
+	switch c.Action {
+	case "", CalendarSkip, CalendarShift:
+	default:
+		return fmt.Errorf("unknown calendar action %q", c.Action)
+	}
+
+	return nil
+}
+
+// Holidays is the resolved set of non-business days of a calendar.
+type Holidays struct {
+	dates        map[string]bool
+	skipWeekends bool
+}
+
+func (h *Holidays) Contains(t time.Time) bool {
+	if h.skipWeekends && (t.Weekday() == time.Saturday || t.Weekday() == time.Sunday) {
+		return true
+	}
+	return h.dates[t.Format(calendarDateLayout)]
+}
+
+// NextBusinessDay returns t moved forward one day at a time, keeping its
+// clock time, until it no longer falls on a holiday.
+func (h *Holidays) NextBusinessDay(t time.Time) (time.Time, error) {
+	for i := 0; i < maxShiftDays; i++ {
+		t = t.AddDate(0, 0, 1)
+		if !h.Contains(t) {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("no business day within %d days", maxShiftDays)
+}
+
+// CalendarCache resolves calendars, fetching iCal feeds at most once per
+// TTL so that schedule ticks do not hit the feed every time.
+type CalendarCache struct {
+	client *http.Client
+	mu     sync.Mutex
+	feeds  map[string]cachedFeed
+}
+
+type cachedFeed struct {
+	dates     map[string]bool
+	fetchedAt time.Time
+}
+
+func NewCalendarCache() *CalendarCache {
+	return &CalendarCache{
+		client: &http.Client{Timeout: time.Second * 10},
+		feeds:  make(map[string]cachedFeed),
+	}
+}
+
+func (c *CalendarCache) Holidays(ctx context.Context, calendar *Calendar) (*Holidays, error) {
+	holidays := &Holidays{dates: make(map[string]bool), skipWeekends: calendar.SkipWeekends}
+
+	for _, date := range calendar.Dates {
+		holidays.dates[date] = true
+	}
+
+	if calendar.ICalURL != "" {
+		dates, err := c.feed(ctx, calendar.ICalURL)
+		if err != nil {
+			return nil, err
+		}
+		for date := range dates {
+			holidays.dates[date] = true
+		}
+	}
+
+	return holidays, nil
+}
+
+func (c *CalendarCache) feed(ctx context.Context, feedURL string) (map[string]bool, error) {
+	c.mu.Lock()
+	cached, ok := c.feeds[feedURL]
+	c.mu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < calendarCacheTTL {
+		return cached.dates, nil
+	}
+
+	dates, err := c.fetchICal(ctx, feedURL)
+	if err != nil {
+		if ok {
+			// Keep using the last good copy rather than running on holidays
+			// because the feed is briefly unavailable.
+			return cached.dates, nil
+		}
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.feeds[feedURL] = cachedFeed{dates: dates, fetchedAt: time.Now()}
+	c.mu.Unlock()
+
+	return dates, nil
+}
+
+func (c *CalendarCache) fetchICal(ctx context.Context, feedURL string) (map[string]bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build calendar request: %w", err)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch calendar: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("calendar feed returned status %d", resp.StatusCode)
+	}
+
+	return parseICalDates(resp.Body)
+}
+
+// parseICalDates collects the start date of every event in an iCal feed,
+// e.g. "DTSTART;VALUE=DATE:20261225" or "DTSTART:20261225T000000Z".
+func parseICalDates(r io.Reader) (map[string]bool, error) {
+	dates := make(map[string]bool)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "DTSTART") {
+			continue
+		}
+
+		colon := strings.LastIndex(line, ":")
+		if colon < 0 || len(line)-colon-1 < 8 {
+			continue
+		}
+
+		date, err := time.Parse("20060102", line[colon+1:colon+9])
+		if err != nil {
+			continue
+		}
+		dates[date.Format(calendarDateLayout)] = true
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read calendar: %w", err)
+	}
+
+	return dates, nil
+}
@@ -0,0 +1,83 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// settleWorkflow skips tasks whose upstream failed and, once every task has
+// reached a terminal status, marks the workflow completed or failed.
+func (s *Scheduler) settleWorkflow(ctx context.Context, workflowID string) error {
+	workflow, err := s.store.GetWorkflow(workflowID)
+	if err != nil {
+		return fmt.Errorf("failed to get workflow: %w", err)
+	}
+
+	if workflow.Status != WorkflowStatusPending && workflow.Status != WorkflowStatusRunning {
+		return nil
+	}
+
+	s.skipFailedDependents(ctx, workflow)
+
+	var unsuccessful []string
+	for _, task := range workflow.Tasks {
+		if !task.Status.IsTerminal() {
+			return nil
+		}
+		if task.Status != TaskStatusCompleted {
+			unsuccessful = append(unsuccessful, fmt.Sprintf("%s (%s)", task.Name, task.Status))
+		}
+	}
+
+	if len(unsuccessful) > 0 {
+		return s.failWorkflow(ctx, workflow.ID, fmt.Sprintf("tasks did not succeed: %s", strings.Join(unsuccessful, ", ")))
+	}
+
+	if err := s.setWorkflowStatus(ctx, workflow.ID, WorkflowStatusCompleted); err != nil {
+		return fmt.Errorf("failed to complete workflow: %w", err)
+	}
+
+	s.logger.Infof("Workflow %s completed", workflow.ID)
+	return nil
+}
+
+// skipFailedDependents marks pending tasks as skipped when a dependency
+// failed, was cancelled, expired or was itself skipped, repeating until the failure
+// has propagated through the whole graph. Tasks that opt into
+// RunOnUpstreamFailure are left to run once their dependencies finish.
+func (s *Scheduler) skipFailedDependents(ctx context.Context, workflow *Workflow) {
+	failedTasks := make(map[string]bool)
+	for _, task := range workflow.Tasks {
+		if task.Status == TaskStatusFailed || task.Status == TaskStatusCancelled || task.Status == TaskStatusSkipped || task.Status == TaskStatusExpired {
+			failedTasks[task.ID] = true
+			failedTasks[task.Name] = true
+		}
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for i := range workflow.Tasks {
+			task := &workflow.Tasks[i]
+			if task.Status != TaskStatusPending || task.RunOnUpstreamFailure {
+				continue
+			}
+
+			failed := task.FailedDependencies(failedTasks)
+			if len(failed) == 0 {
+				continue
+			}
+
+			errorMsg := fmt.Sprintf("skipped because %s did not succeed", strings.Join(failed, ", "))
+			if err := s.setTaskStatus(ctx, workflow.ID, task.ID, TaskStatusSkipped, nil, errorMsg); err != nil {
+				s.logger.Errorf("Failed to skip task %s: %v", task.ID, err)
+				continue
+			}
+
+			task.Status = TaskStatusSkipped
+			failedTasks[task.ID] = true
+			failedTasks[task.Name] = true
+			changed = true
+		}
+	}
+}
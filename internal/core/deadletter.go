@@ -0,0 +1,100 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+type DeadLetterOverflow string
+
+const (
+	DeadLetterDropOldest DeadLetterOverflow = "drop_oldest"
+	DeadLetterArchive    DeadLetterOverflow = "archive"
+	DeadLetterBlockNack  DeadLetterOverflow = "block_nack"
+)
+
+var ErrDeadLetterFull = errors.New("dead letter queue is full")
+
+// MinDeadLetterMaxAge is the shortest retention a dead letter policy may
+// set, leaving time to inspect a dead-lettered task before it is purged.
+const MinDeadLetterMaxAge = time.Minute
+
+// DeadLetterPolicy bounds a task type's dead letter queue. MaxSize caps its
+// entries, with Overflow picking what happens to the excess, and MaxAge
+// purges entries dead-lettered longer ago. Zero means no bound.
+type DeadLetterPolicy struct {
+	TaskType string             `json:"task_type"`
+	MaxSize  int64              `json:"max_size"`
+	Overflow DeadLetterOverflow `json:"overflow,omitempty"`
+	MaxAge   time.Duration      `json:"max_age,omitempty"`
+}
+
+type DeadLetterStats struct {
+	TaskType  string            `json:"task_type"`
+	Size      int64             `json:"size"`
+	OldestAge time.Duration     `json:"oldest_age"`
+	Dropped   int64             `json:"dropped"`
+	Archived  int64             `json:"archived"`
+	Expired   int64             `json:"expired"`
+	Policy    *DeadLetterPolicy `json:"policy,omitempty"`
+}
+
+func (o DeadLetterOverflow) Valid() bool {
+	switch o {
+	case DeadLetterDropOldest, DeadLetterArchive, DeadLetterBlockNack:
+		return true
+	}
+	return false
+}
+
+func (p *DeadLetterPolicy) Validate() error {
+	if p.MaxSize < 0 {
+		return fmt.Errorf("max_size must not be negative")
+	}
+	if p.MaxSize > 0 && !p.Overflow.Valid() {
+		return fmt.Errorf("overflow must be one of drop_oldest, archive, block_nack")
+	}
+	if p.MaxAge < 0 || (p.MaxAge > 0 && p.MaxAge < MinDeadLetterMaxAge) {
+		return fmt.Errorf("max_age must be at least %s", MinDeadLetterMaxAge)
+	}
+	if p.MaxSize == 0 && p.MaxAge == 0 {
+		return fmt.Errorf("max_size, max_age or both must be set")
+	}
+	return nil
+}
+
+// DeadLetterAlert is sent when a task is moved to a dead letter queue.
+type DeadLetterAlert struct {
+	TaskID         string                 `json:"task_id"`
+	WorkflowID     string                 `json:"workflow_id"`
+	TaskName       string                 `json:"task_name"`
+	TaskType       string                 `json:"task_type"`
+	Error          string                 `json:"error"`
+	RetryCount     int                    `json:"retry_count"`
+	Owner          string                 `json:"owner,omitempty"`
+	DocsURL        string                 `json:"docs_url,omitempty"`
+	RunbookURL     string                 `json:"runbook_url,omitempty"`
+	PayloadSample  map[string]interface{} `json:"payload_sample,omitempty"`
+	Link           string                 `json:"link,omitempty"`
+	DeadLetteredAt time.Time              `json:"dead_lettered_at"`
+}
+
+func NewDeadLetterAlert(task *Task, redactor Redactor) *DeadLetterAlert {
+	alert := &DeadLetterAlert{
+		TaskID:        task.ID,
+		WorkflowID:    task.WorkflowID,
+		TaskName:      task.Name,
+		TaskType:      task.Type,
+		Error:         task.Error,
+		RetryCount:    task.RetryCount,
+		Owner:         task.Owner,
+		DocsURL:       task.DocsURL,
+		RunbookURL:    task.RunbookURL,
+		PayloadSample: SamplePayload(redactor, task.Type, task.Payload),
+	}
+	if task.DeadLetteredAt != nil {
+		alert.DeadLetteredAt = *task.DeadLetteredAt
+	}
+	return alert
+}
@@ -0,0 +1,57 @@
+package core
+
+import (
+	"context"
+	"time"
+)
+
+// deadLetterPurgeInterval is how often dead letter queues are purged by
+// their retention policies, and so roughly how long past its max age an
+// entry may linger.
+const deadLetterPurgeInterval = time.Minute
+
+// purgeDeadLetters applies every dead letter policy's retention in the
+// background, so dead letter queues of types that rarely fail shrink too.
+func (s *Scheduler) purgeDeadLetters(ctx context.Context) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(deadLetterPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			passCtx, leading := s.leaderContext()
+			if !leading {
+				continue
+			}
+			policies, err := s.queue.GetDeadLetterPolicies(passCtx)
+			if err != nil {
+				s.logger.Errorf("Failed to get dead letter policies: %v", err)
+				continue
+			}
+			for _, policy := range policies {
+				s.applyDeadLetterRetention(passCtx, policy)
+			}
+		}
+	}
+}
+
+func (s *Scheduler) applyDeadLetterRetention(ctx context.Context, policy DeadLetterPolicy) {
+	expired, dropped, err := s.queue.PurgeDeadLetters(ctx, policy)
+	if expired > 0 {
+		s.metrics.deadLettersPurged.Add(float64(expired), policy.TaskType, "max_age")
+		s.logger.Infof("Purged %d dead letter entries of %s older than %s", expired, policy.TaskType, policy.MaxAge)
+	}
+	if dropped > 0 {
+		s.metrics.deadLettersPurged.Add(float64(dropped), policy.TaskType, "max_size")
+		s.logger.Infof("Purged %d dead letter entries of %s over its cap of %d", dropped, policy.TaskType, policy.MaxSize)
+	}
+	if err != nil {
+		s.logger.Errorf("Failed to purge dead letter queue of %s: %v", policy.TaskType, err)
+	}
+}
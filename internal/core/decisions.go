@@ -0,0 +1,50 @@
+package core
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+const (
+	DefaultDecisionRetention = time.Hour
+	// MaxDecisionRetention bounds how long passes are kept, since at a
+	// sample rate of 1 the leader records every scheduling pass.
+	MaxDecisionRetention = time.Hour * 24
+
+	DefaultSchedulingPassCount = 20
+	MaxSchedulingPassCount     = 100
+)
+
+// DecisionLogConfig turns on recording of the scheduler's decisions. The
+// leader records each scheduling pass with probability SampleRate, and
+// recorded passes are kept for Retention.
+type DecisionLogConfig struct {
+	SampleRate float64       `json:"sample_rate"`
+	Retention  time.Duration `json:"retention"`
+}
+
+func (c *DecisionLogConfig) Validate() error {
+	if c.SampleRate <= 0 || c.SampleRate > 1 {
+		return fmt.Errorf("sample_rate must be greater than 0 and at most 1")
+	}
+	if c.Retention <= 0 || c.Retention > MaxDecisionRetention {
+		return fmt.Errorf("retention must be positive and at most %s", MaxDecisionRetention)
+	}
+	return nil
+}
+
+// Sampled reports whether the next scheduling pass should be recorded.
+func (c *DecisionLogConfig) Sampled() bool {
+	return c.SampleRate >= 1 || rand.Float64() < c.SampleRate
+}
+
+// SchedulingPass is the record of one sampled scheduling pass: every
+// pending task it considered, in dispatch order for those it dispatched and
+// with the reason for those it held back.
+type SchedulingPass struct {
+	DispatchReport
+	SchedulerID string        `json:"scheduler_id"`
+	Considered  int           `json:"considered"`
+	Duration    time.Duration `json:"duration"`
+}
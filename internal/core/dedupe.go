@@ -0,0 +1,73 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	// MaxDedupeWindow bounds how long a task's fingerprint is held, so an
+	// original that never finishes cannot hold its duplicates back forever.
+	MaxDedupeWindow    = time.Hour * 24
+	maxDedupeKeyLength = 255
+)
+
+// DedupeConfig asks the scheduler not to queue a task while an identical
+// one is already queued or running. Tasks are identical when they share a
+// namespace, a type and Key or, without a Key, a namespace, type and
+// payload. Window bounds how long
+// after the original is dispatched its duplicates are held back.
+type DedupeConfig struct {
+	Key    string        `json:"key,omitempty"`
+	Window time.Duration `json:"window"`
+}
+
+// DedupeSpec is a DedupeConfig as written in a YAML workflow file, with the
+// window as a duration string such as "10m".
+type DedupeSpec struct {
+	Key    string `yaml:"key,omitempty"`
+	Window string `yaml:"window"`
+}
+
+func (d *DedupeConfig) Validate() error {
+	if d.Window < time.Second || d.Window > MaxDedupeWindow {
+		return fmt.Errorf("dedupe window must be between 1s and %s", MaxDedupeWindow)
+	}
+	if len(d.Key) > maxDedupeKeyLength {
+		return fmt.Errorf("dedupe key is longer than %d characters", maxDedupeKeyLength)
+	}
+	return nil
+}
+
+func (d *DedupeSpec) config() (*DedupeConfig, error) {
+	window, err := time.ParseDuration(d.Window)
+	if err != nil {
+		return nil, fmt.Errorf("invalid dedupe window: %w", err)
+	}
+	config := &DedupeConfig{Key: d.Key, Window: window}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// DedupeFingerprint identifies the tasks a deduplicated task of a workflow
+// in namespace counts as identical to: its type with its dedupe key, or with
+// a hash of its payload when it has none. Tasks of different namespaces are
+// never identical, so one namespace never receives another's results.
+func (t *Task) DedupeFingerprint(namespace string) string {
+	if namespace == "" {
+		namespace = DefaultNamespace
+	}
+	if t.Dedupe != nil && t.Dedupe.Key != "" {
+		return namespace + ":" + t.Type + ":key:" + t.Dedupe.Key
+	}
+
+	// Marshalling sorts map keys, so equal payloads hash equally.
+	payload, _ := json.Marshal(t.Payload)
+	sum := sha256.Sum256(payload)
+	return namespace + ":" + t.Type + ":payload:" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,77 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDedupeFingerprintIsScopedToNamespace(t *testing.T) {
+	task := &Task{Type: "etl", Payload: map[string]interface{}{"source": "s3://bucket"}}
+	if task.DedupeFingerprint("team-a") == task.DedupeFingerprint("team-b") {
+		t.Error("identical tasks of different namespaces share a fingerprint")
+	}
+	if task.DedupeFingerprint("") != task.DedupeFingerprint(DefaultNamespace) {
+		t.Error("an empty namespace does not fingerprint as the default namespace")
+	}
+
+	keyed := &Task{Type: "etl", Dedupe: &DedupeConfig{Key: "nightly", Window: time.Minute}}
+	if keyed.DedupeFingerprint("team-a") == keyed.DedupeFingerprint("team-b") {
+		t.Error("tasks with the same dedupe key in different namespaces share a fingerprint")
+	}
+}
+
+func TestDeduplicateDoesNotHoldBackTasksOfAnotherNamespace(t *testing.T) {
+	store := newFakeStore()
+	broker := newFakeBroker()
+
+	dedupe := &DedupeConfig{Key: "nightly", Window: time.Minute}
+	for _, namespace := range []string{"team-a", "team-b"} {
+		store.add(&Workflow{
+			ID:        "wf-" + namespace,
+			Namespace: namespace,
+			Status:    WorkflowStatusRunning,
+			Tasks: []Task{{
+				ID:         "task-" + namespace,
+				WorkflowID: "wf-" + namespace,
+				Type:       "etl",
+				Status:     TaskStatusPending,
+				Dedupe:     dedupe,
+			}},
+		})
+	}
+
+	s := newTestScheduler(store, broker)
+	for _, namespace := range []string{"team-a", "team-b"} {
+		task, _ := store.GetTask("task-" + namespace)
+		ready, err := s.prepareDispatch(context.Background(), task)
+		if err != nil {
+			t.Fatalf("prepareDispatch(%s): %v", task.ID, err)
+		}
+		if !ready {
+			t.Errorf("task of namespace %s was held back as a duplicate of another namespace's task", namespace)
+		}
+	}
+
+	// A second identical task in the same namespace is still held back.
+	store.add(&Workflow{
+		ID:        "wf-team-a-2",
+		Namespace: "team-a",
+		Status:    WorkflowStatusRunning,
+		Tasks: []Task{{
+			ID:         "task-team-a-2",
+			WorkflowID: "wf-team-a-2",
+			Type:       "etl",
+			Status:     TaskStatusPending,
+			Dedupe:     dedupe,
+		}},
+	})
+	duplicate, _ := store.GetTask("task-team-a-2")
+	ready, err := s.prepareDispatch(context.Background(), duplicate)
+	if err != nil {
+		t.Fatalf("prepareDispatch: %v", err)
+	}
+	if ready {
+		t.Error("a duplicate in the same namespace was not held back")
+	}
+}
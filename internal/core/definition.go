@@ -0,0 +1,291 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	maxIDLength             = 36
+	maxIdempotencyKeyLength = 255
+	maxPoolLength           = 64
+)
+
+var (
+	ErrInvalidID   = errors.New("invalid id")
+	ErrDuplicateID = errors.New("id already in use")
+)
+
+type WorkflowDefinition struct {
+	ID          string                 `json:"id,omitempty"`
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Namespace   string                 `json:"namespace,omitempty"`
+	Priority    int                    `json:"priority,omitempty"`
+	Pool        string                 `json:"pool,omitempty"`
+	Owner       string                 `json:"owner,omitempty"`
+	DocsURL     string                 `json:"docs_url,omitempty"`
+	RunbookURL  string                 `json:"runbook_url,omitempty"`
+	Config      *WorkflowConfig        `json:"config,omitempty"`
+	Parameters  map[string]ParamSpec   `json:"parameters,omitempty"`
+	Params      map[string]interface{} `json:"params,omitempty"`
+	Labels      map[string]string      `json:"labels,omitempty"`
+	Tasks       []TaskDefinition       `json:"tasks"`
+}
+
+type TaskDefinition struct {
+	ID                   string                 `json:"id,omitempty"`
+	Name                 string                 `json:"name"`
+	Type                 string                 `json:"type"`
+	Payload              map[string]interface{} `json:"payload"`
+	MaxRetries           int                    `json:"max_retries,omitempty"`
+	Priority             int                    `json:"priority,omitempty"`
+	Dependencies         []string               `json:"dependencies,omitempty"`
+	Timeout              time.Duration          `json:"timeout,omitempty"`
+	RunAt                *time.Time             `json:"run_at,omitempty"`
+	ExpiresAt            *time.Time             `json:"expires_at,omitempty"`
+	RunOnUpstreamFailure bool                   `json:"run_on_upstream_failure,omitempty"`
+	Executor             Executor               `json:"executor,omitempty"`
+	Resources            *ResourceRequest       `json:"resources,omitempty"`
+	Selector             map[string]string      `json:"selector,omitempty"`
+	IdempotencyKey       string                 `json:"idempotency_key,omitempty"`
+	Dedupe               *DedupeConfig          `json:"dedupe,omitempty"`
+	Owner                string                 `json:"owner,omitempty"`
+	DocsURL              string                 `json:"docs_url,omitempty"`
+	RunbookURL           string                 `json:"runbook_url,omitempty"`
+	Group                string                 `json:"group,omitempty"`
+}
+
+// Validate checks client-supplied IDs and payload templates. Uniqueness
+// against records that already exist is checked by the scheduler on
+// submission.
+func (d *WorkflowDefinition) Validate() error {
+	if err := d.validateFields(); err != nil {
+		return err
+	}
+	return d.validateTemplates()
+}
+
+func (d *WorkflowDefinition) validateFields() error {
+	if d.ID != "" {
+		if err := validateID(d.ID); err != nil {
+			return err
+		}
+	}
+
+	if err := validateParamSpecs(d.Parameters); err != nil {
+		return err
+	}
+
+	if err := ValidatePool(d.Pool); err != nil {
+		return err
+	}
+
+	if err := validateLabels(d.Labels); err != nil {
+		return err
+	}
+
+	if err := validateOwnership(d.DocsURL, d.RunbookURL); err != nil {
+		return err
+	}
+
+	if d.Config != nil && !d.Config.RetryPolicy.Jitter.Valid() {
+		return fmt.Errorf("unknown retry jitter %q", d.Config.RetryPolicy.Jitter)
+	}
+
+	if d.Config != nil {
+		if err := validateGroupConcurrency(d.Config.GroupConcurrency); err != nil {
+			return err
+		}
+		if err := validateRetryBudget(d.Config.RetryBudget); err != nil {
+			return err
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, taskDef := range d.Tasks {
+		if taskDef.Executor != "" && !taskDef.Executor.Valid() {
+			return fmt.Errorf("task %s: unknown executor %q", taskDef.Name, taskDef.Executor)
+		}
+		if err := ValidateSelector(taskDef.Selector); err != nil {
+			return fmt.Errorf("task %s: %w", taskDef.Name, err)
+		}
+		if len(taskDef.IdempotencyKey) > maxIdempotencyKeyLength {
+			return fmt.Errorf("task %s: idempotency key is longer than %d characters", taskDef.Name, maxIdempotencyKeyLength)
+		}
+		if taskDef.Dedupe != nil {
+			if err := taskDef.Dedupe.Validate(); err != nil {
+				return fmt.Errorf("task %s: %w", taskDef.Name, err)
+			}
+		}
+		if err := validateExpiry(taskDef.RunAt, taskDef.ExpiresAt); err != nil {
+			return fmt.Errorf("task %s: %w", taskDef.Name, err)
+		}
+		if len(taskDef.Group) > maxGroupLength {
+			return fmt.Errorf("task %s: group is longer than %d characters", taskDef.Name, maxGroupLength)
+		}
+		if err := validateOwnership(taskDef.DocsURL, taskDef.RunbookURL); err != nil {
+			return fmt.Errorf("task %s: %w", taskDef.Name, err)
+		}
+		if taskDef.ID == "" {
+			continue
+		}
+		if err := validateID(taskDef.ID); err != nil {
+			return err
+		}
+		if seen[taskDef.ID] {
+			return fmt.Errorf("%w: task id %s is used more than once", ErrDuplicateID, taskDef.ID)
+		}
+		seen[taskDef.ID] = true
+	}
+
+	return nil
+}
+
+// validateTemplates renders every payload against the definition's params
+// so that unknown variables and functions are reported before submission.
+// Wait tasks' payloads are checked once rendered.
+func (d *WorkflowDefinition) validateTemplates() error {
+	params, err := ResolveParams(d.Parameters, d.Params)
+	if err != nil {
+		return err
+	}
+
+	workflow := NewWorkflow(d.Name, d.Description)
+	for _, taskDef := range d.Tasks {
+		payload, err := RenderPayload(taskDef.Payload, NewTemplateContext(params, workflow, taskDef.Name))
+		if err != nil {
+			return fmt.Errorf("task %s: %w", taskDef.Name, err)
+		}
+		if err := validateWait(taskDef.Type, payload, taskDef.RunAt); err != nil {
+			return fmt.Errorf("task %s: %w", taskDef.Name, err)
+		}
+	}
+	return nil
+}
+
+func (d *WorkflowDefinition) HasFixedIDs() bool {
+	if d.ID != "" {
+		return true
+	}
+	for _, taskDef := range d.Tasks {
+		if taskDef.ID != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidatePool checks a worker pool name. Pool names may only contain
+// letters, digits, '-', '_' and '.'; the empty name means no pool.
+func ValidatePool(pool string) error {
+	if len(pool) > maxPoolLength {
+		return fmt.Errorf("pool %s is longer than %d characters", pool, maxPoolLength)
+	}
+
+	for _, r := range pool {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.':
+		default:
+			return fmt.Errorf("pool %s contains %q", pool, r)
+		}
+	}
+
+	return nil
+}
+
+func validateRetryBudget(budget int) error {
+	if budget < 0 {
+		return errors.New("retry budget must not be negative")
+	}
+	return nil
+}
+
+func validateID(id string) error {
+	if len(id) > maxIDLength {
+		return fmt.Errorf("%w: %s is longer than %d characters", ErrInvalidID, id, maxIDLength)
+	}
+
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':':
+		default:
+			return fmt.Errorf("%w: %s contains %q", ErrInvalidID, id, r)
+		}
+	}
+
+	return nil
+}
+
+// NewWorkflow builds a workflow from the definition, rendering payload
+// templates with fresh values such as the current time on every call.
+func (d *WorkflowDefinition) NewWorkflow() (*Workflow, error) {
+	workflow := NewWorkflow(d.Name, d.Description)
+	if d.ID != "" {
+		workflow.ID = d.ID
+	}
+	if d.Namespace != "" {
+		workflow.Namespace = d.Namespace
+	}
+	workflow.Priority = d.Priority
+	workflow.Pool = d.Pool
+	workflow.Owner = d.Owner
+	workflow.DocsURL = d.DocsURL
+	workflow.RunbookURL = d.RunbookURL
+	if d.Config != nil {
+		workflow.Config = *d.Config
+	}
+
+	params, err := ResolveParams(d.Parameters, d.Params)
+	if err != nil {
+		return nil, err
+	}
+	workflow.Params = params
+	workflow.Labels = d.Labels
+
+	for _, taskDef := range d.Tasks {
+		payload, err := RenderPayload(taskDef.Payload, NewTemplateContext(params, workflow, taskDef.Name))
+		if err != nil {
+			return nil, fmt.Errorf("task %s: %w", taskDef.Name, err)
+		}
+
+		task := NewTask(workflow.ID, taskDef.Name, taskDef.Type, payload)
+		if taskDef.ID != "" {
+			task.ID = taskDef.ID
+		}
+
+		if taskDef.MaxRetries > 0 {
+			task.MaxRetries = taskDef.MaxRetries
+		}
+		if workflow.Priority > 0 {
+			task.Priority = workflow.Priority
+		}
+		if taskDef.Priority > 0 {
+			task.Priority = taskDef.Priority
+		}
+		if taskDef.Dependencies != nil {
+			task.Dependencies = taskDef.Dependencies
+		}
+		if taskDef.Timeout > 0 {
+			task.Timeout = taskDef.Timeout
+		}
+		task.RunAt = taskDef.RunAt
+		task.ExpiresAt = taskDef.ExpiresAt
+		task.RunOnUpstreamFailure = taskDef.RunOnUpstreamFailure
+		task.Executor = taskDef.Executor
+		task.Resources = taskDef.Resources
+		task.Selector = taskDef.Selector
+		task.IdempotencyKey = taskDef.IdempotencyKey
+		task.Dedupe = taskDef.Dedupe
+		task.Pool = workflow.Pool
+		task.Group = taskDef.Group
+		task.inheritOwnership(taskDef.Owner, taskDef.DocsURL, taskDef.RunbookURL, workflow)
+
+		workflow.Tasks = append(workflow.Tasks, *task)
+	}
+
+	return workflow, nil
+}
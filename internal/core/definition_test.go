@@ -0,0 +1,131 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"go/format"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// assertGofmted fails the test unless file is formatted as gofmt would.
+func assertGofmted(t *testing.T, file string) {
+	t.Helper()
+	source, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	formatted, err := format.Source(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(source, formatted) {
+		t.Errorf("%s is not gofmt-clean; run gofmt -w internal/core/%s", file, file)
+	}
+}
+
+// The definition types are edited by most changes to the workflow format,
+// so keep their file formatted as gofmt would.
+func TestDefinitionIsGofmted(t *testing.T) {
+	assertGofmted(t, "definition.go")
+}
+
+// As are the task and workflow types, by most changes to the scheduler.
+func TestTypesIsGofmted(t *testing.T) {
+	assertGofmted(t, "types.go")
+}
+
+// The YAML parser is edited alongside the definition types.
+func TestYAMLParserIsGofmted(t *testing.T) {
+	assertGofmted(t, "yaml_parser.go")
+}
+
+func TestRenderPayloadTemplateFuncs(t *testing.T) {
+	data := NewTemplateContext(map[string]interface{}{
+		"date":    "2024-03-15",
+		"shards":  4,
+		"region":  " eu-west ",
+		"tables":  []interface{}{"orders", "users"},
+		"empty":   "",
+		"config":  `{"items":[{"name":"first"},{"name":"second"}]}`,
+		"ratio":   7.5,
+		"numeric": "10",
+	}, &Workflow{ID: "wf-1", Name: "nightly"}, "extract")
+
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{`{{ .params.date | toDate "2006-01-02" | dateAdd "24h" | date "2006-01-02" }}`, "2024-03-16"},
+		{`{{ toDate "2006-01-02T15:04:05Z07:00" "2024-03-15T13:45:00Z" | dateTrunc "1h" | unixEpoch }}`, "1710507600"},
+		{`{{ add .params.shards 1 }}`, "5"},
+		{`{{ sub .params.numeric 3 }}`, "7"},
+		{`{{ mul .params.ratio 2 }}`, "15"},
+		{`{{ div .params.shards 8 }}`, "0.5"},
+		{`{{ mod 10 .params.shards }}`, "2"},
+		{`{{ max 3 .params.shards }} {{ min 3 .params.shards }}`, "4 3"},
+		{`{{ floor .params.ratio }} {{ ceil .params.ratio }} {{ int .params.ratio }}`, "7 8 7"},
+		{`{{ .params.region | trim | upper }}`, "EU-WEST"},
+		{`{{ "s3://bucket/" | trimPrefix "s3://" | trimSuffix "/" }}`, "bucket"},
+		{`{{ replace "-" "_" .workflow.name }}-{{ .task.name | title }}`, "nightly-Extract"},
+		{`{{ contains "west" .params.region }} {{ hasPrefix "eu" .params.region }}`, "true false"},
+		{`{{ join "," .params.tables }}`, "orders,users"},
+		{`{{ split "/" "a/b/c" | join "|" }}`, "a|b|c"},
+		{`{{ .params.empty | default "all" }}`, "all"},
+		{`{{ quote .task.name }} {{ repeat 3 "ab" }}`, `"extract" ababab`},
+		{`{{ .params.tables | toJson }}`, `["orders","users"]`},
+		{`{{ jsonPath "items[1].name" .params.config }}`, "second"},
+		{`{{ (fromJson .params.config).items | len }}`, "2"},
+	}
+	for _, tt := range tests {
+		rendered, err := RenderPayload(map[string]interface{}{"value": tt.template}, data)
+		if err != nil {
+			t.Errorf("%s: %v", tt.template, err)
+			continue
+		}
+		if got := rendered["value"]; got != tt.want {
+			t.Errorf("%s rendered %q, want %q", tt.template, got, tt.want)
+		}
+	}
+}
+
+func TestRenderPayloadIsStrict(t *testing.T) {
+	data := NewTemplateContext(map[string]interface{}{"shards": 4}, &Workflow{ID: "wf-1"}, "extract")
+	for _, template := range []string{
+		`{{ .params.missing }}`,
+		`{{ div .params.shards 0 }}`,
+		`{{ add "four" 1 }}`,
+		`{{ dateAdd "tomorrow" now }}`,
+		`{{ unknownFunc 1 }}`,
+	} {
+		_, err := RenderPayload(map[string]interface{}{"value": template}, data)
+		if !errors.Is(err, ErrInvalidTemplate) {
+			t.Errorf("%s: error = %v, want %v", template, err, ErrInvalidTemplate)
+		}
+	}
+
+	if err := CheckPayload(map[string]interface{}{"nested": []interface{}{"{{ unknownFunc }}"}}); !errors.Is(err, ErrInvalidTemplate) {
+		t.Errorf("CheckPayload() error = %v, want an unknown function caught before rendering", err)
+	}
+}
+
+func TestRenderPayloadLeavesPlainValues(t *testing.T) {
+	payload := map[string]interface{}{
+		"count":  3,
+		"plain":  "no template here",
+		"nested": map[string]interface{}{"list": []interface{}{"{{ .task.name }}", true}},
+	}
+	rendered, err := RenderPayload(payload, NewTemplateContext(nil, &Workflow{ID: "wf-1"}, "extract"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"count":  3,
+		"plain":  "no template here",
+		"nested": map[string]interface{}{"list": []interface{}{"extract", true}},
+	}
+	if !reflect.DeepEqual(rendered, want) {
+		t.Errorf("RenderPayload() = %v, want %v", rendered, want)
+	}
+}
@@ -0,0 +1,56 @@
+package core
+
+import (
+	"context"
+	"time"
+)
+
+// delayedTaskInterval is how often tasks whose run_at has come are moved
+// onto their queues, and so roughly how late past run_at a task may start.
+const delayedTaskInterval = time.Second * 5
+
+// promoteDelayedTasks moves tasks parked until their RunAt onto their
+// queues once it has passed, and completes wait tasks whose timers have
+// fired.
+func (s *Scheduler) promoteDelayedTasks(ctx context.Context) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(delayedTaskInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			passCtx, leading := s.leaderContext()
+			if !leading {
+				continue
+			}
+			if err := s.completeDueWaits(passCtx); err != nil {
+				s.logger.Errorf("Failed to complete wait tasks: %v", err)
+			}
+			s.promoteDueDelayedTasks(passCtx)
+		}
+	}
+}
+
+// promoteDueDelayedTasks queues the delayed tasks whose run_at has passed,
+// of every task type with a queue.
+func (s *Scheduler) promoteDueDelayedTasks(ctx context.Context) {
+	taskTypes, err := s.queue.GetTaskTypes(ctx)
+	if err != nil {
+		s.logger.Errorf("Failed to get task types: %v", err)
+		return
+	}
+	for _, taskType := range taskTypes {
+		if err := s.checkLease(ctx); err != nil {
+			return
+		}
+		if err := s.queue.ProcessDelayedTasks(ctx, taskType); err != nil {
+			s.logger.Errorf("Failed to promote delayed tasks of type %s: %v", taskType, err)
+		}
+	}
+}
@@ -0,0 +1,548 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+type BlockReason string
+
+const (
+	BlockReasonUnmetDependency       BlockReason = "unmet_dependency"
+	BlockReasonQueuePaused           BlockReason = "queue_paused"
+	BlockReasonConcurrencyLimit      BlockReason = "concurrency_limit"
+	BlockReasonGroupConcurrencyLimit BlockReason = "group_concurrency_limit"
+	BlockReasonWorkflowInactive      BlockReason = "workflow_inactive"
+	BlockReasonUpstreamFailed        BlockReason = "upstream_failed"
+	BlockReasonRateLimited           BlockReason = "rate_limited"
+	BlockReasonQueueFull             BlockReason = "queue_full"
+)
+
+type TaskDecision struct {
+	TaskID     string      `json:"task_id"`
+	TaskName   string      `json:"task_name"`
+	WorkflowID string      `json:"workflow_id"`
+	Type       string      `json:"type"`
+	Priority   int         `json:"priority"`
+	Reason     BlockReason `json:"reason,omitempty"`
+	Detail     string      `json:"detail,omitempty"`
+}
+
+type DispatchReport struct {
+	GeneratedAt time.Time      `json:"generated_at"`
+	Dispatch    []TaskDecision `json:"dispatch"`
+	Blocked     []TaskDecision `json:"blocked"`
+}
+
+func newTaskDecision(task *Task, reason BlockReason, detail string) TaskDecision {
+	return TaskDecision{
+		TaskID:     task.ID,
+		TaskName:   task.Name,
+		WorkflowID: task.WorkflowID,
+		Type:       task.Type,
+		Priority:   task.Priority,
+		Reason:     reason,
+		Detail:     detail,
+	}
+}
+
+func (s *Scheduler) planWorkflowTasks(ctx context.Context, workflow *Workflow, tasks []Task) ([]Task, []TaskDecision) {
+	completedTasks := make(map[string]bool)
+	finishedTasks := make(map[string]bool)
+	failedTasks := make(map[string]bool)
+	inFlight := 0
+	groupInFlight := make(map[string]int)
+	for _, task := range workflow.Tasks {
+		switch task.Status {
+		case TaskStatusCompleted:
+			completedTasks[task.ID] = true
+			completedTasks[task.Name] = true
+		case TaskStatusFailed, TaskStatusCancelled, TaskStatusSkipped, TaskStatusExpired:
+			failedTasks[task.ID] = true
+			failedTasks[task.Name] = true
+		case TaskStatusQueued, TaskStatusRunning:
+			inFlight++
+			groupInFlight[task.Group]++
+		}
+		if task.Status.IsTerminal() {
+			finishedTasks[task.ID] = true
+			finishedTasks[task.Name] = true
+		}
+	}
+
+	pausedTypes := make(map[string]bool)
+	var dispatch []Task
+	var blocked []TaskDecision
+
+	for i := range tasks {
+		task := &tasks[i]
+
+		if failed := task.FailedDependencies(failedTasks); len(failed) > 0 && !task.RunOnUpstreamFailure {
+			blocked = append(blocked, newTaskDecision(task, BlockReasonUpstreamFailed,
+				fmt.Sprintf("will be skipped, %s did not succeed", strings.Join(failed, ", "))))
+			continue
+		}
+
+		satisfied := completedTasks
+		if task.RunOnUpstreamFailure {
+			satisfied = finishedTasks
+		}
+
+		if !task.CanExecute(satisfied) {
+			unmet := task.UnmetDependencies(satisfied)
+			blocked = append(blocked, newTaskDecision(task, BlockReasonUnmetDependency,
+				fmt.Sprintf("waiting on %s", strings.Join(unmet, ", "))))
+			continue
+		}
+
+		paused, ok := pausedTypes[task.Type]
+		if !ok {
+			var err error
+			paused, err = s.queue.IsQueuePaused(ctx, task.Type)
+			if err != nil {
+				s.logger.Errorf("Failed to check pause state for task type %s: %v", task.Type, err)
+			}
+			pausedTypes[task.Type] = paused
+		}
+		if paused {
+			blocked = append(blocked, newTaskDecision(task, BlockReasonQueuePaused,
+				fmt.Sprintf("queue for task type %s is paused", task.Type)))
+			continue
+		}
+
+		if workflow.Config.MaxConcurrency > 0 && inFlight >= workflow.Config.MaxConcurrency {
+			blocked = append(blocked, newTaskDecision(task, BlockReasonConcurrencyLimit,
+				fmt.Sprintf("%d of %d workflow slots in use", inFlight, workflow.Config.MaxConcurrency)))
+			continue
+		}
+
+		if limit := workflow.Config.GroupLimit(task.Group); limit > 0 && groupInFlight[task.Group] >= limit {
+			blocked = append(blocked, newTaskDecision(task, BlockReasonGroupConcurrencyLimit,
+				fmt.Sprintf("%d of %d slots of group %s in use", groupInFlight[task.Group], limit, task.Group)))
+			continue
+		}
+
+		inFlight++
+		groupInFlight[task.Group]++
+		dispatch = append(dispatch, *task)
+	}
+
+	return dispatch, blocked
+}
+
+// limitDispatchRate holds back the tasks, in dispatch order, that would
+// exceed the rate limit of their type where it is enforced at enqueue. The
+// tasks it lets through are logged against their limits unless dryRun is
+// set, in which case it only reads how much of each limit is left.
+func (s *Scheduler) limitDispatchRate(ctx context.Context, tasks []Task, dryRun bool) ([]Task, []TaskDecision) {
+	if len(tasks) == 0 {
+		return tasks, nil
+	}
+
+	limits, err := s.queue.GetTaskRateLimits(ctx)
+	if err != nil {
+		s.logger.Errorf("Failed to get task rate limits: %v", err)
+		return tasks, nil
+	}
+
+	wanted := make(map[string]int)
+	for i := range tasks {
+		if limit, ok := limits[tasks[i].Type]; ok && limit.EnforceAt == RateLimitAtEnqueue {
+			wanted[tasks[i].Type]++
+		}
+	}
+	if len(wanted) == 0 {
+		return tasks, nil
+	}
+
+	// A limit that cannot be read holds its tasks back, since letting them
+	// all through is what the limit is there to prevent.
+	allowed := make(map[string]int, len(wanted))
+	for taskType, n := range wanted {
+		var granted int
+		if dryRun {
+			granted, err = s.queue.AvailableRateTokens(ctx, limits[taskType])
+		} else {
+			granted, err = s.queue.TakeRateTokens(ctx, limits[taskType], n)
+		}
+		if err != nil {
+			s.logger.Errorf("Failed to apply rate limit of task type %s: %v", taskType, err)
+		}
+		allowed[taskType] = granted
+	}
+
+	dispatch := make([]Task, 0, len(tasks))
+	var blocked []TaskDecision
+	for i := range tasks {
+		limit, limited := limits[tasks[i].Type]
+		if !limited || limit.EnforceAt != RateLimitAtEnqueue {
+			dispatch = append(dispatch, tasks[i])
+			continue
+		}
+		if allowed[tasks[i].Type] > 0 {
+			allowed[tasks[i].Type]--
+			dispatch = append(dispatch, tasks[i])
+			continue
+		}
+		blocked = append(blocked, newTaskDecision(&tasks[i], BlockReasonRateLimited,
+			fmt.Sprintf("task type %s is limited to %s", tasks[i].Type, limit.String())))
+	}
+	return dispatch, blocked
+}
+
+// limitQueueLength holds back the tasks, in dispatch order, that would take
+// their type's queue past its length limit, so they wait in Postgres until
+// workers drain the queue.
+func (s *Scheduler) limitQueueLength(ctx context.Context, tasks []Task) ([]Task, []TaskDecision) {
+	if len(tasks) == 0 {
+		return tasks, nil
+	}
+
+	limits, err := s.queue.GetQueueLengthLimits(ctx)
+	if err != nil {
+		s.logger.Errorf("Failed to get queue length limits: %v", err)
+		return tasks, nil
+	}
+	if len(limits) == 0 {
+		return tasks, nil
+	}
+
+	// A queue whose length cannot be read holds its tasks back, since
+	// letting them all through is what the limit is there to prevent.
+	room := make(map[string]int64)
+	for i := range tasks {
+		limit, ok := limits[tasks[i].Type]
+		if !ok {
+			continue
+		}
+		if _, measured := room[limit.TaskType]; measured {
+			continue
+		}
+		length, err := s.queue.GetQueueLength(ctx, limit.TaskType)
+		if err != nil {
+			s.logger.Errorf("Failed to get queue length of task type %s: %v", limit.TaskType, err)
+			length = limit.MaxLength
+		}
+		room[limit.TaskType] = limit.MaxLength - length
+	}
+
+	dispatch := make([]Task, 0, len(tasks))
+	var blocked []TaskDecision
+	for i := range tasks {
+		limit, limited := limits[tasks[i].Type]
+		if !limited {
+			dispatch = append(dispatch, tasks[i])
+			continue
+		}
+		if room[tasks[i].Type] > 0 {
+			room[tasks[i].Type]--
+			dispatch = append(dispatch, tasks[i])
+			continue
+		}
+		blocked = append(blocked, newTaskDecision(&tasks[i], BlockReasonQueueFull,
+			fmt.Sprintf("queue of task type %s is full at %d tasks", tasks[i].Type, limit.MaxLength)))
+	}
+	return dispatch, blocked
+}
+
+func (s *Scheduler) DryRun(ctx context.Context) (*DispatchReport, error) {
+	tasks, err := s.store.GetPendingTasks()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending tasks: %w", err)
+	}
+
+	var workflowIDs []string
+	workflowTasks := make(map[string][]Task)
+	for _, task := range tasks {
+		if _, ok := workflowTasks[task.WorkflowID]; !ok {
+			workflowIDs = append(workflowIDs, task.WorkflowID)
+		}
+		workflowTasks[task.WorkflowID] = append(workflowTasks[task.WorkflowID], task)
+	}
+
+	report := &DispatchReport{
+		GeneratedAt: time.Now(),
+		Dispatch:    []TaskDecision{},
+		Blocked:     []TaskDecision{},
+	}
+
+	var ready []Task
+	for _, workflowID := range workflowIDs {
+		workflow, err := s.store.GetWorkflow(workflowID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get workflow: %w", err)
+		}
+
+		if workflow.Status != WorkflowStatusPending && workflow.Status != WorkflowStatusRunning {
+			for i := range workflowTasks[workflowID] {
+				report.Blocked = append(report.Blocked, newTaskDecision(&workflowTasks[workflowID][i], BlockReasonWorkflowInactive,
+					fmt.Sprintf("workflow is %s", workflow.Status)))
+			}
+			continue
+		}
+
+		dispatch, blocked := s.planWorkflowTasks(ctx, workflow, workflowTasks[workflowID])
+		ready = append(ready, dispatch...)
+		report.Blocked = append(report.Blocked, blocked...)
+	}
+
+	dispatch, full := s.limitQueueLength(ctx, ready)
+	report.Blocked = append(report.Blocked, full...)
+
+	dispatch, limited := s.limitDispatchRate(ctx, dispatch, true)
+	for i := range dispatch {
+		report.Dispatch = append(report.Dispatch, newTaskDecision(&dispatch[i], "", ""))
+	}
+	report.Blocked = append(report.Blocked, limited...)
+
+	return report, nil
+}
+
+// sampleSchedulingPass starts the record of a scheduling pass that the
+// decision log samples, returning nil when the pass is not to be recorded.
+func (s *Scheduler) sampleSchedulingPass(ctx context.Context, considered int) (*SchedulingPass, time.Duration) {
+	config, err := s.queue.GetDecisionLogConfig(ctx)
+	if err != nil {
+		s.logger.Errorf("Failed to get decision log config: %v", err)
+		return nil, 0
+	}
+	if config == nil || !config.Sampled() {
+		return nil, 0
+	}
+
+	pass := &SchedulingPass{
+		DispatchReport: DispatchReport{
+			GeneratedAt: time.Now(),
+			Dispatch:    []TaskDecision{},
+			Blocked:     []TaskDecision{},
+		},
+		SchedulerID: s.instanceID,
+		Considered:  considered,
+	}
+	return pass, config.Retention
+}
+
+func (s *Scheduler) recordSchedulingPass(ctx context.Context, pass *SchedulingPass, retention time.Duration) {
+	pass.Duration = time.Since(pass.GeneratedAt)
+	if err := s.queue.RecordSchedulingPass(ctx, pass, retention); err != nil {
+		s.logger.Errorf("Failed to record scheduling pass: %v", err)
+	}
+}
+
+func (s *Scheduler) SetDecisionLogConfig(ctx context.Context, config DecisionLogConfig) error {
+	return s.queue.SetDecisionLogConfig(ctx, config)
+}
+
+func (s *Scheduler) DeleteDecisionLogConfig(ctx context.Context) error {
+	return s.queue.DeleteDecisionLogConfig(ctx)
+}
+
+func (s *Scheduler) GetDecisionLogConfig(ctx context.Context) (*DecisionLogConfig, error) {
+	return s.queue.GetDecisionLogConfig(ctx)
+}
+
+func (s *Scheduler) GetSchedulingPasses(ctx context.Context, since time.Time, limit int64) ([]SchedulingPass, error) {
+	return s.queue.GetSchedulingPasses(ctx, since, limit)
+}
+
+func (s *Scheduler) PauseQueue(ctx context.Context, taskType string) error {
+	return s.queue.PauseQueue(ctx, taskType)
+}
+
+func (s *Scheduler) ResumeQueue(ctx context.Context, taskType string) error {
+	return s.queue.ResumeQueue(ctx, taskType)
+}
+
+type DependencyState struct {
+	Name   string     `json:"name"`
+	Status TaskStatus `json:"status"`
+}
+
+type RetryState struct {
+	RetryCount  int        `json:"retry_count"`
+	MaxRetries  int        `json:"max_retries"`
+	NextRetryAt *time.Time `json:"next_retry_at,omitempty"`
+}
+
+type WorkerAvailability struct {
+	Compatible int  `json:"compatible"`
+	Alive      bool `json:"alive"`
+	// Fit counts the compatible workers whose capacity could hold the
+	// task's resources.
+	Fit int `json:"fit"`
+}
+
+type TaskExplanation struct {
+	TaskID              string             `json:"task_id"`
+	TaskName            string             `json:"task_name"`
+	Status              TaskStatus         `json:"status"`
+	WorkflowStatus      WorkflowStatus     `json:"workflow_status"`
+	Dispatchable        bool               `json:"dispatchable"`
+	Reason              BlockReason        `json:"reason,omitempty"`
+	Summary             string             `json:"summary"`
+	UnmetDependencies   []DependencyState  `json:"unmet_dependencies"`
+	QueuePaused         bool               `json:"queue_paused"`
+	MaxConcurrency      int                `json:"max_concurrency"`
+	InFlight            int                `json:"in_flight"`
+	Group               string             `json:"group,omitempty"`
+	GroupMaxConcurrency int                `json:"group_max_concurrency,omitempty"`
+	GroupInFlight       int                `json:"group_in_flight,omitempty"`
+	RateLimit           *TaskRateLimit     `json:"rate_limit,omitempty"`
+	Retry               RetryState         `json:"retry"`
+	Workers             WorkerAvailability `json:"workers"`
+}
+
+func (s *Scheduler) ExplainTask(ctx context.Context, taskID string) (*TaskExplanation, error) {
+	task, err := s.store.GetTask(taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task: %w", err)
+	}
+
+	workflow, err := s.store.GetWorkflow(task.WorkflowID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get workflow: %w", err)
+	}
+
+	explanation := &TaskExplanation{
+		TaskID:              task.ID,
+		TaskName:            task.Name,
+		Status:              task.Status,
+		WorkflowStatus:      workflow.Status,
+		UnmetDependencies:   []DependencyState{},
+		MaxConcurrency:      workflow.Config.MaxConcurrency,
+		Group:               task.Group,
+		GroupMaxConcurrency: workflow.Config.GroupLimit(task.Group),
+		Retry: RetryState{
+			RetryCount: task.RetryCount,
+			MaxRetries: task.MaxRetries,
+		},
+	}
+
+	tasksByRef := make(map[string]Task)
+	var pending []Task
+	for _, t := range workflow.Tasks {
+		tasksByRef[t.ID] = t
+		tasksByRef[t.Name] = t
+		switch t.Status {
+		case TaskStatusQueued, TaskStatusRunning:
+			explanation.InFlight++
+			if task.Group != "" && t.Group == task.Group {
+				explanation.GroupInFlight++
+			}
+		case TaskStatusPending:
+			pending = append(pending, t)
+		}
+	}
+
+	for _, dep := range task.Dependencies {
+		depTask, ok := tasksByRef[dep]
+		if !ok {
+			explanation.UnmetDependencies = append(explanation.UnmetDependencies, DependencyState{Name: dep})
+			continue
+		}
+		if depTask.Status != TaskStatusCompleted && !(task.RunOnUpstreamFailure && depTask.Status.IsTerminal()) {
+			explanation.UnmetDependencies = append(explanation.UnmetDependencies, DependencyState{Name: dep, Status: depTask.Status})
+		}
+	}
+
+	explanation.QueuePaused, err = s.queue.IsQueuePaused(ctx, task.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	explanation.RateLimit, err = s.queue.GetTaskRateLimit(ctx, task.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	explanation.Retry.NextRetryAt, err = s.queue.GetRetryTime(ctx, task)
+	if err != nil {
+		return nil, err
+	}
+
+	workers, err := s.queue.GetActiveWorkers(ctx, task.Type)
+	if err != nil {
+		return nil, err
+	}
+	// A pinned task can only run on workers of its pool.
+	if task.Pool != "" {
+		pooled := workers[:0]
+		for _, worker := range workers {
+			if worker.Pool == task.Pool {
+				pooled = append(pooled, worker)
+			}
+		}
+		workers = pooled
+	}
+	explanation.Workers = WorkerAvailability{Compatible: len(workers), Alive: len(workers) > 0}
+	for _, worker := range workers {
+		if worker.Fits(task.Resources) {
+			explanation.Workers.Fit++
+		}
+	}
+
+	switch {
+	case workflow.Status != WorkflowStatusPending && workflow.Status != WorkflowStatusRunning:
+		explanation.Reason = BlockReasonWorkflowInactive
+		explanation.Summary = fmt.Sprintf("workflow is %s", workflow.Status)
+	case !task.Status.IsTerminal() && task.Status != TaskStatusRunning && task.Expired(time.Now()):
+		explanation.Summary = fmt.Sprintf("task expired at %s and will be dropped without running", task.ExpiresAt.Format(time.RFC3339))
+	case task.Status == TaskStatusQueued && task.RunAt != nil && task.RunAt.After(time.Now()):
+		explanation.Summary = fmt.Sprintf("task is delayed until its run_at, %s", task.RunAt.Format(time.RFC3339))
+	case task.Status == TaskStatusQueued && explanation.Workers.Alive && explanation.Workers.Fit == 0:
+		explanation.Summary = "task is queued but requests more resources than the capacity of any live worker"
+	case task.Status == TaskStatusQueued:
+		explanation.Summary = "task is queued and waiting for a worker"
+	case task.Status == TaskStatusRunning && task.Type == WaitTaskType && task.StartedAt != nil:
+		if until, err := WaitUntil(task.Payload, *task.StartedAt); err == nil {
+			explanation.Summary = fmt.Sprintf("task is waiting until %s", until.Format(time.RFC3339))
+		} else {
+			explanation.Summary = "task is waiting"
+		}
+	case task.Status == TaskStatusRunning && task.Type == ApprovalTaskType:
+		explanation.Summary = "task is waiting for an operator to approve or reject it"
+	case task.Status == TaskStatusRunning:
+		explanation.Summary = "task is running"
+	case task.Status == TaskStatusRetrying:
+		explanation.Summary = "task is waiting to be retried"
+	case task.Status != TaskStatusPending:
+		explanation.Summary = fmt.Sprintf("task is %s", task.Status)
+	default:
+		sort.SliceStable(pending, func(i, j int) bool {
+			if pending[i].Priority != pending[j].Priority {
+				return pending[i].Priority > pending[j].Priority
+			}
+			return pending[i].Order < pending[j].Order
+		})
+
+		dispatch, blocked := s.planWorkflowTasks(ctx, workflow, pending)
+		dispatch, full := s.limitQueueLength(ctx, dispatch)
+		blocked = append(blocked, full...)
+		dispatch, limited := s.limitDispatchRate(ctx, dispatch, true)
+		blocked = append(blocked, limited...)
+		for _, t := range dispatch {
+			if t.ID == task.ID {
+				explanation.Dispatchable = true
+				explanation.Summary = "task will be dispatched on the next scheduling pass"
+			}
+		}
+		for _, decision := range blocked {
+			if decision.TaskID == task.ID {
+				explanation.Reason = decision.Reason
+				explanation.Summary = decision.Detail
+			}
+		}
+	}
+
+	if explanation.Retry.NextRetryAt != nil {
+		explanation.Summary = fmt.Sprintf("%s; next retry at %s", explanation.Summary, explanation.Retry.NextRetryAt.Format(time.RFC3339))
+	}
+	if !explanation.Workers.Alive && task.Status != TaskStatusCompleted {
+		explanation.Summary = fmt.Sprintf("%s; no live worker handles task type %s", explanation.Summary, task.Type)
+	}
+
+	return explanation, nil
+}
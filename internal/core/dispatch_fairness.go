@@ -0,0 +1,49 @@
+package core
+
+import "fmt"
+
+// DispatchFairnessMode decides how workers interleave the retries of a task
+// type, which wait in its retry lane once due, with the type's new tasks.
+type DispatchFairnessMode string
+
+const (
+	// FairnessShared hands out retries and new tasks together, by priority
+	// and then by when they were queued. It is the default.
+	FairnessShared DispatchFairnessMode = "shared"
+	// FairnessWeighted gives retries RetryShare percent of the tasks handed
+	// out while both retries and new tasks are waiting.
+	FairnessWeighted DispatchFairnessMode = "weighted"
+	// FairnessFreshFirst only hands out a retry when no new task of the
+	// type is waiting.
+	FairnessFreshFirst DispatchFairnessMode = "fresh_first"
+)
+
+func (m DispatchFairnessMode) Valid() bool {
+	switch m {
+	case FairnessShared, FairnessWeighted, FairnessFreshFirst:
+		return true
+	}
+	return false
+}
+
+// DispatchFairnessPolicy keeps a retry storm of a task type from starving
+// its new submissions. Either lane gets the whole queue while the other is
+// empty, so no worker sits idle because of the policy.
+type DispatchFairnessPolicy struct {
+	TaskType   string               `json:"task_type"`
+	Mode       DispatchFairnessMode `json:"mode"`
+	RetryShare int                  `json:"retry_share,omitempty"`
+}
+
+func (p *DispatchFairnessPolicy) Validate() error {
+	if !p.Mode.Valid() {
+		return fmt.Errorf("mode must be one of %s, %s, %s", FairnessShared, FairnessWeighted, FairnessFreshFirst)
+	}
+	if p.Mode == FairnessWeighted && (p.RetryShare < 1 || p.RetryShare > 99) {
+		return fmt.Errorf("retry_share must be between 1 and 99")
+	}
+	if p.Mode != FairnessWeighted && p.RetryShare != 0 {
+		return fmt.Errorf("retry_share only applies to the %s mode", FairnessWeighted)
+	}
+	return nil
+}
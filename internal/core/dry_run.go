@@ -0,0 +1,26 @@
+package core
+
+// MockExecutor is recorded as the executor of task attempts completed by a
+// dry run.
+const MockExecutor = "mock"
+
+// DryRunConfig runs a workflow without sending its tasks to workers. The
+// scheduler walks the DAG in dependency order and completes every task with
+// a canned result, so dependency wiring and payload templating can be
+// checked end to end without side effects.
+type DryRunConfig struct {
+	// Results holds the canned result of each task type. A type without
+	// one returns the task's rendered payload.
+	Results map[string]map[string]interface{} `json:"results,omitempty" yaml:"results,omitempty"`
+}
+
+// MockResult returns the canned result of a task.
+func (c *DryRunConfig) MockResult(task *Task) map[string]interface{} {
+	if result, ok := c.Results[task.Type]; ok {
+		return result
+	}
+	return map[string]interface{}{
+		"dry_run": true,
+		"payload": task.Payload,
+	}
+}
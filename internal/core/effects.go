@@ -0,0 +1,26 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// ErrEffectInProgress is returned when another delivery of the same task is
+// already performing a side effect.
+var ErrEffectInProgress = errors.New("effect already in progress")
+
+// effectNamespace scopes effect tokens so they never collide with other
+// name-based UUIDs.
+var effectNamespace = uuid.MustParse("6f1c3a52-8d0e-4b7a-9e57-2c4f0d9b8a13")
+
+// EffectToken returns the token of a named side effect of a task. It is the
+// same for every delivery and every attempt of the task, so a handler can
+// pass it as the idempotency key of an external call, such as a payment,
+// and record the effect's completion under it: an effect that completed
+// before a retry is not performed again by the retry.
+func EffectToken(task *Task, effect string) string {
+	name := fmt.Sprintf("%s/%s", task.ID, effect)
+	return uuid.NewSHA1(effectNamespace, []byte(name)).String()
+}
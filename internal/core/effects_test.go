@@ -0,0 +1,19 @@
+package core
+
+import "testing"
+
+func TestEffectTokenIsStableAcrossRetries(t *testing.T) {
+	task := &Task{ID: "task-1"}
+	first := EffectToken(task, "charge")
+
+	task.RetryCount = 2
+	if got := EffectToken(task, "charge"); got != first {
+		t.Errorf("EffectToken changed on retry: %s, was %s", got, first)
+	}
+	if EffectToken(task, "refund") == first {
+		t.Error("different effects of a task share a token")
+	}
+	if EffectToken(&Task{ID: "task-2"}, "charge") == first {
+		t.Error("different tasks share a token")
+	}
+}
@@ -0,0 +1,29 @@
+package core
+
+import "time"
+
+// ExecutionEnvironment fingerprints where one attempt of a task ran, as
+// reported by the worker when it starts the attempt. Comparing the
+// environments of failed and successful attempts shows whether failures
+// follow a host, pool or handler version.
+type ExecutionEnvironment struct {
+	Attempt        int       `json:"attempt"`
+	WorkerID       string    `json:"worker_id"`
+	Hostname       string    `json:"hostname,omitempty"`
+	Pool           string    `json:"pool,omitempty"`
+	HandlerVersion string    `json:"handler_version,omitempty"`
+	Executor       string    `json:"executor,omitempty"`
+	StartedAt      time.Time `json:"started_at"`
+}
+
+// LatestAttempt returns the number of the latest attempt of the task a
+// worker reported starting, or 0 if none has been.
+func (t *Task) LatestAttempt() int {
+	latest := 0
+	for _, env := range t.Attempts {
+		if env.Attempt > latest {
+			latest = env.Attempt
+		}
+	}
+	return latest
+}
@@ -0,0 +1,132 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type EventType string
+
+const (
+	EventWorkflowCreated   EventType = "workflow.created"
+	EventWorkflowStarted   EventType = "workflow.started"
+	EventWorkflowCompleted EventType = "workflow.completed"
+	EventWorkflowFailed    EventType = "workflow.failed"
+	EventWorkflowCancelled EventType = "workflow.cancelled"
+	EventTaskPending       EventType = "task.pending"
+	EventTaskQueued        EventType = "task.queued"
+	EventTaskStarted       EventType = "task.started"
+	EventTaskCompleted     EventType = "task.completed"
+	EventTaskFailed        EventType = "task.failed"
+	EventTaskRetrying      EventType = "task.retrying"
+	EventTaskCancelled     EventType = "task.cancelled"
+	EventTaskSkipped       EventType = "task.skipped"
+	EventTaskQuarantined   EventType = "task.quarantined"
+	EventScheduleSkipped   EventType = "schedule.skipped"
+	EventScheduleShifted   EventType = "schedule.shifted"
+	EventQueuePeeked       EventType = "queue.peeked"
+	EventQueueInjected     EventType = "queue.injected"
+	// EventBrokerEvictionUnsafe is published when Redis is found to run
+	// under a maxmemory-policy that can evict queued tasks, and
+	// EventBrokerKeysEvicted when Redis has evicted keys since the last
+	// check.
+	EventBrokerEvictionUnsafe EventType = "broker.eviction_unsafe"
+	EventBrokerKeysEvicted    EventType = "broker.keys_evicted"
+)
+
+type Event struct {
+	ID         string                 `json:"id,omitempty"`
+	Type       EventType              `json:"type"`
+	WorkflowID string                 `json:"workflow_id"`
+	TaskID     string                 `json:"task_id,omitempty"`
+	Status     string                 `json:"status"`
+	Error      string                 `json:"error,omitempty"`
+	Timestamp  time.Time              `json:"timestamp"`
+	Data       map[string]interface{} `json:"data,omitempty"`
+}
+
+func NewWorkflowEvent(workflowID string, status WorkflowStatus, errorMsg string) *Event {
+	eventType := EventType(fmt.Sprintf("workflow.%s", status))
+	if status == WorkflowStatusPending {
+		eventType = EventWorkflowCreated
+	} else if status == WorkflowStatusRunning {
+		eventType = EventWorkflowStarted
+	}
+
+	return &Event{
+		Type:       eventType,
+		WorkflowID: workflowID,
+		Status:     string(status),
+		Error:      errorMsg,
+		Timestamp:  time.Now(),
+	}
+}
+
+func NewTaskEvent(workflowID, taskID string, status TaskStatus, errorMsg string) *Event {
+	eventType := EventType(fmt.Sprintf("task.%s", status))
+	if status == TaskStatusRunning {
+		eventType = EventTaskStarted
+	}
+
+	return &Event{
+		Type:       eventType,
+		WorkflowID: workflowID,
+		TaskID:     taskID,
+		Status:     string(status),
+		Error:      errorMsg,
+		Timestamp:  time.Now(),
+	}
+}
+
+// NewQuarantineEvent records a task moved to its poison queue. The IDs are
+// empty when the queued entry could not be decoded.
+func NewQuarantineEvent(workflowID, taskID, taskType string, deliveries int64, errorMsg string) *Event {
+	return &Event{
+		Type:       EventTaskQuarantined,
+		WorkflowID: workflowID,
+		TaskID:     taskID,
+		Status:     "quarantined",
+		Error:      errorMsg,
+		Timestamp:  time.Now(),
+		Data: map[string]interface{}{
+			"task_type":  taskType,
+			"deliveries": deliveries,
+		},
+	}
+}
+
+// NewScheduleEvent records a schedule run that its calendar skipped or
+// shifted. No workflow exists for such a run.
+func NewScheduleEvent(eventType EventType, scheduleID string, scheduledFor time.Time, data map[string]interface{}) *Event {
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	data["schedule_id"] = scheduleID
+	data["scheduled_for"] = scheduledFor
+
+	return &Event{
+		Type:      eventType,
+		Status:    strings.TrimPrefix(string(eventType), "schedule."),
+		Timestamp: time.Now(),
+		Data:      data,
+	}
+}
+
+// NewQueueAuditEvent records an admin reading or writing a queue directly.
+// Subject is whoever the request came from, as given in its subject header.
+func NewQueueAuditEvent(eventType EventType, taskType, taskID, subject string, data map[string]interface{}) *Event {
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	data["task_type"] = taskType
+	data["subject"] = subject
+
+	return &Event{
+		Type:      eventType,
+		TaskID:    taskID,
+		Status:    strings.TrimPrefix(string(eventType), "queue."),
+		Timestamp: time.Now(),
+		Data:      data,
+	}
+}
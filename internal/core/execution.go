@@ -0,0 +1,94 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const maxLabelLength = 255
+
+// ExecutionContext is what a task handler knows about the run it is part
+// of, so handlers do not have to pass workflow details through payload
+// conventions. Workers attach it to the context of every handler call;
+// read it with ExecutionContextFrom.
+type ExecutionContext struct {
+	WorkflowID   string                 `json:"workflow_id"`
+	WorkflowName string                 `json:"workflow_name"`
+	Namespace    string                 `json:"namespace"`
+	Params       map[string]interface{} `json:"params,omitempty"`
+	Labels       map[string]string      `json:"labels,omitempty"`
+	TaskID       string                 `json:"task_id"`
+	TaskName     string                 `json:"task_name"`
+	// Attempt counts from 1 and goes up with every retry.
+	Attempt int `json:"attempt"`
+	// Deadline is when the attempt times out, if the task has a timeout.
+	Deadline *time.Time `json:"deadline,omitempty"`
+}
+
+// NewExecutionContext describes a workflow run to the handlers of its
+// tasks. The task fields are filled in for each attempt by
+// Task.ExecutionContext.
+func NewExecutionContext(workflow *Workflow) *ExecutionContext {
+	return &ExecutionContext{
+		WorkflowID:   workflow.ID,
+		WorkflowName: workflow.Name,
+		Namespace:    workflow.Namespace,
+		Params:       workflow.Params,
+		Labels:       workflow.Labels,
+	}
+}
+
+// IdempotencyNamespace returns the namespace the task's idempotency key is
+// scoped to: that of its workflow, or DefaultNamespace for a task without a
+// run context.
+func (t *Task) IdempotencyNamespace() string {
+	if t.Execution != nil && t.Execution.Namespace != "" {
+		return t.Execution.Namespace
+	}
+	return DefaultNamespace
+}
+
+// ExecutionContext returns the context of one attempt of the task, ending
+// at deadline unless it is zero. Tasks dispatched without a run context,
+// such as tasks injected into a queue, get one with only the task fields.
+func (t *Task) ExecutionContext(deadline time.Time) *ExecutionContext {
+	execution := ExecutionContext{WorkflowID: t.WorkflowID}
+	if t.Execution != nil {
+		execution = *t.Execution
+	}
+
+	execution.TaskID = t.ID
+	execution.TaskName = t.Name
+	execution.Attempt = t.RetryCount + 1
+	if !deadline.IsZero() {
+		execution.Deadline = &deadline
+	}
+	return &execution
+}
+
+type executionContextKey struct{}
+
+// WithExecutionContext returns a copy of ctx carrying execution.
+func WithExecutionContext(ctx context.Context, execution *ExecutionContext) context.Context {
+	return context.WithValue(ctx, executionContextKey{}, execution)
+}
+
+// ExecutionContextFrom returns the execution context a worker attached to
+// a handler's ctx.
+func ExecutionContextFrom(ctx context.Context) (*ExecutionContext, bool) {
+	execution, ok := ctx.Value(executionContextKey{}).(*ExecutionContext)
+	return execution, ok
+}
+
+func validateLabels(labels map[string]string) error {
+	for key, value := range labels {
+		if key == "" || len(key) > maxLabelLength {
+			return fmt.Errorf("label name must be 1 to %d characters", maxLabelLength)
+		}
+		if len(value) > maxLabelLength {
+			return fmt.Errorf("label %s: value is longer than %d characters", key, maxLabelLength)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	// taskExpiryInterval is how often tasks left waiting past their
+	// expires_at are dropped, and so roughly how long past it one may wait.
+	taskExpiryInterval = time.Second * 15
+	// taskExpiryBatchSize bounds how many tasks one sweep expires.
+	taskExpiryBatchSize = 500
+)
+
+// expireStaleTasks drops tasks still pending, queued or waiting to retry
+// once their ExpiresAt has passed, so time-sensitive work is not run long
+// after it stopped mattering.
+func (s *Scheduler) expireStaleTasks(ctx context.Context) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(taskExpiryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			passCtx, leading := s.leaderContext()
+			if !leading {
+				continue
+			}
+			if err := s.sweepExpiredTasks(passCtx); err != nil {
+				s.logger.Errorf("Failed to expire stale tasks: %v", err)
+			}
+		}
+	}
+}
+
+// sweepExpiredTasks takes expired tasks off their queues, delayed and retry
+// sets, marks them expired and settles their workflows. A worker that has
+// dequeued one but not yet reported it running is told to stop.
+func (s *Scheduler) sweepExpiredTasks(ctx context.Context) error {
+	tasks, err := s.store.GetExpiredTasks(s.clock(), taskExpiryBatchSize)
+	if err != nil {
+		return fmt.Errorf("failed to get expired tasks: %w", err)
+	}
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	_, leased, err := s.queue.PurgeTasks(ctx, tasks)
+	for _, leasedID := range leased {
+		if err := s.queue.PublishCancellation(ctx, leasedID); err != nil {
+			s.logger.Errorf("Failed to cancel expired task %s: %v", leasedID, err)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to remove expired tasks from queues: %w", err)
+	}
+
+	workflows := make(map[string]bool)
+	for i := range tasks {
+		if err := s.markTaskExpired(ctx, &tasks[i]); err != nil {
+			s.logger.Errorf("Failed to expire task %s: %v", tasks[i].ID, err)
+			continue
+		}
+		workflows[tasks[i].WorkflowID] = true
+	}
+
+	for workflowID := range workflows {
+		if err := s.settleWorkflow(ctx, workflowID); err != nil {
+			s.logger.Errorf("Failed to settle workflow %s: %v", workflowID, err)
+		}
+	}
+
+	s.logger.Warnf("Expired %d tasks left waiting past their expires_at", len(tasks))
+	return nil
+}
+
+// expireTask marks a pending task found expired on dispatch, which has never
+// been queued, expired and settles its workflow.
+func (s *Scheduler) expireTask(ctx context.Context, task *Task) error {
+	if err := s.markTaskExpired(ctx, task); err != nil {
+		return err
+	}
+
+	if err := s.settleWorkflow(ctx, task.WorkflowID); err != nil {
+		s.logger.Errorf("Failed to settle workflow %s: %v", task.WorkflowID, err)
+	}
+
+	s.logger.Warnf("Task %s of workflow %s expired at %s before it was dispatched", task.ID, task.WorkflowID, task.ExpiresAt.Format(time.RFC3339))
+	return nil
+}
+
+func (s *Scheduler) markTaskExpired(ctx context.Context, task *Task) error {
+	errorMsg := fmt.Sprintf("expired at %s before it ran", task.ExpiresAt.Format(time.RFC3339))
+	if err := s.setTaskStatus(ctx, task.WorkflowID, task.ID, TaskStatusExpired, nil, errorMsg); err != nil {
+		return err
+	}
+	s.recordTaskStatus(task, TaskStatusExpired)
+	return nil
+}
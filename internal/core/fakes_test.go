@@ -0,0 +1,585 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// fakeBroker is a Broker kept in memory for scheduler tests. Methods a test
+// does not expect to be called are left to the embedded nil interface, so
+// calling one panics.
+type fakeBroker struct {
+	Broker
+
+	mu       sync.Mutex
+	paused   map[string]bool
+	types    []string
+	enqueued []Task
+	events   []Event
+	policies map[string]*SandboxPolicy
+	dedupe   map[string]string
+	retried  []string
+	timers   map[string]bool
+
+	results map[string]map[string]interface{}
+
+	webhooks    map[string]*Webhook
+	webhookErr  error
+	deliveries  []WebhookDelivery
+	scheduleErr error
+	completed   []string
+
+	workers         []WorkerInfo
+	selectorBacklog map[string]int64
+
+	// staleWorkerTasks is what ExpireStaleWorkers returns, once.
+	staleWorkerTasks []Task
+
+	purged    []string
+	cancelled []string
+
+	// rateTokens is how many more dispatches each type's rate limit allows.
+	rateLimits map[string]TaskRateLimit
+	rateTokens map[string]int
+
+	// deadLetterPurges is what PurgeDeadLetters returns for each type, as
+	// entries purged for their age and for the size cap; deadLetterErr is
+	// its error.
+	deadLetterPurges map[string][2]int64
+	deadLetterErr    error
+}
+
+func newFakeBroker() *fakeBroker {
+	return &fakeBroker{paused: make(map[string]bool)}
+}
+
+func (b *fakeBroker) PauseQueue(ctx context.Context, taskType string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.paused[taskType] = true
+	return nil
+}
+
+func (b *fakeBroker) ResumeQueue(ctx context.Context, taskType string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.paused, taskType)
+	return nil
+}
+
+func (b *fakeBroker) IsQueuePaused(ctx context.Context, taskType string) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.paused[taskType], nil
+}
+
+func (b *fakeBroker) GetTaskTypes(ctx context.Context) ([]string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.types...), nil
+}
+
+// EnqueueTasks leaves out tasks that cannot be serialized, as RedisQueue
+// does.
+func (b *fakeBroker) EnqueueTasks(ctx context.Context, tasks []*Task) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var unserializable *UnserializableTasksError
+	for _, task := range tasks {
+		if _, err := task.ToJSON(); err != nil {
+			if unserializable == nil {
+				unserializable = &UnserializableTasksError{Errors: make(map[string]error)}
+			}
+			unserializable.Errors[task.ID] = err
+			continue
+		}
+		b.enqueued = append(b.enqueued, *task)
+	}
+	if unserializable != nil {
+		return unserializable
+	}
+	return nil
+}
+
+func (b *fakeBroker) PublishEvent(ctx context.Context, event *Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.events = append(b.events, *event)
+	return nil
+}
+
+func (b *fakeBroker) PublishEvents(ctx context.Context, events []*Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, event := range events {
+		b.events = append(b.events, *event)
+	}
+	return nil
+}
+
+func (b *fakeBroker) GetQueueStats(ctx context.Context, taskType string) (map[string]int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var pending int64
+	for _, n := range b.selectorBacklog {
+		pending += n
+	}
+	return map[string]int64{"pending": pending}, nil
+}
+
+func (b *fakeBroker) GetOldestEnqueuedAt(ctx context.Context, taskType string) (*time.Time, error) {
+	return nil, nil
+}
+
+func (b *fakeBroker) GetActiveWorkers(ctx context.Context, taskType string) ([]WorkerInfo, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]WorkerInfo(nil), b.workers...), nil
+}
+
+func (b *fakeBroker) ListWorkers(ctx context.Context) ([]WorkerInfo, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]WorkerInfo(nil), b.workers...), nil
+}
+
+func (b *fakeBroker) GetSelectorBacklog(ctx context.Context, taskType string) (map[string]int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	backlog := make(map[string]int64, len(b.selectorBacklog))
+	for selector, n := range b.selectorBacklog {
+		backlog[selector] = n
+	}
+	return backlog, nil
+}
+
+func (b *fakeBroker) ExpireStaleWorkers(ctx context.Context) (int, []Task, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	tasks := b.staleWorkerTasks
+	b.staleWorkerTasks = nil
+	return len(tasks), tasks, nil
+}
+
+// PurgeTasks reports the running tasks among those purged as leased.
+func (b *fakeBroker) PurgeTasks(ctx context.Context, tasks []Task) (int, []string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var leased []string
+	for _, task := range tasks {
+		b.purged = append(b.purged, task.ID)
+		if task.Status == TaskStatusRunning {
+			leased = append(leased, task.ID)
+		}
+	}
+	return len(tasks), leased, nil
+}
+
+func (b *fakeBroker) PublishCancellation(ctx context.Context, taskID string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.cancelled = append(b.cancelled, taskID)
+	return nil
+}
+
+func (b *fakeBroker) GetSandboxPolicy(ctx context.Context, namespace string) (*SandboxPolicy, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.policies[namespace], nil
+}
+
+func (b *fakeBroker) GetTaskRateLimits(ctx context.Context) (map[string]TaskRateLimit, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.rateLimits, nil
+}
+
+func (b *fakeBroker) TakeRateTokens(ctx context.Context, limit TaskRateLimit, n int) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	granted := b.rateTokens[limit.TaskType]
+	if granted > n {
+		granted = n
+	}
+	b.rateTokens[limit.TaskType] -= granted
+	return granted, nil
+}
+
+func (b *fakeBroker) AvailableRateTokens(ctx context.Context, limit TaskRateLimit) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.rateTokens[limit.TaskType], nil
+}
+
+func (b *fakeBroker) PurgeDeadLetters(ctx context.Context, policy DeadLetterPolicy) (int64, int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	purged := b.deadLetterPurges[policy.TaskType]
+	return purged[0], purged[1], b.deadLetterErr
+}
+
+func (b *fakeBroker) ClaimDedupeKey(ctx context.Context, fingerprint, taskID string, window time.Duration, stale string) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.dedupe == nil {
+		b.dedupe = make(map[string]string)
+	}
+	if holder, ok := b.dedupe[fingerprint]; ok && holder != stale {
+		return holder, nil
+	}
+	b.dedupe[fingerprint] = taskID
+	return taskID, nil
+}
+
+func (b *fakeBroker) ProcessRetries(ctx context.Context, taskType string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.retried = append(b.retried, taskType)
+	return nil
+}
+
+func (b *fakeBroker) GetRetryOverloadPolicies(ctx context.Context) ([]RetryOverloadPolicy, error) {
+	return nil, nil
+}
+
+func (b *fakeBroker) RecordInjectedTaskStatus(ctx context.Context, taskID string, status TaskStatus, result map[string]interface{}, errorMsg string) (bool, error) {
+	return false, nil
+}
+
+func (b *fakeBroker) GetDueTimers(ctx context.Context, now time.Time) ([]string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var ids []string
+	for id := range b.timers {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (b *fakeBroker) RemoveTimers(ctx context.Context, taskIDs ...string) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	removed := 0
+	for _, id := range taskIDs {
+		if b.timers[id] {
+			delete(b.timers, id)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+func (b *fakeBroker) GetIdempotentResult(ctx context.Context, namespace, key string) (map[string]interface{}, bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	result, ok := b.results[namespace+"/"+key]
+	return result, ok, nil
+}
+
+func (b *fakeBroker) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var webhooks []Webhook
+	for _, webhook := range b.webhooks {
+		webhooks = append(webhooks, *webhook)
+	}
+	return webhooks, nil
+}
+
+func (b *fakeBroker) GetWebhook(ctx context.Context, webhookID string) (*Webhook, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.webhooks[webhookID], b.webhookErr
+}
+
+func (b *fakeBroker) SaveWebhook(ctx context.Context, webhook *Webhook) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.webhooks == nil {
+		b.webhooks = make(map[string]*Webhook)
+	}
+	b.webhooks[webhook.ID] = webhook
+	return nil
+}
+
+func (b *fakeBroker) ScheduleWebhookDelivery(ctx context.Context, delivery *WebhookDelivery, at time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.scheduleErr != nil {
+		return b.scheduleErr
+	}
+	b.deliveries = append(b.deliveries, *delivery)
+	return nil
+}
+
+func (b *fakeBroker) CompleteWebhookDelivery(ctx context.Context, delivery *WebhookDelivery) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.completed = append(b.completed, delivery.Claim)
+	return nil
+}
+
+func (b *fakeBroker) RecordWebhookAttempt(ctx context.Context, attempt *WebhookAttempt) error {
+	return nil
+}
+
+// fakeStore is a Store kept in memory for scheduler tests, like fakeBroker.
+type fakeStore struct {
+	Store
+
+	mu        sync.Mutex
+	workflows map[string]*Workflow
+	tasks     map[string]*Task
+	keys      map[string]string
+	// attempts holds the IDs of the tasks attempts were recorded for, in
+	// order.
+	attempts  []string
+	schedules map[string]*Schedule
+	// created holds the IDs of the workflows submitted, in order.
+	created []string
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		workflows: make(map[string]*Workflow),
+		tasks:     make(map[string]*Task),
+		schedules: make(map[string]*Schedule),
+	}
+}
+
+// add stores a workflow and its tasks.
+func (st *fakeStore) add(workflow *Workflow) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	stored := *workflow
+	stored.Tasks = nil
+	st.workflows[workflow.ID] = &stored
+	for i := range workflow.Tasks {
+		task := workflow.Tasks[i]
+		st.tasks[task.ID] = &task
+	}
+}
+
+func (st *fakeStore) GetWorkflow(id string) (*Workflow, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	workflow, ok := st.workflows[id]
+	if !ok {
+		return nil, fmt.Errorf("workflow not found: %s", id)
+	}
+	copied := *workflow
+	for _, task := range st.tasks {
+		if task.WorkflowID == id {
+			copied.Tasks = append(copied.Tasks, *task)
+		}
+	}
+	return &copied, nil
+}
+
+func (st *fakeStore) GetTask(id string) (*Task, error) {
+	task, err := st.FindTask(id)
+	if err == nil && task == nil {
+		return nil, fmt.Errorf("task not found: %s", id)
+	}
+	return task, err
+}
+
+func (st *fakeStore) ClaimIdempotencyKey(namespace, key, taskID, stale string) (string, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.keys == nil {
+		st.keys = make(map[string]string)
+	}
+	if holder, ok := st.keys[namespace+"/"+key]; ok && holder != stale && holder != taskID {
+		return holder, nil
+	}
+	st.keys[namespace+"/"+key] = taskID
+	return taskID, nil
+}
+
+func (st *fakeStore) WorkflowNamespace(id string) (string, bool, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	workflow, ok := st.workflows[id]
+	if !ok {
+		return "", false, nil
+	}
+	return workflow.Namespace, true, nil
+}
+
+func (st *fakeStore) FindTask(id string) (*Task, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	task, ok := st.tasks[id]
+	if !ok {
+		return nil, nil
+	}
+	copied := *task
+	return &copied, nil
+}
+
+func (st *fakeStore) GetTasksByWorkflow(workflowID string) ([]Task, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	var tasks []Task
+	for _, task := range st.tasks {
+		if task.WorkflowID == workflowID {
+			tasks = append(tasks, *task)
+		}
+	}
+	return tasks, nil
+}
+
+func (st *fakeStore) UpdateTaskStatus(id string, status TaskStatus, result map[string]interface{}, errorMsg string) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	task, ok := st.tasks[id]
+	if !ok {
+		return fmt.Errorf("task not found: %s", id)
+	}
+	task.Status = status
+	task.Result = result
+	task.Error = errorMsg
+	task.UpdatedAt = time.Now()
+	return nil
+}
+
+func (st *fakeStore) UpdateTasksStatus(ids []string, status TaskStatus) error {
+	for _, id := range ids {
+		if err := st.UpdateTaskStatus(id, status, nil, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (st *fakeStore) UpdateWorkflowStatus(id string, status WorkflowStatus) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	workflow, ok := st.workflows[id]
+	if !ok {
+		return fmt.Errorf("workflow not found: %s", id)
+	}
+	workflow.Status = status
+	return nil
+}
+
+func (st *fakeStore) FailWorkflow(id, errorMsg string) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	workflow, ok := st.workflows[id]
+	if !ok {
+		return fmt.Errorf("workflow not found: %s", id)
+	}
+	workflow.Status = WorkflowStatusFailed
+	workflow.Error = errorMsg
+	return nil
+}
+
+// RetryBudgetUsage reports every workflow as having no retry budget.
+func (st *fakeStore) RetryBudgetUsage(workflowID string) (int, int, error) {
+	return 0, 0, nil
+}
+
+func (st *fakeStore) AddTaskAttempt(id string, env ExecutionEnvironment) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	task, ok := st.tasks[id]
+	if !ok {
+		return fmt.Errorf("task not found: %s", id)
+	}
+	task.Attempts = append(task.Attempts, env)
+	st.attempts = append(st.attempts, id)
+	return nil
+}
+
+func (st *fakeStore) SetTaskDuplicateOf(id, originalID string) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	task, ok := st.tasks[id]
+	if !ok {
+		return fmt.Errorf("task not found: %s", id)
+	}
+	task.DuplicateOf = originalID
+	return nil
+}
+
+func (st *fakeStore) CreateWorkflowWithTasks(workflow *Workflow) error {
+	st.add(workflow)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.created = append(st.created, workflow.ID)
+	return nil
+}
+
+func (st *fakeStore) WorkflowExists(id string) (bool, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	_, ok := st.workflows[id]
+	return ok, nil
+}
+
+func (st *fakeStore) ExistingTaskIDs(ids []string) ([]string, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	var existing []string
+	for _, id := range ids {
+		if _, ok := st.tasks[id]; ok {
+			existing = append(existing, id)
+		}
+	}
+	return existing, nil
+}
+
+// addSchedule stores a schedule.
+func (st *fakeStore) addSchedule(schedule *Schedule) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	stored := *schedule
+	st.schedules[schedule.ID] = &stored
+}
+
+func (st *fakeStore) GetDueSchedules(now time.Time) ([]Schedule, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	var due []Schedule
+	for _, schedule := range st.schedules {
+		if schedule.Enabled && schedule.NextRunAt != nil && !schedule.NextRunAt.After(now) {
+			due = append(due, *schedule)
+		}
+	}
+	return due, nil
+}
+
+func (st *fakeStore) UpdateScheduleRun(id string, lastRunAt, nextRunAt time.Time) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	schedule, ok := st.schedules[id]
+	if !ok {
+		return fmt.Errorf("schedule not found: %s", id)
+	}
+	schedule.LastRunAt, schedule.NextRunAt = &lastRunAt, &nextRunAt
+	return nil
+}
+
+func (st *fakeStore) SetScheduleNextRun(id string, nextRunAt time.Time) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	schedule, ok := st.schedules[id]
+	if !ok {
+		return fmt.Errorf("schedule not found: %s", id)
+	}
+	schedule.NextRunAt = &nextRunAt
+	return nil
+}
+
+func newTestScheduler(store Store, broker Broker) *Scheduler {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return NewScheduler(store, broker, logger)
+}
@@ -0,0 +1,26 @@
+package core
+
+import "fmt"
+
+const maxGroupLength = 255
+
+// GroupLimit returns how many of the group's tasks may be queued or running
+// at once, 0 meaning no limit beyond the workflow's MaxConcurrency.
+func (c WorkflowConfig) GroupLimit(group string) int {
+	if group == "" {
+		return 0
+	}
+	return c.GroupConcurrency[group]
+}
+
+func validateGroupConcurrency(limits map[string]int) error {
+	for group, limit := range limits {
+		if group == "" || len(group) > maxGroupLength {
+			return fmt.Errorf("group name must be 1 to %d characters", maxGroupLength)
+		}
+		if limit < 1 {
+			return fmt.Errorf("group %s: max concurrency must be at least 1", group)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package core
+
+import (
+	"context"
+	"testing"
+)
+
+func addKeyedTask(store *fakeStore, id, namespace string) {
+	store.add(&Workflow{
+		ID:        "wf-" + id,
+		Namespace: namespace,
+		Status:    WorkflowStatusRunning,
+		Tasks: []Task{{
+			ID:             id,
+			WorkflowID:     "wf-" + id,
+			Type:           "payment",
+			Status:         TaskStatusPending,
+			IdempotencyKey: "charge-42",
+		}},
+	})
+}
+
+func prepareTask(t *testing.T, s *Scheduler, store *fakeStore, id string) bool {
+	t.Helper()
+	task, _ := store.GetTask(id)
+	ready, err := s.prepareDispatch(context.Background(), task)
+	if err != nil {
+		t.Fatalf("prepareDispatch(%s): %v", id, err)
+	}
+	return ready
+}
+
+func TestIdempotencyKeyIsScopedToNamespace(t *testing.T) {
+	store := newFakeStore()
+	addKeyedTask(store, "a", "team-a")
+	addKeyedTask(store, "b", "team-b")
+
+	s := newTestScheduler(store, newFakeBroker())
+	if !prepareTask(t, s, store, "a") || !prepareTask(t, s, store, "b") {
+		t.Error("a task was held back by the idempotency key of another namespace")
+	}
+
+	store.UpdateTaskStatus("a", TaskStatusCompleted, map[string]interface{}{"charged": true}, "")
+	addKeyedTask(store, "b2", "team-b")
+	if prepareTask(t, s, store, "b2") {
+		t.Error("a second task with a claimed key was queued")
+	}
+	if task, _ := store.GetTask("b2"); task.Status == TaskStatusCompleted {
+		t.Error("a task reused the result of another namespace's task")
+	}
+}
+
+func TestIdempotencyKeyHoldsBackTasksUntilTheHolderFinishes(t *testing.T) {
+	store := newFakeStore()
+	addKeyedTask(store, "first", "team-a")
+	addKeyedTask(store, "second", "team-a")
+
+	s := newTestScheduler(store, newFakeBroker())
+	if !prepareTask(t, s, store, "first") {
+		t.Fatal("the first task with a key was not queued")
+	}
+	if prepareTask(t, s, store, "second") {
+		t.Fatal("the second task was queued while the first runs under its key")
+	}
+	if task, _ := store.GetTask("second"); task.Status != TaskStatusPending {
+		t.Fatalf("held back task is %s, want pending", task.Status)
+	}
+
+	store.UpdateTaskStatus("first", TaskStatusCompleted, map[string]interface{}{"charged": true}, "")
+	if prepareTask(t, s, store, "second") {
+		t.Fatal("the second task was queued after the first completed")
+	}
+	task, _ := store.GetTask("second")
+	if task.Status != TaskStatusCompleted || task.Result["charged"] != true {
+		t.Errorf("second task is %s with %v, want it completed with the first's result", task.Status, task.Result)
+	}
+}
+
+func TestIdempotencyKeyPassesToTheNextTaskWhenTheHolderFails(t *testing.T) {
+	store := newFakeStore()
+	addKeyedTask(store, "first", "team-a")
+	addKeyedTask(store, "second", "team-a")
+
+	s := newTestScheduler(store, newFakeBroker())
+	prepareTask(t, s, store, "first")
+	store.UpdateTaskStatus("first", TaskStatusFailed, nil, "card declined")
+
+	if !prepareTask(t, s, store, "second") {
+		t.Error("the second task was held back by a failed holder")
+	}
+}
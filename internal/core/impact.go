@@ -0,0 +1,90 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ImpactedTask is a downstream task whose fate changes if another task
+// stops without succeeding.
+type ImpactedTask struct {
+	ID     string     `json:"id"`
+	Name   string     `json:"name"`
+	Status TaskStatus `json:"status"`
+	Reason string     `json:"reason"`
+}
+
+// DownstreamImpact lists what cancelling or skipping a task would do to the
+// rest of its workflow: the tasks that would be skipped, directly or
+// through a chain of dependencies, and those that opted into
+// RunOnUpstreamFailure and would run regardless.
+type DownstreamImpact struct {
+	TaskID   string         `json:"task_id"`
+	TaskName string         `json:"task_name"`
+	Skipped  []ImpactedTask `json:"skipped"`
+	StillRun []ImpactedTask `json:"still_run"`
+}
+
+// DownstreamImpact works out the blast radius of stopping the task with
+// the given ID, propagating the failure through the workflow the way the
+// scheduler does when it settles the workflow.
+func (w *Workflow) DownstreamImpact(taskID string) (*DownstreamImpact, error) {
+	var target *Task
+	for i := range w.Tasks {
+		if w.Tasks[i].ID == taskID {
+			target = &w.Tasks[i]
+			break
+		}
+	}
+	if target == nil {
+		return nil, fmt.Errorf("task %s is not part of workflow %s", taskID, w.ID)
+	}
+
+	impact := &DownstreamImpact{
+		TaskID:   target.ID,
+		TaskName: target.Name,
+		Skipped:  []ImpactedTask{},
+		StillRun: []ImpactedTask{},
+	}
+
+	stopped := map[string]bool{target.ID: true, target.Name: true}
+	decided := map[string]bool{target.ID: true}
+	for changed := true; changed; {
+		changed = false
+		for _, task := range w.Tasks {
+			if decided[task.ID] || task.Status.IsTerminal() {
+				continue
+			}
+
+			upstream := task.FailedDependencies(stopped)
+			if len(upstream) == 0 {
+				continue
+			}
+			decided[task.ID] = true
+			changed = true
+
+			sort.Strings(upstream)
+			if task.RunOnUpstreamFailure {
+				impact.StillRun = append(impact.StillRun, ImpactedTask{
+					ID:     task.ID,
+					Name:   task.Name,
+					Status: task.Status,
+					Reason: fmt.Sprintf("runs on upstream failure of %s", strings.Join(upstream, ", ")),
+				})
+				continue
+			}
+
+			impact.Skipped = append(impact.Skipped, ImpactedTask{
+				ID:     task.ID,
+				Name:   task.Name,
+				Status: task.Status,
+				Reason: fmt.Sprintf("%s would not succeed", strings.Join(upstream, ", ")),
+			})
+			stopped[task.ID] = true
+			stopped[task.Name] = true
+		}
+	}
+
+	return impact, nil
+}
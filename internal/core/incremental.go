@@ -0,0 +1,69 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+)
+
+// incrementalKeyPrefix marks idempotency keys derived for incremental
+// workflows, so they cannot collide with keys that clients choose.
+const incrementalKeyPrefix = "incremental:"
+
+// AssignIncrementalKeys gives every task of an incremental workflow that
+// has no idempotency key one derived from its namespace, type and payload
+// and the keys of the tasks it depends on. A task whose inputs match a
+// task that completed in an earlier run then reuses that result instead of
+// running, while a change to a task's payload also reruns everything
+// downstream of it. Tasks with an explicit key keep it, and dry runs get
+// no keys, so their canned results are never reused by real runs.
+func (w *Workflow) AssignIncrementalKeys() {
+	if !w.Config.Incremental || w.Config.DryRun != nil {
+		return
+	}
+
+	byRef := make(map[string]*Task, len(w.Tasks)*2)
+	for i := range w.Tasks {
+		byRef[w.Tasks[i].ID] = &w.Tasks[i]
+		byRef[w.Tasks[i].Name] = &w.Tasks[i]
+	}
+
+	// visiting guards against cycles, which validation rejects anyway.
+	visiting := make(map[string]bool)
+	var assign func(task *Task) string
+	assign = func(task *Task) string {
+		if task.IdempotencyKey != "" || visiting[task.ID] {
+			return task.IdempotencyKey
+		}
+		visiting[task.ID] = true
+
+		upstream := make([]string, 0, len(task.Dependencies))
+		for _, dep := range task.Dependencies {
+			if depTask, ok := byRef[dep]; ok {
+				upstream = append(upstream, assign(depTask))
+			}
+		}
+		sort.Strings(upstream)
+
+		task.IdempotencyKey = incrementalKey(w.Namespace, task, upstream)
+		return task.IdempotencyKey
+	}
+
+	for i := range w.Tasks {
+		assign(&w.Tasks[i])
+	}
+}
+
+func incrementalKey(namespace string, task *Task, upstream []string) string {
+	// Marshalling sorts map keys, so equal payloads hash equally.
+	inputs, _ := json.Marshal(struct {
+		Namespace string                 `json:"namespace"`
+		Type      string                 `json:"type"`
+		Payload   map[string]interface{} `json:"payload"`
+		Upstream  []string               `json:"upstream"`
+	}{namespace, task.Type, task.Payload, upstream})
+
+	sum := sha256.Sum256(inputs)
+	return incrementalKeyPrefix + hex.EncodeToString(sum[:])
+}
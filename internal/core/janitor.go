@@ -0,0 +1,166 @@
+package core
+
+import (
+	"context"
+	"time"
+)
+
+const DefaultJanitorInterval = time.Minute * 10
+
+// SetJanitorInterval sets how often the leader prunes Redis metadata that
+// nothing refers to any more. Zero disables the janitor.
+func (s *Scheduler) SetJanitorInterval(interval time.Duration) {
+	s.janitorInterval = interval
+}
+
+func (s *Scheduler) runJanitor(ctx context.Context) {
+	defer s.wg.Done()
+
+	if s.janitorInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(s.janitorInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			passCtx, leading := s.leaderContext()
+			if !leading {
+				continue
+			}
+			s.pruneRedis(passCtx)
+		}
+	}
+}
+
+// pruneRedis removes expired workers from the per-type worker sets, worker
+// pools, dedicated namespaces and task selectors nothing uses, and every trace of tasks
+// whose workflow has been deleted: queue, retry, poison and dead letter
+// entries, leases and delivery counts. Redis drops a retry set once its last entry is removed,
+// so retry sets left holding only deleted tasks disappear as well. Records
+// of injected tasks are kept for InjectedTaskRetention.
+func (s *Scheduler) pruneRedis(ctx context.Context) {
+	workers, err := s.queue.PruneWorkerSets(ctx)
+	if err != nil {
+		s.logger.Errorf("Failed to prune worker sets: %v", err)
+	}
+
+	pools, err := s.queue.PrunePools(ctx)
+	if err != nil {
+		s.logger.Errorf("Failed to prune worker pools: %v", err)
+	}
+
+	namespaces, err := s.queue.PruneNamespaces(ctx)
+	if err != nil {
+		s.logger.Errorf("Failed to prune dedicated namespaces: %v", err)
+	}
+
+	selectors, err := s.queue.PruneSelectors(ctx)
+	if err != nil {
+		s.logger.Errorf("Failed to prune task selectors: %v", err)
+	}
+
+	held, err := s.queue.HeldTasks(ctx)
+	if err != nil {
+		s.logger.Errorf("Failed to read tasks held in Redis: %v", err)
+		return
+	}
+
+	heldIDs := make([]string, 0, len(held))
+	for _, task := range held {
+		heldIDs = append(heldIDs, task.ID)
+	}
+	deleted, err := s.deletedTaskIDs(heldIDs)
+	if err != nil {
+		s.logger.Errorf("Failed to look up tasks held in Redis: %v", err)
+		return
+	}
+
+	// Injected tasks never had a row, so they only become orphans once
+	// their injection record is pruned.
+	injected, err := s.queue.InjectedTaskIDs(ctx)
+	if err != nil {
+		s.logger.Errorf("Failed to read injected tasks: %v", err)
+		return
+	}
+	for taskID := range injected {
+		delete(deleted, taskID)
+	}
+
+	var orphans []Task
+	for _, task := range held {
+		if deleted[task.ID] {
+			orphans = append(orphans, task)
+		}
+	}
+
+	purged, leased, err := s.queue.PurgeTasks(ctx, orphans)
+	if err != nil {
+		s.logger.Errorf("Failed to purge deleted tasks: %v", err)
+	}
+	for _, taskID := range leased {
+		if err := s.queue.PublishCancellation(ctx, taskID); err != nil {
+			s.logger.Errorf("Failed to cancel deleted task %s: %v", taskID, err)
+		}
+	}
+
+	counted, err := s.queue.DeliveryCountTaskIDs(ctx)
+	if err != nil {
+		s.logger.Errorf("Failed to read delivery counts: %v", err)
+		return
+	}
+	deleted, err = s.deletedTaskIDs(counted)
+	if err != nil {
+		s.logger.Errorf("Failed to look up counted tasks: %v", err)
+		return
+	}
+	stale := make([]string, 0, len(deleted))
+	for taskID := range deleted {
+		if !injected[taskID] {
+			stale = append(stale, taskID)
+		}
+	}
+	if err := s.queue.ClearDeliveryCounts(ctx, stale); err != nil {
+		s.logger.Errorf("Failed to clear delivery counts: %v", err)
+	}
+
+	expired, err := s.queue.PruneInjectedTasks(ctx, time.Now().Add(-InjectedTaskRetention))
+	if err != nil {
+		s.logger.Errorf("Failed to prune injected tasks: %v", err)
+	}
+
+	if workers+pools+namespaces+selectors+purged+len(stale)+expired > 0 {
+		s.logger.Infof("Janitor pruned %d worker set entries, %d worker pools, %d dedicated namespaces, %d task selectors, %d entries of deleted tasks, %d delivery counts and %d injected tasks",
+			workers, pools, namespaces, selectors, purged, len(stale), expired)
+	}
+}
+
+// deletedTaskIDs returns which of ids no longer have a task row.
+func (s *Scheduler) deletedTaskIDs(ids []string) (map[string]bool, error) {
+	deleted := make(map[string]bool, len(ids))
+	if len(ids) == 0 {
+		return deleted, nil
+	}
+
+	existing, err := s.store.ExistingTaskIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[string]bool, len(existing))
+	for _, id := range existing {
+		found[id] = true
+	}
+
+	for _, id := range ids {
+		if !found[id] {
+			deleted[id] = true
+		}
+	}
+	return deleted, nil
+}
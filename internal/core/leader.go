@@ -0,0 +1,174 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	leaderLeaseTTL      = time.Second * 15
+	leaderRenewInterval = time.Second * 5
+)
+
+type LeaderStatus struct {
+	InstanceID string        `json:"instance_id"`
+	IsLeader   bool          `json:"is_leader"`
+	LeaderID   string        `json:"leader_id,omitempty"`
+	LeaseTTL   time.Duration `json:"lease_ttl"`
+}
+
+// errLeaseLost is returned by the writes of a leader's scheduling pass once
+// the lease it ran under has been lost or has run out, so that a deposed
+// leader stops dispatching instead of racing the new one.
+var errLeaseLost = errors.New("scheduling lease lost")
+
+// leaderLease is the lease a leader's scheduling passes run under. Its
+// context is cancelled as soon as a renewal fails, and expires, in Unix
+// nanoseconds, is pushed back by each renewal, so a pass can tell the lease
+// has run out even while the renewal that would notice is still hanging.
+type leaderLease struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	expires atomic.Int64
+}
+
+type leaseContextKey struct{}
+
+func newInstanceID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "scheduler"
+	}
+	return fmt.Sprintf("%s-%s", hostname, uuid.New().String()[:8])
+}
+
+// IsLeader reports whether this instance currently holds the scheduling
+// lease. Only the leader runs the background scheduling loops; every
+// instance keeps serving the API.
+func (s *Scheduler) IsLeader() bool {
+	return s.lease.Load() != nil
+}
+
+// leaderContext returns the context a leader's scheduling pass runs under,
+// which is cancelled when the lease is lost, and reports whether this
+// instance is the leader.
+func (s *Scheduler) leaderContext() (context.Context, bool) {
+	lease := s.lease.Load()
+	if lease == nil {
+		return nil, false
+	}
+	return lease.ctx, true
+}
+
+// checkLease returns errLeaseLost if ctx belongs to a leader's pass whose
+// lease has been lost or has expired without being renewed. Passes not run
+// under a lease, such as Tick and API requests, are never fenced.
+func (s *Scheduler) checkLease(ctx context.Context) error {
+	lease, ok := ctx.Value(leaseContextKey{}).(*leaderLease)
+	if !ok {
+		return nil
+	}
+	if lease.ctx.Err() != nil || time.Now().UnixNano() >= lease.expires.Load() {
+		return errLeaseLost
+	}
+	return nil
+}
+
+func (s *Scheduler) runLeaderElection(ctx context.Context) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(leaderRenewInterval)
+	defer ticker.Stop()
+
+	s.campaign(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.resign()
+			return
+		case <-s.stopCh:
+			s.resign()
+			return
+		case <-ticker.C:
+			s.campaign(ctx)
+		}
+	}
+}
+
+func (s *Scheduler) campaign(ctx context.Context) {
+	// The lease is counted from before the request, since Redis may have set
+	// it at any point until the reply arrived.
+	start := time.Now()
+	acquired, err := s.queue.AcquireLeadership(ctx, s.instanceID, leaderLeaseTTL)
+	if err != nil {
+		// Without a confirmed lease another instance may take over, so stop
+		// scheduling until the lease can be renewed. The queue already logs
+		// when Redis becomes unavailable.
+		if !errors.Is(err, ErrBrokerUnavailable) {
+			s.logger.Errorf("Failed to renew leadership: %v", err)
+		}
+		acquired = false
+	}
+
+	if !acquired {
+		if lease := s.lease.Swap(nil); lease != nil {
+			lease.cancel()
+			s.logger.Warnf("Scheduler instance %s lost leadership", s.instanceID)
+		}
+		return
+	}
+
+	expires := start.Add(leaderLeaseTTL).UnixNano()
+	if lease := s.lease.Load(); lease != nil {
+		lease.expires.Store(expires)
+		return
+	}
+
+	lease := &leaderLease{}
+	lease.ctx, lease.cancel = context.WithCancel(ctx)
+	lease.ctx = context.WithValue(lease.ctx, leaseContextKey{}, lease)
+	lease.expires.Store(expires)
+	s.lease.Store(lease)
+
+	s.logger.Infof("Scheduler instance %s became leader", s.instanceID)
+	s.wg.Add(1)
+	go s.recoverState(lease.ctx)
+}
+
+func (s *Scheduler) resign() {
+	lease := s.lease.Swap(nil)
+	if lease == nil {
+		return
+	}
+	lease.cancel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	if err := s.queue.ReleaseLeadership(ctx, s.instanceID); err != nil {
+		s.logger.Errorf("Failed to release leadership: %v", err)
+		return
+	}
+	s.logger.Infof("Scheduler instance %s released leadership", s.instanceID)
+}
+
+func (s *Scheduler) GetLeaderStatus(ctx context.Context) (*LeaderStatus, error) {
+	leaderID, ttl, err := s.queue.GetLeader(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LeaderStatus{
+		InstanceID: s.instanceID,
+		IsLeader:   s.IsLeader(),
+		LeaderID:   leaderID,
+		LeaseTTL:   ttl,
+	}, nil
+}
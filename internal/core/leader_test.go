@@ -0,0 +1,132 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// leaderBroker grants the scheduling lease while leading is set.
+type leaderBroker struct {
+	*fakeBroker
+	leading bool
+}
+
+func (b *leaderBroker) AcquireLeadership(ctx context.Context, instanceID string, ttl time.Duration) (bool, error) {
+	return b.leading, nil
+}
+
+// leaderStore runs onUpdate before marking tasks with a new status, and
+// has nothing for a new leader's recovery pass to do.
+type leaderStore struct {
+	*fakeStore
+	onUpdate func()
+}
+
+func (st *leaderStore) UpdateTasksStatus(ids []string, status TaskStatus) error {
+	if st.onUpdate != nil {
+		st.onUpdate()
+	}
+	return st.fakeStore.UpdateTasksStatus(ids, status)
+}
+
+func (st *leaderStore) GetInFlightTasks() ([]Task, error) {
+	return nil, nil
+}
+
+func (st *leaderStore) ListSchedules() ([]Schedule, error) {
+	return nil, nil
+}
+
+func newLeaderTest(t *testing.T) (*Scheduler, *leaderStore, *leaderBroker, context.Context) {
+	t.Helper()
+	store := &leaderStore{fakeStore: newFakeStore()}
+	store.add(&Workflow{
+		ID:     "wf",
+		Status: WorkflowStatusRunning,
+		Tasks:  []Task{{ID: "extract", WorkflowID: "wf", Name: "extract", Type: "etl", Status: TaskStatusPending}},
+	})
+	broker := &leaderBroker{fakeBroker: newFakeBroker(), leading: true}
+
+	s := newTestScheduler(store, broker)
+	s.campaign(context.Background())
+	s.wg.Wait()
+
+	passCtx, leading := s.leaderContext()
+	if !leading {
+		t.Fatal("scheduler did not become leader")
+	}
+	return s, store, broker, passCtx
+}
+
+func TestDispatchStopsWhenLeadershipIsLostMidPass(t *testing.T) {
+	s, store, broker, passCtx := newLeaderTest(t)
+
+	// The renewal fails while the pass is marking the task queued.
+	store.onUpdate = func() {
+		broker.leading = false
+		s.campaign(context.Background())
+	}
+
+	task, _ := store.GetTask("extract")
+	if err := s.dispatchBatch(passCtx, []*Task{task}); !errors.Is(err, errLeaseLost) {
+		t.Fatalf("dispatchBatch() error = %v, want %v", err, errLeaseLost)
+	}
+	if passCtx.Err() == nil {
+		t.Error("pass context was not cancelled when the lease was lost")
+	}
+	if s.IsLeader() {
+		t.Error("scheduler still reports itself leader")
+	}
+	if len(broker.enqueued) != 0 {
+		t.Errorf("deposed leader enqueued %d tasks, want none", len(broker.enqueued))
+	}
+	// Left queued for the new leader's recovery pass, not reverted.
+	if task, _ := store.GetTask("extract"); task.Status != TaskStatusQueued {
+		t.Errorf("task is %s, want queued", task.Status)
+	}
+}
+
+func TestDispatchRefusedOnceLeaseExpires(t *testing.T) {
+	s, store, broker, passCtx := newLeaderTest(t)
+
+	// A renewal that hangs past the TTL never gets to cancel the pass.
+	s.lease.Load().expires.Store(time.Now().Add(-time.Second).UnixNano())
+
+	task, _ := store.GetTask("extract")
+	if err := s.dispatchBatch(passCtx, []*Task{task}); !errors.Is(err, errLeaseLost) {
+		t.Fatalf("dispatchBatch() error = %v, want %v", err, errLeaseLost)
+	}
+	if len(broker.enqueued) != 0 {
+		t.Errorf("leader with an expired lease enqueued %d tasks, want none", len(broker.enqueued))
+	}
+	if task, _ := store.GetTask("extract"); task.Status != TaskStatusPending {
+		t.Errorf("task is %s, want pending", task.Status)
+	}
+
+	// Passes not run under a lease, such as Tick, are not fenced.
+	if err := s.dispatchBatch(context.Background(), []*Task{task}); err != nil {
+		t.Fatalf("unfenced dispatchBatch() error = %v", err)
+	}
+	if len(broker.enqueued) != 1 {
+		t.Errorf("unfenced dispatch enqueued %d tasks, want 1", len(broker.enqueued))
+	}
+}
+
+func TestStopWaitsForEveryLoop(t *testing.T) {
+	broker := &leaderBroker{fakeBroker: newFakeBroker()}
+	s := newTestScheduler(newFakeStore(), broker)
+	s.Start(context.Background())
+
+	stopped := make(chan struct{})
+	go func() {
+		s.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop() did not return; the wait group does not match the loops started")
+	}
+}
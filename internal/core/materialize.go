@@ -0,0 +1,144 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const submissionBatchSize = 500
+
+func (s *Scheduler) SubmitWorkflowAsync(ctx context.Context, workflow *Workflow) (*Submission, error) {
+	if err := s.checkQueueCapacity(ctx, workflow); err != nil {
+		return nil, err
+	}
+
+	if err := s.admit(ctx, workflow); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkSandboxPolicy(ctx, workflow); err != nil {
+		return nil, err
+	}
+
+	exists, err := s.store.WorkflowExists(workflow.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check workflow id: %w", err)
+	}
+	if exists {
+		return nil, fmt.Errorf("%w: workflow %s", ErrDuplicateID, workflow.ID)
+	}
+
+	workflow.AssignTaskOrder()
+	workflow.AssignIncrementalKeys()
+	tasks := workflow.Tasks
+	workflow.Status = WorkflowStatusSubmitting
+
+	if err := s.store.CreateWorkflow(workflow); err != nil {
+		return nil, fmt.Errorf("failed to create workflow: %w", err)
+	}
+
+	submission := NewSubmission(workflow.ID, len(tasks))
+	if err := s.queue.SaveSubmission(ctx, submission); err != nil {
+		if failErr := s.failWorkflow(ctx, workflow.ID, "failed to track submission"); failErr != nil {
+			s.logger.Errorf("Failed to fail workflow %s: %v", workflow.ID, failErr)
+		}
+		return nil, err
+	}
+
+	s.wg.Add(1)
+	go s.materializeWorkflow(submission, tasks)
+
+	s.logger.Infof("Accepted async submission %s for workflow %s with %d tasks", submission.ID, workflow.ID, len(tasks))
+	return submission, nil
+}
+
+func (s *Scheduler) GetSubmission(ctx context.Context, id string) (*Submission, error) {
+	return s.queue.GetSubmission(ctx, id)
+}
+
+func (s *Scheduler) materializeWorkflow(submission *Submission, tasks []Task) {
+	defer s.wg.Done()
+
+	ctx := context.Background()
+
+	taskIDs := make([]string, 0, len(tasks))
+	for _, task := range tasks {
+		taskIDs = append(taskIDs, task.ID)
+	}
+
+	existing, err := s.store.ExistingTaskIDs(taskIDs)
+	if err != nil {
+		s.failSubmission(ctx, submission, fmt.Errorf("failed to check task ids: %w", err))
+		return
+	}
+	if len(existing) > 0 {
+		s.failSubmission(ctx, submission, fmt.Errorf("%w: tasks %s", ErrDuplicateID, strings.Join(existing, ", ")))
+		return
+	}
+
+	for start := 0; start < len(tasks); start += submissionBatchSize {
+		select {
+		case <-s.stopCh:
+			s.failSubmission(ctx, submission, errors.New("scheduler stopped before all tasks were persisted"))
+			return
+		default:
+		}
+
+		end := start + submissionBatchSize
+		if end > len(tasks) {
+			end = len(tasks)
+		}
+
+		if err := s.store.CreateTasks(tasks[start:end]); err != nil {
+			s.failSubmission(ctx, submission, err)
+			return
+		}
+
+		submission.PersistedTasks = end
+		submission.UpdatedAt = time.Now()
+		if err := s.queue.SaveSubmission(ctx, submission); err != nil {
+			s.logger.Errorf("Failed to update submission %s: %v", submission.ID, err)
+		}
+	}
+
+	released, err := s.store.TransitionWorkflowStatus(submission.WorkflowID, WorkflowStatusSubmitting, WorkflowStatusPending)
+	if err != nil {
+		s.failSubmission(ctx, submission, err)
+		return
+	}
+
+	if !released {
+		// The workflow was cancelled while its tasks were being persisted.
+		submission.Status = SubmissionStatusFailed
+		submission.Error = "workflow left the submitting state before materialization finished"
+	} else {
+		submission.Status = SubmissionStatusCompleted
+		s.publishEvent(ctx, NewWorkflowEvent(submission.WorkflowID, WorkflowStatusPending, ""))
+	}
+
+	submission.UpdatedAt = time.Now()
+	if err := s.queue.SaveSubmission(ctx, submission); err != nil {
+		s.logger.Errorf("Failed to update submission %s: %v", submission.ID, err)
+	}
+
+	s.logger.Infof("Finished submission %s for workflow %s: %s", submission.ID, submission.WorkflowID, submission.Status)
+}
+
+func (s *Scheduler) failSubmission(ctx context.Context, submission *Submission, err error) {
+	s.logger.Errorf("Submission %s for workflow %s failed: %v", submission.ID, submission.WorkflowID, err)
+
+	submission.Status = SubmissionStatusFailed
+	submission.Error = err.Error()
+	submission.UpdatedAt = time.Now()
+
+	if saveErr := s.queue.SaveSubmission(ctx, submission); saveErr != nil {
+		s.logger.Errorf("Failed to update submission %s: %v", submission.ID, saveErr)
+	}
+
+	if failErr := s.failWorkflow(ctx, submission.WorkflowID, fmt.Sprintf("submission failed: %v", err)); failErr != nil {
+		s.logger.Errorf("Failed to fail workflow %s: %v", submission.WorkflowID, failErr)
+	}
+}
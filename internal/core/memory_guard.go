@@ -0,0 +1,110 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// SetMemoryCheckInterval sets how often the leader checks that Redis cannot
+// evict queued tasks and whether it has evicted any. Zero disables the
+// checks.
+func (s *Scheduler) SetMemoryCheckInterval(interval time.Duration) {
+	s.memoryInterval = interval
+}
+
+// CheckEvictionPolicy returns an error wrapping ErrUnsafeEvictionPolicy
+// unless Redis runs with the noeviction policy.
+func (s *Scheduler) CheckEvictionPolicy(ctx context.Context) error {
+	memory, err := s.queue.GetRedisMemory(ctx)
+	if err != nil {
+		return err
+	}
+	return memory.CheckEviction()
+}
+
+// RedisMemoryReport describes Redis's memory use and estimates how much of
+// it each task type's queues take.
+func (s *Scheduler) RedisMemoryReport(ctx context.Context) (*RedisMemoryReport, error) {
+	memory, err := s.queue.GetRedisMemory(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	taskTypes, err := s.queue.GetTaskTypes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &RedisMemoryReport{RedisMemory: *memory, Queues: []QueueMemoryUsage{}}
+	for _, taskType := range taskTypes {
+		usage, err := s.queue.GetQueueMemoryUsage(ctx, taskType)
+		if err != nil {
+			return nil, err
+		}
+		report.Queues = append(report.Queues, *usage)
+	}
+	sort.Slice(report.Queues, func(i, j int) bool {
+		if report.Queues[i].Bytes != report.Queues[j].Bytes {
+			return report.Queues[i].Bytes > report.Queues[j].Bytes
+		}
+		return report.Queues[i].TaskType < report.Queues[j].TaskType
+	})
+	return report, nil
+}
+
+func (s *Scheduler) guardRedisMemory(ctx context.Context) {
+	defer s.wg.Done()
+
+	if s.memoryInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(s.memoryInterval)
+	defer ticker.Stop()
+
+	// evicted is the eviction count at the last check, -1 before the
+	// first; unsafe is whether the policy was unsafe then.
+	evicted, unsafe := int64(-1), false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			if !s.IsLeader() {
+				evicted, unsafe = -1, false
+				continue
+			}
+
+			memory, err := s.queue.GetRedisMemory(ctx)
+			if err != nil {
+				s.logger.Errorf("Failed to check Redis memory: %v", err)
+				continue
+			}
+
+			// Alert once when the policy turns unsafe, but log every check.
+			if err := memory.CheckEviction(); err != nil {
+				s.logger.Errorf("Redis eviction is unsafe: %v", err)
+				if !unsafe {
+					s.publishEvent(ctx, NewBrokerEvent(EventBrokerEvictionUnsafe, err.Error(), memory, nil))
+				}
+				unsafe = true
+			} else {
+				unsafe = false
+			}
+
+			// Redis counts evictions since it started; only new ones alert.
+			if evicted >= 0 && memory.EvictedKeys > evicted {
+				msg := fmt.Sprintf("redis evicted %d keys since the last check; queued tasks may have been lost", memory.EvictedKeys-evicted)
+				s.logger.Error(msg)
+				s.publishEvent(ctx, NewBrokerEvent(EventBrokerKeysEvicted, msg, memory, map[string]interface{}{
+					"newly_evicted": memory.EvictedKeys - evicted,
+				}))
+			}
+			evicted = memory.EvictedKeys
+		}
+	}
+}
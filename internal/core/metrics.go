@@ -0,0 +1,31 @@
+package core
+
+import "time"
+
+// Metrics counts every workflow and task stored, together with the current
+// queue depths and worker fleet.
+type Metrics struct {
+	GeneratedAt time.Time                   `json:"generated_at"`
+	Workflows   WorkflowMetrics             `json:"workflows"`
+	Tasks       TaskMetrics                 `json:"tasks"`
+	Queues      map[string]map[string]int64 `json:"queues"`
+	Workers     WorkerMetrics               `json:"workers"`
+}
+
+type WorkflowMetrics struct {
+	Total    int                    `json:"total"`
+	ByStatus map[WorkflowStatus]int `json:"by_status"`
+}
+
+type TaskMetrics struct {
+	Total    int                           `json:"total"`
+	ByStatus map[TaskStatus]int            `json:"by_status"`
+	ByType   map[string]map[TaskStatus]int `json:"by_type"`
+}
+
+// WorkerMetrics counts live workers; Busy ones hold at least one task.
+type WorkerMetrics struct {
+	Active int `json:"active"`
+	Busy   int `json:"busy"`
+	Idle   int `json:"idle"`
+}
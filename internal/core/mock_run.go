@@ -0,0 +1,64 @@
+package core
+
+import (
+	"context"
+	"fmt"
+)
+
+// runMockTasks completes the ready tasks of a dry-run workflow with their
+// canned results, then keeps planning the workflow until no task is ready,
+// so a whole DAG finishes in one scheduling pass.
+func (s *Scheduler) runMockTasks(ctx context.Context, workflow *Workflow, ready []Task) error {
+	for len(ready) > 0 {
+		for i := range ready {
+			if err := s.completeMockTask(ctx, workflow, &ready[i]); err != nil {
+				return fmt.Errorf("failed to complete task %s: %w", ready[i].ID, err)
+			}
+		}
+
+		if err := s.settleWorkflow(ctx, workflow.ID); err != nil {
+			s.logger.Errorf("Failed to settle workflow %s: %v", workflow.ID, err)
+		}
+
+		var err error
+		workflow, err = s.store.GetWorkflow(workflow.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get workflow: %w", err)
+		}
+		if workflow.Status != WorkflowStatusPending && workflow.Status != WorkflowStatusRunning {
+			return nil
+		}
+
+		var pending []Task
+		for _, task := range workflow.Tasks {
+			if task.Status == TaskStatusPending {
+				pending = append(pending, task)
+			}
+		}
+		ready, _ = s.planWorkflowTasks(ctx, workflow, pending)
+	}
+	return nil
+}
+
+// completeMockTask moves a task through queued and running to completed,
+// publishing the same events a real execution would.
+func (s *Scheduler) completeMockTask(ctx context.Context, workflow *Workflow, task *Task) error {
+	if err := s.setTaskStatus(ctx, task.WorkflowID, task.ID, TaskStatusQueued, nil, ""); err != nil {
+		return err
+	}
+	if err := s.setTaskStatus(ctx, task.WorkflowID, task.ID, TaskStatusRunning, nil, ""); err != nil {
+		return err
+	}
+
+	env := ExecutionEnvironment{
+		Attempt:   task.RetryCount + 1,
+		WorkerID:  s.instanceID,
+		Executor:  MockExecutor,
+		StartedAt: s.clock(),
+	}
+	if err := s.store.AddTaskAttempt(task.ID, env); err != nil {
+		s.logger.Warnf("Failed to record mock attempt of task %s: %v", task.ID, err)
+	}
+
+	return s.setTaskStatus(ctx, task.WorkflowID, task.ID, TaskStatusCompleted, workflow.Config.DryRun.MockResult(task), "")
+}
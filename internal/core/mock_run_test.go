@@ -0,0 +1,99 @@
+package core
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDryRunConfigMockResult(t *testing.T) {
+	config := &DryRunConfig{Results: map[string]map[string]interface{}{
+		"etl": {"rows": 10},
+	}}
+
+	if result := config.MockResult(&Task{Type: "etl"}); result["rows"] != 10 {
+		t.Errorf("etl result = %v, want the canned result", result)
+	}
+
+	payload := map[string]interface{}{"url": "https://example.com"}
+	result := config.MockResult(&Task{Type: "http", Payload: payload})
+	if result["dry_run"] != true || result["payload"].(map[string]interface{})["url"] != "https://example.com" {
+		t.Errorf("http result = %v, want the rendered payload", result)
+	}
+}
+
+func TestParseDryRunConfig(t *testing.T) {
+	workflow, err := ParseWorkflowFromYAMLBytes([]byte(`
+name: pipeline
+config:
+  dry_run:
+    results:
+      etl:
+        rows: 10
+tasks:
+  - name: extract
+    type: etl
+`))
+	if err != nil {
+		t.Fatalf("ParseWorkflowFromYAMLBytes() error = %v", err)
+	}
+	if workflow.Config.DryRun == nil || workflow.Config.DryRun.Results["etl"]["rows"] != 10 {
+		t.Errorf("dry_run = %+v, want the etl result", workflow.Config.DryRun)
+	}
+}
+
+func TestRunMockTasksWalksTheDAG(t *testing.T) {
+	workflow := NewWorkflow("pipeline", "")
+	workflow.Status = WorkflowStatusRunning
+	workflow.Config.DryRun = &DryRunConfig{Results: map[string]map[string]interface{}{
+		"etl": {"rows": 10},
+	}}
+	load := NewTask(workflow.ID, "load", "warehouse", nil)
+	load.Dependencies = []string{"transform"}
+	transform := NewTask(workflow.ID, "transform", "etl", nil)
+	transform.Dependencies = []string{"extract"}
+	extract := NewTask(workflow.ID, "extract", "etl", nil)
+	workflow.Tasks = []Task{*load, *transform, *extract}
+
+	store := newFakeStore()
+	store.add(workflow)
+	broker := newFakeBroker()
+	s := newTestScheduler(store, broker)
+
+	ready, _ := s.planWorkflowTasks(context.Background(), workflow, workflow.Tasks)
+	if err := s.runMockTasks(context.Background(), workflow, ready); err != nil {
+		t.Fatalf("runMockTasks() error = %v", err)
+	}
+
+	want := []string{extract.ID, transform.ID, load.ID}
+	if len(store.attempts) != len(want) {
+		t.Fatalf("attempts = %v, want one each for extract, transform and load", store.attempts)
+	}
+	for i, id := range want {
+		if store.attempts[i] != id {
+			t.Fatalf("attempts = %v, want extract, transform and load in that order", store.attempts)
+		}
+	}
+
+	for _, id := range want {
+		task, _ := store.FindTask(id)
+		if task.Status != TaskStatusCompleted {
+			t.Errorf("task %s is %s, want completed", task.Name, task.Status)
+		}
+		if len(task.Attempts) != 1 || task.Attempts[0].Executor != MockExecutor {
+			t.Errorf("task %s attempts = %+v, want one mock attempt", task.Name, task.Attempts)
+		}
+	}
+	if task, _ := store.FindTask(transform.ID); task.Result["rows"] != 10 {
+		t.Errorf("transform result = %v, want the canned etl result", task.Result)
+	}
+	if task, _ := store.FindTask(load.ID); task.Result["dry_run"] != true {
+		t.Errorf("load result = %v, want its payload echoed", task.Result)
+	}
+
+	if got, _ := store.GetWorkflow(workflow.ID); got.Status != WorkflowStatusCompleted {
+		t.Errorf("workflow is %s, want completed", got.Status)
+	}
+	if len(broker.enqueued) != 0 {
+		t.Errorf("dry run enqueued %d tasks", len(broker.enqueued))
+	}
+}
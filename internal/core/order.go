@@ -0,0 +1,91 @@
+package core
+
+import "sort"
+
+// AssignTaskOrder numbers the workflow's tasks in dependency order, so that
+// every task comes after the tasks it depends on. Tasks that are not ordered
+// relative to each other keep the order they were submitted in. Tasks on a
+// cycle, or waiting on one, are numbered last in submission order, and
+// dependencies on unknown task names are ignored.
+//
+// Tasks created in one submission share practically the same created_at, so
+// the order is what listings and dispatch use to break ties between them.
+func (w *Workflow) AssignTaskOrder() {
+	index := make(map[string]int, len(w.Tasks))
+	for i, task := range w.Tasks {
+		index[task.Name] = i
+	}
+
+	waiting := make([]int, len(w.Tasks))
+	dependents := make([][]int, len(w.Tasks))
+	for i, task := range w.Tasks {
+		for _, dep := range task.Dependencies {
+			j, ok := index[dep]
+			if !ok || j == i {
+				continue
+			}
+			waiting[i]++
+			dependents[j] = append(dependents[j], i)
+		}
+	}
+
+	assigned := make([]bool, len(w.Tasks))
+	order := 0
+	for {
+		// Take the first unassigned task in submission order whose
+		// dependencies have all been numbered.
+		next := -1
+		for i := range w.Tasks {
+			if !assigned[i] && waiting[i] == 0 {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			break
+		}
+
+		assigned[next] = true
+		w.Tasks[next].Order = order
+		order++
+		for _, dependent := range dependents[next] {
+			waiting[dependent]--
+		}
+	}
+
+	for i := range w.Tasks {
+		if !assigned[i] {
+			w.Tasks[i].Order = order
+			order++
+		}
+	}
+}
+
+// sortForDispatch orders the ready tasks of many workflows for dispatch: by
+// workflow priority, then task priority, then oldest workflow first, and
+// within a workflow in dependency order. Every step compares the same key
+// of both tasks, never a key that only some pairs share, so the order is a
+// strict weak ordering and does not depend on which tasks the sort happens
+// to compare.
+func sortForDispatch(tasks []Task, workflows map[string]*Workflow) {
+	sort.SliceStable(tasks, func(i, j int) bool {
+		a, b := &tasks[i], &tasks[j]
+		wa, wb := workflows[a.WorkflowID], workflows[b.WorkflowID]
+		if wa.Priority != wb.Priority {
+			return wa.Priority > wb.Priority
+		}
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		if !wa.CreatedAt.Equal(wb.CreatedAt) {
+			return wa.CreatedAt.Before(wb.CreatedAt)
+		}
+		if a.WorkflowID != b.WorkflowID {
+			return a.WorkflowID < b.WorkflowID
+		}
+		if a.Order != b.Order {
+			return a.Order < b.Order
+		}
+		return a.CreatedAt.Before(b.CreatedAt)
+	})
+}
@@ -0,0 +1,97 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func taskOrders(w *Workflow) map[string]int {
+	orders := make(map[string]int, len(w.Tasks))
+	for _, task := range w.Tasks {
+		orders[task.Name] = task.Order
+	}
+	return orders
+}
+
+func TestAssignTaskOrderPutsDependenciesFirst(t *testing.T) {
+	w := &Workflow{Tasks: []Task{
+		{Name: "load", Dependencies: []string{"transform"}},
+		{Name: "transform", Dependencies: []string{"extract"}},
+		{Name: "report"},
+		{Name: "extract"},
+	}}
+	w.AssignTaskOrder()
+
+	want := map[string]int{"report": 0, "extract": 1, "transform": 2, "load": 3}
+	got := taskOrders(w)
+	for name, order := range want {
+		if got[name] != order {
+			t.Errorf("orders = %v, want %v", got, want)
+			break
+		}
+	}
+}
+
+func TestAssignTaskOrderNumbersCyclesLast(t *testing.T) {
+	w := &Workflow{Tasks: []Task{
+		{Name: "a", Dependencies: []string{"b"}},
+		{Name: "b", Dependencies: []string{"a"}},
+		{Name: "after", Dependencies: []string{"a"}},
+		{Name: "free", Dependencies: []string{"missing", "free"}},
+	}}
+	w.AssignTaskOrder()
+
+	want := map[string]int{"free": 0, "a": 1, "b": 2, "after": 3}
+	got := taskOrders(w)
+	for name, order := range want {
+		if got[name] != order {
+			t.Errorf("orders = %v, want %v", got, want)
+			break
+		}
+	}
+}
+
+func TestSortForDispatchIsConsistent(t *testing.T) {
+	base := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	workflows := map[string]*Workflow{
+		"wf1":    {ID: "wf1", CreatedAt: base},
+		"wf2":    {ID: "wf2", CreatedAt: base.Add(time.Second)},
+		"urgent": {ID: "urgent", Priority: 1, CreatedAt: base.Add(time.Hour)},
+	}
+	// Ordering wf1's tasks by dependency order and other pairs by task
+	// creation time would put load before extract, extract before other
+	// and other before load.
+	tasks := []Task{
+		{ID: "load", WorkflowID: "wf1", Order: 1, CreatedAt: base},
+		{ID: "other", WorkflowID: "wf2", CreatedAt: base.Add(time.Second)},
+		{ID: "extract", WorkflowID: "wf1", Order: 0, CreatedAt: base.Add(2 * time.Second)},
+		{ID: "alert", WorkflowID: "urgent", CreatedAt: base.Add(time.Hour)},
+		{ID: "high", WorkflowID: "wf2", Priority: 5, CreatedAt: base.Add(time.Second)},
+	}
+	want := []string{"alert", "high", "extract", "load", "other"}
+
+	// Every arrangement of the input must sort the same way.
+	var permute func(k int)
+	permute = func(k int) {
+		if k == len(tasks) {
+			sorted := append([]Task(nil), tasks...)
+			sortForDispatch(sorted, workflows)
+			for i, task := range sorted {
+				if task.ID != want[i] {
+					var got []string
+					for _, task := range sorted {
+						got = append(got, task.ID)
+					}
+					t.Fatalf("sorted %v, want %v", got, want)
+				}
+			}
+			return
+		}
+		for i := k; i < len(tasks); i++ {
+			tasks[k], tasks[i] = tasks[i], tasks[k]
+			permute(k + 1)
+			tasks[k], tasks[i] = tasks[i], tasks[k]
+		}
+	}
+	permute(0)
+}
@@ -0,0 +1,43 @@
+package core
+
+import "time"
+
+// Overview summarizes the state of the system in one response for the
+// dashboard home page and command line status checks.
+type Overview struct {
+	GeneratedAt         time.Time              `json:"generated_at"`
+	Window              time.Duration          `json:"window"`
+	WorkflowRuns        map[WorkflowStatus]int `json:"workflow_runs"`
+	TopFailingTaskTypes []TaskTypeFailures     `json:"top_failing_task_types"`
+	Queues              map[string]QueueDepth  `json:"queues"`
+	DeadLetters         map[string]int64       `json:"dead_letters"`
+	Workers             WorkerCounts           `json:"workers"`
+	Scheduler           SchedulerLag           `json:"scheduler"`
+}
+
+type TaskTypeFailures struct {
+	Type     string `json:"type"`
+	Failures int    `json:"failures"`
+}
+
+type QueueDepth struct {
+	Pending    int64 `json:"pending"`
+	Processing int64 `json:"processing"`
+	Retry      int64 `json:"retry"`
+	Delayed    int64 `json:"delayed"`
+	Poison     int64 `json:"poison"`
+	Paused     bool  `json:"paused"`
+}
+
+type WorkerCounts struct {
+	Total  int            `json:"total"`
+	ByType map[string]int `json:"by_type"`
+}
+
+// SchedulerLag shows how far behind the scheduler is: Lag is how long the
+// most overdue enabled schedule has been waiting to fire.
+type SchedulerLag struct {
+	LeaderID         string        `json:"leader_id,omitempty"`
+	OverdueSchedules int           `json:"overdue_schedules"`
+	Lag              time.Duration `json:"lag"`
+}
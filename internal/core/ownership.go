@@ -0,0 +1,62 @@
+package core
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// validateDocURL checks a docs or runbook link, which must be an absolute
+// http or https URL. An empty link is allowed.
+func validateDocURL(field, link string) error {
+	if link == "" {
+		return nil
+	}
+
+	parsed, err := url.Parse(link)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("%s %q is not an http or https URL", field, link)
+	}
+	return nil
+}
+
+// validateOwnership checks the docs and runbook links of a workflow or task.
+func validateOwnership(docsURL, runbookURL string) error {
+	if err := validateDocURL("docs_url", docsURL); err != nil {
+		return err
+	}
+	return validateDocURL("runbook_url", runbookURL)
+}
+
+// inheritOwnership fills the owner, docs and runbook link a task leaves
+// unset from its workflow.
+func (t *Task) inheritOwnership(owner, docsURL, runbookURL string, workflow *Workflow) {
+	t.Owner = firstNonEmpty(owner, workflow.Owner)
+	t.DocsURL = firstNonEmpty(docsURL, workflow.DocsURL)
+	t.RunbookURL = firstNonEmpty(runbookURL, workflow.RunbookURL)
+}
+
+// OwnershipData is what failure notifications carry so that on-call
+// engineers know who owns the failing pipeline and where its runbook is.
+// Unset fields are left out.
+func OwnershipData(owner, docsURL, runbookURL string) map[string]interface{} {
+	data := make(map[string]interface{})
+	if owner != "" {
+		data["owner"] = owner
+	}
+	if docsURL != "" {
+		data["docs_url"] = docsURL
+	}
+	if runbookURL != "" {
+		data["runbook_url"] = runbookURL
+	}
+	return data
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
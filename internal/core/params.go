@@ -0,0 +1,147 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+)
+
+var ErrInvalidParam = errors.New("invalid param")
+
+type ParamType string
+
+const (
+	ParamTypeString  ParamType = "string"
+	ParamTypeNumber  ParamType = "number"
+	ParamTypeInteger ParamType = "integer"
+	ParamTypeBoolean ParamType = "boolean"
+	ParamTypeObject  ParamType = "object"
+	ParamTypeArray   ParamType = "array"
+)
+
+func (t ParamType) Valid() bool {
+	switch t {
+	case ParamTypeString, ParamTypeNumber, ParamTypeInteger, ParamTypeBoolean, ParamTypeObject, ParamTypeArray:
+		return true
+	}
+	return false
+}
+
+// ParamSpec declares a workflow param. A param without a type accepts any
+// value. Default is used when a submission leaves the param unset.
+type ParamSpec struct {
+	Type        ParamType   `json:"type,omitempty" yaml:"type,omitempty"`
+	Description string      `json:"description,omitempty" yaml:"description,omitempty"`
+	Required    bool        `json:"required,omitempty" yaml:"required,omitempty"`
+	Default     interface{} `json:"default,omitempty" yaml:"default,omitempty"`
+}
+
+// validateParamSpecs checks declared types and that defaults match them.
+func validateParamSpecs(specs map[string]ParamSpec) error {
+	for _, name := range sortedParamNames(specs) {
+		spec := specs[name]
+		if spec.Type != "" && !spec.Type.Valid() {
+			return fmt.Errorf("%w: %s: unknown type %q", ErrInvalidParam, name, spec.Type)
+		}
+		if spec.Default == nil {
+			continue
+		}
+		if spec.Required {
+			return fmt.Errorf("%w: %s: a required param cannot have a default", ErrInvalidParam, name)
+		}
+		if err := checkParamType(spec.Type, spec.Default); err != nil {
+			return fmt.Errorf("%w: %s: default %v", ErrInvalidParam, name, err)
+		}
+	}
+	return nil
+}
+
+// ResolveParams checks submitted param values against their declarations
+// and fills in defaults. Without declarations the values are returned as
+// they are; with them, values for undeclared params are rejected.
+func ResolveParams(specs map[string]ParamSpec, values map[string]interface{}) (map[string]interface{}, error) {
+	if len(specs) == 0 {
+		return values, nil
+	}
+
+	resolved := make(map[string]interface{}, len(specs))
+	for name, value := range values {
+		spec, ok := specs[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s is not declared", ErrInvalidParam, name)
+		}
+		if err := checkParamType(spec.Type, value); err != nil {
+			return nil, fmt.Errorf("%w: %s %v", ErrInvalidParam, name, err)
+		}
+		resolved[name] = value
+	}
+
+	for _, name := range sortedParamNames(specs) {
+		if _, ok := resolved[name]; ok {
+			continue
+		}
+		spec := specs[name]
+		switch {
+		case spec.Default != nil:
+			resolved[name] = spec.Default
+		case spec.Required:
+			return nil, fmt.Errorf("%w: %s is required", ErrInvalidParam, name)
+		}
+	}
+
+	return resolved, nil
+}
+
+func checkParamType(paramType ParamType, value interface{}) error {
+	if paramType == "" {
+		return nil
+	}
+
+	var ok bool
+	switch paramType {
+	case ParamTypeString:
+		_, ok = value.(string)
+	case ParamTypeBoolean:
+		_, ok = value.(bool)
+	case ParamTypeNumber:
+		ok = isNumber(value)
+	case ParamTypeInteger:
+		if isNumber(value) {
+			f, _ := toFloat(value)
+			ok = f == math.Trunc(f)
+		}
+	case ParamTypeObject:
+		_, ok = value.(map[string]interface{})
+	case ParamTypeArray:
+		_, ok = value.([]interface{})
+	}
+
+	if !ok {
+		return fmt.Errorf("must be of type %s, got %v (%T)", paramType, value, value)
+	}
+	return nil
+}
+
+func isNumber(value interface{}) bool {
+	if value == nil {
+		return false
+	}
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func sortedParamNames(specs map[string]ParamSpec) []string {
+	names := make([]string, 0, len(specs))
+	for name := range specs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
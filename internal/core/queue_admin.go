@@ -0,0 +1,77 @@
+package core
+
+import (
+	"context"
+	"time"
+)
+
+// SetQueueInjection allows or forbids injecting tasks straight into queues.
+// It is off unless the scheduler is started with it enabled, since an
+// injected task runs on real workers with whatever payload it was given.
+func (s *Scheduler) SetQueueInjection(enabled bool) {
+	s.allowInjection = enabled
+}
+
+// PeekQueue reads the head of a queue without dequeuing anything and
+// records who looked.
+func (s *Scheduler) PeekQueue(ctx context.Context, taskType, pool, namespace string, count int, subject string) (*QueuePeek, error) {
+	if count <= 0 {
+		count = DefaultQueuePeekCount
+	}
+	if count > MaxQueuePeekCount {
+		count = MaxQueuePeekCount
+	}
+
+	peek, err := s.queue.PeekQueue(ctx, taskType, pool, namespace, int64(count))
+	if err != nil {
+		return nil, err
+	}
+
+	s.publishEvent(ctx, NewQueueAuditEvent(EventQueuePeeked, taskType, "", subject, map[string]interface{}{
+		"pool":      pool,
+		"namespace": namespace,
+		"count":     len(peek.Entries),
+	}))
+	return peek, nil
+}
+
+// InjectTask puts a task straight onto its queue, bypassing workflows and
+// the store, so a handler can be exercised with a hand-written payload.
+func (s *Scheduler) InjectTask(ctx context.Context, task *Task, subject string) (*InjectedTask, error) {
+	if !s.allowInjection {
+		return nil, ErrQueueInjectionDisabled
+	}
+
+	injected := &InjectedTask{
+		Task:       *task,
+		Subject:    subject,
+		InjectedAt: time.Now(),
+		Status:     TaskStatusQueued,
+	}
+	injected.Task.Status = TaskStatusQueued
+	if err := s.queue.InjectTask(ctx, injected); err != nil {
+		return nil, err
+	}
+
+	s.logger.Warnf("Injected task %s into the %s queue for subject %q", task.ID, task.Type, subject)
+	s.publishEvent(ctx, NewQueueAuditEvent(EventQueueInjected, task.Type, task.ID, subject, map[string]interface{}{
+		"pool": task.Pool,
+	}))
+	return injected, nil
+}
+
+// GetInjectedTask returns an injected task along with the status its worker
+// last reported, or nil if it is unknown or its record has been pruned.
+func (s *Scheduler) GetInjectedTask(ctx context.Context, taskID string) (*InjectedTask, error) {
+	return s.queue.GetInjectedTask(ctx, taskID)
+}
+
+// updateInjectedTaskStatus records a worker's report on an injected task.
+// It returns false when taskID was not injected.
+func (s *Scheduler) updateInjectedTaskStatus(ctx context.Context, taskID string, status TaskStatus, result map[string]interface{}, errorMsg string) (bool, error) {
+	result, _, err := TruncateResult(result, s.maxResultSize)
+	if err != nil {
+		return false, err
+	}
+	return s.queue.RecordInjectedTaskStatus(ctx, taskID, status, result, errorMsg)
+}
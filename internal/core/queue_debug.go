@@ -0,0 +1,68 @@
+package core
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	DefaultQueuePeekCount = 10
+	MaxQueuePeekCount     = 100
+
+	// InjectedTaskRetention is how long the janitor keeps the record of a
+	// task injected into a queue, and with it the outcome its worker
+	// reported.
+	InjectedTaskRetention = time.Hour * 24
+)
+
+// ErrQueueInjectionDisabled is returned when a task is injected into a
+// queue while the scheduler was not started with injection allowed.
+var ErrQueueInjectionDisabled = errors.New("queue injection is disabled on this scheduler")
+
+// QueueEntry is one task waiting in a queue, in the order workers will
+// take them. Raw holds the entry as stored when it cannot be decoded; a
+// worker will move such an entry to the poison queue.
+type QueueEntry struct {
+	Position int    `json:"position"`
+	Queue    string `json:"queue"`
+	Task     *Task  `json:"task,omitempty"`
+	Raw      string `json:"raw,omitempty"`
+}
+
+// QueuePeek is the head of a task type's queue, read without dequeuing.
+type QueuePeek struct {
+	TaskType  string       `json:"task_type"`
+	Pool      string       `json:"pool,omitempty"`
+	Namespace string       `json:"namespace,omitempty"`
+	Depth     int64        `json:"depth"`
+	Entries   []QueueEntry `json:"entries"`
+}
+
+// InjectedTask is a task put straight onto a queue to exercise a handler.
+// It belongs to no workflow and has no row in the store, so the status its
+// worker reports is kept here instead.
+type InjectedTask struct {
+	Task       Task                   `json:"task"`
+	Subject    string                 `json:"subject,omitempty"`
+	InjectedAt time.Time              `json:"injected_at"`
+	Status     TaskStatus             `json:"status"`
+	Result     map[string]interface{} `json:"result,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+	UpdatedAt  *time.Time             `json:"updated_at,omitempty"`
+}
+
+// NewInjectedTask builds a task to put straight onto a queue. It belongs
+// to no workflow and is not retried, so a failing handler shows up as a
+// failure right away.
+func NewInjectedTask(taskType, name string, payload map[string]interface{}) *Task {
+	if name == "" {
+		name = "injected-" + taskType
+	}
+	if payload == nil {
+		payload = map[string]interface{}{}
+	}
+
+	task := NewTask("", name, taskType, payload)
+	task.MaxRetries = 0
+	return task
+}
@@ -0,0 +1,54 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrQueueFull is wrapped by a BackpressureError when a task type's queue
+// holds as many tasks as its length limit allows.
+var ErrQueueFull = errors.New("queue is full")
+
+const (
+	// DefaultBackpressureRetryAfter is the retry hint given when a full
+	// queue has not drained at all recently, so no better estimate exists.
+	DefaultBackpressureRetryAfter = time.Second * 30
+	// MaxBackpressureRetryAfter caps the retry hint of a slowly draining
+	// queue.
+	MaxBackpressureRetryAfter = time.Minute * 5
+)
+
+// QueueLengthLimit caps how many tasks of a type may wait in Redis, ready
+// or delayed, so a backlog grows in Postgres, where tasks stay pending,
+// rather than in Redis memory. Tasks held by workers and retries that are
+// not yet due do not count.
+type QueueLengthLimit struct {
+	TaskType  string `json:"task_type"`
+	MaxLength int64  `json:"max_length"`
+}
+
+func (l *QueueLengthLimit) Validate() error {
+	if l.MaxLength < 1 {
+		return fmt.Errorf("max_length must be at least 1")
+	}
+	return nil
+}
+
+// BackpressureError rejects work for a task type whose queue is full.
+// RetryAfter estimates, from how fast the queue drained over the last
+// minute, when it will have room.
+type BackpressureError struct {
+	TaskType   string        `json:"task_type"`
+	Length     int64         `json:"length"`
+	MaxLength  int64         `json:"max_length"`
+	RetryAfter time.Duration `json:"retry_after"`
+}
+
+func (e *BackpressureError) Error() string {
+	return fmt.Sprintf("%s: task type %s has %d of %d tasks queued", ErrQueueFull, e.TaskType, e.Length, e.MaxLength)
+}
+
+func (e *BackpressureError) Unwrap() error {
+	return ErrQueueFull
+}
@@ -0,0 +1,54 @@
+package core
+
+import (
+	"fmt"
+	"time"
+)
+
+// MaxRateLimitInterval bounds the window of a task type's rate limit.
+const MaxRateLimitInterval = time.Hour * 24
+
+// RateLimitStage is where a task type's rate limit is enforced.
+type RateLimitStage string
+
+const (
+	// RateLimitAtDequeue lets the scheduler queue tasks freely and holds
+	// workers back once the type's limit is reached. It is the default.
+	RateLimitAtDequeue RateLimitStage = "dequeue"
+	// RateLimitAtEnqueue keeps tasks over the limit pending in the
+	// scheduler, so the queue never holds more than the limit allows.
+	RateLimitAtEnqueue RateLimitStage = "enqueue"
+)
+
+func (s RateLimitStage) Valid() bool {
+	return s == RateLimitAtDequeue || s == RateLimitAtEnqueue
+}
+
+// TaskRateLimit caps how many tasks of a type are dispatched in any window
+// of Interval, so the databases and external APIs its handlers call are not
+// overwhelmed. Tasks over the limit wait, in priority order, rather than
+// fail.
+type TaskRateLimit struct {
+	TaskType  string         `json:"task_type"`
+	Limit     int            `json:"limit"`
+	Interval  time.Duration  `json:"interval"`
+	EnforceAt RateLimitStage `json:"enforce_at"`
+}
+
+func (l *TaskRateLimit) Validate() error {
+	if l.Limit < 1 {
+		return fmt.Errorf("limit must be at least 1")
+	}
+	if l.Interval < time.Millisecond || l.Interval > MaxRateLimitInterval {
+		return fmt.Errorf("interval must be between 1ms and %s", MaxRateLimitInterval)
+	}
+	if !l.EnforceAt.Valid() {
+		return fmt.Errorf("enforce_at must be %s or %s", RateLimitAtDequeue, RateLimitAtEnqueue)
+	}
+	return nil
+}
+
+// String describes the limit, such as "10 per 1m0s".
+func (l *TaskRateLimit) String() string {
+	return fmt.Sprintf("%d per %s", l.Limit, l.Interval)
+}
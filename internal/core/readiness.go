@@ -0,0 +1,14 @@
+package core
+
+import "context"
+
+// CheckReadiness pings Redis and Postgres.
+func (s *Scheduler) CheckReadiness(ctx context.Context) *Readiness {
+	readiness := &Readiness{Broker: s.queue.CheckBroker(ctx)}
+	if err := s.store.Ping(ctx); err != nil {
+		readiness.DatabaseError = err.Error()
+	}
+
+	readiness.Ready = readiness.Broker.Status == BrokerStatusUp && readiness.DatabaseError == ""
+	return readiness
+}
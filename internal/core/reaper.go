@@ -0,0 +1,99 @@
+package core
+
+import (
+	"context"
+	"time"
+)
+
+const DefaultVisibilityTimeout = time.Minute * 5
+
+// SetVisibilityTimeout sets how long a dequeued task may go without its
+// worker touching it before the reaper puts it back on the queue.
+func (s *Scheduler) SetVisibilityTimeout(timeout time.Duration) {
+	s.visibilityTimeout = timeout
+}
+
+func (s *Scheduler) reapExpiredTasks(ctx context.Context) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(time.Second * 30)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			passCtx, leading := s.leaderContext()
+			if !leading {
+				continue
+			}
+			s.requeueExpiredTasks(passCtx)
+			s.expireStaleWorkers(passCtx)
+		}
+	}
+}
+
+func (s *Scheduler) requeueExpiredTasks(ctx context.Context) {
+	tasks, err := s.queue.RequeueExpiredTasks(ctx, s.visibilityTimeout)
+	if err != nil {
+		s.logger.Errorf("Failed to requeue expired tasks: %v", err)
+	}
+	s.markRequeued(ctx, tasks, TaskStatusQueued)
+}
+
+// expireStaleWorkers removes workers whose heartbeat lapsed and requeues
+// exactly the tasks they held, without waiting for those tasks' visibility
+// timeout. The tasks are marked retrying, as the interrupted run used up an
+// attempt, except those that had no retries left, which the broker
+// dead-lettered and are marked failed.
+func (s *Scheduler) expireStaleWorkers(ctx context.Context) int {
+	expired, tasks, err := s.queue.ExpireStaleWorkers(ctx)
+	if err != nil {
+		s.logger.Errorf("Failed to expire stale workers: %v", err)
+	}
+
+	var requeued []Task
+	workflows := make(map[string]bool)
+	for i := range tasks {
+		task := &tasks[i]
+		if task.Status != TaskStatusFailed {
+			requeued = append(requeued, *task)
+			continue
+		}
+		if err := s.setTaskStatus(ctx, task.WorkflowID, task.ID, TaskStatusFailed, nil, task.Error); err != nil {
+			s.logger.Errorf("Failed to mark task %s of a dead worker failed: %v", task.ID, err)
+			continue
+		}
+		s.recordTaskStatus(task, TaskStatusFailed)
+		workflows[task.WorkflowID] = true
+	}
+	s.markRequeued(ctx, requeued, TaskStatusRetrying)
+
+	for workflowID := range workflows {
+		if err := s.settleWorkflow(ctx, workflowID); err != nil {
+			s.logger.Errorf("Failed to settle workflow %s: %v", workflowID, err)
+		}
+	}
+	return expired
+}
+
+func (s *Scheduler) markRequeued(ctx context.Context, tasks []Task, status TaskStatus) {
+	workflows := make(map[string]bool)
+	for _, task := range tasks {
+		if err := s.setTaskStatus(ctx, task.WorkflowID, task.ID, status, nil, task.Error); err != nil {
+			s.logger.Errorf("Failed to mark requeued task %s %s: %v", task.ID, status, err)
+			continue
+		}
+		workflows[task.WorkflowID] = true
+	}
+
+	if status != TaskStatusRetrying {
+		return
+	}
+	for workflowID := range workflows {
+		s.checkRetryBudget(ctx, workflowID)
+	}
+}
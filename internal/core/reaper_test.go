@@ -0,0 +1,118 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExpireStaleWorkersFailsTasksOutOfRetries(t *testing.T) {
+	store := newFakeStore()
+	store.add(&Workflow{
+		ID:     "wf",
+		Status: WorkflowStatusRunning,
+		Tasks: []Task{
+			{ID: "requeued", WorkflowID: "wf", Name: "requeued", Type: "etl", Status: TaskStatusRunning},
+			{ID: "exhausted", WorkflowID: "wf", Name: "exhausted", Type: "etl", Status: TaskStatusRunning},
+		},
+	})
+	store.add(&Workflow{
+		ID:     "single",
+		Status: WorkflowStatusRunning,
+		Tasks: []Task{
+			{ID: "last", WorkflowID: "single", Name: "last", Type: "etl", Status: TaskStatusRunning},
+		},
+	})
+
+	broker := newFakeBroker()
+	broker.staleWorkerTasks = []Task{
+		{ID: "requeued", WorkflowID: "wf", Type: "etl", Status: TaskStatusRetrying, RetryCount: 1, MaxRetries: 3, Error: "worker w1 stopped heartbeating"},
+		{ID: "exhausted", WorkflowID: "wf", Type: "etl", Status: TaskStatusFailed, RetryCount: 3, MaxRetries: 3, Error: "worker w1 stopped heartbeating"},
+		{ID: "last", WorkflowID: "single", Type: "etl", Status: TaskStatusFailed, Error: "worker w1 stopped heartbeating"},
+	}
+
+	s := newTestScheduler(store, broker)
+	s.expireStaleWorkers(context.Background())
+
+	for id, want := range map[string]TaskStatus{
+		"requeued":  TaskStatusRetrying,
+		"exhausted": TaskStatusFailed,
+		"last":      TaskStatusFailed,
+	} {
+		if task, _ := store.FindTask(id); task.Status != want {
+			t.Errorf("task %s is %s, want %s", id, task.Status, want)
+		}
+	}
+	if task, _ := store.FindTask("exhausted"); task.Error != "worker w1 stopped heartbeating" {
+		t.Errorf("exhausted task error = %q, want the dead worker named", task.Error)
+	}
+
+	if workflow, _ := store.GetWorkflow("wf"); workflow.Status != WorkflowStatusRunning {
+		t.Errorf("workflow with a task left to retry is %s, want running", workflow.Status)
+	}
+	if workflow, _ := store.GetWorkflow("single"); workflow.Status != WorkflowStatusFailed {
+		t.Errorf("workflow whose only task ran out of retries is %s, want failed", workflow.Status)
+	}
+}
+
+func TestDeadLetterPolicyValidate(t *testing.T) {
+	for _, policy := range []DeadLetterPolicy{
+		{TaskType: "etl"},
+		{TaskType: "etl", MaxSize: -1, Overflow: DeadLetterDropOldest},
+		{TaskType: "etl", MaxSize: 100, Overflow: "shrug"},
+		{TaskType: "etl", MaxAge: time.Second},
+		{TaskType: "etl", MaxAge: -time.Hour},
+	} {
+		if err := policy.Validate(); err == nil {
+			t.Errorf("Validate() accepted %+v", policy)
+		}
+	}
+	for _, policy := range []DeadLetterPolicy{
+		{TaskType: "etl", MaxAge: MinDeadLetterMaxAge},
+		{TaskType: "etl", MaxSize: 100, Overflow: DeadLetterArchive, MaxAge: time.Hour * 24},
+	} {
+		if err := policy.Validate(); err != nil {
+			t.Errorf("Validate() rejected %+v: %v", policy, err)
+		}
+	}
+}
+
+func TestDeadLetterRetentionCountsPurgesByReason(t *testing.T) {
+	broker := newFakeBroker()
+	broker.deadLetterPurges = map[string][2]int64{
+		"etl":   {3, 0},
+		"video": {1, 5},
+	}
+	s := newTestScheduler(newFakeStore(), broker)
+
+	for _, policy := range []DeadLetterPolicy{
+		{TaskType: "etl", MaxAge: time.Hour},
+		{TaskType: "video", MaxSize: 10, Overflow: DeadLetterDropOldest, MaxAge: time.Hour},
+		{TaskType: "script", MaxAge: time.Hour},
+	} {
+		s.applyDeadLetterRetention(context.Background(), policy)
+	}
+	// Entries purged before an error still count.
+	broker.deadLetterErr = errors.New("redis: connection refused")
+	s.applyDeadLetterRetention(context.Background(), DeadLetterPolicy{TaskType: "etl", MaxAge: time.Hour})
+
+	w := httptest.NewRecorder()
+	s.metrics.registry.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	metrics := w.Body.String()
+	for _, want := range []string{
+		`flowctl_dead_letters_purged_total{type="etl",reason="max_age"} 6`,
+		`flowctl_dead_letters_purged_total{type="video",reason="max_age"} 1`,
+		`flowctl_dead_letters_purged_total{type="video",reason="max_size"} 5`,
+	} {
+		if !strings.Contains(metrics, want) {
+			t.Errorf("metrics are missing %s", want)
+		}
+	}
+	if strings.Contains(metrics, `type="script"`) || strings.Contains(metrics, `type="etl",reason="max_size"`) {
+		t.Error("metrics count purges that did not happen")
+	}
+}
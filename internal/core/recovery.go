@@ -0,0 +1,103 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// recoverState reconciles Postgres with Redis when this instance takes over
+// scheduling. A crash or restart can leave tasks marked queued, running or
+// retrying that no queue holds anymore, workers that will never heartbeat again and
+// schedules without a next run; none of them would otherwise recover.
+func (s *Scheduler) recoverState(ctx context.Context) {
+	defer s.wg.Done()
+
+	start := time.Now()
+
+	requeued, err := s.requeueOrphanedTasks(ctx, start)
+	if err != nil {
+		s.logger.Errorf("Failed to requeue orphaned tasks: %v", err)
+	}
+
+	expired := s.expireStaleWorkers(ctx)
+
+	resumed, err := s.resumeSchedules(ctx)
+	if err != nil {
+		s.logger.Errorf("Failed to resume schedules: %v", err)
+	}
+
+	s.logger.Infof("Recovery pass finished in %s: requeued %d tasks, expired %d workers, resumed %d schedules",
+		time.Since(start).Round(time.Millisecond), requeued, expired, resumed)
+}
+
+// requeueOrphanedTasks only considers tasks last updated before the pass
+// started, so a task this instance is dispatching right now is left alone.
+func (s *Scheduler) requeueOrphanedTasks(ctx context.Context, before time.Time) (int, error) {
+	tasks, err := s.store.GetInFlightTasks()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get in-flight tasks: %w", err)
+	}
+
+	tracked := make(map[string]map[string]bool)
+	requeued := 0
+	for i := range tasks {
+		task := &tasks[i]
+		if !task.UpdatedAt.Before(before) {
+			continue
+		}
+
+		ids, ok := tracked[task.Type]
+		if !ok {
+			ids, err = s.queue.TrackedTaskIDs(ctx, task.Type)
+			if err != nil {
+				return requeued, err
+			}
+			tracked[task.Type] = ids
+		}
+		if ids[task.ID] {
+			continue
+		}
+
+		if err := s.dispatchTask(ctx, task); err != nil {
+			s.logger.Errorf("Failed to requeue orphaned task %s: %v", task.ID, err)
+			continue
+		}
+		s.logger.Warnf("Requeued orphaned task %s (was %s)", task.ID, task.Status)
+		requeued++
+	}
+
+	return requeued, nil
+}
+
+// resumeSchedules gives enabled schedules that lost their next run time a
+// new one; schedules whose run fell due while no leader was active are
+// fired by the next schedule tick.
+func (s *Scheduler) resumeSchedules(ctx context.Context) (int, error) {
+	schedules, err := s.store.ListSchedules()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list schedules: %w", err)
+	}
+
+	now := time.Now()
+	resumed := 0
+	for _, schedule := range schedules {
+		if !schedule.Enabled || schedule.NextRunAt != nil {
+			continue
+		}
+
+		nextRunAt, err := schedule.Next(now)
+		if err != nil {
+			s.logger.Errorf("Failed to compute next run for schedule %s: %v", schedule.ID, err)
+			continue
+		}
+
+		if err := s.store.SetScheduleNextRun(schedule.ID, nextRunAt); err != nil {
+			s.logger.Errorf("Failed to resume schedule %s: %v", schedule.ID, err)
+			continue
+		}
+		resumed++
+	}
+
+	return resumed, nil
+}
@@ -0,0 +1,164 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+)
+
+const (
+	RedactedValue         = "[REDACTED]"
+	maxSampleStringLength = 256
+)
+
+// DefaultRedactedFields apply to every task type in addition to any
+// per-type rule.
+var DefaultRedactedFields = []string{
+	`(?i)password|passwd`,
+	`(?i)secret`,
+	`(?i)token`,
+	`(?i)api[_-]?key`,
+	`(?i)credential`,
+	`(?i)auth`,
+	`(?i)private[_-]?key`,
+}
+
+// Redactor masks sensitive fields in task payloads and results before they
+// reach logs, events, alerts or API responses.
+type Redactor interface {
+	Redact(taskType string, data map[string]interface{}) map[string]interface{}
+}
+
+// RedactionRule lists extra field name patterns, as regular expressions,
+// to mask for one task type.
+type RedactionRule struct {
+	TaskType string   `json:"task_type"`
+	Fields   []string `json:"fields"`
+}
+
+func (r *RedactionRule) Validate() error {
+	for _, field := range r.Fields {
+		if _, err := regexp.Compile(field); err != nil {
+			return fmt.Errorf("invalid field pattern %q: %w", field, err)
+		}
+	}
+	return nil
+}
+
+type PatternRedactor struct {
+	defaults []*regexp.Regexp
+	byType   map[string][]*regexp.Regexp
+}
+
+func NewPatternRedactor(rules []RedactionRule) (*PatternRedactor, error) {
+	r := &PatternRedactor{byType: make(map[string][]*regexp.Regexp)}
+
+	for _, field := range DefaultRedactedFields {
+		r.defaults = append(r.defaults, regexp.MustCompile(field))
+	}
+
+	for _, rule := range rules {
+		for _, field := range rule.Fields {
+			pattern, err := regexp.Compile(field)
+			if err != nil {
+				return nil, fmt.Errorf("invalid field pattern %q for task type %s: %w", field, rule.TaskType, err)
+			}
+			r.byType[rule.TaskType] = append(r.byType[rule.TaskType], pattern)
+		}
+	}
+
+	return r, nil
+}
+
+func (r *PatternRedactor) Redact(taskType string, data map[string]interface{}) map[string]interface{} {
+	return r.redactMap(r.byType[taskType], data)
+}
+
+func (r *PatternRedactor) redactMap(typePatterns []*regexp.Regexp, data map[string]interface{}) map[string]interface{} {
+	if data == nil {
+		return nil
+	}
+
+	redacted := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		if r.sensitive(typePatterns, key) {
+			redacted[key] = RedactedValue
+			continue
+		}
+		redacted[key] = r.redactValue(typePatterns, value)
+	}
+	return redacted
+}
+
+func (r *PatternRedactor) redactValue(typePatterns []*regexp.Regexp, value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return r.redactMap(typePatterns, v)
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = r.redactValue(typePatterns, item)
+		}
+		return items
+	default:
+		return v
+	}
+}
+
+func (r *PatternRedactor) sensitive(typePatterns []*regexp.Regexp, key string) bool {
+	for _, pattern := range r.defaults {
+		if pattern.MatchString(key) {
+			return true
+		}
+	}
+	for _, pattern := range typePatterns {
+		if pattern.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactTask returns a copy of task with its payload, result and run
+// params redacted.
+func RedactTask(redactor Redactor, task Task) Task {
+	task.Payload = redactor.Redact(task.Type, task.Payload)
+	task.Result = redactor.Redact(task.Type, task.Result)
+	if task.Execution != nil {
+		execution := *task.Execution
+		execution.Params = redactor.Redact(task.Type, execution.Params)
+		task.Execution = &execution
+	}
+	return task
+}
+
+// SamplePayload returns a redacted copy of payload with long strings
+// truncated, suitable for notifications sent to external systems.
+func SamplePayload(redactor Redactor, taskType string, payload map[string]interface{}) map[string]interface{} {
+	sample := redactor.Redact(taskType, payload)
+	for key, value := range sample {
+		sample[key] = truncateValue(value)
+	}
+	return sample
+}
+
+func truncateValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = truncateValue(item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = truncateValue(item)
+		}
+		return v
+	case string:
+		if len(v) > maxSampleStringLength {
+			return v[:maxSampleStringLength] + "..."
+		}
+		return v
+	default:
+		return v
+	}
+}
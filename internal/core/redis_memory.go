@@ -0,0 +1,76 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SafeEvictionPolicy is the only maxmemory-policy under which Redis never
+// drops a key to make room. Under any other policy a full Redis silently
+// loses queued tasks, leases and retry sets.
+const SafeEvictionPolicy = "noeviction"
+
+const DefaultMemoryCheckInterval = time.Minute
+
+// ErrUnsafeEvictionPolicy is returned when Redis may evict keys under
+// memory pressure.
+var ErrUnsafeEvictionPolicy = errors.New("redis may evict queued tasks")
+
+// RedisMemory is how much memory Redis uses and what it does once full.
+// MaxBytes is zero when Redis has no memory limit. EvictedKeys counts every
+// key Redis has evicted since it started.
+type RedisMemory struct {
+	UsedBytes      int64     `json:"used_bytes"`
+	MaxBytes       int64     `json:"max_bytes"`
+	EvictionPolicy string    `json:"eviction_policy"`
+	EvictionSafe   bool      `json:"eviction_safe"`
+	EvictedKeys    int64     `json:"evicted_keys"`
+	CheckedAt      time.Time `json:"checked_at"`
+}
+
+// CheckEviction returns an error wrapping ErrUnsafeEvictionPolicy unless
+// Redis is configured never to evict keys.
+func (m *RedisMemory) CheckEviction() error {
+	if m.EvictionPolicy != SafeEvictionPolicy {
+		return fmt.Errorf("%w: maxmemory-policy is %s, set it to %s", ErrUnsafeEvictionPolicy, m.EvictionPolicy, SafeEvictionPolicy)
+	}
+	return nil
+}
+
+// QueueMemoryUsage estimates the memory held by the queues of one task
+// type: its shared, pool and namespace queues and their retry lanes, and
+// its processing, retry, delayed, poison and dead letter sets. Redis
+// samples large keys, so Bytes is approximate.
+type QueueMemoryUsage struct {
+	TaskType string `json:"task_type"`
+	Bytes    int64  `json:"bytes"`
+	Keys     int    `json:"keys"`
+}
+
+// RedisMemoryReport is Redis's memory use with the share each task type's
+// queues take, largest first.
+type RedisMemoryReport struct {
+	RedisMemory
+	Queues []QueueMemoryUsage `json:"queues"`
+}
+
+// NewBrokerEvent records a problem with Redis itself. No workflow is
+// involved.
+func NewBrokerEvent(eventType EventType, errorMsg string, memory *RedisMemory, data map[string]interface{}) *Event {
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	data["eviction_policy"] = memory.EvictionPolicy
+	data["used_bytes"] = memory.UsedBytes
+	data["max_bytes"] = memory.MaxBytes
+	data["evicted_keys"] = memory.EvictedKeys
+
+	return &Event{
+		Type:      eventType,
+		Status:    "alert",
+		Error:     errorMsg,
+		Timestamp: time.Now(),
+		Data:      data,
+	}
+}
@@ -0,0 +1,109 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Rerun builds a fresh run of the workflow: new IDs and statuses, but the
+// same settings, tasks, payloads, expiry times and dependencies, those
+// given by task ID pointing at the new IDs. Overrides, keyed by task name,
+// replace top-level payload fields of those tasks; a null value removes the
+// field. Idempotency keys are not carried over, since the point of a rerun
+// is to execute the tasks again rather than reuse the results of the
+// original run.
+func (w *Workflow) Rerun(overrides map[string]map[string]interface{}) (*Workflow, error) {
+	tasksByName := make(map[string]bool, len(w.Tasks))
+	for _, task := range w.Tasks {
+		tasksByName[task.Name] = true
+	}
+	for name := range overrides {
+		if !tasksByName[name] {
+			return nil, fmt.Errorf("override names unknown task %s", name)
+		}
+	}
+
+	rerun := NewWorkflow(w.Name, w.Description)
+	rerun.Namespace = w.Namespace
+	rerun.Priority = w.Priority
+	rerun.Pool = w.Pool
+	rerun.Owner = w.Owner
+	rerun.DocsURL = w.DocsURL
+	rerun.RunbookURL = w.RunbookURL
+	rerun.Config = w.Config
+	rerun.Labels = w.Labels
+
+	params, err := copyPayload(w.Params)
+	if err != nil {
+		return nil, fmt.Errorf("params: %w", err)
+	}
+	if len(params) > 0 {
+		rerun.Params = params
+	}
+
+	newIDs := make(map[string]string, len(w.Tasks))
+	for _, original := range w.Tasks {
+		payload, err := copyPayload(original.Payload)
+		if err != nil {
+			return nil, fmt.Errorf("task %s: %w", original.Name, err)
+		}
+		for field, value := range overrides[original.Name] {
+			if value == nil {
+				delete(payload, field)
+				continue
+			}
+			payload[field] = value
+		}
+
+		task := NewTask(rerun.ID, original.Name, original.Type, payload)
+		task.MaxRetries = original.MaxRetries
+		task.Priority = original.Priority
+		task.Timeout = original.Timeout
+		task.RunAt = original.RunAt
+		task.ExpiresAt = original.ExpiresAt
+		task.RunOnUpstreamFailure = original.RunOnUpstreamFailure
+		task.Executor = original.Executor
+		task.Resources = original.Resources
+		task.Selector = original.Selector
+		task.Dedupe = original.Dedupe
+		task.Pool = original.Pool
+		task.Group = original.Group
+		task.Owner = original.Owner
+		task.DocsURL = original.DocsURL
+		task.RunbookURL = original.RunbookURL
+
+		newIDs[original.ID] = task.ID
+		rerun.Tasks = append(rerun.Tasks, *task)
+	}
+
+	for i, original := range w.Tasks {
+		dependencies := make([]string, 0, len(original.Dependencies))
+		for _, dep := range original.Dependencies {
+			if id, ok := newIDs[dep]; ok {
+				dep = id
+			}
+			dependencies = append(dependencies, dep)
+		}
+		rerun.Tasks[i].Dependencies = dependencies
+	}
+
+	return rerun, nil
+}
+
+// copyPayload deep-copies a JSON payload so a rerun never shares nested
+// maps or slices with the run it was cloned from.
+func copyPayload(payload map[string]interface{}) (map[string]interface{}, error) {
+	copied := make(map[string]interface{}, len(payload))
+	if len(payload) == 0 {
+		return copied, nil
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy payload: %w", err)
+	}
+	if err := json.Unmarshal(data, &copied); err != nil {
+		return nil, fmt.Errorf("failed to copy payload: %w", err)
+	}
+	return copied, nil
+}
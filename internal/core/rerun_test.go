@@ -0,0 +1,38 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRerunRemapsDependenciesAndKeepsExpiry(t *testing.T) {
+	expiresAt := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	original := NewWorkflow("nightly", "")
+	extract := NewTask(original.ID, "extract", "etl", nil)
+	extract.ExpiresAt = &expiresAt
+	load := NewTask(original.ID, "load", "etl", nil)
+	load.Dependencies = []string{extract.ID}
+	report := NewTask(original.ID, "report", "etl", nil)
+	report.Dependencies = []string{"load"}
+	original.Tasks = []Task{*extract, *load, *report}
+
+	rerun, err := original.Rerun(nil)
+	if err != nil {
+		t.Fatalf("Rerun() error = %v", err)
+	}
+
+	newExtract, newLoad, newReport := rerun.Tasks[0], rerun.Tasks[1], rerun.Tasks[2]
+	if newExtract.ID == extract.ID {
+		t.Fatalf("rerun kept task ID %s", extract.ID)
+	}
+	if len(newLoad.Dependencies) != 1 || newLoad.Dependencies[0] != newExtract.ID {
+		t.Errorf("load depends on %v, want the rerun's extract %s", newLoad.Dependencies, newExtract.ID)
+	}
+	if len(newReport.Dependencies) != 1 || newReport.Dependencies[0] != "load" {
+		t.Errorf("report depends on %v, want load by name", newReport.Dependencies)
+	}
+	if newExtract.ExpiresAt == nil || !newExtract.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("extract expires at %v, want %v", newExtract.ExpiresAt, expiresAt)
+	}
+}
@@ -0,0 +1,78 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// DefaultMaxResultSize is the largest task result, in bytes of JSON, that is
+// stored as reported.
+const DefaultMaxResultSize = 256 * 1024
+
+// SetMaxResultSize sets the largest task result stored as reported. Larger
+// results are truncated; zero stores every result in full.
+func (s *Scheduler) SetMaxResultSize(bytes int) {
+	s.maxResultSize = bytes
+}
+
+// TruncateResult fits a result into maxBytes of JSON. An oversize result
+// keeps as many of its top-level fields as fit, in key order, and is marked
+// with "truncated": true, its "original_size_bytes" and the number of
+// "omitted_fields". It reports whether the result was truncated.
+func TruncateResult(result map[string]interface{}, maxBytes int) (map[string]interface{}, bool, error) {
+	if result == nil || maxBytes <= 0 {
+		return result, false, nil
+	}
+
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to marshal result: %w", err)
+	}
+	if len(encoded) <= maxBytes {
+		return result, false, nil
+	}
+
+	truncated := map[string]interface{}{
+		"truncated":           true,
+		"original_size_bytes": len(encoded),
+		"omitted_fields":      len(result),
+	}
+	marker, err := json.Marshal(truncated)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to marshal result: %w", err)
+	}
+
+	keys := make([]string, 0, len(result))
+	for key := range result {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	budget := maxBytes - len(marker)
+	omitted := 0
+	for _, key := range keys {
+		if _, reserved := truncated[key]; reserved {
+			omitted++
+			continue
+		}
+
+		field, err := json.Marshal(map[string]interface{}{key: result[key]})
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to marshal result field %s: %w", key, err)
+		}
+		// The field without its braces, plus the comma separating it from
+		// the next one.
+		cost := len(field) - 2 + 1
+		if cost > budget {
+			omitted++
+			continue
+		}
+
+		truncated[key] = result[key]
+		budget -= cost
+	}
+	truncated["omitted_fields"] = omitted
+
+	return truncated, true, nil
+}
@@ -0,0 +1,48 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+)
+
+type RetryOverloadAction string
+
+const (
+	RetryOverloadEvict    RetryOverloadAction = "evict"
+	RetryOverloadFastFail RetryOverloadAction = "fast_fail"
+)
+
+// ErrRetryShed is returned by NackTask when a failed task is not retried
+// because its type's retry set is overloaded.
+var ErrRetryShed = errors.New("retry set is overloaded")
+
+// RetryOverloadPolicy keeps a failure storm of low-priority tasks from
+// delaying the retries of critical ones. Once more than MaxSize tasks of a
+// type wait to retry, evict fails the lowest-priority ones until MaxSize
+// remain, while fast_fail fails new failures outright instead of retrying
+// them. Tasks of ProtectPriority or higher are never shed; zero protects
+// none.
+type RetryOverloadPolicy struct {
+	TaskType        string              `json:"task_type"`
+	MaxSize         int64               `json:"max_size"`
+	Action          RetryOverloadAction `json:"action"`
+	ProtectPriority int                 `json:"protect_priority,omitempty"`
+}
+
+func (a RetryOverloadAction) Valid() bool {
+	switch a {
+	case RetryOverloadEvict, RetryOverloadFastFail:
+		return true
+	}
+	return false
+}
+
+// Sheds reports whether the policy may shed a task of the given priority.
+func (p *RetryOverloadPolicy) Sheds(priority int) bool {
+	return p.ProtectPriority == 0 || priority < p.ProtectPriority
+}
+
+// EvictionReason is the error recorded on a task evicted under the policy.
+func (p *RetryOverloadPolicy) EvictionReason(task *Task) string {
+	return fmt.Sprintf("evicted from the overloaded retry set of %s (more than %d waiting); last error: %s", p.TaskType, p.MaxSize, task.Error)
+}
@@ -0,0 +1,91 @@
+package core
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPromoteRetriesCoversEveryTaskType(t *testing.T) {
+	broker := newFakeBroker()
+	broker.types = []string{"etl", "script", "video_transcode"}
+
+	s := newTestScheduler(newFakeStore(), broker)
+	s.promoteRetries(context.Background())
+
+	if !reflect.DeepEqual(broker.retried, broker.types) {
+		t.Errorf("retries promoted for %v, want every task type %v", broker.retried, broker.types)
+	}
+}
+
+func TestJitterModeValid(t *testing.T) {
+	tests := []struct {
+		mode JitterMode
+		want bool
+	}{
+		{"", true},
+		{JitterNone, true},
+		{JitterFull, true},
+		{JitterEqual, true},
+		{JitterDecorrelated, true},
+		{"Full", false},
+		{"random", false},
+	}
+	for _, tt := range tests {
+		if got := tt.mode.Valid(); got != tt.want {
+			t.Errorf("JitterMode(%q).Valid() = %v, want %v", tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     RetryPolicy
+		retryCount int
+		want       time.Duration
+	}{
+		{"first retry", RetryPolicy{InitialDelay: time.Second, MaxDelay: time.Minute, BackoffFactor: 2}, 0, time.Second},
+		{"grows by the factor", RetryPolicy{InitialDelay: time.Second, MaxDelay: time.Minute, BackoffFactor: 3}, 2, 9 * time.Second},
+		{"capped at max delay", RetryPolicy{InitialDelay: time.Second, MaxDelay: time.Minute, BackoffFactor: 2}, 10, time.Minute},
+		{"zero fields use the defaults", RetryPolicy{}, 3, 8 * time.Second},
+		{"default cap", RetryPolicy{}, 20, DefaultRetryPolicy.MaxDelay},
+		{"factor below one uses the default", RetryPolicy{InitialDelay: time.Second, BackoffFactor: 0.5}, 2, 4 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := tt.policy.Backoff(tt.retryCount); got != tt.want {
+			t.Errorf("%s: Backoff(%d) = %s, want %s", tt.name, tt.retryCount, got, tt.want)
+		}
+	}
+}
+
+func TestRetryPolicyDelayStaysWithinJitterBounds(t *testing.T) {
+	base := RetryPolicy{InitialDelay: time.Second, MaxDelay: time.Minute, BackoffFactor: 2}
+	tests := []struct {
+		jitter   JitterMode
+		retry    int
+		previous time.Duration
+		min, max time.Duration
+	}{
+		{"", 3, 0, 8 * time.Second, 8 * time.Second},
+		{JitterNone, 3, 0, 8 * time.Second, 8 * time.Second},
+		{JitterFull, 3, 0, 0, 8 * time.Second},
+		{JitterEqual, 3, 0, 4 * time.Second, 8 * time.Second},
+		{JitterDecorrelated, 0, 0, time.Second, 3 * time.Second},
+		{JitterDecorrelated, 3, 5 * time.Second, time.Second, 15 * time.Second},
+		// Three times the previous delay would pass MaxDelay.
+		{JitterDecorrelated, 3, 50 * time.Second, time.Second, time.Minute},
+	}
+	for _, tt := range tests {
+		policy := base
+		policy.Jitter = tt.jitter
+		for i := 0; i < 200; i++ {
+			got := policy.Delay(tt.retry, tt.previous)
+			if got < tt.min || got > tt.max {
+				t.Errorf("%q jitter: Delay(%d, %s) = %s, want within [%s, %s]", tt.jitter, tt.retry, tt.previous, got, tt.min, tt.max)
+				break
+			}
+		}
+	}
+}
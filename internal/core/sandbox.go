@@ -0,0 +1,143 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+)
+
+const DefaultNamespace = "default"
+
+type Executor string
+
+const (
+	ExecutorBuiltin Executor = "builtin"
+	ExecutorShell   Executor = "shell"
+	ExecutorDocker  Executor = "docker"
+	ExecutorK8s     Executor = "k8s"
+	ExecutorWasm    Executor = "wasm"
+)
+
+// DefaultExecutor runs tasks that do not name an executor: the worker's
+// built-in handlers, in the worker process.
+const DefaultExecutor = ExecutorBuiltin
+
+func (e Executor) Valid() bool {
+	switch e {
+	case ExecutorBuiltin, ExecutorShell, ExecutorDocker, ExecutorK8s, ExecutorWasm:
+		return true
+	}
+	return false
+}
+
+// ResourceRequest is the CPU and memory a task asks its executor for.
+type ResourceRequest struct {
+	CPU      float64 `json:"cpu,omitempty" yaml:"cpu,omitempty"`
+	MemoryMB int64   `json:"memory_mb,omitempty" yaml:"memory_mb,omitempty"`
+}
+
+var ErrSandboxViolation = errors.New("sandbox policy violation")
+
+// SandboxPolicy restricts the executors tasks in a namespace may request and
+// caps the resources they may ask for. Tasks that do not request resources
+// get the defaults, or else the ceilings. Namespaces without a policy are
+// unrestricted.
+type SandboxPolicy struct {
+	Namespace        string     `json:"namespace"`
+	AllowedExecutors []Executor `json:"allowed_executors"`
+	MaxCPU           float64    `json:"max_cpu,omitempty"`
+	MaxMemoryMB      int64      `json:"max_memory_mb,omitempty"`
+	DefaultCPU       float64    `json:"default_cpu,omitempty"`
+	DefaultMemoryMB  int64      `json:"default_memory_mb,omitempty"`
+}
+
+func (p *SandboxPolicy) Validate() error {
+	for _, executor := range p.AllowedExecutors {
+		if !executor.Valid() {
+			return fmt.Errorf("unknown executor %q", executor)
+		}
+	}
+	if p.MaxCPU < 0 || p.MaxMemoryMB < 0 {
+		return fmt.Errorf("resource ceilings must not be negative")
+	}
+	if p.DefaultCPU < 0 || p.DefaultMemoryMB < 0 {
+		return fmt.Errorf("default resources must not be negative")
+	}
+	if (p.MaxCPU > 0 && p.DefaultCPU > p.MaxCPU) || (p.MaxMemoryMB > 0 && p.DefaultMemoryMB > p.MaxMemoryMB) {
+		return fmt.Errorf("default resources must not exceed the ceilings")
+	}
+	return nil
+}
+
+// Resources returns the resources task runs with in the namespace: what it
+// requests and, for what it does not, the policy's default or else its
+// ceiling. It is nil when that leaves nothing requested.
+func (p *SandboxPolicy) Resources(task *Task) *ResourceRequest {
+	var resources ResourceRequest
+	if task.Resources != nil {
+		resources = *task.Resources
+	}
+	if resources.CPU == 0 {
+		resources.CPU = p.DefaultCPU
+		if resources.CPU == 0 {
+			resources.CPU = p.MaxCPU
+		}
+	}
+	if resources.MemoryMB == 0 {
+		resources.MemoryMB = p.DefaultMemoryMB
+		if resources.MemoryMB == 0 {
+			resources.MemoryMB = p.MaxMemoryMB
+		}
+	}
+	if resources.CPU == 0 && resources.MemoryMB == 0 {
+		return nil
+	}
+	return &resources
+}
+
+func (p *SandboxPolicy) Allows(executor Executor) bool {
+	for _, allowed := range p.AllowedExecutors {
+		if allowed == executor {
+			return true
+		}
+	}
+	return false
+}
+
+// Check returns an ErrSandboxViolation describing the first task in workflow
+// that the policy does not permit.
+func (p *SandboxPolicy) Check(workflow *Workflow) error {
+	for i := range workflow.Tasks {
+		if err := p.CheckTask(&workflow.Tasks[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckTask returns an ErrSandboxViolation if the policy does not permit
+// task. A task without an executor runs on DefaultExecutor, and one without
+// resources gets those of Resources, so both are checked like any other.
+func (p *SandboxPolicy) CheckTask(task *Task) error {
+	executor := task.Executor
+	if executor == "" {
+		executor = DefaultExecutor
+	}
+	if !p.Allows(executor) {
+		return fmt.Errorf("%w: task %s runs on executor %s, which namespace %s does not allow",
+			ErrSandboxViolation, task.Name, executor, p.Namespace)
+	}
+
+	resources := p.Resources(task)
+	if resources == nil {
+		return nil
+	}
+	if p.MaxCPU > 0 && resources.CPU > p.MaxCPU {
+		return fmt.Errorf("%w: task %s requests %.2f CPU, namespace %s allows at most %.2f",
+			ErrSandboxViolation, task.Name, resources.CPU, p.Namespace, p.MaxCPU)
+	}
+	if p.MaxMemoryMB > 0 && resources.MemoryMB > p.MaxMemoryMB {
+		return fmt.Errorf("%w: task %s requests %d MB memory, namespace %s allows at most %d MB",
+			ErrSandboxViolation, task.Name, resources.MemoryMB, p.Namespace, p.MaxMemoryMB)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSchedulerFailsTasksTheSandboxPolicyForbids(t *testing.T) {
+	store := newFakeStore()
+	broker := newFakeBroker()
+	broker.policies = map[string]*SandboxPolicy{
+		"team-a": {Namespace: "team-a", AllowedExecutors: []Executor{ExecutorDocker}},
+	}
+
+	workflow := &Workflow{ID: "wf-1", Namespace: "team-a", Status: WorkflowStatusRunning}
+	workflow.Tasks = []Task{
+		{ID: "shell", WorkflowID: "wf-1", Name: "shell", Type: "generic", Status: TaskStatusPending, Executor: ExecutorShell},
+		{ID: "docker", WorkflowID: "wf-1", Name: "docker", Type: "generic", Status: TaskStatusPending, Executor: ExecutorDocker},
+	}
+	store.add(workflow)
+
+	s := newTestScheduler(store, broker)
+	ready, _, err := s.scheduleWorkflowTasks(context.Background(), workflow, append([]Task(nil), workflow.Tasks...))
+	if err != nil {
+		t.Fatalf("scheduleWorkflowTasks: %v", err)
+	}
+
+	if len(ready) != 1 || ready[0].ID != "docker" {
+		t.Fatalf("ready tasks = %+v, want only the docker task", ready)
+	}
+	shell, _ := store.GetTask("shell")
+	if shell.Status != TaskStatusFailed || !strings.Contains(shell.Error, "does not allow") {
+		t.Errorf("shell task is %s with error %q, want it failed by the sandbox policy", shell.Status, shell.Error)
+	}
+}
+
+func TestSchedulerDispatchesTasksWithoutSandboxPolicy(t *testing.T) {
+	store := newFakeStore()
+	broker := newFakeBroker()
+
+	workflow := &Workflow{ID: "wf-1", Namespace: "team-b", Status: WorkflowStatusRunning}
+	workflow.Tasks = []Task{
+		{ID: "shell", WorkflowID: "wf-1", Name: "shell", Type: "generic", Status: TaskStatusPending, Executor: ExecutorShell},
+	}
+	store.add(workflow)
+
+	s := newTestScheduler(store, broker)
+	ready, _, err := s.scheduleWorkflowTasks(context.Background(), workflow, append([]Task(nil), workflow.Tasks...))
+	if err != nil {
+		t.Fatalf("scheduleWorkflowTasks: %v", err)
+	}
+	if len(ready) != 1 {
+		t.Fatalf("ready tasks = %+v, want the shell task", ready)
+	}
+}
+
+func TestSandboxPolicyChecksDefaultExecutorAndResources(t *testing.T) {
+	policy := &SandboxPolicy{
+		Namespace:        "team-a",
+		AllowedExecutors: []Executor{ExecutorDocker},
+		MaxCPU:           2,
+		MaxMemoryMB:      1024,
+		DefaultCPU:       0.5,
+	}
+
+	if err := policy.CheckTask(&Task{Name: "builtin"}); !errors.Is(err, ErrSandboxViolation) {
+		t.Errorf("CheckTask() of a task without an executor = %v, want ErrSandboxViolation", err)
+	}
+
+	policy.AllowedExecutors = append(policy.AllowedExecutors, ExecutorBuiltin)
+	task := &Task{Name: "builtin"}
+	if err := policy.CheckTask(task); err != nil {
+		t.Fatalf("CheckTask() with builtin allowed = %v", err)
+	}
+	if got := policy.Resources(task); got == nil || got.CPU != 0.5 || got.MemoryMB != 1024 {
+		t.Errorf("Resources() = %+v, want the default CPU and the memory ceiling", got)
+	}
+
+	policy.DefaultCPU = 4
+	if err := policy.Validate(); err == nil {
+		t.Error("Validate() accepted a default above the ceiling")
+	}
+	if err := policy.CheckTask(&Task{Name: "big", Resources: &ResourceRequest{MemoryMB: 256}}); !errors.Is(err, ErrSandboxViolation) {
+		t.Errorf("CheckTask() with a default above the ceiling = %v, want ErrSandboxViolation", err)
+	}
+}
+
+func TestSchedulerDispatchesTasksWithPolicyResources(t *testing.T) {
+	store := newFakeStore()
+	broker := newFakeBroker()
+	broker.policies = map[string]*SandboxPolicy{
+		"team-a": {Namespace: "team-a", AllowedExecutors: []Executor{ExecutorBuiltin}, MaxCPU: 1, DefaultMemoryMB: 256},
+	}
+
+	workflow := &Workflow{ID: "wf-1", Namespace: "team-a", Status: WorkflowStatusRunning}
+	workflow.Tasks = []Task{
+		{ID: "plain", WorkflowID: "wf-1", Name: "plain", Type: "generic", Status: TaskStatusPending},
+	}
+	store.add(workflow)
+
+	s := newTestScheduler(store, broker)
+	ready, _, err := s.scheduleWorkflowTasks(context.Background(), workflow, append([]Task(nil), workflow.Tasks...))
+	if err != nil {
+		t.Fatalf("scheduleWorkflowTasks: %v", err)
+	}
+	if len(ready) != 1 || ready[0].Resources == nil || ready[0].Resources.CPU != 1 || ready[0].Resources.MemoryMB != 256 {
+		t.Fatalf("ready tasks = %+v, want the plain task with 1 CPU and 256 MB", ready)
+	}
+}
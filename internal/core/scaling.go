@@ -0,0 +1,103 @@
+package core
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// QueueScalingSignal reports the backlog of a task type, how long its oldest
+// ready task has waited, and how many live workers handle it.
+func (s *Scheduler) QueueScalingSignal(ctx context.Context, taskType string) (*QueueScalingSignal, error) {
+	stats, err := s.queue.GetQueueStats(ctx, taskType)
+	if err != nil {
+		return nil, err
+	}
+
+	oldest, err := s.queue.GetOldestEnqueuedAt(ctx, taskType)
+	if err != nil {
+		return nil, err
+	}
+
+	workers, err := s.queue.GetActiveWorkers(ctx, taskType)
+	if err != nil {
+		return nil, err
+	}
+
+	paused, err := s.queue.IsQueuePaused(ctx, taskType)
+	if err != nil {
+		return nil, err
+	}
+
+	selectorBacklog, err := s.queue.GetSelectorBacklog(ctx, taskType)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	signal := &QueueScalingSignal{
+		TaskType:    taskType,
+		Backlog:     stats["pending"],
+		InFlight:    stats["processing"],
+		Workers:     len(workers),
+		Paused:      paused,
+		GeneratedAt: now,
+	}
+	if oldest != nil && oldest.Before(now) {
+		signal.OldestTaskAgeSeconds = now.Sub(*oldest).Seconds()
+	}
+	for _, worker := range workers {
+		if len(worker.CurrentTasks) > 0 {
+			signal.BusyWorkers++
+		}
+	}
+	signal.UnmatchedSelectors, signal.UnmatchedBacklog = s.unmatchedSelectors(selectorBacklog, workers)
+	return signal, nil
+}
+
+// unmatchedSelectors returns, in order, the selectors of a backlog that no
+// worker's labels match, and how many tasks wait for them.
+func (s *Scheduler) unmatchedSelectors(backlog map[string]int64, workers []WorkerInfo) ([]string, int64) {
+	var unmatched []string
+	var waiting int64
+	for formatted, n := range backlog {
+		selector, err := ParseSelector(formatted)
+		if err != nil {
+			s.logger.Warnf("Ignoring task selector: %v", err)
+			continue
+		}
+
+		matched := false
+		for _, worker := range workers {
+			if SelectorMatches(selector, worker.Labels) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			unmatched = append(unmatched, formatted)
+			waiting += n
+		}
+	}
+	sort.Strings(unmatched)
+	return unmatched, waiting
+}
+
+// QueueScalingSignals reports the scaling signal of every task type with a
+// queue or worker in Redis, ordered by type.
+func (s *Scheduler) QueueScalingSignals(ctx context.Context) ([]QueueScalingSignal, error) {
+	taskTypes, err := s.queue.GetTaskTypes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	signals := make([]QueueScalingSignal, 0, len(taskTypes))
+	for _, taskType := range taskTypes {
+		signal, err := s.QueueScalingSignal(ctx, taskType)
+		if err != nil {
+			return nil, err
+		}
+		signals = append(signals, *signal)
+	}
+	return signals, nil
+}
@@ -0,0 +1,37 @@
+package core
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestScalingSignalReportsSelectorsNoWorkerMatches(t *testing.T) {
+	broker := newFakeBroker()
+	broker.workers = []WorkerInfo{
+		{ID: "w-1", Labels: map[string]string{"gpu": "true", "zone": "us-east-1"}},
+		{ID: "w-2"},
+	}
+	broker.selectorBacklog = map[string]int64{
+		"gpu=true":                4,
+		"zone=eu-west-1":          2,
+		"arch=arm64,gpu=true":     3,
+		"gpu=true,zone=us-east-1": 1,
+	}
+	s := newTestScheduler(newFakeStore(), broker)
+
+	signal, err := s.QueueScalingSignal(context.Background(), "etl")
+	if err != nil {
+		t.Fatalf("QueueScalingSignal() error = %v", err)
+	}
+
+	if want := []string{"arch=arm64,gpu=true", "zone=eu-west-1"}; !reflect.DeepEqual(signal.UnmatchedSelectors, want) {
+		t.Errorf("UnmatchedSelectors = %v, want %v", signal.UnmatchedSelectors, want)
+	}
+	if signal.UnmatchedBacklog != 5 {
+		t.Errorf("UnmatchedBacklog = %d, want 5", signal.UnmatchedBacklog)
+	}
+	if signal.Backlog != 10 {
+		t.Errorf("Backlog = %d, want every ready task", signal.Backlog)
+	}
+}
@@ -0,0 +1,131 @@
+package core
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/robfig/cron/v3"
+)
+
+const MaxSchedulePreviewRuns = 100
+
+type Schedule struct {
+	ID        string             `json:"id" db:"id"`
+	Name      string             `json:"name" db:"name"`
+	Cron      string             `json:"cron" db:"cron_expr"`
+	Timezone  string             `json:"timezone" db:"timezone"`
+	Workflow  WorkflowDefinition `json:"workflow" db:"workflow"`
+	Enabled   bool               `json:"enabled" db:"enabled"`
+	Calendar  *Calendar          `json:"calendar,omitempty" db:"calendar"`
+	LastRunAt *time.Time         `json:"last_run_at,omitempty" db:"last_run_at"`
+	NextRunAt *time.Time         `json:"next_run_at,omitempty" db:"next_run_at"`
+	CreatedAt time.Time          `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at" db:"updated_at"`
+}
+
+func NewSchedule(name, cronExpr, timezone string, workflow WorkflowDefinition) *Schedule {
+	if timezone == "" {
+		timezone = "UTC"
+	}
+
+	return &Schedule{
+		ID:        uuid.New().String(),
+		Name:      name,
+		Cron:      cronExpr,
+		Timezone:  timezone,
+		Workflow:  workflow,
+		Enabled:   true,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+}
+
+func (s *Schedule) Validate() error {
+	if _, err := s.Next(time.Now()); err != nil {
+		return err
+	}
+
+	if len(s.Workflow.Tasks) == 0 {
+		return fmt.Errorf("schedule workflow has no tasks")
+	}
+
+	if s.Workflow.HasFixedIDs() {
+		return fmt.Errorf("schedule workflow cannot set ids, each run is assigned new ones")
+	}
+
+	if err := s.Workflow.Validate(); err != nil {
+		return err
+	}
+
+	if s.Calendar != nil {
+		if err := s.Calendar.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Schedule) Next(after time.Time) (time.Time, error) {
+	location, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timezone %q: %w", s.Timezone, err)
+	}
+
+	cronSchedule, err := cron.ParseStandard(s.Cron)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid cron expression %q: %w", s.Cron, err)
+	}
+
+	return cronSchedule.Next(after.In(location)), nil
+}
+
+// Preview returns the next count fire times strictly after the given time,
+// in the schedule's timezone.
+func (s *Schedule) Preview(after time.Time, count int) ([]time.Time, error) {
+	if count <= 0 || count > MaxSchedulePreviewRuns {
+		return nil, fmt.Errorf("count must be between 1 and %d", MaxSchedulePreviewRuns)
+	}
+
+	runs := make([]time.Time, 0, count)
+	next := after
+	for len(runs) < count {
+		var err error
+		next, err = s.Next(next)
+		if err != nil {
+			return nil, err
+		}
+		if next.IsZero() {
+			break
+		}
+		runs = append(runs, next)
+	}
+
+	return runs, nil
+}
+
+// UpcomingRuns previews a saved schedule the way the scheduler will run it.
+// A run that fell due while no scheduler was active fires once, on the next
+// schedule tick; any other missed runs are not replayed.
+func (s *Schedule) UpcomingRuns(now time.Time, count int) ([]time.Time, error) {
+	if !s.Enabled || s.NextRunAt == nil || s.NextRunAt.After(now) {
+		return s.Preview(now, count)
+	}
+
+	location, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q: %w", s.Timezone, err)
+	}
+
+	runs := []time.Time{now.In(location)}
+	if count == 1 {
+		return runs, nil
+	}
+
+	rest, err := s.Preview(now, count-1)
+	if err != nil {
+		return nil, err
+	}
+	return append(runs, rest...), nil
+}
@@ -0,0 +1,176 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+func (s *Scheduler) runSchedules(ctx context.Context) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(time.Second * 30)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			passCtx, leading := s.leaderContext()
+			if !leading {
+				continue
+			}
+			if err := s.fireDueSchedules(passCtx); err != nil {
+				s.logger.Errorf("Failed to fire due schedules: %v", err)
+			}
+		}
+	}
+}
+
+func (s *Scheduler) fireDueSchedules(ctx context.Context) error {
+	now := time.Now()
+
+	schedules, err := s.store.GetDueSchedules(now)
+	if err != nil {
+		return fmt.Errorf("failed to get due schedules: %w", err)
+	}
+
+	for i := range schedules {
+		schedule := &schedules[i]
+
+		nextRunAt, err := schedule.Next(now)
+		if err != nil {
+			s.logger.Errorf("Failed to compute next run for schedule %s: %v", schedule.ID, err)
+			continue
+		}
+
+		if schedule.Calendar != nil && s.applyCalendar(ctx, schedule, now, nextRunAt) {
+			continue
+		}
+
+		if err := s.store.UpdateScheduleRun(schedule.ID, now, nextRunAt); err != nil {
+			s.logger.Errorf("Failed to update schedule %s: %v", schedule.ID, err)
+			continue
+		}
+
+		workflow, err := schedule.Workflow.NewWorkflow()
+		if err != nil {
+			s.logger.Errorf("Failed to build workflow for schedule %s: %v", schedule.ID, err)
+			continue
+		}
+		if err := s.submitWorkflow(ctx, workflow); err != nil {
+			s.logger.Errorf("Failed to submit workflow for schedule %s: %v", schedule.ID, err)
+			continue
+		}
+
+		s.logger.Infof("Schedule %s started workflow %s, next run at %s", schedule.ID, workflow.ID, nextRunAt.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// applyCalendar handles a due run that falls on one of the schedule's
+// holidays, reporting whether it did. The run is skipped or moved to the
+// next business day, and either way an event records it. If the calendar
+// cannot be resolved the run goes ahead.
+func (s *Scheduler) applyCalendar(ctx context.Context, schedule *Schedule, now, nextRunAt time.Time) bool {
+	location, err := time.LoadLocation(schedule.Timezone)
+	if err != nil {
+		s.logger.Errorf("Failed to load timezone for schedule %s: %v", schedule.ID, err)
+		return false
+	}
+
+	runAt := now.In(location)
+	if schedule.NextRunAt != nil {
+		runAt = schedule.NextRunAt.In(location)
+	}
+
+	holidays, err := s.calendars.Holidays(ctx, schedule.Calendar)
+	if err != nil {
+		s.logger.Errorf("Failed to resolve calendar for schedule %s, running anyway: %v", schedule.ID, err)
+		return false
+	}
+	if !holidays.Contains(runAt) {
+		return false
+	}
+
+	if schedule.Calendar.Action == CalendarShift {
+		shiftedTo, err := holidays.NextBusinessDay(runAt)
+		if err != nil {
+			s.logger.Errorf("Failed to shift run of schedule %s: %v", schedule.ID, err)
+			return false
+		}
+
+		// A shift that lands on or after the next regular run merges into it.
+		if shiftedTo.Before(nextRunAt) {
+			nextRunAt = shiftedTo
+		}
+
+		if err := s.store.SetScheduleNextRun(schedule.ID, nextRunAt); err != nil {
+			s.logger.Errorf("Failed to shift schedule %s: %v", schedule.ID, err)
+			return true
+		}
+
+		s.publishEvent(ctx, NewScheduleEvent(EventScheduleShifted, schedule.ID, runAt, map[string]interface{}{
+			"shifted_to": nextRunAt,
+		}))
+		s.logger.Infof("Schedule %s run at %s falls on a holiday, shifted to %s", schedule.ID, runAt.Format(time.RFC3339), nextRunAt.Format(time.RFC3339))
+		return true
+	}
+
+	if err := s.store.SetScheduleNextRun(schedule.ID, nextRunAt); err != nil {
+		s.logger.Errorf("Failed to skip run of schedule %s: %v", schedule.ID, err)
+		return true
+	}
+
+	s.publishEvent(ctx, NewScheduleEvent(EventScheduleSkipped, schedule.ID, runAt, map[string]interface{}{
+		"next_run_at": nextRunAt,
+	}))
+	s.logger.Infof("Schedule %s run at %s falls on a holiday, skipped", schedule.ID, runAt.Format(time.RFC3339))
+	return true
+}
+
+func (s *Scheduler) CreateSchedule(ctx context.Context, schedule *Schedule) error {
+	nextRunAt, err := schedule.Next(time.Now())
+	if err != nil {
+		return err
+	}
+	schedule.NextRunAt = &nextRunAt
+
+	if err := s.store.CreateSchedule(schedule); err != nil {
+		return fmt.Errorf("failed to create schedule: %w", err)
+	}
+
+	s.logger.Infof("Created schedule %s (%s)", schedule.ID, schedule.Cron)
+	return nil
+}
+
+func (s *Scheduler) UpdateSchedule(ctx context.Context, schedule *Schedule) error {
+	nextRunAt, err := schedule.Next(time.Now())
+	if err != nil {
+		return err
+	}
+	schedule.NextRunAt = &nextRunAt
+	schedule.UpdatedAt = time.Now()
+
+	if err := s.store.UpdateSchedule(schedule); err != nil {
+		return fmt.Errorf("failed to update schedule: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Scheduler) DeleteSchedule(ctx context.Context, scheduleID string) error {
+	return s.store.DeleteSchedule(scheduleID)
+}
+
+func (s *Scheduler) GetSchedule(scheduleID string) (*Schedule, error) {
+	return s.store.GetSchedule(scheduleID)
+}
+
+func (s *Scheduler) ListSchedules() ([]Schedule, error) {
+	return s.store.ListSchedules()
+}
@@ -2,42 +2,94 @@ package core
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"flowctl/internal/queue"
-	"flowctl/internal/storage"
-
 	"github.com/sirupsen/logrus"
 )
 
+const (
+	enqueueAttempts     = 4
+	enqueueInitialDelay = time.Millisecond * 250
+	// dispatchBatchSize caps how many tasks are marked queued and pushed to
+	// Redis together.
+	dispatchBatchSize = 500
+
+	overviewWindow       = time.Hour * 24
+	overviewFailingTypes = 5
+)
+
 type Scheduler struct {
-	store    *storage.PostgresStore
-	queue    *queue.RedisQueue
-	logger   *logrus.Logger
-	stopCh   chan struct{}
-	wg       sync.WaitGroup
-	interval time.Duration
+	store             Store
+	queue             Broker
+	logger            *logrus.Logger
+	stopCh            chan struct{}
+	wg                sync.WaitGroup
+	interval          time.Duration
+	visibilityTimeout time.Duration
+	janitorInterval   time.Duration
+	memoryInterval    time.Duration
+	maxResultSize     int
+	allowInjection    bool
+	calendars         *CalendarCache
+	admission         AdmissionController
+	instanceID        string
+	lease             atomic.Pointer[leaderLease]
+	metrics           *schedulerMetrics
+	clock             func() time.Time
+
+	webhookClient       *http.Client
+	webhookAllowPrivate bool
 }
 
-func NewScheduler(store *storage.PostgresStore, queue *queue.RedisQueue, logger *logrus.Logger) *Scheduler {
-	return &Scheduler{
-		store:    store,
-		queue:    queue,
-		logger:   logger,
-		stopCh:   make(chan struct{}),
-		interval: time.Second * 10,
+// NewScheduler returns a scheduler keeping its records in store and moving
+// tasks through broker.
+func NewScheduler(store Store, broker Broker, logger *logrus.Logger) *Scheduler {
+	s := &Scheduler{
+		store:             store,
+		queue:             broker,
+		logger:            logger,
+		stopCh:            make(chan struct{}),
+		interval:          time.Second * 10,
+		visibilityTimeout: DefaultVisibilityTimeout,
+		janitorInterval:   DefaultJanitorInterval,
+		memoryInterval:    DefaultMemoryCheckInterval,
+		maxResultSize:     DefaultMaxResultSize,
+		calendars:         NewCalendarCache(),
+		instanceID:        newInstanceID(),
+		webhookClient:     newWebhookClient(false),
+		clock:             time.Now,
 	}
+	s.metrics = s.newSchedulerMetrics()
+	return s
 }
 
 func (s *Scheduler) Start(ctx context.Context) {
 	s.logger.Info("Starting scheduler")
 	
-	s.wg.Add(3)
-	go s.scheduleWorkflows(ctx)
-	go s.processRetries(ctx)
-	go s.monitorWorkflows(ctx)
+	for _, loop := range []func(context.Context){
+		s.runLeaderElection,
+		s.scheduleWorkflows,
+		s.processRetries,
+		s.promoteDelayedTasks,
+		s.monitorWorkflows,
+		s.runSchedules,
+		s.enforceWorkflowTimeouts,
+		s.reapExpiredTasks,
+		s.expireStaleTasks,
+		s.deliverWebhooks,
+		s.runJanitor,
+		s.purgeDeadLetters,
+		s.guardRedisMemory,
+	} {
+		s.wg.Add(1)
+		go loop(ctx)
+	}
 }
 
 func (s *Scheduler) Stop() {
@@ -46,6 +98,37 @@ func (s *Scheduler) Stop() {
 	s.wg.Wait()
 }
 
+// Tick runs once, in order, the passes of the leader's loops that move
+// workflows along: completing due waits, timing out workflows, expiring
+// tasks, queuing due delayed tasks and retries, dispatching pending tasks
+// and settling running workflows. It is for driving a scheduler that was
+// not started, such as from pkg/flowtest, and does not check leadership.
+func (s *Scheduler) Tick(ctx context.Context) {
+	if err := s.completeDueWaits(ctx); err != nil {
+		s.logger.Errorf("Failed to complete wait tasks: %v", err)
+	}
+	if err := s.checkWorkflowTimeouts(ctx); err != nil {
+		s.logger.Errorf("Failed to check workflow timeouts: %v", err)
+	}
+	if err := s.sweepExpiredTasks(ctx); err != nil {
+		s.logger.Errorf("Failed to expire tasks: %v", err)
+	}
+	s.promoteDueDelayedTasks(ctx)
+	s.promoteRetries(ctx)
+	if err := s.schedulePendingTasks(ctx); err != nil {
+		s.logger.Errorf("Failed to schedule pending tasks: %v", err)
+	}
+	if err := s.checkWorkflowCompletion(ctx); err != nil {
+		s.logger.Errorf("Failed to check workflow completion: %v", err)
+	}
+}
+
+// SetClock replaces the clock the scheduler checks waits, expiry and
+// workflow timeouts against, for driving it on simulated time.
+func (s *Scheduler) SetClock(now func() time.Time) {
+	s.clock = now
+}
+
 func (s *Scheduler) scheduleWorkflows(ctx context.Context) {
 	defer s.wg.Done()
 	
@@ -59,7 +142,11 @@ func (s *Scheduler) scheduleWorkflows(ctx context.Context) {
 		case <-s.stopCh:
 			return
 		case <-ticker.C:
-			if err := s.schedulePendingTasks(ctx); err != nil {
+			passCtx, leading := s.leaderContext()
+			if !leading {
+				continue
+			}
+			if err := s.schedulePendingTasks(passCtx); err != nil {
 				s.logger.Errorf("Failed to schedule pending tasks: %v", err)
 			}
 		}
@@ -77,62 +164,452 @@ func (s *Scheduler) schedulePendingTasks(ctx context.Context) error {
 		workflowTasks[task.WorkflowID] = append(workflowTasks[task.WorkflowID], task)
 	}
 
+	pass, retention := s.sampleSchedulingPass(ctx, len(tasks))
+
+	var tasksToSchedule []Task
+	workflows := make(map[string]*Workflow)
 	for workflowID, tasks := range workflowTasks {
-		if err := s.scheduleWorkflowTasks(ctx, workflowID, tasks); err != nil {
+		workflow, err := s.store.GetWorkflow(workflowID)
+		if err != nil {
+			s.logger.Errorf("Failed to get workflow %s: %v", workflowID, err)
+			continue
+		}
+		workflows[workflowID] = workflow
+
+		ready, blocked, err := s.scheduleWorkflowTasks(ctx, workflow, tasks)
+		if err != nil {
 			s.logger.Errorf("Failed to schedule tasks for workflow %s: %v", workflowID, err)
+			continue
+		}
+		if pass != nil {
+			pass.Blocked = append(pass.Blocked, blocked...)
+		}
+		if workflow.Config.DryRun != nil {
+			if pass != nil {
+				for i := range ready {
+					pass.Dispatch = append(pass.Dispatch, newTaskDecision(&ready[i], "", ""))
+				}
+			}
+			if err := s.runMockTasks(ctx, workflow, ready); err != nil {
+				s.logger.Errorf("Failed to dry-run workflow %s: %v", workflowID, err)
+			}
+			continue
+		}
+		tasksToSchedule = append(tasksToSchedule, ready...)
+	}
+
+	// Dispatch across all workflows in priority order so that, combined with
+	// the per-type priority queues, higher-priority work is picked up first.
+	// An urgent workflow's tasks go ahead of every other workflow's.
+	sortForDispatch(tasksToSchedule, workflows)
+
+	// Tasks that would overfill their type's queue or exceed its rate limit
+	// stay pending for a later pass. Full queues are checked first, so
+	// tasks they hold back use none of the rate limit.
+	tasksToSchedule, full := s.limitQueueLength(ctx, tasksToSchedule)
+	tasksToSchedule, limited := s.limitDispatchRate(ctx, tasksToSchedule, false)
+
+	if pass != nil {
+		pass.Blocked = append(pass.Blocked, full...)
+		pass.Blocked = append(pass.Blocked, limited...)
+		for i := range tasksToSchedule {
+			pass.Dispatch = append(pass.Dispatch, newTaskDecision(&tasksToSchedule[i], "", ""))
 		}
 	}
 
+	s.dispatchTasks(ctx, tasksToSchedule)
+
+	if pass != nil {
+		s.recordSchedulingPass(ctx, pass, retention)
+	}
+
+	if len(tasksToSchedule) > 0 {
+		s.logger.Infof("Scheduled %d tasks across %d workflows", len(tasksToSchedule), len(workflowTasks))
+	}
 	return nil
 }
 
-func (s *Scheduler) scheduleWorkflowTasks(ctx context.Context, workflowID string, tasks []Task) error {
-	workflow, err := s.store.GetWorkflow(workflowID)
+// scheduleWorkflowTasks returns the tasks of one workflow that are ready to
+// dispatch, moving the workflow to running when it has any, along with why
+// the others are held back.
+func (s *Scheduler) scheduleWorkflowTasks(ctx context.Context, workflow *Workflow, tasks []Task) ([]Task, []TaskDecision, error) {
+	if workflow.Status != WorkflowStatusPending && workflow.Status != WorkflowStatusRunning {
+		blocked := make([]TaskDecision, 0, len(tasks))
+		for i := range tasks {
+			blocked = append(blocked, newTaskDecision(&tasks[i], BlockReasonWorkflowInactive,
+				fmt.Sprintf("workflow is %s", workflow.Status)))
+		}
+		return nil, blocked, nil
+	}
+
+	tasksToSchedule, blocked := s.planWorkflowTasks(ctx, workflow, tasks)
+
+	tasksToSchedule, err := s.enforceSandboxPolicy(ctx, workflow, tasksToSchedule)
 	if err != nil {
-		return fmt.Errorf("failed to get workflow: %w", err)
+		return nil, nil, err
 	}
 
-	if workflow.Status != WorkflowStatusPending && workflow.Status != WorkflowStatusRunning {
-		return nil
+	if len(tasksToSchedule) == 0 {
+		return nil, blocked, nil
 	}
 
-	completedTasks := make(map[string]bool)
-	for _, task := range workflow.Tasks {
-		if task.Status == TaskStatusCompleted {
-			completedTasks[task.ID] = true
+	// Workers nack with the workflow's retry timing and hand handlers the
+	// run's execution context, so both travel with the task through the
+	// queue.
+	execution := NewExecutionContext(workflow)
+	for i := range tasksToSchedule {
+		policy := workflow.Config.RetryPolicy
+		tasksToSchedule[i].RetryPolicy = &policy
+		tasksToSchedule[i].Execution = execution
+	}
+
+	if workflow.Status == WorkflowStatusPending {
+		if err := s.setWorkflowStatus(ctx, workflow.ID, WorkflowStatusRunning); err != nil {
+			return nil, nil, fmt.Errorf("failed to update workflow status: %w", err)
 		}
 	}
 
-	var tasksToSchedule []Task
-	for _, task := range tasks {
-		if task.CanExecute(completedTasks) {
-			tasksToSchedule = append(tasksToSchedule, task)
+	return tasksToSchedule, blocked, nil
+}
+
+// dispatchTask marks a task queued before pushing it to Redis, so a worker
+// can never report on a task the database still considers pending. If the
+// push keeps failing the task is returned to pending for the next pass.
+func (s *Scheduler) dispatchTask(ctx context.Context, task *Task) error {
+	ready, err := s.prepareDispatch(ctx, task)
+	if err != nil || !ready {
+		return err
+	}
+
+	return s.dispatchBatch(ctx, []*Task{task})
+}
+
+// dispatchTasks dispatches tasks like dispatchTask, but marks them queued
+// and pushes them to Redis in batches, so a pass that readies hundreds of
+// tasks makes a few round trips instead of hundreds.
+func (s *Scheduler) dispatchTasks(ctx context.Context, tasks []Task) {
+	batch := make([]*Task, 0, len(tasks))
+	for i := range tasks {
+		ready, err := s.prepareDispatch(ctx, &tasks[i])
+		if err != nil {
+			s.logger.Errorf("Failed to dispatch task %s: %v", tasks[i].ID, err)
+		}
+		if ready {
+			batch = append(batch, &tasks[i])
 		}
 	}
 
-	if len(tasksToSchedule) == 0 {
-		return nil
+	for start := 0; start < len(batch); start += dispatchBatchSize {
+		end := start + dispatchBatchSize
+		if end > len(batch) {
+			end = len(batch)
+		}
+		if err := s.dispatchBatch(ctx, batch[start:end]); err != nil {
+			s.logger.Errorf("Failed to dispatch %d tasks: %v", end-start, err)
+		}
 	}
+}
 
-	if workflow.Status == WorkflowStatusPending {
-		if err := s.store.UpdateWorkflowStatus(workflowID, WorkflowStatusRunning); err != nil {
-			return fmt.Errorf("failed to update workflow status: %w", err)
+// prepareDispatch reports whether a task still needs to be queued, after
+// expiring it if its expires_at has passed, starting it on a timer if it is
+// a wait task or holding it for an operator if it is an approval task,
+// completing it from a prior execution under its idempotency
+// key if there is one and holding it back while an identical task is in
+// flight, and fills in the retry policy and execution context it carries.
+func (s *Scheduler) prepareDispatch(ctx context.Context, task *Task) (bool, error) {
+	if task.Expired(s.clock()) {
+		if err := s.expireTask(ctx, task); err != nil {
+			s.logger.Errorf("Failed to expire task %s: %v", task.ID, err)
+		}
+		return false, nil
+	}
+
+	if task.Type == WaitTaskType {
+		return false, s.startWait(ctx, task)
+	}
+	if task.Type == ApprovalTaskType {
+		return false, s.startApproval(ctx, task)
+	}
+
+	// The execution context is filled in first, since deduplication is
+	// scoped to the namespace it names.
+	if task.RetryPolicy == nil || task.Execution == nil {
+		workflow, err := s.store.GetWorkflow(task.WorkflowID)
+		if err != nil {
+			return false, fmt.Errorf("failed to get workflow: %w", err)
 		}
+		if task.RetryPolicy == nil {
+			task.RetryPolicy = &workflow.Config.RetryPolicy
+		}
+		if task.Execution == nil {
+			task.Execution = NewExecutionContext(workflow)
+		}
+	}
+
+	if task.IdempotencyKey != "" {
+		// A task whose key cannot be claimed is held back until the next
+		// pass rather than risk running it twice.
+		done, err := s.completeFromPriorExecution(ctx, task)
+		if err != nil {
+			s.logger.Errorf("Failed to claim idempotency key for task %s: %v", task.ID, err)
+			return false, nil
+		}
+		if done {
+			return false, nil
+		}
+	}
+
+	if task.Dedupe != nil {
+		held, err := s.deduplicate(ctx, task)
+		if err != nil {
+			s.logger.Errorf("Failed to deduplicate task %s: %v", task.ID, err)
+		}
+		if held {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// dispatchBatch marks tasks queued with one update and pushes them to Redis
+// in one transaction, returning them all to pending if the push keeps
+// failing. A task that cannot be serialized fails on its own, without
+// holding back the rest of the batch.
+func (s *Scheduler) dispatchBatch(ctx context.Context, tasks []*Task) error {
+	if err := s.checkLease(ctx); err != nil {
+		return err
+	}
+	if err := s.setTasksStatus(ctx, tasks, TaskStatusQueued); err != nil {
+		return fmt.Errorf("failed to mark tasks queued: %w", err)
+	}
+
+	err := s.enqueueWithRetry(ctx, tasks)
+	var unserializable *UnserializableTasksError
+	if errors.As(err, &unserializable) {
+		tasks = s.failUnserializableTasks(ctx, tasks, unserializable.Errors)
+		err = nil
+	}
+	if err == nil {
+		for _, task := range tasks {
+			s.metrics.tasksEnqueued.Inc(task.Type)
+			s.metrics.schedulingLatency.Observe(time.Since(task.UpdatedAt).Seconds(), task.Type)
+		}
+		return nil
+	}
+	// Tasks marked queued by a leader that has since been deposed are left
+	// for the new leader's recovery pass to requeue, rather than returned to
+	// pending behind its back.
+	if errors.Is(err, errLeaseLost) {
+		return err
+	}
+
+	if revertErr := s.setTasksStatus(ctx, tasks, TaskStatusPending); revertErr != nil {
+		s.logger.Errorf("Failed to return %d tasks to pending after enqueue failure: %v", len(tasks), revertErr)
 	}
+	return err
+}
 
-	for _, task := range tasksToSchedule {
-		if err := s.queue.EnqueueTask(ctx, &task); err != nil {
-			s.logger.Errorf("Failed to enqueue task %s: %v", task.ID, err)
+// failUnserializableTasks fails the tasks of a batch the broker could not
+// serialize, which would fail the same way on every pass, and returns the
+// rest.
+func (s *Scheduler) failUnserializableTasks(ctx context.Context, tasks []*Task, errs map[string]error) []*Task {
+	queued := make([]*Task, 0, len(tasks))
+	for _, task := range tasks {
+		err, ok := errs[task.ID]
+		if !ok {
+			queued = append(queued, task)
 			continue
 		}
-		
-		if err := s.store.UpdateTaskStatus(task.ID, TaskStatusPending, nil, ""); err != nil {
-			s.logger.Errorf("Failed to update task status %s: %v", task.ID, err)
+		if updateErr := s.UpdateTaskStatus(ctx, task.ID, TaskStatusFailed, nil, fmt.Sprintf("failed to serialize task: %v", err)); updateErr != nil {
+			s.logger.Errorf("Failed to fail unserializable task %s: %v", task.ID, updateErr)
 		}
 	}
+	return queued
+}
 
-	s.logger.Infof("Scheduled %d tasks for workflow %s", len(tasksToSchedule), workflowID)
-	return nil
+// completeFromPriorExecution completes a task straight away with the stored
+// result of an earlier completed execution under the same idempotency key
+// in its namespace, and reports whether it did or is holding the task back
+// while another task runs under the key.
+func (s *Scheduler) completeFromPriorExecution(ctx context.Context, task *Task) (bool, error) {
+	namespace := task.IdempotencyNamespace()
+	result, found, err := s.queue.GetIdempotentResult(ctx, namespace, task.IdempotencyKey)
+	if err != nil {
+		return false, err
+	}
+
+	if !found {
+		run, holder, err := s.claimIdempotencyKey(namespace, task)
+		if err != nil {
+			return false, err
+		}
+		if run {
+			return false, nil
+		}
+		if holder == nil || holder.Status != TaskStatusCompleted {
+			s.logger.Infof("Holding back task %s while another task runs under idempotency key %s", task.ID, task.IdempotencyKey)
+			return true, nil
+		}
+		result = holder.Result
+	}
+
+	if err := s.UpdateTaskStatus(ctx, task.ID, TaskStatusCompleted, result, ""); err != nil {
+		return false, err
+	}
+
+	s.logger.Infof("Task %s reused the result of idempotency key %s", task.ID, task.IdempotencyKey)
+	return true, nil
+}
+
+// claimIdempotencyKey claims a task's idempotency key in namespace and
+// reports whether the task holds it and should run. Otherwise it returns
+// the task that holds the key, which is completed or still in flight, or
+// nil if the key changed hands while it looked. A holder that was deleted
+// or finished without completing gives the key up.
+func (s *Scheduler) claimIdempotencyKey(namespace string, task *Task) (bool, *Task, error) {
+	stale := ""
+	for i := 0; i < 3; i++ {
+		holderID, err := s.store.ClaimIdempotencyKey(namespace, task.IdempotencyKey, task.ID, stale)
+		if err != nil {
+			return false, nil, err
+		}
+		if holderID == task.ID {
+			return true, nil, nil
+		}
+
+		holder, err := s.store.FindTask(holderID)
+		if err != nil {
+			return false, nil, err
+		}
+		if holder != nil && (holder.Status == TaskStatusCompleted || !holder.Status.IsTerminal()) {
+			return false, holder, nil
+		}
+		stale = holderID
+	}
+	return false, nil, nil
+}
+
+// deduplicate reports whether a task is held back as a duplicate. A task
+// linked to an original that has since completed completes with its result.
+// Otherwise the task claims its fingerprint, and while another task that is
+// still in flight holds it the task stays pending, linked to that task, to
+// be checked again on the next pass. A task whose original failed, was
+// cancelled or outlived the window runs itself.
+func (s *Scheduler) deduplicate(ctx context.Context, task *Task) (bool, error) {
+	if task.DuplicateOf != "" {
+		original, err := s.store.FindTask(task.DuplicateOf)
+		if err != nil {
+			return false, err
+		}
+		if original != nil && original.Status == TaskStatusCompleted {
+			if err := s.UpdateTaskStatus(ctx, task.ID, TaskStatusCompleted, original.Result, ""); err != nil {
+				return false, err
+			}
+			s.logger.Infof("Task %s completed with the result of task %s it duplicated", task.ID, original.ID)
+			return true, nil
+		}
+	}
+
+	fingerprint := task.DedupeFingerprint(task.Execution.Namespace)
+	holder, err := s.queue.ClaimDedupeKey(ctx, fingerprint, task.ID, task.Dedupe.Window, "")
+	if err != nil {
+		return false, err
+	}
+
+	if holder != task.ID {
+		original, err := s.store.FindTask(holder)
+		if err != nil {
+			return false, err
+		}
+		if original != nil && dedupeInFlight(original.Status) {
+			if task.DuplicateOf != holder {
+				if err := s.store.SetTaskDuplicateOf(task.ID, holder); err != nil {
+					return false, err
+				}
+				task.DuplicateOf = holder
+				s.logger.Infof("Task %s duplicates in-flight task %s, holding it back", task.ID, holder)
+			}
+			return true, nil
+		}
+
+		// The holder has finished or is gone, so this task takes its place
+		// unless another duplicate got there first.
+		holder, err = s.queue.ClaimDedupeKey(ctx, fingerprint, task.ID, task.Dedupe.Window, holder)
+		if err != nil {
+			return false, err
+		}
+		if holder != task.ID {
+			return true, nil
+		}
+	}
+
+	if task.DuplicateOf != "" {
+		if err := s.store.SetTaskDuplicateOf(task.ID, ""); err != nil {
+			return false, err
+		}
+		task.DuplicateOf = ""
+	}
+	return false, nil
+}
+
+// dedupeInFlight reports whether a task in status is waiting to run or
+// running, which is when identical tasks are held back as its duplicates.
+func dedupeInFlight(status TaskStatus) bool {
+	switch status {
+	case TaskStatusPending, TaskStatusQueued, TaskStatusRunning, TaskStatusRetrying:
+		return true
+	}
+	return false
+}
+
+func (s *Scheduler) enqueueWithRetry(ctx context.Context, tasks []*Task) error {
+	delay := enqueueInitialDelay
+
+	var err error
+	for attempt := 1; attempt <= enqueueAttempts; attempt++ {
+		if err = s.checkLease(ctx); err != nil {
+			return err
+		}
+		if err = s.queue.EnqueueTasks(ctx, tasks); err == nil {
+			return nil
+		}
+		// A full queue will not drain within the retries; the tasks go back
+		// to pending for a later pass instead.
+		if errors.Is(err, ErrQueueFull) {
+			return err
+		}
+		// The tasks left out of the batch would be left out again, and the
+		// rest are queued.
+		var unserializable *UnserializableTasksError
+		if errors.As(err, &unserializable) {
+			return err
+		}
+		if attempt == enqueueAttempts {
+			break
+		}
+
+		s.logger.Warnf("Enqueue attempt %d for %s failed, retrying in %s: %v", attempt, describeTasks(tasks), delay, err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.stopCh:
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+
+	return fmt.Errorf("failed to enqueue %s after %d attempts: %w", describeTasks(tasks), enqueueAttempts, err)
+}
+
+// describeTasks names a single task by ID and a batch by its size, for logs.
+func describeTasks(tasks []*Task) string {
+	if len(tasks) == 1 {
+		return "task " + tasks[0].ID
+	}
+	return fmt.Sprintf("%d tasks", len(tasks))
 }
 
 func (s *Scheduler) processRetries(ctx context.Context) {
@@ -148,12 +625,62 @@ func (s *Scheduler) processRetries(ctx context.Context) {
 		case <-s.stopCh:
 			return
 		case <-ticker.C:
-			taskTypes := []string{"etl", "ml_training", "ci", "generic"}
-			for _, taskType := range taskTypes {
-				if err := s.queue.ProcessRetries(ctx, taskType); err != nil {
-					s.logger.Errorf("Failed to process retries for task type %s: %v", taskType, err)
-				}
+			passCtx, leading := s.leaderContext()
+			if !leading {
+				continue
 			}
+			s.promoteRetries(passCtx)
+		}
+	}
+}
+
+// promoteRetries queues the retries that are due, of every task type with
+// a queue, after evicting those their overload policy sheds.
+func (s *Scheduler) promoteRetries(ctx context.Context) {
+	s.evictOverloadedRetries(ctx)
+	taskTypes, err := s.queue.GetTaskTypes(ctx)
+	if err != nil {
+		s.logger.Errorf("Failed to get task types: %v", err)
+		return
+	}
+	for _, taskType := range taskTypes {
+		if err := s.checkLease(ctx); err != nil {
+			return
+		}
+		if err := s.queue.ProcessRetries(ctx, taskType); err != nil {
+			s.logger.Errorf("Failed to process retries for task type %s: %v", taskType, err)
+		}
+	}
+}
+
+// evictOverloadedRetries trims the retry sets that have an evict overload
+// policy, failing the evicted tasks, so the retries that remain are promoted
+// without waiting behind them.
+func (s *Scheduler) evictOverloadedRetries(ctx context.Context) {
+	policies, err := s.queue.GetRetryOverloadPolicies(ctx)
+	if err != nil {
+		s.logger.Errorf("Failed to get retry overload policies: %v", err)
+		return
+	}
+
+	for _, policy := range policies {
+		if policy.Action != RetryOverloadEvict {
+			continue
+		}
+
+		evicted, err := s.queue.EvictRetries(ctx, policy)
+		if err != nil {
+			s.logger.Errorf("Failed to evict retries of task type %s: %v", policy.TaskType, err)
+			continue
+		}
+
+		for i := range evicted {
+			task := &evicted[i]
+			if err := s.UpdateTaskStatus(ctx, task.ID, TaskStatusFailed, nil, policy.EvictionReason(task)); err != nil {
+				s.logger.Errorf("Failed to fail evicted task %s: %v", task.ID, err)
+				continue
+			}
+			s.metrics.retriesEvicted.Inc(task.Type)
 		}
 	}
 }
@@ -171,7 +698,11 @@ func (s *Scheduler) monitorWorkflows(ctx context.Context) {
 		case <-s.stopCh:
 			return
 		case <-ticker.C:
-			if err := s.checkWorkflowCompletion(ctx); err != nil {
+			passCtx, leading := s.leaderContext()
+			if !leading {
+				continue
+			}
+			if err := s.checkWorkflowCompletion(passCtx); err != nil {
 				s.logger.Errorf("Failed to check workflow completion: %v", err)
 			}
 		}
@@ -179,33 +710,445 @@ func (s *Scheduler) monitorWorkflows(ctx context.Context) {
 }
 
 func (s *Scheduler) checkWorkflowCompletion(ctx context.Context) error {
+	workflows, err := s.store.GetRunningWorkflows()
+	if err != nil {
+		return fmt.Errorf("failed to get running workflows: %w", err)
+	}
+
+	for _, workflow := range workflows {
+		if err := s.settleWorkflow(ctx, workflow.ID); err != nil {
+			s.logger.Errorf("Failed to settle workflow %s: %v", workflow.ID, err)
+		}
+	}
+
 	return nil
 }
 
+// SubmitWorkflow stores a workflow for scheduling, rejecting it with a
+// *BackpressureError while the queue of any of its task types is full.
 func (s *Scheduler) SubmitWorkflow(ctx context.Context, workflow *Workflow) error {
-	if err := s.store.CreateWorkflow(workflow); err != nil {
+	if err := s.checkQueueCapacity(ctx, workflow); err != nil {
+		return err
+	}
+	return s.submitWorkflow(ctx, workflow)
+}
+
+// submitWorkflow stores a workflow whatever the state of its queues. Runs
+// of schedules are submitted this way, so a full queue delays them rather
+// than dropping them.
+func (s *Scheduler) submitWorkflow(ctx context.Context, workflow *Workflow) error {
+	if err := s.admit(ctx, workflow); err != nil {
+		return err
+	}
+
+	if err := s.checkSandboxPolicy(ctx, workflow); err != nil {
+		return err
+	}
+
+	if err := s.checkIDsAvailable(workflow); err != nil {
+		return err
+	}
+
+	workflow.AssignTaskOrder()
+	workflow.AssignIncrementalKeys()
+
+	if err := s.store.CreateWorkflowWithTasks(workflow); err != nil {
 		return fmt.Errorf("failed to create workflow: %w", err)
 	}
 
+	s.publishEvent(ctx, NewWorkflowEvent(workflow.ID, workflow.Status, ""))
+
+	s.logger.Infof("Submitted workflow %s with %d tasks", workflow.ID, len(workflow.Tasks))
+	return nil
+}
+
+// SetAdmissionController installs the hook that sees, and may change or
+// reject, every submitted workflow before it is stored.
+func (s *Scheduler) SetAdmissionController(admission AdmissionController) {
+	s.admission = admission
+}
+
+func (s *Scheduler) admit(ctx context.Context, workflow *Workflow) error {
+	if s.admission == nil {
+		return nil
+	}
+	if err := s.admission.Admit(ctx, workflow); err != nil {
+		if errors.Is(err, ErrAdmissionDenied) {
+			s.logger.Warnf("Admission webhook rejected workflow %s: %v", workflow.ID, err)
+			return err
+		}
+		return fmt.Errorf("failed to admit workflow: %w", err)
+	}
+	return nil
+}
+
+func (s *Scheduler) checkSandboxPolicy(ctx context.Context, workflow *Workflow) error {
+	policy, err := s.queue.GetSandboxPolicy(ctx, workflow.Namespace)
+	if err != nil {
+		return fmt.Errorf("failed to get sandbox policy: %w", err)
+	}
+	if policy == nil {
+		return nil
+	}
+	return policy.Check(workflow)
+}
+
+// enforceSandboxPolicy fails the tasks about to be dispatched that the
+// sandbox policy of their workflow's namespace does not permit, and returns
+// the others with the resources the policy gives them. Workflows are checked
+// on submission too, but the policy may have been tightened since, and
+// workers trust the executor a task carries.
+func (s *Scheduler) enforceSandboxPolicy(ctx context.Context, workflow *Workflow, tasks []Task) ([]Task, error) {
+	policy, err := s.queue.GetSandboxPolicy(ctx, workflow.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sandbox policy: %w", err)
+	}
+	if policy == nil || len(tasks) == 0 {
+		return tasks, nil
+	}
+
+	permitted := tasks[:0]
+	violated := false
+	for i := range tasks {
+		task := tasks[i]
+		violation := policy.CheckTask(&task)
+		if violation == nil {
+			task.Resources = policy.Resources(&task)
+			permitted = append(permitted, task)
+			continue
+		}
+
+		violated = true
+		if err := s.setTaskStatus(ctx, task.WorkflowID, task.ID, TaskStatusFailed, nil, violation.Error()); err != nil {
+			s.logger.Errorf("Failed to fail task %s: %v", task.ID, err)
+			continue
+		}
+		s.recordTaskStatus(&task, TaskStatusFailed)
+		s.logger.Warnf("Failed task %s of workflow %s: %v", task.ID, workflow.ID, violation)
+	}
+
+	if violated {
+		if err := s.settleWorkflow(ctx, workflow.ID); err != nil {
+			s.logger.Errorf("Failed to settle workflow %s: %v", workflow.ID, err)
+		}
+	}
+	return permitted, nil
+}
+
+// checkQueueCapacity returns a *BackpressureError when the queue of any of
+// the workflow's task types is full, so clients back off instead of piling
+// more work behind it.
+func (s *Scheduler) checkQueueCapacity(ctx context.Context, workflow *Workflow) error {
+	limits, err := s.queue.GetQueueLengthLimits(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get queue length limits: %w", err)
+	}
+
+	checked := make(map[string]bool)
 	for _, task := range workflow.Tasks {
-		if err := s.store.CreateTask(&task); err != nil {
-			s.logger.Errorf("Failed to create task %s: %v", task.ID, err)
+		limit, ok := limits[task.Type]
+		if !ok || checked[task.Type] {
+			continue
+		}
+		checked[task.Type] = true
+		if err := s.queue.CheckQueueCapacity(ctx, limit, 1); err != nil {
+			return err
 		}
 	}
+	return nil
+}
+
+func (s *Scheduler) checkIDsAvailable(workflow *Workflow) error {
+	exists, err := s.store.WorkflowExists(workflow.ID)
+	if err != nil {
+		return fmt.Errorf("failed to check workflow id: %w", err)
+	}
+	if exists {
+		return fmt.Errorf("%w: workflow %s", ErrDuplicateID, workflow.ID)
+	}
+
+	taskIDs := make([]string, 0, len(workflow.Tasks))
+	for _, task := range workflow.Tasks {
+		taskIDs = append(taskIDs, task.ID)
+	}
+
+	existing, err := s.store.ExistingTaskIDs(taskIDs)
+	if err != nil {
+		return fmt.Errorf("failed to check task ids: %w", err)
+	}
+	if len(existing) > 0 {
+		return fmt.Errorf("%w: tasks %s", ErrDuplicateID, strings.Join(existing, ", "))
+	}
 
-	s.logger.Infof("Submitted workflow %s with %d tasks", workflow.ID, len(workflow.Tasks))
 	return nil
 }
 
+// CancelWorkflow marks a workflow cancelled and stops its unfinished tasks:
+// they are taken off their queues, retry and delayed sets, workers running
+// them are signalled over their cancel:<task ID> channel to cancel the
+// task's context, and they are marked cancelled.
 func (s *Scheduler) CancelWorkflow(ctx context.Context, workflowID string) error {
-	if err := s.store.UpdateWorkflowStatus(workflowID, WorkflowStatusCancelled); err != nil {
+	// Cancelling the workflow first stops the scheduler from dispatching
+	// any more of its tasks while they are being stopped.
+	if err := s.setWorkflowStatus(ctx, workflowID, WorkflowStatusCancelled); err != nil {
 		return fmt.Errorf("failed to cancel workflow: %w", err)
 	}
 
-	s.logger.Infof("Cancelled workflow %s", workflowID)
+	tasks, err := s.store.GetTasksByWorkflow(workflowID)
+	if err != nil {
+		return fmt.Errorf("failed to get workflow tasks: %w", err)
+	}
+
+	var unfinished []Task
+	for _, task := range tasks {
+		if !task.Status.IsTerminal() {
+			unfinished = append(unfinished, task)
+		}
+	}
+
+	if _, _, err := s.queue.PurgeTasks(ctx, unfinished); err != nil {
+		s.logger.Errorf("Failed to purge tasks of workflow %s: %v", workflowID, err)
+	}
+	s.cancelUnfinishedTasks(ctx, unfinished)
+
+	s.logger.Infof("Cancelled workflow %s and its %d unfinished tasks", workflowID, len(unfinished))
+	return nil
+}
+
+// WorkflowDeletion reports what deleting a workflow removed.
+type WorkflowDeletion struct {
+	WorkflowID string `json:"workflow_id"`
+	Tasks      int    `json:"tasks"`
+	// Purged counts the task entries removed from Redis queues, retry
+	// sets, poison and dead letter queues and leases.
+	Purged   int `json:"purged"`
+	Webhooks int `json:"webhooks"`
+}
+
+// DeleteWorkflow deletes a workflow and its tasks, then purges the tasks
+// from Redis so none of them runs, retries or lingers in a dead letter
+// queue. Tasks a worker is running are cancelled. Webhooks registered for
+// the workflow are deleted too.
+func (s *Scheduler) DeleteWorkflow(ctx context.Context, workflowID string) (*WorkflowDeletion, error) {
+	tasks, err := s.store.GetTasksByWorkflow(workflowID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get workflow tasks: %w", err)
+	}
+
+	// Deleting the rows first stops the scheduler from enqueuing any more
+	// of the tasks while they are being purged.
+	if err := s.store.DeleteWorkflow(workflowID); err != nil {
+		return nil, err
+	}
+
+	deletion := &WorkflowDeletion{WorkflowID: workflowID, Tasks: len(tasks)}
+
+	purged, leased, err := s.queue.PurgeTasks(ctx, tasks)
+	deletion.Purged = purged
+	for _, taskID := range leased {
+		if err := s.queue.PublishCancellation(ctx, taskID); err != nil {
+			s.logger.Errorf("Failed to cancel running task %s: %v", taskID, err)
+		}
+	}
+	if err != nil {
+		return deletion, fmt.Errorf("failed to purge tasks of workflow %s: %w", workflowID, err)
+	}
+
+	webhooks, err := s.queue.ListWebhooks(ctx)
+	if err != nil {
+		return deletion, err
+	}
+	for _, webhook := range webhooks {
+		if webhook.WorkflowID != workflowID {
+			continue
+		}
+		if _, err := s.queue.DeleteWebhook(ctx, webhook.ID); err != nil {
+			return deletion, err
+		}
+		deletion.Webhooks++
+	}
+
+	s.logger.Infof("Deleted workflow %s with %d tasks, purging %d queue entries", workflowID, deletion.Tasks, deletion.Purged)
+	return deletion, nil
+}
+
+func (s *Scheduler) UpdateTaskStatus(ctx context.Context, taskID string, status TaskStatus, result map[string]interface{}, errorMsg string) error {
+	return s.ReportTaskStatus(ctx, taskID, 0, status, result, errorMsg)
+}
+
+// ReportTaskStatus is UpdateTaskStatus for a worker's report on attempt,
+// counted from 1, of a task. A report on an attempt older than the latest
+// one a worker started is dropped with ErrStaleTaskStatus, so a report
+// delivered late cannot overwrite a newer status. An attempt of 0 is not
+// checked. A report on a task that has already finished, such as one
+// cancelled with its workflow, is dropped with ErrTaskFinished. A task that
+// does not exist returns ErrTaskNotFound.
+func (s *Scheduler) ReportTaskStatus(ctx context.Context, taskID string, attempt int, status TaskStatus, result map[string]interface{}, errorMsg string) error {
+	task, err := s.store.FindTask(taskID)
+	if err != nil {
+		return fmt.Errorf("failed to get task: %w", err)
+	}
+	if task == nil {
+		// A task injected into a queue has no row; its outcome is kept
+		// with its injection record instead.
+		if injected, injectErr := s.updateInjectedTaskStatus(ctx, taskID, status, result, errorMsg); injected || injectErr != nil {
+			return injectErr
+		}
+		return fmt.Errorf("%w: %s", ErrTaskNotFound, taskID)
+	}
+
+	if latest := task.LatestAttempt(); attempt > 0 && attempt < latest {
+		s.logger.Warnf("Dropping %s report on attempt %d of task %s, which is on attempt %d", status, attempt, taskID, latest)
+		return fmt.Errorf("%w: attempt %d of task %s, which is on attempt %d", ErrStaleTaskStatus, attempt, taskID, latest)
+	}
+
+	if task.Status.IsTerminal() {
+		s.logger.Warnf("Dropping %s report on task %s, which is already %s", status, taskID, task.Status)
+		return fmt.Errorf("%w: task %s is %s", ErrTaskFinished, taskID, task.Status)
+	}
+
+	result, truncated, err := TruncateResult(result, s.maxResultSize)
+	if err != nil {
+		return err
+	}
+	if truncated {
+		s.logger.Warnf("Truncated result of task %s to %d bytes", task.ID, s.maxResultSize)
+	}
+
+	if err := s.setTaskStatus(ctx, task.WorkflowID, task.ID, status, result, errorMsg); err != nil {
+		return err
+	}
+	s.recordTaskStatus(task, status)
+
+	if status == TaskStatusRetrying {
+		s.checkRetryBudget(ctx, task.WorkflowID)
+	}
+
+	if status.IsTerminal() {
+		if err := s.settleWorkflow(ctx, task.WorkflowID); err != nil {
+			s.logger.Errorf("Failed to settle workflow %s: %v", task.WorkflowID, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Scheduler) setTaskStatus(ctx context.Context, workflowID, taskID string, status TaskStatus, result map[string]interface{}, errorMsg string) error {
+	if err := s.store.UpdateTaskStatus(taskID, status, result, errorMsg); err != nil {
+		return err
+	}
+
+	s.publishEvent(ctx, NewTaskEvent(workflowID, taskID, status, errorMsg))
+	return nil
+}
+
+// setTasksStatus is setTaskStatus for many tasks at once, for statuses
+// that record nothing but the status itself.
+func (s *Scheduler) setTasksStatus(ctx context.Context, tasks []*Task, status TaskStatus) error {
+	ids := make([]string, len(tasks))
+	for i, task := range tasks {
+		ids[i] = task.ID
+	}
+	if err := s.store.UpdateTasksStatus(ids, status); err != nil {
+		return err
+	}
+
+	events := make([]*Event, len(tasks))
+	for i, task := range tasks {
+		events[i] = NewTaskEvent(task.WorkflowID, task.ID, status, "")
+	}
+	s.publishEvents(ctx, events)
+	return nil
+}
+
+func (s *Scheduler) setWorkflowStatus(ctx context.Context, workflowID string, status WorkflowStatus) error {
+	if err := s.store.UpdateWorkflowStatus(workflowID, status); err != nil {
+		return err
+	}
+
+	s.publishEvent(ctx, NewWorkflowEvent(workflowID, status, ""))
 	return nil
 }
 
+func (s *Scheduler) failWorkflow(ctx context.Context, workflowID, errorMsg string) error {
+	if err := s.store.FailWorkflow(workflowID, errorMsg); err != nil {
+		return err
+	}
+
+	s.publishEvent(ctx, NewWorkflowEvent(workflowID, WorkflowStatusFailed, errorMsg))
+	return nil
+}
+
+func (s *Scheduler) publishEvent(ctx context.Context, event *Event) {
+	s.annotateFailure(event)
+	if err := s.queue.PublishEvent(ctx, event); err != nil {
+		s.logger.Errorf("Failed to publish %s event: %v", event.Type, err)
+	}
+	s.queueWebhooks(ctx, event)
+}
+
+// publishEvents is publishEvent for many events, which reach the stream in
+// one round trip.
+func (s *Scheduler) publishEvents(ctx context.Context, events []*Event) {
+	for _, event := range events {
+		s.annotateFailure(event)
+	}
+	if err := s.queue.PublishEvents(ctx, events); err != nil {
+		s.logger.Errorf("Failed to publish %d events: %v", len(events), err)
+	}
+	for _, event := range events {
+		s.queueWebhooks(ctx, event)
+	}
+}
+
+// annotateFailure adds the owner, docs and runbook links of a failed task or
+// workflow to its event, so whoever is paged knows where to start.
+func (s *Scheduler) annotateFailure(event *Event) {
+	var ownership map[string]interface{}
+	switch event.Type {
+	case EventTaskFailed:
+		task, err := s.store.GetTask(event.TaskID)
+		if err != nil {
+			s.logger.Warnf("Failed to get owner of task %s: %v", event.TaskID, err)
+			return
+		}
+		ownership = OwnershipData(task.Owner, task.DocsURL, task.RunbookURL)
+	case EventWorkflowFailed:
+		workflow, err := s.store.GetWorkflow(event.WorkflowID)
+		if err != nil {
+			s.logger.Warnf("Failed to get owner of workflow %s: %v", event.WorkflowID, err)
+			return
+		}
+		ownership = OwnershipData(workflow.Owner, workflow.DocsURL, workflow.RunbookURL)
+	}
+
+	if len(ownership) == 0 {
+		return
+	}
+	if event.Data == nil {
+		event.Data = make(map[string]interface{})
+	}
+	for key, value := range ownership {
+		event.Data[key] = value
+	}
+}
+
+func (s *Scheduler) ReadEvents(ctx context.Context, after string, count int64, block time.Duration) ([]Event, error) {
+	return s.queue.ReadEvents(ctx, after, count, block)
+}
+
+func (s *Scheduler) ReadWorkflowEventsAfter(ctx context.Context, workflowID, after string, count int64, block time.Duration) ([]Event, error) {
+	return s.queue.ReadWorkflowEventsAfter(ctx, workflowID, after, count, block)
+}
+
+func (s *Scheduler) LastEventID(ctx context.Context) (string, error) {
+	return s.queue.LastEventID(ctx)
+}
+
+func (s *Scheduler) ReadTaskOutput(ctx context.Context, taskID string, attempt int, after string, count int64, block time.Duration) ([]TaskOutputChunk, error) {
+	return s.queue.ReadTaskOutput(ctx, taskID, attempt, after, count, block)
+}
+
 func (s *Scheduler) GetWorkflow(workflowID string) (*Workflow, error) {
 	return s.store.GetWorkflow(workflowID)
 }
@@ -214,6 +1157,335 @@ func (s *Scheduler) GetTask(taskID string) (*Task, error) {
 	return s.store.GetTask(taskID)
 }
 
+// WorkflowNamespace returns the namespace of a workflow, and false when
+// there is no such workflow.
+func (s *Scheduler) WorkflowNamespace(workflowID string) (string, bool, error) {
+	return s.store.WorkflowNamespace(workflowID)
+}
+
+// TaskNamespace returns the namespace of a task's workflow, and false when
+// there is no such task.
+func (s *Scheduler) TaskNamespace(taskID string) (string, bool, error) {
+	return s.store.TaskNamespace(taskID)
+}
+
 func (s *Scheduler) GetWorkflowTasks(workflowID string) ([]Task, error) {
 	return s.store.GetTasksByWorkflow(workflowID)
 }
+
+func (s *Scheduler) SetLatencySLO(ctx context.Context, slo LatencySLO) error {
+	return s.queue.SetLatencySLO(ctx, slo)
+}
+
+func (s *Scheduler) DeleteLatencySLO(ctx context.Context, taskType string) error {
+	return s.queue.DeleteLatencySLO(ctx, taskType)
+}
+
+func (s *Scheduler) GetLatencySLOs(ctx context.Context) ([]LatencySLO, error) {
+	return s.queue.GetLatencySLOs(ctx)
+}
+
+func (s *Scheduler) GetQueueLatencyStats(ctx context.Context, taskType string) (*QueueLatencyStats, error) {
+	return s.queue.GetQueueLatencyStats(ctx, taskType)
+}
+
+func (s *Scheduler) SetDeadLetterPolicy(ctx context.Context, policy DeadLetterPolicy) error {
+	return s.queue.SetDeadLetterPolicy(ctx, policy)
+}
+
+func (s *Scheduler) SetRetryOverloadPolicy(ctx context.Context, policy RetryOverloadPolicy) error {
+	return s.queue.SetRetryOverloadPolicy(ctx, policy)
+}
+
+func (s *Scheduler) DeleteRetryOverloadPolicy(ctx context.Context, taskType string) error {
+	return s.queue.DeleteRetryOverloadPolicy(ctx, taskType)
+}
+
+func (s *Scheduler) GetRetryOverloadPolicy(ctx context.Context, taskType string) (*RetryOverloadPolicy, error) {
+	return s.queue.GetRetryOverloadPolicy(ctx, taskType)
+}
+
+func (s *Scheduler) SetDispatchFairnessPolicy(ctx context.Context, policy DispatchFairnessPolicy) error {
+	return s.queue.SetDispatchFairnessPolicy(ctx, policy)
+}
+
+func (s *Scheduler) DeleteDispatchFairnessPolicy(ctx context.Context, taskType string) error {
+	return s.queue.DeleteDispatchFairnessPolicy(ctx, taskType)
+}
+
+func (s *Scheduler) GetDispatchFairnessPolicy(ctx context.Context, taskType string) (*DispatchFairnessPolicy, error) {
+	return s.queue.GetDispatchFairnessPolicy(ctx, taskType)
+}
+
+func (s *Scheduler) SetQueueLengthLimit(ctx context.Context, limit QueueLengthLimit) error {
+	return s.queue.SetQueueLengthLimit(ctx, limit)
+}
+
+func (s *Scheduler) DeleteQueueLengthLimit(ctx context.Context, taskType string) error {
+	return s.queue.DeleteQueueLengthLimit(ctx, taskType)
+}
+
+func (s *Scheduler) GetQueueLengthLimit(ctx context.Context, taskType string) (*QueueLengthLimit, error) {
+	return s.queue.GetQueueLengthLimit(ctx, taskType)
+}
+
+func (s *Scheduler) SetTaskRateLimit(ctx context.Context, limit TaskRateLimit) error {
+	return s.queue.SetTaskRateLimit(ctx, limit)
+}
+
+func (s *Scheduler) DeleteTaskRateLimit(ctx context.Context, taskType string) error {
+	return s.queue.DeleteTaskRateLimit(ctx, taskType)
+}
+
+func (s *Scheduler) GetTaskRateLimit(ctx context.Context, taskType string) (*TaskRateLimit, error) {
+	return s.queue.GetTaskRateLimit(ctx, taskType)
+}
+
+func (s *Scheduler) DeleteDeadLetterPolicy(ctx context.Context, taskType string) error {
+	return s.queue.DeleteDeadLetterPolicy(ctx, taskType)
+}
+
+func (s *Scheduler) GetDeadLetterEntry(ctx context.Context, taskType, taskID string) (*Task, error) {
+	return s.queue.GetDeadLetterEntry(ctx, taskType, taskID)
+}
+
+func (s *Scheduler) GetDeadLetterStats(ctx context.Context, taskType string) (*DeadLetterStats, error) {
+	return s.queue.GetDeadLetterStats(ctx, taskType)
+}
+
+func (s *Scheduler) Redactor(ctx context.Context) Redactor {
+	return s.queue.Redactor(ctx)
+}
+
+func (s *Scheduler) SetRedactionRule(ctx context.Context, rule RedactionRule) error {
+	return s.queue.SetRedactionRule(ctx, rule)
+}
+
+func (s *Scheduler) DeleteRedactionRule(ctx context.Context, taskType string) error {
+	return s.queue.DeleteRedactionRule(ctx, taskType)
+}
+
+func (s *Scheduler) GetRedactionRules(ctx context.Context) ([]RedactionRule, error) {
+	return s.queue.GetRedactionRules(ctx)
+}
+
+func (s *Scheduler) SetSandboxPolicy(ctx context.Context, policy SandboxPolicy) error {
+	return s.queue.SetSandboxPolicy(ctx, policy)
+}
+
+func (s *Scheduler) DeleteSandboxPolicy(ctx context.Context, namespace string) error {
+	return s.queue.DeleteSandboxPolicy(ctx, namespace)
+}
+
+func (s *Scheduler) GetSandboxPolicy(ctx context.Context, namespace string) (*SandboxPolicy, error) {
+	return s.queue.GetSandboxPolicy(ctx, namespace)
+}
+
+func (s *Scheduler) GetActiveWorkers(ctx context.Context, taskType string) ([]WorkerInfo, error) {
+	return s.queue.GetActiveWorkers(ctx, taskType)
+}
+
+func (s *Scheduler) ListWorkers(ctx context.Context) ([]WorkerInfo, error) {
+	return s.queue.ListWorkers(ctx)
+}
+
+// WorkerSummary aggregates the registered workers by task type, counting
+// only those in pool unless it is empty.
+func (s *Scheduler) WorkerSummary(ctx context.Context, pool string) (*WorkerSummary, error) {
+	workers, err := s.queue.ListWorkers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if pool != "" {
+		inPool := workers[:0]
+		for _, worker := range workers {
+			if worker.Pool == pool {
+				inPool = append(inPool, worker)
+			}
+		}
+		workers = inPool
+	}
+
+	return SummarizeWorkers(workers, time.Now()), nil
+}
+
+func (s *Scheduler) GetWorker(ctx context.Context, workerID string) (*WorkerInfo, error) {
+	return s.queue.GetWorker(ctx, workerID)
+}
+
+// RecordTaskUsage stores the resource usage a worker reported for a task.
+func (s *Scheduler) RecordTaskUsage(taskID string, usage ResourceUsage) error {
+	return s.store.SetTaskUsage(taskID, usage)
+}
+
+// RecordTaskAttempt stores where a worker is running an attempt of a task.
+func (s *Scheduler) RecordTaskAttempt(taskID string, env ExecutionEnvironment) error {
+	return s.store.AddTaskAttempt(taskID, env)
+}
+
+// GetWorkflowUsage rolls up the resource usage reported for a workflow's
+// tasks.
+func (s *Scheduler) GetWorkflowUsage(workflowID string) (*WorkflowUsage, error) {
+	tasks, err := s.store.GetTasksByWorkflow(workflowID)
+	if err != nil {
+		return nil, err
+	}
+	return SummarizeUsage(workflowID, tasks), nil
+}
+
+// ListWorkflows returns a page of workflows, newest first, without their
+// tasks, together with how many workflows match the filter in total.
+func (s *Scheduler) ListWorkflows(filter WorkflowFilter) ([]Workflow, int, error) {
+	return s.store.ListWorkflows(filter)
+}
+
+// GetOverview gathers workflow runs and task failures of the last 24 hours
+// together with the current queues, workers and scheduler lag.
+func (s *Scheduler) GetOverview(ctx context.Context) (*Overview, error) {
+	now := time.Now()
+	since := now.Add(-overviewWindow)
+
+	overview := &Overview{
+		GeneratedAt:         now,
+		Window:              overviewWindow,
+		TopFailingTaskTypes: []TaskTypeFailures{},
+		Queues:              make(map[string]QueueDepth),
+		DeadLetters:         make(map[string]int64),
+		Workers:             WorkerCounts{ByType: make(map[string]int)},
+	}
+
+	runs, err := s.store.CountWorkflowsByStatus(since)
+	if err != nil {
+		return nil, err
+	}
+	overview.WorkflowRuns = runs
+
+	failing, err := s.store.GetTopFailingTaskTypes(since, overviewFailingTypes)
+	if err != nil {
+		return nil, err
+	}
+	if failing != nil {
+		overview.TopFailingTaskTypes = failing
+	}
+
+	taskTypes, err := s.queue.GetTaskTypes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	workerIDs := make(map[string]bool)
+	for _, taskType := range taskTypes {
+		stats, err := s.queue.GetQueueStats(ctx, taskType)
+		if err != nil {
+			return nil, err
+		}
+		paused, err := s.queue.IsQueuePaused(ctx, taskType)
+		if err != nil {
+			return nil, err
+		}
+		overview.Queues[taskType] = QueueDepth{
+			Pending:    stats["pending"],
+			Processing: stats["processing"],
+			Retry:      stats["retry"],
+			Delayed:    stats["delayed"],
+			Poison:     stats["poison"],
+			Paused:     paused,
+		}
+		overview.DeadLetters[taskType] = stats["dead_letter"]
+
+		workers, err := s.queue.GetActiveWorkers(ctx, taskType)
+		if err != nil {
+			return nil, err
+		}
+		overview.Workers.ByType[taskType] = len(workers)
+		for _, worker := range workers {
+			workerIDs[worker.ID] = true
+		}
+	}
+	overview.Workers.Total = len(workerIDs)
+
+	leaderID, _, err := s.queue.GetLeader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	overview.Scheduler.LeaderID = leaderID
+
+	due, err := s.store.GetDueSchedules(now)
+	if err != nil {
+		return nil, err
+	}
+	overview.Scheduler.OverdueSchedules = len(due)
+	for _, schedule := range due {
+		if schedule.NextRunAt != nil && now.Sub(*schedule.NextRunAt) > overview.Scheduler.Lag {
+			overview.Scheduler.Lag = now.Sub(*schedule.NextRunAt)
+		}
+	}
+
+	return overview, nil
+}
+
+// GetMetrics counts workflows and tasks by status across the whole store and
+// reports the current queue depths and live workers.
+func (s *Scheduler) GetMetrics(ctx context.Context) (*Metrics, error) {
+	metrics := &Metrics{
+		GeneratedAt: time.Now(),
+		Workflows:   WorkflowMetrics{ByStatus: make(map[WorkflowStatus]int)},
+		Tasks: TaskMetrics{
+			ByStatus: make(map[TaskStatus]int),
+			ByType:   make(map[string]map[TaskStatus]int),
+		},
+		Queues: make(map[string]map[string]int64),
+	}
+
+	workflows, err := s.store.CountWorkflowsByStatus(time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	for status, count := range workflows {
+		metrics.Workflows.ByStatus[status] = count
+		metrics.Workflows.Total += count
+	}
+
+	tasks, err := s.store.CountTasksByTypeAndStatus()
+	if err != nil {
+		return nil, err
+	}
+	for taskType, counts := range tasks {
+		metrics.Tasks.ByType[taskType] = counts
+		for status, count := range counts {
+			metrics.Tasks.ByStatus[status] += count
+			metrics.Tasks.Total += count
+		}
+	}
+
+	taskTypes, err := s.queue.GetTaskTypes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, taskType := range taskTypes {
+		stats, err := s.queue.GetQueueStats(ctx, taskType)
+		if err != nil {
+			return nil, err
+		}
+		metrics.Queues[taskType] = stats
+	}
+
+	workers, err := s.queue.ListWorkers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, worker := range workers {
+		if worker.Status == WorkerStatusStale {
+			continue
+		}
+		metrics.Workers.Active++
+		if len(worker.CurrentTasks) > 0 {
+			metrics.Workers.Busy++
+		} else {
+			metrics.Workers.Idle++
+		}
+	}
+
+	return metrics, nil
+}
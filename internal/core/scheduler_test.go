@@ -0,0 +1,376 @@
+package core
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSchedulerRunsOnAnyBroker(t *testing.T) {
+	broker := newFakeBroker()
+	store := newFakeStore()
+	workflow := NewWorkflow("nightly", "")
+	workflow.Tasks = []Task{*NewTask(workflow.ID, "extract", "etl", nil)}
+	store.add(workflow)
+
+	s := newTestScheduler(store, broker)
+	ctx := context.Background()
+
+	if err := s.PauseQueue(ctx, "etl"); err != nil {
+		t.Fatalf("PauseQueue: %v", err)
+	}
+	if !broker.paused["etl"] {
+		t.Fatal("pausing a queue did not reach the broker")
+	}
+
+	got, err := s.GetWorkflow(workflow.ID)
+	if err != nil {
+		t.Fatalf("GetWorkflow: %v", err)
+	}
+	if len(got.Tasks) != 1 || got.Tasks[0].Name != "extract" {
+		t.Fatalf("GetWorkflow returned tasks %+v, want the stored task", got.Tasks)
+	}
+}
+
+func TestDispatchBatchFailsOnlyUnserializableTasks(t *testing.T) {
+	broker := newFakeBroker()
+	store := newFakeStore()
+	workflow := &Workflow{ID: "wf-1", Status: WorkflowStatusRunning}
+	workflow.Tasks = []Task{
+		{ID: "extract", WorkflowID: "wf-1", Name: "extract", Type: "etl", Status: TaskStatusPending},
+		{ID: "broken", WorkflowID: "wf-1", Name: "broken", Type: "etl", Status: TaskStatusPending,
+			Payload: map[string]interface{}{"ratio": math.NaN()}},
+		{ID: "load", WorkflowID: "wf-1", Name: "load", Type: "etl", Status: TaskStatusPending},
+	}
+	store.add(workflow)
+
+	s := newTestScheduler(store, broker)
+	var batch []*Task
+	for _, id := range []string{"extract", "broken", "load"} {
+		task, _ := store.GetTask(id)
+		batch = append(batch, task)
+	}
+
+	if err := s.dispatchBatch(context.Background(), batch); err != nil {
+		t.Fatalf("dispatchBatch() error = %v", err)
+	}
+
+	if len(broker.enqueued) != 2 {
+		t.Errorf("enqueued %d tasks, want the 2 that serialize", len(broker.enqueued))
+	}
+	for _, id := range []string{"extract", "load"} {
+		if task, _ := store.GetTask(id); task.Status != TaskStatusQueued {
+			t.Errorf("task %s is %s, want queued", id, task.Status)
+		}
+	}
+	broken, _ := store.GetTask("broken")
+	if broken.Status != TaskStatusFailed || !strings.HasPrefix(broken.Error, "failed to serialize task") {
+		t.Errorf("broken task is %s (%q), want failed on serialization", broken.Status, broken.Error)
+	}
+
+	queuedEvents := 0
+	for _, event := range broker.events {
+		if event.Type == EventTaskQueued {
+			queuedEvents++
+		}
+	}
+	if queuedEvents != 3 {
+		t.Errorf("published %d task queued events, want one per task in the batch", queuedEvents)
+	}
+}
+
+func TestScheduleRejectsInvalidCron(t *testing.T) {
+	workflow := WorkflowDefinition{
+		Name:  "nightly",
+		Tasks: []TaskDefinition{{Name: "extract", Type: "etl"}},
+	}
+	for _, tt := range []struct{ cron, timezone string }{
+		{"", "UTC"},
+		{"not a cron", "UTC"},
+		{"61 * * * *", "UTC"},
+		{"0 0 * *", "UTC"},
+		{"0 0 31 2 * *", "UTC"},
+		{"0 2 * * *", "Mars/Olympus_Mons"},
+	} {
+		if err := NewSchedule("nightly", tt.cron, tt.timezone, workflow).Validate(); err == nil {
+			t.Errorf("Validate() accepted cron %q in %q", tt.cron, tt.timezone)
+		}
+	}
+
+	if err := NewSchedule("nightly", "0 2 * * *", "Europe/Berlin", workflow).Validate(); err != nil {
+		t.Errorf("Validate() rejected a valid schedule: %v", err)
+	}
+}
+
+func TestDueScheduleFiresOncePerPeriod(t *testing.T) {
+	store := newFakeStore()
+	schedule := NewSchedule("hourly", "0 * * * *", "UTC", WorkflowDefinition{
+		Name:  "hourly",
+		Tasks: []TaskDefinition{{Name: "extract", Type: "etl"}},
+	})
+	due := time.Now().Add(-time.Second)
+	schedule.NextRunAt = &due
+	store.addSchedule(schedule)
+
+	s := newTestScheduler(store, newFakeBroker())
+	for i := 0; i < 3; i++ {
+		if err := s.fireDueSchedules(context.Background()); err != nil {
+			t.Fatalf("fireDueSchedules() error = %v", err)
+		}
+	}
+
+	if len(store.created) != 1 {
+		t.Errorf("schedule started %d workflows in one period, want 1", len(store.created))
+	}
+	next := store.schedules[schedule.ID].NextRunAt
+	if next == nil || !next.After(time.Now()) || next.Minute() != 0 {
+		t.Errorf("next run at %v, want the top of a coming hour", next)
+	}
+}
+
+func TestCalendarValidate(t *testing.T) {
+	for _, calendar := range []Calendar{
+		{Dates: []string{"2026-12-25", "25/12/2026"}},
+		{ICalURL: "ftp://example.com/holidays.ics"},
+		{Action: "postpone"},
+	} {
+		if err := calendar.Validate(); err == nil {
+			t.Errorf("Validate() accepted %+v", calendar)
+		}
+	}
+
+	valid := Calendar{Dates: []string{"2026-12-25"}, ICalURL: "https://example.com/holidays.ics", Action: CalendarShift}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Validate() rejected a valid calendar: %v", err)
+	}
+}
+
+func TestHolidaysNextBusinessDay(t *testing.T) {
+	holidays, err := NewCalendarCache().Holidays(context.Background(), &Calendar{
+		Dates:        []string{"2026-12-25", "2026-12-28"},
+		SkipWeekends: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Friday 25th is a holiday, then a weekend, then a holiday on Monday.
+	christmas := time.Date(2026, 12, 25, 9, 30, 0, 0, time.UTC)
+	if !holidays.Contains(christmas) || holidays.Contains(christmas.AddDate(0, 0, -1)) {
+		t.Error("Contains() does not match the listed dates")
+	}
+	if !holidays.Contains(christmas.AddDate(0, 0, 1)) {
+		t.Error("Contains() does not skip weekends")
+	}
+
+	next, err := holidays.NextBusinessDay(christmas)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2026, 12, 29, 9, 30, 0, 0, time.UTC); !next.Equal(want) {
+		t.Errorf("NextBusinessDay() = %s, want %s", next, want)
+	}
+}
+
+func TestCalendarCacheFetchesICalFeedOnce(t *testing.T) {
+	var fetches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		w.Write([]byte("BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20261225\r\nEND:VEVENT\r\n" +
+			"BEGIN:VEVENT\r\nDTSTART:20270101T000000Z\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"))
+	}))
+	defer server.Close()
+
+	cache := NewCalendarCache()
+	calendar := &Calendar{ICalURL: server.URL}
+	for i := 0; i < 3; i++ {
+		holidays, err := cache.Holidays(context.Background(), calendar)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, date := range []time.Time{
+			time.Date(2026, 12, 25, 12, 0, 0, 0, time.UTC),
+			time.Date(2027, 1, 1, 12, 0, 0, 0, time.UTC),
+		} {
+			if !holidays.Contains(date) {
+				t.Errorf("feed holidays do not contain %s", date.Format(calendarDateLayout))
+			}
+		}
+	}
+	if fetches := atomic.LoadInt32(&fetches); fetches != 1 {
+		t.Errorf("fetched the feed %d times, want 1 within the cache TTL", fetches)
+	}
+
+	// An unavailable feed falls back to the last good copy.
+	server.Close()
+	cache.feeds[server.URL] = cachedFeed{dates: cache.feeds[server.URL].dates, fetchedAt: time.Now().Add(-2 * calendarCacheTTL)}
+	holidays, err := cache.Holidays(context.Background(), calendar)
+	if err != nil {
+		t.Fatalf("Holidays() with the feed down error = %v, want the cached copy", err)
+	}
+	if !holidays.Contains(time.Date(2026, 12, 25, 12, 0, 0, 0, time.UTC)) {
+		t.Error("cached copy lost the feed's holidays")
+	}
+}
+
+func TestScheduleRunOnHoliday(t *testing.T) {
+	for _, action := range []CalendarAction{CalendarSkip, CalendarShift} {
+		t.Run(string(action), func(t *testing.T) {
+			due := time.Now().UTC().Add(-time.Second)
+			schedule := NewSchedule("yearly", "0 0 1 1 *", "UTC", WorkflowDefinition{
+				Name:  "yearly",
+				Tasks: []TaskDefinition{{Name: "extract", Type: "etl"}},
+			})
+			schedule.NextRunAt = &due
+			schedule.Calendar = &Calendar{Dates: []string{due.Format(calendarDateLayout)}, Action: action}
+
+			store := newFakeStore()
+			store.addSchedule(schedule)
+			broker := newFakeBroker()
+			s := newTestScheduler(store, broker)
+
+			if err := s.fireDueSchedules(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if len(store.created) != 0 {
+				t.Errorf("a run on a holiday started %d workflows", len(store.created))
+			}
+
+			regular, _ := schedule.Next(time.Now())
+			want, event := regular, EventScheduleSkipped
+			if action == CalendarShift {
+				event = EventScheduleShifted
+				// A shift past the next regular run merges into it.
+				if shifted := due.AddDate(0, 0, 1); shifted.Before(regular) {
+					want = shifted
+				}
+			}
+			if next := store.schedules[schedule.ID].NextRunAt; next == nil || !next.Equal(want) {
+				t.Errorf("next run at %v, want %s", next, want)
+			}
+			if len(broker.events) != 1 || broker.events[0].Type != event {
+				t.Errorf("published %+v, want one %s event", broker.events, event)
+			}
+		})
+	}
+}
+
+func TestGroupConcurrencyCapsDispatch(t *testing.T) {
+	workflow := &Workflow{
+		ID:     "wf",
+		Status: WorkflowStatusRunning,
+		Config: WorkflowConfig{GroupConcurrency: map[string]int{"shards": 2}},
+		Tasks: []Task{
+			{ID: "shard-1", Name: "shard-1", Type: "etl", Group: "shards", Status: TaskStatusRunning},
+			{ID: "shard-2", Name: "shard-2", Type: "etl", Group: "shards", Status: TaskStatusPending},
+			{ID: "shard-3", Name: "shard-3", Type: "etl", Group: "shards", Status: TaskStatusPending},
+			{ID: "shard-4", Name: "shard-4", Type: "etl", Group: "shards", Status: TaskStatusPending},
+			{ID: "report", Name: "report", Type: "etl", Group: "reports", Status: TaskStatusPending},
+			{ID: "notify", Name: "notify", Type: "etl", Status: TaskStatusPending},
+		},
+	}
+
+	s := newTestScheduler(newFakeStore(), newFakeBroker())
+	dispatch, blocked := s.planWorkflowTasks(context.Background(), workflow, workflow.Tasks[1:])
+
+	var dispatched []string
+	for _, task := range dispatch {
+		dispatched = append(dispatched, task.ID)
+	}
+	if want := []string{"shard-2", "report", "notify"}; strings.Join(dispatched, ",") != strings.Join(want, ",") {
+		t.Errorf("dispatched %v, want %v", dispatched, want)
+	}
+
+	if len(blocked) != 2 {
+		t.Fatalf("blocked %d tasks, want shard-3 and shard-4", len(blocked))
+	}
+	for _, decision := range blocked {
+		if decision.Reason != BlockReasonGroupConcurrencyLimit {
+			t.Errorf("task %s blocked for %s, want %s", decision.TaskID, decision.Reason, BlockReasonGroupConcurrencyLimit)
+		}
+	}
+}
+
+func TestValidateGroupConcurrency(t *testing.T) {
+	for _, limits := range []map[string]int{
+		{"": 2},
+		{strings.Repeat("g", maxGroupLength+1): 2},
+		{"shards": 0},
+	} {
+		if err := validateGroupConcurrency(limits); err == nil {
+			t.Errorf("validateGroupConcurrency(%v) accepted an invalid limit", limits)
+		}
+	}
+	if err := validateGroupConcurrency(map[string]int{"shards": 4}); err != nil {
+		t.Errorf("validateGroupConcurrency() rejected a valid limit: %v", err)
+	}
+}
+
+func TestTaskRateLimitValidate(t *testing.T) {
+	for _, limit := range []TaskRateLimit{
+		{Limit: 0, Interval: time.Minute, EnforceAt: RateLimitAtDequeue},
+		{Limit: 10, Interval: time.Microsecond, EnforceAt: RateLimitAtDequeue},
+		{Limit: 10, Interval: MaxRateLimitInterval + time.Second, EnforceAt: RateLimitAtDequeue},
+		{Limit: 10, Interval: time.Minute, EnforceAt: "submit"},
+	} {
+		if err := limit.Validate(); err == nil {
+			t.Errorf("Validate() accepted %+v", limit)
+		}
+	}
+	if err := (&TaskRateLimit{Limit: 10, Interval: time.Minute, EnforceAt: RateLimitAtEnqueue}).Validate(); err != nil {
+		t.Errorf("Validate() rejected a valid limit: %v", err)
+	}
+}
+
+func TestLimitDispatchRateHoldsBackTasksOverTheLimit(t *testing.T) {
+	broker := newFakeBroker()
+	broker.rateLimits = map[string]TaskRateLimit{
+		"etl":   {TaskType: "etl", Limit: 2, Interval: time.Minute, EnforceAt: RateLimitAtEnqueue},
+		"video": {TaskType: "video", Limit: 1, Interval: time.Minute, EnforceAt: RateLimitAtDequeue},
+	}
+	broker.rateTokens = map[string]int{"etl": 2, "video": 0}
+	s := newTestScheduler(newFakeStore(), broker)
+
+	tasks := []Task{
+		{ID: "etl-1", Type: "etl"},
+		{ID: "video-1", Type: "video"},
+		{ID: "etl-2", Type: "etl"},
+		{ID: "etl-3", Type: "etl"},
+		{ID: "script-1", Type: "script"},
+	}
+	ids := func(tasks []Task) string {
+		var ids []string
+		for _, task := range tasks {
+			ids = append(ids, task.ID)
+		}
+		return strings.Join(ids, ",")
+	}
+
+	// A dry run reads the limit without using it up.
+	dispatch, blocked := s.limitDispatchRate(context.Background(), tasks, true)
+	if got := ids(dispatch); got != "etl-1,video-1,etl-2,script-1" || len(blocked) != 1 {
+		t.Errorf("dry run dispatched %s and blocked %d tasks, want etl-3 held back", got, len(blocked))
+	}
+	if broker.rateTokens["etl"] != 2 {
+		t.Errorf("dry run used %d rate tokens", 2-broker.rateTokens["etl"])
+	}
+
+	dispatch, blocked = s.limitDispatchRate(context.Background(), tasks, false)
+	if got := ids(dispatch); got != "etl-1,video-1,etl-2,script-1" {
+		t.Errorf("dispatched %s, want the tasks within the limit and those limited at dequeue", got)
+	}
+	if len(blocked) != 1 || blocked[0].TaskID != "etl-3" || blocked[0].Reason != BlockReasonRateLimited {
+		t.Errorf("blocked %+v, want etl-3 rate limited", blocked)
+	}
+
+	// The limit is used up for the rest of its window.
+	dispatch, _ = s.limitDispatchRate(context.Background(), tasks[3:4], false)
+	if len(dispatch) != 0 {
+		t.Errorf("dispatched %s past a used-up limit", ids(dispatch))
+	}
+}
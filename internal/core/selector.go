@@ -0,0 +1,102 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// maxRoutingLabelLength bounds the names and values of worker labels and
+// task selectors.
+const maxRoutingLabelLength = 63
+
+// ValidateSelector checks a task's label selector. A task with a selector
+// is only delivered to workers carrying every label it names, with the
+// same value.
+func ValidateSelector(selector map[string]string) error {
+	return validateRoutingLabels("selector", selector)
+}
+
+// ValidateWorkerLabels checks the labels a worker registers with.
+func ValidateWorkerLabels(labels map[string]string) error {
+	return validateRoutingLabels("worker label", labels)
+}
+
+// validateRoutingLabels checks labels used to route tasks. Names may only
+// contain letters, digits, '-', '_', '.' and '/', and values the same
+// but '/', so that a selector can name a queue.
+func validateRoutingLabels(what string, labels map[string]string) error {
+	for name, value := range labels {
+		if name == "" || len(name) > maxRoutingLabelLength {
+			return fmt.Errorf("%s name must be 1 to %d characters", what, maxRoutingLabelLength)
+		}
+		if len(value) > maxRoutingLabelLength {
+			return fmt.Errorf("%s %s: value is longer than %d characters", what, name, maxRoutingLabelLength)
+		}
+		for _, r := range name {
+			if !routingLabelRune(r) && r != '/' {
+				return fmt.Errorf("%s name %s contains %q", what, name, r)
+			}
+		}
+		for _, r := range value {
+			if !routingLabelRune(r) {
+				return fmt.Errorf("%s %s: value %s contains %q", what, name, value, r)
+			}
+		}
+	}
+	return nil
+}
+
+func routingLabelRune(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return true
+	case r == '-', r == '_', r == '.':
+		return true
+	}
+	return false
+}
+
+// FormatSelector writes a selector as name=value pairs sorted by name,
+// such as "gpu=true,zone=us-east-1", so equal selectors read the same.
+func FormatSelector(selector map[string]string) string {
+	names := make([]string, 0, len(selector))
+	for name := range selector {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + "=" + selector[name]
+	}
+	return strings.Join(pairs, ",")
+}
+
+// ParseSelector reads a selector written by FormatSelector.
+func ParseSelector(s string) (map[string]string, error) {
+	selector := make(map[string]string)
+	if s == "" {
+		return selector, nil
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("selector %q: %q is not name=value", s, pair)
+		}
+		selector[name] = value
+	}
+	return selector, nil
+}
+
+// SelectorMatches reports whether labels carry every label of selector
+// with the same value. The empty selector matches every worker.
+func SelectorMatches(selector, labels map[string]string) bool {
+	for name, value := range selector {
+		if label, ok := labels[name]; !ok || label != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,66 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFormatSelectorSortsByName(t *testing.T) {
+	tests := []struct {
+		selector map[string]string
+		want     string
+	}{
+		{nil, ""},
+		{map[string]string{"gpu": "true"}, "gpu=true"},
+		{map[string]string{"zone": "us-east-1", "gpu": "true", "node/arch": "arm64"}, "gpu=true,node/arch=arm64,zone=us-east-1"},
+	}
+	for _, tt := range tests {
+		if got := FormatSelector(tt.selector); got != tt.want {
+			t.Errorf("FormatSelector(%v) = %q, want %q", tt.selector, got, tt.want)
+		}
+	}
+}
+
+func TestParseSelectorReadsFormatSelector(t *testing.T) {
+	for _, selector := range []map[string]string{
+		{},
+		{"gpu": "true"},
+		{"gpu": "true", "zone": "us-east-1", "empty": ""},
+	} {
+		got, err := ParseSelector(FormatSelector(selector))
+		if err != nil {
+			t.Fatalf("ParseSelector(FormatSelector(%v)) error = %v", selector, err)
+		}
+		if !reflect.DeepEqual(got, selector) {
+			t.Errorf("ParseSelector(FormatSelector(%v)) = %v", selector, got)
+		}
+	}
+
+	if _, err := ParseSelector("gpu=true,zone"); err == nil {
+		t.Error("ParseSelector accepted a pair without a value")
+	}
+}
+
+func TestSelectorMatches(t *testing.T) {
+	labels := map[string]string{"gpu": "true", "zone": "us-east-1"}
+	tests := []struct {
+		name     string
+		selector map[string]string
+		labels   map[string]string
+		want     bool
+	}{
+		{"empty selector", nil, nil, true},
+		{"subset of labels", map[string]string{"gpu": "true"}, labels, true},
+		{"every label", map[string]string{"gpu": "true", "zone": "us-east-1"}, labels, true},
+		{"other value", map[string]string{"zone": "eu-west-1"}, labels, false},
+		{"missing label", map[string]string{"arch": "arm64"}, labels, false},
+		{"unlabeled worker", map[string]string{"gpu": "true"}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SelectorMatches(tt.selector, tt.labels); got != tt.want {
+				t.Errorf("SelectorMatches(%v, %v) = %v, want %v", tt.selector, tt.labels, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package core
+
+import "time"
+
+type LatencySLO struct {
+	TaskType  string        `json:"task_type"`
+	Threshold time.Duration `json:"threshold"`
+	Objective float64       `json:"objective"`
+}
+
+type LatencyBucket struct {
+	UpperBound time.Duration `json:"le"`
+	Count      int64         `json:"count"`
+}
+
+type BurnRate struct {
+	Window   time.Duration `json:"window"`
+	Total    int64         `json:"total"`
+	Breached int64         `json:"breached"`
+	Rate     float64       `json:"burn_rate"`
+}
+
+type QueueLatencyStats struct {
+	TaskType  string          `json:"task_type"`
+	Count     int64           `json:"count"`
+	Mean      time.Duration   `json:"mean"`
+	Buckets   []LatencyBucket `json:"buckets"`
+	SLO       *LatencySLO     `json:"slo,omitempty"`
+	BurnRates []BurnRate      `json:"burn_rates,omitempty"`
+}
+
+func (s *LatencySLO) ErrorBudget() float64 {
+	return 1 - s.Objective
+}
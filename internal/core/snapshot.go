@@ -0,0 +1,140 @@
+package core
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrWorkflowNotCreated = errors.New("workflow did not exist yet")
+
+// WorkflowSnapshot is a workflow as the scheduler saw it at AsOf, rebuilt
+// from the event log. Task definitions are today's; statuses, errors,
+// retry counts and timestamps are those of AsOf. HistoryComplete is false
+// when the log no longer reaches back to the workflow's creation, in which
+// case tasks whose early events were trimmed may show as pending.
+type WorkflowSnapshot struct {
+	Workflow
+	AsOf            time.Time `json:"as_of"`
+	EventsApplied   int       `json:"events_applied"`
+	HistoryComplete bool      `json:"history_complete"`
+}
+
+// ReplayWorkflow rebuilds the state of workflow at asOf from its events,
+// oldest first. Events of other workflows and events after asOf are
+// ignored.
+func ReplayWorkflow(workflow *Workflow, events []Event, asOf time.Time) (*WorkflowSnapshot, error) {
+	if asOf.Before(workflow.CreatedAt) {
+		return nil, ErrWorkflowNotCreated
+	}
+
+	snapshot := &WorkflowSnapshot{Workflow: *workflow, AsOf: asOf}
+	snapshot.Status = WorkflowStatusPending
+	snapshot.Error = ""
+	snapshot.StartedAt = nil
+	snapshot.CompletedAt = nil
+	snapshot.UpdatedAt = workflow.CreatedAt
+
+	snapshot.Tasks = make([]Task, len(workflow.Tasks))
+	tasks := make(map[string]*Task, len(workflow.Tasks))
+	for i, task := range workflow.Tasks {
+		task.Status = TaskStatusPending
+		task.Error = ""
+		task.RetryCount = 0
+		task.StartedAt = nil
+		task.CompletedAt = nil
+		task.EnqueuedAt = nil
+		task.DeadLetteredAt = nil
+		task.UpdatedAt = task.CreatedAt
+
+		var attempts []ExecutionEnvironment
+		for _, attempt := range task.Attempts {
+			if !attempt.StartedAt.After(asOf) {
+				attempts = append(attempts, attempt)
+			}
+		}
+		task.Attempts = attempts
+
+		snapshot.Tasks[i] = task
+		tasks[task.ID] = &snapshot.Tasks[i]
+	}
+
+	for _, event := range events {
+		if event.WorkflowID != workflow.ID || event.Timestamp.After(asOf) {
+			continue
+		}
+
+		if event.TaskID == "" {
+			snapshot.applyWorkflowEvent(event)
+			snapshot.EventsApplied++
+			continue
+		}
+
+		task, ok := tasks[event.TaskID]
+		if !ok || event.Type == EventTaskQuarantined {
+			continue
+		}
+		applyTaskEvent(task, event)
+		snapshot.EventsApplied++
+	}
+
+	// Results and usage are only known once a task completed; a result
+	// from a later run must not show through.
+	for i := range snapshot.Tasks {
+		task := &snapshot.Tasks[i]
+		if task.Status != TaskStatusCompleted {
+			task.Result = nil
+			task.Usage = nil
+		}
+	}
+
+	return snapshot, nil
+}
+
+func (w *WorkflowSnapshot) applyWorkflowEvent(event Event) {
+	timestamp := event.Timestamp
+	w.UpdatedAt = timestamp
+
+	switch event.Type {
+	case EventWorkflowCreated:
+		w.HistoryComplete = true
+		w.Status = WorkflowStatusPending
+	case EventWorkflowStarted:
+		w.Status = WorkflowStatusRunning
+		w.CompletedAt = nil
+		w.Error = ""
+		if w.StartedAt == nil {
+			w.StartedAt = &timestamp
+		}
+	default:
+		w.Status = WorkflowStatus(event.Status)
+		w.Error = event.Error
+		if w.Status.IsTerminal() {
+			w.CompletedAt = &timestamp
+		}
+	}
+}
+
+func applyTaskEvent(task *Task, event Event) {
+	timestamp := event.Timestamp
+	task.Status = TaskStatus(event.Status)
+	task.UpdatedAt = timestamp
+
+	switch task.Status {
+	case TaskStatusPending:
+		task.Error = ""
+		task.CompletedAt = nil
+	case TaskStatusQueued:
+		task.EnqueuedAt = &timestamp
+	case TaskStatusRunning:
+		task.StartedAt = &timestamp
+	case TaskStatusRetrying:
+		task.RetryCount++
+		task.Error = event.Error
+		task.CompletedAt = nil
+	default:
+		task.Error = event.Error
+		if task.Status.IsTerminal() {
+			task.CompletedAt = &timestamp
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestReportTaskStatusOfMissingTask(t *testing.T) {
+	s := newTestScheduler(newFakeStore(), newFakeBroker())
+
+	err := s.ReportTaskStatus(context.Background(), "gone", 1, TaskStatusCompleted, nil, "")
+	if !errors.Is(err, ErrTaskNotFound) {
+		t.Fatalf("ReportTaskStatus() error = %v, want ErrTaskNotFound", err)
+	}
+}
+
+func TestReportTaskStatusDropsStaleAttempts(t *testing.T) {
+	store := newFakeStore()
+	store.add(&Workflow{
+		ID: "wf",
+		Tasks: []Task{{
+			ID:         "task",
+			WorkflowID: "wf",
+			Status:     TaskStatusRunning,
+			Attempts:   []ExecutionEnvironment{{Attempt: 1}, {Attempt: 2}},
+		}},
+	})
+	s := newTestScheduler(store, newFakeBroker())
+	ctx := context.Background()
+
+	// A replayed report from the first attempt must not overwrite the
+	// second one.
+	err := s.ReportTaskStatus(ctx, "task", 1, TaskStatusRetrying, nil, "boom")
+	if !errors.Is(err, ErrStaleTaskStatus) {
+		t.Fatalf("ReportTaskStatus() of attempt 1 error = %v, want ErrStaleTaskStatus", err)
+	}
+	if task, _ := store.FindTask("task"); task.Status != TaskStatusRunning {
+		t.Errorf("status after stale report = %s, want %s", task.Status, TaskStatusRunning)
+	}
+
+	if err := s.ReportTaskStatus(ctx, "task", 2, TaskStatusRunning, nil, ""); err != nil {
+		t.Fatalf("ReportTaskStatus() of attempt 2: %v", err)
+	}
+	// Reports without an attempt come from workers that predate it.
+	if err := s.ReportTaskStatus(ctx, "task", 0, TaskStatusRunning, nil, ""); err != nil {
+		t.Fatalf("ReportTaskStatus() without an attempt: %v", err)
+	}
+}
+
+func TestReportTaskStatusKeepsCancellation(t *testing.T) {
+	store := newFakeStore()
+	store.add(&Workflow{
+		ID:     "wf",
+		Status: WorkflowStatusRunning,
+		Tasks: []Task{
+			{ID: "running", WorkflowID: "wf", Name: "running", Type: "etl", Status: TaskStatusRunning},
+			{ID: "done", WorkflowID: "wf", Name: "done", Type: "etl", Status: TaskStatusCompleted},
+		},
+	})
+	s := newTestScheduler(store, newFakeBroker())
+	ctx := context.Background()
+
+	if err := s.CancelWorkflow(ctx, "wf"); err != nil {
+		t.Fatalf("CancelWorkflow() error = %v", err)
+	}
+
+	// The worker running the task finishes it before the cancellation
+	// reaches it.
+	for _, status := range []TaskStatus{TaskStatusCompleted, TaskStatusFailed, TaskStatusRetrying} {
+		err := s.ReportTaskStatus(ctx, "running", 1, status, map[string]interface{}{"rows": 10}, "")
+		if !errors.Is(err, ErrTaskFinished) {
+			t.Errorf("%s report error = %v, want ErrTaskFinished", status, err)
+		}
+	}
+	if err := s.ReportTaskStatus(ctx, "done", 0, TaskStatusFailed, nil, "late"); !errors.Is(err, ErrTaskFinished) {
+		t.Errorf("report on a completed task error = %v, want ErrTaskFinished", err)
+	}
+
+	if task, _ := store.FindTask("running"); task.Status != TaskStatusCancelled || task.Result != nil {
+		t.Errorf("cancelled task is %s with result %v, want cancelled without one", task.Status, task.Result)
+	}
+	if task, _ := store.FindTask("done"); task.Status != TaskStatusCompleted {
+		t.Errorf("completed task is %s, want completed", task.Status)
+	}
+	if workflow, _ := store.GetWorkflow("wf"); workflow.Status != WorkflowStatusCancelled {
+		t.Errorf("workflow is %s, want cancelled", workflow.Status)
+	}
+}
@@ -0,0 +1,67 @@
+package core
+
+import (
+	"context"
+	"time"
+)
+
+// Store is the scheduler's durable record of workflows, tasks, schedules
+// and workflow templates. The storage package's PostgresStore implements
+// it.
+type Store interface {
+	Ping(ctx context.Context) error
+
+	// Workflows.
+	CreateWorkflow(workflow *Workflow) error
+	CreateWorkflowWithTasks(workflow *Workflow) error
+	GetWorkflow(id string) (*Workflow, error)
+	WorkflowExists(id string) (bool, error)
+	WorkflowNamespace(id string) (string, bool, error)
+	ListWorkflows(filter WorkflowFilter) ([]Workflow, int, error)
+	GetRunningWorkflows() ([]Workflow, error)
+	UpdateWorkflowStatus(id string, status WorkflowStatus) error
+	TransitionWorkflowStatus(id string, from, to WorkflowStatus) (bool, error)
+	ReopenWorkflow(id string) (bool, error)
+	FailWorkflow(id, errorMsg string) error
+	DeleteWorkflow(id string) error
+	RetryBudgetUsage(workflowID string) (int, int, error)
+	CountWorkflowsByStatus(since time.Time) (map[WorkflowStatus]int, error)
+
+	// Tasks.
+	CreateTasks(tasks []Task) error
+	GetTask(id string) (*Task, error)
+	FindTask(id string) (*Task, error)
+	TaskNamespace(id string) (string, bool, error)
+	ExistingTaskIDs(ids []string) ([]string, error)
+	GetTasksByWorkflow(workflowID string) ([]Task, error)
+	GetPendingTasks() ([]Task, error)
+	GetInFlightTasks() ([]Task, error)
+	GetExpiredTasks(now time.Time, limit int) ([]Task, error)
+	ClaimIdempotencyKey(namespace, key, taskID, stale string) (string, error)
+	UpdateTaskStatus(id string, status TaskStatus, result map[string]interface{}, errorMsg string) error
+	UpdateTasksStatus(ids []string, status TaskStatus) error
+	ResetTask(id string, resetRetries bool) (bool, error)
+	SetTaskDuplicateOf(id, originalID string) error
+	SetTaskUsage(id string, usage ResourceUsage) error
+	AddTaskAttempt(id string, env ExecutionEnvironment) error
+	CountTasksByTypeAndStatus() (map[string]map[TaskStatus]int, error)
+	GetTopFailingTaskTypes(since time.Time, limit int) ([]TaskTypeFailures, error)
+
+	// Schedules.
+	CreateSchedule(schedule *Schedule) error
+	GetSchedule(id string) (*Schedule, error)
+	ListSchedules() ([]Schedule, error)
+	GetDueSchedules(now time.Time) ([]Schedule, error)
+	UpdateSchedule(schedule *Schedule) error
+	UpdateScheduleRun(id string, lastRunAt, nextRunAt time.Time) error
+	SetScheduleNextRun(id string, nextRunAt time.Time) error
+	DeleteSchedule(id string) error
+
+	// Workflow templates.
+	CreateWorkflowTemplate(tmpl *WorkflowTemplate) error
+	AddWorkflowTemplateVersion(tmpl *WorkflowTemplate) error
+	GetWorkflowTemplate(name string, version int) (*WorkflowTemplate, error)
+	ListWorkflowTemplates() ([]WorkflowTemplate, error)
+	ListWorkflowTemplateVersions(name string) ([]WorkflowTemplate, error)
+	DeleteWorkflowTemplate(name string) error
+}
@@ -0,0 +1,41 @@
+package core
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SubmissionStatus string
+
+const (
+	SubmissionStatusInProgress SubmissionStatus = "in_progress"
+	SubmissionStatusCompleted  SubmissionStatus = "completed"
+	SubmissionStatusFailed     SubmissionStatus = "failed"
+)
+
+// Submission tracks the background materialization of a workflow submitted
+// asynchronously. The workflow stays in the submitting state, invisible to
+// dispatch, until every task has been persisted.
+type Submission struct {
+	ID             string           `json:"id"`
+	WorkflowID     string           `json:"workflow_id"`
+	Status         SubmissionStatus `json:"status"`
+	TotalTasks     int              `json:"total_tasks"`
+	PersistedTasks int              `json:"persisted_tasks"`
+	Error          string           `json:"error,omitempty"`
+	CreatedAt      time.Time        `json:"created_at"`
+	UpdatedAt      time.Time        `json:"updated_at"`
+}
+
+func NewSubmission(workflowID string, totalTasks int) *Submission {
+	now := time.Now()
+	return &Submission{
+		ID:         uuid.New().String(),
+		WorkflowID: workflowID,
+		Status:     SubmissionStatusInProgress,
+		TotalTasks: totalTasks,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+}
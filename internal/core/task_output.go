@@ -0,0 +1,90 @@
+package core
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// EventTaskOutput is the server-sent event carrying a TaskOutputChunk.
+const EventTaskOutput EventType = "task.output"
+
+// Limits on streamed task output. A chunk holds at most
+// MaxTaskOutputChunkSize bytes; an attempt streams at most
+// MaxTaskOutputSize bytes, after which the rest of its output is dropped
+// and its final chunk is marked truncated. Output is kept for
+// TaskOutputRetention after the attempt last wrote.
+const (
+	MaxTaskOutputChunkSize = 16 * 1024
+	MaxTaskOutputSize      = 1024 * 1024
+	TaskOutputRetention    = time.Hour * 24
+)
+
+type OutputStream string
+
+const (
+	OutputStdout OutputStream = "stdout"
+	OutputStderr OutputStream = "stderr"
+)
+
+// TaskOutputChunk is a piece of the stdout or stderr of one attempt of a
+// task. The last chunk of an attempt has EOF set and no data; it reports
+// the bytes the worker dropped, either because the attempt went over
+// MaxTaskOutputSize or because output came faster than it could be sent.
+type TaskOutputChunk struct {
+	ID         string       `json:"id,omitempty"`
+	WorkflowID string       `json:"workflow_id"`
+	TaskID     string       `json:"task_id"`
+	Attempt    int          `json:"attempt"`
+	Stream     OutputStream `json:"stream,omitempty"`
+	Data       string       `json:"data,omitempty"`
+	Timestamp  time.Time    `json:"timestamp"`
+	EOF        bool         `json:"eof,omitempty"`
+	Dropped    int64        `json:"dropped,omitempty"`
+	Truncated  bool         `json:"truncated,omitempty"`
+}
+
+// StreamsOutput reports whether workers stream the stdout and stderr of
+// tasks run by the executor.
+func (e Executor) StreamsOutput() bool {
+	switch e {
+	case ExecutorShell, ExecutorDocker, ExecutorK8s:
+		return true
+	}
+	return false
+}
+
+// ScriptTaskType is the type of tasks that run an inline script from their
+// payload. Their output is streamed whatever their executor.
+const ScriptTaskType = "script"
+
+// StreamsOutput reports whether workers stream the stdout and stderr of the
+// task.
+func (t *Task) StreamsOutput() bool {
+	return t.Executor.StreamsOutput() || t.Type == ScriptTaskType
+}
+
+// TaskOutput is where a handler writes the stdout and stderr of the
+// attempt it runs.
+type TaskOutput struct {
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+var discardOutput = &TaskOutput{Stdout: io.Discard, Stderr: io.Discard}
+
+type taskOutputKey struct{}
+
+// WithTaskOutput returns a copy of ctx carrying output.
+func WithTaskOutput(ctx context.Context, output *TaskOutput) context.Context {
+	return context.WithValue(ctx, taskOutputKey{}, output)
+}
+
+// TaskOutputFrom returns the output a worker attached to a handler's ctx.
+// Without one, what the handler writes is discarded.
+func TaskOutputFrom(ctx context.Context) *TaskOutput {
+	if output, ok := ctx.Value(taskOutputKey{}).(*TaskOutput); ok {
+		return output
+	}
+	return discardOutput
+}
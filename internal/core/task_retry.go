@@ -0,0 +1,61 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrTaskNotRetryable is returned when retrying a task that has not failed,
+// or whose workflow was cancelled.
+var ErrTaskNotRetryable = errors.New("task cannot be retried")
+
+// RetryTask returns a failed task to pending so the scheduler enqueues it
+// again, optionally with its retry count reset. Any copy of the task left in
+// Redis, such as its dead letter entry, is removed. If the task's failure
+// had finished the workflow, the workflow is reopened; the rest of its tasks
+// are left as they are, so dependents skipped because of the failure stay
+// skipped.
+func (s *Scheduler) RetryTask(ctx context.Context, taskID string, resetRetries bool) (*Task, error) {
+	task, err := s.store.GetTask(taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task: %w", err)
+	}
+	if task.Status != TaskStatusFailed {
+		return nil, fmt.Errorf("%w: task is %s, not failed", ErrTaskNotRetryable, task.Status)
+	}
+
+	workflow, err := s.store.GetWorkflow(task.WorkflowID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get workflow: %w", err)
+	}
+	if workflow.Status == WorkflowStatusCancelled {
+		return nil, fmt.Errorf("%w: workflow %s was cancelled", ErrTaskNotRetryable, workflow.ID)
+	}
+
+	if _, _, err := s.queue.PurgeTasks(ctx, []Task{*task}); err != nil {
+		return nil, fmt.Errorf("failed to remove task from queues: %w", err)
+	}
+
+	reset, err := s.store.ResetTask(taskID, resetRetries)
+	if err != nil {
+		return nil, err
+	}
+	if !reset {
+		return nil, fmt.Errorf("%w: task changed status", ErrTaskNotRetryable)
+	}
+	s.publishEvent(ctx, NewTaskEvent(task.WorkflowID, taskID, TaskStatusPending, ""))
+
+	if workflow.Status == WorkflowStatusFailed {
+		reopened, err := s.store.ReopenWorkflow(workflow.ID)
+		if err != nil {
+			return nil, err
+		}
+		if reopened {
+			s.publishEvent(ctx, NewWorkflowEvent(workflow.ID, WorkflowStatusRunning, ""))
+		}
+	}
+
+	s.logger.Infof("Retrying task %s of workflow %s (reset retries: %t)", taskID, task.WorkflowID, resetRetries)
+	return s.store.GetTask(taskID)
+}
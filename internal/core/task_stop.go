@@ -0,0 +1,85 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrTaskNotStoppable is returned when cancelling a task that has already
+// finished, or skipping one that has started.
+var ErrTaskNotStoppable = errors.New("task cannot be stopped")
+
+// TaskImpact returns what cancelling or skipping the task would do to the
+// rest of its workflow, without changing anything.
+func (s *Scheduler) TaskImpact(taskID string) (*DownstreamImpact, error) {
+	task, err := s.store.GetTask(taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task: %w", err)
+	}
+
+	workflow, err := s.store.GetWorkflow(task.WorkflowID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get workflow: %w", err)
+	}
+	return workflow.DownstreamImpact(taskID)
+}
+
+// CancelTask stops an unfinished task: it is taken off its queue, a worker
+// running it is told to stop, and it is marked cancelled. Its downstream
+// tasks are then skipped as if it had failed, and the returned impact lists
+// them.
+func (s *Scheduler) CancelTask(ctx context.Context, taskID string) (*DownstreamImpact, error) {
+	return s.stopTask(ctx, taskID, TaskStatusCancelled, "cancelled by operator")
+}
+
+// SkipTask marks a task that has not started skipped, so it never runs.
+// Like CancelTask, its downstream tasks are skipped too.
+func (s *Scheduler) SkipTask(ctx context.Context, taskID string) (*DownstreamImpact, error) {
+	return s.stopTask(ctx, taskID, TaskStatusSkipped, "skipped by operator")
+}
+
+func (s *Scheduler) stopTask(ctx context.Context, taskID string, status TaskStatus, errorMsg string) (*DownstreamImpact, error) {
+	task, err := s.store.GetTask(taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task: %w", err)
+	}
+
+	switch {
+	case task.Status.IsTerminal():
+		return nil, fmt.Errorf("%w: task is already %s", ErrTaskNotStoppable, task.Status)
+	case status == TaskStatusSkipped && task.Status == TaskStatusRunning:
+		return nil, fmt.Errorf("%w: task is running, cancel it instead", ErrTaskNotStoppable)
+	}
+
+	workflow, err := s.store.GetWorkflow(task.WorkflowID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get workflow: %w", err)
+	}
+	impact, err := workflow.DownstreamImpact(taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, leased, err := s.queue.PurgeTasks(ctx, []Task{*task})
+	for _, leasedID := range leased {
+		if err := s.queue.PublishCancellation(ctx, leasedID); err != nil {
+			s.logger.Errorf("Failed to cancel running task %s: %v", leasedID, err)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove task from queues: %w", err)
+	}
+
+	if err := s.setTaskStatus(ctx, task.WorkflowID, task.ID, status, nil, errorMsg); err != nil {
+		return nil, err
+	}
+	s.recordTaskStatus(task, status)
+
+	if err := s.settleWorkflow(ctx, task.WorkflowID); err != nil {
+		s.logger.Errorf("Failed to settle workflow %s: %v", task.WorkflowID, err)
+	}
+
+	s.logger.Infof("Task %s of workflow %s %s, %d downstream tasks skipped", taskID, task.WorkflowID, status, len(impact.Skipped))
+	return impact, nil
+}
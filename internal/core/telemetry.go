@@ -0,0 +1,195 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"flowctl/internal/telemetry"
+)
+
+// telemetryTimeout bounds the Redis reads made while a scrape is served.
+const telemetryTimeout = time.Second * 5
+
+type schedulerMetrics struct {
+	registry          *telemetry.Registry
+	tasksEnqueued     *telemetry.CounterVec
+	tasksCompleted    *telemetry.CounterVec
+	tasksFailed       *telemetry.CounterVec
+	tasksExpired      *telemetry.CounterVec
+	taskDuration      *telemetry.HistogramVec
+	schedulingLatency *telemetry.HistogramVec
+	retriesEvicted    *telemetry.CounterVec
+	deadLettersPurged *telemetry.CounterVec
+}
+
+func (s *Scheduler) newSchedulerMetrics() *schedulerMetrics {
+	registry := telemetry.NewRegistry()
+	metrics := &schedulerMetrics{
+		registry:          registry,
+		tasksEnqueued:     registry.Counter("flowctl_tasks_enqueued_total", "Tasks dispatched to their queue.", "type"),
+		tasksCompleted:    registry.Counter("flowctl_tasks_completed_total", "Tasks reported completed.", "type"),
+		tasksFailed:       registry.Counter("flowctl_tasks_failed_total", "Tasks reported failed for good.", "type"),
+		tasksExpired:      registry.Counter("flowctl_tasks_expired_total", "Tasks dropped unrun because their expires_at passed.", "type"),
+		taskDuration:      registry.Histogram("flowctl_task_duration_seconds", "Time from a task starting to it completing or failing.", telemetry.DefaultBuckets, "type"),
+		schedulingLatency: registry.Histogram("flowctl_scheduling_latency_seconds", "Time a task spent pending before it was dispatched.", telemetry.DefaultBuckets, "type"),
+		retriesEvicted:    registry.Counter("flowctl_retries_evicted_total", "Tasks failed by eviction from an overloaded retry set.", "type"),
+		deadLettersPurged: registry.Counter("flowctl_dead_letters_purged_total", "Dead letter entries purged by their type's retention policy.", "type", "reason"),
+	}
+
+	registry.GaugeFunc("flowctl_queue_depth", "Tasks per queue state and task type.", []string{"type", "state"}, s.collectQueueDepths)
+	registry.GaugeFunc("flowctl_queue_oldest_task_age_seconds", "Time the longest-waiting ready task of each type has waited.", []string{"type"}, s.collectOldestTaskAges)
+	registry.GaugeFunc("flowctl_workers", "Live workers handling each task type.", []string{"type"}, s.collectLiveWorkers)
+	registry.GaugeFunc("flowctl_queue_memory_bytes", "Estimated Redis memory held by the queues of each task type.", []string{"type"}, s.collectQueueMemory)
+	registry.GaugeFunc("flowctl_worker_heartbeat_age_seconds", "Time since each registered worker last heartbeated.", []string{"worker"}, s.collectHeartbeatAges)
+
+	return metrics
+}
+
+// SetMetricsLabels adds constant labels, such as the cluster the scheduler
+// runs in, to every metric it serves.
+func (s *Scheduler) SetMetricsLabels(labels map[string]string) error {
+	return s.metrics.registry.SetConstLabels(labels)
+}
+
+// MetricsHandler serves the scheduler's metrics in the Prometheus text
+// format.
+func (s *Scheduler) MetricsHandler() http.Handler {
+	return s.metrics.registry
+}
+
+// recordTaskStatus counts a reported task status change.
+func (s *Scheduler) recordTaskStatus(task *Task, status TaskStatus) {
+	switch status {
+	case TaskStatusCompleted:
+		s.metrics.tasksCompleted.Inc(task.Type)
+	case TaskStatusFailed:
+		s.metrics.tasksFailed.Inc(task.Type)
+	case TaskStatusExpired:
+		s.metrics.tasksExpired.Inc(task.Type)
+		return
+	default:
+		return
+	}
+
+	if task.StartedAt != nil {
+		s.metrics.taskDuration.Observe(time.Since(*task.StartedAt).Seconds(), task.Type)
+	}
+}
+
+func (s *Scheduler) collectQueueDepths() []telemetry.Sample {
+	ctx, cancel := context.WithTimeout(context.Background(), telemetryTimeout)
+	defer cancel()
+
+	taskTypes, err := s.queue.GetTaskTypes(ctx)
+	if err != nil {
+		s.logger.Errorf("Failed to get task types for metrics: %v", err)
+		return nil
+	}
+
+	var samples []telemetry.Sample
+	for _, taskType := range taskTypes {
+		stats, err := s.queue.GetQueueStats(ctx, taskType)
+		if err != nil {
+			s.logger.Errorf("Failed to get queue stats of %s for metrics: %v", taskType, err)
+			continue
+		}
+		for state, depth := range stats {
+			samples = append(samples, telemetry.Sample{LabelValues: []string{taskType, state}, Value: float64(depth)})
+		}
+	}
+	return samples
+}
+
+func (s *Scheduler) collectOldestTaskAges() []telemetry.Sample {
+	ctx, cancel := context.WithTimeout(context.Background(), telemetryTimeout)
+	defer cancel()
+
+	taskTypes, err := s.queue.GetTaskTypes(ctx)
+	if err != nil {
+		s.logger.Errorf("Failed to get task types for metrics: %v", err)
+		return nil
+	}
+
+	now := time.Now()
+	samples := make([]telemetry.Sample, 0, len(taskTypes))
+	for _, taskType := range taskTypes {
+		oldest, err := s.queue.GetOldestEnqueuedAt(ctx, taskType)
+		if err != nil {
+			s.logger.Errorf("Failed to find oldest task of %s for metrics: %v", taskType, err)
+			continue
+		}
+		age := 0.0
+		if oldest != nil && oldest.Before(now) {
+			age = now.Sub(*oldest).Seconds()
+		}
+		samples = append(samples, telemetry.Sample{LabelValues: []string{taskType}, Value: age})
+	}
+	return samples
+}
+
+func (s *Scheduler) collectLiveWorkers() []telemetry.Sample {
+	ctx, cancel := context.WithTimeout(context.Background(), telemetryTimeout)
+	defer cancel()
+
+	workers, err := s.queue.ListWorkers(ctx)
+	if err != nil {
+		s.logger.Errorf("Failed to list workers for metrics: %v", err)
+		return nil
+	}
+
+	live := make(map[string]int)
+	for _, worker := range workers {
+		if worker.Status == WorkerStatusStale {
+			continue
+		}
+		for _, taskType := range worker.TaskTypes {
+			live[taskType]++
+		}
+	}
+
+	samples := make([]telemetry.Sample, 0, len(live))
+	for taskType, count := range live {
+		samples = append(samples, telemetry.Sample{LabelValues: []string{taskType}, Value: float64(count)})
+	}
+	return samples
+}
+
+func (s *Scheduler) collectQueueMemory() []telemetry.Sample {
+	ctx, cancel := context.WithTimeout(context.Background(), telemetryTimeout)
+	defer cancel()
+
+	taskTypes, err := s.queue.GetTaskTypes(ctx)
+	if err != nil {
+		s.logger.Errorf("Failed to get task types for metrics: %v", err)
+		return nil
+	}
+
+	var samples []telemetry.Sample
+	for _, taskType := range taskTypes {
+		usage, err := s.queue.GetQueueMemoryUsage(ctx, taskType)
+		if err != nil {
+			s.logger.Errorf("Failed to measure queues of %s for metrics: %v", taskType, err)
+			continue
+		}
+		samples = append(samples, telemetry.Sample{LabelValues: []string{taskType}, Value: float64(usage.Bytes)})
+	}
+	return samples
+}
+
+func (s *Scheduler) collectHeartbeatAges() []telemetry.Sample {
+	ctx, cancel := context.WithTimeout(context.Background(), telemetryTimeout)
+	defer cancel()
+
+	workers, err := s.queue.ListWorkers(ctx)
+	if err != nil {
+		s.logger.Errorf("Failed to list workers for metrics: %v", err)
+		return nil
+	}
+
+	samples := make([]telemetry.Sample, 0, len(workers))
+	for _, worker := range workers {
+		samples = append(samples, telemetry.Sample{LabelValues: []string{worker.ID}, Value: time.Since(worker.LastHeartbeat).Seconds()})
+	}
+	return samples
+}
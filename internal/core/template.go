@@ -0,0 +1,373 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+var ErrInvalidTemplate = errors.New("invalid payload template")
+
+// TemplateContext is the data payload templates are rendered against:
+// {{ .params.name }}, {{ .workflow.id }}, {{ .workflow.name }} and
+// {{ .task.name }}.
+type TemplateContext map[string]interface{}
+
+func NewTemplateContext(params map[string]interface{}, workflow *Workflow, taskName string) TemplateContext {
+	if params == nil {
+		params = map[string]interface{}{}
+	}
+
+	return TemplateContext{
+		"params": params,
+		"workflow": map[string]interface{}{
+			"id":        workflow.ID,
+			"name":      workflow.Name,
+			"namespace": workflow.Namespace,
+		},
+		"task": map[string]interface{}{
+			"name": taskName,
+		},
+	}
+}
+
+// RenderPayload renders every string in a payload that contains a template
+// action. Rendering is strict: referencing a variable that is not in the
+// context or calling an unknown function is an error. Values that hold no
+// template are returned unchanged.
+func RenderPayload(payload map[string]interface{}, data TemplateContext) (map[string]interface{}, error) {
+	if payload == nil {
+		return nil, nil
+	}
+
+	rendered, err := renderValue(payload, data, "")
+	if err != nil {
+		return nil, err
+	}
+	return rendered.(map[string]interface{}), nil
+}
+
+// CheckPayload parses every template in a payload without rendering it,
+// catching syntax errors and unknown functions before the params it
+// references are known.
+func CheckPayload(payload map[string]interface{}) error {
+	return checkValue(payload, "")
+}
+
+func checkValue(value interface{}, path string) error {
+	switch v := value.(type) {
+	case string:
+		if !strings.Contains(v, "{{") {
+			return nil
+		}
+		if _, err := parseTemplate(v, path); err != nil {
+			return err
+		}
+	case map[string]interface{}:
+		for key, item := range v {
+			if err := checkValue(item, joinPath(path, key)); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			if err := checkValue(item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func renderValue(value interface{}, data TemplateContext, path string) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		if !strings.Contains(v, "{{") {
+			return v, nil
+		}
+		return renderString(v, data, path)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			rendered, err := renderValue(item, data, joinPath(path, key))
+			if err != nil {
+				return nil, err
+			}
+			out[key] = rendered
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			rendered, err := renderValue(item, data, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			out[i] = rendered
+		}
+		return out, nil
+	default:
+		return value, nil
+	}
+}
+
+func renderString(text string, data TemplateContext, path string) (string, error) {
+	tmpl, err := parseTemplate(text, path)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, map[string]interface{}(data)); err != nil {
+		return "", fmt.Errorf("%w: %s: %v", ErrInvalidTemplate, path, err)
+	}
+	return buf.String(), nil
+}
+
+func parseTemplate(text, path string) (*template.Template, error) {
+	tmpl, err := template.New(path).Option("missingkey=error").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidTemplate, path, err)
+	}
+	return tmpl, nil
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+var templateFuncs = template.FuncMap{
+	// Dates
+	"now":       time.Now,
+	"utcNow":    func() time.Time { return time.Now().UTC() },
+	"date":      formatDate,
+	"dateAdd":   dateAdd,
+	"toDate":    time.Parse,
+	"unixEpoch": func(t time.Time) int64 { return t.Unix() },
+	"dateTrunc": truncateDate,
+
+	// Math
+	"add": func(a, b interface{}) (float64, error) {
+		return arith(a, b, func(x, y float64) float64 { return x + y })
+	},
+	"sub": func(a, b interface{}) (float64, error) {
+		return arith(a, b, func(x, y float64) float64 { return x - y })
+	},
+	"mul": func(a, b interface{}) (float64, error) {
+		return arith(a, b, func(x, y float64) float64 { return x * y })
+	},
+	"div": divide,
+	"mod": modulo,
+	"max": func(a, b interface{}) (float64, error) { return arith(a, b, math.Max) },
+	"min": func(a, b interface{}) (float64, error) { return arith(a, b, math.Min) },
+	"floor": func(a interface{}) (float64, error) {
+		return arith(a, 0, func(x, _ float64) float64 { return math.Floor(x) })
+	},
+	"ceil": func(a interface{}) (float64, error) {
+		return arith(a, 0, func(x, _ float64) float64 { return math.Ceil(x) })
+	},
+	"int": func(a interface{}) (int64, error) { f, err := toFloat(a); return int64(f), err },
+
+	// Strings
+	"upper":      strings.ToUpper,
+	"lower":      strings.ToLower,
+	"title":      strings.Title,
+	"trim":       strings.TrimSpace,
+	"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+	"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+	"replace":    func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+	"contains":   func(substr, s string) bool { return strings.Contains(s, substr) },
+	"hasPrefix":  func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+	"hasSuffix":  func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+	"split":      func(sep, s string) []string { return strings.Split(s, sep) },
+	"join":       joinValues,
+	"quote":      strconv.Quote,
+	"repeat":     func(count int, s string) string { return strings.Repeat(s, count) },
+	"default":    defaultValue,
+
+	// JSON
+	"toJson":   toJSON,
+	"fromJson": fromJSON,
+	"jsonPath": jsonPath,
+}
+
+func formatDate(layout string, t time.Time) string {
+	return t.Format(layout)
+}
+
+func dateAdd(duration string, t time.Time) (time.Time, error) {
+	d, err := time.ParseDuration(duration)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.Add(d), nil
+}
+
+func truncateDate(duration string, t time.Time) (time.Time, error) {
+	d, err := time.ParseDuration(duration)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.Truncate(d), nil
+}
+
+func toFloat(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case string:
+		return strconv.ParseFloat(v, 64)
+	case json.Number:
+		return v.Float64()
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	}
+	return 0, fmt.Errorf("cannot use %v (%T) as a number", value, value)
+}
+
+func arith(a, b interface{}, op func(x, y float64) float64) (float64, error) {
+	x, err := toFloat(a)
+	if err != nil {
+		return 0, err
+	}
+	y, err := toFloat(b)
+	if err != nil {
+		return 0, err
+	}
+	return op(x, y), nil
+}
+
+func divide(a, b interface{}) (float64, error) {
+	y, err := toFloat(b)
+	if err != nil {
+		return 0, err
+	}
+	if y == 0 {
+		return 0, errors.New("division by zero")
+	}
+	return arith(a, y, func(x, y float64) float64 { return x / y })
+}
+
+func modulo(a, b interface{}) (float64, error) {
+	y, err := toFloat(b)
+	if err != nil {
+		return 0, err
+	}
+	if y == 0 {
+		return 0, errors.New("division by zero")
+	}
+	return arith(a, y, math.Mod)
+}
+
+func joinValues(sep string, values interface{}) (string, error) {
+	rv := reflect.ValueOf(values)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return "", fmt.Errorf("cannot join %T", values)
+	}
+
+	parts := make([]string, rv.Len())
+	for i := range parts {
+		parts[i] = fmt.Sprint(rv.Index(i).Interface())
+	}
+	return strings.Join(parts, sep), nil
+}
+
+// defaultValue returns fallback when value is empty. Strict rendering still
+// rejects variables that do not exist at all.
+func defaultValue(fallback, value interface{}) interface{} {
+	if value == nil {
+		return fallback
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map:
+		if rv.Len() == 0 {
+			return fallback
+		}
+	}
+	return value
+}
+
+func toJSON(value interface{}) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func fromJSON(text string) (interface{}, error) {
+	var value interface{}
+	if err := json.Unmarshal([]byte(text), &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// jsonPath looks up a dotted path such as "items[0].name" in a decoded JSON
+// value or in a JSON document given as a string.
+func jsonPath(path string, value interface{}) (interface{}, error) {
+	if text, ok := value.(string); ok {
+		decoded, err := fromJSON(text)
+		if err != nil {
+			return nil, err
+		}
+		value = decoded
+	}
+
+	current := value
+	for _, segment := range strings.Split(path, ".") {
+		if segment == "" {
+			continue
+		}
+
+		name := segment
+		var indexes []int
+		if open := strings.Index(segment, "["); open >= 0 {
+			name = segment[:open]
+			for _, part := range strings.Split(segment[open:], "[")[1:] {
+				index, err := strconv.Atoi(strings.TrimSuffix(part, "]"))
+				if err != nil || !strings.HasSuffix(part, "]") {
+					return nil, fmt.Errorf("invalid path segment %q", segment)
+				}
+				indexes = append(indexes, index)
+			}
+		}
+
+		if name != "" {
+			object, ok := current.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("path %s: %q is not an object", path, name)
+			}
+			if current, ok = object[name]; !ok {
+				return nil, fmt.Errorf("path %s: no field %q", path, name)
+			}
+		}
+
+		for _, index := range indexes {
+			array, ok := current.([]interface{})
+			if !ok || index < 0 || index >= len(array) {
+				return nil, fmt.Errorf("path %s: index %d out of range", path, index)
+			}
+			current = array[index]
+		}
+	}
+
+	return current, nil
+}
@@ -0,0 +1,187 @@
+package core
+
+import (
+	"strconv"
+	"time"
+)
+
+// approvalWindow is how long a run of the CI template may wait for its
+// approval before the workflow times out.
+const approvalWindow = time.Hour * 24
+
+// BuiltinWorkflowTemplates returns the example templates a new installation
+// starts with: an ETL chain, a fan-out/fan-in over shards and a CI pipeline
+// that waits for approval before deploying. Each declares the params a run
+// must or may set. They only use the task types the bundled worker runs,
+// and ApprovalTaskType, which the scheduler runs itself. Params are passed
+// to commands as arguments, never spliced into the command line.
+func BuiltinWorkflowTemplates() []*WorkflowTemplate {
+	return []*WorkflowTemplate{
+		etlChainTemplate(),
+		fanOutFanInTemplate(),
+		ciWithApprovalTemplate(),
+	}
+}
+
+func etlChainTemplate() *WorkflowTemplate {
+	return NewWorkflowTemplate("etl-chain", "Extract, transform and load one dataset, staging each step's output", WorkflowDefinition{
+		Name:        "ETL chain",
+		Description: "Extract, transform and load",
+		Parameters: map[string]ParamSpec{
+			"source_url":  {Type: ParamTypeString, Description: "Dataset to extract", Required: true},
+			"target_url":  {Type: ParamTypeString, Description: "Where the transformed dataset is loaded", Required: true},
+			"staging_url": {Type: ParamTypeString, Description: "Where intermediate outputs are kept, per run", Default: "s3://flowctl-staging"},
+		},
+		Tasks: []TaskDefinition{
+			{
+				Name: "extract",
+				Type: "etl",
+				Payload: map[string]interface{}{
+					"source_url": "{{ .params.source_url }}",
+					"target_url": "{{ .params.staging_url }}/{{ .workflow.id }}/raw",
+				},
+			},
+			{
+				Name:         "transform",
+				Type:         "etl",
+				Dependencies: []string{"extract"},
+				Payload: map[string]interface{}{
+					"source_url": "{{ .params.staging_url }}/{{ .workflow.id }}/raw",
+					"target_url": "{{ .params.staging_url }}/{{ .workflow.id }}/clean",
+				},
+			},
+			{
+				Name:         "load",
+				Type:         "etl",
+				Dependencies: []string{"transform"},
+				Payload: map[string]interface{}{
+					"source_url": "{{ .params.staging_url }}/{{ .workflow.id }}/clean",
+					"target_url": "{{ .params.target_url }}",
+				},
+			},
+		},
+	})
+}
+
+func fanOutFanInTemplate() *WorkflowTemplate {
+	const shards = 3
+
+	definition := WorkflowDefinition{
+		Name:        "Fan-out/fan-in",
+		Description: "Split a dataset into shards, process them in parallel and merge the results",
+		Parameters: map[string]ParamSpec{
+			"source_url":  {Type: ParamTypeString, Description: "Dataset to split", Required: true},
+			"target_url":  {Type: ParamTypeString, Description: "Where the merged result is written", Required: true},
+			"staging_url": {Type: ParamTypeString, Description: "Where shards and their outputs are kept, per run", Default: "s3://flowctl-staging"},
+		},
+		Tasks: []TaskDefinition{{
+			Name: "split",
+			Type: "etl",
+			Payload: map[string]interface{}{
+				"source_url": "{{ .params.source_url }}",
+				"target_url": "{{ .params.staging_url }}/{{ .workflow.id }}/shards",
+			},
+		}},
+	}
+
+	merge := TaskDefinition{
+		Name: "merge",
+		Type: "etl",
+		Payload: map[string]interface{}{
+			"source_url": "{{ .params.staging_url }}/{{ .workflow.id }}/processed",
+			"target_url": "{{ .params.target_url }}",
+		},
+	}
+
+	for i := 0; i < shards; i++ {
+		name := "process_shard_" + strconv.Itoa(i)
+		definition.Tasks = append(definition.Tasks, TaskDefinition{
+			Name:         name,
+			Type:         "etl",
+			Dependencies: []string{"split"},
+			Payload: map[string]interface{}{
+				"source_url": "{{ .params.staging_url }}/{{ .workflow.id }}/shards/" + strconv.Itoa(i),
+				"target_url": "{{ .params.staging_url }}/{{ .workflow.id }}/processed/" + strconv.Itoa(i),
+			},
+		})
+		merge.Dependencies = append(merge.Dependencies, name)
+	}
+	definition.Tasks = append(definition.Tasks, merge)
+
+	return NewWorkflowTemplate("fan-out-fan-in", "Process a dataset's shards in parallel, then merge them", definition)
+}
+
+func ciWithApprovalTemplate() *WorkflowTemplate {
+	return NewWorkflowTemplate("ci-with-approval", "Test and build a repository, deploy it to staging and, once approved, to production", WorkflowDefinition{
+		Name:        "CI pipeline with approval",
+		Description: "Test, build, deploy to staging, approve, deploy",
+		Config: &WorkflowConfig{
+			MaxConcurrency: 10,
+			Timeout:        approvalWindow,
+			RetryPolicy:    DefaultRetryPolicy,
+		},
+		Parameters: map[string]ParamSpec{
+			"repo_url": {Type: ParamTypeString, Description: "Repository to build", Required: true},
+			"branch":   {Type: ParamTypeString, Description: "Branch to build", Default: "main"},
+			"image":    {Type: ParamTypeString, Description: "Image to build and deploy", Default: "app:latest"},
+		},
+		Tasks: []TaskDefinition{
+			{
+				Name: "checkout",
+				Type: "ci",
+				Payload: map[string]interface{}{
+					"repo_url": "{{ .params.repo_url }}",
+					"command":  `git clone --branch "$1" -- "$2"`,
+					"args":     []interface{}{"{{ .params.branch }}", "{{ .params.repo_url }}"},
+				},
+			},
+			{
+				Name:         "test",
+				Type:         "ci",
+				Dependencies: []string{"checkout"},
+				Payload: map[string]interface{}{
+					"repo_url": "{{ .params.repo_url }}",
+					"command":  "make test",
+				},
+			},
+			{
+				Name:         "build_image",
+				Type:         "ci",
+				Dependencies: []string{"test"},
+				Payload: map[string]interface{}{
+					"repo_url": "{{ .params.repo_url }}",
+					"command":  `docker build -t "$1" .`,
+					"args":     []interface{}{"{{ .params.image }}"},
+				},
+			},
+			{
+				Name:         "deploy_staging",
+				Type:         "generic",
+				Dependencies: []string{"build_image"},
+				Payload: map[string]interface{}{
+					"command":     `deploy -- "$1"`,
+					"args":        []interface{}{"{{ .params.image }}"},
+					"environment": "staging",
+				},
+			},
+			{
+				Name:         "approve_release",
+				Type:         ApprovalTaskType,
+				Dependencies: []string{"deploy_staging"},
+				Payload: map[string]interface{}{
+					"message": "Approve deploying {{ .params.image }} from {{ .params.branch }} to production",
+				},
+			},
+			{
+				Name:         "deploy_production",
+				Type:         "generic",
+				Dependencies: []string{"approve_release"},
+				Payload: map[string]interface{}{
+					"command":     `deploy -- "$1"`,
+					"args":        []interface{}{"{{ .params.image }}"},
+					"environment": "production",
+				},
+			},
+		},
+	})
+}
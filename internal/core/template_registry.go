@@ -0,0 +1,50 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+func (s *Scheduler) CreateWorkflowTemplate(ctx context.Context, tmpl *WorkflowTemplate) error {
+	return s.store.CreateWorkflowTemplate(tmpl)
+}
+
+// PublishWorkflowTemplate saves tmpl as the next version of an existing
+// template.
+func (s *Scheduler) PublishWorkflowTemplate(ctx context.Context, tmpl *WorkflowTemplate) error {
+	return s.store.AddWorkflowTemplateVersion(tmpl)
+}
+
+func (s *Scheduler) GetWorkflowTemplate(name string, version int) (*WorkflowTemplate, error) {
+	return s.store.GetWorkflowTemplate(name, version)
+}
+
+func (s *Scheduler) ListWorkflowTemplates() ([]WorkflowTemplate, error) {
+	return s.store.ListWorkflowTemplates()
+}
+
+func (s *Scheduler) ListWorkflowTemplateVersions(name string) ([]WorkflowTemplate, error) {
+	return s.store.ListWorkflowTemplateVersions(name)
+}
+
+func (s *Scheduler) DeleteWorkflowTemplate(ctx context.Context, name string) error {
+	return s.store.DeleteWorkflowTemplate(name)
+}
+
+// RegisterBuiltinTemplates creates each of BuiltinWorkflowTemplates that
+// does not exist yet. A template already saved under a built-in name,
+// including a later version of the built-in one, is left alone.
+func (s *Scheduler) RegisterBuiltinTemplates(ctx context.Context) error {
+	for _, tmpl := range BuiltinWorkflowTemplates() {
+		err := s.CreateWorkflowTemplate(ctx, tmpl)
+		if errors.Is(err, ErrTemplateExists) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to register template %s: %w", tmpl.Name, err)
+		}
+		s.logger.Infof("Registered built-in workflow template %s", tmpl.Name)
+	}
+	return nil
+}
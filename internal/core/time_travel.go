@@ -0,0 +1,26 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// historySkew widens the event log range read for a snapshot, since the
+// log is indexed by Redis's clock and events are stamped by the scheduler's.
+const historySkew = time.Minute
+
+// WorkflowAsOf rebuilds a workflow's task statuses at a past moment from
+// the event log, for reviewing what the scheduler could see at the time.
+func (s *Scheduler) WorkflowAsOf(ctx context.Context, workflow *Workflow, asOf time.Time) (*WorkflowSnapshot, error) {
+	if asOf.Before(workflow.CreatedAt) {
+		return nil, ErrWorkflowNotCreated
+	}
+
+	events, err := s.queue.ReadWorkflowEvents(ctx, workflow.ID, workflow.CreatedAt.Add(-historySkew), asOf.Add(historySkew))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workflow history: %w", err)
+	}
+
+	return ReplayWorkflow(workflow, events, asOf)
+}
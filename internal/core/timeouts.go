@@ -0,0 +1,145 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+func (s *Scheduler) enforceWorkflowTimeouts(ctx context.Context) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(time.Second * 30)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			passCtx, leading := s.leaderContext()
+			if !leading {
+				continue
+			}
+			if err := s.checkWorkflowTimeouts(passCtx); err != nil {
+				s.logger.Errorf("Failed to check workflow timeouts: %v", err)
+			}
+		}
+	}
+}
+
+func (s *Scheduler) checkWorkflowTimeouts(ctx context.Context) error {
+	workflows, err := s.store.GetRunningWorkflows()
+	if err != nil {
+		return fmt.Errorf("failed to get running workflows: %w", err)
+	}
+
+	for i := range workflows {
+		workflow := &workflows[i]
+		if workflow.Config.Timeout <= 0 || workflow.StartedAt == nil {
+			continue
+		}
+		if s.clock().Sub(*workflow.StartedAt) < workflow.Config.Timeout {
+			continue
+		}
+
+		if err := s.timeoutWorkflow(ctx, workflow); err != nil {
+			s.logger.Errorf("Failed to time out workflow %s: %v", workflow.ID, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Scheduler) timeoutWorkflow(ctx context.Context, workflow *Workflow) error {
+	tasks, err := s.store.GetTasksByWorkflow(workflow.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get tasks: %w", err)
+	}
+
+	// Retries and delayed tasks are taken off their sets too, so none of
+	// them is queued again after the workflow has failed.
+	var unfinished []Task
+	for _, task := range tasks {
+		if !task.Status.IsTerminal() {
+			unfinished = append(unfinished, task)
+		}
+	}
+	if _, _, err := s.queue.PurgeTasks(ctx, unfinished); err != nil {
+		s.logger.Errorf("Failed to purge tasks of workflow %s: %v", workflow.ID, err)
+	}
+	s.cancelUnfinishedTasks(ctx, unfinished)
+
+	errorMsg := fmt.Sprintf("workflow timed out after %s", workflow.Config.Timeout)
+	if err := s.failWorkflow(ctx, workflow.ID, errorMsg); err != nil {
+		return err
+	}
+
+	s.logger.Warnf("Workflow %s timed out after %s", workflow.ID, workflow.Config.Timeout)
+	return nil
+}
+
+// cancelUnfinishedTasks marks every task that has not finished cancelled,
+// signalling the ones a worker may be running to stop.
+func (s *Scheduler) cancelUnfinishedTasks(ctx context.Context, tasks []Task) {
+	for _, task := range tasks {
+		switch task.Status {
+		case TaskStatusQueued, TaskStatusRunning:
+			if err := s.queue.PublishCancellation(ctx, task.ID); err != nil {
+				s.logger.Errorf("Failed to signal task %s: %v", task.ID, err)
+			}
+		case TaskStatusPending, TaskStatusRetrying:
+		default:
+			continue
+		}
+
+		if err := s.setTaskStatus(ctx, task.WorkflowID, task.ID, TaskStatusCancelled, nil, ""); err != nil {
+			s.logger.Errorf("Failed to cancel task %s: %v", task.ID, err)
+		}
+	}
+}
+
+// checkRetryBudget fails a workflow fast once its tasks have between them
+// been retried more often than its RetryBudget allows. Its unfinished
+// tasks are cancelled and taken off their queues and retry sets, so none
+// of the scheduled retries runs.
+func (s *Scheduler) checkRetryBudget(ctx context.Context, workflowID string) {
+	budget, used, err := s.store.RetryBudgetUsage(workflowID)
+	if err != nil {
+		s.logger.Errorf("Failed to check retry budget of workflow %s: %v", workflowID, err)
+		return
+	}
+	if budget <= 0 || used <= budget {
+		return
+	}
+
+	workflow, err := s.store.GetWorkflow(workflowID)
+	if err != nil {
+		s.logger.Errorf("Failed to get workflow %s: %v", workflowID, err)
+		return
+	}
+	if workflow.Status != WorkflowStatusPending && workflow.Status != WorkflowStatusRunning {
+		return
+	}
+
+	var unfinished []Task
+	for _, task := range workflow.Tasks {
+		if !task.Status.IsTerminal() {
+			unfinished = append(unfinished, task)
+		}
+	}
+	if _, _, err := s.queue.PurgeTasks(ctx, unfinished); err != nil {
+		s.logger.Errorf("Failed to purge tasks of workflow %s: %v", workflowID, err)
+	}
+	s.cancelUnfinishedTasks(ctx, unfinished)
+
+	errorMsg := fmt.Sprintf("retry budget of %d exhausted", budget)
+	if err := s.failWorkflow(ctx, workflowID, errorMsg); err != nil {
+		s.logger.Errorf("Failed to fail workflow %s: %v", workflowID, err)
+		return
+	}
+
+	s.logger.Warnf("Workflow %s failed after its tasks were retried %d times, over its budget of %d", workflowID, used, budget)
+}
@@ -2,6 +2,9 @@ package core
 
 import (
 	"encoding/json"
+	"errors"
+	"math"
+	"math/rand"
 	"time"
 
 	"github.com/google/uuid"
@@ -11,59 +14,188 @@ type TaskStatus string
 
 const (
 	TaskStatusPending   TaskStatus = "pending"
+	TaskStatusQueued    TaskStatus = "queued"
 	TaskStatusRunning   TaskStatus = "running"
 	TaskStatusCompleted TaskStatus = "completed"
 	TaskStatusFailed    TaskStatus = "failed"
 	TaskStatusRetrying  TaskStatus = "retrying"
 	TaskStatusCancelled TaskStatus = "cancelled"
+	TaskStatusSkipped   TaskStatus = "skipped"
+	// TaskStatusExpired marks a task that was still waiting to run when its
+	// ExpiresAt passed, and was dropped instead.
+	TaskStatusExpired TaskStatus = "expired"
 )
 
+// IsTerminal reports whether a task in this status will never run again.
+func (s TaskStatus) IsTerminal() bool {
+	switch s {
+	case TaskStatusCompleted, TaskStatusFailed, TaskStatusCancelled, TaskStatusSkipped, TaskStatusExpired:
+		return true
+	}
+	return false
+}
+
 type WorkflowStatus string
 
 const (
-	WorkflowStatusPending   WorkflowStatus = "pending"
-	WorkflowStatusRunning   WorkflowStatus = "running"
-	WorkflowStatusCompleted WorkflowStatus = "completed"
-	WorkflowStatusFailed    WorkflowStatus = "failed"
-	WorkflowStatusCancelled WorkflowStatus = "cancelled"
+	WorkflowStatusPending    WorkflowStatus = "pending"
+	WorkflowStatusRunning    WorkflowStatus = "running"
+	WorkflowStatusCompleted  WorkflowStatus = "completed"
+	WorkflowStatusFailed     WorkflowStatus = "failed"
+	WorkflowStatusCancelled  WorkflowStatus = "cancelled"
+	WorkflowStatusSubmitting WorkflowStatus = "submitting"
 )
 
+// IsTerminal reports whether a workflow in this status has finished.
+func (s WorkflowStatus) IsTerminal() bool {
+	switch s {
+	case WorkflowStatusCompleted, WorkflowStatusFailed, WorkflowStatusCancelled:
+		return true
+	}
+	return false
+}
+
+// ErrLeaseExpired is returned when acking, nacking or touching a task whose
+// lease has already ended, usually because the reaper requeued it.
+var ErrLeaseExpired = errors.New("task lease has expired")
+
+// ErrTaskNotFound is returned for a status report on a task that does not
+// exist, such as one whose workflow has been deleted.
+var ErrTaskNotFound = errors.New("task not found")
+
+// ErrStaleTaskStatus is returned for a status report on an attempt of a
+// task that a later attempt has since started.
+var ErrStaleTaskStatus = errors.New("status report of an earlier attempt")
+
+// ErrTaskFinished is returned for a status report on a task that is
+// already completed, failed, cancelled, skipped or expired. A worker can
+// drop the report: the task's outcome has been settled without it.
+var ErrTaskFinished = errors.New("task has already finished")
+
+// ErrTaskQuarantined is returned by dequeue for a task it moved to the
+// poison queue instead of delivering it.
+var ErrTaskQuarantined = errors.New("task quarantined")
+
 type Task struct {
-	ID          string                 `json:"id" db:"id"`
-	WorkflowID  string                 `json:"workflow_id" db:"workflow_id"`
-	Name        string                 `json:"name" db:"name"`
-	Type        string                 `json:"type" db:"type"`
-	Payload     map[string]interface{} `json:"payload" db:"payload"`
-	Status      TaskStatus             `json:"status" db:"status"`
-	Result      map[string]interface{} `json:"result,omitempty" db:"result"`
+	ID         string                 `json:"id" db:"id"`
+	WorkflowID string                 `json:"workflow_id" db:"workflow_id"`
+	Name       string                 `json:"name" db:"name"`
+	Type       string                 `json:"type" db:"type"`
+	Payload    map[string]interface{} `json:"payload" db:"payload"`
+	Status     TaskStatus             `json:"status" db:"status"`
+	Result     map[string]interface{} `json:"result,omitempty" db:"result"`
+	Error      string                 `json:"error,omitempty" db:"error"`
+	RetryCount int                    `json:"retry_count" db:"retry_count"`
+	MaxRetries int                    `json:"max_retries" db:"max_retries"`
+	Priority   int                    `json:"priority" db:"priority"`
+	// Order is the task's position in its workflow's dependency order,
+	// assigned on submission.
+	Order int    `json:"order" db:"topo_order"`
+	Pool  string `json:"pool,omitempty" db:"pool"`
+	// Group names the set of tasks, such as the shards of a fan-out, that
+	// the workflow's GroupConcurrency limit applies to.
+	Group string `json:"group,omitempty" db:"task_group"`
+	// Owner, DocsURL and RunbookURL default to the workflow's and are
+	// included in failure notifications.
+	Owner        string        `json:"owner,omitempty" db:"owner"`
+	DocsURL      string        `json:"docs_url,omitempty" db:"docs_url"`
+	RunbookURL   string        `json:"runbook_url,omitempty" db:"runbook_url"`
+	Dependencies []string      `json:"dependencies" db:"dependencies"`
+	Timeout      time.Duration `json:"timeout,omitempty" db:"timeout"`
+	// RunAt is the earliest time the task may run. Until then it waits,
+	// queued, in its type's delayed set.
+	RunAt *time.Time `json:"run_at,omitempty" db:"run_at"`
+	// ExpiresAt is the latest time the task may start. A task still
+	// waiting then is dropped from its queue and marked expired.
+	ExpiresAt            *time.Time       `json:"expires_at,omitempty" db:"expires_at"`
+	RunOnUpstreamFailure bool             `json:"run_on_upstream_failure,omitempty" db:"run_on_upstream_failure"`
+	Executor             Executor         `json:"executor,omitempty" db:"executor"`
+	Resources            *ResourceRequest `json:"resources,omitempty" db:"resources"`
+	// Selector restricts the task to workers whose labels include each of
+	// its labels, such as gpu=true.
+	Selector       map[string]string `json:"selector,omitempty" db:"selector"`
+	IdempotencyKey string            `json:"idempotency_key,omitempty" db:"idempotency_key"`
+	// Dedupe holds the task back while an identical task is queued or
+	// running. DuplicateOf then names that task, whose result the task
+	// completes with.
+	Dedupe         *DedupeConfig `json:"dedupe,omitempty" db:"dedupe"`
+	DuplicateOf    string        `json:"duplicate_of,omitempty" db:"duplicate_of"`
+	CreatedAt      time.Time     `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at" db:"updated_at"`
+	StartedAt      *time.Time    `json:"started_at,omitempty" db:"started_at"`
+	CompletedAt    *time.Time    `json:"completed_at,omitempty" db:"completed_at"`
+	EnqueuedAt     *time.Time    `json:"enqueued_at,omitempty"`
+	DeadLetteredAt *time.Time    `json:"dead_lettered_at,omitempty"`
+	RetryPolicy    *RetryPolicy  `json:"retry_policy,omitempty"`
+	// Execution describes the task's workflow run to its handler. Like
+	// RetryPolicy it is filled in on dispatch and only travels through the
+	// queue.
+	Execution *ExecutionContext `json:"execution,omitempty"`
+	// QueueNamespace is set when the task's namespace has dedicated
+	// workers, and names the namespace whose queues it waits in. It only
+	// travels through the queue.
+	QueueNamespace string         `json:"queue_namespace,omitempty"`
+	RetryDelay     time.Duration  `json:"retry_delay,omitempty"`
+	Usage          *ResourceUsage `json:"usage,omitempty" db:"usage"`
+	// Attempts records where each attempt of the task ran, oldest first.
+	Attempts []ExecutionEnvironment `json:"attempts,omitempty" db:"attempts"`
+	// ReceiptHandle identifies the lease under which a worker holds a
+	// dequeued task. It is never serialized.
+	ReceiptHandle string `json:"-"`
+}
+
+type Workflow struct {
+	ID          string `json:"id" db:"id"`
+	Name        string `json:"name" db:"name"`
+	Description string `json:"description" db:"description"`
+	Namespace   string `json:"namespace" db:"namespace"`
+	Priority    int    `json:"priority" db:"priority"`
+	// Pool pins every task of the workflow to the workers registered with
+	// that pool. Empty means any worker of the task's type.
+	Pool       string `json:"pool,omitempty" db:"pool"`
+	Owner      string `json:"owner,omitempty" db:"owner"`
+	DocsURL    string `json:"docs_url,omitempty" db:"docs_url"`
+	RunbookURL string `json:"runbook_url,omitempty" db:"runbook_url"`
+	// Params are the run's resolved params and Labels free-form tags; both
+	// reach every task handler through its ExecutionContext.
+	Params      map[string]interface{} `json:"params,omitempty" db:"params"`
+	Labels      map[string]string      `json:"labels,omitempty" db:"labels"`
+	Status      WorkflowStatus         `json:"status" db:"status"`
+	Tasks       []Task                 `json:"tasks"`
+	Config      WorkflowConfig         `json:"config" db:"config"`
 	Error       string                 `json:"error,omitempty" db:"error"`
-	RetryCount  int                    `json:"retry_count" db:"retry_count"`
-	MaxRetries  int                    `json:"max_retries" db:"max_retries"`
-	Priority    int                    `json:"priority" db:"priority"`
-	Dependencies []string              `json:"dependencies" db:"dependencies"`
 	CreatedAt   time.Time              `json:"created_at" db:"created_at"`
 	UpdatedAt   time.Time              `json:"updated_at" db:"updated_at"`
 	StartedAt   *time.Time             `json:"started_at,omitempty" db:"started_at"`
 	CompletedAt *time.Time             `json:"completed_at,omitempty" db:"completed_at"`
 }
 
-type Workflow struct {
-	ID          string         `json:"id" db:"id"`
-	Name        string         `json:"name" db:"name"`
-	Description string         `json:"description" db:"description"`
-	Status      WorkflowStatus `json:"status" db:"status"`
-	Tasks       []Task         `json:"tasks"`
-	Config      WorkflowConfig `json:"config" db:"config"`
-	CreatedAt   time.Time      `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at" db:"updated_at"`
-	StartedAt   *time.Time     `json:"started_at,omitempty" db:"started_at"`
-	CompletedAt *time.Time     `json:"completed_at,omitempty" db:"completed_at"`
+// WorkflowFilter selects a page of workflows. Zero values match everything;
+// Name matches workflows whose name contains it, ignoring case, and the
+// created-at range includes both ends.
+type WorkflowFilter struct {
+	Status        WorkflowStatus
+	Name          string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	Page          int
+	Limit         int
 }
 
 type WorkflowConfig struct {
 	MaxConcurrency int           `json:"max_concurrency" yaml:"max_concurrency"`
 	Timeout        time.Duration `json:"timeout" yaml:"timeout"`
 	RetryPolicy    RetryPolicy   `json:"retry_policy" yaml:"retry_policy"`
+	DryRun         *DryRunConfig `json:"dry_run,omitempty" yaml:"dry_run,omitempty"`
+	// GroupConcurrency caps how many tasks of each named group may be
+	// queued or running at once, on top of MaxConcurrency.
+	GroupConcurrency map[string]int `json:"group_concurrency,omitempty" yaml:"group_concurrency,omitempty"`
+	// RetryBudget caps the retries of all the workflow's tasks together;
+	// the workflow fails as soon as one more is needed. Zero means no cap.
+	RetryBudget int `json:"retry_budget,omitempty" yaml:"retry_budget,omitempty"`
+	// Incremental reuses the results of tasks whose inputs match a task
+	// that completed in an earlier run; see AssignIncrementalKeys.
+	Incremental bool `json:"incremental,omitempty" yaml:"incremental,omitempty"`
 }
 
 type RetryPolicy struct {
@@ -71,15 +203,137 @@ type RetryPolicy struct {
 	InitialDelay  time.Duration `json:"initial_delay" yaml:"initial_delay"`
 	MaxDelay      time.Duration `json:"max_delay" yaml:"max_delay"`
 	BackoffFactor float64       `json:"backoff_factor" yaml:"backoff_factor"`
+	Jitter        JitterMode    `json:"jitter,omitempty" yaml:"jitter,omitempty"`
+}
+
+// JitterMode randomizes retry delays so tasks that failed together, for
+// example during an outage, do not all retry in the same second.
+type JitterMode string
+
+const (
+	// JitterNone waits exactly the exponential backoff.
+	JitterNone JitterMode = "none"
+	// JitterFull waits a random time between zero and the backoff.
+	JitterFull JitterMode = "full"
+	// JitterEqual waits half the backoff plus a random time up to the
+	// other half.
+	JitterEqual JitterMode = "equal"
+	// JitterDecorrelated waits a random time between InitialDelay and three
+	// times the previous delay, capped at MaxDelay.
+	JitterDecorrelated JitterMode = "decorrelated"
+)
+
+// Valid reports whether m is a jitter mode Delay knows. The empty mode is
+// valid and means JitterNone.
+func (m JitterMode) Valid() bool {
+	switch m {
+	case "", JitterNone, JitterFull, JitterEqual, JitterDecorrelated:
+		return true
+	}
+	return false
+}
+
+// DefaultRetryPolicy fills in any retry timing a workflow leaves unset.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:   3,
+	InitialDelay:  time.Second,
+	MaxDelay:      time.Minute * 5,
+	BackoffFactor: 2.0,
+}
+
+// Backoff returns how long to wait before the retry that follows the given
+// number of previous retries: InitialDelay * BackoffFactor^retryCount,
+// capped at MaxDelay.
+func (p RetryPolicy) Backoff(retryCount int) time.Duration {
+	initial, maxDelay, factor := p.InitialDelay, p.MaxDelay, p.BackoffFactor
+	if initial <= 0 {
+		initial = DefaultRetryPolicy.InitialDelay
+	}
+	if maxDelay <= 0 {
+		maxDelay = DefaultRetryPolicy.MaxDelay
+	}
+	if factor < 1 {
+		factor = DefaultRetryPolicy.BackoffFactor
+	}
+
+	delay := float64(initial) * math.Pow(factor, float64(retryCount))
+	if delay > float64(maxDelay) {
+		return maxDelay
+	}
+	return time.Duration(delay)
 }
 
+// Delay returns the backoff for the given number of previous retries with
+// the policy's jitter applied. previous is the delay before the last retry,
+// which decorrelated jitter grows from; zero means there was none.
+func (p RetryPolicy) Delay(retryCount int, previous time.Duration) time.Duration {
+	backoff := p.Backoff(retryCount)
+
+	switch p.Jitter {
+	case JitterFull:
+		return randomDuration(0, backoff)
+	case JitterEqual:
+		return backoff/2 + randomDuration(0, backoff-backoff/2)
+	case JitterDecorrelated:
+		initial, maxDelay := p.InitialDelay, p.MaxDelay
+		if initial <= 0 {
+			initial = DefaultRetryPolicy.InitialDelay
+		}
+		if maxDelay <= 0 {
+			maxDelay = DefaultRetryPolicy.MaxDelay
+		}
+		if previous < initial {
+			previous = initial
+		}
+		delay := randomDuration(initial, previous*3)
+		if delay > maxDelay {
+			return maxDelay
+		}
+		return delay
+	default:
+		return backoff
+	}
+}
+
+// randomDuration returns a random duration in [min, max].
+func randomDuration(min, max time.Duration) time.Duration {
+	if max <= min {
+		return min
+	}
+	return min + time.Duration(rand.Int63n(int64(max-min)+1))
+}
+
+const (
+	WorkerStatusActive = "active"
+	// WorkerStatusIdle marks a worker that has found all of its queues
+	// empty for a while and polls them less often.
+	WorkerStatusIdle = "idle"
+	// WorkerStatusStale marks a worker whose heartbeat timed out and that
+	// the leader has not expired yet.
+	WorkerStatusStale = "stale"
+)
+
 type WorkerInfo struct {
-	ID           string    `json:"id"`
-	Address      string    `json:"address"`
-	TaskTypes    []string  `json:"task_types"`
-	Status       string    `json:"status"`
-	LastHeartbeat time.Time `json:"last_heartbeat"`
-	CurrentTasks []string  `json:"current_tasks"`
+	ID         string   `json:"id"`
+	Address    string   `json:"address"`
+	TaskTypes  []string `json:"task_types"`
+	Pool       string   `json:"pool,omitempty"`
+	Namespaces []string `json:"namespaces,omitempty"`
+	// Labels describe the worker to task selectors, such as gpu=true or
+	// zone=us-east-1.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Slots is how many tasks of each of its types the worker runs at once.
+	// Workers that do not report it run one.
+	Slots map[string]int `json:"slots,omitempty"`
+	// Capacity is the CPU and memory the worker has for tasks, if it limits
+	// them. Allocated adds up the resources of the tasks it holds, and
+	// Utilization is the share of its capacity they take.
+	Capacity      *ResourceRequest     `json:"capacity,omitempty"`
+	Allocated     *ResourceRequest     `json:"allocated,omitempty"`
+	Utilization   *ResourceUtilization `json:"utilization,omitempty"`
+	Status        string               `json:"status"`
+	LastHeartbeat time.Time            `json:"last_heartbeat"`
+	CurrentTasks  []string             `json:"current_tasks"`
 }
 
 func NewTask(workflowID, name, taskType string, payload map[string]interface{}) *Task {
@@ -104,17 +358,13 @@ func NewWorkflow(name, description string) *Workflow {
 		ID:          uuid.New().String(),
 		Name:        name,
 		Description: description,
+		Namespace:   DefaultNamespace,
 		Status:      WorkflowStatusPending,
 		Tasks:       []Task{},
 		Config: WorkflowConfig{
 			MaxConcurrency: 10,
 			Timeout:        time.Hour,
-			RetryPolicy: RetryPolicy{
-				MaxAttempts:   3,
-				InitialDelay:  time.Second,
-				MaxDelay:      time.Minute * 5,
-				BackoffFactor: 2.0,
-			},
+			RetryPolicy:    DefaultRetryPolicy,
 		},
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
@@ -127,7 +377,41 @@ func (t *Task) CanExecute(completedTasks map[string]bool) bool {
 			return false
 		}
 	}
-	return t.Status == TaskStatusPending || t.Status == TaskStatusRetrying
+	return t.Status == TaskStatusPending
+}
+
+func (t *Task) UnmetDependencies(completedTasks map[string]bool) []string {
+	var unmet []string
+	for _, dep := range t.Dependencies {
+		if !completedTasks[dep] {
+			unmet = append(unmet, dep)
+		}
+	}
+	return unmet
+}
+
+func (t *Task) FailedDependencies(failedTasks map[string]bool) []string {
+	var failed []string
+	for _, dep := range t.Dependencies {
+		if failedTasks[dep] {
+			failed = append(failed, dep)
+		}
+	}
+	return failed
+}
+
+// Expired reports whether the task's ExpiresAt has passed by now.
+func (t *Task) Expired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
+
+// validateExpiry checks that a task set to run later expires after it may
+// start.
+func validateExpiry(runAt, expiresAt *time.Time) error {
+	if runAt != nil && expiresAt != nil && !expiresAt.After(*runAt) {
+		return errors.New("expires_at must be after run_at")
+	}
+	return nil
 }
 
 func (t *Task) ToJSON() ([]byte, error) {
@@ -0,0 +1,60 @@
+package core
+
+import (
+	"sort"
+	"time"
+)
+
+// ResourceUsage is what one execution of a task consumed, as reported by
+// the worker that ran it.
+type ResourceUsage struct {
+	CPUTime  time.Duration `json:"cpu_time"`
+	MaxRSS   int64         `json:"max_rss_bytes"`
+	WallTime time.Duration `json:"wall_time"`
+}
+
+// TaskUsage is the usage reported for one task of a workflow run.
+type TaskUsage struct {
+	TaskID string        `json:"task_id"`
+	Name   string        `json:"name"`
+	Type   string        `json:"type"`
+	Usage  ResourceUsage `json:"usage"`
+}
+
+// WorkflowUsage rolls up the usage of a workflow run. Total sums CPU and
+// wall time over its tasks and holds the largest MaxRSS of any of them.
+// Tasks lists the tasks that reported usage, heaviest CPU user first.
+type WorkflowUsage struct {
+	WorkflowID string        `json:"workflow_id"`
+	Total      ResourceUsage `json:"total"`
+	Tasks      []TaskUsage   `json:"tasks"`
+}
+
+func SummarizeUsage(workflowID string, tasks []Task) *WorkflowUsage {
+	summary := &WorkflowUsage{WorkflowID: workflowID, Tasks: []TaskUsage{}}
+
+	for _, task := range tasks {
+		if task.Usage == nil {
+			continue
+		}
+
+		summary.Total.CPUTime += task.Usage.CPUTime
+		summary.Total.WallTime += task.Usage.WallTime
+		if task.Usage.MaxRSS > summary.Total.MaxRSS {
+			summary.Total.MaxRSS = task.Usage.MaxRSS
+		}
+
+		summary.Tasks = append(summary.Tasks, TaskUsage{
+			TaskID: task.ID,
+			Name:   task.Name,
+			Type:   task.Type,
+			Usage:  *task.Usage,
+		})
+	}
+
+	sort.SliceStable(summary.Tasks, func(i, j int) bool {
+		return summary.Tasks[i].Usage.CPUTime > summary.Tasks[j].Usage.CPUTime
+	})
+
+	return summary
+}
@@ -0,0 +1,61 @@
+package core
+
+import (
+	"errors"
+	"time"
+)
+
+// WaitTaskType is the type of tasks the scheduler runs itself instead of
+// handing them to a worker. A wait task holds its dependents back until the
+// duration in its payload has passed since it started, or until the time
+// its payload names, without occupying a worker slot.
+const WaitTaskType = "wait"
+
+// WaitUntil returns when a wait task started at start is done waiting. Its
+// payload sets either duration, a Go duration string such as "1h", or until,
+// an RFC3339 time.
+func WaitUntil(payload map[string]interface{}, start time.Time) (time.Time, error) {
+	duration, hasDuration := payload["duration"]
+	until, hasUntil := payload["until"]
+
+	switch {
+	case hasDuration && hasUntil:
+		return time.Time{}, errors.New("wait task takes a duration or an until time, not both")
+	case hasDuration:
+		value, ok := duration.(string)
+		if !ok {
+			return time.Time{}, errors.New("wait duration must be a duration such as 1h")
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return time.Time{}, errors.New("wait duration must be a duration such as 1h")
+		}
+		return start.Add(d), nil
+	case hasUntil:
+		value, ok := until.(string)
+		if !ok {
+			return time.Time{}, errors.New("wait until must be an RFC3339 time")
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return time.Time{}, errors.New("wait until must be an RFC3339 time")
+		}
+		return t, nil
+	}
+	return time.Time{}, errors.New("wait task needs a duration or an until time in its payload")
+}
+
+// validateWait checks the payload of a wait task. A wait task starts as soon
+// as its dependencies allow, so it cannot also be held back by run_at.
+func validateWait(taskType string, payload map[string]interface{}, runAt *time.Time) error {
+	if taskType != WaitTaskType {
+		return nil
+	}
+	if runAt != nil {
+		return errors.New("wait task cannot set run_at; use an until time in its payload instead")
+	}
+	if _, err := WaitUntil(payload, time.Now()); err != nil {
+		return err
+	}
+	return nil
+}
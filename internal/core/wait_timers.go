@@ -0,0 +1,90 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// startWait arms the timer of a wait task in place of queuing it and marks
+// it running. A task re-armed by recovery keeps waiting from when it first
+// started. A payload that cannot be waited on fails the task.
+func (s *Scheduler) startWait(ctx context.Context, task *Task) error {
+	until, err := WaitUntil(task.Payload, waitStart(task, s.clock()))
+	if err != nil {
+		return s.UpdateTaskStatus(ctx, task.ID, TaskStatusFailed, nil, err.Error())
+	}
+
+	// The timer is armed first: a task marked running without one would
+	// never finish, while a timer that fires for a task that is not running
+	// is simply disarmed.
+	if err := s.queue.AddTimer(ctx, task.ID, until); err != nil {
+		return err
+	}
+	if task.Status != TaskStatusRunning {
+		if err := s.setTaskStatus(ctx, task.WorkflowID, task.ID, TaskStatusRunning, nil, ""); err != nil {
+			return err
+		}
+	}
+
+	s.logger.Infof("Task %s of workflow %s is waiting until %s", task.ID, task.WorkflowID, until.Format(time.RFC3339))
+	return nil
+}
+
+// completeDueWaits completes the wait tasks whose timers have fired. Timers
+// of tasks that stopped running meanwhile, such as by being cancelled, are
+// just disarmed.
+func (s *Scheduler) completeDueWaits(ctx context.Context) error {
+	ids, err := s.queue.GetDueTimers(ctx, s.clock())
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		task, err := s.store.FindTask(id)
+		if err != nil {
+			s.logger.Errorf("Failed to get wait task %s: %v", id, err)
+			continue
+		}
+		if task == nil {
+			// The task was deleted with its workflow; its timer would
+			// otherwise come due on every pass.
+			if _, err := s.queue.RemoveTimers(ctx, id); err != nil {
+				return fmt.Errorf("failed to disarm timer of deleted task %s: %w", id, err)
+			}
+			continue
+		}
+
+		if task.Status == TaskStatusRunning {
+			until, err := WaitUntil(task.Payload, waitStart(task, s.clock()))
+			if err != nil {
+				until = s.clock()
+			}
+			if err := s.UpdateTaskStatus(ctx, task.ID, TaskStatusCompleted, waitResult(until), ""); err != nil {
+				s.logger.Errorf("Failed to complete wait task %s: %v", task.ID, err)
+				continue
+			}
+			s.logger.Infof("Task %s of workflow %s finished waiting", task.ID, task.WorkflowID)
+		}
+
+		if _, err := s.queue.RemoveTimers(ctx, task.ID); err != nil {
+			return fmt.Errorf("failed to disarm timer of task %s: %w", task.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// waitResult is the result a wait task completes with.
+func waitResult(until time.Time) map[string]interface{} {
+	return map[string]interface{}{"waited_until": until.Format(time.RFC3339)}
+}
+
+// waitStart returns when a wait task's wait began: when it first started,
+// for a task re-armed after a failover, or now.
+func waitStart(task *Task, now time.Time) time.Time {
+	if task.Status == TaskStatusRunning && task.StartedAt != nil {
+		return *task.StartedAt
+	}
+	return now
+}
@@ -0,0 +1,19 @@
+package core
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCompleteDueWaitsDisarmsTimersOfDeletedTasks(t *testing.T) {
+	broker := newFakeBroker()
+	broker.timers = map[string]bool{"deleted": true}
+
+	s := newTestScheduler(newFakeStore(), broker)
+	if err := s.completeDueWaits(context.Background()); err != nil {
+		t.Fatalf("completeDueWaits() error = %v", err)
+	}
+	if len(broker.timers) != 0 {
+		t.Errorf("timers still armed: %v", broker.timers)
+	}
+}
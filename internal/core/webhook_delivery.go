@@ -0,0 +1,241 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// A failed delivery is retried after webhookRetryDelay, doubling each time,
+// until webhookMaxAttempts attempts have been made.
+const (
+	webhookMaxAttempts  = 5
+	webhookRetryDelay   = time.Second * 30
+	webhookTimeout      = time.Second * 10
+	webhookPollInterval = time.Second * 2
+	webhookBatchSize    = 50
+)
+
+// CreateWebhook saves a webhook after checking that its URL does not point
+// at an internal address.
+func (s *Scheduler) CreateWebhook(ctx context.Context, webhook *Webhook) error {
+	if err := s.checkWebhookURL(ctx, webhook.URL); err != nil {
+		return err
+	}
+	return s.queue.SaveWebhook(ctx, webhook)
+}
+
+func (s *Scheduler) GetWebhook(ctx context.Context, webhookID string) (*Webhook, error) {
+	return s.queue.GetWebhook(ctx, webhookID)
+}
+
+func (s *Scheduler) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	return s.queue.ListWebhooks(ctx)
+}
+
+func (s *Scheduler) DeleteWebhook(ctx context.Context, webhookID string) (bool, error) {
+	return s.queue.DeleteWebhook(ctx, webhookID)
+}
+
+func (s *Scheduler) GetWebhookAttempts(ctx context.Context, webhookID string) ([]WebhookAttempt, error) {
+	return s.queue.GetWebhookAttempts(ctx, webhookID)
+}
+
+// queueWebhooks schedules a delivery of event to every webhook subscribed
+// to it. Deliveries are sent by the leader, so publishing an event never
+// waits on a webhook.
+func (s *Scheduler) queueWebhooks(ctx context.Context, event *Event) {
+	if !IsWebhookEvent(event.Type) {
+		return
+	}
+
+	webhooks, err := s.queue.ListWebhooks(ctx)
+	if err != nil {
+		s.logger.Errorf("Failed to list webhooks for %s event: %v", event.Type, err)
+		return
+	}
+
+	namespace, err := s.eventNamespace(event, webhooks)
+	if err != nil {
+		s.logger.Errorf("Failed to get namespace of %s event: %v", event.Type, err)
+		return
+	}
+
+	for i := range webhooks {
+		if !webhooks[i].Matches(event, namespace) {
+			continue
+		}
+
+		delivery := &WebhookDelivery{
+			ID:        uuid.New().String(),
+			WebhookID: webhooks[i].ID,
+			Event:     event,
+			Attempt:   1,
+		}
+		if err := s.queue.ScheduleWebhookDelivery(ctx, delivery, time.Now()); err != nil {
+			s.logger.Errorf("Failed to queue %s event for webhook %s: %v", event.Type, delivery.WebhookID, err)
+		}
+	}
+}
+
+// eventNamespace returns the namespace of the workflow an event is about,
+// looking it up only when a namespaced webhook could receive it. It is ""
+// for broker events, which belong to no workflow.
+func (s *Scheduler) eventNamespace(event *Event, webhooks []Webhook) (string, error) {
+	if event.WorkflowID == "" {
+		return "", nil
+	}
+	for i := range webhooks {
+		if webhooks[i].Namespace != "" {
+			namespace, _, err := s.store.WorkflowNamespace(event.WorkflowID)
+			return namespace, err
+		}
+	}
+	return "", nil
+}
+
+func (s *Scheduler) deliverWebhooks(ctx context.Context) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			passCtx, leading := s.leaderContext()
+			if !leading {
+				continue
+			}
+			s.deliverDueWebhooks(passCtx)
+		}
+	}
+}
+
+func (s *Scheduler) deliverDueWebhooks(ctx context.Context) {
+	deliveries, err := s.queue.ClaimDueWebhookDeliveries(ctx, time.Now(), webhookBatchSize)
+	if err != nil {
+		s.logger.Errorf("Failed to claim webhook deliveries: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := range deliveries {
+		wg.Add(1)
+		go func(delivery *WebhookDelivery) {
+			defer wg.Done()
+			s.deliverWebhook(ctx, delivery)
+		}(&deliveries[i])
+	}
+	wg.Wait()
+}
+
+// deliverWebhook makes one attempt at a delivery, logs it and, if it failed
+// and attempts remain, schedules the next one. The claimed delivery is only
+// completed once it was sent or its retry scheduled; until then, a
+// scheduler that stops mid-attempt leaves it to be claimed again.
+func (s *Scheduler) deliverWebhook(ctx context.Context, delivery *WebhookDelivery) {
+	webhook, err := s.queue.GetWebhook(ctx, delivery.WebhookID)
+	if err != nil {
+		s.logger.Errorf("Failed to get webhook %s: %v", delivery.WebhookID, err)
+		return
+	}
+	if webhook == nil {
+		s.logger.Infof("Dropping delivery %s of deleted webhook %s", delivery.ID, delivery.WebhookID)
+		s.completeWebhookDelivery(ctx, delivery)
+		return
+	}
+
+	attempt := &WebhookAttempt{
+		DeliveryID: delivery.ID,
+		WebhookID:  delivery.WebhookID,
+		EventType:  delivery.Event.Type,
+		EventID:    delivery.Event.ID,
+		Attempt:    delivery.Attempt,
+		Timestamp:  time.Now(),
+	}
+
+	statusCode, err := s.postWebhook(ctx, webhook, delivery)
+	attempt.Duration = time.Since(attempt.Timestamp)
+	attempt.StatusCode = statusCode
+	attempt.Succeeded = err == nil
+	complete := true
+	if err != nil {
+		attempt.Error = err.Error()
+
+		if delivery.Attempt < webhookMaxAttempts {
+			next := time.Now().Add(webhookRetryDelay << (delivery.Attempt - 1))
+			attempt.NextRetry = &next
+
+			retry := *delivery
+			retry.Attempt++
+			retry.Claim = ""
+			if err := s.queue.ScheduleWebhookDelivery(ctx, &retry, next); err != nil {
+				s.logger.Errorf("Failed to schedule retry of webhook delivery %s: %v", delivery.ID, err)
+				attempt.NextRetry = nil
+				complete = false
+			}
+		}
+		s.logger.Warnf("Webhook delivery %s to %s failed (attempt %d/%d): %v", delivery.ID, webhook.URL, delivery.Attempt, webhookMaxAttempts, err)
+	}
+
+	if err := s.queue.RecordWebhookAttempt(ctx, attempt); err != nil {
+		s.logger.Errorf("Failed to record attempt of webhook delivery %s: %v", delivery.ID, err)
+	}
+	if complete {
+		s.completeWebhookDelivery(ctx, delivery)
+	}
+}
+
+func (s *Scheduler) completeWebhookDelivery(ctx context.Context, delivery *WebhookDelivery) {
+	if err := s.queue.CompleteWebhookDelivery(ctx, delivery); err != nil {
+		s.logger.Errorf("Failed to complete webhook delivery %s, it may be sent again: %v", delivery.ID, err)
+	}
+}
+
+// postWebhook sends a signed delivery and returns the response status.
+func (s *Scheduler) postWebhook(ctx context.Context, webhook *Webhook, delivery *WebhookDelivery) (int, error) {
+	body, err := json.Marshal(WebhookPayload{
+		DeliveryID: delivery.ID,
+		WebhookID:  webhook.ID,
+		Event:      delivery.Event,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to serialize webhook payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to build webhook request: %w", err)
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, string(delivery.Event.Type))
+	req.Header.Set(WebhookDeliveryHeader, delivery.ID)
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(webhook.Secret, timestamp, body))
+
+	resp, err := s.webhookClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
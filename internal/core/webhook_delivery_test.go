@@ -0,0 +1,185 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckWebhookIP(t *testing.T) {
+	tests := []struct {
+		ip           string
+		allowPrivate bool
+		allowed      bool
+	}{
+		{"203.0.113.10", false, true},
+		{"2001:db8::1", false, true},
+		{"127.0.0.1", true, false},
+		{"::1", true, false},
+		{"169.254.169.254", true, false},
+		{"fe80::1", true, false},
+		{"0.0.0.0", true, false},
+		{"224.0.0.1", true, false},
+		{"10.1.2.3", false, false},
+		{"172.16.0.1", false, false},
+		{"192.168.1.1", false, false},
+		{"fd00::1", false, false},
+		{"::ffff:10.1.2.3", false, false},
+		{"10.1.2.3", true, true},
+		{"fd00::1", true, true},
+	}
+
+	for _, tt := range tests {
+		err := checkWebhookIP(net.ParseIP(tt.ip), tt.allowPrivate)
+		if allowed := err == nil; allowed != tt.allowed {
+			t.Errorf("checkWebhookIP(%s, %v) = %v, want allowed %v", tt.ip, tt.allowPrivate, err, tt.allowed)
+		}
+		if err != nil && !errors.Is(err, ErrWebhookDestination) {
+			t.Errorf("checkWebhookIP(%s, %v) = %v, want ErrWebhookDestination", tt.ip, tt.allowPrivate, err)
+		}
+	}
+}
+
+func TestCreateWebhookRejectsInternalURLs(t *testing.T) {
+	s := newTestScheduler(newFakeStore(), newFakeBroker())
+
+	for _, rawURL := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://[::1]/hook",
+		"http://10.0.0.5/hook",
+	} {
+		webhook, err := NewWebhook(rawURL, "", "", nil, "")
+		if err != nil {
+			t.Fatalf("NewWebhook(%s) error = %v", rawURL, err)
+		}
+		if err := s.CreateWebhook(context.Background(), webhook); !errors.Is(err, ErrWebhookDestination) {
+			t.Errorf("CreateWebhook(%s) = %v, want ErrWebhookDestination", rawURL, err)
+		}
+	}
+
+	s.SetWebhookPrivateNetworks(true)
+	webhook, _ := NewWebhook("http://10.0.0.5/hook", "", "", nil, "")
+	if err := s.CreateWebhook(context.Background(), webhook); err != nil {
+		t.Errorf("CreateWebhook() with private networks allowed = %v", err)
+	}
+}
+
+func TestWebhookClientRefusesLoopback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	_, err := newWebhookClient(true).Get(server.URL)
+	if !errors.Is(err, ErrWebhookDestination) {
+		t.Errorf("Get(%s) = %v, want ErrWebhookDestination", server.URL, err)
+	}
+}
+
+func TestDeliverWebhookCompletesOnlyHandledDeliveries(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		deleted     bool
+		webhookErr  error
+		scheduleErr error
+		completed   bool
+		retried     bool
+	}{
+		{name: "sent", status: http.StatusOK, completed: true},
+		{name: "retry scheduled", status: http.StatusInternalServerError, completed: true, retried: true},
+		{name: "retry not scheduled", status: http.StatusInternalServerError, scheduleErr: errors.New("redis down")},
+		{name: "webhook deleted", deleted: true, completed: true},
+		{name: "webhook unreadable", webhookErr: errors.New("redis down")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			broker := newFakeBroker()
+			broker.webhookErr = tt.webhookErr
+			broker.scheduleErr = tt.scheduleErr
+			if !tt.deleted {
+				broker.webhooks = map[string]*Webhook{"hook": {ID: "hook", URL: server.URL, Secret: "secret"}}
+			}
+
+			s := newTestScheduler(newFakeStore(), broker)
+			s.webhookClient = server.Client()
+
+			delivery := &WebhookDelivery{
+				ID:        "delivery",
+				WebhookID: "hook",
+				Event:     &Event{ID: "event", Type: EventTaskFailed},
+				Attempt:   1,
+				Claim:     "claim",
+			}
+			s.deliverWebhook(context.Background(), delivery)
+
+			if completed := len(broker.completed) == 1; completed != tt.completed {
+				t.Errorf("completed = %v, want %v", broker.completed, tt.completed)
+			}
+			if retried := len(broker.deliveries) == 1; retried != tt.retried {
+				t.Fatalf("scheduled = %v, want retried %v", broker.deliveries, tt.retried)
+			}
+			if tt.retried && (broker.deliveries[0].Attempt != 2 || broker.deliveries[0].Claim != "") {
+				t.Errorf("retry = %+v, want attempt 2 without a claim", broker.deliveries[0])
+			}
+		})
+	}
+}
+
+func TestWebhookMatchesNamespace(t *testing.T) {
+	event := &Event{Type: EventTaskFailed, WorkflowID: "wf"}
+
+	tests := []struct {
+		webhook   Webhook
+		namespace string
+		want      bool
+	}{
+		{Webhook{}, "team-a", true},
+		{Webhook{}, "", true},
+		{Webhook{Namespace: "team-a"}, "team-a", true},
+		{Webhook{Namespace: "team-a"}, "team-b", false},
+		{Webhook{Namespace: "team-a"}, "", false},
+		{Webhook{Namespace: "team-a", WorkflowID: "other"}, "team-a", false},
+	}
+
+	for _, tt := range tests {
+		tt.webhook.Events = []EventType{EventTaskFailed}
+		if got := tt.webhook.Matches(event, tt.namespace); got != tt.want {
+			t.Errorf("%+v.Matches(event, %q) = %v, want %v", tt.webhook, tt.namespace, got, tt.want)
+		}
+	}
+}
+
+func TestQueueWebhooksScopesToWorkflowNamespace(t *testing.T) {
+	store := newFakeStore()
+	store.add(&Workflow{ID: "wf", Namespace: "team-a"})
+
+	broker := newFakeBroker()
+	broker.webhooks = map[string]*Webhook{
+		"same":    {ID: "same", Namespace: "team-a"},
+		"other":   {ID: "other", Namespace: "team-b"},
+		"cluster": {ID: "cluster"},
+	}
+	for _, webhook := range broker.webhooks {
+		webhook.Events = []EventType{EventTaskFailed}
+	}
+
+	s := newTestScheduler(store, broker)
+	s.queueWebhooks(context.Background(), &Event{ID: "event", Type: EventTaskFailed, WorkflowID: "wf"})
+
+	delivered := make(map[string]bool)
+	for _, delivery := range broker.deliveries {
+		delivered[delivery.WebhookID] = true
+	}
+	if !delivered["same"] || !delivered["cluster"] || delivered["other"] {
+		t.Errorf("delivered to %v, want same and cluster only", delivered)
+	}
+}
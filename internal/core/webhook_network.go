@@ -0,0 +1,112 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+)
+
+// ErrWebhookDestination is returned for a webhook URL that points at an
+// address webhooks may not be sent to.
+var ErrWebhookDestination = errors.New("webhook destination not allowed")
+
+// privateNetworks are the ranges a webhook may only be sent to when the
+// scheduler allows private networks: RFC 1918, shared address space and
+// IPv6 unique local addresses.
+var privateNetworks = mustParseCIDRs("10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10", "fc00::/7")
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks[i] = network
+	}
+	return networks
+}
+
+// checkWebhookIP rejects addresses that would let a webhook reach the
+// scheduler's own host or its cloud metadata service: loopback, link-local,
+// unspecified and multicast addresses, and private ranges unless
+// allowPrivate is set.
+func checkWebhookIP(ip net.IP, allowPrivate bool) error {
+	switch {
+	case ip.IsLoopback(), ip.IsLinkLocalUnicast(), ip.IsLinkLocalMulticast(),
+		ip.IsInterfaceLocalMulticast(), ip.IsMulticast(), ip.IsUnspecified():
+		return fmt.Errorf("%w: %s is an internal address", ErrWebhookDestination, ip)
+	}
+	if !allowPrivate {
+		for _, network := range privateNetworks {
+			if network.Contains(ip) {
+				return fmt.Errorf("%w: %s is a private address", ErrWebhookDestination, ip)
+			}
+		}
+	}
+	return nil
+}
+
+// newWebhookClient returns the client deliveries are sent with. Every
+// address it connects to is checked when it connects, so a host that
+// resolves to an internal address after it was registered, or a redirect
+// to one, is refused as well. It never goes through a proxy, which would
+// hide the address.
+func newWebhookClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: webhookTimeout,
+		Control: func(network, address string, conn syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil {
+				return fmt.Errorf("%w: %s is not an IP address", ErrWebhookDestination, host)
+			}
+			return checkWebhookIP(ip, allowPrivate)
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{Timeout: webhookTimeout, Transport: transport}
+}
+
+// SetWebhookPrivateNetworks lets webhooks be registered for and sent to
+// addresses in private ranges, for receivers inside the same network.
+// Loopback and link-local addresses stay refused.
+func (s *Scheduler) SetWebhookPrivateNetworks(allow bool) {
+	s.webhookAllowPrivate = allow
+	s.webhookClient = newWebhookClient(allow)
+}
+
+// checkWebhookURL resolves the host of a webhook URL and refuses it if any
+// of its addresses may not be sent to.
+func (s *Scheduler) checkWebhookURL(ctx context.Context, rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrWebhookDestination, err)
+	}
+
+	host := parsed.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		return checkWebhookIP(ip, s.webhookAllowPrivate)
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("%w: failed to resolve %s: %v", ErrWebhookDestination, host, err)
+	}
+	for _, addr := range addrs {
+		if err := checkWebhookIP(addr.IP, s.webhookAllowPrivate); err != nil {
+			return fmt.Errorf("%s resolves to a refused address: %w", host, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package core
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Headers sent with every webhook delivery. The signature is
+// "sha256=" followed by the hex HMAC-SHA256, keyed with the webhook's
+// secret, of the timestamp header, a dot and the request body.
+const (
+	WebhookEventHeader     = "X-Flowctl-Event"
+	WebhookDeliveryHeader  = "X-Flowctl-Delivery"
+	WebhookTimestampHeader = "X-Flowctl-Timestamp"
+	WebhookSignatureHeader = "X-Flowctl-Signature"
+)
+
+// WebhookEvents are the events a webhook can subscribe to.
+var WebhookEvents = []EventType{
+	EventWorkflowStarted,
+	EventWorkflowCompleted,
+	EventWorkflowFailed,
+	EventTaskFailed,
+	EventBrokerEvictionUnsafe,
+	EventBrokerKeysEvicted,
+}
+
+// Webhook is a URL notified of workflow and task state changes, either of
+// one workflow or, without a workflow ID, of every workflow in its
+// namespace. A webhook without a namespace is cluster-wide: it is notified
+// of every workflow, and of broker events that belong to no workflow.
+type Webhook struct {
+	ID         string      `json:"id"`
+	URL        string      `json:"url"`
+	Namespace  string      `json:"namespace,omitempty"`
+	WorkflowID string      `json:"workflow_id,omitempty"`
+	Events     []EventType `json:"events"`
+	Secret     string      `json:"secret,omitempty"`
+	CreatedAt  time.Time   `json:"created_at"`
+}
+
+// NewWebhook validates a webhook registration. Without events the webhook
+// receives all of WebhookEvents; without a secret one is generated.
+func NewWebhook(rawURL, namespace, workflowID string, events []EventType, secret string) (*Webhook, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("webhook url must be an absolute http or https URL")
+	}
+
+	if len(events) == 0 {
+		events = WebhookEvents
+	}
+	for _, event := range events {
+		if !IsWebhookEvent(event) {
+			return nil, fmt.Errorf("unsupported webhook event %q, expected one of %v", event, WebhookEvents)
+		}
+	}
+
+	if secret == "" {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("failed to generate webhook secret: %w", err)
+		}
+		secret = hex.EncodeToString(key)
+	}
+
+	return &Webhook{
+		ID:         uuid.New().String(),
+		URL:        rawURL,
+		Namespace:  namespace,
+		WorkflowID: workflowID,
+		Events:     events,
+		Secret:     secret,
+		CreatedAt:  time.Now(),
+	}, nil
+}
+
+// IsWebhookEvent reports whether webhooks can subscribe to an event type.
+func IsWebhookEvent(eventType EventType) bool {
+	for _, supported := range WebhookEvents {
+		if eventType == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches reports whether the webhook subscribes to an event of a workflow
+// in namespace, or to a broker event when namespace is "".
+func (w *Webhook) Matches(event *Event, namespace string) bool {
+	if w.WorkflowID != "" && w.WorkflowID != event.WorkflowID {
+		return false
+	}
+	if w.Namespace != "" && w.Namespace != namespace {
+		return false
+	}
+	for _, eventType := range w.Events {
+		if eventType == event.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookPayload is the JSON body of a webhook delivery. Retries of a
+// delivery carry the same delivery ID, so receivers can drop duplicates.
+type WebhookPayload struct {
+	DeliveryID string `json:"delivery_id"`
+	WebhookID  string `json:"webhook_id"`
+	Event      *Event `json:"event"`
+}
+
+// WebhookDelivery is an event waiting to be delivered to a webhook, and the
+// attempt it is on. Claim identifies a claimed delivery to the broker until
+// it is completed.
+type WebhookDelivery struct {
+	ID        string `json:"id"`
+	WebhookID string `json:"webhook_id"`
+	Event     *Event `json:"event"`
+	Attempt   int    `json:"attempt"`
+	Claim     string `json:"-"`
+}
+
+// WebhookAttempt records one attempt at a delivery in the webhook's
+// delivery log.
+type WebhookAttempt struct {
+	DeliveryID string        `json:"delivery_id"`
+	WebhookID  string        `json:"webhook_id"`
+	EventType  EventType     `json:"event_type"`
+	EventID    string        `json:"event_id,omitempty"`
+	Attempt    int           `json:"attempt"`
+	StatusCode int           `json:"status_code,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	Succeeded  bool          `json:"succeeded"`
+	NextRetry  *time.Time    `json:"next_retry,omitempty"`
+	Duration   time.Duration `json:"duration"`
+	Timestamp  time.Time     `json:"timestamp"`
+}
+
+// SignWebhookPayload returns the signature header value for a delivery
+// body sent at timestamp, in Unix seconds.
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,46 @@
+package core
+
+// ResourceUtilization is the share, from 0 to 1, of a worker's CPU and
+// memory capacity that the tasks it holds request. A resource the worker
+// does not limit is left out.
+type ResourceUtilization struct {
+	CPU    float64 `json:"cpu,omitempty"`
+	Memory float64 `json:"memory,omitempty"`
+}
+
+// Fits reports whether the worker could ever take a task requesting
+// request, that is whether it fits the worker's capacity with nothing else
+// running. A worker without a capacity takes any task.
+func (w *WorkerInfo) Fits(request *ResourceRequest) bool {
+	if w.Capacity == nil || request == nil {
+		return true
+	}
+	if w.Capacity.CPU > 0 && request.CPU > w.Capacity.CPU+1e-9 {
+		return false
+	}
+	if w.Capacity.MemoryMB > 0 && request.MemoryMB > w.Capacity.MemoryMB {
+		return false
+	}
+	return true
+}
+
+// SetAllocated records the resources requested by the tasks the worker
+// holds, and how much of its capacity they take.
+func (w *WorkerInfo) SetAllocated(allocated *ResourceRequest) {
+	if w.Capacity == nil && allocated.CPU == 0 && allocated.MemoryMB == 0 {
+		return
+	}
+	w.Allocated = allocated
+
+	if w.Capacity == nil {
+		return
+	}
+	utilization := &ResourceUtilization{}
+	if w.Capacity.CPU > 0 {
+		utilization.CPU = allocated.CPU / w.Capacity.CPU
+	}
+	if w.Capacity.MemoryMB > 0 {
+		utilization.Memory = float64(allocated.MemoryMB) / float64(w.Capacity.MemoryMB)
+	}
+	w.Utilization = utilization
+}
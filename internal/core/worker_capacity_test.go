@@ -0,0 +1,28 @@
+package core
+
+import "testing"
+
+func TestWorkerFits(t *testing.T) {
+	limited := &WorkerInfo{Capacity: &ResourceRequest{CPU: 2, MemoryMB: 4096}}
+	cpuOnly := &WorkerInfo{Capacity: &ResourceRequest{CPU: 2}}
+	unlimited := &WorkerInfo{}
+
+	tests := []struct {
+		name    string
+		worker  *WorkerInfo
+		request *ResourceRequest
+		want    bool
+	}{
+		{"no request", limited, nil, true},
+		{"within capacity", limited, &ResourceRequest{CPU: 2, MemoryMB: 4096}, true},
+		{"too much cpu", limited, &ResourceRequest{CPU: 2.5}, false},
+		{"too much memory", limited, &ResourceRequest{MemoryMB: 8192}, false},
+		{"unlimited memory", cpuOnly, &ResourceRequest{CPU: 1, MemoryMB: 1 << 20}, true},
+		{"no capacity", unlimited, &ResourceRequest{CPU: 64, MemoryMB: 1 << 20}, true},
+	}
+	for _, tt := range tests {
+		if got := tt.worker.Fits(tt.request); got != tt.want {
+			t.Errorf("%s: Fits() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package core
+
+import (
+	"sort"
+	"time"
+)
+
+// WorkerTypeSummary aggregates the registered workers that handle one task
+// type. Stale workers are counted in Stale only.
+type WorkerTypeSummary struct {
+	TaskType string `json:"task_type"`
+	Workers  int    `json:"workers"`
+	Active   int    `json:"active"`
+	Idle     int    `json:"idle"`
+	Stale    int    `json:"stale"`
+	// Concurrency is how many tasks of the type the live workers can run
+	// at once, by the slots each has for it.
+	Concurrency        int           `json:"concurrency"`
+	AvgHeartbeatAge    time.Duration `json:"avg_heartbeat_age"`
+	OldestHeartbeatAge time.Duration `json:"oldest_heartbeat_age"`
+}
+
+// WorkerSummary is the worker fleet by task type, ordered by type.
+type WorkerSummary struct {
+	GeneratedAt time.Time           `json:"generated_at"`
+	Types       []WorkerTypeSummary `json:"types"`
+}
+
+// SlotsFor returns how many tasks of a type the worker runs at once.
+func (w *WorkerInfo) SlotsFor(taskType string) int {
+	if slots := w.Slots[taskType]; slots > 0 {
+		return slots
+	}
+	return 1
+}
+
+// SummarizeWorkers aggregates workers by the task types they handle as of
+// now. A worker handling several types counts toward each of them.
+func SummarizeWorkers(workers []WorkerInfo, now time.Time) *WorkerSummary {
+	byType := make(map[string]*WorkerTypeSummary)
+	heartbeatAges := make(map[string]time.Duration)
+
+	for _, worker := range workers {
+		age := now.Sub(worker.LastHeartbeat)
+		for _, taskType := range worker.TaskTypes {
+			summary, ok := byType[taskType]
+			if !ok {
+				summary = &WorkerTypeSummary{TaskType: taskType}
+				byType[taskType] = summary
+			}
+
+			if worker.Status == WorkerStatusStale {
+				summary.Stale++
+				continue
+			}
+
+			summary.Workers++
+			summary.Concurrency += worker.SlotsFor(taskType)
+			if worker.Status == WorkerStatusIdle {
+				summary.Idle++
+			} else {
+				summary.Active++
+			}
+
+			heartbeatAges[taskType] += age
+			if age > summary.OldestHeartbeatAge {
+				summary.OldestHeartbeatAge = age
+			}
+		}
+	}
+
+	result := &WorkerSummary{
+		GeneratedAt: now,
+		Types:       make([]WorkerTypeSummary, 0, len(byType)),
+	}
+	for taskType, summary := range byType {
+		if summary.Workers > 0 {
+			summary.AvgHeartbeatAge = heartbeatAges[taskType] / time.Duration(summary.Workers)
+		}
+		result.Types = append(result.Types, *summary)
+	}
+	sort.Slice(result.Types, func(i, j int) bool {
+		return result.Types[i].TaskType < result.Types[j].TaskType
+	})
+
+	return result
+}
@@ -0,0 +1,89 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const maxTemplateNameLength = 255
+
+var ErrTemplateExists = errors.New("workflow template already exists")
+
+// WorkflowTemplate is one version of a named, reusable workflow definition.
+// Saving a template under an existing name adds a version; runs are built
+// from the latest version unless another is asked for. The definition's
+// params are defaults that each run may override.
+type WorkflowTemplate struct {
+	Name        string             `json:"name" db:"name"`
+	Version     int                `json:"version" db:"version"`
+	Description string             `json:"description" db:"description"`
+	Workflow    WorkflowDefinition `json:"workflow" db:"workflow"`
+	CreatedAt   time.Time          `json:"created_at" db:"created_at"`
+}
+
+func NewWorkflowTemplate(name, description string, workflow WorkflowDefinition) *WorkflowTemplate {
+	return &WorkflowTemplate{
+		Name:        name,
+		Description: description,
+		Workflow:    workflow,
+		CreatedAt:   time.Now(),
+	}
+}
+
+// Validate checks the template's name and definition. Payload templates are
+// only parsed, since the params they reference may be supplied per run.
+func (t *WorkflowTemplate) Validate() error {
+	if t.Name == "" {
+		return fmt.Errorf("template name is required")
+	}
+	if len(t.Name) > maxTemplateNameLength {
+		return fmt.Errorf("template name %s is longer than %d characters", t.Name, maxTemplateNameLength)
+	}
+	for _, r := range t.Name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.':
+		default:
+			return fmt.Errorf("template name %s contains %q", t.Name, r)
+		}
+	}
+
+	if len(t.Workflow.Tasks) == 0 {
+		return fmt.Errorf("template workflow has no tasks")
+	}
+
+	if t.Workflow.HasFixedIDs() {
+		return fmt.Errorf("template workflow cannot set ids, each run is assigned new ones")
+	}
+
+	if err := t.Workflow.validateFields(); err != nil {
+		return err
+	}
+
+	for _, taskDef := range t.Workflow.Tasks {
+		if err := CheckPayload(taskDef.Payload); err != nil {
+			return fmt.Errorf("task %s: %w", taskDef.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// Instantiate builds a new run of the template. params are laid over the
+// template's own params; rendering fails on any param that neither sets.
+func (t *WorkflowTemplate) Instantiate(params map[string]interface{}) (*Workflow, error) {
+	definition := t.Workflow
+	definition.Params = make(map[string]interface{}, len(t.Workflow.Params)+len(params))
+	for name, value := range t.Workflow.Params {
+		definition.Params[name] = value
+	}
+	for name, value := range params {
+		definition.Params[name] = value
+	}
+
+	if err := definition.Validate(); err != nil {
+		return nil, err
+	}
+	return definition.NewWorkflow()
+}
@@ -9,32 +9,59 @@ import (
 )
 
 type WorkflowSpec struct {
-	Name        string              `yaml:"name"`
-	Description string              `yaml:"description"`
-	Config      WorkflowConfigSpec  `yaml:"config,omitempty"`
-	Tasks       []TaskSpec          `yaml:"tasks"`
+	Name        string                 `yaml:"name"`
+	Description string                 `yaml:"description"`
+	Namespace   string                 `yaml:"namespace,omitempty"`
+	Priority    int                    `yaml:"priority,omitempty"`
+	Pool        string                 `yaml:"pool,omitempty"`
+	Owner       string                 `yaml:"owner,omitempty"`
+	DocsURL     string                 `yaml:"docs_url,omitempty"`
+	RunbookURL  string                 `yaml:"runbook_url,omitempty"`
+	Config      WorkflowConfigSpec     `yaml:"config,omitempty"`
+	Parameters  map[string]ParamSpec   `yaml:"parameters,omitempty"`
+	Params      map[string]interface{} `yaml:"params,omitempty"`
+	Labels      map[string]string      `yaml:"labels,omitempty"`
+	Tasks       []TaskSpec             `yaml:"tasks"`
 }
 
 type WorkflowConfigSpec struct {
-	MaxConcurrency int    `yaml:"max_concurrency,omitempty"`
-	Timeout        string `yaml:"timeout,omitempty"`
-	RetryPolicy    RetryPolicySpec `yaml:"retry_policy,omitempty"`
+	MaxConcurrency   int             `yaml:"max_concurrency,omitempty"`
+	Timeout          string          `yaml:"timeout,omitempty"`
+	RetryPolicy      RetryPolicySpec `yaml:"retry_policy,omitempty"`
+	DryRun           *DryRunConfig   `yaml:"dry_run,omitempty"`
+	GroupConcurrency map[string]int  `yaml:"group_concurrency,omitempty"`
+	RetryBudget      int             `yaml:"retry_budget,omitempty"`
+	Incremental      bool            `yaml:"incremental,omitempty"`
 }
 
 type RetryPolicySpec struct {
-	MaxAttempts   int    `yaml:"max_attempts,omitempty"`
-	InitialDelay  string `yaml:"initial_delay,omitempty"`
-	MaxDelay      string `yaml:"max_delay,omitempty"`
+	MaxAttempts   int     `yaml:"max_attempts,omitempty"`
+	InitialDelay  string  `yaml:"initial_delay,omitempty"`
+	MaxDelay      string  `yaml:"max_delay,omitempty"`
 	BackoffFactor float64 `yaml:"backoff_factor,omitempty"`
+	Jitter        string  `yaml:"jitter,omitempty"`
 }
 
 type TaskSpec struct {
-	Name         string                 `yaml:"name"`
-	Type         string                 `yaml:"type"`
-	Payload      map[string]interface{} `yaml:"payload,omitempty"`
-	MaxRetries   int                    `yaml:"max_retries,omitempty"`
-	Priority     int                    `yaml:"priority,omitempty"`
-	Dependencies []string               `yaml:"depends_on,omitempty"`
+	Name                 string                 `yaml:"name"`
+	Type                 string                 `yaml:"type"`
+	Payload              map[string]interface{} `yaml:"payload,omitempty"`
+	MaxRetries           int                    `yaml:"max_retries,omitempty"`
+	Priority             int                    `yaml:"priority,omitempty"`
+	Dependencies         []string               `yaml:"depends_on,omitempty"`
+	Timeout              string                 `yaml:"timeout,omitempty"`
+	RunAt                string                 `yaml:"run_at,omitempty"`
+	ExpiresAt            string                 `yaml:"expires_at,omitempty"`
+	RunOnUpstreamFailure bool                   `yaml:"run_on_upstream_failure,omitempty"`
+	Executor             Executor               `yaml:"executor,omitempty"`
+	Resources            *ResourceRequest       `yaml:"resources,omitempty"`
+	Selector             map[string]string      `yaml:"selector,omitempty"`
+	IdempotencyKey       string                 `yaml:"idempotency_key,omitempty"`
+	Dedupe               *DedupeSpec            `yaml:"dedupe,omitempty"`
+	Owner                string                 `yaml:"owner,omitempty"`
+	DocsURL              string                 `yaml:"docs_url,omitempty"`
+	RunbookURL           string                 `yaml:"runbook_url,omitempty"`
+	Group                string                 `yaml:"group,omitempty"`
 }
 
 func ParseWorkflowFromYAML(filename string) (*Workflow, error) {
@@ -57,6 +84,22 @@ func ParseWorkflowFromYAMLBytes(data []byte) (*Workflow, error) {
 
 func convertSpecToWorkflow(spec *WorkflowSpec) (*Workflow, error) {
 	workflow := NewWorkflow(spec.Name, spec.Description)
+	if spec.Namespace != "" {
+		workflow.Namespace = spec.Namespace
+	}
+	workflow.Priority = spec.Priority
+
+	if err := ValidatePool(spec.Pool); err != nil {
+		return nil, err
+	}
+	workflow.Pool = spec.Pool
+
+	if err := validateOwnership(spec.DocsURL, spec.RunbookURL); err != nil {
+		return nil, err
+	}
+	workflow.Owner = spec.Owner
+	workflow.DocsURL = spec.DocsURL
+	workflow.RunbookURL = spec.RunbookURL
 
 	if spec.Config.MaxConcurrency > 0 {
 		workflow.Config.MaxConcurrency = spec.Config.MaxConcurrency
@@ -94,21 +137,131 @@ func convertSpecToWorkflow(spec *WorkflowSpec) (*Workflow, error) {
 		workflow.Config.RetryPolicy.BackoffFactor = spec.Config.RetryPolicy.BackoffFactor
 	}
 
+	if spec.Config.RetryPolicy.Jitter != "" {
+		jitter := JitterMode(spec.Config.RetryPolicy.Jitter)
+		if !jitter.Valid() {
+			return nil, fmt.Errorf("unknown retry jitter %q", jitter)
+		}
+		workflow.Config.RetryPolicy.Jitter = jitter
+	}
+
+	workflow.Config.DryRun = spec.Config.DryRun
+
+	if err := validateGroupConcurrency(spec.Config.GroupConcurrency); err != nil {
+		return nil, err
+	}
+	workflow.Config.GroupConcurrency = spec.Config.GroupConcurrency
+
+	if err := validateRetryBudget(spec.Config.RetryBudget); err != nil {
+		return nil, err
+	}
+	workflow.Config.RetryBudget = spec.Config.RetryBudget
+	workflow.Config.Incremental = spec.Config.Incremental
+
+	if err := validateParamSpecs(spec.Parameters); err != nil {
+		return nil, err
+	}
+	params, err := ResolveParams(spec.Parameters, spec.Params)
+	if err != nil {
+		return nil, err
+	}
+	workflow.Params = params
+
+	if err := validateLabels(spec.Labels); err != nil {
+		return nil, err
+	}
+	workflow.Labels = spec.Labels
+
 	taskMap := make(map[string]*Task)
-	
+
 	for _, taskSpec := range spec.Tasks {
-		task := NewTask(workflow.ID, taskSpec.Name, taskSpec.Type, taskSpec.Payload)
-		
+		payload, err := RenderPayload(taskSpec.Payload, NewTemplateContext(params, workflow, taskSpec.Name))
+		if err != nil {
+			return nil, fmt.Errorf("task %s: %w", taskSpec.Name, err)
+		}
+
+		task := NewTask(workflow.ID, taskSpec.Name, taskSpec.Type, payload)
+
 		if taskSpec.MaxRetries > 0 {
 			task.MaxRetries = taskSpec.MaxRetries
 		}
-		
+
+		if workflow.Priority > 0 {
+			task.Priority = workflow.Priority
+		}
+
 		if taskSpec.Priority > 0 {
 			task.Priority = taskSpec.Priority
 		}
 
 		task.Dependencies = taskSpec.Dependencies
-		
+		task.RunOnUpstreamFailure = taskSpec.RunOnUpstreamFailure
+
+		if taskSpec.Executor != "" && !taskSpec.Executor.Valid() {
+			return nil, fmt.Errorf("unknown executor %q for task %s", taskSpec.Executor, taskSpec.Name)
+		}
+		task.Executor = taskSpec.Executor
+		task.Resources = taskSpec.Resources
+
+		if err := ValidateSelector(taskSpec.Selector); err != nil {
+			return nil, fmt.Errorf("task %s: %w", taskSpec.Name, err)
+		}
+		task.Selector = taskSpec.Selector
+
+		if len(taskSpec.IdempotencyKey) > maxIdempotencyKeyLength {
+			return nil, fmt.Errorf("task %s: idempotency key is longer than %d characters", taskSpec.Name, maxIdempotencyKeyLength)
+		}
+		task.IdempotencyKey = taskSpec.IdempotencyKey
+
+		if taskSpec.Dedupe != nil {
+			dedupe, err := taskSpec.Dedupe.config()
+			if err != nil {
+				return nil, fmt.Errorf("task %s: %w", taskSpec.Name, err)
+			}
+			task.Dedupe = dedupe
+		}
+		task.Pool = workflow.Pool
+
+		if len(taskSpec.Group) > maxGroupLength {
+			return nil, fmt.Errorf("task %s: group is longer than %d characters", taskSpec.Name, maxGroupLength)
+		}
+		task.Group = taskSpec.Group
+
+		if err := validateOwnership(taskSpec.DocsURL, taskSpec.RunbookURL); err != nil {
+			return nil, fmt.Errorf("task %s: %w", taskSpec.Name, err)
+		}
+		task.inheritOwnership(taskSpec.Owner, taskSpec.DocsURL, taskSpec.RunbookURL, workflow)
+
+		if taskSpec.Timeout != "" {
+			timeout, err := time.ParseDuration(taskSpec.Timeout)
+			if err != nil {
+				return nil, fmt.Errorf("invalid timeout for task %s: %w", taskSpec.Name, err)
+			}
+			task.Timeout = timeout
+		}
+
+		if taskSpec.RunAt != "" {
+			runAt, err := time.Parse(time.RFC3339, taskSpec.RunAt)
+			if err != nil {
+				return nil, fmt.Errorf("invalid run_at for task %s: %w", taskSpec.Name, err)
+			}
+			task.RunAt = &runAt
+		}
+
+		if taskSpec.ExpiresAt != "" {
+			expiresAt, err := time.Parse(time.RFC3339, taskSpec.ExpiresAt)
+			if err != nil {
+				return nil, fmt.Errorf("invalid expires_at for task %s: %w", taskSpec.Name, err)
+			}
+			task.ExpiresAt = &expiresAt
+		}
+		if err := validateExpiry(task.RunAt, task.ExpiresAt); err != nil {
+			return nil, fmt.Errorf("task %s: %w", taskSpec.Name, err)
+		}
+		if err := validateWait(task.Type, task.Payload, task.RunAt); err != nil {
+			return nil, fmt.Errorf("task %s: %w", taskSpec.Name, err)
+		}
+
 		taskMap[taskSpec.Name] = task
 		workflow.Tasks = append(workflow.Tasks, *task)
 	}
@@ -120,6 +273,12 @@ func convertSpecToWorkflow(spec *WorkflowSpec) (*Workflow, error) {
 	return workflow, nil
 }
 
+// ValidateDependencies checks that every dependency names a task of the
+// workflow and that the dependencies contain no cycle.
+func (w *Workflow) ValidateDependencies() error {
+	return validateWorkflowDependencies(w.Tasks)
+}
+
 func validateWorkflowDependencies(tasks []Task) error {
 	taskNames := make(map[string]bool)
 	for _, task := range tasks {
@@ -0,0 +1,62 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"flowctl/internal/core"
+)
+
+type DeadLetterAlerter interface {
+	Alert(ctx context.Context, alert *core.DeadLetterAlert) error
+}
+
+// WebhookAlerter posts dead letter alerts as JSON to a URL. When linkBase is
+// set, each alert links back to its entry in the scheduler API.
+type WebhookAlerter struct {
+	url      string
+	linkBase string
+	client   *http.Client
+}
+
+func NewWebhookAlerter(url, linkBase string) *WebhookAlerter {
+	return &WebhookAlerter{
+		url:      url,
+		linkBase: strings.TrimSuffix(linkBase, "/"),
+		client:   &http.Client{Timeout: time.Second * 5},
+	}
+}
+
+func (a *WebhookAlerter) Alert(ctx context.Context, alert *core.DeadLetterAlert) error {
+	if a.linkBase != "" {
+		alert.Link = fmt.Sprintf("%s/api/v1/dead-letters/%s/entries/%s", a.linkBase, alert.TaskType, alert.TaskID)
+	}
+
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("failed to serialize alert: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build alert request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send alert: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("alert webhook returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
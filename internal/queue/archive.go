@@ -0,0 +1,84 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type DeadLetterArchiver interface {
+	Archive(ctx context.Context, taskType string, entries []string) error
+}
+
+type FileArchiver struct {
+	dir string
+}
+
+func NewFileArchiver(dir string) (*FileArchiver, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	return &FileArchiver{dir: dir}, nil
+}
+
+func (a *FileArchiver) Archive(ctx context.Context, taskType string, entries []string) error {
+	name := fmt.Sprintf("%s-%s.jsonl", taskType, time.Now().UTC().Format("2006-01-02"))
+
+	f, err := os.OpenFile(filepath.Join(a.dir, name), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open archive file: %w", err)
+	}
+	defer f.Close()
+
+	for _, entry := range entries {
+		if _, err := f.WriteString(entry + "\n"); err != nil {
+			return fmt.Errorf("failed to write archive entry: %w", err)
+		}
+	}
+
+	return f.Sync()
+}
+
+// ObjectStore writes objects to a bucket, such as S3Store does to an
+// S3-compatible object storage service.
+type ObjectStore interface {
+	PutObject(ctx context.Context, key string, body []byte) error
+}
+
+// ObjectArchiver writes each batch of archived dead letter entries to an
+// object store as one JSON lines object, keyed
+// <prefix>/<task type>/<date>/<time>-<batch>.jsonl. Objects cannot be
+// appended to, so unlike FileArchiver it never rewrites an earlier batch.
+type ObjectArchiver struct {
+	store  ObjectStore
+	prefix string
+	now    func() time.Time
+}
+
+func NewObjectArchiver(store ObjectStore, prefix string) *ObjectArchiver {
+	return &ObjectArchiver{store: store, prefix: strings.Trim(prefix, "/"), now: time.Now}
+}
+
+func (a *ObjectArchiver) Archive(ctx context.Context, taskType string, entries []string) error {
+	var body bytes.Buffer
+	for _, entry := range entries {
+		body.WriteString(entry)
+		body.WriteByte('\n')
+	}
+
+	now := a.now().UTC()
+	name := fmt.Sprintf("%s-%s.jsonl", now.Format("150405.000000000"), uuid.New().String()[:8])
+	key := path.Join(a.prefix, taskType, now.Format("2006-01-02"), name)
+
+	if err := a.store.PutObject(ctx, key, body.Bytes()); err != nil {
+		return fmt.Errorf("failed to write archive object %s: %w", key, err)
+	}
+	return nil
+}
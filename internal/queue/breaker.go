@@ -0,0 +1,249 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"flowctl/internal/core"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+)
+
+// The client never resends a command: a write that failed on a transient
+// error may have been applied anyway, and a script such as a dequeue or an
+// enqueue must not run twice. Reads are retried by retryRead instead, up to
+// redisReadRetries times with a backoff between redisMinRetryBackoff and
+// redisMaxRetryBackoff. Once DefaultBreakerThreshold commands in a row have
+// failed, the circuit breaker opens: commands fail fast with core.ErrBrokerUnavailable, and one
+// probe command is let through after a cooldown that starts at
+// DefaultBreakerCooldown and doubles up to maxBreakerCooldown while Redis
+// stays down.
+const (
+	redisReadRetries        = 3
+	redisMinRetryBackoff    = time.Millisecond * 100
+	redisMaxRetryBackoff    = time.Second * 2
+	DefaultBreakerThreshold = 5
+	DefaultBreakerCooldown  = time.Second
+	maxBreakerCooldown      = time.Second * 30
+)
+
+// circuitBreaker is a go-redis hook that tracks consecutive transient
+// failures across every command the client sends.
+type circuitBreaker struct {
+	logger *logrus.Logger
+
+	mu            sync.Mutex
+	threshold     int
+	minCooldown   time.Duration
+	cooldown      time.Duration
+	failures      int
+	lastErr       error
+	lastFailureAt time.Time
+	openedAt      time.Time
+	probeAt       time.Time
+	probing       bool
+}
+
+func newCircuitBreaker(logger *logrus.Logger) *circuitBreaker {
+	return &circuitBreaker{
+		logger:      logger,
+		threshold:   DefaultBreakerThreshold,
+		minCooldown: DefaultBreakerCooldown,
+	}
+}
+
+// SetCircuitBreaker changes how many consecutive failed commands open the
+// circuit breaker and the first cooldown before Redis is probed again. A
+// threshold of zero disables the breaker.
+func (q *RedisQueue) SetCircuitBreaker(threshold int, cooldown time.Duration) {
+	q.breaker.mu.Lock()
+	defer q.breaker.mu.Unlock()
+
+	q.breaker.threshold = threshold
+	q.breaker.minCooldown = cooldown
+}
+
+// BrokerHealth reports the state of the connection to Redis from the
+// commands sent so far.
+func (q *RedisQueue) BrokerHealth() core.BrokerHealth {
+	return q.breaker.health()
+}
+
+// CheckBroker pings Redis, unless the circuit breaker is open, and reports
+// the resulting health.
+func (q *RedisQueue) CheckBroker(ctx context.Context) core.BrokerHealth {
+	q.client.Ping(ctx)
+	return q.breaker.health()
+}
+
+// probeKey marks the context of the command sent to probe Redis while the
+// breaker is open.
+type probeKey struct{}
+
+func (b *circuitBreaker) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return b.allow(ctx)
+}
+
+func (b *circuitBreaker) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	b.record(ctx, cmd.Err())
+	return nil
+}
+
+func (b *circuitBreaker) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return b.allow(ctx)
+}
+
+func (b *circuitBreaker) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	var err error
+	for _, cmd := range cmds {
+		if isTransientRedisError(cmd.Err()) {
+			err = cmd.Err()
+			break
+		}
+	}
+	b.record(ctx, err)
+	return nil
+}
+
+// allow rejects commands while the breaker is open, except for a single
+// probe once the cooldown has passed.
+func (b *circuitBreaker) allow(ctx context.Context) (context.Context, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.openedAt.IsZero() {
+		return ctx, nil
+	}
+	if b.probing || time.Now().Before(b.probeAt) {
+		return ctx, core.ErrBrokerUnavailable
+	}
+	b.probing = true
+	return context.WithValue(ctx, probeKey{}, true), nil
+}
+
+func (b *circuitBreaker) record(ctx context.Context, err error) {
+	if errors.Is(err, core.ErrBrokerUnavailable) {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// A command abandoned by its caller says nothing about Redis; if it
+	// was the probe, the next command probes instead.
+	if err != nil && ctx.Err() != nil {
+		if ctx.Value(probeKey{}) != nil {
+			b.probing = false
+		}
+		return
+	}
+
+	if !isTransientRedisError(err) {
+		if !b.openedAt.IsZero() {
+			b.logger.Infof("Redis connection restored after %s", time.Since(b.openedAt).Round(time.Millisecond))
+		}
+		b.failures = 0
+		b.openedAt = time.Time{}
+		b.probing = false
+		return
+	}
+
+	b.failures++
+	b.lastErr = err
+	b.lastFailureAt = time.Now()
+
+	switch {
+	case b.probing:
+		b.probing = false
+		b.cooldown *= 2
+		if b.cooldown > maxBreakerCooldown {
+			b.cooldown = maxBreakerCooldown
+		}
+		b.probeAt = time.Now().Add(b.cooldown)
+	case b.openedAt.IsZero() && b.threshold > 0 && b.failures >= b.threshold:
+		b.openedAt = time.Now()
+		b.cooldown = b.minCooldown
+		b.probeAt = b.openedAt.Add(b.cooldown)
+		b.logger.Warnf("Redis unavailable after %d failed commands, failing fast until it recovers: %v", b.failures, err)
+	}
+}
+
+func (b *circuitBreaker) health() core.BrokerHealth {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	health := core.BrokerHealth{
+		Status:              core.BrokerStatusUp,
+		ConsecutiveFailures: b.failures,
+	}
+	if b.failures == 0 {
+		return health
+	}
+
+	health.Status = core.BrokerStatusDegraded
+	health.LastError = b.lastErr.Error()
+	lastFailureAt := b.lastFailureAt
+	health.LastFailureAt = &lastFailureAt
+
+	if !b.openedAt.IsZero() {
+		health.Status = core.BrokerStatusDown
+		downSince, probeAt := b.openedAt, b.probeAt
+		health.DownSince = &downSince
+		health.NextProbeAt = &probeAt
+	}
+	return health
+}
+
+// retryRead runs read, which must only read from Redis, again while it
+// fails on a transient error, up to redisReadRetries times. It gives up at
+// once when ctx is done or the circuit breaker is open.
+func retryRead(ctx context.Context, read func() error) error {
+	backoff := redisMinRetryBackoff
+	for attempt := 0; ; attempt++ {
+		err := read()
+		if attempt == redisReadRetries || !isTransientRedisError(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > redisMaxRetryBackoff {
+			backoff = redisMaxRetryBackoff
+		}
+	}
+}
+
+// isTransientRedisError reports whether err means Redis could not be
+// reached or could not serve commands for now, as opposed to a reply such
+// as redis.Nil or an error about the command itself.
+func isTransientRedisError(err error) bool {
+	if err == nil || err == redis.Nil {
+		return false
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	msg := err.Error()
+	for _, prefix := range []string{"redis: connection pool timeout", "LOADING ", "READONLY ", "CLUSTERDOWN ", "TRYAGAIN ", "MASTERDOWN ", "ERR max number of clients reached"} {
+		if strings.HasPrefix(msg, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"flowctl/internal/core"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func TestRetryReadRetriesTransientErrors(t *testing.T) {
+	calls := 0
+	err := retryRead(context.Background(), func() error {
+		calls++
+		if calls == 1 {
+			return io.EOF
+		}
+		return nil
+	})
+	if err != nil || calls != 2 {
+		t.Errorf("retryRead() = %v after %d calls, want success on the second", err, calls)
+	}
+}
+
+func TestRetryReadDoesNotRetryOtherErrors(t *testing.T) {
+	for _, want := range []error{redis.Nil, core.ErrBrokerUnavailable, errors.New("WRONGTYPE Operation against a key")} {
+		calls := 0
+		err := retryRead(context.Background(), func() error {
+			calls++
+			return want
+		})
+		if err != want || calls != 1 {
+			t.Errorf("retryRead() of %q = %v after %d calls, want it returned at once", want, err, calls)
+		}
+	}
+}
+
+func TestRetryReadStopsWhenContextIsDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	calls := 0
+	err := retryRead(ctx, func() error {
+		calls++
+		return io.EOF
+	})
+	if err != io.EOF || calls != 1 {
+		t.Errorf("retryRead() = %v after %d calls, want io.EOF after one", err, calls)
+	}
+}
+
+func TestClientNeverResendsCommands(t *testing.T) {
+	client := redis.NewClient(redisOptions("localhost:6379", "", 0))
+	defer client.Close()
+
+	if n := client.Options().MaxRetries; n != 0 {
+		t.Errorf("client resends a failed command up to %d times, want never", n)
+	}
+}
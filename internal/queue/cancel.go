@@ -0,0 +1,46 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+)
+
+func (q *RedisQueue) PublishCancellation(ctx context.Context, taskID string) error {
+	channel := fmt.Sprintf("cancel:%s", taskID)
+
+	if err := q.client.Publish(ctx, channel, taskID).Err(); err != nil {
+		return fmt.Errorf("failed to publish cancellation: %w", err)
+	}
+
+	q.logger.Infof("Published cancellation for task %s", taskID)
+	return nil
+}
+
+func (q *RedisQueue) SubscribeCancellations(ctx context.Context) <-chan string {
+	pubsub := q.client.PSubscribe(ctx, "cancel:*")
+	taskIDs := make(chan string)
+
+	go func() {
+		defer close(taskIDs)
+		defer pubsub.Close()
+
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				select {
+				case taskIDs <- msg.Payload:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return taskIDs
+}
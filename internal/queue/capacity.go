@@ -0,0 +1,65 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"flowctl/internal/core"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// capacityScanLimit is how many tasks behind one that does not fit a
+// capacity-limited worker the dequeue script tries before giving up.
+const capacityScanLimit = 32
+
+// SetWorkerCapacity sets the CPU and memory the workers using this queue
+// have for tasks. They then only dequeue a task whose resources fit beside
+// those of the tasks they hold, and register with the capacity. A zero
+// amount leaves that resource unlimited.
+func (q *RedisQueue) SetWorkerCapacity(capacity *core.ResourceRequest) {
+	q.capacity = capacity
+}
+
+// capacityArgs returns the worker's capacity as arguments to the dequeue
+// script, zero for a resource that is unlimited.
+func (q *RedisQueue) capacityArgs() (string, string) {
+	if q.capacity == nil {
+		return "0", "0"
+	}
+	return strconv.FormatFloat(q.capacity.CPU, 'f', -1, 64), strconv.FormatInt(q.capacity.MemoryMB, 10)
+}
+
+// workerAllocation adds up the resources requested by the tasks a worker
+// holds.
+func (q *RedisQueue) workerAllocation(ctx context.Context, workerID string) (*core.ResourceRequest, error) {
+	receipts, err := q.client.HVals(ctx, workerTasksKey(workerID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tasks of worker %s: %w", workerID, err)
+	}
+
+	allocated := &core.ResourceRequest{}
+	if len(receipts) == 0 {
+		return allocated, nil
+	}
+
+	members, err := q.client.HMGet(ctx, leasesKey, receipts...).Result()
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to get leases of worker %s: %w", workerID, err)
+	}
+	for _, member := range members {
+		memberJSON, ok := member.(string)
+		if !ok {
+			// The lease ended after the claim was read.
+			continue
+		}
+		task, err := core.TaskFromJSON([]byte(memberJSON))
+		if err != nil || task.Resources == nil {
+			continue
+		}
+		allocated.CPU += task.Resources.CPU
+		allocated.MemoryMB += task.Resources.MemoryMB
+	}
+	return allocated, nil
+}
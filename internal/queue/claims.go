@@ -0,0 +1,91 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+
+	"flowctl/internal/core"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// A worker's claims live in worker_tasks:<worker> (task ID -> the receipt
+// handle of the task's lease), and task_owners maps each claimed task back
+// to its worker so a claim can be released knowing only the task.
+const (
+	workerTasksPrefix = "worker_tasks:"
+	taskOwnersKey     = "task_owners"
+)
+
+func workerTasksKey(workerID string) string {
+	return workerTasksPrefix + workerID
+}
+
+// GetWorkerTasks returns the IDs of the tasks a worker currently holds.
+func (q *RedisQueue) GetWorkerTasks(ctx context.Context, workerID string) ([]string, error) {
+	taskIDs, err := q.client.HKeys(ctx, workerTasksKey(workerID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tasks of worker %s: %w", workerID, err)
+	}
+	return taskIDs, nil
+}
+
+// requeueWorkerTasks returns every task a removed worker still held to its
+// queue. The interrupted run counts as a failed attempt, so a task with no
+// retries left is dead-lettered instead and returned marked failed.
+func (q *RedisQueue) requeueWorkerTasks(ctx context.Context, workerID string) ([]core.Task, error) {
+	claims, err := q.client.HGetAll(ctx, workerTasksKey(workerID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tasks of worker %s: %w", workerID, err)
+	}
+
+	var requeued []core.Task
+	for taskID, receipt := range claims {
+		member, err := q.client.HGet(ctx, leasesKey, receipt).Result()
+		if err == redis.Nil {
+			// The lease ended after the claim was read.
+			continue
+		}
+		if err != nil {
+			q.logger.Errorf("Failed to get lease of task %s held by worker %s: %v", taskID, workerID, err)
+			continue
+		}
+
+		task, err := core.TaskFromJSON([]byte(member))
+		if err != nil {
+			q.logger.Errorf("Failed to unmarshal task %s held by worker %s: %v", taskID, workerID, err)
+			continue
+		}
+
+		errorMsg := fmt.Sprintf("worker %s stopped heartbeating", workerID)
+		if task.RetryCount >= task.MaxRetries {
+			task.ReceiptHandle = receipt
+			if err := q.deadLetter(ctx, task, errorMsg); err != nil {
+				q.logger.Errorf("Failed to dead-letter task %s held by worker %s: %v", taskID, workerID, err)
+				continue
+			}
+			q.logger.Warnf("Dead-lettered task %s held by removed worker %s, which had no retries left", taskID, workerID)
+			task.Status = core.TaskStatusFailed
+			task.Error = errorMsg
+			requeued = append(requeued, *task)
+			continue
+		}
+
+		requeuedTask, err := q.requeueLease(ctx, task.Type, receipt, func(t *core.Task) {
+			t.RetryCount++
+			t.Error = errorMsg
+		})
+		if err != nil {
+			q.logger.Errorf("Failed to requeue task %s held by worker %s: %v", taskID, workerID, err)
+			continue
+		}
+		if requeuedTask != nil {
+			q.logger.Warnf("Requeued task %s held by removed worker %s", taskID, workerID)
+			requeuedTask.Status = core.TaskStatusRetrying
+			requeued = append(requeued, *requeuedTask)
+		}
+	}
+
+	q.client.Del(ctx, workerTasksKey(workerID))
+	return requeued, nil
+}
@@ -0,0 +1,346 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"flowctl/internal/core"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const deadLetterPolicyKey = "dead_letter_policies"
+
+func (q *RedisQueue) SetDeadLetterArchiver(archiver DeadLetterArchiver) {
+	q.archiver = archiver
+}
+
+func (q *RedisQueue) SetDeadLetterAlerter(alerter DeadLetterAlerter) {
+	q.alerter = alerter
+}
+
+func (q *RedisQueue) deadLetter(ctx context.Context, task *core.Task, errorMsg string) error {
+	deadLetterKey := fmt.Sprintf("dead_letter:%s", task.Type)
+
+	policy, err := q.GetDeadLetterPolicy(ctx, task.Type)
+	if err != nil {
+		return err
+	}
+
+	if policy != nil && policy.MaxSize > 0 && policy.Overflow == core.DeadLetterBlockNack {
+		size, err := q.client.LLen(ctx, deadLetterKey).Result()
+		if err != nil {
+			return fmt.Errorf("failed to get dead letter size: %w", err)
+		}
+		if size >= policy.MaxSize {
+			return fmt.Errorf("failed to dead-letter task %s: %w", task.ID, core.ErrDeadLetterFull)
+		}
+	}
+
+	deadTask := *task
+	deadTask.Error = errorMsg
+	deadLetteredAt := time.Now()
+	deadTask.DeadLetteredAt = &deadLetteredAt
+
+	deadJSON, err := deadTask.ToJSON()
+	if err != nil {
+		return fmt.Errorf("failed to serialize task: %w", err)
+	}
+
+	if err := q.settleLease(ctx, task.Type, task.ID, task.ReceiptHandle, deadLetterKey, string(deadJSON), nil, true); err != nil {
+		return fmt.Errorf("failed to dead-letter task: %w", err)
+	}
+
+	if policy != nil && policy.MaxSize > 0 {
+		q.enforceDeadLetterCap(ctx, task.Type, policy)
+	}
+
+	q.logger.Infof("Moved task %s to dead letter queue %s", task.ID, deadLetterKey)
+
+	if q.alerter != nil {
+		if err := q.alerter.Alert(ctx, core.NewDeadLetterAlert(&deadTask, q.Redactor(ctx))); err != nil {
+			q.logger.Errorf("Failed to send dead letter alert for task %s: %v", task.ID, err)
+		}
+	}
+
+	return nil
+}
+
+func (q *RedisQueue) enforceDeadLetterCap(ctx context.Context, taskType string, policy *core.DeadLetterPolicy) {
+	deadLetterKey := fmt.Sprintf("dead_letter:%s", taskType)
+	statsKey := fmt.Sprintf("dead_letter_stats:%s", taskType)
+
+	if policy.Overflow == core.DeadLetterArchive && q.archiver != nil {
+		var overflow []string
+		for {
+			size, err := q.client.LLen(ctx, deadLetterKey).Result()
+			if err != nil || size <= policy.MaxSize {
+				break
+			}
+			entry, err := q.client.RPop(ctx, deadLetterKey).Result()
+			if err != nil {
+				break
+			}
+			overflow = append(overflow, entry)
+		}
+
+		if len(overflow) == 0 {
+			return
+		}
+
+		if err := q.archiver.Archive(ctx, taskType, overflow); err != nil {
+			q.logger.Errorf("Failed to archive %d dead letter entries for %s: %v", len(overflow), taskType, err)
+			pipe := q.client.Pipeline()
+			for i := len(overflow) - 1; i >= 0; i-- {
+				pipe.RPush(ctx, deadLetterKey, overflow[i])
+			}
+			if _, err := pipe.Exec(ctx); err != nil {
+				q.logger.Errorf("Failed to restore dead letter entries for %s: %v", taskType, err)
+			}
+			return
+		}
+
+		q.client.HIncrBy(ctx, statsKey, "archived", int64(len(overflow)))
+		q.logger.Infof("Archived %d dead letter entries for %s", len(overflow), taskType)
+		return
+	}
+
+	if policy.Overflow == core.DeadLetterArchive {
+		q.logger.Warnf("No dead letter archiver configured, dropping oldest entries for %s", taskType)
+	}
+
+	dropped, err := q.trimDeadLetters(ctx, taskType, policy.MaxSize)
+	if err != nil {
+		q.logger.Errorf("Failed to trim dead letter queue for %s: %v", taskType, err)
+		return
+	}
+	if dropped > 0 {
+		q.logger.Infof("Dropped %d oldest dead letter entries for %s", dropped, taskType)
+	}
+}
+
+// trimDeadLetters drops the oldest entries of a type's dead letter queue
+// beyond maxSize, returning how many it dropped.
+func (q *RedisQueue) trimDeadLetters(ctx context.Context, taskType string, maxSize int64) (int64, error) {
+	deadLetterKey := fmt.Sprintf("dead_letter:%s", taskType)
+	statsKey := fmt.Sprintf("dead_letter_stats:%s", taskType)
+
+	size, err := q.client.LLen(ctx, deadLetterKey).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get dead letter size: %w", err)
+	}
+	if size <= maxSize {
+		return 0, nil
+	}
+
+	pipe := q.client.Pipeline()
+	pipe.LTrim(ctx, deadLetterKey, 0, maxSize-1)
+	pipe.HIncrBy(ctx, statsKey, "dropped", size-maxSize)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return size - maxSize, nil
+}
+
+// deadLetterPurgeBatch is how many entries at the old end of a dead letter
+// queue PurgeDeadLetters reads at a time.
+const deadLetterPurgeBatch = 500
+
+// expireDeadLettersScript drops the ARGV[1] oldest entries of the dead
+// letter queue KEYS[1], provided the newest of them is still ARGV[2], and
+// counts them as expired in KEYS[2]. It returns how many it dropped.
+var expireDeadLettersScript = redis.NewScript(`
+local n = tonumber(ARGV[1])
+if redis.call("LINDEX", KEYS[1], -n) ~= ARGV[2] then
+	return 0
+end
+redis.call("LTRIM", KEYS[1], 0, -n - 1)
+redis.call("HINCRBY", KEYS[2], "expired", n)
+return n
+`)
+
+// PurgeDeadLetters applies a policy's retention to its type's dead letter
+// queue: entries dead-lettered more than MaxAge ago are purged and, when
+// the policy drops the oldest entries on overflow, the queue is trimmed to
+// MaxSize. It returns how many entries were purged for their age and how
+// many for the size cap.
+func (q *RedisQueue) PurgeDeadLetters(ctx context.Context, policy core.DeadLetterPolicy) (int64, int64, error) {
+	var expired, dropped int64
+	if policy.MaxAge > 0 {
+		var err error
+		expired, err = q.expireDeadLetters(ctx, policy.TaskType, time.Now().Add(-policy.MaxAge))
+		if err != nil {
+			return expired, 0, err
+		}
+	}
+
+	// The other overflow modes are enforced as tasks are dead-lettered,
+	// by the workers.
+	if policy.MaxSize > 0 && policy.Overflow == core.DeadLetterDropOldest {
+		var err error
+		dropped, err = q.trimDeadLetters(ctx, policy.TaskType, policy.MaxSize)
+		if err != nil {
+			return expired, 0, fmt.Errorf("failed to trim dead letter queue for %s: %w", policy.TaskType, err)
+		}
+	}
+
+	return expired, dropped, nil
+}
+
+// expireDeadLetters drops the entries of a type's dead letter queue that
+// were dead-lettered before cutoff. New entries are pushed on the left, so
+// those are a run at its right end. Entries without a dead-letter time
+// were dead-lettered before it was recorded, and count as expired too.
+func (q *RedisQueue) expireDeadLetters(ctx context.Context, taskType string, cutoff time.Time) (int64, error) {
+	deadLetterKey := fmt.Sprintf("dead_letter:%s", taskType)
+	statsKey := fmt.Sprintf("dead_letter_stats:%s", taskType)
+
+	var expired int64
+	for {
+		entries, err := q.client.LRange(ctx, deadLetterKey, -deadLetterPurgeBatch, -1).Result()
+		if err != nil {
+			return expired, fmt.Errorf("failed to read dead letter queue of %s: %w", taskType, err)
+		}
+
+		old := 0
+		for i := len(entries) - 1; i >= 0; i-- {
+			task, err := core.TaskFromJSON([]byte(entries[i]))
+			if err == nil && task.DeadLetteredAt != nil && !task.DeadLetteredAt.Before(cutoff) {
+				break
+			}
+			old++
+		}
+		if old == 0 {
+			return expired, nil
+		}
+
+		removed, err := expireDeadLettersScript.Run(ctx, q.client, []string{deadLetterKey, statsKey}, old, entries[len(entries)-old]).Int64()
+		if err != nil {
+			return expired, fmt.Errorf("failed to purge dead letter queue of %s: %w", taskType, err)
+		}
+		expired += removed
+
+		// The queue changed while it was read; the next pass tries again.
+		if removed == 0 || old < len(entries) {
+			return expired, nil
+		}
+	}
+}
+
+func (q *RedisQueue) SetDeadLetterPolicy(ctx context.Context, policy core.DeadLetterPolicy) error {
+	policyJSON, err := json.Marshal(policy)
+	if err != nil {
+		return fmt.Errorf("failed to serialize dead letter policy: %w", err)
+	}
+
+	if err := q.client.HSet(ctx, deadLetterPolicyKey, policy.TaskType, policyJSON).Err(); err != nil {
+		return fmt.Errorf("failed to set dead letter policy: %w", err)
+	}
+
+	q.logger.Infof("Set dead letter policy for task type %s: max %d, %s, max age %s", policy.TaskType, policy.MaxSize, policy.Overflow, policy.MaxAge)
+	return nil
+}
+
+func (q *RedisQueue) DeleteDeadLetterPolicy(ctx context.Context, taskType string) error {
+	if err := q.client.HDel(ctx, deadLetterPolicyKey, taskType).Err(); err != nil {
+		return fmt.Errorf("failed to delete dead letter policy: %w", err)
+	}
+	return nil
+}
+
+func (q *RedisQueue) GetDeadLetterPolicy(ctx context.Context, taskType string) (*core.DeadLetterPolicy, error) {
+	policyJSON, err := q.client.HGet(ctx, deadLetterPolicyKey, taskType).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get dead letter policy: %w", err)
+	}
+
+	var policy core.DeadLetterPolicy
+	if err := json.Unmarshal([]byte(policyJSON), &policy); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal dead letter policy: %w", err)
+	}
+
+	return &policy, nil
+}
+
+// GetDeadLetterPolicies returns the dead letter policy of every task type
+// that has one.
+func (q *RedisQueue) GetDeadLetterPolicies(ctx context.Context) (map[string]core.DeadLetterPolicy, error) {
+	entries, err := q.client.HGetAll(ctx, deadLetterPolicyKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dead letter policies: %w", err)
+	}
+
+	policies := make(map[string]core.DeadLetterPolicy, len(entries))
+	for taskType, policyJSON := range entries {
+		var policy core.DeadLetterPolicy
+		if err := json.Unmarshal([]byte(policyJSON), &policy); err != nil {
+			q.logger.Errorf("Failed to unmarshal dead letter policy of task type %s: %v", taskType, err)
+			continue
+		}
+		policies[taskType] = policy
+	}
+	return policies, nil
+}
+
+func (q *RedisQueue) GetDeadLetterEntry(ctx context.Context, taskType, taskID string) (*core.Task, error) {
+	deadLetterKey := fmt.Sprintf("dead_letter:%s", taskType)
+
+	entries, err := q.client.LRange(ctx, deadLetterKey, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dead letter queue: %w", err)
+	}
+
+	for _, entry := range entries {
+		task, err := core.TaskFromJSON([]byte(entry))
+		if err != nil {
+			continue
+		}
+		if task.ID == taskID {
+			return task, nil
+		}
+	}
+
+	return nil, fmt.Errorf("dead letter entry not found: %s", taskID)
+}
+
+func (q *RedisQueue) GetDeadLetterStats(ctx context.Context, taskType string) (*core.DeadLetterStats, error) {
+	deadLetterKey := fmt.Sprintf("dead_letter:%s", taskType)
+	statsKey := fmt.Sprintf("dead_letter_stats:%s", taskType)
+
+	pipe := q.client.Pipeline()
+	size := pipe.LLen(ctx, deadLetterKey)
+	oldest := pipe.LIndex(ctx, deadLetterKey, -1)
+	counters := pipe.HGetAll(ctx, statsKey)
+
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to get dead letter stats: %w", err)
+	}
+
+	stats := &core.DeadLetterStats{
+		TaskType: taskType,
+		Size:     size.Val(),
+	}
+	stats.Dropped, _ = strconv.ParseInt(counters.Val()["dropped"], 10, 64)
+	stats.Archived, _ = strconv.ParseInt(counters.Val()["archived"], 10, 64)
+	stats.Expired, _ = strconv.ParseInt(counters.Val()["expired"], 10, 64)
+
+	if oldest.Val() != "" {
+		if task, err := core.TaskFromJSON([]byte(oldest.Val())); err == nil && task.DeadLetteredAt != nil {
+			stats.OldestAge = time.Since(*task.DeadLetteredAt)
+		}
+	}
+
+	policy, err := q.GetDeadLetterPolicy(ctx, taskType)
+	if err != nil {
+		return nil, err
+	}
+	stats.Policy = policy
+
+	return stats, nil
+}
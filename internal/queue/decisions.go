@@ -0,0 +1,113 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"flowctl/internal/core"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	decisionLogConfigKey = "scheduler:decision_log"
+	// decisionsKey is the sorted set of recorded scheduling passes, scored
+	// by when each ran in Unix nanoseconds.
+	decisionsKey = "scheduler:decisions"
+)
+
+func (q *RedisQueue) SetDecisionLogConfig(ctx context.Context, config core.DecisionLogConfig) error {
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to serialize decision log config: %w", err)
+	}
+
+	if err := q.client.Set(ctx, decisionLogConfigKey, configJSON, 0).Err(); err != nil {
+		return fmt.Errorf("failed to set decision log config: %w", err)
+	}
+
+	q.logger.Infof("Recording scheduler decisions: sample rate %g, retention %s", config.SampleRate, config.Retention)
+	return nil
+}
+
+// DeleteDecisionLogConfig stops recording scheduler decisions. Passes
+// already recorded expire with their retention.
+func (q *RedisQueue) DeleteDecisionLogConfig(ctx context.Context) error {
+	if err := q.client.Del(ctx, decisionLogConfigKey).Err(); err != nil {
+		return fmt.Errorf("failed to delete decision log config: %w", err)
+	}
+	return nil
+}
+
+func (q *RedisQueue) GetDecisionLogConfig(ctx context.Context) (*core.DecisionLogConfig, error) {
+	configJSON, err := q.client.Get(ctx, decisionLogConfigKey).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get decision log config: %w", err)
+	}
+
+	var config core.DecisionLogConfig
+	if err := json.Unmarshal([]byte(configJSON), &config); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal decision log config: %w", err)
+	}
+
+	return &config, nil
+}
+
+// RecordSchedulingPass stores a pass and drops those older than retention.
+func (q *RedisQueue) RecordSchedulingPass(ctx context.Context, pass *core.SchedulingPass, retention time.Duration) error {
+	passJSON, err := json.Marshal(pass)
+	if err != nil {
+		return fmt.Errorf("failed to serialize scheduling pass: %w", err)
+	}
+
+	cutoff := pass.GeneratedAt.Add(-retention).UnixNano()
+
+	pipe := q.client.Pipeline()
+	pipe.ZAdd(ctx, decisionsKey, &redis.Z{
+		Score:  float64(pass.GeneratedAt.UnixNano()),
+		Member: string(passJSON),
+	})
+	pipe.ZRemRangeByScore(ctx, decisionsKey, "-inf", fmt.Sprintf("(%d", cutoff))
+	// Once recording is turned off the last passes still age out.
+	pipe.Expire(ctx, decisionsKey, retention)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to record scheduling pass: %w", err)
+	}
+
+	return nil
+}
+
+// GetSchedulingPasses returns up to limit recorded passes, newest first,
+// skipping any that ran before since.
+func (q *RedisQueue) GetSchedulingPasses(ctx context.Context, since time.Time, limit int64) ([]core.SchedulingPass, error) {
+	min := "-inf"
+	if !since.IsZero() {
+		min = fmt.Sprintf("%d", since.UnixNano())
+	}
+
+	members, err := q.client.ZRevRangeByScore(ctx, decisionsKey, &redis.ZRangeBy{
+		Min:   min,
+		Max:   "+inf",
+		Count: limit,
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scheduling passes: %w", err)
+	}
+
+	passes := make([]core.SchedulingPass, 0, len(members))
+	for _, member := range members {
+		var pass core.SchedulingPass
+		if err := json.Unmarshal([]byte(member), &pass); err != nil {
+			q.logger.Errorf("Failed to deserialize scheduling pass: %v", err)
+			continue
+		}
+		passes = append(passes, pass)
+	}
+
+	return passes, nil
+}
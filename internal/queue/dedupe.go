@@ -0,0 +1,40 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// dedupeKey holds the ID of the task that claimed a fingerprint, for the
+// task's dedupe window.
+func dedupeKey(fingerprint string) string {
+	return fmt.Sprintf("dedupe:%s", fingerprint)
+}
+
+// claimDedupeScript gives the fingerprint KEYS[1] to task ARGV[1] for ARGV[2]
+// milliseconds if nobody holds it or its holder is ARGV[3], and returns the
+// fingerprint's holder.
+var claimDedupeScript = redis.NewScript(`
+local holder = redis.call("GET", KEYS[1])
+if holder == false or holder == ARGV[3] then
+	redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+	return ARGV[1]
+end
+return holder
+`)
+
+// ClaimDedupeKey claims a task fingerprint for a task for window, taking it
+// over from stale if that task holds it, and returns the ID of the task
+// holding the fingerprint afterwards. A task that already holds it keeps its
+// original window.
+func (q *RedisQueue) ClaimDedupeKey(ctx context.Context, fingerprint, taskID string, window time.Duration, stale string) (string, error) {
+	holder, err := claimDedupeScript.Run(ctx, q.client, []string{dedupeKey(fingerprint)},
+		taskID, window.Milliseconds(), stale).Text()
+	if err != nil {
+		return "", fmt.Errorf("failed to claim dedupe key: %w", err)
+	}
+	return holder, nil
+}
@@ -0,0 +1,128 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"flowctl/internal/core"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// promoteBatch caps how many due tasks one pass moves out of a retry or
+// delayed set.
+const promoteBatch = 100
+
+// delayedKey is the sorted set where tasks with a future RunAt wait, scored
+// by that time in Unix seconds.
+func delayedKey(taskType string) string {
+	return fmt.Sprintf("delayed:%s", taskType)
+}
+
+// promoteScript moves ARGV[1] from the waiting set KEYS[1] to the queue
+// KEYS[2] with score ARGV[3], storing it as ARGV[2]. Nothing is added if
+// the member has meanwhile left the waiting set, such as by being purged.
+var promoteScript = redis.NewScript(`
+if redis.call("ZREM", KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+redis.call("ZADD", KEYS[2], ARGV[3], ARGV[2])
+return 1
+`)
+
+// delayTask adds a task to pipe to be parked until its RunAt instead of
+// being queued.
+func delayTask(ctx context.Context, pipe redis.Pipeliner, task *core.Task, taskJSON []byte) {
+	pipe.ZAdd(ctx, delayedKey(task.Type), &redis.Z{
+		Score:  float64(task.RunAt.Unix()),
+		Member: string(taskJSON),
+	})
+}
+
+// ProcessDelayedTasks moves tasks of a type whose RunAt has come to their
+// queue, behind tasks of higher priority like any newly enqueued task.
+func (q *RedisQueue) ProcessDelayedTasks(ctx context.Context, taskType string) error {
+	return q.promoteDue(ctx, delayedKey(taskType), "delayed", taskQueueKey)
+}
+
+// promoteDue moves the tasks in a retry or delayed set whose time has come
+// onto the queue, or retry lane, that queueKey picks for each.
+func (q *RedisQueue) promoteDue(ctx context.Context, key, kind string, queueKey func(*core.Task) string) error {
+	now := float64(time.Now().Unix())
+
+	members, err := q.client.ZRangeByScore(ctx, key, &redis.ZRangeBy{
+		Min:   "0",
+		Max:   fmt.Sprintf("%f", now),
+		Count: promoteBatch,
+	}).Result()
+	if err != nil {
+		return fmt.Errorf("failed to get %s tasks: %w", kind, err)
+	}
+
+	if len(members) == 0 {
+		return nil
+	}
+
+	// The namespace a task was queued for may no longer have workers for
+	// its type, so each is routed afresh.
+	dedicated, err := q.dedicatedTypes(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, member := range members {
+		task, err := core.TaskFromJSON([]byte(member))
+		if err != nil {
+			q.logger.Errorf("Failed to deserialize %s task: %v", kind, err)
+			continue
+		}
+		routeToNamespace(task, dedicated)
+
+		enqueuedAt := time.Now()
+		task.EnqueuedAt = &enqueuedAt
+
+		taskJSON, err := task.ToJSON()
+		if err != nil {
+			q.logger.Errorf("Failed to serialize %s task %s: %v", kind, task.ID, err)
+			continue
+		}
+
+		// The pool may have been pruned while no queue of it existed.
+		if task.Pool != "" {
+			if err := q.client.SAdd(ctx, poolsKey, task.Pool).Err(); err != nil {
+				q.logger.Errorf("Failed to record worker pool %s: %v", task.Pool, err)
+				continue
+			}
+		}
+		// Likewise the namespace, once its workers were all gone.
+		if task.Pool == "" && task.QueueNamespace != "" {
+			if err := q.client.SAdd(ctx, dedicatedNamespacesKey, task.QueueNamespace).Err(); err != nil {
+				q.logger.Errorf("Failed to record dedicated namespace %s: %v", task.QueueNamespace, err)
+				continue
+			}
+		}
+		// And the selector, which labeled workers only find through the set.
+		if len(task.Selector) > 0 {
+			if err := q.client.SAdd(ctx, selectorsKey, core.FormatSelector(task.Selector)).Err(); err != nil {
+				q.logger.Errorf("Failed to record task selector of task %s: %v", task.ID, err)
+				continue
+			}
+		}
+
+		score := fmt.Sprintf("%f", priorityScore(task))
+		promoted, err := promoteScript.Run(ctx, q.client, []string{key, queueKey(task)}, member, string(taskJSON), score).Int()
+		if err != nil {
+			q.logger.Errorf("Failed to requeue %s task %s: %v", kind, task.ID, err)
+			continue
+		}
+		if promoted == 0 {
+			continue
+		}
+
+		q.nudgeWorkers(ctx, task)
+		q.logger.Infof("Requeued %s task %s", kind, task.ID)
+	}
+
+	return nil
+}
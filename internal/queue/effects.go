@@ -0,0 +1,117 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"flowctl/internal/core"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+// effectLockTTL bounds how long a crashed worker can keep an effect locked
+// when its context has no deadline.
+const effectLockTTL = time.Minute * 10
+
+func effectKey(token string) string {
+	return fmt.Sprintf("effect:%s", token)
+}
+
+func effectLockKey(token string) string {
+	return fmt.Sprintf("effect_lock:%s", token)
+}
+
+var releaseEffectLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// EffectResult is the completion check for a side effect: it returns the
+// result recorded under token and whether the effect has completed.
+// Completions are kept as long as idempotent results.
+func (q *RedisQueue) EffectResult(ctx context.Context, token string) (map[string]interface{}, bool, error) {
+	resultJSON, err := q.client.Get(ctx, effectKey(token)).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to get effect %s: %w", token, err)
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal([]byte(resultJSON), &result); err != nil {
+		return nil, false, fmt.Errorf("failed to unmarshal effect %s: %w", token, err)
+	}
+	return result, true, nil
+}
+
+// CompleteEffect records that the side effect identified by token has
+// happened, with its result. Only the first completion is kept.
+func (q *RedisQueue) CompleteEffect(ctx context.Context, token string, result map[string]interface{}) error {
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to serialize effect result: %w", err)
+	}
+
+	if err := q.client.SetNX(ctx, effectKey(token), resultJSON, idempotencyResultTTL).Err(); err != nil {
+		return fmt.Errorf("failed to complete effect %s: %w", token, err)
+	}
+	return nil
+}
+
+// RunEffect performs a side effect at most once to completion per token,
+// on top of the queue's at-least-once delivery. If the effect already
+// completed, its recorded result is returned without calling fn. Otherwise
+// fn runs with the token, under a lock that makes a concurrent delivery of
+// the same task fail with core.ErrEffectInProgress, and its result is
+// recorded when it succeeds. fn should pass the token to the external
+// system as an idempotency key, which covers a crash between the effect and
+// its completion being recorded.
+func (q *RedisQueue) RunEffect(ctx context.Context, token string, fn func(ctx context.Context, token string) (map[string]interface{}, error)) (map[string]interface{}, error) {
+	result, done, err := q.EffectResult(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if done {
+		return result, nil
+	}
+
+	lockTTL := effectLockTTL
+	if deadline, ok := ctx.Deadline(); ok {
+		lockTTL = time.Until(deadline) + time.Minute
+	}
+
+	owner := uuid.New().String()
+	locked, err := q.client.SetNX(ctx, effectLockKey(token), owner, lockTTL).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock effect %s: %w", token, err)
+	}
+	if !locked {
+		return nil, fmt.Errorf("%w: %s", core.ErrEffectInProgress, token)
+	}
+	defer releaseEffectLockScript.Run(context.Background(), q.client, []string{effectLockKey(token)}, owner)
+
+	// The effect may have completed between the check and the lock.
+	result, done, err = q.EffectResult(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if done {
+		return result, nil
+	}
+
+	result, err = fn(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := q.CompleteEffect(ctx, token, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
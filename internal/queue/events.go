@@ -0,0 +1,262 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"flowctl/internal/core"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	eventStreamKey    = "events"
+	eventStreamMaxLen = 100000
+	eventRangePage    = 1000
+
+	// A workflow's own stream keeps its recent events, and is dropped a
+	// week after its last one.
+	workflowEventStreamMaxLen = 10000
+	workflowEventStreamTTL    = 7 * 24 * time.Hour
+)
+
+// workflowEventStreamKey indexes one workflow's events under the IDs they
+// have in the event stream, so a reader following one workflow need not
+// scan every other workflow's events.
+func workflowEventStreamKey(workflowID string) string {
+	return fmt.Sprintf("events:workflow:%s", workflowID)
+}
+
+// appendEventsScript adds each event ARGV[i+3] to the event stream KEYS[1],
+// capped near ARGV[1] entries, and under the same ID to its workflow's
+// stream KEYS[i+1], capped near ARGV[2] entries and expiring ARGV[3]
+// seconds after its last event. An event with no workflow is given KEYS[1]
+// as its workflow's stream. It returns the events' IDs.
+var appendEventsScript = redis.NewScript(`
+local ids = {}
+for i = 4, #ARGV do
+	local id = redis.call("XADD", KEYS[1], "MAXLEN", "~", ARGV[1], "*", "event", ARGV[i])
+	local workflow = KEYS[i - 2]
+	if workflow ~= KEYS[1] then
+		redis.call("XADD", workflow, "MAXLEN", "~", ARGV[2], id, "event", ARGV[i])
+		redis.call("EXPIRE", workflow, ARGV[3])
+	end
+	ids[#ids + 1] = id
+end
+return ids
+`)
+
+func (q *RedisQueue) PublishEvent(ctx context.Context, event *core.Event) error {
+	eventJSON, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to serialize event: %w", err)
+	}
+
+	if err := q.appendEvents(ctx, []*core.Event{event}, [][]byte{eventJSON}); err != nil {
+		return fmt.Errorf("failed to publish event: %w", err)
+	}
+	return nil
+}
+
+// PublishEvents adds events to the stream in one round trip, setting the
+// ID of each one published. An event that cannot be serialized is logged
+// and skipped rather than holding back the others.
+func (q *RedisQueue) PublishEvents(ctx context.Context, events []*core.Event) error {
+	published := make([]*core.Event, 0, len(events))
+	payloads := make([][]byte, 0, len(events))
+	for _, event := range events {
+		eventJSON, err := json.Marshal(event)
+		if err != nil {
+			q.logger.Errorf("Skipping %s event that failed to serialize: %v", event.Type, err)
+			continue
+		}
+		published = append(published, event)
+		payloads = append(payloads, eventJSON)
+	}
+	if len(published) == 0 {
+		return nil
+	}
+
+	if err := q.appendEvents(ctx, published, payloads); err != nil {
+		return fmt.Errorf("failed to publish %d events: %w", len(published), err)
+	}
+	return nil
+}
+
+// appendEvents adds serialized events to the event stream and their
+// workflows' streams, and sets their IDs.
+func (q *RedisQueue) appendEvents(ctx context.Context, events []*core.Event, payloads [][]byte) error {
+	keys, args := appendEventsArgs(events, payloads)
+	ids, err := appendEventsScript.Run(ctx, q.client, keys, args...).StringSlice()
+	if err != nil {
+		return err
+	}
+	for i, id := range ids {
+		events[i].ID = id
+	}
+	return nil
+}
+
+// appendEventsArgs lays out the keys and arguments of appendEventsScript.
+func appendEventsArgs(events []*core.Event, payloads [][]byte) ([]string, []interface{}) {
+	keys := make([]string, 0, len(events)+1)
+	args := make([]interface{}, 0, len(events)+3)
+	keys = append(keys, eventStreamKey)
+	args = append(args, eventStreamMaxLen, workflowEventStreamMaxLen, int64(workflowEventStreamTTL.Seconds()))
+	for i, event := range events {
+		if event.WorkflowID == "" {
+			keys = append(keys, eventStreamKey)
+		} else {
+			keys = append(keys, workflowEventStreamKey(event.WorkflowID))
+		}
+		args = append(args, payloads[i])
+	}
+	return keys, args
+}
+
+func (q *RedisQueue) ReadEvents(ctx context.Context, after string, count int64, block time.Duration) ([]core.Event, error) {
+	return q.readEventStream(ctx, eventStreamKey, after, count, block)
+}
+
+// ReadWorkflowEventsAfter is ReadEvents for one workflow's events. Its
+// events have the IDs they have in the event stream, so after may be any
+// event ID.
+func (q *RedisQueue) ReadWorkflowEventsAfter(ctx context.Context, workflowID, after string, count int64, block time.Duration) ([]core.Event, error) {
+	return q.readEventStream(ctx, workflowEventStreamKey(workflowID), after, count, block)
+}
+
+func (q *RedisQueue) readEventStream(ctx context.Context, key, after string, count int64, block time.Duration) ([]core.Event, error) {
+	if after == "" {
+		after = "0"
+	}
+
+	args := &redis.XReadArgs{
+		Streams: []string{key, after},
+		Count:   count,
+		Block:   -1,
+	}
+	if block > 0 {
+		args.Block = block
+	}
+
+	streams, err := q.client.XRead(ctx, args).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return []core.Event{}, nil
+		}
+		return nil, fmt.Errorf("failed to read events: %w", err)
+	}
+
+	events := []core.Event{}
+	for _, stream := range streams {
+		events = append(events, q.decodeEvents(stream.Messages)...)
+	}
+
+	return events, nil
+}
+
+// ReadWorkflowEvents returns a workflow's events whose stream IDs fall
+// between start and end, oldest first. Stream IDs carry Redis's clock, so
+// callers should widen the range and filter on the events' timestamps.
+// They are read from the workflow's own stream; a workflow whose events
+// were published before it had one is found by scanning the event stream.
+func (q *RedisQueue) ReadWorkflowEvents(ctx context.Context, workflowID string, start, end time.Time) ([]core.Event, error) {
+	from := strconv.FormatInt(start.UnixMilli(), 10)
+	to := strconv.FormatInt(end.UnixMilli(), 10)
+
+	key := workflowEventStreamKey(workflowID)
+	indexed, err := q.client.Exists(ctx, key).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read events: %w", err)
+	}
+	if indexed == 0 {
+		return q.scanWorkflowEvents(ctx, workflowID, from, to)
+	}
+
+	events := []core.Event{}
+	for {
+		messages, err := q.client.XRangeN(ctx, key, from, to, eventRangePage).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read events: %w", err)
+		}
+
+		events = append(events, q.decodeEvents(messages)...)
+
+		if len(messages) < eventRangePage {
+			return events, nil
+		}
+		from = nextStreamID(messages[len(messages)-1].ID)
+	}
+}
+
+// scanWorkflowEvents reads a workflow's events between the stream IDs from
+// and to by filtering the whole event stream.
+func (q *RedisQueue) scanWorkflowEvents(ctx context.Context, workflowID, from, to string) ([]core.Event, error) {
+	events := []core.Event{}
+	for {
+		messages, err := q.client.XRangeN(ctx, eventStreamKey, from, to, eventRangePage).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read events: %w", err)
+		}
+
+		for _, event := range q.decodeEvents(messages) {
+			if event.WorkflowID == workflowID {
+				events = append(events, event)
+			}
+		}
+
+		if len(messages) < eventRangePage {
+			return events, nil
+		}
+		from = nextStreamID(messages[len(messages)-1].ID)
+	}
+}
+
+func (q *RedisQueue) decodeEvents(messages []redis.XMessage) []core.Event {
+	events := make([]core.Event, 0, len(messages))
+	for _, message := range messages {
+		eventJSON, ok := message.Values["event"].(string)
+		if !ok {
+			continue
+		}
+
+		var event core.Event
+		if err := json.Unmarshal([]byte(eventJSON), &event); err != nil {
+			q.logger.Errorf("Failed to unmarshal event %s: %v", message.ID, err)
+			continue
+		}
+		event.ID = message.ID
+		events = append(events, event)
+	}
+	return events
+}
+
+// nextStreamID returns the smallest stream ID after id.
+func nextStreamID(id string) string {
+	ms, seq, found := strings.Cut(id, "-")
+	if !found {
+		return id + "-1"
+	}
+	n, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil {
+		return id
+	}
+	return ms + "-" + strconv.FormatUint(n+1, 10)
+}
+
+// LastEventID returns the ID of the newest event, or "0" when none has been
+// published. Reading after it returns only events published since.
+func (q *RedisQueue) LastEventID(ctx context.Context) (string, error) {
+	messages, err := q.client.XRevRangeN(ctx, eventStreamKey, "+", "-", 1).Result()
+	if err != nil {
+		return "", fmt.Errorf("failed to read last event: %w", err)
+	}
+	if len(messages) == 0 {
+		return "0", nil
+	}
+	return messages[0].ID, nil
+}
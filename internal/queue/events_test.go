@@ -0,0 +1,28 @@
+package queue
+
+import (
+	"reflect"
+	"testing"
+
+	"flowctl/internal/core"
+)
+
+func TestAppendEventsArgsIndexEventsByWorkflow(t *testing.T) {
+	events := []*core.Event{
+		{Type: core.EventTaskQueued, WorkflowID: "wf-1"},
+		{Type: "broker.keys_evicted"},
+		{Type: core.EventWorkflowCompleted, WorkflowID: "wf-2"},
+	}
+	payloads := [][]byte{[]byte(`{"n":1}`), []byte(`{"n":2}`), []byte(`{"n":3}`)}
+
+	keys, args := appendEventsArgs(events, payloads)
+
+	wantKeys := []string{"events", "events:workflow:wf-1", "events", "events:workflow:wf-2"}
+	if !reflect.DeepEqual(keys, wantKeys) {
+		t.Errorf("keys = %v, want %v", keys, wantKeys)
+	}
+	wantArgs := []interface{}{eventStreamMaxLen, workflowEventStreamMaxLen, int64(7 * 24 * 60 * 60), payloads[0], payloads[1], payloads[2]}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("args = %v, want %v", args, wantArgs)
+	}
+}
@@ -0,0 +1,81 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"flowctl/internal/core"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Retries that come due wait in a retry lane beside their queue, so the
+// dequeue script can interleave them with new tasks under the type's
+// dispatch fairness policy. dispatchTurnsKey counts, per queue, the
+// dequeues where both had a task waiting, for the weighted mode.
+const (
+	dispatchFairnessPolicyKey = "dispatch_fairness_policies"
+	dispatchTurnsKey          = "dispatch_turns"
+)
+
+func retryLaneKey(taskType string) string {
+	return fmt.Sprintf("retry_lane:%s", taskType)
+}
+
+func poolRetryLaneKey(pool, taskType string) string {
+	return fmt.Sprintf("pool_retry_lane:%s:%s", pool, taskType)
+}
+
+func namespaceRetryLaneKey(namespace, taskType string) string {
+	return fmt.Sprintf("ns_retry_lane:%s:%s", namespace, taskType)
+}
+
+// taskRetryLaneKey is the retry lane beside the queue a task waits in.
+func taskRetryLaneKey(task *core.Task) string {
+	switch {
+	case task.Pool != "":
+		return taskLabeledKey(task, poolRetryLaneKey(task.Pool, task.Type))
+	case task.QueueNamespace != "":
+		return taskLabeledKey(task, namespaceRetryLaneKey(task.QueueNamespace, task.Type))
+	}
+	return taskLabeledKey(task, retryLaneKey(task.Type))
+}
+
+func (q *RedisQueue) SetDispatchFairnessPolicy(ctx context.Context, policy core.DispatchFairnessPolicy) error {
+	policyJSON, err := json.Marshal(policy)
+	if err != nil {
+		return fmt.Errorf("failed to serialize dispatch fairness policy: %w", err)
+	}
+
+	if err := q.client.HSet(ctx, dispatchFairnessPolicyKey, policy.TaskType, policyJSON).Err(); err != nil {
+		return fmt.Errorf("failed to set dispatch fairness policy: %w", err)
+	}
+
+	q.logger.Infof("Set dispatch fairness policy for task type %s: %s", policy.TaskType, policy.Mode)
+	return nil
+}
+
+func (q *RedisQueue) DeleteDispatchFairnessPolicy(ctx context.Context, taskType string) error {
+	if err := q.client.HDel(ctx, dispatchFairnessPolicyKey, taskType).Err(); err != nil {
+		return fmt.Errorf("failed to delete dispatch fairness policy: %w", err)
+	}
+	return nil
+}
+
+func (q *RedisQueue) GetDispatchFairnessPolicy(ctx context.Context, taskType string) (*core.DispatchFairnessPolicy, error) {
+	policyJSON, err := q.client.HGet(ctx, dispatchFairnessPolicyKey, taskType).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get dispatch fairness policy: %w", err)
+	}
+
+	var policy core.DispatchFairnessPolicy
+	if err := json.Unmarshal([]byte(policyJSON), &policy); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal dispatch fairness policy: %w", err)
+	}
+
+	return &policy, nil
+}
@@ -0,0 +1,53 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// idempotencyResultTTL bounds how long a completed execution is remembered.
+// The scheduler still finds older executions in Postgres.
+const idempotencyResultTTL = time.Hour * 24 * 7
+
+// idempotencyKey scopes an idempotency key to its namespace, so tasks of
+// different namespaces never share results.
+func idempotencyKey(namespace, key string) string {
+	return fmt.Sprintf("idempotency:%s:%s", namespace, key)
+}
+
+// SaveIdempotentResult records the result of a completed execution so a
+// redelivered task with the same key in the same namespace can return it
+// without running again.
+func (q *RedisQueue) SaveIdempotentResult(ctx context.Context, namespace, key string, result map[string]interface{}) error {
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to serialize result: %w", err)
+	}
+
+	if err := q.client.Set(ctx, idempotencyKey(namespace, key), resultJSON, idempotencyResultTTL).Err(); err != nil {
+		return fmt.Errorf("failed to save idempotent result: %w", err)
+	}
+	return nil
+}
+
+// GetIdempotentResult returns the stored result for a key in a namespace
+// and whether one was found.
+func (q *RedisQueue) GetIdempotentResult(ctx context.Context, namespace, key string) (map[string]interface{}, bool, error) {
+	resultJSON, err := q.client.Get(ctx, idempotencyKey(namespace, key)).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to get idempotent result: %w", err)
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal([]byte(resultJSON), &result); err != nil {
+		return nil, false, fmt.Errorf("failed to unmarshal idempotent result: %w", err)
+	}
+	return result, true, nil
+}
@@ -0,0 +1,193 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"flowctl/internal/core"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// injectedTasksKey maps the ID of every task injected into a queue to its
+// core.InjectedTask record.
+const injectedTasksKey = "injected_tasks"
+
+// PeekQueue returns up to count tasks from the head of a task type's queue
+// without dequeuing them, along with the queue's depth. With a pool or a
+// namespace it reads that pool's or dedicated namespace's queue; otherwise
+// it reads the shared queue, preceded by the legacy list that workers drain
+// first. Due retries in the queue's
+// retry lane are merged in by priority, the order the shared fairness mode
+// hands them out in.
+func (q *RedisQueue) PeekQueue(ctx context.Context, taskType, pool, namespace string, count int64) (*core.QueuePeek, error) {
+	queueKey, laneKey := priorityQueueKey(taskType), retryLaneKey(taskType)
+	switch {
+	case pool != "":
+		queueKey, laneKey = poolQueueKey(pool, taskType), poolRetryLaneKey(pool, taskType)
+	case namespace != "":
+		queueKey, laneKey = namespaceQueueKey(namespace, taskType), namespaceRetryLaneKey(namespace, taskType)
+	}
+
+	// The legacy list is popped from its tail, so its head for a worker is
+	// the end of the list.
+	pipe := q.client.TxPipeline()
+	var legacy *redis.StringSliceCmd
+	var legacyLen *redis.IntCmd
+	if pool == "" && namespace == "" {
+		legacy = pipe.LRange(ctx, legacyQueueKey(taskType), -count, -1)
+		legacyLen = pipe.LLen(ctx, legacyQueueKey(taskType))
+	}
+	queued := pipe.ZRangeWithScores(ctx, queueKey, 0, count-1)
+	queueLen := pipe.ZCard(ctx, queueKey)
+	lane := pipe.ZRangeWithScores(ctx, laneKey, 0, count-1)
+	laneLen := pipe.ZCard(ctx, laneKey)
+
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to peek at queue %s: %w", queueKey, err)
+	}
+
+	peek := &core.QueuePeek{
+		TaskType:  taskType,
+		Pool:      pool,
+		Namespace: namespace,
+		Depth:     queueLen.Val() + laneLen.Val(),
+		Entries:   []core.QueueEntry{},
+	}
+
+	add := func(queue, member string) {
+		if int64(len(peek.Entries)) >= count {
+			return
+		}
+		entry := core.QueueEntry{Position: len(peek.Entries), Queue: queue}
+		if task, err := core.TaskFromJSON([]byte(member)); err == nil {
+			entry.Task = task
+		} else {
+			entry.Raw = member
+		}
+		peek.Entries = append(peek.Entries, entry)
+	}
+
+	if legacy != nil {
+		peek.Depth += legacyLen.Val()
+		members := legacy.Val()
+		for i := len(members) - 1; i >= 0; i-- {
+			add(legacyQueueKey(taskType), members[i])
+		}
+	}
+	fresh, retries := queued.Val(), lane.Val()
+	for len(fresh) > 0 || len(retries) > 0 {
+		if len(retries) == 0 || (len(fresh) > 0 && fresh[0].Score <= retries[0].Score) {
+			add(queueKey, fresh[0].Member.(string))
+			fresh = fresh[1:]
+			continue
+		}
+		add(laneKey, retries[0].Member.(string))
+		retries = retries[1:]
+	}
+
+	return peek, nil
+}
+
+// InjectTask records an injected task and enqueues it like any other.
+func (q *RedisQueue) InjectTask(ctx context.Context, injected *core.InjectedTask) error {
+	record, err := json.Marshal(injected)
+	if err != nil {
+		return fmt.Errorf("failed to serialize injected task: %w", err)
+	}
+
+	if err := q.client.HSet(ctx, injectedTasksKey, injected.Task.ID, record).Err(); err != nil {
+		return fmt.Errorf("failed to record injected task: %w", err)
+	}
+
+	if err := q.EnqueueTask(ctx, &injected.Task); err != nil {
+		q.client.HDel(ctx, injectedTasksKey, injected.Task.ID)
+		return err
+	}
+	return nil
+}
+
+// GetInjectedTask returns the record of an injected task, or nil if there
+// is none.
+func (q *RedisQueue) GetInjectedTask(ctx context.Context, taskID string) (*core.InjectedTask, error) {
+	record, err := q.client.HGet(ctx, injectedTasksKey, taskID).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get injected task: %w", err)
+	}
+
+	var injected core.InjectedTask
+	if err := json.Unmarshal(record, &injected); err != nil {
+		return nil, fmt.Errorf("failed to decode injected task %s: %w", taskID, err)
+	}
+	return &injected, nil
+}
+
+// RecordInjectedTaskStatus stores the status a worker reported for an
+// injected task. It returns false without changing anything when taskID
+// was not injected.
+func (q *RedisQueue) RecordInjectedTaskStatus(ctx context.Context, taskID string, status core.TaskStatus, result map[string]interface{}, errorMsg string) (bool, error) {
+	injected, err := q.GetInjectedTask(ctx, taskID)
+	if err != nil || injected == nil {
+		return false, err
+	}
+
+	now := time.Now()
+	injected.Status = status
+	injected.Result = result
+	injected.Error = errorMsg
+	injected.UpdatedAt = &now
+
+	record, err := json.Marshal(injected)
+	if err != nil {
+		return true, fmt.Errorf("failed to serialize injected task: %w", err)
+	}
+	if err := q.client.HSet(ctx, injectedTasksKey, taskID, record).Err(); err != nil {
+		return true, fmt.Errorf("failed to record injected task status: %w", err)
+	}
+	return true, nil
+}
+
+// InjectedTaskIDs returns the IDs of every injected task still on record.
+func (q *RedisQueue) InjectedTaskIDs(ctx context.Context) (map[string]bool, error) {
+	ids, err := q.client.HKeys(ctx, injectedTasksKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list injected tasks: %w", err)
+	}
+
+	injected := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		injected[id] = true
+	}
+	return injected, nil
+}
+
+// PruneInjectedTasks drops the records of tasks injected before cutoff and
+// returns how many were removed. Records that cannot be decoded are
+// dropped as well.
+func (q *RedisQueue) PruneInjectedTasks(ctx context.Context, cutoff time.Time) (int, error) {
+	records, err := q.client.HGetAll(ctx, injectedTasksKey).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read injected tasks: %w", err)
+	}
+
+	var stale []string
+	for id, record := range records {
+		var injected core.InjectedTask
+		if json.Unmarshal([]byte(record), &injected) != nil || injected.InjectedAt.Before(cutoff) {
+			stale = append(stale, id)
+		}
+	}
+	if len(stale) == 0 {
+		return 0, nil
+	}
+
+	if err := q.client.HDel(ctx, injectedTasksKey, stale...).Err(); err != nil {
+		return 0, fmt.Errorf("failed to prune injected tasks: %w", err)
+	}
+	return len(stale), nil
+}
@@ -0,0 +1,333 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+
+	"flowctl/internal/core"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// PruneWorkerSets removes workers whose registration has expired from the
+// workers:<type> sets. Workers that still hold claims are left for
+// ExpireStaleWorkers, which requeues their tasks first.
+func (q *RedisQueue) PruneWorkerSets(ctx context.Context) (int, error) {
+	setKeys, err := q.scanKeys(ctx, "workers:*")
+	if err != nil {
+		return 0, err
+	}
+
+	pruned := 0
+	for _, setKey := range setKeys {
+		workerIDs, err := q.client.SMembers(ctx, setKey).Result()
+		if err != nil {
+			return pruned, fmt.Errorf("failed to get worker IDs: %w", err)
+		}
+
+		for _, workerID := range workerIDs {
+			registered, err := q.client.Exists(ctx, fmt.Sprintf("worker:%s", workerID), workerTasksKey(workerID)).Result()
+			if err != nil {
+				return pruned, fmt.Errorf("failed to check worker %s: %w", workerID, err)
+			}
+			if registered > 0 {
+				continue
+			}
+
+			if err := q.client.SRem(ctx, setKey, workerID).Err(); err != nil {
+				return pruned, fmt.Errorf("failed to remove worker %s: %w", workerID, err)
+			}
+			pruned++
+		}
+	}
+
+	return pruned, nil
+}
+
+// PrunePools forgets worker pools that have no queued tasks and no
+// registered workers.
+func (q *RedisQueue) PrunePools(ctx context.Context) (int, error) {
+	pools, err := q.client.SMembers(ctx, poolsKey).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get worker pools: %w", err)
+	}
+	if len(pools) == 0 {
+		return 0, nil
+	}
+
+	workers, err := q.ListWorkers(ctx)
+	if err != nil {
+		return 0, err
+	}
+	inUse := make(map[string]bool)
+	for _, worker := range workers {
+		inUse[worker.Pool] = true
+	}
+
+	pruned := 0
+	for _, pool := range pools {
+		if inUse[pool] {
+			continue
+		}
+
+		queued, err := q.poolHasTasks(ctx, pool)
+		if err != nil {
+			return pruned, err
+		}
+		if queued {
+			continue
+		}
+
+		if err := q.client.SRem(ctx, poolsKey, pool).Err(); err != nil {
+			return pruned, fmt.Errorf("failed to remove worker pool %s: %w", pool, err)
+		}
+
+		// A task may have been queued for the pool since it was checked.
+		if queued, err := q.poolHasTasks(ctx, pool); err != nil || queued {
+			q.client.SAdd(ctx, poolsKey, pool)
+			continue
+		}
+		pruned++
+	}
+
+	return pruned, nil
+}
+
+// poolHasTasks reports whether any queue of the pool, its retry lane or a
+// labeled sub-queue of either holds a task.
+func (q *RedisQueue) poolHasTasks(ctx context.Context, pool string) (bool, error) {
+	queue, lane := poolQueueKey(pool, "*"), poolRetryLaneKey(pool, "*")
+	for _, pattern := range []string{queue, lane, labeledQueueKey("*", queue), labeledQueueKey("*", lane)} {
+		keys, err := q.scanKeys(ctx, pattern)
+		if err != nil {
+			return false, err
+		}
+		if len(keys) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// PruneNamespaces stops dedicating namespaces and task types no live
+// worker registered for the namespace runs. Their queued tasks go back to
+// the shared queues, and a namespace is forgotten once it has no queued
+// tasks and no registered workers.
+func (q *RedisQueue) PruneNamespaces(ctx context.Context) (int, error) {
+	namespaces, err := q.client.SMembers(ctx, dedicatedNamespacesKey).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get dedicated namespaces: %w", err)
+	}
+	if len(namespaces) == 0 {
+		return 0, nil
+	}
+
+	workers, err := q.ListWorkers(ctx)
+	if err != nil {
+		return 0, err
+	}
+	inUse := make(map[string]bool)
+	live := make(map[string]bool)
+	for _, worker := range workers {
+		for _, namespace := range worker.Namespaces {
+			inUse[namespace] = true
+			if worker.Status == core.WorkerStatusStale {
+				continue
+			}
+			for _, taskType := range worker.TaskTypes {
+				live[dedicationMember(namespace, taskType)] = true
+			}
+		}
+	}
+
+	if err := q.pruneDedicatedTypes(ctx, live); err != nil {
+		return 0, err
+	}
+
+	pruned := 0
+	for _, namespace := range namespaces {
+		released, err := q.releaseNamespaceQueues(ctx, namespace, live)
+		if released > 0 {
+			q.logger.Warnf("Moved %d tasks of namespace %s that no live worker runs back to the shared queues", released, namespace)
+		}
+		if err != nil {
+			return pruned, err
+		}
+
+		if inUse[namespace] {
+			continue
+		}
+
+		queued, err := q.namespaceHasTasks(ctx, namespace)
+		if err != nil {
+			return pruned, err
+		}
+		if queued {
+			continue
+		}
+
+		if err := q.client.SRem(ctx, dedicatedNamespacesKey, namespace).Err(); err != nil {
+			return pruned, fmt.Errorf("failed to remove dedicated namespace %s: %w", namespace, err)
+		}
+
+		// A task may have been queued for the namespace since it was checked.
+		if queued, err := q.namespaceHasTasks(ctx, namespace); err != nil || queued {
+			q.client.SAdd(ctx, dedicatedNamespacesKey, namespace)
+			continue
+		}
+		pruned++
+	}
+
+	return pruned, nil
+}
+
+// pruneDedicatedTypes stops queueing tasks of the namespace and task type
+// pairs not in live for their namespaces' workers.
+func (q *RedisQueue) pruneDedicatedTypes(ctx context.Context, live map[string]bool) error {
+	pairs, err := q.client.SMembers(ctx, dedicatedTypesKey).Result()
+	if err != nil {
+		return fmt.Errorf("failed to get dedicated namespaces: %w", err)
+	}
+
+	var dead []interface{}
+	for _, pair := range pairs {
+		if !live[pair] {
+			dead = append(dead, pair)
+		}
+	}
+	if len(dead) == 0 {
+		return nil
+	}
+	if err := q.client.SRem(ctx, dedicatedTypesKey, dead...).Err(); err != nil {
+		return fmt.Errorf("failed to remove dedicated namespace task types: %w", err)
+	}
+	return nil
+}
+
+// namespaceHasTasks reports whether any queue of the namespace, its retry
+// lane or a labeled sub-queue of either holds a task.
+func (q *RedisQueue) namespaceHasTasks(ctx context.Context, namespace string) (bool, error) {
+	queue, lane := namespaceQueueKey(namespace, "*"), namespaceRetryLaneKey(namespace, "*")
+	for _, pattern := range []string{queue, lane, labeledQueueKey("*", queue), labeledQueueKey("*", lane)} {
+		keys, err := q.scanKeys(ctx, pattern)
+		if err != nil {
+			return false, err
+		}
+		if len(keys) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// PruneSelectors forgets task selectors that no labeled queue holds a task
+// for, so labeled workers stop polling those queues.
+func (q *RedisQueue) PruneSelectors(ctx context.Context) (int, error) {
+	selectors, err := q.client.SMembers(ctx, selectorsKey).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get task selectors: %w", err)
+	}
+
+	pruned := 0
+	for _, selector := range selectors {
+		queued, err := q.selectorHasTasks(ctx, selector)
+		if err != nil {
+			return pruned, err
+		}
+		if queued {
+			continue
+		}
+
+		if err := q.client.SRem(ctx, selectorsKey, selector).Err(); err != nil {
+			return pruned, fmt.Errorf("failed to remove task selector %s: %w", selector, err)
+		}
+
+		// A task may have been queued with the selector since it was checked.
+		if queued, err := q.selectorHasTasks(ctx, selector); err != nil || queued {
+			q.client.SAdd(ctx, selectorsKey, selector)
+			continue
+		}
+		pruned++
+	}
+
+	return pruned, nil
+}
+
+// selectorHasTasks reports whether any labeled queue of the selector, or
+// its retry lane, holds a task.
+func (q *RedisQueue) selectorHasTasks(ctx context.Context, selector string) (bool, error) {
+	keys, err := q.scanKeys(ctx, labeledQueueKey(selector, "*"))
+	if err != nil {
+		return false, err
+	}
+	return len(keys) > 0, nil
+}
+
+// HeldTasks returns every task Redis holds, of any type: waiting in a
+// queue, leased to a worker, waiting to retry or for its run time,
+// quarantined or dead-lettered. A task can appear more than once.
+func (q *RedisQueue) HeldTasks(ctx context.Context) ([]core.Task, error) {
+	var sortedSets, lists []string
+	for _, pattern := range []string{priorityQueueKey("*"), poolQueueKey("*", "*"), retryLaneKey("*"), poolRetryLaneKey("*", "*"), namespaceQueueKey("*", "*"), namespaceRetryLaneKey("*", "*"), labeledQueueKey("*", "*"), "retry:*", delayedKey("*")} {
+		keys, err := q.scanKeys(ctx, pattern)
+		if err != nil {
+			return nil, err
+		}
+		sortedSets = append(sortedSets, keys...)
+	}
+	for _, pattern := range []string{legacyQueueKey("*"), poisonKey("*"), "dead_letter:*"} {
+		keys, err := q.scanKeys(ctx, pattern)
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, keys...)
+	}
+
+	pipe := q.client.Pipeline()
+	reads := make([]*redis.StringSliceCmd, 0, len(sortedSets)+len(lists)+1)
+	for _, key := range sortedSets {
+		reads = append(reads, pipe.ZRange(ctx, key, 0, -1))
+	}
+	for _, key := range lists {
+		reads = append(reads, pipe.LRange(ctx, key, 0, -1))
+	}
+	reads = append(reads, pipe.HVals(ctx, leasesKey))
+
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to read queues: %w", err)
+	}
+
+	var tasks []core.Task
+	for _, read := range reads {
+		for _, member := range read.Val() {
+			task, err := core.TaskFromJSON([]byte(member))
+			if err != nil {
+				// Malformed entries are quarantined when dequeued.
+				continue
+			}
+			tasks = append(tasks, *task)
+		}
+	}
+
+	return tasks, nil
+}
+
+// DeliveryCountTaskIDs returns the IDs of the tasks with a delivery count.
+func (q *RedisQueue) DeliveryCountTaskIDs(ctx context.Context) ([]string, error) {
+	taskIDs, err := q.client.HKeys(ctx, deliveriesKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get delivery counts: %w", err)
+	}
+	return taskIDs, nil
+}
+
+// ClearDeliveryCounts drops the delivery counts of tasks.
+func (q *RedisQueue) ClearDeliveryCounts(ctx context.Context, taskIDs []string) error {
+	if len(taskIDs) == 0 {
+		return nil
+	}
+	if err := q.client.HDel(ctx, deliveriesKey, taskIDs...).Err(); err != nil {
+		return fmt.Errorf("failed to clear delivery counts: %w", err)
+	}
+	return nil
+}
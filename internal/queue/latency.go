@@ -0,0 +1,209 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"flowctl/internal/core"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const latencySLOKey = "latency_slos"
+
+var latencyBuckets = []time.Duration{
+	time.Second,
+	time.Second * 5,
+	time.Second * 10,
+	time.Second * 30,
+	time.Minute,
+	time.Minute * 5,
+	time.Minute * 15,
+	time.Hour,
+}
+
+var burnRateWindows = []time.Duration{time.Minute * 5, time.Hour}
+
+func (q *RedisQueue) recordQueueLatency(ctx context.Context, taskType string, wait time.Duration) {
+	histogramKey := fmt.Sprintf("latency:%s", taskType)
+	windowKey := fmt.Sprintf("latency_window:%s:%d", taskType, time.Now().Unix()/60)
+
+	bucket := "inf"
+	for _, bound := range latencyBuckets {
+		if wait <= bound {
+			bucket = strconv.FormatInt(bound.Milliseconds(), 10)
+			break
+		}
+	}
+
+	pipe := q.client.Pipeline()
+	pipe.HIncrBy(ctx, histogramKey, bucket, 1)
+	pipe.HIncrBy(ctx, histogramKey, "count", 1)
+	pipe.HIncrBy(ctx, histogramKey, "sum_ms", wait.Milliseconds())
+	pipe.HIncrBy(ctx, windowKey, "total", 1)
+	if slo := q.latencySLO(ctx, taskType); slo != nil && wait > slo.Threshold {
+		pipe.HIncrBy(ctx, windowKey, "breached", 1)
+	}
+	pipe.Expire(ctx, windowKey, burnRateWindows[len(burnRateWindows)-1]+time.Minute*2)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		q.logger.Errorf("Failed to record queue latency for task type %s: %v", taskType, err)
+	}
+}
+
+func (q *RedisQueue) latencySLO(ctx context.Context, taskType string) *core.LatencySLO {
+	q.sloMu.Lock()
+	defer q.sloMu.Unlock()
+
+	if time.Since(q.sloLoadedAt) > time.Second*30 {
+		slos, err := q.GetLatencySLOs(ctx)
+		if err != nil {
+			q.logger.Errorf("Failed to load latency SLOs: %v", err)
+		} else {
+			q.sloCache = make(map[string]core.LatencySLO, len(slos))
+			for _, slo := range slos {
+				q.sloCache[slo.TaskType] = slo
+			}
+			q.sloLoadedAt = time.Now()
+		}
+	}
+
+	slo, ok := q.sloCache[taskType]
+	if !ok {
+		return nil
+	}
+	return &slo
+}
+
+func (q *RedisQueue) SetLatencySLO(ctx context.Context, slo core.LatencySLO) error {
+	sloJSON, err := json.Marshal(slo)
+	if err != nil {
+		return fmt.Errorf("failed to serialize latency SLO: %w", err)
+	}
+
+	if err := q.client.HSet(ctx, latencySLOKey, slo.TaskType, sloJSON).Err(); err != nil {
+		return fmt.Errorf("failed to set latency SLO: %w", err)
+	}
+
+	q.sloMu.Lock()
+	q.sloLoadedAt = time.Time{}
+	q.sloMu.Unlock()
+
+	q.logger.Infof("Set queue latency SLO for task type %s: %s at %.4f", slo.TaskType, slo.Threshold, slo.Objective)
+	return nil
+}
+
+func (q *RedisQueue) DeleteLatencySLO(ctx context.Context, taskType string) error {
+	if err := q.client.HDel(ctx, latencySLOKey, taskType).Err(); err != nil {
+		return fmt.Errorf("failed to delete latency SLO: %w", err)
+	}
+
+	q.sloMu.Lock()
+	q.sloLoadedAt = time.Time{}
+	q.sloMu.Unlock()
+
+	return nil
+}
+
+func (q *RedisQueue) GetLatencySLOs(ctx context.Context) ([]core.LatencySLO, error) {
+	entries, err := q.client.HGetAll(ctx, latencySLOKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latency SLOs: %w", err)
+	}
+
+	slos := []core.LatencySLO{}
+	for taskType, sloJSON := range entries {
+		var slo core.LatencySLO
+		if err := json.Unmarshal([]byte(sloJSON), &slo); err != nil {
+			q.logger.Errorf("Failed to unmarshal latency SLO for task type %s: %v", taskType, err)
+			continue
+		}
+		slos = append(slos, slo)
+	}
+
+	return slos, nil
+}
+
+func (q *RedisQueue) GetQueueLatencyStats(ctx context.Context, taskType string) (*core.QueueLatencyStats, error) {
+	histogramKey := fmt.Sprintf("latency:%s", taskType)
+
+	histogram, err := q.client.HGetAll(ctx, histogramKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get queue latency histogram: %w", err)
+	}
+
+	stats := &core.QueueLatencyStats{
+		TaskType: taskType,
+		Buckets:  make([]core.LatencyBucket, 0, len(latencyBuckets)),
+	}
+
+	stats.Count, _ = strconv.ParseInt(histogram["count"], 10, 64)
+	sumMs, _ := strconv.ParseInt(histogram["sum_ms"], 10, 64)
+	if stats.Count > 0 {
+		stats.Mean = time.Duration(sumMs/stats.Count) * time.Millisecond
+	}
+
+	var cumulative int64
+	for _, bound := range latencyBuckets {
+		count, _ := strconv.ParseInt(histogram[strconv.FormatInt(bound.Milliseconds(), 10)], 10, 64)
+		cumulative += count
+		stats.Buckets = append(stats.Buckets, core.LatencyBucket{UpperBound: bound, Count: cumulative})
+	}
+
+	slo := q.latencySLO(ctx, taskType)
+	if slo == nil {
+		return stats, nil
+	}
+	stats.SLO = slo
+
+	for _, window := range burnRateWindows {
+		burnRate, err := q.burnRate(ctx, taskType, slo, window)
+		if err != nil {
+			return nil, err
+		}
+		stats.BurnRates = append(stats.BurnRates, *burnRate)
+	}
+
+	return stats, nil
+}
+
+func (q *RedisQueue) burnRate(ctx context.Context, taskType string, slo *core.LatencySLO, window time.Duration) (*core.BurnRate, error) {
+	currentMinute := time.Now().Unix() / 60
+	minutes := int64(window / time.Minute)
+
+	pipe := q.client.Pipeline()
+	var windows []*redis.SliceCmd
+	for i := int64(0); i < minutes; i++ {
+		windowKey := fmt.Sprintf("latency_window:%s:%d", taskType, currentMinute-i)
+		windows = append(windows, pipe.HMGet(ctx, windowKey, "total", "breached"))
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to get queue latency windows: %w", err)
+	}
+
+	burnRate := &core.BurnRate{Window: window}
+	for _, cmd := range windows {
+		values := cmd.Val()
+		burnRate.Total += parseCount(values[0])
+		burnRate.Breached += parseCount(values[1])
+	}
+
+	if burnRate.Total > 0 && slo.ErrorBudget() > 0 {
+		burnRate.Rate = (float64(burnRate.Breached) / float64(burnRate.Total)) / slo.ErrorBudget()
+	}
+
+	return burnRate, nil
+}
+
+func parseCount(value interface{}) int64 {
+	s, ok := value.(string)
+	if !ok {
+		return 0
+	}
+	count, _ := strconv.ParseInt(s, 10, 64)
+	return count
+}
@@ -0,0 +1,64 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const leaderKey = "scheduler_leader"
+
+// acquireLeaderScript takes the lease when it is free and extends it when the
+// caller already holds it, so acquisition and renewal are a single atomic step.
+var acquireLeaderScript = redis.NewScript(`
+local current = redis.call("GET", KEYS[1])
+if not current then
+	redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+	return 1
+end
+if current == ARGV[1] then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+	return 1
+end
+return 0
+`)
+
+var releaseLeaderScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+func (q *RedisQueue) AcquireLeadership(ctx context.Context, instanceID string, ttl time.Duration) (bool, error) {
+	acquired, err := acquireLeaderScript.Run(ctx, q.client, []string{leaderKey}, instanceID, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire leadership: %w", err)
+	}
+	return acquired == 1, nil
+}
+
+func (q *RedisQueue) ReleaseLeadership(ctx context.Context, instanceID string) error {
+	if err := releaseLeaderScript.Run(ctx, q.client, []string{leaderKey}, instanceID).Err(); err != nil {
+		return fmt.Errorf("failed to release leadership: %w", err)
+	}
+	return nil
+}
+
+func (q *RedisQueue) GetLeader(ctx context.Context) (string, time.Duration, error) {
+	pipe := q.client.Pipeline()
+	leader := pipe.Get(ctx, leaderKey)
+	ttl := pipe.PTTL(ctx, leaderKey)
+
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return "", 0, fmt.Errorf("failed to get leader: %w", err)
+	}
+
+	if leader.Err() == redis.Nil {
+		return "", 0, nil
+	}
+
+	return leader.Val(), ttl.Val(), nil
+}
@@ -0,0 +1,372 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"flowctl/internal/core"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+// A dequeued task is held under a lease: leases maps the receipt handle
+// returned by DequeueTask to the task's JSON as it was dequeued. Ack, nack
+// and requeue look the task up by its receipt, so they keep working after
+// the worker's copy of the task has changed.
+const (
+	leasesKey           = "leases"
+	dequeuePollInterval = time.Millisecond * 200
+)
+
+// dequeueScript pops the next task of a type, from the legacy list first
+// unless ARGV[5] is "1", as it is for pooled workers, and leases it under
+// ARGV[1] in the same step, recording when the lease was taken and that
+// worker ARGV[3] holds it in its claims KEYS[13]. Between the queue KEYS[2]
+// and its retry lane KEYS[8] it picks by the fairness policy of type
+// ARGV[6] in KEYS[9], counting weighted turns in KEYS[10]. It also counts
+// the delivery; a task delivered more than ARGV[4] times without being
+// acked or nacked, or one that is not valid JSON, is moved to the poison
+// queue KEYS[7] instead. While the type's rate limit in KEYS[11] is
+// enforced at dequeue and its window KEYS[12] is full at ARGV[7]
+// milliseconds, nothing is dequeued; otherwise the lease is logged in the
+// window. A worker with a CPU capacity ARGV[8] or memory capacity ARGV[9]
+// only takes a task whose resources fit beside those of the tasks it holds.
+// A task that does not fit goes back where it was, and the next ARGV[10]
+// tasks behind it in the same queue are tried in turn, so a large task does
+// not hold back the smaller ones behind it; if none fits, nothing is
+// dequeued. Every key it touches is passed in KEYS, as dequeueKeys lists
+// them. It returns the task's JSON, its delivery count and 1 if it was
+// quarantined.
+var dequeueScript = redis.NewScript(`
+local usedCPU, usedMemory = false, false
+local function fits(member)
+	local cpu, memory = tonumber(ARGV[8]) or 0, tonumber(ARGV[9]) or 0
+	if cpu <= 0 and memory <= 0 then
+		return true
+	end
+	local ok, task = pcall(cjson.decode, member)
+	if not ok or type(task) ~= "table" or type(task.resources) ~= "table" then
+		return true
+	end
+	local request = task.resources
+	if not usedCPU then
+		usedCPU, usedMemory = 0, 0
+		for _, receipt in ipairs(redis.call("HVALS", KEYS[13])) do
+			local held = redis.call("HGET", KEYS[3], receipt)
+			if held then
+				local heldOK, heldTask = pcall(cjson.decode, held)
+				if heldOK and type(heldTask) == "table" and type(heldTask.resources) == "table" then
+					usedCPU = usedCPU + (tonumber(heldTask.resources.cpu) or 0)
+					usedMemory = usedMemory + (tonumber(heldTask.resources.memory_mb) or 0)
+				end
+			end
+		end
+	end
+	if cpu > 0 and usedCPU + (tonumber(request.cpu) or 0) > cpu + 1e-9 then
+		return false
+	end
+	if memory > 0 and usedMemory + (tonumber(request.memory_mb) or 0) > memory then
+		return false
+	end
+	return true
+end
+local window = false
+local limit = redis.call("HGET", KEYS[11], ARGV[6])
+if limit then
+	local ok, decoded = pcall(cjson.decode, limit)
+	if ok and type(decoded) == "table" and decoded.enforce_at == "dequeue" and tonumber(decoded.limit) and tonumber(decoded.interval) then
+		window = math.ceil(tonumber(decoded.interval) / 1000000)
+		redis.call("ZREMRANGEBYSCORE", KEYS[12], "-inf", tonumber(ARGV[7]) - window)
+		if redis.call("ZCARD", KEYS[12]) >= tonumber(decoded.limit) then
+			return false
+		end
+	end
+end
+local member, from, score = false, KEYS[1], false
+if ARGV[5] ~= "1" then
+	member = redis.call("RPOP", KEYS[1])
+	if member and ARGV[3] ~= "" and not fits(member) then
+		redis.call("RPUSH", KEYS[1], member)
+		member = false
+	end
+end
+if not member then
+	local fresh = redis.call("ZRANGE", KEYS[2], 0, 0, "WITHSCORES")
+	local retry = redis.call("ZRANGE", KEYS[8], 0, 0, "WITHSCORES")
+	if #fresh == 0 and #retry == 0 then
+		return false
+	end
+	from = KEYS[2]
+	if #fresh == 0 then
+		from = KEYS[8]
+	elseif #retry > 0 then
+		local mode, share = "shared", 0
+		local policy = redis.call("HGET", KEYS[9], ARGV[6])
+		if policy then
+			local ok, decoded = pcall(cjson.decode, policy)
+			if ok and type(decoded) == "table" then
+				mode = decoded.mode or mode
+				share = tonumber(decoded.retry_share) or 0
+			end
+		end
+		if mode == "weighted" then
+			local turn = redis.call("HINCRBY", KEYS[10], KEYS[2], 1)
+			if math.floor(turn * share / 100) > math.floor((turn - 1) * share / 100) then
+				from = KEYS[8]
+			end
+		elseif mode ~= "fresh_first" and tonumber(retry[2]) < tonumber(fresh[2]) then
+			from = KEYS[8]
+		end
+	end
+	local popped = redis.call("ZPOPMIN", from)
+	member, score = popped[1], popped[2]
+	if ARGV[3] ~= "" and not fits(member) then
+		redis.call("ZADD", from, score, member)
+		member = false
+		local behind = redis.call("ZRANGE", from, 1, tonumber(ARGV[10]), "WITHSCORES")
+		for i = 1, #behind, 2 do
+			if fits(behind[i]) then
+				member, score = behind[i], behind[i + 1]
+				redis.call("ZREM", from, member)
+				break
+			end
+		end
+		if not member then
+			return false
+		end
+	end
+end
+local ok, task = pcall(cjson.decode, member)
+if not ok or type(task) ~= "table" or not task.id then
+	redis.call("LPUSH", KEYS[7], member)
+	return {member, 0, 1}
+end
+local deliveries = redis.call("HINCRBY", KEYS[6], task.id, 1)
+if tonumber(ARGV[4]) > 0 and deliveries > tonumber(ARGV[4]) then
+	redis.call("HDEL", KEYS[6], task.id)
+	redis.call("LPUSH", KEYS[7], member)
+	return {member, deliveries, 1}
+end
+redis.call("HSET", KEYS[3], ARGV[1], member)
+redis.call("ZADD", KEYS[4], ARGV[2], ARGV[1])
+if ARGV[3] ~= "" then
+	redis.call("HSET", KEYS[13], task.id, ARGV[1])
+	redis.call("HSET", KEYS[5], task.id, ARGV[3])
+end
+if window then
+	redis.call("ZADD", KEYS[12], ARGV[7], ARGV[1])
+	redis.call("PEXPIRE", KEYS[12], window)
+end
+return {member, deliveries, 0}
+`)
+
+// settleLeaseScript ends a lease and, depending on ARGV[4], adds the task
+// to a sorted set ("zadd") or a list ("lpush"). The worker's claim is only
+// dropped if it still belongs to this lease, since the task may have been
+// requeued and leased again since. When ARGV[7] is "1" the worker handled
+// the delivery, so the task's delivery count starts over. It returns 0
+// without changing anything when the lease no longer exists.
+var settleLeaseScript = redis.NewScript(`
+if redis.call("HDEL", KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+redis.call("ZREM", KEYS[2], ARGV[1])
+if ARGV[7] == "1" then
+	redis.call("HDEL", KEYS[5], ARGV[2])
+end
+local owner = redis.call("HGET", KEYS[3], ARGV[2])
+if owner and redis.call("HGET", ARGV[3] .. owner, ARGV[2]) == ARGV[1] then
+	redis.call("HDEL", ARGV[3] .. owner, ARGV[2])
+	redis.call("HDEL", KEYS[3], ARGV[2])
+end
+if ARGV[4] == "zadd" then
+	redis.call("ZADD", KEYS[4], ARGV[6], ARGV[5])
+elseif ARGV[4] == "lpush" then
+	redis.call("LPUSH", KEYS[4], ARGV[5])
+end
+return 1
+`)
+
+// dequeueKeys returns the KEYS of dequeueScript for a worker polling a
+// queue and its retry lane for tasks of a type.
+func dequeueKeys(taskType string, queue [2]string, workerID string) []string {
+	return []string{
+		legacyQueueKey(taskType),
+		queue[0],
+		leasesKey,
+		visibilityKey(taskType),
+		taskOwnersKey,
+		deliveriesKey,
+		poisonKey(taskType),
+		queue[1],
+		dispatchFairnessPolicyKey,
+		dispatchTurnsKey,
+		taskRateLimitsKey,
+		rateWindowKey(taskType),
+		workerTasksKey(workerID),
+	}
+}
+
+// leasedTask is the outcome of one dequeue attempt.
+type leasedTask struct {
+	member      string
+	receipt     string
+	deliveries  int64
+	quarantined bool
+}
+
+// leaseTask polls for the next task of a type until one is available or
+// timeout passes.
+func (q *RedisQueue) leaseTask(ctx context.Context, workerID, taskType string, timeout time.Duration) (*leasedTask, error) {
+	// A pooled worker only takes tasks pinned to its pool, and a worker
+	// registered for namespaces only tasks of those namespaces.
+	queues := [][2]string{{priorityQueueKey(taskType), retryLaneKey(taskType)}}
+	skipLegacy := "0"
+	switch {
+	case q.pool != "":
+		queues = [][2]string{{poolQueueKey(q.pool, taskType), poolRetryLaneKey(q.pool, taskType)}}
+		skipLegacy = "1"
+	case len(q.namespaces) > 0:
+		// Each poll starts at the next namespace, so a busy one cannot
+		// starve the others.
+		start := int(atomic.AddUint32(&q.namespaceTurn, 1))
+		queues = make([][2]string, len(q.namespaces))
+		for i := range q.namespaces {
+			namespace := q.namespaces[(start+i)%len(q.namespaces)]
+			queues[i] = [2]string{namespaceQueueKey(namespace, taskType), namespaceRetryLaneKey(namespace, taskType)}
+		}
+		skipLegacy = "1"
+	}
+
+	// A labeled worker also takes tasks whose selector its labels match,
+	// before those any worker may take.
+	selectors, err := q.matchingSelectors(ctx)
+	if err != nil {
+		return nil, err
+	}
+	queues = append(labeledQueues(queues, selectors), queues...)
+
+	cpu, memory := q.capacityArgs()
+	deadline := time.Now().Add(timeout)
+
+	for {
+		for _, queue := range queues {
+			keys := dequeueKeys(taskType, queue, workerID)
+			receipt := uuid.New().String()
+			now := time.Now()
+			result, err := dequeueScript.Run(ctx, q.client, keys, receipt, now.Unix(), workerID, q.maxDeliveries, skipLegacy, taskType, now.UnixMilli(), cpu, memory, capacityScanLimit).Slice()
+			if err == nil {
+				if len(result) != 3 {
+					return nil, fmt.Errorf("unexpected dequeue result %v", result)
+				}
+				member, _ := result[0].(string)
+				deliveries, _ := result[1].(int64)
+				quarantined, _ := result[2].(int64)
+				return &leasedTask{
+					member:      member,
+					receipt:     receipt,
+					deliveries:  deliveries,
+					quarantined: quarantined == 1,
+				}, nil
+			}
+			if err != redis.Nil {
+				return nil, err
+			}
+		}
+		if !time.Now().Before(deadline) {
+			return nil, redis.Nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, redis.Nil
+		case <-time.After(dequeuePollInterval):
+		}
+	}
+}
+
+// settleLease ends the lease of a task of type taskType. destKey, when set,
+// receives member: as a sorted set member with score if score is non-nil,
+// otherwise pushed onto a list. handled is set when the worker acked or
+// nacked the task, rather than the lease being taken back from it. It
+// returns core.ErrLeaseExpired if the lease had already ended.
+func (q *RedisQueue) settleLease(ctx context.Context, taskType, taskID, receipt, destKey, member string, score *float64, handled bool) error {
+	op, scoreArg := "", "0"
+	switch {
+	case destKey != "" && score != nil:
+		op, scoreArg = "zadd", fmt.Sprintf("%f", *score)
+	case destKey != "":
+		op = "lpush"
+	default:
+		destKey = leasesKey
+	}
+
+	handledArg := "0"
+	if handled {
+		handledArg = "1"
+	}
+
+	keys := []string{leasesKey, visibilityKey(taskType), taskOwnersKey, destKey, deliveriesKey}
+	settled, err := settleLeaseScript.Run(ctx, q.client, keys, receipt, taskID, workerTasksPrefix, op, member, scoreArg, handledArg).Int()
+	if err != nil {
+		return err
+	}
+	if settled == 0 {
+		return core.ErrLeaseExpired
+	}
+	return nil
+}
+
+// requeueLease puts a leased task back on its queue, applying update, if
+// given, to the copy that is requeued. It returns nil when the lease had
+// already ended.
+func (q *RedisQueue) requeueLease(ctx context.Context, taskType, receipt string, update func(*core.Task)) (*core.Task, error) {
+	member, err := q.client.HGet(ctx, leasesKey, receipt).Result()
+	if err == redis.Nil {
+		q.client.ZRem(ctx, visibilityKey(taskType), receipt)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lease %s: %w", receipt, err)
+	}
+
+	task, err := core.TaskFromJSON([]byte(member))
+	if err != nil {
+		q.settleLease(ctx, taskType, "", receipt, poisonKey(taskType), member, nil, false)
+		return nil, fmt.Errorf("failed to deserialize task: %w", err)
+	}
+
+	if update != nil {
+		update(task)
+	}
+
+	enqueuedAt := time.Now()
+	task.EnqueuedAt = &enqueuedAt
+
+	taskJSON, err := task.ToJSON()
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize task: %w", err)
+	}
+
+	// The selector may have been pruned while the task was leased.
+	if len(task.Selector) > 0 {
+		if err := q.client.SAdd(ctx, selectorsKey, core.FormatSelector(task.Selector)).Err(); err != nil {
+			return nil, fmt.Errorf("failed to record task selector: %w", err)
+		}
+	}
+
+	score := priorityScore(task)
+	err = q.settleLease(ctx, task.Type, task.ID, receipt, taskQueueKey(task), string(taskJSON), &score, false)
+	if err == core.ErrLeaseExpired {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	q.nudgeWorkers(ctx, task)
+	return task, nil
+}
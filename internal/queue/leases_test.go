@@ -0,0 +1,80 @@
+package queue
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// scriptSource returns the Lua source of the script assigned to name in
+// file.
+func scriptSource(t *testing.T, file, name string) string {
+	t.Helper()
+	f, err := parser.ParseFile(token.NewFileSet(), file, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var source string
+	ast.Inspect(f, func(n ast.Node) bool {
+		spec, ok := n.(*ast.ValueSpec)
+		if !ok || len(spec.Names) != 1 || spec.Names[0].Name != name || len(spec.Values) != 1 {
+			return true
+		}
+		call, ok := spec.Values[0].(*ast.CallExpr)
+		if !ok || len(call.Args) != 1 {
+			return true
+		}
+		if lit, ok := call.Args[0].(*ast.BasicLit); ok {
+			source, _ = strconv.Unquote(lit.Value)
+		}
+		return false
+	})
+	if source == "" {
+		t.Fatalf("no script %s in %s", name, file)
+	}
+	return source
+}
+
+func TestDequeueScriptTakesEveryKeyFromKeys(t *testing.T) {
+	source := scriptSource(t, "leases.go", "dequeueScript")
+
+	// Keys built in the script are invisible to Redis Cluster routing.
+	if built := regexp.MustCompile(`(ARGV\[\d+\]|"[^"]*")\s*\.\.`).FindString(source); built != "" {
+		t.Errorf("dequeueScript builds a key from %q; pass it in KEYS", built)
+	}
+
+	keys := dequeueKeys("etl", [2]string{priorityQueueKey("etl"), retryLaneKey("etl")}, "worker-1")
+	used := map[int]bool{}
+	for _, m := range regexp.MustCompile(`KEYS\[(\d+)\]`).FindAllStringSubmatch(source, -1) {
+		i, _ := strconv.Atoi(m[1])
+		if i < 1 || i > len(keys) {
+			t.Errorf("dequeueScript uses KEYS[%d], but dequeueKeys passes %d keys", i, len(keys))
+		}
+		used[i] = true
+	}
+	for i := 1; i <= len(keys); i++ {
+		if !used[i] {
+			t.Errorf("dequeueKeys passes %q as KEYS[%d], which dequeueScript never uses", keys[i-1], i)
+		}
+	}
+	if got, want := keys[len(keys)-1], workerTasksKey("worker-1"); got != want {
+		t.Errorf("last key = %q, want the worker's claims %q", got, want)
+	}
+}
+
+func TestRedisHasNoTrailingWhitespace(t *testing.T) {
+	source, err := os.ReadFile("redis.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, line := range strings.Split(string(source), "\n") {
+		if strings.TrimRight(line, " \t") != line {
+			t.Errorf("redis.go:%d has trailing whitespace", i+1)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package queue
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"flowctl/internal/core"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// GetRedisMemory reads Redis's memory use, limit, eviction policy and
+// eviction count from INFO, which managed Redis services allow even where
+// CONFIG GET is disabled.
+func (q *RedisQueue) GetRedisMemory(ctx context.Context) (*core.RedisMemory, error) {
+	pipe := q.client.Pipeline()
+	memoryInfo := pipe.Info(ctx, "memory")
+	statsInfo := pipe.Info(ctx, "stats")
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to read redis memory info: %w", err)
+	}
+
+	fields := parseInfo(memoryInfo.Val())
+	for name, value := range parseInfo(statsInfo.Val()) {
+		fields[name] = value
+	}
+
+	policy, ok := fields["maxmemory_policy"]
+	if !ok {
+		return nil, fmt.Errorf("redis did not report its maxmemory_policy")
+	}
+
+	memory := &core.RedisMemory{
+		EvictionPolicy: policy,
+		EvictionSafe:   policy == core.SafeEvictionPolicy,
+		CheckedAt:      time.Now(),
+	}
+	memory.UsedBytes, _ = strconv.ParseInt(fields["used_memory"], 10, 64)
+	memory.MaxBytes, _ = strconv.ParseInt(fields["maxmemory"], 10, 64)
+	memory.EvictedKeys, _ = strconv.ParseInt(fields["evicted_keys"], 10, 64)
+	return memory, nil
+}
+
+// parseInfo splits the "name:value" lines of an INFO reply, skipping its
+// "# Section" headers.
+func parseInfo(info string) map[string]string {
+	fields := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(info))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if name, value, ok := strings.Cut(line, ":"); ok {
+			fields[name] = value
+		}
+	}
+	return fields
+}
+
+// GetQueueMemoryUsage estimates the memory held by every queue of a task
+// type with MEMORY USAGE. Leases of every type share one hash, so they are
+// not counted.
+func (q *RedisQueue) GetQueueMemoryUsage(ctx context.Context, taskType string) (*core.QueueMemoryUsage, error) {
+	scopedQueues, err := q.scopedQueueKeys(ctx, taskType)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := append([]string{
+		priorityQueueKey(taskType),
+		retryLaneKey(taskType),
+		legacyQueueKey(taskType),
+		visibilityKey(taskType),
+		fmt.Sprintf("retry:%s", taskType),
+		delayedKey(taskType),
+		poisonKey(taskType),
+		fmt.Sprintf("dead_letter:%s", taskType),
+	}, scopedQueues...)
+
+	pipe := q.client.Pipeline()
+	sizes := make([]*redis.IntCmd, len(keys))
+	for i, key := range keys {
+		sizes[i] = pipe.MemoryUsage(ctx, key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to measure queues of %s: %w", taskType, err)
+	}
+
+	usage := &core.QueueMemoryUsage{TaskType: taskType}
+	for _, size := range sizes {
+		// MEMORY USAGE replies nil for a key that does not exist.
+		if size.Err() != nil {
+			continue
+		}
+		usage.Bytes += size.Val()
+		usage.Keys++
+	}
+	return usage, nil
+}
@@ -0,0 +1,203 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"flowctl/internal/core"
+)
+
+// dedicatedNamespacesKey is the set of workflow namespaces that workers have
+// registered for. Such a namespace has its own queues, which only those
+// workers dequeue from, for as long as any of them hold tasks.
+const dedicatedNamespacesKey = "dedicated_namespaces"
+
+// dedicatedTypesKey is the set of namespace and task type pairs, written by
+// dedicationMember, that workers registered for the namespace run. Only
+// tasks of such a pair are queued in the namespace's queues, so tasks of
+// types its workers do not run stay in the shared queues.
+const dedicatedTypesKey = "dedicated_namespace_types"
+
+// dedicationMember writes a namespace and task type pair as a member of
+// dedicatedTypesKey.
+func dedicationMember(namespace, taskType string) string {
+	member, _ := json.Marshal([2]string{namespace, taskType})
+	return string(member)
+}
+
+// namespaceQueueKey is the queue of a task type that only workers
+// registered for namespace dequeue from.
+func namespaceQueueKey(namespace, taskType string) string {
+	return fmt.Sprintf("ns_queue:%s:%s", namespace, taskType)
+}
+
+// SetWorkerNamespaces registers the workers using this queue for workflow
+// namespaces. They then only dequeue tasks of those namespaces, and never
+// tasks of the shared queues.
+func (q *RedisQueue) SetWorkerNamespaces(namespaces []string) {
+	q.namespaces = namespaces
+}
+
+// WorkerNamespaces returns the namespaces set with SetWorkerNamespaces.
+func (q *RedisQueue) WorkerNamespaces() []string {
+	return q.namespaces
+}
+
+// dedicateNamespaces records that a worker registered for namespaces runs
+// taskTypes, so their tasks are queued in the namespaces' own queues.
+func (q *RedisQueue) dedicateNamespaces(ctx context.Context, namespaces, taskTypes []string) error {
+	if len(namespaces) == 0 {
+		return nil
+	}
+
+	names := make([]interface{}, len(namespaces))
+	pairs := make([]interface{}, 0, len(namespaces)*len(taskTypes))
+	for i, namespace := range namespaces {
+		names[i] = namespace
+		for _, taskType := range taskTypes {
+			pairs = append(pairs, dedicationMember(namespace, taskType))
+		}
+	}
+
+	pipe := q.client.TxPipeline()
+	pipe.SAdd(ctx, dedicatedNamespacesKey, names...)
+	if len(pairs) > 0 {
+		pipe.SAdd(ctx, dedicatedTypesKey, pairs...)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to dedicate namespaces to worker: %w", err)
+	}
+	return nil
+}
+
+// dedicatedTypes returns the namespace and task type pairs, as written by
+// dedicationMember, whose tasks go to their namespace's queues.
+func (q *RedisQueue) dedicatedTypes(ctx context.Context) (map[string]bool, error) {
+	pairs, err := q.client.SMembers(ctx, dedicatedTypesKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dedicated namespaces: %w", err)
+	}
+
+	dedicated := make(map[string]bool, len(pairs))
+	for _, pair := range pairs {
+		dedicated[pair] = true
+	}
+	return dedicated, nil
+}
+
+// routeToNamespace sends a task to its namespace's queues when workers
+// registered for the namespace run its type. Tasks pinned to a pool stay
+// in the pool's queues.
+func routeToNamespace(task *core.Task, dedicated map[string]bool) {
+	task.QueueNamespace = ""
+	if task.Pool != "" || task.Execution == nil {
+		return
+	}
+	if dedicated[dedicationMember(task.Execution.Namespace, task.Type)] {
+		task.QueueNamespace = task.Execution.Namespace
+	}
+}
+
+// sharedQueueOf returns the shared queue or retry lane that the tasks in
+// key, a queue or retry lane of namespace or a labeled sub-queue of one, go
+// back to when no live worker runs them, and the task type they are of.
+func sharedQueueOf(key, namespace string) (string, string, bool) {
+	selector := ""
+	if rest, ok := strings.CutPrefix(key, "labeled:"); ok {
+		// Selectors cannot contain ':', so the first one ends it.
+		var found bool
+		selector, key, found = strings.Cut(rest, ":")
+		if !found {
+			return "", "", false
+		}
+	}
+
+	var shared, taskType string
+	if t, ok := strings.CutPrefix(key, namespaceQueueKey(namespace, "")); ok {
+		shared, taskType = priorityQueueKey(t), t
+	} else if t, ok := strings.CutPrefix(key, namespaceRetryLaneKey(namespace, "")); ok {
+		shared, taskType = retryLaneKey(t), t
+	} else {
+		return "", "", false
+	}
+
+	if selector != "" {
+		shared = labeledQueueKey(selector, shared)
+	}
+	return shared, taskType, true
+}
+
+// releaseNamespaceQueues moves the tasks of namespace whose type no live
+// worker registered for it runs, as given by live, from the namespace's
+// queues back to the shared queues, where any worker may take them. It
+// returns how many it moved.
+func (q *RedisQueue) releaseNamespaceQueues(ctx context.Context, namespace string, live map[string]bool) (int, error) {
+	queue, lane := namespaceQueueKey(namespace, "*"), namespaceRetryLaneKey(namespace, "*")
+
+	released := 0
+	for _, pattern := range []string{queue, lane, labeledQueueKey("*", queue), labeledQueueKey("*", lane)} {
+		keys, err := q.scanKeys(ctx, pattern)
+		if err != nil {
+			return released, err
+		}
+
+		for _, key := range keys {
+			shared, taskType, ok := sharedQueueOf(key, namespace)
+			if !ok || live[dedicationMember(namespace, taskType)] {
+				continue
+			}
+			moved, err := q.moveQueue(ctx, key, shared)
+			released += moved
+			if err != nil {
+				return released, err
+			}
+		}
+	}
+	return released, nil
+}
+
+// moveQueue moves every task in the queue from to the queue to, keeping its
+// score and clearing the namespace it was queued for. A task a worker
+// dequeues meanwhile is left to that worker.
+func (q *RedisQueue) moveQueue(ctx context.Context, from, to string) (int, error) {
+	moved := 0
+	for {
+		entries, err := q.client.ZRangeWithScores(ctx, from, 0, promoteBatch-1).Result()
+		if err != nil {
+			return moved, fmt.Errorf("failed to read queue %s: %w", from, err)
+		}
+		if len(entries) == 0 {
+			return moved, nil
+		}
+
+		for _, entry := range entries {
+			member, _ := entry.Member.(string)
+			task, err := core.TaskFromJSON([]byte(member))
+			if err != nil {
+				// An entry that cannot be read would be read again on every
+				// pass, so it is dropped from the namespace's queue.
+				q.logger.Errorf("Dropping unreadable task in queue %s: %v", from, err)
+				q.client.ZRem(ctx, from, member)
+				continue
+			}
+
+			task.QueueNamespace = ""
+			taskJSON, err := task.ToJSON()
+			if err != nil {
+				return moved, fmt.Errorf("failed to serialize task %s: %w", task.ID, err)
+			}
+
+			score := fmt.Sprintf("%f", entry.Score)
+			promoted, err := promoteScript.Run(ctx, q.client, []string{from, to}, member, string(taskJSON), score).Int()
+			if err != nil {
+				return moved, fmt.Errorf("failed to move task %s: %w", task.ID, err)
+			}
+			if promoted == 1 {
+				moved++
+				q.nudgeWorkers(ctx, task)
+			}
+		}
+	}
+}
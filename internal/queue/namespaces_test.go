@@ -0,0 +1,61 @@
+package queue
+
+import (
+	"testing"
+
+	"flowctl/internal/core"
+)
+
+func TestRouteToNamespaceOnlyForDedicatedTypes(t *testing.T) {
+	dedicated := map[string]bool{dedicationMember("team-a", "etl"): true}
+
+	tests := []struct {
+		name      string
+		task      core.Task
+		namespace string
+	}{
+		{"dedicated type", core.Task{Type: "etl", Execution: &core.ExecutionContext{Namespace: "team-a"}}, "team-a"},
+		{"type its workers do not run", core.Task{Type: "ci", Execution: &core.ExecutionContext{Namespace: "team-a"}}, ""},
+		{"other namespace", core.Task{Type: "etl", Execution: &core.ExecutionContext{Namespace: "team-b"}}, ""},
+		{"pooled", core.Task{Type: "etl", Pool: "gpu", Execution: &core.ExecutionContext{Namespace: "team-a"}}, ""},
+		{"no longer dedicated", core.Task{Type: "ci", QueueNamespace: "team-a", Execution: &core.ExecutionContext{Namespace: "team-a"}}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := tt.task
+			routeToNamespace(&task, dedicated)
+			if task.QueueNamespace != tt.namespace {
+				t.Errorf("QueueNamespace = %q, want %q", task.QueueNamespace, tt.namespace)
+			}
+		})
+	}
+}
+
+func TestDedicationMemberIsUnambiguous(t *testing.T) {
+	if dedicationMember("a:b", "c") == dedicationMember("a", "b:c") {
+		t.Error("different namespace and type pairs write the same member")
+	}
+}
+
+func TestSharedQueueOf(t *testing.T) {
+	tests := []struct {
+		key      string
+		shared   string
+		taskType string
+	}{
+		{namespaceQueueKey("team-a", "etl"), priorityQueueKey("etl"), "etl"},
+		{namespaceRetryLaneKey("team-a", "etl"), retryLaneKey("etl"), "etl"},
+		{labeledQueueKey("gpu=true", namespaceQueueKey("team-a", "ml_training")), labeledQueueKey("gpu=true", priorityQueueKey("ml_training")), "ml_training"},
+		{labeledQueueKey("gpu=true", namespaceRetryLaneKey("team-a", "ml_training")), labeledQueueKey("gpu=true", retryLaneKey("ml_training")), "ml_training"},
+	}
+	for _, tt := range tests {
+		shared, taskType, ok := sharedQueueOf(tt.key, "team-a")
+		if !ok || shared != tt.shared || taskType != tt.taskType {
+			t.Errorf("sharedQueueOf(%q) = %q, %q, %v; want %q, %q", tt.key, shared, taskType, ok, tt.shared, tt.taskType)
+		}
+	}
+
+	if _, _, ok := sharedQueueOf(namespaceQueueKey("team-b", "etl"), "team-a"); ok {
+		t.Error("sharedQueueOf accepted a queue of another namespace")
+	}
+}
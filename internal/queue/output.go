@@ -0,0 +1,96 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"flowctl/internal/core"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// outputStreamMaxLen bounds an attempt's output stream. Chunks are at least
+// a line long in practice, so the byte cap the worker enforces is reached
+// well before this.
+const outputStreamMaxLen = 10000
+
+func taskOutputKey(taskID string, attempt int) string {
+	return fmt.Sprintf("task_output:%s:%d", taskID, attempt)
+}
+
+// AppendTaskOutput adds chunks of one attempt's output to the attempt's
+// stream, oldest first, and extends how long the stream is kept.
+func (q *RedisQueue) AppendTaskOutput(ctx context.Context, chunks []core.TaskOutputChunk) error {
+	if len(chunks) == 0 {
+		return nil
+	}
+
+	key := taskOutputKey(chunks[0].TaskID, chunks[0].Attempt)
+	pipe := q.client.TxPipeline()
+	for _, chunk := range chunks {
+		chunkJSON, err := json.Marshal(chunk)
+		if err != nil {
+			return fmt.Errorf("failed to serialize output chunk: %w", err)
+		}
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: key,
+			MaxLen: outputStreamMaxLen,
+			Approx: true,
+			Values: map[string]interface{}{"chunk": chunkJSON},
+		})
+	}
+	pipe.Expire(ctx, key, core.TaskOutputRetention)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to append task output: %w", err)
+	}
+	return nil
+}
+
+// ReadTaskOutput returns up to count chunks of an attempt's output after
+// the chunk with ID after, waiting up to block for one when there are none
+// yet. after "0" reads from the start.
+func (q *RedisQueue) ReadTaskOutput(ctx context.Context, taskID string, attempt int, after string, count int64, block time.Duration) ([]core.TaskOutputChunk, error) {
+	if after == "" {
+		after = "0"
+	}
+
+	args := &redis.XReadArgs{
+		Streams: []string{taskOutputKey(taskID, attempt), after},
+		Count:   count,
+		Block:   -1,
+	}
+	if block > 0 {
+		args.Block = block
+	}
+
+	streams, err := q.client.XRead(ctx, args).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return []core.TaskOutputChunk{}, nil
+		}
+		return nil, fmt.Errorf("failed to read task output: %w", err)
+	}
+
+	chunks := []core.TaskOutputChunk{}
+	for _, stream := range streams {
+		for _, message := range stream.Messages {
+			chunkJSON, ok := message.Values["chunk"].(string)
+			if !ok {
+				continue
+			}
+
+			var chunk core.TaskOutputChunk
+			if err := json.Unmarshal([]byte(chunkJSON), &chunk); err != nil {
+				q.logger.Errorf("Failed to unmarshal output chunk %s: %v", message.ID, err)
+				continue
+			}
+			chunk.ID = message.ID
+			chunks = append(chunks, chunk)
+		}
+	}
+
+	return chunks, nil
+}
@@ -0,0 +1,53 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+
+	"flowctl/internal/core"
+)
+
+// DefaultMaxDeliveries is how many times a task may be delivered without
+// its worker acking or nacking it, for example because it crashed the
+// worker each time, before it is quarantined.
+const DefaultMaxDeliveries = 5
+
+// deliveriesKey counts, per task ID, the deliveries since the task was last
+// acked or nacked.
+const deliveriesKey = "task_deliveries"
+
+func poisonKey(taskType string) string {
+	return fmt.Sprintf("poison:%s", taskType)
+}
+
+// SetMaxDeliveries changes how many unfinished deliveries quarantine a task.
+// Zero disables the delivery limit; tasks that are not valid JSON are still
+// quarantined.
+func (q *RedisQueue) SetMaxDeliveries(n int) {
+	q.maxDeliveries = n
+}
+
+// quarantined reports a task that dequeue moved to its poison queue and
+// publishes an event to alert on. The returned task is nil when the entry
+// could not be decoded.
+func (q *RedisQueue) quarantined(ctx context.Context, taskType string, leased *leasedTask) (*core.Task, error) {
+	var workflowID, taskID string
+	var err error
+
+	task, decodeErr := core.TaskFromJSON([]byte(leased.member))
+	if decodeErr != nil {
+		task = nil
+		err = fmt.Errorf("%w: undecodable %s task: %v", core.ErrTaskQuarantined, taskType, decodeErr)
+	} else {
+		workflowID, taskID = task.WorkflowID, task.ID
+		err = fmt.Errorf("%w: task %s was delivered %d times without being acked or nacked", core.ErrTaskQuarantined, task.ID, leased.deliveries-1)
+	}
+
+	event := core.NewQuarantineEvent(workflowID, taskID, taskType, leased.deliveries, err.Error())
+	if pubErr := q.PublishEvent(ctx, event); pubErr != nil {
+		q.logger.Errorf("Failed to publish quarantine event for %s task: %v", taskType, pubErr)
+	}
+
+	q.logger.Warnf("Quarantined %s task into %s: %v", taskType, poisonKey(taskType), err)
+	return task, err
+}
@@ -0,0 +1,91 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+
+	"flowctl/internal/core"
+)
+
+// poolsKey is the set of worker pools that tasks have been queued for.
+const poolsKey = "worker_pools"
+
+// poolQueueKey is the queue of a task type that only workers registered
+// with pool dequeue from.
+func poolQueueKey(pool, taskType string) string {
+	return fmt.Sprintf("pool_queue:%s:%s", pool, taskType)
+}
+
+// taskQueueKey is the queue a task waits in: its pool's queue if it is
+// pinned to a pool, its namespace's queue if the namespace is dedicated,
+// otherwise the shared priority queue of its type. A task with a selector
+// waits in the labeled sub-queue of that queue.
+func taskQueueKey(task *core.Task) string {
+	switch {
+	case task.Pool != "":
+		return taskLabeledKey(task, poolQueueKey(task.Pool, task.Type))
+	case task.QueueNamespace != "":
+		return taskLabeledKey(task, namespaceQueueKey(task.QueueNamespace, task.Type))
+	}
+	return taskLabeledKey(task, priorityQueueKey(task.Type))
+}
+
+// SetWorkerPool registers the workers using this queue with a pool. They
+// then only dequeue tasks pinned to that pool, and never tasks of the
+// shared queues.
+func (q *RedisQueue) SetWorkerPool(pool string) {
+	q.pool = pool
+}
+
+// WorkerPool returns the pool set with SetWorkerPool.
+func (q *RedisQueue) WorkerPool() string {
+	return q.pool
+}
+
+// scopedQueueKeys returns the queue of a task type in every known pool and
+// dedicated namespace, the labeled sub-queues of those and of the shared
+// queue for every known selector, and each queue's retry lane.
+func (q *RedisQueue) scopedQueueKeys(ctx context.Context, taskType string) ([]string, error) {
+	scopes, err := q.unlabeledQueueKeys(ctx, taskType)
+	if err != nil {
+		return nil, err
+	}
+
+	selectors, err := q.client.SMembers(ctx, selectorsKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task selectors: %w", err)
+	}
+
+	keys := make([]string, 0, len(scopes)*(len(selectors)+1))
+	keys = append(keys, scopes[2:]...)
+	for _, selector := range selectors {
+		for _, key := range scopes {
+			keys = append(keys, labeledQueueKey(selector, key))
+		}
+	}
+	return keys, nil
+}
+
+// unlabeledQueueKeys returns the shared queue of a task type and its queue
+// in every known pool and dedicated namespace, each followed by its retry
+// lane.
+func (q *RedisQueue) unlabeledQueueKeys(ctx context.Context, taskType string) ([]string, error) {
+	pools, err := q.client.SMembers(ctx, poolsKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get worker pools: %w", err)
+	}
+	namespaces, err := q.client.SMembers(ctx, dedicatedNamespacesKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dedicated namespaces: %w", err)
+	}
+
+	keys := make([]string, 0, 2*(len(pools)+len(namespaces)+1))
+	keys = append(keys, priorityQueueKey(taskType), retryLaneKey(taskType))
+	for _, pool := range pools {
+		keys = append(keys, poolQueueKey(pool, taskType), poolRetryLaneKey(pool, taskType))
+	}
+	for _, namespace := range namespaces {
+		keys = append(keys, namespaceQueueKey(namespace, taskType), namespaceRetryLaneKey(namespace, taskType))
+	}
+	return keys, nil
+}
@@ -0,0 +1,134 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+
+	"flowctl/internal/core"
+)
+
+// PurgeTasks removes every copy of tasks that Redis holds: waiting in a
+// shared, pool, namespace or legacy queue, waiting to retry or for its run time,
+// quarantined or dead-lettered. The timers of wait tasks are disarmed.
+// Leases on them are ended, releasing their workers' claims, so a worker still
+// running one can no longer ack or nack it. It returns how many entries were
+// removed and the IDs of the tasks that were leased.
+func (q *RedisQueue) PurgeTasks(ctx context.Context, tasks []core.Task) (int, []string, error) {
+	byType := make(map[string]map[string]bool)
+	for _, task := range tasks {
+		if byType[task.Type] == nil {
+			byType[task.Type] = make(map[string]bool)
+		}
+		byType[task.Type][task.ID] = true
+	}
+
+	purged := 0
+	for taskType, ids := range byType {
+		scopedQueues, err := q.scopedQueueKeys(ctx, taskType)
+		if err != nil {
+			return purged, nil, err
+		}
+
+		sortedSets := append([]string{priorityQueueKey(taskType), retryLaneKey(taskType), fmt.Sprintf("retry:%s", taskType), delayedKey(taskType)}, scopedQueues...)
+		for _, key := range sortedSets {
+			removed, err := q.purgeSortedSet(ctx, key, ids)
+			purged += removed
+			if err != nil {
+				return purged, nil, err
+			}
+		}
+
+		lists := []string{legacyQueueKey(taskType), poisonKey(taskType), fmt.Sprintf("dead_letter:%s", taskType)}
+		for _, key := range lists {
+			removed, err := q.purgeList(ctx, key, ids)
+			purged += removed
+			if err != nil {
+				return purged, nil, err
+			}
+		}
+	}
+
+	leases, err := q.client.HGetAll(ctx, leasesKey).Result()
+	if err != nil {
+		return purged, nil, fmt.Errorf("failed to get leases: %w", err)
+	}
+
+	var leased []string
+	for receipt, member := range leases {
+		task, err := core.TaskFromJSON([]byte(member))
+		if err != nil || !byType[task.Type][task.ID] {
+			continue
+		}
+		err = q.settleLease(ctx, task.Type, task.ID, receipt, "", "", nil, true)
+		if err == core.ErrLeaseExpired {
+			continue
+		}
+		if err != nil {
+			return purged, leased, fmt.Errorf("failed to end lease of task %s: %w", task.ID, err)
+		}
+		purged++
+		leased = append(leased, task.ID)
+	}
+
+	taskIDs := make([]string, 0, len(tasks))
+	for _, task := range tasks {
+		taskIDs = append(taskIDs, task.ID)
+	}
+	if len(taskIDs) > 0 {
+		if err := q.client.HDel(ctx, deliveriesKey, taskIDs...).Err(); err != nil {
+			return purged, leased, fmt.Errorf("failed to clear delivery counts: %w", err)
+		}
+	}
+
+	disarmed, err := q.RemoveTimers(ctx, taskIDs...)
+	purged += disarmed
+	if err != nil {
+		return purged, leased, err
+	}
+
+	return purged, leased, nil
+}
+
+// purgeSortedSet removes the members of a sorted set that are tasks in ids.
+func (q *RedisQueue) purgeSortedSet(ctx context.Context, key string, ids map[string]bool) (int, error) {
+	members, err := q.client.ZRange(ctx, key, 0, -1).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+
+	removed := 0
+	for _, member := range members {
+		task, err := core.TaskFromJSON([]byte(member))
+		if err != nil || !ids[task.ID] {
+			continue
+		}
+		count, err := q.client.ZRem(ctx, key, member).Result()
+		if err != nil {
+			return removed, fmt.Errorf("failed to remove task %s from %s: %w", task.ID, key, err)
+		}
+		removed += int(count)
+	}
+	return removed, nil
+}
+
+// purgeList removes the entries of a list that are tasks in ids.
+func (q *RedisQueue) purgeList(ctx context.Context, key string, ids map[string]bool) (int, error) {
+	members, err := q.client.LRange(ctx, key, 0, -1).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+
+	removed := 0
+	for _, member := range members {
+		task, err := core.TaskFromJSON([]byte(member))
+		if err != nil || !ids[task.ID] {
+			continue
+		}
+		count, err := q.client.LRem(ctx, key, 1, member).Result()
+		if err != nil {
+			return removed, fmt.Errorf("failed to remove task %s from %s: %w", task.ID, key, err)
+		}
+		removed += int(count)
+	}
+	return removed, nil
+}
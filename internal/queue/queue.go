@@ -0,0 +1,48 @@
+package queue
+
+import (
+	"context"
+
+	"flowctl/internal/core"
+)
+
+// Queue and WorkerRegistry are defined by core, so that the scheduler can
+// depend on them without importing this package.
+type (
+	Queue          = core.Queue
+	WorkerRegistry = core.WorkerRegistry
+)
+
+// WorkerBroker is what a worker needs from its broker on top of Queue:
+// nudges and cancellations pushed to it, results kept for idempotency and
+// side effects, a place to stream task output, and the broker's health and
+// redaction rules.
+type WorkerBroker interface {
+	Queue
+
+	// WorkerPool is the pool whose queues this worker dequeues from.
+	WorkerPool() string
+	// WorkerNamespaces are the namespaces whose queues this worker
+	// dequeues from.
+	WorkerNamespaces() []string
+	// WorkerLabels are the labels matched against task selectors to pick
+	// the labeled queues this worker also dequeues from.
+	WorkerLabels() map[string]string
+	CheckBroker(ctx context.Context) core.BrokerHealth
+	Redactor(ctx context.Context) core.Redactor
+
+	SubscribeWakeups(ctx context.Context, taskTypes []string) <-chan string
+	SubscribeCancellations(ctx context.Context) <-chan string
+
+	GetIdempotentResult(ctx context.Context, namespace, key string) (map[string]interface{}, bool, error)
+	SaveIdempotentResult(ctx context.Context, namespace, key string, result map[string]interface{}) error
+	RunEffect(ctx context.Context, token string, fn func(ctx context.Context, token string) (map[string]interface{}, error)) (map[string]interface{}, error)
+
+	// AppendTaskOutput streams chunks of one attempt's stdout and stderr.
+	AppendTaskOutput(ctx context.Context, chunks []core.TaskOutputChunk) error
+}
+
+var (
+	_ WorkerBroker = (*RedisQueue)(nil)
+	_ core.Broker  = (*RedisQueue)(nil)
+)
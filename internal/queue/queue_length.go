@@ -0,0 +1,148 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"flowctl/internal/core"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// queueLengthLimitsKey maps task types to their core.QueueLengthLimit.
+const queueLengthLimitsKey = "queue_length_limits"
+
+func (q *RedisQueue) SetQueueLengthLimit(ctx context.Context, limit core.QueueLengthLimit) error {
+	limitJSON, err := json.Marshal(limit)
+	if err != nil {
+		return fmt.Errorf("failed to serialize queue length limit: %w", err)
+	}
+
+	if err := q.client.HSet(ctx, queueLengthLimitsKey, limit.TaskType, limitJSON).Err(); err != nil {
+		return fmt.Errorf("failed to set queue length limit: %w", err)
+	}
+
+	q.logger.Infof("Set queue length limit for task type %s: %d", limit.TaskType, limit.MaxLength)
+	return nil
+}
+
+func (q *RedisQueue) DeleteQueueLengthLimit(ctx context.Context, taskType string) error {
+	if err := q.client.HDel(ctx, queueLengthLimitsKey, taskType).Err(); err != nil {
+		return fmt.Errorf("failed to delete queue length limit: %w", err)
+	}
+	return nil
+}
+
+func (q *RedisQueue) GetQueueLengthLimit(ctx context.Context, taskType string) (*core.QueueLengthLimit, error) {
+	limitJSON, err := q.client.HGet(ctx, queueLengthLimitsKey, taskType).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get queue length limit: %w", err)
+	}
+
+	var limit core.QueueLengthLimit
+	if err := json.Unmarshal([]byte(limitJSON), &limit); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal queue length limit: %w", err)
+	}
+
+	return &limit, nil
+}
+
+// GetQueueLengthLimits returns the length limit of every limited task type.
+func (q *RedisQueue) GetQueueLengthLimits(ctx context.Context) (map[string]core.QueueLengthLimit, error) {
+	entries, err := q.client.HGetAll(ctx, queueLengthLimitsKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get queue length limits: %w", err)
+	}
+
+	limits := make(map[string]core.QueueLengthLimit, len(entries))
+	for taskType, limitJSON := range entries {
+		var limit core.QueueLengthLimit
+		if err := json.Unmarshal([]byte(limitJSON), &limit); err != nil {
+			q.logger.Errorf("Failed to unmarshal queue length limit of task type %s: %v", taskType, err)
+			continue
+		}
+		limits[taskType] = limit
+	}
+	return limits, nil
+}
+
+// GetQueueLength counts the tasks of a type waiting in Redis, ready or
+// delayed, which is what its length limit caps.
+func (q *RedisQueue) GetQueueLength(ctx context.Context, taskType string) (int64, error) {
+	stats, err := q.GetQueueStats(ctx, taskType)
+	if err != nil {
+		return 0, err
+	}
+	return stats["pending"] + stats["delayed"], nil
+}
+
+// CheckQueueCapacity returns a *core.BackpressureError unless the queue of
+// limit's task type has room for adding more tasks.
+func (q *RedisQueue) CheckQueueCapacity(ctx context.Context, limit core.QueueLengthLimit, adding int64) error {
+	length, err := q.GetQueueLength(ctx, limit.TaskType)
+	if err != nil {
+		return err
+	}
+	if length+adding <= limit.MaxLength {
+		return nil
+	}
+
+	return &core.BackpressureError{
+		TaskType:   limit.TaskType,
+		Length:     length,
+		MaxLength:  limit.MaxLength,
+		RetryAfter: q.estimateDrainTime(ctx, limit.TaskType, length+adding-limit.MaxLength),
+	}
+}
+
+// checkQueueCapacity rejects a batch of tasks if any limited task type's
+// queue has no room for its share of the batch. The check and the enqueue
+// are separate round trips, so concurrent enqueues can overshoot a limit by
+// a batch.
+func (q *RedisQueue) checkQueueCapacity(ctx context.Context, tasks []*core.Task) error {
+	limits, err := q.GetQueueLengthLimits(ctx)
+	if err != nil {
+		return err
+	}
+	if len(limits) == 0 {
+		return nil
+	}
+
+	adding := make(map[string]int64)
+	for _, task := range tasks {
+		if _, ok := limits[task.Type]; ok {
+			adding[task.Type]++
+		}
+	}
+	for taskType, n := range adding {
+		if err := q.CheckQueueCapacity(ctx, limits[taskType], n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// estimateDrainTime estimates how long a task type's queue takes to hand
+// out excess more tasks, at the rate workers dequeued them over the last
+// full minute.
+func (q *RedisQueue) estimateDrainTime(ctx context.Context, taskType string, excess int64) time.Duration {
+	windowKey := fmt.Sprintf("latency_window:%s:%d", taskType, time.Now().Unix()/60-1)
+	dequeued, err := q.client.HGet(ctx, windowKey, "total").Int64()
+	if err != nil || dequeued <= 0 {
+		return core.DefaultBackpressureRetryAfter
+	}
+
+	wait := time.Duration(float64(excess) / float64(dequeued) * float64(time.Minute))
+	if wait < time.Second {
+		return time.Second
+	}
+	if wait > core.MaxBackpressureRetryAfter {
+		return core.MaxBackpressureRetryAfter
+	}
+	return wait.Round(time.Second)
+}
@@ -0,0 +1,126 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"flowctl/internal/core"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+// taskRateLimitsKey maps task types to their core.TaskRateLimit. Each
+// limited type's dispatches within the current window are logged in
+// rateWindowKey, scored by when they happened in milliseconds.
+const taskRateLimitsKey = "task_rate_limits"
+
+func rateWindowKey(taskType string) string {
+	return fmt.Sprintf("rate_window:%s", taskType)
+}
+
+// takeRateTokensScript drops dispatches older than ARGV[2] milliseconds
+// from the window KEYS[1] and logs up to ARGV[4] new ones at ARGV[3], as
+// many as the limit ARGV[1] leaves room for. It returns how many it logged.
+var takeRateTokensScript = redis.NewScript(`
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", tonumber(ARGV[3]) - tonumber(ARGV[2]))
+local free = tonumber(ARGV[1]) - redis.call("ZCARD", KEYS[1])
+local granted = math.min(math.max(free, 0), tonumber(ARGV[4]))
+for i = 1, granted do
+	redis.call("ZADD", KEYS[1], ARGV[3], ARGV[5] .. ":" .. i)
+end
+if granted > 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return granted
+`)
+
+func (q *RedisQueue) SetTaskRateLimit(ctx context.Context, limit core.TaskRateLimit) error {
+	limitJSON, err := json.Marshal(limit)
+	if err != nil {
+		return fmt.Errorf("failed to serialize task rate limit: %w", err)
+	}
+
+	if err := q.client.HSet(ctx, taskRateLimitsKey, limit.TaskType, limitJSON).Err(); err != nil {
+		return fmt.Errorf("failed to set task rate limit: %w", err)
+	}
+
+	q.logger.Infof("Set rate limit for task type %s: %s at %s", limit.TaskType, limit.String(), limit.EnforceAt)
+	return nil
+}
+
+// DeleteTaskRateLimit lifts a task type's rate limit and forgets the
+// dispatches logged against it.
+func (q *RedisQueue) DeleteTaskRateLimit(ctx context.Context, taskType string) error {
+	pipe := q.client.TxPipeline()
+	pipe.HDel(ctx, taskRateLimitsKey, taskType)
+	pipe.Del(ctx, rateWindowKey(taskType))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to delete task rate limit: %w", err)
+	}
+	return nil
+}
+
+func (q *RedisQueue) GetTaskRateLimit(ctx context.Context, taskType string) (*core.TaskRateLimit, error) {
+	limitJSON, err := q.client.HGet(ctx, taskRateLimitsKey, taskType).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get task rate limit: %w", err)
+	}
+
+	var limit core.TaskRateLimit
+	if err := json.Unmarshal([]byte(limitJSON), &limit); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal task rate limit: %w", err)
+	}
+
+	return &limit, nil
+}
+
+// GetTaskRateLimits returns the rate limit of every limited task type.
+func (q *RedisQueue) GetTaskRateLimits(ctx context.Context) (map[string]core.TaskRateLimit, error) {
+	entries, err := q.client.HGetAll(ctx, taskRateLimitsKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task rate limits: %w", err)
+	}
+
+	limits := make(map[string]core.TaskRateLimit, len(entries))
+	for taskType, limitJSON := range entries {
+		var limit core.TaskRateLimit
+		if err := json.Unmarshal([]byte(limitJSON), &limit); err != nil {
+			q.logger.Errorf("Failed to unmarshal rate limit of task type %s: %v", taskType, err)
+			continue
+		}
+		limits[taskType] = limit
+	}
+	return limits, nil
+}
+
+// TakeRateTokens logs up to n dispatches of a task type against its rate
+// limit, returning how many the limit allowed.
+func (q *RedisQueue) TakeRateTokens(ctx context.Context, limit core.TaskRateLimit, n int) (int, error) {
+	granted, err := takeRateTokensScript.Run(ctx, q.client, []string{rateWindowKey(limit.TaskType)},
+		limit.Limit, limit.Interval.Milliseconds(), time.Now().UnixMilli(), n, uuid.New().String()).Int()
+	if err != nil {
+		return 0, fmt.Errorf("failed to take rate limit tokens for %s: %w", limit.TaskType, err)
+	}
+	return granted, nil
+}
+
+// AvailableRateTokens returns how many more tasks of a type its rate limit
+// allows right now, without using any of them.
+func (q *RedisQueue) AvailableRateTokens(ctx context.Context, limit core.TaskRateLimit) (int, error) {
+	since := time.Now().Add(-limit.Interval).UnixMilli()
+	used, err := q.client.ZCount(ctx, rateWindowKey(limit.TaskType), fmt.Sprintf("(%d", since), "+inf").Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read rate limit window of %s: %w", limit.TaskType, err)
+	}
+
+	if available := limit.Limit - int(used); available > 0 {
+		return available, nil
+	}
+	return 0, nil
+}
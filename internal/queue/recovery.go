@@ -0,0 +1,139 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"flowctl/internal/core"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const workerHeartbeatTimeout = time.Minute * 2
+
+// TrackedTaskIDs returns the IDs of every task of a type that Redis still
+// holds, whether waiting in a queue, leased to a worker, waiting to retry or
+// for its run time, quarantined or, for a wait task, waiting on its timer.
+func (q *RedisQueue) TrackedTaskIDs(ctx context.Context, taskType string) (map[string]bool, error) {
+	scopedQueues, err := q.scopedQueueKeys(ctx, taskType)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read every list in one transaction so a task moving between them is
+	// not missed.
+	pipe := q.client.TxPipeline()
+	queued := pipe.ZRange(ctx, priorityQueueKey(taskType), 0, -1)
+	lane := pipe.ZRange(ctx, retryLaneKey(taskType), 0, -1)
+	scoped := make([]*redis.StringSliceCmd, len(scopedQueues))
+	for i, scopedQueue := range scopedQueues {
+		scoped[i] = pipe.ZRange(ctx, scopedQueue, 0, -1)
+	}
+	legacy := pipe.LRange(ctx, legacyQueueKey(taskType), 0, -1)
+	leased := pipe.HVals(ctx, leasesKey)
+	retrying := pipe.ZRange(ctx, fmt.Sprintf("retry:%s", taskType), 0, -1)
+	delayed := pipe.ZRange(ctx, delayedKey(taskType), 0, -1)
+	poisoned := pipe.LRange(ctx, poisonKey(taskType), 0, -1)
+	timers := pipe.ZRange(ctx, timersKey, 0, -1)
+
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to read queues for %s: %w", taskType, err)
+	}
+
+	lists := [][]string{queued.Val(), lane.Val(), legacy.Val(), leased.Val(), retrying.Val(), delayed.Val(), poisoned.Val()}
+	for _, cmd := range scoped {
+		lists = append(lists, cmd.Val())
+	}
+
+	ids := make(map[string]bool)
+	// Timers hold bare IDs, of tasks of any type; as IDs are unique, only
+	// this type's can match.
+	for _, id := range timers.Val() {
+		ids[id] = true
+	}
+	for _, members := range lists {
+		for _, member := range members {
+			task, err := core.TaskFromJSON([]byte(member))
+			if err != nil {
+				q.logger.Errorf("Failed to unmarshal queued %s task: %v", taskType, err)
+				continue
+			}
+			// Leases of every task type share one hash.
+			if task.Type != taskType {
+				continue
+			}
+			ids[task.ID] = true
+		}
+	}
+
+	return ids, nil
+}
+
+// ExpireStaleWorkers removes workers whose registration has expired or whose
+// last heartbeat is too old from every task type's worker set, and returns
+// the tasks they still held to their queues. It reports how many workers
+// were removed and which tasks were requeued, marked retrying, or, having
+// no retries left, dead-lettered, marked failed.
+func (q *RedisQueue) ExpireStaleWorkers(ctx context.Context) (int, []core.Task, error) {
+	setKeys, err := q.scanKeys(ctx, "workers:*")
+	if err != nil {
+		return 0, nil, err
+	}
+
+	expired := 0
+	var requeued []core.Task
+	for _, setKey := range setKeys {
+		workerIDs, err := q.client.SMembers(ctx, setKey).Result()
+		if err != nil {
+			return expired, requeued, fmt.Errorf("failed to get worker IDs: %w", err)
+		}
+
+		for _, workerID := range workerIDs {
+			workerKey := fmt.Sprintf("worker:%s", workerID)
+			workerJSON, err := q.client.Get(ctx, workerKey).Result()
+			if err != nil && err != redis.Nil {
+				return expired, requeued, fmt.Errorf("failed to get worker %s info: %w", workerID, err)
+			}
+
+			if err == nil {
+				var workerInfo core.WorkerInfo
+				if err := json.Unmarshal([]byte(workerJSON), &workerInfo); err == nil &&
+					time.Since(workerInfo.LastHeartbeat) <= workerHeartbeatTimeout {
+					continue
+				}
+				q.client.Del(ctx, workerKey)
+			}
+
+			q.client.SRem(ctx, setKey, workerID)
+			q.logger.Infof("Expired stale worker %s from %s", workerID, strings.TrimPrefix(setKey, "workers:"))
+			expired++
+
+			tasks, err := q.requeueWorkerTasks(ctx, workerID)
+			if err != nil {
+				q.logger.Errorf("Failed to requeue tasks of worker %s: %v", workerID, err)
+			}
+			requeued = append(requeued, tasks...)
+		}
+	}
+
+	return expired, requeued, nil
+}
+
+func (q *RedisQueue) scanKeys(ctx context.Context, pattern string) ([]string, error) {
+	var keys []string
+	err := retryRead(ctx, func() error {
+		keys = nil
+		iter := q.client.Scan(ctx, 0, pattern, 100).Iterator()
+		for iter.Next(ctx) {
+			keys = append(keys, iter.Val())
+		}
+		return iter.Err()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", pattern, err)
+	}
+	return keys, nil
+}
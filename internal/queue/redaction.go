@@ -0,0 +1,97 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"flowctl/internal/core"
+)
+
+const redactionRulesKey = "redaction_rules"
+
+// SetRedactor replaces the rule-based redactor with a custom implementation.
+func (q *RedisQueue) SetRedactor(redactor core.Redactor) {
+	q.redactMu.Lock()
+	defer q.redactMu.Unlock()
+	q.redactor = redactor
+}
+
+// Redactor returns the custom redactor if one is set, otherwise a redactor
+// built from the stored per-type rules, reloaded at most every 30 seconds.
+func (q *RedisQueue) Redactor(ctx context.Context) core.Redactor {
+	q.redactMu.Lock()
+	defer q.redactMu.Unlock()
+
+	if q.redactor != nil {
+		return q.redactor
+	}
+
+	if q.rules == nil || time.Since(q.rulesLoadedAt) > time.Second*30 {
+		rules, err := q.GetRedactionRules(ctx)
+		if err != nil {
+			q.logger.Errorf("Failed to load redaction rules: %v", err)
+		}
+
+		redactor, err := core.NewPatternRedactor(rules)
+		if err != nil {
+			q.logger.Errorf("Failed to build redactor: %v", err)
+			redactor, _ = core.NewPatternRedactor(nil)
+		}
+
+		q.rules = redactor
+		q.rulesLoadedAt = time.Now()
+	}
+
+	return q.rules
+}
+
+func (q *RedisQueue) SetRedactionRule(ctx context.Context, rule core.RedactionRule) error {
+	ruleJSON, err := json.Marshal(rule)
+	if err != nil {
+		return fmt.Errorf("failed to serialize redaction rule: %w", err)
+	}
+
+	if err := q.client.HSet(ctx, redactionRulesKey, rule.TaskType, ruleJSON).Err(); err != nil {
+		return fmt.Errorf("failed to set redaction rule: %w", err)
+	}
+
+	q.expireRedactionRules()
+	q.logger.Infof("Set redaction rule for task type %s: %v", rule.TaskType, rule.Fields)
+	return nil
+}
+
+func (q *RedisQueue) DeleteRedactionRule(ctx context.Context, taskType string) error {
+	if err := q.client.HDel(ctx, redactionRulesKey, taskType).Err(); err != nil {
+		return fmt.Errorf("failed to delete redaction rule: %w", err)
+	}
+
+	q.expireRedactionRules()
+	return nil
+}
+
+func (q *RedisQueue) GetRedactionRules(ctx context.Context) ([]core.RedactionRule, error) {
+	result, err := q.client.HGetAll(ctx, redactionRulesKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get redaction rules: %w", err)
+	}
+
+	rules := make([]core.RedactionRule, 0, len(result))
+	for taskType, ruleJSON := range result {
+		var rule core.RedactionRule
+		if err := json.Unmarshal([]byte(ruleJSON), &rule); err != nil {
+			q.logger.Errorf("Failed to unmarshal redaction rule for %s: %v", taskType, err)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+func (q *RedisQueue) expireRedactionRules() {
+	q.redactMu.Lock()
+	defer q.redactMu.Unlock()
+	q.rules = nil
+}
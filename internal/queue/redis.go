@@ -4,6 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"flowctl/internal/core"
@@ -13,16 +16,43 @@ import (
 )
 
 type RedisQueue struct {
-	client *redis.Client
-	logger *logrus.Logger
+	client        *redis.Client
+	logger        *logrus.Logger
+	sloMu         sync.Mutex
+	sloCache      map[string]core.LatencySLO
+	sloLoadedAt   time.Time
+	archiver      DeadLetterArchiver
+	alerter       DeadLetterAlerter
+	redactMu      sync.Mutex
+	redactor      core.Redactor
+	rules         *core.PatternRedactor
+	rulesLoadedAt time.Time
+	maxDeliveries int
+	pool          string
+	namespaces    []string
+	slots         map[string]int
+	capacity      *core.ResourceRequest
+	labels        map[string]string
+	selectorMu    sync.Mutex
+	selectorCache []string
+	selectorsAt   time.Time
+	namespaceTurn uint32
+	breaker       *circuitBreaker
+}
+
+// redisOptions configures the client. MaxRetries of -1 stops the client
+// from resending commands; see retryRead.
+func redisOptions(addr, password string, db int) *redis.Options {
+	return &redis.Options{
+		Addr:       addr,
+		Password:   password,
+		DB:         db,
+		MaxRetries: -1,
+	}
 }
 
 func NewRedisQueue(addr, password string, db int, logger *logrus.Logger) (*RedisQueue, error) {
-	client := redis.NewClient(&redis.Options{
-		Addr:     addr,
-		Password: password,
-		DB:       db,
-	})
+	client := redis.NewClient(redisOptions(addr, password, db))
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -31,34 +61,145 @@ func NewRedisQueue(addr, password string, db int, logger *logrus.Logger) (*Redis
 		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
 	}
 
+	breaker := newCircuitBreaker(logger)
+	client.AddHook(breaker)
+
 	return &RedisQueue{
-		client: client,
-		logger: logger,
+		client:        client,
+		logger:        logger,
+		sloCache:      make(map[string]core.LatencySLO),
+		maxDeliveries: DefaultMaxDeliveries,
+		breaker:       breaker,
 	}, nil
 }
 
+// priorityScoreScale separates priority levels in a queue's score so that
+// the enqueue time, in milliseconds, only orders tasks within a level.
+const priorityScoreScale = 1e13
+
+// priorityScore orders a per-type queue so that ZPOPMIN returns the highest
+// priority task first and, within a priority, the one enqueued earliest.
+func priorityScore(task *core.Task) float64 {
+	return float64(-task.Priority)*priorityScoreScale + float64(task.EnqueuedAt.UnixMilli())
+}
+
+func priorityQueueKey(taskType string) string {
+	return fmt.Sprintf("priority_queue:%s", taskType)
+}
+
+// legacyQueueKey is the FIFO list used before priority queues. Workers
+// still drain it so tasks enqueued by an older scheduler are not stranded.
+func legacyQueueKey(taskType string) string {
+	return fmt.Sprintf("queue:%s", taskType)
+}
+
 func (q *RedisQueue) EnqueueTask(ctx context.Context, task *core.Task) error {
-	taskJSON, err := task.ToJSON()
-	if err != nil {
-		return fmt.Errorf("failed to serialize task: %w", err)
+	return q.EnqueueTasks(ctx, []*core.Task{task})
+}
+
+// EnqueueTasks queues tasks in a single round trip to Redis, parking those
+// with a future RunAt in their delayed set. The tasks are added in one
+// transaction, so when it fails none of them has been queued and the whole
+// batch can be retried. A batch that would take a task type past its queue
+// length limit is rejected with a *core.BackpressureError. Tasks that
+// cannot be serialized are left out of the transaction rather than failing
+// it, and reported in a *core.UnserializableTasksError once the rest are
+// queued.
+func (q *RedisQueue) EnqueueTasks(ctx context.Context, tasks []*core.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	if err := q.checkQueueCapacity(ctx, tasks); err != nil {
+		return err
 	}
 
-	queueKey := fmt.Sprintf("queue:%s", task.Type)
-	
-	err = q.client.LPush(ctx, queueKey, taskJSON).Err()
+	dedicated, err := q.dedicatedTypes(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to enqueue task: %w", err)
+		return err
+	}
+
+	now := time.Now()
+	delayed := make([]bool, len(tasks))
+	skipped := make([]bool, len(tasks))
+	var unserializable *core.UnserializableTasksError
+
+	pipe := q.client.TxPipeline()
+	for i, task := range tasks {
+		enqueuedAt := now
+		task.EnqueuedAt = &enqueuedAt
+		routeToNamespace(task, dedicated)
+
+		taskJSON, err := task.ToJSON()
+		if err != nil {
+			q.logger.Errorf("Leaving task %s out of its batch: failed to serialize it: %v", task.ID, err)
+			if unserializable == nil {
+				unserializable = &core.UnserializableTasksError{Errors: make(map[string]error)}
+			}
+			unserializable.Errors[task.ID] = err
+			skipped[i] = true
+			continue
+		}
+
+		if task.RunAt != nil && task.RunAt.After(now) {
+			delayTask(ctx, pipe, task, taskJSON)
+			delayed[i] = true
+			continue
+		}
+
+		if task.Pool != "" {
+			pipe.SAdd(ctx, poolsKey, task.Pool)
+		}
+		if len(task.Selector) > 0 {
+			pipe.SAdd(ctx, selectorsKey, core.FormatSelector(task.Selector))
+		}
+		pipe.ZAdd(ctx, taskQueueKey(task), &redis.Z{
+			Score:  priorityScore(task),
+			Member: string(taskJSON),
+		})
+	}
+
+	if unserializable != nil && len(unserializable.Errors) == len(tasks) {
+		return unserializable
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to enqueue tasks: %w", err)
+	}
+
+	// One nudge per channel wakes every worker the batch has work for.
+	nudged := make(map[string]bool)
+	for i, task := range tasks {
+		if skipped[i] {
+			continue
+		}
+		if delayed[i] {
+			q.logger.Infof("Delayed task %s until %s", task.ID, task.RunAt.Format(time.RFC3339))
+			continue
+		}
+
+		q.logger.Infof("Enqueued task %s to queue %s", task.ID, taskQueueKey(task))
+		if channel := taskWakeChannel(task); !nudged[channel] {
+			nudged[channel] = true
+			q.nudgeWorkers(ctx, task)
+		}
 	}
 
-	q.logger.Infof("Enqueued task %s to queue %s", task.ID, queueKey)
+	if unserializable != nil {
+		return unserializable
+	}
 	return nil
 }
 
-func (q *RedisQueue) DequeueTask(ctx context.Context, taskType string, timeout time.Duration) (*core.Task, error) {
-	queueKey := fmt.Sprintf("queue:%s", taskType)
-	processingKey := fmt.Sprintf("processing:%s", taskType)
-
-	result, err := q.client.BRPopLPush(ctx, queueKey, processingKey, timeout).Result()
+// DequeueTask leases the next task of a type to workerID, waiting up to
+// timeout for one to arrive. The returned task carries the lease's receipt
+// handle, which AckTask, NackTask and TouchTask use to find it again.
+//
+// A task that keeps being delivered without being acked or nacked, or that
+// cannot be decoded, is moved to the poison queue instead; DequeueTask then
+// returns an error wrapping core.ErrTaskQuarantined along with the task, if
+// it could be decoded.
+func (q *RedisQueue) DequeueTask(ctx context.Context, workerID, taskType string, timeout time.Duration) (*core.Task, error) {
+	leased, err := q.leaseTask(ctx, workerID, taskType, timeout)
 	if err != nil {
 		if err == redis.Nil {
 			return nil, nil
@@ -66,26 +207,29 @@ func (q *RedisQueue) DequeueTask(ctx context.Context, taskType string, timeout t
 		return nil, fmt.Errorf("failed to dequeue task: %w", err)
 	}
 
-	task, err := core.TaskFromJSON([]byte(result))
+	if leased.quarantined {
+		return q.quarantined(ctx, taskType, leased)
+	}
+
+	task, err := core.TaskFromJSON([]byte(leased.member))
 	if err != nil {
-		q.client.LRem(ctx, processingKey, 1, result)
-		return nil, fmt.Errorf("failed to deserialize task: %w", err)
+		q.settleLease(ctx, taskType, "", leased.receipt, poisonKey(taskType), leased.member, nil, false)
+		return nil, fmt.Errorf("%w: failed to deserialize task: %v", core.ErrTaskQuarantined, err)
+	}
+	task.ReceiptHandle = leased.receipt
+
+	if task.EnqueuedAt != nil {
+		q.recordQueueLatency(ctx, taskType, time.Since(*task.EnqueuedAt))
 	}
 
-	q.logger.Infof("Dequeued task %s from queue %s", task.ID, queueKey)
+	q.logger.Infof("Dequeued task %s from queue %s", task.ID, taskQueueKey(task))
 	return task, nil
 }
 
+// AckTask ends the lease of a finished task. It returns core.ErrLeaseExpired
+// if the lease ended before, in which case the task has been requeued.
 func (q *RedisQueue) AckTask(ctx context.Context, task *core.Task) error {
-	processingKey := fmt.Sprintf("processing:%s", task.Type)
-	
-	taskJSON, err := task.ToJSON()
-	if err != nil {
-		return fmt.Errorf("failed to serialize task: %w", err)
-	}
-
-	err = q.client.LRem(ctx, processingKey, 1, string(taskJSON)).Err()
-	if err != nil {
+	if err := q.settleLease(ctx, task.Type, task.ID, task.ReceiptHandle, "", "", nil, true); err != nil {
 		return fmt.Errorf("failed to acknowledge task: %w", err)
 	}
 
@@ -93,31 +237,39 @@ func (q *RedisQueue) AckTask(ctx context.Context, task *core.Task) error {
 	return nil
 }
 
-func (q *RedisQueue) NackTask(ctx context.Context, task *core.Task) error {
-	processingKey := fmt.Sprintf("processing:%s", task.Type)
+// NackTask ends the lease of a failed task and schedules its retry, or
+// dead-letters it once its retries are used up. If a fast fail retry
+// overload policy sheds the task instead, its lease is ended and an error
+// wrapping core.ErrRetryShed is returned; the task should be failed.
+func (q *RedisQueue) NackTask(ctx context.Context, task *core.Task, errorMsg string) error {
 	retryKey := fmt.Sprintf("retry:%s", task.Type)
-	
-	taskJSON, err := task.ToJSON()
-	if err != nil {
-		return fmt.Errorf("failed to serialize task: %w", err)
+
+	if task.RetryCount >= task.MaxRetries {
+		return q.deadLetter(ctx, task, errorMsg)
 	}
 
-	pipe := q.client.Pipeline()
-	pipe.LRem(ctx, processingKey, 1, string(taskJSON))
-	
-	if task.RetryCount < task.MaxRetries {
-		retryAt := time.Now().Add(q.calculateBackoff(task.RetryCount))
-		pipe.ZAdd(ctx, retryKey, &redis.Z{
-			Score:  float64(retryAt.Unix()),
-			Member: string(taskJSON),
-		})
-	} else {
-		deadLetterKey := fmt.Sprintf("dead_letter:%s", task.Type)
-		pipe.LPush(ctx, deadLetterKey, string(taskJSON))
+	if shedErr := q.checkRetryOverload(ctx, task); shedErr != nil {
+		if err := q.settleLease(ctx, task.Type, task.ID, task.ReceiptHandle, "", "", nil, true); err != nil {
+			return fmt.Errorf("failed to nack task: %w", err)
+		}
+		q.logger.Warnf("Not retrying task %s: %v", task.ID, shedErr)
+		return shedErr
 	}
 
-	_, err = pipe.Exec(ctx)
+	delay := q.calculateBackoff(task)
+
+	retryTask := *task
+	retryTask.RetryCount++
+	retryTask.Error = errorMsg
+	retryTask.RetryDelay = delay
+
+	retryJSON, err := retryTask.ToJSON()
 	if err != nil {
+		return fmt.Errorf("failed to serialize task: %w", err)
+	}
+
+	retryAt := float64(time.Now().Add(delay).Unix())
+	if err := q.settleLease(ctx, task.Type, task.ID, task.ReceiptHandle, retryKey, string(retryJSON), &retryAt, true); err != nil {
 		return fmt.Errorf("failed to nack task: %w", err)
 	}
 
@@ -125,78 +277,205 @@ func (q *RedisQueue) NackTask(ctx context.Context, task *core.Task) error {
 	return nil
 }
 
+// RequeueTask ends the lease of a task its worker did not finish, such as
+// one another delivery of which is running, and puts it in the retry set to
+// be queued again after delay. Unlike NackTask it counts no attempt: the
+// task keeps its retry count and is never dead-lettered.
+func (q *RedisQueue) RequeueTask(ctx context.Context, task *core.Task, delay time.Duration) error {
+	retryKey := fmt.Sprintf("retry:%s", task.Type)
+
+	requeued := *task
+	requeued.RetryDelay = delay
+
+	requeuedJSON, err := requeued.ToJSON()
+	if err != nil {
+		return fmt.Errorf("failed to serialize task: %w", err)
+	}
+
+	retryAt := float64(time.Now().Add(delay).Unix())
+	if err := q.settleLease(ctx, task.Type, task.ID, task.ReceiptHandle, retryKey, string(requeuedJSON), &retryAt, true); err != nil {
+		return fmt.Errorf("failed to requeue task: %w", err)
+	}
+
+	q.logger.Infof("Requeued task %s in %s (retry count: %d)", task.ID, delay, task.RetryCount)
+	return nil
+}
+
 func (q *RedisQueue) ProcessRetries(ctx context.Context, taskType string) error {
-	retryKey := fmt.Sprintf("retry:%s", taskType)
-	queueKey := fmt.Sprintf("queue:%s", taskType)
-	
-	now := float64(time.Now().Unix())
-	
-	result, err := q.client.ZRangeByScore(ctx, retryKey, &redis.ZRangeBy{
-		Min:   "0",
-		Max:   fmt.Sprintf("%f", now),
-		Count: 100,
-	}).Result()
-	
+	return q.promoteDue(ctx, fmt.Sprintf("retry:%s", taskType), "retry", taskRetryLaneKey)
+}
+
+func (q *RedisQueue) GetRetryTime(ctx context.Context, task *core.Task) (*time.Time, error) {
+	retryKey := fmt.Sprintf("retry:%s", task.Type)
+
+	var entries []redis.Z
+	err := retryRead(ctx, func() (err error) {
+		entries, err = q.client.ZRangeWithScores(ctx, retryKey, 0, -1).Result()
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("failed to get retry tasks: %w", err)
+		return nil, fmt.Errorf("failed to get retry tasks: %w", err)
 	}
 
-	for _, taskJSON := range result {
-		task, err := core.TaskFromJSON([]byte(taskJSON))
-		if err != nil {
-			q.logger.Errorf("Failed to deserialize retry task: %v", err)
+	for _, entry := range entries {
+		member, ok := entry.Member.(string)
+		if !ok {
 			continue
 		}
 
-		pipe := q.client.Pipeline()
-		pipe.ZRem(ctx, retryKey, taskJSON)
-		pipe.LPush(ctx, queueKey, taskJSON)
-		
-		_, err = pipe.Exec(ctx)
-		if err != nil {
-			q.logger.Errorf("Failed to requeue retry task %s: %v", task.ID, err)
+		retryTask, err := core.TaskFromJSON([]byte(member))
+		if err != nil || retryTask.ID != task.ID {
 			continue
 		}
 
-		q.logger.Infof("Requeued retry task %s", task.ID)
+		retryAt := time.Unix(int64(entry.Score), 0)
+		return &retryAt, nil
 	}
 
-	return nil
+	return nil, nil
 }
 
 func (q *RedisQueue) GetQueueStats(ctx context.Context, taskType string) (map[string]int64, error) {
-	queueKey := fmt.Sprintf("queue:%s", taskType)
-	processingKey := fmt.Sprintf("processing:%s", taskType)
 	retryKey := fmt.Sprintf("retry:%s", taskType)
 	deadLetterKey := fmt.Sprintf("dead_letter:%s", taskType)
 
+	scopedQueues, err := q.scopedQueueKeys(ctx, taskType)
+	if err != nil {
+		return nil, err
+	}
+
 	pipe := q.client.Pipeline()
-	queueLen := pipe.LLen(ctx, queueKey)
-	processingLen := pipe.LLen(ctx, processingKey)
+	queueLen := pipe.ZCard(ctx, priorityQueueKey(taskType))
+	laneLen := pipe.ZCard(ctx, retryLaneKey(taskType))
+	legacyLen := pipe.LLen(ctx, legacyQueueKey(taskType))
+	scopedLens := make([]*redis.IntCmd, len(scopedQueues))
+	for i, scopedQueue := range scopedQueues {
+		scopedLens[i] = pipe.ZCard(ctx, scopedQueue)
+	}
+	processingLen := pipe.ZCard(ctx, visibilityKey(taskType))
 	retryLen := pipe.ZCard(ctx, retryKey)
+	delayedLen := pipe.ZCard(ctx, delayedKey(taskType))
 	deadLetterLen := pipe.LLen(ctx, deadLetterKey)
+	poisonLen := pipe.LLen(ctx, poisonKey(taskType))
 
-	_, err := pipe.Exec(ctx)
+	err = retryRead(ctx, func() error {
+		_, err := pipe.Exec(ctx)
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get queue stats: %w", err)
 	}
 
+	pending := queueLen.Val() + laneLen.Val() + legacyLen.Val()
+	for _, scopedLen := range scopedLens {
+		pending += scopedLen.Val()
+	}
+
 	return map[string]int64{
-		"pending":     queueLen.Val(),
+		"pending":     pending,
 		"processing":  processingLen.Val(),
 		"retry":       retryLen.Val(),
+		"delayed":     delayedLen.Val(),
 		"dead_letter": deadLetterLen.Val(),
+		"poison":      poisonLen.Val(),
 	}, nil
 }
 
+// GetTaskTypes returns every task type that has a queue, retry set, dead
+// letter queue, lease or registered worker in Redis.
+func (q *RedisQueue) GetTaskTypes(ctx context.Context) ([]string, error) {
+	prefixes := []string{
+		priorityQueueKey(""),
+		retryLaneKey(""),
+		legacyQueueKey(""),
+		"retry:",
+		delayedKey(""),
+		"dead_letter:",
+		visibilityKey(""),
+		"workers:",
+	}
+
+	seen := make(map[string]bool)
+	var taskTypes []string
+	for _, prefix := range prefixes {
+		keys, err := q.scanKeys(ctx, prefix+"*")
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			taskType := strings.TrimPrefix(key, prefix)
+			if !seen[taskType] {
+				seen[taskType] = true
+				taskTypes = append(taskTypes, taskType)
+			}
+		}
+	}
+
+	sort.Strings(taskTypes)
+	return taskTypes, nil
+}
+
+func (q *RedisQueue) PauseQueue(ctx context.Context, taskType string) error {
+	if err := q.client.SAdd(ctx, "paused_queues", taskType).Err(); err != nil {
+		return fmt.Errorf("failed to pause queue: %w", err)
+	}
+
+	q.logger.Infof("Paused queue for task type %s", taskType)
+	return nil
+}
+
+func (q *RedisQueue) ResumeQueue(ctx context.Context, taskType string) error {
+	if err := q.client.SRem(ctx, "paused_queues", taskType).Err(); err != nil {
+		return fmt.Errorf("failed to resume queue: %w", err)
+	}
+
+	q.logger.Infof("Resumed queue for task type %s", taskType)
+	return nil
+}
+
+func (q *RedisQueue) IsQueuePaused(ctx context.Context, taskType string) (bool, error) {
+	var paused bool
+	err := retryRead(ctx, func() (err error) {
+		paused, err = q.client.SIsMember(ctx, "paused_queues", taskType).Result()
+		return err
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to check queue pause state: %w", err)
+	}
+	return paused, nil
+}
+
+func (q *RedisQueue) GetPausedQueues(ctx context.Context) ([]string, error) {
+	var taskTypes []string
+	err := retryRead(ctx, func() (err error) {
+		taskTypes, err = q.client.SMembers(ctx, "paused_queues").Result()
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get paused queues: %w", err)
+	}
+	return taskTypes, nil
+}
+
+// SetWorkerSlots records, with the registration of the workers using this
+// queue, how many tasks of each type they run at once.
+func (q *RedisQueue) SetWorkerSlots(slots map[string]int) {
+	q.slots = slots
+}
+
 func (q *RedisQueue) RegisterWorker(ctx context.Context, workerID, address string, taskTypes []string) error {
 	workerKey := fmt.Sprintf("worker:%s", workerID)
-	
+
 	workerInfo := core.WorkerInfo{
 		ID:            workerID,
 		Address:       address,
 		TaskTypes:     taskTypes,
-		Status:        "active",
+		Pool:          q.pool,
+		Namespaces:    q.namespaces,
+		Labels:        q.labels,
+		Slots:         q.slots,
+		Capacity:      q.capacity,
+		Status:        core.WorkerStatusActive,
 		LastHeartbeat: time.Now(),
 		CurrentTasks:  []string{},
 	}
@@ -211,6 +490,12 @@ func (q *RedisQueue) RegisterWorker(ctx context.Context, workerID, address strin
 		return fmt.Errorf("failed to register worker: %w", err)
 	}
 
+	// From now on tasks of the worker's namespaces and types are queued for
+	// it and the other workers of those namespaces alone.
+	if err := q.dedicateNamespaces(ctx, q.namespaces, taskTypes); err != nil {
+		return err
+	}
+
 	for _, taskType := range taskTypes {
 		workerSetKey := fmt.Sprintf("workers:%s", taskType)
 		err = q.client.SAdd(ctx, workerSetKey, workerID).Err()
@@ -223,9 +508,28 @@ func (q *RedisQueue) RegisterWorker(ctx context.Context, workerID, address strin
 	return nil
 }
 
+// DeregisterWorker removes a worker that is shutting down from the
+// registry, so it is no longer listed or counted for its task types. The
+// worker acks or nacks its tasks before deregistering; a lease it failed to
+// release is requeued once its visibility timeout expires.
+func (q *RedisQueue) DeregisterWorker(ctx context.Context, workerID string, taskTypes []string) error {
+	if err := q.client.Del(ctx, fmt.Sprintf("worker:%s", workerID)).Err(); err != nil {
+		return fmt.Errorf("failed to deregister worker: %w", err)
+	}
+
+	for _, taskType := range taskTypes {
+		if err := q.client.SRem(ctx, fmt.Sprintf("workers:%s", taskType), workerID).Err(); err != nil {
+			return fmt.Errorf("failed to remove worker from task type %s: %w", taskType, err)
+		}
+	}
+
+	q.logger.Infof("Deregistered worker %s", workerID)
+	return nil
+}
+
 func (q *RedisQueue) UpdateWorkerHeartbeat(ctx context.Context, workerID string) error {
 	workerKey := fmt.Sprintf("worker:%s", workerID)
-	
+
 	workerJSON, err := q.client.Get(ctx, workerKey).Result()
 	if err != nil {
 		return fmt.Errorf("failed to get worker info: %w", err)
@@ -248,12 +552,17 @@ func (q *RedisQueue) UpdateWorkerHeartbeat(ctx context.Context, workerID string)
 		return fmt.Errorf("failed to update worker heartbeat: %w", err)
 	}
 
-	return nil
+	// The janitor stops dedicating namespaces to a worker it found stale,
+	// so a worker that recovers claims them again.
+	return q.dedicateNamespaces(ctx, workerInfo.Namespaces, workerInfo.TaskTypes)
 }
 
+// GetActiveWorkers lists live workers for a task type with the tasks each
+// one holds. Stale workers are skipped here and removed, with their tasks
+// requeued, by ExpireStaleWorkers.
 func (q *RedisQueue) GetActiveWorkers(ctx context.Context, taskType string) ([]core.WorkerInfo, error) {
 	workerSetKey := fmt.Sprintf("workers:%s", taskType)
-	
+
 	workerIDs, err := q.client.SMembers(ctx, workerSetKey).Result()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get worker IDs: %w", err)
@@ -261,36 +570,96 @@ func (q *RedisQueue) GetActiveWorkers(ctx context.Context, taskType string) ([]c
 
 	var workers []core.WorkerInfo
 	for _, workerID := range workerIDs {
-		workerKey := fmt.Sprintf("worker:%s", workerID)
-		workerJSON, err := q.client.Get(ctx, workerKey).Result()
+		workerInfo, err := q.GetWorker(ctx, workerID)
 		if err != nil {
-			if err == redis.Nil {
-				q.client.SRem(ctx, workerSetKey, workerID)
-				continue
-			}
 			q.logger.Errorf("Failed to get worker %s info: %v", workerID, err)
 			continue
 		}
-
-		var workerInfo core.WorkerInfo
-		if err := json.Unmarshal([]byte(workerJSON), &workerInfo); err != nil {
-			q.logger.Errorf("Failed to unmarshal worker %s info: %v", workerID, err)
+		if workerInfo == nil || workerInfo.Status == core.WorkerStatusStale {
 			continue
 		}
 
-		if time.Since(workerInfo.LastHeartbeat) > time.Minute*2 {
-			q.client.SRem(ctx, workerSetKey, workerID)
-			q.client.Del(ctx, workerKey)
+		workers = append(workers, *workerInfo)
+	}
+
+	return workers, nil
+}
+
+// ListWorkers returns every registered worker, ordered by ID, including
+// workers whose heartbeat is stale but that have not been expired yet.
+func (q *RedisQueue) ListWorkers(ctx context.Context) ([]core.WorkerInfo, error) {
+	workerKeys, err := q.scanKeys(ctx, "worker:*")
+	if err != nil {
+		return nil, err
+	}
+
+	workers := []core.WorkerInfo{}
+	for _, workerKey := range workerKeys {
+		workerID := strings.TrimPrefix(workerKey, "worker:")
+		workerInfo, err := q.GetWorker(ctx, workerID)
+		if err != nil {
+			q.logger.Errorf("Failed to get worker %s info: %v", workerID, err)
 			continue
 		}
-
-		workers = append(workers, workerInfo)
+		if workerInfo == nil {
+			continue
+		}
+		workers = append(workers, *workerInfo)
 	}
 
+	sort.Slice(workers, func(i, j int) bool {
+		return workers[i].ID < workers[j].ID
+	})
 	return workers, nil
 }
 
-func (q *RedisQueue) calculateBackoff(retryCount int) time.Duration {
+// GetWorker returns a registered worker with the tasks it holds and the
+// resources they request, or nil if no worker with that ID is registered. A worker whose heartbeat is older
+// than the heartbeat timeout is reported as stale.
+func (q *RedisQueue) GetWorker(ctx context.Context, workerID string) (*core.WorkerInfo, error) {
+	workerJSON, err := q.client.Get(ctx, fmt.Sprintf("worker:%s", workerID)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get worker info: %w", err)
+	}
+
+	var workerInfo core.WorkerInfo
+	if err := json.Unmarshal([]byte(workerJSON), &workerInfo); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal worker info: %w", err)
+	}
+
+	if time.Since(workerInfo.LastHeartbeat) > workerHeartbeatTimeout {
+		workerInfo.Status = core.WorkerStatusStale
+	}
+
+	workerInfo.CurrentTasks, err = q.GetWorkerTasks(ctx, workerID)
+	if err != nil {
+		q.logger.Errorf("Failed to get tasks of worker %s: %v", workerID, err)
+		workerInfo.CurrentTasks = []string{}
+	}
+
+	if len(workerInfo.CurrentTasks) > 0 || workerInfo.Capacity != nil {
+		allocated, err := q.workerAllocation(ctx, workerID)
+		if err != nil {
+			q.logger.Errorf("Failed to get resources allocated on worker %s: %v", workerID, err)
+		} else {
+			workerInfo.SetAllocated(allocated)
+		}
+	}
+
+	return &workerInfo, nil
+}
+
+// calculateBackoff follows the retry policy of the task's workflow. Tasks
+// enqueued without one keep the original fixed backoff.
+func (q *RedisQueue) calculateBackoff(task *core.Task) time.Duration {
+	if task.RetryPolicy != nil {
+		return task.RetryPolicy.Delay(task.RetryCount, task.RetryDelay)
+	}
+
+	retryCount := task.RetryCount
 	base := time.Second * 2
 	backoff := base * time.Duration(1<<uint(retryCount))
 	if backoff > time.Minute*5 {
@@ -0,0 +1,171 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"flowctl/internal/core"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const retryOverloadPolicyKey = "retry_overload_policies"
+
+func (q *RedisQueue) SetRetryOverloadPolicy(ctx context.Context, policy core.RetryOverloadPolicy) error {
+	policyJSON, err := json.Marshal(policy)
+	if err != nil {
+		return fmt.Errorf("failed to serialize retry overload policy: %w", err)
+	}
+
+	if err := q.client.HSet(ctx, retryOverloadPolicyKey, policy.TaskType, policyJSON).Err(); err != nil {
+		return fmt.Errorf("failed to set retry overload policy: %w", err)
+	}
+
+	q.logger.Infof("Set retry overload policy for task type %s: max %d, %s", policy.TaskType, policy.MaxSize, policy.Action)
+	return nil
+}
+
+func (q *RedisQueue) DeleteRetryOverloadPolicy(ctx context.Context, taskType string) error {
+	if err := q.client.HDel(ctx, retryOverloadPolicyKey, taskType).Err(); err != nil {
+		return fmt.Errorf("failed to delete retry overload policy: %w", err)
+	}
+	return nil
+}
+
+func (q *RedisQueue) GetRetryOverloadPolicy(ctx context.Context, taskType string) (*core.RetryOverloadPolicy, error) {
+	policyJSON, err := q.client.HGet(ctx, retryOverloadPolicyKey, taskType).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get retry overload policy: %w", err)
+	}
+
+	var policy core.RetryOverloadPolicy
+	if err := json.Unmarshal([]byte(policyJSON), &policy); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal retry overload policy: %w", err)
+	}
+
+	return &policy, nil
+}
+
+func (q *RedisQueue) GetRetryOverloadPolicies(ctx context.Context) ([]core.RetryOverloadPolicy, error) {
+	entries, err := q.client.HGetAll(ctx, retryOverloadPolicyKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get retry overload policies: %w", err)
+	}
+
+	policies := make([]core.RetryOverloadPolicy, 0, len(entries))
+	for taskType, policyJSON := range entries {
+		var policy core.RetryOverloadPolicy
+		if err := json.Unmarshal([]byte(policyJSON), &policy); err != nil {
+			q.logger.Errorf("Failed to unmarshal retry overload policy for %s: %v", taskType, err)
+			continue
+		}
+		policies = append(policies, policy)
+	}
+
+	sort.Slice(policies, func(i, j int) bool { return policies[i].TaskType < policies[j].TaskType })
+	return policies, nil
+}
+
+// checkRetryOverload returns an error wrapping core.ErrRetryShed if a fast
+// fail policy sheds the task because its retry set is full.
+func (q *RedisQueue) checkRetryOverload(ctx context.Context, task *core.Task) error {
+	policy, err := q.GetRetryOverloadPolicy(ctx, task.Type)
+	if err != nil {
+		// Retry as usual rather than fail a task over a policy lookup.
+		q.logger.Errorf("Failed to check retry overload for task %s: %v", task.ID, err)
+		return nil
+	}
+	if policy == nil || policy.Action != core.RetryOverloadFastFail || !policy.Sheds(task.Priority) {
+		return nil
+	}
+
+	size, err := q.client.ZCard(ctx, fmt.Sprintf("retry:%s", task.Type)).Result()
+	if err != nil {
+		q.logger.Errorf("Failed to check retry overload for task %s: %v", task.ID, err)
+		return nil
+	}
+	if size < policy.MaxSize {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %d tasks of type %s are waiting to retry", core.ErrRetryShed, size, task.Type)
+}
+
+// EvictRetries trims a task type's retry set to the policy's MaxSize,
+// removing the lowest-priority tasks first and, among equals, those due to
+// retry last. Protected tasks are never removed, so more than MaxSize may
+// remain. It returns the tasks it removed.
+func (q *RedisQueue) EvictRetries(ctx context.Context, policy core.RetryOverloadPolicy) ([]core.Task, error) {
+	retryKey := fmt.Sprintf("retry:%s", policy.TaskType)
+
+	size, err := q.client.ZCard(ctx, retryKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get retry set size: %w", err)
+	}
+	if size <= policy.MaxSize {
+		return nil, nil
+	}
+
+	entries, err := q.client.ZRangeWithScores(ctx, retryKey, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get retry tasks: %w", err)
+	}
+
+	type candidate struct {
+		member string
+		retry  float64
+		task   *core.Task
+	}
+	var candidates []candidate
+	for _, entry := range entries {
+		member, ok := entry.Member.(string)
+		if !ok {
+			continue
+		}
+		task, err := core.TaskFromJSON([]byte(member))
+		if err != nil || !policy.Sheds(task.Priority) {
+			continue
+		}
+		candidates = append(candidates, candidate{member: member, retry: entry.Score, task: task})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].task.Priority != candidates[j].task.Priority {
+			return candidates[i].task.Priority < candidates[j].task.Priority
+		}
+		return candidates[i].retry > candidates[j].retry
+	})
+
+	excess := int(int64(len(entries)) - policy.MaxSize)
+	if excess > len(candidates) {
+		excess = len(candidates)
+	}
+	candidates = candidates[:excess]
+
+	pipe := q.client.Pipeline()
+	removals := make([]*redis.IntCmd, len(candidates))
+	for i, c := range candidates {
+		removals[i] = pipe.ZRem(ctx, retryKey, c.member)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to evict retry tasks: %w", err)
+	}
+
+	// A task promoted meanwhile is no longer waiting and is not evicted.
+	evicted := make([]core.Task, 0, len(candidates))
+	for i, c := range candidates {
+		if removals[i].Val() > 0 {
+			evicted = append(evicted, *c.task)
+		}
+	}
+
+	if len(evicted) > 0 {
+		q.logger.Warnf("Evicted %d tasks from overloaded retry set %s", len(evicted), retryKey)
+	}
+	return evicted, nil
+}
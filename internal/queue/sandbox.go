@@ -0,0 +1,55 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"flowctl/internal/core"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const sandboxPolicyKey = "sandbox_policies"
+
+func (q *RedisQueue) SetSandboxPolicy(ctx context.Context, policy core.SandboxPolicy) error {
+	policyJSON, err := json.Marshal(policy)
+	if err != nil {
+		return fmt.Errorf("failed to serialize sandbox policy: %w", err)
+	}
+
+	if err := q.client.HSet(ctx, sandboxPolicyKey, policy.Namespace, policyJSON).Err(); err != nil {
+		return fmt.Errorf("failed to set sandbox policy: %w", err)
+	}
+
+	q.logger.Infof("Set sandbox policy for namespace %s: executors %v", policy.Namespace, policy.AllowedExecutors)
+	return nil
+}
+
+func (q *RedisQueue) DeleteSandboxPolicy(ctx context.Context, namespace string) error {
+	if err := q.client.HDel(ctx, sandboxPolicyKey, namespace).Err(); err != nil {
+		return fmt.Errorf("failed to delete sandbox policy: %w", err)
+	}
+	return nil
+}
+
+func (q *RedisQueue) GetSandboxPolicy(ctx context.Context, namespace string) (*core.SandboxPolicy, error) {
+	var policyJSON string
+	err := retryRead(ctx, func() (err error) {
+		policyJSON, err = q.client.HGet(ctx, sandboxPolicyKey, namespace).Result()
+		return err
+	})
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get sandbox policy: %w", err)
+	}
+
+	var policy core.SandboxPolicy
+	if err := json.Unmarshal([]byte(policyJSON), &policy); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal sandbox policy: %w", err)
+	}
+
+	return &policy, nil
+}
@@ -0,0 +1,77 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"flowctl/internal/core"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// oldestEnqueuedScript returns the earliest enqueue time, in milliseconds,
+// of the tasks in the queue KEYS[1], or nil when it is empty. Queues are
+// ordered by priority first, so it takes the head of each priority level in
+// turn, jumping ARGV[1], the priority score scale, from one level to the
+// next, rather than reading every task.
+var oldestEnqueuedScript = redis.NewScript(`
+local scale = tonumber(ARGV[1])
+local oldest = false
+local min = "-inf"
+while true do
+	local head = redis.call("ZRANGEBYSCORE", KEYS[1], min, "+inf", "WITHSCORES", "LIMIT", 0, 1)
+	if #head == 0 then
+		break
+	end
+	local level = math.floor(tonumber(head[2]) / scale)
+	local enqueued = tonumber(head[2]) - level * scale
+	if not oldest or enqueued < oldest then
+		oldest = enqueued
+	end
+	min = string.format("%.0f", (level + 1) * scale)
+end
+return oldest
+`)
+
+// GetOldestEnqueuedAt returns when the longest-waiting task of a type that
+// is ready to run was enqueued, across its shared, pool and namespace queues
+// and their retry lanes, or nil when none is waiting. Delayed tasks and
+// retries that are not yet due are not ready, so they do not count.
+func (q *RedisQueue) GetOldestEnqueuedAt(ctx context.Context, taskType string) (*time.Time, error) {
+	scopedQueues, err := q.scopedQueueKeys(ctx, taskType)
+	if err != nil {
+		return nil, err
+	}
+	keys := append([]string{priorityQueueKey(taskType), retryLaneKey(taskType)}, scopedQueues...)
+
+	scale := fmt.Sprintf("%.0f", float64(priorityScoreScale))
+	var oldest *time.Time
+	for _, key := range keys {
+		millis, err := oldestEnqueuedScript.Run(ctx, q.client, []string{key}, scale).Int64()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to find oldest task in %s: %w", key, err)
+		}
+		if enqueuedAt := time.UnixMilli(millis); oldest == nil || enqueuedAt.Before(*oldest) {
+			oldest = &enqueuedAt
+		}
+	}
+
+	// The legacy list is drained from its right end, where its oldest task
+	// waits.
+	member, err := q.client.LIndex(ctx, legacyQueueKey(taskType), -1).Result()
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to read legacy queue of %s: %w", taskType, err)
+	}
+	if err == nil {
+		task, err := core.TaskFromJSON([]byte(member))
+		if err == nil && task.EnqueuedAt != nil && (oldest == nil || task.EnqueuedAt.Before(*oldest)) {
+			oldest = task.EnqueuedAt
+		}
+	}
+
+	return oldest, nil
+}
@@ -0,0 +1,139 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"flowctl/internal/core"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// selectorsKey is the set of label selectors, as written by
+// core.FormatSelector, that tasks have been queued with.
+const selectorsKey = "task_selectors"
+
+// A labeled worker reloads the selectors tasks are queued with every
+// selectorRefreshInterval.
+const selectorRefreshInterval = time.Second
+
+// labeledQueueKey is the sub-queue of queue, or of its retry lane, that
+// tasks with selector wait in. Only workers whose labels match the
+// selector dequeue from it.
+func labeledQueueKey(selector, queue string) string {
+	return fmt.Sprintf("labeled:%s:%s", selector, queue)
+}
+
+// taskLabeledKey returns the labeled sub-queue of queue for a task's
+// selector, or queue itself for a task without one.
+func taskLabeledKey(task *core.Task, queue string) string {
+	if len(task.Selector) == 0 {
+		return queue
+	}
+	return labeledQueueKey(core.FormatSelector(task.Selector), queue)
+}
+
+// SetWorkerLabels registers the workers using this queue with labels. Besides
+// the tasks without a selector, they then dequeue the tasks whose selector
+// their labels match.
+func (q *RedisQueue) SetWorkerLabels(labels map[string]string) {
+	q.labels = labels
+}
+
+// WorkerLabels returns the labels set with SetWorkerLabels.
+func (q *RedisQueue) WorkerLabels() map[string]string {
+	return q.labels
+}
+
+// matchingSelectors returns the selectors tasks are queued with that the
+// worker's labels match. A worker without labels matches none.
+func (q *RedisQueue) matchingSelectors(ctx context.Context) ([]string, error) {
+	if len(q.labels) == 0 {
+		return nil, nil
+	}
+
+	q.selectorMu.Lock()
+	defer q.selectorMu.Unlock()
+
+	if time.Since(q.selectorsAt) < selectorRefreshInterval {
+		return q.selectorCache, nil
+	}
+
+	selectors, err := q.client.SMembers(ctx, selectorsKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task selectors: %w", err)
+	}
+
+	// Callers may still hold the old slice, so it is replaced rather than
+	// reused.
+	matching := make([]string, 0, len(selectors))
+	for _, formatted := range selectors {
+		selector, err := core.ParseSelector(formatted)
+		if err != nil {
+			q.logger.Warnf("Ignoring task selector: %v", err)
+			continue
+		}
+		if core.SelectorMatches(selector, q.labels) {
+			matching = append(matching, formatted)
+		}
+	}
+	q.selectorCache = matching
+	q.selectorsAt = time.Now()
+	return q.selectorCache, nil
+}
+
+// labeledQueues returns the labeled sub-queues of queues, each a queue and
+// its retry lane, for the given selectors.
+func labeledQueues(queues [][2]string, selectors []string) [][2]string {
+	labeled := make([][2]string, 0, len(queues)*len(selectors))
+	for _, selector := range selectors {
+		for _, queue := range queues {
+			labeled = append(labeled, [2]string{labeledQueueKey(selector, queue[0]), labeledQueueKey(selector, queue[1])})
+		}
+	}
+	return labeled
+}
+
+// GetSelectorBacklog counts the ready tasks of a type in the labeled
+// sub-queues of each known selector, leaving out selectors with none.
+func (q *RedisQueue) GetSelectorBacklog(ctx context.Context, taskType string) (map[string]int64, error) {
+	selectors, err := q.client.SMembers(ctx, selectorsKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task selectors: %w", err)
+	}
+	backlog := make(map[string]int64)
+	if len(selectors) == 0 {
+		return backlog, nil
+	}
+
+	scopes, err := q.unlabeledQueueKeys(ctx, taskType)
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := q.client.Pipeline()
+	lens := make(map[string][]*redis.IntCmd, len(selectors))
+	for _, selector := range selectors {
+		for _, scope := range scopes {
+			lens[selector] = append(lens[selector], pipe.ZCard(ctx, labeledQueueKey(selector, scope)))
+		}
+	}
+	err = retryRead(ctx, func() error {
+		_, err := pipe.Exec(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to count labeled queues: %w", err)
+	}
+
+	for selector, cmds := range lens {
+		for _, cmd := range cmds {
+			backlog[selector] += cmd.Val()
+		}
+		if backlog[selector] == 0 {
+			delete(backlog, selector)
+		}
+	}
+	return backlog, nil
+}
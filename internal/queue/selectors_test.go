@@ -0,0 +1,41 @@
+package queue
+
+import (
+	"reflect"
+	"testing"
+
+	"flowctl/internal/core"
+)
+
+func TestTaskQueueKeyRoutesSelectorsToLabeledQueues(t *testing.T) {
+	gpu := map[string]string{"zone": "us-east-1", "gpu": "true"}
+	tests := []struct {
+		name string
+		task core.Task
+		want string
+	}{
+		{"shared", core.Task{Type: "etl"}, "priority_queue:etl"},
+		{"shared with selector", core.Task{Type: "etl", Selector: gpu}, "labeled:gpu=true,zone=us-east-1:priority_queue:etl"},
+		{"pool with selector", core.Task{Type: "etl", Pool: "batch", Selector: gpu}, "labeled:gpu=true,zone=us-east-1:" + poolQueueKey("batch", "etl")},
+		{"namespace with selector", core.Task{Type: "etl", QueueNamespace: "team-a", Selector: gpu}, "labeled:gpu=true,zone=us-east-1:" + namespaceQueueKey("team-a", "etl")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := taskQueueKey(&tt.task); got != tt.want {
+				t.Errorf("taskQueueKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLabeledQueuesCoverEveryQueueAndLane(t *testing.T) {
+	queues := [][2]string{{"priority_queue:etl", retryLaneKey("etl")}}
+	got := labeledQueues(queues, []string{"gpu=true", "zone=us-east-1"})
+	want := [][2]string{
+		{"labeled:gpu=true:priority_queue:etl", "labeled:gpu=true:" + retryLaneKey("etl")},
+		{"labeled:zone=us-east-1:priority_queue:etl", "labeled:zone=us-east-1:" + retryLaneKey("etl")},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("labeledQueues() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,47 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"flowctl/internal/core"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const submissionTTL = time.Hour * 24
+
+func (q *RedisQueue) SaveSubmission(ctx context.Context, submission *core.Submission) error {
+	submissionJSON, err := json.Marshal(submission)
+	if err != nil {
+		return fmt.Errorf("failed to serialize submission: %w", err)
+	}
+
+	key := fmt.Sprintf("submission:%s", submission.ID)
+	if err := q.client.Set(ctx, key, submissionJSON, submissionTTL).Err(); err != nil {
+		return fmt.Errorf("failed to save submission: %w", err)
+	}
+
+	return nil
+}
+
+func (q *RedisQueue) GetSubmission(ctx context.Context, id string) (*core.Submission, error) {
+	key := fmt.Sprintf("submission:%s", id)
+
+	submissionJSON, err := q.client.Get(ctx, key).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, fmt.Errorf("submission not found: %s", id)
+		}
+		return nil, fmt.Errorf("failed to get submission: %w", err)
+	}
+
+	var submission core.Submission
+	if err := json.Unmarshal([]byte(submissionJSON), &submission); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal submission: %w", err)
+	}
+
+	return &submission, nil
+}
@@ -0,0 +1,62 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// timersKey is the sorted set of the IDs of running wait tasks, scored by
+// when each is done waiting in Unix seconds.
+const timersKey = "timers"
+
+// AddTimer arms the timer of a wait task to fire at fireAt. Arming it again
+// moves it rather than adding a second one.
+func (q *RedisQueue) AddTimer(ctx context.Context, taskID string, fireAt time.Time) error {
+	err := q.client.ZAdd(ctx, timersKey, &redis.Z{
+		Score:  float64(fireAt.Unix()),
+		Member: taskID,
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("failed to arm timer of task %s: %w", taskID, err)
+	}
+	return nil
+}
+
+// GetDueTimers returns up to promoteBatch IDs of wait tasks whose timers
+// had fired by now. They stay armed until RemoveTimers disarms them.
+func (q *RedisQueue) GetDueTimers(ctx context.Context, now time.Time) ([]string, error) {
+	var ids []string
+	err := retryRead(ctx, func() (err error) {
+		ids, err = q.client.ZRangeByScore(ctx, timersKey, &redis.ZRangeBy{
+			Min:   "0",
+			Max:   fmt.Sprintf("%d", now.Unix()),
+			Count: promoteBatch,
+		}).Result()
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get due timers: %w", err)
+	}
+	return ids, nil
+}
+
+// RemoveTimers disarms the timers of wait tasks and returns how many were
+// armed.
+func (q *RedisQueue) RemoveTimers(ctx context.Context, taskIDs ...string) (int, error) {
+	if len(taskIDs) == 0 {
+		return 0, nil
+	}
+
+	members := make([]interface{}, len(taskIDs))
+	for i, id := range taskIDs {
+		members[i] = id
+	}
+	removed, err := q.client.ZRem(ctx, timersKey, members...).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to remove timers: %w", err)
+	}
+	return int(removed), nil
+}
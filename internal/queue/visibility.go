@@ -0,0 +1,89 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"flowctl/internal/core"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// visibilityKey holds, per task type, the receipt handle of every lease
+// scored by when its worker last touched it.
+func visibilityKey(taskType string) string {
+	return fmt.Sprintf("processing_since:%s", taskType)
+}
+
+// TouchTask tells the reaper the worker holding a task is still alive.
+// Workers call it periodically while a task runs. It returns
+// core.ErrLeaseExpired once the task's lease has ended, for example because
+// the reaper already requeued it.
+func (q *RedisQueue) TouchTask(ctx context.Context, task *core.Task) error {
+	exists, err := q.client.HExists(ctx, leasesKey, task.ReceiptHandle).Result()
+	if err != nil {
+		return fmt.Errorf("failed to touch task: %w", err)
+	}
+	if !exists {
+		return core.ErrLeaseExpired
+	}
+
+	err = q.client.ZAddXX(ctx, visibilityKey(task.Type), &redis.Z{
+		Score:  float64(time.Now().Unix()),
+		Member: task.ReceiptHandle,
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("failed to touch task: %w", err)
+	}
+	return nil
+}
+
+// RequeueExpiredTasks puts tasks back on their queue when the worker that
+// dequeued them has not touched them within the visibility timeout, which
+// usually means it died mid-task.
+func (q *RedisQueue) RequeueExpiredTasks(ctx context.Context, visibilityTimeout time.Duration) ([]core.Task, error) {
+	keys, err := q.scanKeys(ctx, visibilityKey("*"))
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().Add(-visibilityTimeout).Unix()
+
+	var requeued []core.Task
+	for _, key := range keys {
+		taskType := strings.TrimPrefix(key, visibilityKey(""))
+
+		receipts, err := q.client.ZRangeByScore(ctx, key, &redis.ZRangeBy{
+			Min: "-inf",
+			Max: fmt.Sprintf("%d", cutoff),
+		}).Result()
+		if err != nil {
+			return requeued, fmt.Errorf("failed to read expired %s tasks: %w", taskType, err)
+		}
+
+		for _, receipt := range receipts {
+			task, err := q.requeueExpired(ctx, taskType, receipt)
+			if err != nil {
+				q.logger.Errorf("Failed to requeue expired %s task: %v", taskType, err)
+				continue
+			}
+			if task != nil {
+				requeued = append(requeued, *task)
+			}
+		}
+	}
+
+	return requeued, nil
+}
+
+func (q *RedisQueue) requeueExpired(ctx context.Context, taskType, receipt string) (*core.Task, error) {
+	task, err := q.requeueLease(ctx, taskType, receipt, nil)
+	if err != nil || task == nil {
+		return nil, err
+	}
+
+	q.logger.Warnf("Requeued task %s after its visibility timeout expired", task.ID)
+	return task, nil
+}
@@ -0,0 +1,112 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"flowctl/internal/core"
+)
+
+// wakeChannel is the pub/sub channel nudged when a task of a type becomes
+// ready in a pool's queue, or in the shared queue when pool is empty.
+func wakeChannel(pool, taskType string) string {
+	if pool == "" {
+		return fmt.Sprintf("task_wake:%s", taskType)
+	}
+	return fmt.Sprintf("task_wake:%s:%s", pool, taskType)
+}
+
+// namespaceWakeChannel is the pub/sub channel nudged when a task of a type
+// becomes ready in a dedicated namespace's queue.
+func namespaceWakeChannel(namespace, taskType string) string {
+	return fmt.Sprintf("task_wake_ns:%s:%s", namespace, taskType)
+}
+
+// taskWakeChannel is the channel nudged when task becomes ready in the
+// queue taskQueueKey puts it in.
+func taskWakeChannel(task *core.Task) string {
+	if task.Pool == "" && task.QueueNamespace != "" {
+		return namespaceWakeChannel(task.QueueNamespace, task.Type)
+	}
+	return wakeChannel(task.Pool, task.Type)
+}
+
+// nudgeWorkers wakes idle workers waiting for tasks like task. Nudges are
+// best effort: an idle worker that misses one still polls its queue.
+func (q *RedisQueue) nudgeWorkers(ctx context.Context, task *core.Task) {
+	if err := q.client.Publish(ctx, taskWakeChannel(task), task.Type).Err(); err != nil {
+		q.logger.Warnf("Failed to nudge workers for task %s: %v", task.ID, err)
+	}
+}
+
+// SubscribeWakeups returns the task types, among taskTypes, for which new
+// work is ready in the queues this worker dequeues from.
+func (q *RedisQueue) SubscribeWakeups(ctx context.Context, taskTypes []string) <-chan string {
+	channels := make([]string, 0, len(taskTypes))
+	for _, taskType := range taskTypes {
+		if len(q.namespaces) == 0 {
+			channels = append(channels, wakeChannel(q.pool, taskType))
+			continue
+		}
+		for _, namespace := range q.namespaces {
+			channels = append(channels, namespaceWakeChannel(namespace, taskType))
+		}
+	}
+
+	pubsub := q.client.Subscribe(ctx, channels...)
+	wakeups := make(chan string)
+
+	go func() {
+		defer close(wakeups)
+		defer pubsub.Close()
+
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				select {
+				case wakeups <- msg.Payload:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return wakeups
+}
+
+// SetWorkerStatus records whether a registered worker is active or idle.
+func (q *RedisQueue) SetWorkerStatus(ctx context.Context, workerID, status string) error {
+	workerKey := fmt.Sprintf("worker:%s", workerID)
+
+	workerJSON, err := q.client.Get(ctx, workerKey).Result()
+	if err != nil {
+		return fmt.Errorf("failed to get worker info: %w", err)
+	}
+
+	var workerInfo core.WorkerInfo
+	if err := json.Unmarshal([]byte(workerJSON), &workerInfo); err != nil {
+		return fmt.Errorf("failed to unmarshal worker info: %w", err)
+	}
+
+	workerInfo.Status = status
+
+	updatedJSON, err := json.Marshal(workerInfo)
+	if err != nil {
+		return fmt.Errorf("failed to marshal updated worker info: %w", err)
+	}
+
+	if err := q.client.Set(ctx, workerKey, updatedJSON, time.Minute*5).Err(); err != nil {
+		return fmt.Errorf("failed to update worker status: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,200 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"flowctl/internal/core"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Webhooks are kept in a hash by ID. Deliveries waiting for their next
+// attempt are held in a sorted set scored by when it is due, and each
+// webhook's latest attempts in a capped list, newest first.
+const (
+	webhooksKey          = "webhooks"
+	webhookDeliveriesKey = "webhook_deliveries"
+	webhookLogSize       = 100
+)
+
+func webhookLogKey(webhookID string) string {
+	return fmt.Sprintf("webhook_log:%s", webhookID)
+}
+
+func (q *RedisQueue) SaveWebhook(ctx context.Context, webhook *core.Webhook) error {
+	webhookJSON, err := json.Marshal(webhook)
+	if err != nil {
+		return fmt.Errorf("failed to serialize webhook: %w", err)
+	}
+
+	if err := q.client.HSet(ctx, webhooksKey, webhook.ID, webhookJSON).Err(); err != nil {
+		return fmt.Errorf("failed to save webhook: %w", err)
+	}
+
+	q.logger.Infof("Registered webhook %s for %v", webhook.ID, webhook.Events)
+	return nil
+}
+
+// GetWebhook returns a webhook, or nil if no webhook has that ID.
+func (q *RedisQueue) GetWebhook(ctx context.Context, webhookID string) (*core.Webhook, error) {
+	webhookJSON, err := q.client.HGet(ctx, webhooksKey, webhookID).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+
+	var webhook core.Webhook
+	if err := json.Unmarshal([]byte(webhookJSON), &webhook); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal webhook: %w", err)
+	}
+	return &webhook, nil
+}
+
+// ListWebhooks returns every webhook, oldest first.
+func (q *RedisQueue) ListWebhooks(ctx context.Context) ([]core.Webhook, error) {
+	result, err := q.client.HGetAll(ctx, webhooksKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhooks: %w", err)
+	}
+
+	webhooks := make([]core.Webhook, 0, len(result))
+	for webhookID, webhookJSON := range result {
+		var webhook core.Webhook
+		if err := json.Unmarshal([]byte(webhookJSON), &webhook); err != nil {
+			q.logger.Errorf("Failed to unmarshal webhook %s: %v", webhookID, err)
+			continue
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	sort.Slice(webhooks, func(i, j int) bool {
+		return webhooks[i].CreatedAt.Before(webhooks[j].CreatedAt)
+	})
+	return webhooks, nil
+}
+
+// DeleteWebhook removes a webhook and its delivery log. It reports whether
+// the webhook existed. Deliveries still waiting for a retry are dropped
+// when they come due.
+func (q *RedisQueue) DeleteWebhook(ctx context.Context, webhookID string) (bool, error) {
+	deleted, err := q.client.HDel(ctx, webhooksKey, webhookID).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete webhook: %w", err)
+	}
+
+	if err := q.client.Del(ctx, webhookLogKey(webhookID)).Err(); err != nil {
+		q.logger.Errorf("Failed to delete delivery log of webhook %s: %v", webhookID, err)
+	}
+
+	return deleted > 0, nil
+}
+
+// ScheduleWebhookDelivery queues a delivery attempt for at.
+func (q *RedisQueue) ScheduleWebhookDelivery(ctx context.Context, delivery *core.WebhookDelivery, at time.Time) error {
+	deliveryJSON, err := json.Marshal(delivery)
+	if err != nil {
+		return fmt.Errorf("failed to serialize webhook delivery: %w", err)
+	}
+
+	err = q.client.ZAdd(ctx, webhookDeliveriesKey, &redis.Z{
+		Score:  float64(at.UnixMilli()),
+		Member: string(deliveryJSON),
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("failed to schedule webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// webhookClaimTimeout is how long a claimed delivery is hidden from other
+// claims. A scheduler that stops before completing it leaves it to be
+// claimed again once this has passed.
+const webhookClaimTimeout = time.Minute
+
+// claimWebhookDeliveriesScript pushes up to ARGV[2] deliveries due by
+// ARGV[1] back to ARGV[3], hiding them from other claims, and returns them.
+var claimWebhookDeliveriesScript = redis.NewScript(`
+local due = redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", ARGV[1], "LIMIT", 0, ARGV[2])
+for _, member in ipairs(due) do
+	redis.call("ZADD", KEYS[1], ARGV[3], member)
+end
+return due
+`)
+
+// ClaimDueWebhookDeliveries returns up to limit deliveries due by now. They
+// stay in Redis, hidden from other claims for webhookClaimTimeout, until
+// CompleteWebhookDelivery removes them, so a delivery being sent when its
+// scheduler stops is not lost.
+func (q *RedisQueue) ClaimDueWebhookDeliveries(ctx context.Context, now time.Time, limit int64) ([]core.WebhookDelivery, error) {
+	members, err := claimWebhookDeliveriesScript.Run(ctx, q.client, []string{webhookDeliveriesKey},
+		now.UnixMilli(), limit, now.Add(webhookClaimTimeout).UnixMilli()).StringSlice()
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+
+	var deliveries []core.WebhookDelivery
+	for _, member := range members {
+		var delivery core.WebhookDelivery
+		if err := json.Unmarshal([]byte(member), &delivery); err != nil {
+			q.logger.Errorf("Dropping malformed webhook delivery: %v", err)
+			q.client.ZRem(ctx, webhookDeliveriesKey, member)
+			continue
+		}
+		delivery.Claim = member
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, nil
+}
+
+// CompleteWebhookDelivery removes a claimed delivery once it was sent, its
+// retry scheduled or its webhook deleted.
+func (q *RedisQueue) CompleteWebhookDelivery(ctx context.Context, delivery *core.WebhookDelivery) error {
+	if err := q.client.ZRem(ctx, webhookDeliveriesKey, delivery.Claim).Err(); err != nil {
+		return fmt.Errorf("failed to complete webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// RecordWebhookAttempt adds an attempt to its webhook's delivery log, which
+// keeps the latest webhookLogSize attempts.
+func (q *RedisQueue) RecordWebhookAttempt(ctx context.Context, attempt *core.WebhookAttempt) error {
+	attemptJSON, err := json.Marshal(attempt)
+	if err != nil {
+		return fmt.Errorf("failed to serialize webhook attempt: %w", err)
+	}
+
+	key := webhookLogKey(attempt.WebhookID)
+	pipe := q.client.TxPipeline()
+	pipe.LPush(ctx, key, attemptJSON)
+	pipe.LTrim(ctx, key, 0, webhookLogSize-1)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to record webhook attempt: %w", err)
+	}
+	return nil
+}
+
+// GetWebhookAttempts returns a webhook's delivery log, newest first.
+func (q *RedisQueue) GetWebhookAttempts(ctx context.Context, webhookID string) ([]core.WebhookAttempt, error) {
+	members, err := q.client.LRange(ctx, webhookLogKey(webhookID), 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook delivery log: %w", err)
+	}
+
+	attempts := make([]core.WebhookAttempt, 0, len(members))
+	for _, member := range members {
+		var attempt core.WebhookAttempt
+		if err := json.Unmarshal([]byte(member), &attempt); err != nil {
+			q.logger.Errorf("Failed to unmarshal attempt of webhook %s: %v", webhookID, err)
+			continue
+		}
+		attempts = append(attempts, attempt)
+	}
+	return attempts, nil
+}
@@ -1,9 +1,12 @@
 package storage
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"flowctl/internal/core"
@@ -17,6 +20,8 @@ type PostgresStore struct {
 	logger *logrus.Logger
 }
 
+var _ core.Store = (*PostgresStore)(nil)
+
 func NewPostgresStore(connStr string, logger *logrus.Logger) (*PostgresStore, error) {
 	db, err := sql.Open("postgres", connStr)
 	if err != nil {
@@ -74,6 +79,69 @@ func (s *PostgresStore) migrate() error {
 		`CREATE INDEX IF NOT EXISTS idx_tasks_status ON tasks(status)`,
 		`CREATE INDEX IF NOT EXISTS idx_tasks_type ON tasks(type)`,
 		`CREATE INDEX IF NOT EXISTS idx_workflows_status ON workflows(status)`,
+		`ALTER TABLE workflows ADD COLUMN IF NOT EXISTS error TEXT`,
+		`CREATE TABLE IF NOT EXISTS schedules (
+			id VARCHAR(36) PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			cron_expr VARCHAR(255) NOT NULL,
+			timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+			workflow JSONB NOT NULL,
+			enabled BOOLEAN NOT NULL DEFAULT TRUE,
+			last_run_at TIMESTAMP WITH TIME ZONE,
+			next_run_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_schedules_next_run_at ON schedules(next_run_at)`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS timeout BIGINT NOT NULL DEFAULT 0`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS run_on_upstream_failure BOOLEAN NOT NULL DEFAULT FALSE`,
+		`ALTER TABLE workflows ADD COLUMN IF NOT EXISTS namespace VARCHAR(255) NOT NULL DEFAULT 'default'`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS executor VARCHAR(32) NOT NULL DEFAULT ''`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS resources JSONB`,
+		`ALTER TABLE schedules ADD COLUMN IF NOT EXISTS calendar JSONB`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS idempotency_key VARCHAR(255) NOT NULL DEFAULT ''`,
+		`CREATE INDEX IF NOT EXISTS idx_tasks_idempotency_key ON tasks(idempotency_key) WHERE idempotency_key <> ''`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS usage JSONB`,
+		`ALTER TABLE workflows ADD COLUMN IF NOT EXISTS priority INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS topo_order INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE workflows ADD COLUMN IF NOT EXISTS pool VARCHAR(64) NOT NULL DEFAULT ''`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS pool VARCHAR(64) NOT NULL DEFAULT ''`,
+		`ALTER TABLE workflows ADD COLUMN IF NOT EXISTS owner TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE workflows ADD COLUMN IF NOT EXISTS docs_url TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE workflows ADD COLUMN IF NOT EXISTS runbook_url TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS owner TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS docs_url TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS runbook_url TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS attempts JSONB`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS task_group VARCHAR(255) NOT NULL DEFAULT ''`,
+		`ALTER TABLE workflows ADD COLUMN IF NOT EXISTS params JSONB`,
+		`ALTER TABLE workflows ADD COLUMN IF NOT EXISTS labels JSONB`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS run_at TIMESTAMP WITH TIME ZONE`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS dedupe JSONB`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS duplicate_of VARCHAR(36) NOT NULL DEFAULT ''`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP WITH TIME ZONE`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS selector JSONB`,
+		`CREATE INDEX IF NOT EXISTS idx_tasks_expires_at ON tasks(expires_at) WHERE expires_at IS NOT NULL`,
+		`CREATE TABLE IF NOT EXISTS workflow_templates (
+			name VARCHAR(255) NOT NULL,
+			version INTEGER NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			workflow JSONB NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			PRIMARY KEY (name, version)
+		)`,
+		`CREATE TABLE IF NOT EXISTS idempotency_keys (
+			namespace VARCHAR(255) NOT NULL,
+			key VARCHAR(255) NOT NULL,
+			task_id VARCHAR(36) NOT NULL,
+			PRIMARY KEY (namespace, key)
+		)`,
+		`INSERT INTO idempotency_keys (namespace, key, task_id)
+			SELECT DISTINCT ON (w.namespace, t.idempotency_key) w.namespace, t.idempotency_key, t.id
+			FROM tasks t JOIN workflows w ON w.id = t.workflow_id
+			WHERE t.idempotency_key <> '' AND t.status = 'completed'
+			ORDER BY w.namespace, t.idempotency_key, t.completed_at DESC
+		ON CONFLICT DO NOTHING`,
 	}
 
 	for _, query := range queries {
@@ -85,70 +153,289 @@ func (s *PostgresStore) migrate() error {
 	return nil
 }
 
+// execer is satisfied by both *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
 func (s *PostgresStore) CreateWorkflow(workflow *core.Workflow) error {
+	if err := insertWorkflow(s.db, workflow); err != nil {
+		return err
+	}
+
+	s.logger.Infof("Created workflow: %s", workflow.ID)
+	return nil
+}
+
+// CreateWorkflowWithTasks inserts a workflow and all its tasks in one
+// transaction, so a failed insert leaves no partial workflow behind.
+func (s *PostgresStore) CreateWorkflowWithTasks(workflow *core.Workflow) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := insertWorkflow(tx, workflow); err != nil {
+		return err
+	}
+
+	for start := 0; start < len(workflow.Tasks); start += taskInsertBatchSize {
+		end := start + taskInsertBatchSize
+		if end > len(workflow.Tasks) {
+			end = len(workflow.Tasks)
+		}
+		if err := insertTasks(tx, workflow.Tasks[start:end]); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit workflow: %w", err)
+	}
+
+	s.logger.Infof("Created workflow %s with %d tasks", workflow.ID, len(workflow.Tasks))
+	return nil
+}
+
+func insertWorkflow(db execer, workflow *core.Workflow) error {
 	configJSON, err := json.Marshal(workflow.Config)
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 
+	var paramsJSON, labelsJSON []byte
+	if len(workflow.Params) > 0 {
+		paramsJSON, err = json.Marshal(workflow.Params)
+		if err != nil {
+			return fmt.Errorf("failed to marshal params: %w", err)
+		}
+	}
+	if len(workflow.Labels) > 0 {
+		labelsJSON, err = json.Marshal(workflow.Labels)
+		if err != nil {
+			return fmt.Errorf("failed to marshal labels: %w", err)
+		}
+	}
+
 	query := `
-		INSERT INTO workflows (id, name, description, status, config, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO workflows (id, name, description, namespace, status, config, created_at, updated_at, priority, pool, owner, docs_url, runbook_url, params, labels)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
 	`
 
-	_, err = s.db.Exec(query,
+	_, err = db.Exec(query,
 		workflow.ID,
 		workflow.Name,
 		workflow.Description,
+		workflow.Namespace,
 		workflow.Status,
 		configJSON,
 		workflow.CreatedAt,
 		workflow.UpdatedAt,
+		workflow.Priority,
+		workflow.Pool,
+		workflow.Owner,
+		workflow.DocsURL,
+		workflow.RunbookURL,
+		paramsJSON,
+		labelsJSON,
 	)
 
 	if err != nil {
 		return fmt.Errorf("failed to create workflow: %w", err)
 	}
 
-	s.logger.Infof("Created workflow: %s", workflow.ID)
 	return nil
 }
 
 func (s *PostgresStore) GetWorkflow(id string) (*core.Workflow, error) {
 	query := `
-		SELECT id, name, description, status, config, created_at, updated_at, started_at, completed_at
+		SELECT id, name, description, namespace, status, config, error, created_at, updated_at, started_at, completed_at, priority, pool, owner, docs_url, runbook_url, params, labels
 		FROM workflows WHERE id = $1
 	`
 
 	row := s.db.QueryRow(query, id)
 
+	workflow, err := s.scanWorkflow(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("workflow not found: %s", id)
+		}
+		return nil, err
+	}
+
+	tasks, err := s.GetTasksByWorkflow(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tasks: %w", err)
+	}
+
+	workflow.Tasks = tasks
+	return workflow, nil
+}
+
+func (s *PostgresStore) GetRunningWorkflows() ([]core.Workflow, error) {
+	query := `
+		SELECT id, name, description, namespace, status, config, error, created_at, updated_at, started_at, completed_at, priority, pool, owner, docs_url, runbook_url, params, labels
+		FROM workflows WHERE status = 'running' ORDER BY started_at
+	`
+
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query running workflows: %w", err)
+	}
+	defer rows.Close()
+
+	var workflows []core.Workflow
+	for rows.Next() {
+		workflow, err := s.scanWorkflow(rows)
+		if err != nil {
+			return nil, err
+		}
+		workflows = append(workflows, *workflow)
+	}
+
+	return workflows, nil
+}
+
+// ListWorkflows returns the page of workflows selected by filter, newest
+// first and without their tasks, along with the number of workflows that
+// match the filter across all pages.
+func (s *PostgresStore) ListWorkflows(filter core.WorkflowFilter) ([]core.Workflow, int, error) {
+	var conditions []string
+	var args []interface{}
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.Status != "" {
+		addCondition("status = $%d", filter.Status)
+	}
+	if filter.Name != "" {
+		escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(filter.Name)
+		addCondition("name ILIKE '%%' || $%d || '%%'", escaped)
+	}
+	if filter.CreatedAfter != nil {
+		addCondition("created_at >= $%d", *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		addCondition("created_at <= $%d", *filter.CreatedBefore)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM workflows`+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count workflows: %w", err)
+	}
+
+	query := `
+		SELECT id, name, description, namespace, status, config, error, created_at, updated_at, started_at, completed_at, priority, pool, owner, docs_url, runbook_url, params, labels
+		FROM workflows` + where + fmt.Sprintf(` ORDER BY created_at DESC, id LIMIT $%d OFFSET $%d`, len(args)+1, len(args)+2)
+
+	rows, err := s.db.Query(query, append(args, filter.Limit, (filter.Page-1)*filter.Limit)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query workflows: %w", err)
+	}
+	defer rows.Close()
+
+	workflows := []core.Workflow{}
+	for rows.Next() {
+		workflow, err := s.scanWorkflow(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		workflows = append(workflows, *workflow)
+	}
+
+	return workflows, total, rows.Err()
+}
+
+// DeleteWorkflow deletes a workflow and, through the foreign key, its
+// tasks.
+func (s *PostgresStore) DeleteWorkflow(id string) error {
+	result, err := s.db.Exec(`DELETE FROM workflows WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete workflow: %w", err)
+	}
+
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("workflow not found: %s", id)
+	}
+
+	s.logger.Infof("Deleted workflow: %s", id)
+	return nil
+}
+
+func (s *PostgresStore) FailWorkflow(id, errorMsg string) error {
+	now := time.Now()
+	query := `UPDATE workflows SET status = $1, error = $2, completed_at = $3, updated_at = $4 WHERE id = $5`
+
+	_, err := s.db.Exec(query, core.WorkflowStatusFailed, errorMsg, now, now, id)
+	if err != nil {
+		return fmt.Errorf("failed to fail workflow: %w", err)
+	}
+
+	s.logger.Infof("Failed workflow %s: %s", id, errorMsg)
+	return nil
+}
+
+func (s *PostgresStore) scanWorkflow(scanner interface {
+	Scan(dest ...interface{}) error
+}) (*core.Workflow, error) {
 	var workflow core.Workflow
-	var configJSON []byte
+	var configJSON, paramsJSON, labelsJSON []byte
+	var errorMsg sql.NullString
 	var startedAt, completedAt sql.NullTime
 
-	err := row.Scan(
+	err := scanner.Scan(
 		&workflow.ID,
 		&workflow.Name,
 		&workflow.Description,
+		&workflow.Namespace,
 		&workflow.Status,
 		&configJSON,
+		&errorMsg,
 		&workflow.CreatedAt,
 		&workflow.UpdatedAt,
 		&startedAt,
 		&completedAt,
+		&workflow.Priority,
+		&workflow.Pool,
+		&workflow.Owner,
+		&workflow.DocsURL,
+		&workflow.RunbookURL,
+		&paramsJSON,
+		&labelsJSON,
 	)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("workflow not found: %s", id)
+			return nil, err
 		}
-		return nil, fmt.Errorf("failed to get workflow: %w", err)
+		return nil, fmt.Errorf("failed to scan workflow: %w", err)
 	}
 
 	if err := json.Unmarshal(configJSON, &workflow.Config); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
+	if len(paramsJSON) > 0 {
+		if err := json.Unmarshal(paramsJSON, &workflow.Params); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal params: %w", err)
+		}
+	}
+	if len(labelsJSON) > 0 {
+		if err := json.Unmarshal(labelsJSON, &workflow.Labels); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal labels: %w", err)
+		}
+	}
 
+	if errorMsg.Valid {
+		workflow.Error = errorMsg.String
+	}
 	if startedAt.Valid {
 		workflow.StartedAt = &startedAt.Time
 	}
@@ -156,12 +443,6 @@ func (s *PostgresStore) GetWorkflow(id string) (*core.Workflow, error) {
 		workflow.CompletedAt = &completedAt.Time
 	}
 
-	tasks, err := s.GetTasksByWorkflow(id)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get tasks: %w", err)
-	}
-
-	workflow.Tasks = tasks
 	return &workflow, nil
 }
 
@@ -191,23 +472,260 @@ func (s *PostgresStore) UpdateWorkflowStatus(id string, status core.WorkflowStat
 	return nil
 }
 
+// ReopenWorkflow returns a failed workflow to running, clearing its error.
+// It reports false if the workflow was not failed.
+func (s *PostgresStore) ReopenWorkflow(id string) (bool, error) {
+	result, err := s.db.Exec(
+		`UPDATE workflows SET status = $1, error = NULL, completed_at = NULL, updated_at = $2 WHERE id = $3 AND status = $4`,
+		core.WorkflowStatusRunning, time.Now(), id, core.WorkflowStatusFailed,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to reopen workflow: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to reopen workflow: %w", err)
+	}
+	return rows > 0, nil
+}
+
+func (s *PostgresStore) WorkflowExists(id string) (bool, error) {
+	var exists bool
+	err := s.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM workflows WHERE id = $1)`, id).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check workflow: %w", err)
+	}
+	return exists, nil
+}
+
+// WorkflowNamespace returns the namespace of a workflow, and false when
+// there is no such workflow.
+func (s *PostgresStore) WorkflowNamespace(id string) (string, bool, error) {
+	var namespace string
+	err := s.db.QueryRow(`SELECT namespace FROM workflows WHERE id = $1`, id).Scan(&namespace)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get workflow namespace: %w", err)
+	}
+	return namespace, true, nil
+}
+
+// TaskNamespace returns the namespace of a task's workflow, and false when
+// there is no such task.
+func (s *PostgresStore) TaskNamespace(id string) (string, bool, error) {
+	var namespace string
+	err := s.db.QueryRow(`
+		SELECT w.namespace FROM tasks t JOIN workflows w ON w.id = t.workflow_id
+		WHERE t.id = $1
+	`, id).Scan(&namespace)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get task namespace: %w", err)
+	}
+	return namespace, true, nil
+}
+
+// RetryBudgetUsage returns a workflow's retry budget and how many retries
+// its tasks have used between them.
+func (s *PostgresStore) RetryBudgetUsage(workflowID string) (int, int, error) {
+	var configJSON []byte
+	var used int
+	err := s.db.QueryRow(`
+		SELECT w.config, COALESCE(SUM(t.retry_count), 0)
+		FROM workflows w LEFT JOIN tasks t ON t.workflow_id = w.id
+		WHERE w.id = $1
+		GROUP BY w.id
+	`, workflowID).Scan(&configJSON, &used)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get retry budget usage: %w", err)
+	}
+
+	var config core.WorkflowConfig
+	if err := json.Unmarshal(configJSON, &config); err != nil {
+		return 0, 0, fmt.Errorf("failed to unmarshal config: %w", err)
+	}
+	return config.RetryBudget, used, nil
+}
+
+func (s *PostgresStore) TransitionWorkflowStatus(id string, from, to core.WorkflowStatus) (bool, error) {
+	result, err := s.db.Exec(
+		`UPDATE workflows SET status = $1, updated_at = $2 WHERE id = $3 AND status = $4`,
+		to, time.Now(), id, from,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to update workflow status: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to update workflow status: %w", err)
+	}
+
+	return rows > 0, nil
+}
+
+const idLookupBatchSize = 1000
+
+func (s *PostgresStore) ExistingTaskIDs(ids []string) ([]string, error) {
+	var existing []string
+
+	for start := 0; start < len(ids); start += idLookupBatchSize {
+		end := start + idLookupBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		found, err := s.existingTaskIDs(ids[start:end])
+		if err != nil {
+			return nil, err
+		}
+		existing = append(existing, found...)
+	}
+
+	return existing, nil
+}
+
+func (s *PostgresStore) existingTaskIDs(ids []string) ([]string, error) {
+	placeholders := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = id
+	}
+
+	query := fmt.Sprintf(`SELECT id FROM tasks WHERE id IN (%s)`, strings.Join(placeholders, ", "))
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query task ids: %w", err)
+	}
+	defer rows.Close()
+
+	var existing []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan task id: %w", err)
+		}
+		existing = append(existing, id)
+	}
+
+	return existing, rows.Err()
+}
+
+const (
+	taskInsertColumns = 27
+	// taskInsertBatchSize keeps a multi-row task insert well under
+	// PostgreSQL's limit of 65535 bind parameters.
+	taskInsertBatchSize = 500
+)
+
 func (s *PostgresStore) CreateTask(task *core.Task) error {
+	args, err := taskInsertArgs(task)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO tasks (id, workflow_id, name, type, payload, status, retry_count, max_retries, priority, dependencies, timeout, run_on_upstream_failure, executor, resources, idempotency_key, topo_order, pool, owner, docs_url, runbook_url, task_group, created_at, updated_at, run_at, dedupe, expires_at, selector)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27)
+	`
+
+	if _, err := s.db.Exec(query, args...); err != nil {
+		return fmt.Errorf("failed to create task: %w", err)
+	}
+
+	s.logger.Infof("Created task: %s", task.ID)
+	return nil
+}
+
+// CreateTasks inserts a batch of tasks in a single statement, so either the
+// whole batch is persisted or none of it is.
+func (s *PostgresStore) CreateTasks(tasks []core.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	if err := insertTasks(s.db, tasks); err != nil {
+		return err
+	}
+
+	s.logger.Infof("Created %d tasks for workflow %s", len(tasks), tasks[0].WorkflowID)
+	return nil
+}
+
+func insertTasks(db execer, tasks []core.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	rows := make([]string, len(tasks))
+	args := make([]interface{}, 0, len(tasks)*taskInsertColumns)
+	for i := range tasks {
+		taskArgs, err := taskInsertArgs(&tasks[i])
+		if err != nil {
+			return err
+		}
+
+		placeholders := make([]string, taskInsertColumns)
+		for j := range placeholders {
+			placeholders[j] = fmt.Sprintf("$%d", len(args)+j+1)
+		}
+		rows[i] = "(" + strings.Join(placeholders, ", ") + ")"
+		args = append(args, taskArgs...)
+	}
+
+	query := `
+		INSERT INTO tasks (id, workflow_id, name, type, payload, status, retry_count, max_retries, priority, dependencies, timeout, run_on_upstream_failure, executor, resources, idempotency_key, topo_order, pool, owner, docs_url, runbook_url, task_group, created_at, updated_at, run_at, dedupe, expires_at, selector)
+		VALUES ` + strings.Join(rows, ", ")
+
+	if _, err := db.Exec(query, args...); err != nil {
+		return fmt.Errorf("failed to create tasks: %w", err)
+	}
+
+	return nil
+}
+
+func taskInsertArgs(task *core.Task) ([]interface{}, error) {
 	payloadJSON, err := json.Marshal(task.Payload)
 	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %w", err)
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
 	}
 
 	dependenciesJSON, err := json.Marshal(task.Dependencies)
 	if err != nil {
-		return fmt.Errorf("failed to marshal dependencies: %w", err)
+		return nil, fmt.Errorf("failed to marshal dependencies: %w", err)
 	}
 
-	query := `
-		INSERT INTO tasks (id, workflow_id, name, type, payload, status, retry_count, max_retries, priority, dependencies, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
-	`
+	var resourcesJSON []byte
+	if task.Resources != nil {
+		resourcesJSON, err = json.Marshal(task.Resources)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal resources: %w", err)
+		}
+	}
+
+	var dedupeJSON []byte
+	if task.Dedupe != nil {
+		dedupeJSON, err = json.Marshal(task.Dedupe)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal dedupe: %w", err)
+		}
+	}
 
-	_, err = s.db.Exec(query,
+	var selectorJSON []byte
+	if len(task.Selector) > 0 {
+		selectorJSON, err = json.Marshal(task.Selector)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal selector: %w", err)
+		}
+	}
+
+	return []interface{}{
 		task.ID,
 		task.WorkflowID,
 		task.Name,
@@ -218,21 +736,29 @@ func (s *PostgresStore) CreateTask(task *core.Task) error {
 		task.MaxRetries,
 		task.Priority,
 		dependenciesJSON,
+		int64(task.Timeout),
+		task.RunOnUpstreamFailure,
+		task.Executor,
+		resourcesJSON,
+		task.IdempotencyKey,
+		task.Order,
+		task.Pool,
+		task.Owner,
+		task.DocsURL,
+		task.RunbookURL,
+		task.Group,
 		task.CreatedAt,
 		task.UpdatedAt,
-	)
-
-	if err != nil {
-		return fmt.Errorf("failed to create task: %w", err)
-	}
-
-	s.logger.Infof("Created task: %s", task.ID)
-	return nil
+		task.RunAt,
+		dedupeJSON,
+		task.ExpiresAt,
+		selectorJSON,
+	}, nil
 }
 
 func (s *PostgresStore) GetTask(id string) (*core.Task, error) {
 	query := `
-		SELECT id, workflow_id, name, type, payload, status, result, error, retry_count, max_retries, priority, dependencies, created_at, updated_at, started_at, completed_at
+		SELECT id, workflow_id, name, type, payload, status, result, error, retry_count, max_retries, priority, dependencies, created_at, updated_at, started_at, completed_at, timeout, run_on_upstream_failure, executor, resources, idempotency_key, usage, topo_order, pool, owner, docs_url, runbook_url, attempts, task_group, run_at, dedupe, duplicate_of, expires_at, selector
 		FROM tasks WHERE id = $1
 	`
 
@@ -242,8 +768,8 @@ func (s *PostgresStore) GetTask(id string) (*core.Task, error) {
 
 func (s *PostgresStore) GetTasksByWorkflow(workflowID string) ([]core.Task, error) {
 	query := `
-		SELECT id, workflow_id, name, type, payload, status, result, error, retry_count, max_retries, priority, dependencies, created_at, updated_at, started_at, completed_at
-		FROM tasks WHERE workflow_id = $1 ORDER BY created_at
+		SELECT id, workflow_id, name, type, payload, status, result, error, retry_count, max_retries, priority, dependencies, created_at, updated_at, started_at, completed_at, timeout, run_on_upstream_failure, executor, resources, idempotency_key, usage, topo_order, pool, owner, docs_url, runbook_url, attempts, task_group, run_at, dedupe, duplicate_of, expires_at, selector
+		FROM tasks WHERE workflow_id = $1 ORDER BY topo_order, created_at
 	`
 
 	rows, err := s.db.Query(query, workflowID)
@@ -266,7 +792,7 @@ func (s *PostgresStore) GetTasksByWorkflow(workflowID string) ([]core.Task, erro
 
 func (s *PostgresStore) UpdateTaskStatus(id string, status core.TaskStatus, result map[string]interface{}, errorMsg string) error {
 	now := time.Now()
-	
+
 	var resultJSON []byte
 	if result != nil {
 		var err error
@@ -286,7 +812,7 @@ func (s *PostgresStore) UpdateTaskStatus(id string, status core.TaskStatus, resu
 	case core.TaskStatusCompleted:
 		query = `UPDATE tasks SET status = $1, result = $2, completed_at = $3, updated_at = $4 WHERE id = $5`
 		args = []interface{}{status, resultJSON, now, now, id}
-	case core.TaskStatusFailed:
+	case core.TaskStatusFailed, core.TaskStatusCancelled, core.TaskStatusSkipped, core.TaskStatusExpired:
 		query = `UPDATE tasks SET status = $1, error = $2, completed_at = $3, updated_at = $4 WHERE id = $5`
 		args = []interface{}{status, errorMsg, now, now, id}
 	case core.TaskStatusRetrying:
@@ -306,10 +832,112 @@ func (s *PostgresStore) UpdateTaskStatus(id string, status core.TaskStatus, resu
 	return nil
 }
 
+// UpdateTasksStatus sets the status of many tasks with one statement per
+// batch of IDs. It is meant for statuses that record nothing else, such as
+// queued and pending; use UpdateTaskStatus for the others.
+func (s *PostgresStore) UpdateTasksStatus(ids []string, status core.TaskStatus) error {
+	now := time.Now()
+
+	for start := 0; start < len(ids); start += idLookupBatchSize {
+		end := start + idLookupBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		placeholders := make([]string, 0, end-start)
+		args := []interface{}{status, now}
+		for _, id := range ids[start:end] {
+			args = append(args, id)
+			placeholders = append(placeholders, fmt.Sprintf("$%d", len(args)))
+		}
+
+		query := fmt.Sprintf(`UPDATE tasks SET status = $1, updated_at = $2 WHERE id IN (%s)`, strings.Join(placeholders, ", "))
+		if _, err := s.db.Exec(query, args...); err != nil {
+			return fmt.Errorf("failed to update task status: %w", err)
+		}
+	}
+
+	s.logger.Infof("Updated %d tasks' status to %s", len(ids), status)
+	return nil
+}
+
+// ResetTask returns a failed task to pending, clearing the outcome of its
+// last run and, with resetRetries, its retry count. It reports false if the
+// task was not failed.
+func (s *PostgresStore) ResetTask(id string, resetRetries bool) (bool, error) {
+	query := `
+		UPDATE tasks SET status = $1, result = NULL, error = NULL, started_at = NULL, completed_at = NULL,
+			retry_count = CASE WHEN $2 THEN 0 ELSE retry_count END, updated_at = $3
+		WHERE id = $4 AND status = $5
+	`
+
+	result, err := s.db.Exec(query, core.TaskStatusPending, resetRetries, time.Now(), id, core.TaskStatusFailed)
+	if err != nil {
+		return false, fmt.Errorf("failed to reset task: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to reset task: %w", err)
+	}
+	return rows > 0, nil
+}
+
+// SetTaskUsage stores the resource usage of a task's latest execution.
+func (s *PostgresStore) SetTaskUsage(id string, usage core.ResourceUsage) error {
+	usageJSON, err := json.Marshal(usage)
+	if err != nil {
+		return fmt.Errorf("failed to marshal usage: %w", err)
+	}
+
+	query := `UPDATE tasks SET usage = $1, updated_at = $2 WHERE id = $3`
+	if _, err := s.db.Exec(query, usageJSON, time.Now(), id); err != nil {
+		return fmt.Errorf("failed to set task usage: %w", err)
+	}
+	return nil
+}
+
+// AddTaskAttempt appends the environment of a new attempt to a task's
+// attempts.
+func (s *PostgresStore) AddTaskAttempt(id string, env core.ExecutionEnvironment) error {
+	attemptJSON, err := json.Marshal([]core.ExecutionEnvironment{env})
+	if err != nil {
+		return fmt.Errorf("failed to marshal attempt: %w", err)
+	}
+
+	query := `UPDATE tasks SET attempts = COALESCE(attempts, '[]'::jsonb) || $1::jsonb, updated_at = $2 WHERE id = $3`
+	if _, err := s.db.Exec(query, attemptJSON, time.Now(), id); err != nil {
+		return fmt.Errorf("failed to add task attempt: %w", err)
+	}
+	return nil
+}
+
+// SetTaskDuplicateOf links a task to the identical task it is held back
+// behind, or unlinks it when originalID is empty.
+func (s *PostgresStore) SetTaskDuplicateOf(id, originalID string) error {
+	query := `UPDATE tasks SET duplicate_of = $1, updated_at = $2 WHERE id = $3`
+	if _, err := s.db.Exec(query, originalID, time.Now(), id); err != nil {
+		return fmt.Errorf("failed to set duplicate of task: %w", err)
+	}
+	return nil
+}
+
+// FindTask returns the task with the given ID, or nil if there is none.
+func (s *PostgresStore) FindTask(id string) (*core.Task, error) {
+	task, err := s.GetTask(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return task, nil
+}
+
 func (s *PostgresStore) GetPendingTasks() ([]core.Task, error) {
 	query := `
-		SELECT id, workflow_id, name, type, payload, status, result, error, retry_count, max_retries, priority, dependencies, created_at, updated_at, started_at, completed_at
-		FROM tasks WHERE status IN ('pending', 'retrying') ORDER BY priority DESC, created_at ASC
+		SELECT id, workflow_id, name, type, payload, status, result, error, retry_count, max_retries, priority, dependencies, created_at, updated_at, started_at, completed_at, timeout, run_on_upstream_failure, executor, resources, idempotency_key, usage, topo_order, pool, owner, docs_url, runbook_url, attempts, task_group, run_at, dedupe, duplicate_of, expires_at, selector
+		FROM tasks WHERE status = 'pending' ORDER BY priority DESC, created_at ASC
 	`
 
 	rows, err := s.db.Query(query)
@@ -330,14 +958,93 @@ func (s *PostgresStore) GetPendingTasks() ([]core.Task, error) {
 	return tasks, nil
 }
 
+// ClaimIdempotencyKey records taskID as the task running under an
+// idempotency key in a namespace, unless another task holds the key, and
+// returns the holder. The holder is replaced only when it is stale, so of
+// two tasks claiming a key at once, one gets it.
+func (s *PostgresStore) ClaimIdempotencyKey(namespace, key, taskID, stale string) (string, error) {
+	var holder string
+	err := s.db.QueryRow(`
+		INSERT INTO idempotency_keys (namespace, key, task_id) VALUES ($1, $2, $3)
+		ON CONFLICT (namespace, key) DO UPDATE SET task_id = EXCLUDED.task_id
+			WHERE idempotency_keys.task_id = $4 OR idempotency_keys.task_id = EXCLUDED.task_id
+		RETURNING task_id
+	`, namespace, key, taskID, stale).Scan(&holder)
+	if err == nil {
+		return holder, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("failed to claim idempotency key: %w", err)
+	}
+
+	err = s.db.QueryRow(`SELECT task_id FROM idempotency_keys WHERE namespace = $1 AND key = $2`, namespace, key).Scan(&holder)
+	if err != nil {
+		return "", fmt.Errorf("failed to get holder of idempotency key: %w", err)
+	}
+	return holder, nil
+}
+
+// GetInFlightTasks returns tasks the database believes were handed to the
+// queue, including those waiting to retry, so they can be checked against
+// what Redis actually holds.
+func (s *PostgresStore) GetInFlightTasks() ([]core.Task, error) {
+	query := `
+		SELECT id, workflow_id, name, type, payload, status, result, error, retry_count, max_retries, priority, dependencies, created_at, updated_at, started_at, completed_at, timeout, run_on_upstream_failure, executor, resources, idempotency_key, usage, topo_order, pool, owner, docs_url, runbook_url, attempts, task_group, run_at, dedupe, duplicate_of, expires_at, selector
+		FROM tasks WHERE status IN ('queued', 'running', 'retrying') ORDER BY priority DESC, created_at ASC
+	`
+
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query in-flight tasks: %w", err)
+	}
+	defer rows.Close()
+
+	var tasks []core.Task
+	for rows.Next() {
+		task, err := s.scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, *task)
+	}
+
+	return tasks, nil
+}
+
+// GetExpiredTasks returns up to limit tasks whose expires_at had passed by
+// now while they were still waiting to run, the longest expired first.
+func (s *PostgresStore) GetExpiredTasks(now time.Time, limit int) ([]core.Task, error) {
+	query := `
+		SELECT id, workflow_id, name, type, payload, status, result, error, retry_count, max_retries, priority, dependencies, created_at, updated_at, started_at, completed_at, timeout, run_on_upstream_failure, executor, resources, idempotency_key, usage, topo_order, pool, owner, docs_url, runbook_url, attempts, task_group, run_at, dedupe, duplicate_of, expires_at, selector
+		FROM tasks WHERE status IN ('pending', 'queued', 'retrying') AND expires_at <= $1 ORDER BY expires_at LIMIT $2
+	`
+
+	rows, err := s.db.Query(query, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query expired tasks: %w", err)
+	}
+	defer rows.Close()
+
+	var tasks []core.Task
+	for rows.Next() {
+		task, err := s.scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, *task)
+	}
+
+	return tasks, nil
+}
+
 func (s *PostgresStore) scanTask(scanner interface {
 	Scan(dest ...interface{}) error
 }) (*core.Task, error) {
 	var task core.Task
-	var payloadJSON, resultJSON, dependenciesJSON []byte
-	var result sql.NullString
+	var payloadJSON, resultJSON, dependenciesJSON, resourcesJSON, usageJSON, attemptsJSON, dedupeJSON, selectorJSON []byte
 	var errorMsg sql.NullString
-	var startedAt, completedAt sql.NullTime
+	var startedAt, completedAt, runAt, expiresAt sql.NullTime
+	var timeout int64
 
 	err := scanner.Scan(
 		&task.ID,
@@ -356,12 +1063,32 @@ func (s *PostgresStore) scanTask(scanner interface {
 		&task.UpdatedAt,
 		&startedAt,
 		&completedAt,
+		&timeout,
+		&task.RunOnUpstreamFailure,
+		&task.Executor,
+		&resourcesJSON,
+		&task.IdempotencyKey,
+		&usageJSON,
+		&task.Order,
+		&task.Pool,
+		&task.Owner,
+		&task.DocsURL,
+		&task.RunbookURL,
+		&attemptsJSON,
+		&task.Group,
+		&runAt,
+		&dedupeJSON,
+		&task.DuplicateOf,
+		&expiresAt,
+		&selectorJSON,
 	)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to scan task: %w", err)
 	}
 
+	task.Timeout = time.Duration(timeout)
+
 	if err := json.Unmarshal(payloadJSON, &task.Payload); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal payload: %w", err)
 	}
@@ -376,6 +1103,36 @@ func (s *PostgresStore) scanTask(scanner interface {
 		}
 	}
 
+	if resourcesJSON != nil {
+		if err := json.Unmarshal(resourcesJSON, &task.Resources); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal resources: %w", err)
+		}
+	}
+
+	if usageJSON != nil {
+		if err := json.Unmarshal(usageJSON, &task.Usage); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal usage: %w", err)
+		}
+	}
+
+	if attemptsJSON != nil {
+		if err := json.Unmarshal(attemptsJSON, &task.Attempts); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal attempts: %w", err)
+		}
+	}
+
+	if dedupeJSON != nil {
+		if err := json.Unmarshal(dedupeJSON, &task.Dedupe); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal dedupe: %w", err)
+		}
+	}
+
+	if selectorJSON != nil {
+		if err := json.Unmarshal(selectorJSON, &task.Selector); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal selector: %w", err)
+		}
+	}
+
 	if errorMsg.Valid {
 		task.Error = errorMsg.String
 	}
@@ -388,9 +1145,98 @@ func (s *PostgresStore) scanTask(scanner interface {
 		task.CompletedAt = &completedAt.Time
 	}
 
+	if runAt.Valid {
+		task.RunAt = &runAt.Time
+	}
+
+	if expiresAt.Valid {
+		task.ExpiresAt = &expiresAt.Time
+	}
+
 	return &task, nil
 }
 
+// CountWorkflowsByStatus counts the workflows created since the given time
+// by their current status.
+func (s *PostgresStore) CountWorkflowsByStatus(since time.Time) (map[core.WorkflowStatus]int, error) {
+	query := `SELECT status, COUNT(*) FROM workflows WHERE created_at >= $1 GROUP BY status`
+
+	rows, err := s.db.Query(query, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count workflows: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[core.WorkflowStatus]int)
+	for rows.Next() {
+		var status core.WorkflowStatus
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan workflow count: %w", err)
+		}
+		counts[status] = count
+	}
+
+	return counts, rows.Err()
+}
+
+// CountTasksByTypeAndStatus counts every stored task by its type and status.
+func (s *PostgresStore) CountTasksByTypeAndStatus() (map[string]map[core.TaskStatus]int, error) {
+	rows, err := s.db.Query(`SELECT type, status, COUNT(*) FROM tasks GROUP BY type, status`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count tasks: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]map[core.TaskStatus]int)
+	for rows.Next() {
+		var taskType string
+		var status core.TaskStatus
+		var count int
+		if err := rows.Scan(&taskType, &status, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan task count: %w", err)
+		}
+		if counts[taskType] == nil {
+			counts[taskType] = make(map[core.TaskStatus]int)
+		}
+		counts[taskType][status] = count
+	}
+
+	return counts, rows.Err()
+}
+
+// GetTopFailingTaskTypes returns the task types with the most tasks failed
+// since the given time, most failures first.
+func (s *PostgresStore) GetTopFailingTaskTypes(since time.Time, limit int) ([]core.TaskTypeFailures, error) {
+	query := `
+		SELECT type, COUNT(*) FROM tasks
+		WHERE status = 'failed' AND completed_at >= $1
+		GROUP BY type ORDER BY COUNT(*) DESC, type LIMIT $2
+	`
+
+	rows, err := s.db.Query(query, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query failing task types: %w", err)
+	}
+	defer rows.Close()
+
+	var failures []core.TaskTypeFailures
+	for rows.Next() {
+		var f core.TaskTypeFailures
+		if err := rows.Scan(&f.Type, &f.Failures); err != nil {
+			return nil, fmt.Errorf("failed to scan failing task type: %w", err)
+		}
+		failures = append(failures, f)
+	}
+
+	return failures, rows.Err()
+}
+
+// Ping checks that the database is reachable.
+func (s *PostgresStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
 func (s *PostgresStore) Close() error {
 	return s.db.Close()
 }
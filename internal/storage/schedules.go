@@ -0,0 +1,228 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"flowctl/internal/core"
+)
+
+const scheduleColumns = `id, name, cron_expr, timezone, workflow, enabled, last_run_at, next_run_at, created_at, updated_at, calendar`
+
+func (s *PostgresStore) CreateSchedule(schedule *core.Schedule) error {
+	workflowJSON, err := json.Marshal(schedule.Workflow)
+	if err != nil {
+		return fmt.Errorf("failed to marshal schedule workflow: %w", err)
+	}
+
+	calendarJSON, err := marshalCalendar(schedule.Calendar)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO schedules (id, name, cron_expr, timezone, workflow, enabled, next_run_at, created_at, updated_at, calendar)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`
+
+	_, err = s.db.Exec(query,
+		schedule.ID,
+		schedule.Name,
+		schedule.Cron,
+		schedule.Timezone,
+		workflowJSON,
+		schedule.Enabled,
+		schedule.NextRunAt,
+		schedule.CreatedAt,
+		schedule.UpdatedAt,
+		calendarJSON,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create schedule: %w", err)
+	}
+
+	s.logger.Infof("Created schedule: %s", schedule.ID)
+	return nil
+}
+
+func (s *PostgresStore) GetSchedule(id string) (*core.Schedule, error) {
+	query := `SELECT ` + scheduleColumns + ` FROM schedules WHERE id = $1`
+
+	schedule, err := s.scanSchedule(s.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("schedule not found: %s", id)
+		}
+		return nil, err
+	}
+
+	return schedule, nil
+}
+
+func (s *PostgresStore) ListSchedules() ([]core.Schedule, error) {
+	query := `SELECT ` + scheduleColumns + ` FROM schedules ORDER BY created_at`
+
+	return s.querySchedules(query)
+}
+
+func (s *PostgresStore) GetDueSchedules(now time.Time) ([]core.Schedule, error) {
+	query := `SELECT ` + scheduleColumns + ` FROM schedules WHERE enabled AND next_run_at <= $1 ORDER BY next_run_at`
+
+	return s.querySchedules(query, now)
+}
+
+func (s *PostgresStore) UpdateSchedule(schedule *core.Schedule) error {
+	workflowJSON, err := json.Marshal(schedule.Workflow)
+	if err != nil {
+		return fmt.Errorf("failed to marshal schedule workflow: %w", err)
+	}
+
+	calendarJSON, err := marshalCalendar(schedule.Calendar)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE schedules SET name = $1, cron_expr = $2, timezone = $3, workflow = $4, enabled = $5, next_run_at = $6, updated_at = $7, calendar = $8
+		WHERE id = $9
+	`
+
+	result, err := s.db.Exec(query,
+		schedule.Name,
+		schedule.Cron,
+		schedule.Timezone,
+		workflowJSON,
+		schedule.Enabled,
+		schedule.NextRunAt,
+		schedule.UpdatedAt,
+		calendarJSON,
+		schedule.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update schedule: %w", err)
+	}
+
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("schedule not found: %s", schedule.ID)
+	}
+
+	s.logger.Infof("Updated schedule: %s", schedule.ID)
+	return nil
+}
+
+func (s *PostgresStore) UpdateScheduleRun(id string, lastRunAt, nextRunAt time.Time) error {
+	query := `UPDATE schedules SET last_run_at = $1, next_run_at = $2, updated_at = $3 WHERE id = $4`
+
+	_, err := s.db.Exec(query, lastRunAt, nextRunAt, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update schedule run: %w", err)
+	}
+
+	return nil
+}
+
+func (s *PostgresStore) SetScheduleNextRun(id string, nextRunAt time.Time) error {
+	query := `UPDATE schedules SET next_run_at = $1, updated_at = $2 WHERE id = $3`
+
+	_, err := s.db.Exec(query, nextRunAt, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update schedule next run: %w", err)
+	}
+
+	return nil
+}
+
+func (s *PostgresStore) DeleteSchedule(id string) error {
+	result, err := s.db.Exec(`DELETE FROM schedules WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete schedule: %w", err)
+	}
+
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("schedule not found: %s", id)
+	}
+
+	s.logger.Infof("Deleted schedule: %s", id)
+	return nil
+}
+
+func (s *PostgresStore) querySchedules(query string, args ...interface{}) ([]core.Schedule, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schedules: %w", err)
+	}
+	defer rows.Close()
+
+	schedules := []core.Schedule{}
+	for rows.Next() {
+		schedule, err := s.scanSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, *schedule)
+	}
+
+	return schedules, nil
+}
+
+func (s *PostgresStore) scanSchedule(scanner interface {
+	Scan(dest ...interface{}) error
+}) (*core.Schedule, error) {
+	var schedule core.Schedule
+	var workflowJSON, calendarJSON []byte
+	var lastRunAt, nextRunAt sql.NullTime
+
+	err := scanner.Scan(
+		&schedule.ID,
+		&schedule.Name,
+		&schedule.Cron,
+		&schedule.Timezone,
+		&workflowJSON,
+		&schedule.Enabled,
+		&lastRunAt,
+		&nextRunAt,
+		&schedule.CreatedAt,
+		&schedule.UpdatedAt,
+		&calendarJSON,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan schedule: %w", err)
+	}
+
+	if err := json.Unmarshal(workflowJSON, &schedule.Workflow); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal schedule workflow: %w", err)
+	}
+
+	if calendarJSON != nil {
+		if err := json.Unmarshal(calendarJSON, &schedule.Calendar); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal schedule calendar: %w", err)
+		}
+	}
+
+	if lastRunAt.Valid {
+		schedule.LastRunAt = &lastRunAt.Time
+	}
+	if nextRunAt.Valid {
+		schedule.NextRunAt = &nextRunAt.Time
+	}
+
+	return &schedule, nil
+}
+
+func marshalCalendar(calendar *core.Calendar) ([]byte, error) {
+	if calendar == nil {
+		return nil, nil
+	}
+
+	calendarJSON, err := json.Marshal(calendar)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal schedule calendar: %w", err)
+	}
+	return calendarJSON, nil
+}
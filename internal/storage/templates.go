@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"flowctl/internal/core"
+
+	"github.com/lib/pq"
+)
+
+const (
+	templateColumns = `name, version, description, workflow, created_at`
+
+	// templateVersionAttempts bounds how often adding a version retries
+	// after losing the race for its number to a concurrent update.
+	templateVersionAttempts = 5
+
+	uniqueViolation = "23505"
+)
+
+// CreateWorkflowTemplate saves version 1 of a new template, failing with
+// core.ErrTemplateExists if the name is taken. Of two creates racing for
+// a name, the primary key on (name, version) lets one through.
+func (s *PostgresStore) CreateWorkflowTemplate(tmpl *core.WorkflowTemplate) error {
+	workflowJSON, err := json.Marshal(tmpl.Workflow)
+	if err != nil {
+		return fmt.Errorf("failed to marshal template workflow: %w", err)
+	}
+
+	query := `
+		INSERT INTO workflow_templates (name, version, description, workflow, created_at)
+		SELECT $1::VARCHAR, 1, $2::TEXT, $3::JSONB, $4::TIMESTAMPTZ
+		WHERE NOT EXISTS (SELECT 1 FROM workflow_templates WHERE name = $1::VARCHAR)
+	`
+
+	result, err := s.db.Exec(query, tmpl.Name, tmpl.Description, workflowJSON, tmpl.CreatedAt)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("%w: %s", core.ErrTemplateExists, tmpl.Name)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create workflow template: %w", err)
+	}
+
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("%w: %s", core.ErrTemplateExists, tmpl.Name)
+	}
+
+	tmpl.Version = 1
+	s.logger.Infof("Created workflow template: %s", tmpl.Name)
+	return nil
+}
+
+// AddWorkflowTemplateVersion saves the template as the next version of an
+// existing name and sets its Version. Two updates racing for the same
+// number both read the same latest version; the primary key on (name,
+// version) rejects the second, which retries with the next number.
+func (s *PostgresStore) AddWorkflowTemplateVersion(tmpl *core.WorkflowTemplate) error {
+	workflowJSON, err := json.Marshal(tmpl.Workflow)
+	if err != nil {
+		return fmt.Errorf("failed to marshal template workflow: %w", err)
+	}
+
+	query := `
+		INSERT INTO workflow_templates (name, version, description, workflow, created_at)
+		SELECT $1::VARCHAR, MAX(version) + 1, $2::TEXT, $3::JSONB, $4::TIMESTAMPTZ
+		FROM workflow_templates WHERE name = $1::VARCHAR
+		HAVING COUNT(*) > 0
+		RETURNING version
+	`
+
+	err = retryUniqueViolation(templateVersionAttempts, func() error {
+		return s.db.QueryRow(query, tmpl.Name, tmpl.Description, workflowJSON, tmpl.CreatedAt).Scan(&tmpl.Version)
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("workflow template not found: %s", tmpl.Name)
+		}
+		return fmt.Errorf("failed to add workflow template version: %w", err)
+	}
+
+	s.logger.Infof("Added version %d of workflow template: %s", tmpl.Version, tmpl.Name)
+	return nil
+}
+
+// GetWorkflowTemplate returns a version of a template, or its latest
+// version when version is 0.
+func (s *PostgresStore) GetWorkflowTemplate(name string, version int) (*core.WorkflowTemplate, error) {
+	query := `SELECT ` + templateColumns + ` FROM workflow_templates WHERE name = $1 AND ($2 = 0 OR version = $2) ORDER BY version DESC LIMIT 1`
+
+	tmpl, err := s.scanWorkflowTemplate(s.db.QueryRow(query, name, version))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			if version > 0 {
+				return nil, fmt.Errorf("workflow template not found: %s version %d", name, version)
+			}
+			return nil, fmt.Errorf("workflow template not found: %s", name)
+		}
+		return nil, err
+	}
+
+	return tmpl, nil
+}
+
+// ListWorkflowTemplates returns the latest version of every template.
+func (s *PostgresStore) ListWorkflowTemplates() ([]core.WorkflowTemplate, error) {
+	query := `SELECT DISTINCT ON (name) ` + templateColumns + ` FROM workflow_templates ORDER BY name, version DESC`
+
+	return s.queryWorkflowTemplates(query)
+}
+
+// ListWorkflowTemplateVersions returns every version of a template, newest
+// first.
+func (s *PostgresStore) ListWorkflowTemplateVersions(name string) ([]core.WorkflowTemplate, error) {
+	query := `SELECT ` + templateColumns + ` FROM workflow_templates WHERE name = $1 ORDER BY version DESC`
+
+	return s.queryWorkflowTemplates(query, name)
+}
+
+// DeleteWorkflowTemplate deletes every version of a template.
+func (s *PostgresStore) DeleteWorkflowTemplate(name string) error {
+	result, err := s.db.Exec(`DELETE FROM workflow_templates WHERE name = $1`, name)
+	if err != nil {
+		return fmt.Errorf("failed to delete workflow template: %w", err)
+	}
+
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("workflow template not found: %s", name)
+	}
+
+	s.logger.Infof("Deleted workflow template: %s", name)
+	return nil
+}
+
+func (s *PostgresStore) queryWorkflowTemplates(query string, args ...interface{}) ([]core.WorkflowTemplate, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query workflow templates: %w", err)
+	}
+	defer rows.Close()
+
+	templates := []core.WorkflowTemplate{}
+	for rows.Next() {
+		tmpl, err := s.scanWorkflowTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, *tmpl)
+	}
+
+	return templates, nil
+}
+
+func (s *PostgresStore) scanWorkflowTemplate(scanner interface {
+	Scan(dest ...interface{}) error
+}) (*core.WorkflowTemplate, error) {
+	var tmpl core.WorkflowTemplate
+	var workflowJSON []byte
+
+	err := scanner.Scan(
+		&tmpl.Name,
+		&tmpl.Version,
+		&tmpl.Description,
+		&workflowJSON,
+		&tmpl.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan workflow template: %w", err)
+	}
+
+	if err := json.Unmarshal(workflowJSON, &tmpl.Workflow); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal template workflow: %w", err)
+	}
+
+	return &tmpl, nil
+}
+
+// retryUniqueViolation runs insert until it succeeds, fails with anything
+// but a unique violation, or has run attempts times.
+func retryUniqueViolation(attempts int, insert func() error) error {
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = insert(); !isUniqueViolation(err) {
+			return err
+		}
+	}
+	return err
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolation
+}
@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestRetryUniqueViolationRetriesLostRaces(t *testing.T) {
+	calls := 0
+	err := retryUniqueViolation(5, func() error {
+		calls++
+		if calls < 3 {
+			return &pq.Error{Code: uniqueViolation}
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("retryUniqueViolation() = %v after %d calls, want success on the third", err, calls)
+	}
+}
+
+func TestRetryUniqueViolationGivesUp(t *testing.T) {
+	calls := 0
+	err := retryUniqueViolation(5, func() error {
+		calls++
+		return &pq.Error{Code: uniqueViolation}
+	})
+	if !isUniqueViolation(err) || calls != 5 {
+		t.Errorf("retryUniqueViolation() = %v after %d calls, want the violation after 5", err, calls)
+	}
+
+	calls = 0
+	other := errors.New("connection refused")
+	if err := retryUniqueViolation(5, func() error { calls++; return other }); err != other || calls != 1 {
+		t.Errorf("retryUniqueViolation() = %v after %d calls, want other errors returned at once", err, calls)
+	}
+}
+
+func TestPostgresHasNoTrailingWhitespace(t *testing.T) {
+	source, err := os.ReadFile("postgres.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, line := range strings.Split(string(source), "\n") {
+		if strings.TrimRight(line, " \t") != line {
+			t.Errorf("postgres.go:%d has trailing whitespace", i+1)
+		}
+	}
+}
@@ -0,0 +1,369 @@
+// Package telemetry exposes counters, gauges and histograms in the
+// Prometheus text exposition format.
+package telemetry
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are histogram upper bounds, in seconds, suited to task and
+// scheduling durations.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600}
+
+type collector interface {
+	write(w io.Writer, constant labelSet)
+	labelNames() []string
+}
+
+// labelSet holds label names and their values in matching order.
+type labelSet struct {
+	names  []string
+	values []string
+}
+
+// labelNamePattern matches valid Prometheus label names.
+var labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Registry holds metrics and serves them over HTTP.
+type Registry struct {
+	mu         sync.Mutex
+	collectors []collector
+	constant   labelSet
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, c)
+}
+
+// ServeHTTP writes every registered metric in the text exposition format.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	r.mu.Lock()
+	collectors := append([]collector(nil), r.collectors...)
+	constant := r.constant
+	r.mu.Unlock()
+
+	for _, c := range collectors {
+		c.write(w, constant)
+	}
+}
+
+// SetConstLabels adds labels, such as the cluster or environment a process
+// runs in, to every sample the registry serves. A name must be a valid
+// Prometheus label name that none of the registered metrics uses.
+func (r *Registry) SetConstLabels(labels map[string]string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	used := map[string]bool{"le": true}
+	for _, c := range r.collectors {
+		for _, name := range c.labelNames() {
+			used[name] = true
+		}
+	}
+
+	var constant labelSet
+	for name := range labels {
+		if !labelNamePattern.MatchString(name) || strings.HasPrefix(name, "__") {
+			return fmt.Errorf("invalid label name %q", name)
+		}
+		if used[name] {
+			return fmt.Errorf("label %q is already used by a metric", name)
+		}
+		constant.names = append(constant.names, name)
+	}
+	sort.Strings(constant.names)
+	for _, name := range constant.names {
+		constant.values = append(constant.values, labels[name])
+	}
+
+	r.constant = constant
+	return nil
+}
+
+// ParseLabels parses comma-separated name=value pairs, such as
+// "cluster=eu-1,env=prod". An empty string has no labels.
+func ParseLabels(s string) (map[string]string, error) {
+	labels := make(map[string]string)
+	if strings.TrimSpace(s) == "" {
+		return labels, nil
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		name, value, ok := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("label %q is not name=value", pair)
+		}
+		if _, seen := labels[name]; seen {
+			return nil, fmt.Errorf("label %q is given more than once", name)
+		}
+		labels[name] = strings.TrimSpace(value)
+	}
+	return labels, nil
+}
+
+// series holds one value per combination of label values.
+type series struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string][]string
+}
+
+func newSeries(name, help, kind string, labels []string) series {
+	return series{name: name, help: help, kind: kind, labels: labels, values: make(map[string][]string)}
+}
+
+// key returns the map key of a set of label values, remembering the values
+// themselves for output. The caller holds s.mu.
+func (s *series) key(labelValues []string) string {
+	if len(labelValues) != len(s.labels) {
+		panic(fmt.Sprintf("metric %s takes %d label values, got %d", s.name, len(s.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	if _, ok := s.values[key]; !ok {
+		s.values[key] = append([]string(nil), labelValues...)
+	}
+	return key
+}
+
+// sortedKeys returns the series keys in a stable order. The caller holds
+// s.mu.
+func (s *series) sortedKeys() []string {
+	keys := make([]string, 0, len(s.values))
+	for key := range s.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *series) labelNames() []string {
+	return s.labels
+}
+
+func (s *series) writeHeader(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", s.name, escapeHelp(s.help), s.name, s.kind)
+}
+
+// CounterVec is a monotonically increasing count per set of label values.
+type CounterVec struct {
+	series
+	counts map[string]float64
+}
+
+func (r *Registry) Counter(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{series: newSeries(name, help, "counter", labels), counts: make(map[string]float64)}
+	r.register(c)
+	return c
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[c.key(labelValues)] += delta
+}
+
+func (c *CounterVec) write(w io.Writer, constant labelSet) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.writeHeader(w)
+	for _, key := range c.sortedKeys() {
+		writeSample(w, c.name, c.labels, c.values[key], constant, c.counts[key])
+	}
+}
+
+// GaugeVec is a value that can go up and down per set of label values.
+type GaugeVec struct {
+	series
+	gauges map[string]float64
+}
+
+func (r *Registry) Gauge(name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{series: newSeries(name, help, "gauge", labels), gauges: make(map[string]float64)}
+	r.register(g)
+	return g
+}
+
+func (g *GaugeVec) Set(value float64, labelValues ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.gauges[g.key(labelValues)] = value
+}
+
+func (g *GaugeVec) Add(delta float64, labelValues ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.gauges[g.key(labelValues)] += delta
+}
+
+func (g *GaugeVec) write(w io.Writer, constant labelSet) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.writeHeader(w)
+	for _, key := range g.sortedKeys() {
+		writeSample(w, g.name, g.labels, g.values[key], constant, g.gauges[key])
+	}
+}
+
+// Sample is one value reported by a GaugeFunc, with its label values in the
+// order the gauge declared its labels.
+type Sample struct {
+	LabelValues []string
+	Value       float64
+}
+
+type gaugeFunc struct {
+	name    string
+	help    string
+	labels  []string
+	collect func() []Sample
+}
+
+// GaugeFunc registers a gauge whose samples are collected on every scrape,
+// for values such as queue depths that live elsewhere.
+func (r *Registry) GaugeFunc(name, help string, labels []string, collect func() []Sample) {
+	r.register(&gaugeFunc{name: name, help: help, labels: labels, collect: collect})
+}
+
+func (g *gaugeFunc) labelNames() []string {
+	return g.labels
+}
+
+func (g *gaugeFunc) write(w io.Writer, constant labelSet) {
+	samples := g.collect()
+	sort.Slice(samples, func(i, j int) bool {
+		return strings.Join(samples[i].LabelValues, "\xff") < strings.Join(samples[j].LabelValues, "\xff")
+	})
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", g.name, escapeHelp(g.help), g.name)
+	for _, sample := range samples {
+		writeSample(w, g.name, g.labels, sample.LabelValues, constant, sample.Value)
+	}
+}
+
+// HistogramVec counts observations into cumulative buckets per set of label
+// values.
+type HistogramVec struct {
+	series
+	buckets []float64
+	counts  map[string][]uint64
+	sums    map[string]float64
+	totals  map[string]uint64
+}
+
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{
+		series:  newSeries(name, help, "histogram", labels),
+		buckets: buckets,
+		counts:  make(map[string][]uint64),
+		sums:    make(map[string]float64),
+		totals:  make(map[string]uint64),
+	}
+	r.register(h)
+	return h
+}
+
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key := h.key(labelValues)
+	counts, ok := h.counts[key]
+	if !ok {
+		counts = make([]uint64, len(h.buckets))
+		h.counts[key] = counts
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			counts[i]++
+		}
+	}
+	h.sums[key] += value
+	h.totals[key]++
+}
+
+func (h *HistogramVec) write(w io.Writer, constant labelSet) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.writeHeader(w)
+	bucketLabels := append(append([]string(nil), h.labels...), "le")
+	for _, key := range h.sortedKeys() {
+		labelValues := h.values[key]
+		for i, bound := range h.buckets {
+			writeSample(w, h.name+"_bucket", bucketLabels, append(append([]string(nil), labelValues...), formatFloat(bound)), constant, float64(h.counts[key][i]))
+		}
+		writeSample(w, h.name+"_bucket", bucketLabels, append(append([]string(nil), labelValues...), "+Inf"), constant, float64(h.totals[key]))
+		writeSample(w, h.name+"_sum", h.labels, labelValues, constant, h.sums[key])
+		writeSample(w, h.name+"_count", h.labels, labelValues, constant, float64(h.totals[key]))
+	}
+}
+
+// writeSample writes one sample, with the registry's constant labels after
+// the metric's own.
+func writeSample(w io.Writer, name string, labels, labelValues []string, constant labelSet, value float64) {
+	if len(labels) == 0 && len(constant.names) == 0 {
+		fmt.Fprintf(w, "%s %s\n", name, formatFloat(value))
+		return
+	}
+
+	pairs := make([]string, 0, len(labels)+len(constant.names))
+	for i, label := range labels {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, label, escapeLabel(labelValues[i])))
+	}
+	for i, label := range constant.names {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, label, escapeLabel(constant.values[i])))
+	}
+	fmt.Fprintf(w, "%s{%s} %s\n", name, strings.Join(pairs, ","), formatFloat(value))
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
@@ -0,0 +1,99 @@
+package flowtest
+
+import "reflect"
+
+// AssertTaskRan fails the test unless the task has started at least once.
+func (o *Orchestrator) AssertTaskRan(ref string) {
+	o.t.Helper()
+
+	task := o.Task(ref)
+	if o.firstExecution(task.ID) < 0 {
+		o.t.Errorf("flowtest: task %s never ran, it is %s", ref, task.Status)
+	}
+}
+
+// AssertTaskNotRan fails the test if the task has started.
+func (o *Orchestrator) AssertTaskNotRan(ref string) {
+	o.t.Helper()
+
+	task := o.Task(ref)
+	if o.firstExecution(task.ID) >= 0 {
+		o.t.Errorf("flowtest: task %s ran, it is %s", ref, task.Status)
+	}
+}
+
+// AssertRanBefore fails the test unless first started before second did.
+func (o *Orchestrator) AssertRanBefore(first, second string) {
+	o.t.Helper()
+
+	i := o.firstExecution(o.Task(first).ID)
+	j := o.firstExecution(o.Task(second).ID)
+	switch {
+	case i < 0:
+		o.t.Errorf("flowtest: task %s never ran", first)
+	case j >= 0 && j < i:
+		o.t.Errorf("flowtest: task %s ran before %s", second, first)
+	}
+}
+
+// AssertTaskStatus fails the test unless the task is in status.
+func (o *Orchestrator) AssertTaskStatus(ref string, status TaskStatus) {
+	o.t.Helper()
+
+	task := o.Task(ref)
+	if task.Status != status {
+		o.t.Errorf("flowtest: task %s is %s, want %s (error: %q)", ref, task.Status, status, task.Error)
+	}
+}
+
+// AssertTaskResult fails the test unless the task completed with result.
+func (o *Orchestrator) AssertTaskResult(ref string, result map[string]interface{}) {
+	o.t.Helper()
+
+	task := o.Task(ref)
+	if task.Status != TaskStatusCompleted {
+		o.t.Errorf("flowtest: task %s is %s, want %s", ref, task.Status, TaskStatusCompleted)
+		return
+	}
+	if !reflect.DeepEqual(task.Result, result) {
+		o.t.Errorf("flowtest: task %s result is %v, want %v", ref, task.Result, result)
+	}
+}
+
+// AssertTaskAttempts fails the test unless the task has started attempts
+// times.
+func (o *Orchestrator) AssertTaskAttempts(ref string, attempts int) {
+	o.t.Helper()
+
+	task := o.Task(ref)
+	started := 0
+	for _, execution := range o.store.attempts {
+		if execution.TaskID == task.ID {
+			started++
+		}
+	}
+	if started != attempts {
+		o.t.Errorf("flowtest: task %s ran %d times, want %d", ref, started, attempts)
+	}
+}
+
+// AssertWorkflowStatus fails the test unless the workflow, given by ID or
+// name, is in status.
+func (o *Orchestrator) AssertWorkflowStatus(ref string, status WorkflowStatus) {
+	o.t.Helper()
+
+	workflow := o.Workflow(ref)
+	if workflow.Status != status {
+		o.t.Errorf("flowtest: workflow %s is %s, want %s (error: %q)", ref, workflow.Status, status, workflow.Error)
+	}
+}
+
+// firstExecution returns the index of the task's first attempt, or -1.
+func (o *Orchestrator) firstExecution(taskID string) int {
+	for i, execution := range o.store.attempts {
+		if execution.TaskID == taskID {
+			return i
+		}
+	}
+	return -1
+}
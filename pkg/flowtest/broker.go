@@ -0,0 +1,278 @@
+package flowtest
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"flowctl/internal/core"
+)
+
+// memoryBroker is the core.Broker an Orchestrator's scheduler queues tasks
+// on, with retries, run_at delays and wait timers kept on the
+// Orchestrator's clock. It has one queue for every task type, in the order
+// tasks were queued, and no policies: nothing is paused, rate limited,
+// sandboxed or length limited. Methods the scheduler does not call on the
+// paths an Orchestrator drives are left to the embedded nil Broker, so
+// calling one panics.
+type memoryBroker struct {
+	core.Broker
+
+	now       func() time.Time
+	types     map[string]bool
+	queued    []*Task
+	delayed   []*Task
+	retries   []scheduledRetry
+	leased    map[string]bool
+	cancelled map[string]bool
+	timers    map[string]time.Time
+	results   map[string]map[string]interface{}
+	dedupe    map[string]dedupeClaim
+}
+
+// scheduledRetry is a nacked task waiting out its retry delay.
+type scheduledRetry struct {
+	task *Task
+	at   time.Time
+}
+
+type dedupeClaim struct {
+	taskID  string
+	expires time.Time
+}
+
+func newMemoryBroker(now func() time.Time) *memoryBroker {
+	return &memoryBroker{
+		now:       now,
+		types:     make(map[string]bool),
+		leased:    make(map[string]bool),
+		cancelled: make(map[string]bool),
+		timers:    make(map[string]time.Time),
+		results:   make(map[string]map[string]interface{}),
+		dedupe:    make(map[string]dedupeClaim),
+	}
+}
+
+func (b *memoryBroker) EnqueueTasks(ctx context.Context, tasks []*Task) error {
+	for _, task := range tasks {
+		queued := *task
+		b.types[task.Type] = true
+		if task.RunAt != nil && task.RunAt.After(b.now()) {
+			b.delayed = append(b.delayed, &queued)
+			continue
+		}
+		b.queued = append(b.queued, &queued)
+	}
+	return nil
+}
+
+// dequeue leases the task that has been queued longest, of any type, or
+// returns nil if the queues are empty.
+func (b *memoryBroker) dequeue() *Task {
+	if len(b.queued) == 0 {
+		return nil
+	}
+	task := b.queued[0]
+	b.queued = b.queued[1:]
+	b.leased[task.ID] = true
+	return task
+}
+
+func (b *memoryBroker) AckTask(ctx context.Context, task *Task) error {
+	delete(b.leased, task.ID)
+	return nil
+}
+
+// NackTask schedules the retry of a failed task after its retry policy's
+// delay, or drops it once it has no retries left.
+func (b *memoryBroker) NackTask(ctx context.Context, task *Task, errorMsg string) error {
+	delete(b.leased, task.ID)
+	if task.RetryCount >= task.MaxRetries {
+		return nil
+	}
+
+	retry := *task
+	retry.RetryDelay = task.RetryPolicy.Delay(task.RetryCount, task.RetryDelay)
+	retry.RetryCount++
+	retry.Error = errorMsg
+	b.retries = append(b.retries, scheduledRetry{task: &retry, at: b.now().Add(retry.RetryDelay)})
+	return nil
+}
+
+func (b *memoryBroker) GetTaskTypes(ctx context.Context) ([]string, error) {
+	types := make([]string, 0, len(b.types))
+	for taskType := range b.types {
+		types = append(types, taskType)
+	}
+	sort.Strings(types)
+	return types, nil
+}
+
+func (b *memoryBroker) ProcessRetries(ctx context.Context, taskType string) error {
+	now := b.now()
+	pending := b.retries[:0]
+	for _, retry := range b.retries {
+		if retry.task.Type == taskType && !now.Before(retry.at) {
+			b.queued = append(b.queued, retry.task)
+			continue
+		}
+		pending = append(pending, retry)
+	}
+	b.retries = pending
+	return nil
+}
+
+func (b *memoryBroker) ProcessDelayedTasks(ctx context.Context, taskType string) error {
+	now := b.now()
+	pending := b.delayed[:0]
+	for _, task := range b.delayed {
+		if task.Type == taskType && !now.Before(*task.RunAt) {
+			b.queued = append(b.queued, task)
+			continue
+		}
+		pending = append(pending, task)
+	}
+	b.delayed = pending
+	return nil
+}
+
+// PurgeTasks takes tasks off the queue, delayed and retry sets, and
+// returns the ones leased to the Orchestrator's worker.
+func (b *memoryBroker) PurgeTasks(ctx context.Context, tasks []Task) (int, []string, error) {
+	purge := make(map[string]bool, len(tasks))
+	for _, task := range tasks {
+		purge[task.ID] = true
+	}
+
+	removed := 0
+	keep := func(task *Task) bool {
+		if purge[task.ID] {
+			removed++
+			return false
+		}
+		return true
+	}
+
+	queued := b.queued[:0]
+	for _, task := range b.queued {
+		if keep(task) {
+			queued = append(queued, task)
+		}
+	}
+	b.queued = queued
+
+	delayed := b.delayed[:0]
+	for _, task := range b.delayed {
+		if keep(task) {
+			delayed = append(delayed, task)
+		}
+	}
+	b.delayed = delayed
+
+	retries := b.retries[:0]
+	for _, retry := range b.retries {
+		if keep(retry.task) {
+			retries = append(retries, retry)
+		}
+	}
+	b.retries = retries
+
+	var leased []string
+	for _, task := range tasks {
+		if b.leased[task.ID] {
+			leased = append(leased, task.ID)
+		}
+	}
+	return removed, leased, nil
+}
+
+func (b *memoryBroker) PublishCancellation(ctx context.Context, taskID string) error {
+	b.cancelled[taskID] = true
+	return nil
+}
+
+func (b *memoryBroker) AddTimer(ctx context.Context, taskID string, fireAt time.Time) error {
+	b.timers[taskID] = fireAt
+	return nil
+}
+
+func (b *memoryBroker) GetDueTimers(ctx context.Context, now time.Time) ([]string, error) {
+	var due []string
+	for taskID, fireAt := range b.timers {
+		if !now.Before(fireAt) {
+			due = append(due, taskID)
+		}
+	}
+	sort.Strings(due)
+	return due, nil
+}
+
+func (b *memoryBroker) RemoveTimers(ctx context.Context, taskIDs ...string) (int, error) {
+	removed := 0
+	for _, taskID := range taskIDs {
+		if _, ok := b.timers[taskID]; ok {
+			delete(b.timers, taskID)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+func (b *memoryBroker) GetIdempotentResult(ctx context.Context, namespace, key string) (map[string]interface{}, bool, error) {
+	result, ok := b.results[namespace+"/"+key]
+	return result, ok, nil
+}
+
+func (b *memoryBroker) SaveIdempotentResult(ctx context.Context, namespace, key string, result map[string]interface{}) error {
+	b.results[namespace+"/"+key] = result
+	return nil
+}
+
+func (b *memoryBroker) ClaimDedupeKey(ctx context.Context, fingerprint, taskID string, window time.Duration, stale string) (string, error) {
+	now := b.now()
+	if claim, ok := b.dedupe[fingerprint]; ok && now.Before(claim.expires) && claim.taskID != stale {
+		return claim.taskID, nil
+	}
+	b.dedupe[fingerprint] = dedupeClaim{taskID: taskID, expires: now.Add(window)}
+	return taskID, nil
+}
+
+func (b *memoryBroker) IsQueuePaused(ctx context.Context, taskType string) (bool, error) {
+	return false, nil
+}
+
+func (b *memoryBroker) GetQueueLengthLimits(ctx context.Context) (map[string]core.QueueLengthLimit, error) {
+	return nil, nil
+}
+
+func (b *memoryBroker) GetTaskRateLimits(ctx context.Context) (map[string]core.TaskRateLimit, error) {
+	return nil, nil
+}
+
+func (b *memoryBroker) GetRetryOverloadPolicies(ctx context.Context) ([]core.RetryOverloadPolicy, error) {
+	return nil, nil
+}
+
+func (b *memoryBroker) GetSandboxPolicy(ctx context.Context, namespace string) (*core.SandboxPolicy, error) {
+	return nil, nil
+}
+
+func (b *memoryBroker) GetDecisionLogConfig(ctx context.Context) (*core.DecisionLogConfig, error) {
+	return nil, nil
+}
+
+func (b *memoryBroker) PublishEvent(ctx context.Context, event *core.Event) error {
+	return nil
+}
+
+func (b *memoryBroker) PublishEvents(ctx context.Context, events []*core.Event) error {
+	return nil
+}
+
+func (b *memoryBroker) ListWebhooks(ctx context.Context) ([]core.Webhook, error) {
+	return nil, nil
+}
+
+func (b *memoryBroker) RecordInjectedTaskStatus(ctx context.Context, taskID string, status TaskStatus, result map[string]interface{}, errorMsg string) (bool, error) {
+	return false, nil
+}
@@ -0,0 +1,453 @@
+// Package flowtest runs workflows in memory for Go tests. An Orchestrator
+// drives the real scheduler over an in-memory store and broker, runs the
+// tasks it queues with handlers registered per task type, as a worker
+// would, and keeps its own clock, so workflow definitions, payload
+// templates, retries and timeouts can be tested without Postgres, Redis or
+// waiting.
+//
+//	o := flowtest.New(t)
+//	o.Handle("etl", func(ctx context.Context, task *flowtest.Task) (map[string]interface{}, error) {
+//		return map[string]interface{}{"rows": 10}, nil
+//	})
+//	o.SubmitFile("pipelines/nightly.yaml")
+//	o.CompleteTask("approve", map[string]interface{}{"approved": true})
+//	o.AssertTaskRan("load")
+//	o.AssertWorkflowStatus("nightly", flowtest.WorkflowStatusCompleted)
+//
+// Tasks of a type without a handler start and then wait, running, until the
+// test completes or fails them with CompleteTask or FailTask, as do approval
+// tasks, which the scheduler holds for an operator. Wait tasks finish once
+// AdvanceTime reaches the end of their wait. Template functions such as now
+// read the wall clock when the workflow is submitted, not the Orchestrator's
+// clock.
+package flowtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"testing"
+	"time"
+
+	"flowctl/internal/core"
+
+	"github.com/sirupsen/logrus"
+)
+
+type (
+	Task               = core.Task
+	Workflow           = core.Workflow
+	TaskStatus         = core.TaskStatus
+	WorkflowStatus     = core.WorkflowStatus
+	WorkflowDefinition = core.WorkflowDefinition
+	TaskDefinition     = core.TaskDefinition
+	ExecutionContext   = core.ExecutionContext
+)
+
+const (
+	TaskStatusPending   = core.TaskStatusPending
+	TaskStatusQueued    = core.TaskStatusQueued
+	TaskStatusRunning   = core.TaskStatusRunning
+	TaskStatusCompleted = core.TaskStatusCompleted
+	TaskStatusFailed    = core.TaskStatusFailed
+	TaskStatusRetrying  = core.TaskStatusRetrying
+	TaskStatusCancelled = core.TaskStatusCancelled
+	TaskStatusSkipped   = core.TaskStatusSkipped
+	TaskStatusExpired   = core.TaskStatusExpired
+
+	WorkflowStatusPending   = core.WorkflowStatusPending
+	WorkflowStatusRunning   = core.WorkflowStatusRunning
+	WorkflowStatusCompleted = core.WorkflowStatusCompleted
+	WorkflowStatusFailed    = core.WorkflowStatusFailed
+)
+
+// maxPasses bounds how many times run drives the scheduler without the
+// workflows coming to rest, so a test that would never finish fails.
+const maxPasses = 10000
+
+// workerID is the worker the Orchestrator reports task attempts from.
+const workerID = "flowtest"
+
+// Handler runs one attempt of a task, like a worker's task handler. Its ctx
+// carries the attempt's ExecutionContext, with a deadline on the
+// Orchestrator's clock. An error fails the attempt, which is retried while
+// the task has retries left.
+type Handler func(ctx context.Context, task *Task) (map[string]interface{}, error)
+
+// ExecutionContextFrom returns the execution context of the attempt a
+// handler is running.
+func ExecutionContextFrom(ctx context.Context) (*ExecutionContext, bool) {
+	return core.ExecutionContextFrom(ctx)
+}
+
+// Execution records one attempt of a task.
+type Execution struct {
+	WorkflowID string
+	TaskID     string
+	TaskName   string
+	Attempt    int
+	StartedAt  time.Time
+}
+
+// Orchestrator is a scheduler and worker for one test, with the scheduler's
+// store and broker kept in memory. It is not safe for concurrent use, and
+// handlers must not call it.
+type Orchestrator struct {
+	t         testing.TB
+	ctx       context.Context
+	now       time.Time
+	handlers  map[string]Handler
+	store     *memoryStore
+	broker    *memoryBroker
+	scheduler *core.Scheduler
+	// waiting holds the attempts the worker started without a handler,
+	// by task ID, until the test completes or fails them.
+	waiting map[string]*Task
+}
+
+// New returns an Orchestrator whose clock starts at the current time and
+// only moves when AdvanceTime is called.
+func New(t testing.TB) *Orchestrator {
+	o := &Orchestrator{
+		t:        t,
+		ctx:      context.Background(),
+		now:      time.Now(),
+		handlers: make(map[string]Handler),
+		waiting:  make(map[string]*Task),
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	o.store = newMemoryStore(o.Now)
+	o.broker = newMemoryBroker(o.Now)
+	o.scheduler = core.NewScheduler(o.store, o.broker, logger)
+	o.scheduler.SetClock(o.Now)
+	return o
+}
+
+// Handle registers the handler that runs tasks of a type. Tasks that are
+// already waiting for a handler keep waiting for CompleteTask or FailTask.
+func (o *Orchestrator) Handle(taskType string, handler Handler) {
+	o.handlers[taskType] = handler
+}
+
+// Now returns the Orchestrator's clock.
+func (o *Orchestrator) Now() time.Time {
+	return o.now
+}
+
+// AdvanceTime moves the clock forward, then times out the attempts that
+// ran past their task's timeout and lets the scheduler requeue retries and
+// delayed tasks that have come due, complete wait tasks that are done
+// waiting and time out workflows that ran past their timeout.
+func (o *Orchestrator) AdvanceTime(d time.Duration) {
+	o.t.Helper()
+
+	o.now = o.now.Add(d)
+	for _, id := range o.waitingIDs() {
+		task := o.waiting[id]
+		if task.Timeout > 0 && !o.now.Before(o.attemptStarted(task).Add(task.Timeout)) {
+			delete(o.waiting, id)
+			o.finish(task, nil, fmt.Errorf("task timed out after %s", task.Timeout))
+		}
+	}
+	o.run()
+}
+
+// Submit starts a workflow and runs it as far as its handlers allow. The
+// returned workflow is updated in place as the run progresses.
+func (o *Orchestrator) Submit(workflow *Workflow) *Workflow {
+	o.t.Helper()
+
+	if err := workflow.ValidateDependencies(); err != nil {
+		o.t.Fatalf("flowtest: workflow %s: %v", workflow.Name, err)
+	}
+
+	workflow.Status = WorkflowStatusPending
+	if err := o.scheduler.SubmitWorkflow(o.ctx, workflow); err != nil {
+		o.t.Fatalf("flowtest: workflow %s: %v", workflow.Name, err)
+	}
+
+	o.run()
+	return workflow
+}
+
+// SubmitDefinition submits a workflow built from a definition, the shape of
+// a JSON submission to the API.
+func (o *Orchestrator) SubmitDefinition(definition *WorkflowDefinition) *Workflow {
+	o.t.Helper()
+
+	if err := definition.Validate(); err != nil {
+		o.t.Fatalf("flowtest: workflow %s: %v", definition.Name, err)
+	}
+	workflow, err := definition.NewWorkflow()
+	if err != nil {
+		o.t.Fatalf("flowtest: workflow %s: %v", definition.Name, err)
+	}
+	return o.Submit(workflow)
+}
+
+// SubmitYAML submits a workflow from a YAML definition.
+func (o *Orchestrator) SubmitYAML(data []byte) *Workflow {
+	o.t.Helper()
+
+	workflow, err := core.ParseWorkflowFromYAMLBytes(data)
+	if err != nil {
+		o.t.Fatalf("flowtest: %v", err)
+	}
+	return o.Submit(workflow)
+}
+
+// SubmitFile submits a workflow from a YAML file.
+func (o *Orchestrator) SubmitFile(filename string) *Workflow {
+	o.t.Helper()
+
+	workflow, err := core.ParseWorkflowFromYAML(filename)
+	if err != nil {
+		o.t.Fatalf("flowtest: %v", err)
+	}
+	return o.Submit(workflow)
+}
+
+// CompleteTask completes a running task with result, as a worker reporting
+// success would, or an operator approving an approval task. ref is the
+// task's ID or name.
+func (o *Orchestrator) CompleteTask(ref string, result map[string]interface{}) {
+	o.t.Helper()
+
+	o.settleByTest(ref, result, nil)
+	o.run()
+}
+
+// FailTask fails the current attempt of a running task, which is retried
+// while the task has retries left, or rejects an approval task. ref is the
+// task's ID or name.
+func (o *Orchestrator) FailTask(ref string, errorMsg string) {
+	o.t.Helper()
+
+	o.settleByTest(ref, nil, fmt.Errorf("%s", errorMsg))
+	o.run()
+}
+
+// settleByTest ends a running task the test completes or fails: an attempt
+// waiting for a handler as its worker would, and a task the scheduler
+// holds itself through the task status API, as an operator would.
+func (o *Orchestrator) settleByTest(ref string, result map[string]interface{}, err error) {
+	o.t.Helper()
+
+	task := o.Task(ref)
+	if task.Status != TaskStatusRunning {
+		o.t.Fatalf("flowtest: task %s is %s, not running", ref, task.Status)
+	}
+
+	if attempt, ok := o.waiting[task.ID]; ok {
+		delete(o.waiting, task.ID)
+		o.finish(attempt, result, err)
+		return
+	}
+	if task.Type != core.ApprovalTaskType {
+		o.t.Fatalf("flowtest: task %s is run by the scheduler, not the test", ref)
+	}
+
+	status, errorMsg := TaskStatusCompleted, ""
+	if err != nil {
+		status, errorMsg = TaskStatusFailed, err.Error()
+	}
+	if err := o.scheduler.UpdateTaskStatus(o.ctx, task.ID, status, result, errorMsg); err != nil {
+		o.t.Fatalf("flowtest: task %s: %v", ref, err)
+	}
+}
+
+// Task returns a task by ID or name. A name shared by tasks of several
+// workflows must be given as an ID instead.
+func (o *Orchestrator) Task(ref string) *Task {
+	o.t.Helper()
+
+	var matches []*Task
+	for _, workflow := range o.store.workflows {
+		for i := range workflow.Tasks {
+			task := &workflow.Tasks[i]
+			if task.ID == ref {
+				return task
+			}
+			if task.Name == ref {
+				matches = append(matches, task)
+			}
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		o.t.Fatalf("flowtest: no task %s", ref)
+	case 1:
+	default:
+		o.t.Fatalf("flowtest: %d tasks are named %s, use an ID", len(matches), ref)
+	}
+	return matches[0]
+}
+
+// Workflow returns a workflow by ID or name.
+func (o *Orchestrator) Workflow(ref string) *Workflow {
+	o.t.Helper()
+
+	var matches []*Workflow
+	for _, workflow := range o.store.workflows {
+		if workflow.ID == ref {
+			return workflow
+		}
+		if workflow.Name == ref {
+			matches = append(matches, workflow)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		o.t.Fatalf("flowtest: no workflow %s", ref)
+	case 1:
+	default:
+		o.t.Fatalf("flowtest: %d workflows are named %s, use an ID", len(matches), ref)
+	}
+	return matches[0]
+}
+
+// Executions returns every task attempt started so far, in order.
+func (o *Orchestrator) Executions() []Execution {
+	return append([]Execution(nil), o.store.attempts...)
+}
+
+// run alternates scheduler passes with running what they queue until a
+// pass changes nothing, when every task left is waiting on the test, the
+// clock or a dependency.
+func (o *Orchestrator) run() {
+	o.t.Helper()
+
+	for pass := 0; pass < maxPasses; pass++ {
+		changes := o.store.changes
+		o.scheduler.Tick(o.ctx)
+		o.dropCancelled()
+		worked := o.work()
+		if !worked && o.store.changes == changes {
+			return
+		}
+	}
+	o.t.Fatalf("flowtest: workflows still changing after %d scheduler passes", maxPasses)
+}
+
+// work runs every queued task, as a worker would, and reports whether there
+// were any.
+func (o *Orchestrator) work() bool {
+	worked := false
+	for task := o.broker.dequeue(); task != nil; task = o.broker.dequeue() {
+		worked = true
+		o.start(task)
+	}
+	return worked
+}
+
+// start reports an attempt of a dequeued task running and, if a handler
+// covers its type, runs it to the end. Otherwise it waits for the test.
+func (o *Orchestrator) start(task *Task) {
+	o.t.Helper()
+
+	if o.broker.cancelled[task.ID] {
+		o.broker.AckTask(o.ctx, task)
+		return
+	}
+
+	o.report(task, TaskStatusRunning, nil, "")
+	executor := task.Executor
+	if executor == "" {
+		executor = core.DefaultExecutor
+	}
+	env := core.ExecutionEnvironment{
+		Attempt:   task.RetryCount + 1,
+		WorkerID:  workerID,
+		Executor:  string(executor),
+		StartedAt: o.now,
+	}
+	if err := o.scheduler.RecordTaskAttempt(task.ID, env); err != nil {
+		o.t.Fatalf("flowtest: task %s: %v", task.ID, err)
+	}
+
+	handler, ok := o.handlers[task.Type]
+	if !ok {
+		o.waiting[task.ID] = task
+		return
+	}
+
+	var deadline time.Time
+	if task.Timeout > 0 {
+		deadline = o.now.Add(task.Timeout)
+	}
+	attempt := *task
+	ctx := core.WithExecutionContext(o.ctx, attempt.ExecutionContext(deadline))
+	result, err := handler(ctx, &attempt)
+	o.finish(task, result, err)
+}
+
+// finish ends an attempt as a worker does: a completed one is acked and
+// its result saved under its idempotency key, and a failed one nacked,
+// which schedules its retry while it has retries left.
+func (o *Orchestrator) finish(task *Task, result map[string]interface{}, err error) {
+	o.t.Helper()
+
+	if err == nil {
+		if task.IdempotencyKey != "" {
+			o.broker.SaveIdempotentResult(o.ctx, task.IdempotencyNamespace(), task.IdempotencyKey, result)
+		}
+		o.broker.AckTask(o.ctx, task)
+		o.report(task, TaskStatusCompleted, result, "")
+		return
+	}
+
+	o.broker.NackTask(o.ctx, task, err.Error())
+	if task.RetryCount < task.MaxRetries {
+		o.report(task, TaskStatusRetrying, nil, err.Error())
+	} else {
+		o.report(task, TaskStatusFailed, nil, err.Error())
+	}
+}
+
+// report sends the scheduler the status of a task's current attempt.
+func (o *Orchestrator) report(task *Task, status TaskStatus, result map[string]interface{}, errorMsg string) {
+	o.t.Helper()
+
+	// A task settled while its attempt ran, such as one cancelled with its
+	// workflow, keeps its outcome, as it does for a worker's report.
+	err := o.scheduler.ReportTaskStatus(o.ctx, task.ID, task.RetryCount+1, status, result, errorMsg)
+	if err != nil && !errors.Is(err, core.ErrTaskFinished) {
+		o.t.Fatalf("flowtest: task %s: %v", task.ID, err)
+	}
+}
+
+// dropCancelled lets go of the attempts waiting for the test whose tasks
+// the scheduler cancelled, such as when their workflow timed out.
+func (o *Orchestrator) dropCancelled() {
+	for _, id := range o.waitingIDs() {
+		if o.broker.cancelled[id] {
+			o.broker.AckTask(o.ctx, o.waiting[id])
+			delete(o.waiting, id)
+		}
+	}
+}
+
+// waitingIDs returns the IDs of the attempts waiting for the test, sorted
+// so they are handled in the same order on every run.
+func (o *Orchestrator) waitingIDs() []string {
+	ids := make([]string, 0, len(o.waiting))
+	for id := range o.waiting {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// attemptStarted returns when the current attempt of a task started.
+func (o *Orchestrator) attemptStarted(task *Task) time.Time {
+	if stored := o.Task(task.ID); stored.StartedAt != nil {
+		return *stored.StartedAt
+	}
+	return o.now
+}
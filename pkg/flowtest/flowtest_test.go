@@ -0,0 +1,234 @@
+package flowtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+const pipeline = `
+name: pipeline
+tasks:
+  - name: extract
+    type: etl
+  - name: transform
+    type: etl
+    depends_on: [extract]
+  - name: load
+    type: etl
+    depends_on: [transform]
+`
+
+func TestRunsTasksInDependencyOrder(t *testing.T) {
+	o := New(t)
+	o.Handle("etl", func(ctx context.Context, task *Task) (map[string]interface{}, error) {
+		if _, ok := ExecutionContextFrom(ctx); !ok {
+			t.Errorf("task %s has no execution context", task.Name)
+		}
+		return map[string]interface{}{"task": task.Name}, nil
+	})
+
+	o.SubmitYAML([]byte(pipeline))
+
+	o.AssertRanBefore("extract", "transform")
+	o.AssertRanBefore("transform", "load")
+	o.AssertTaskResult("load", map[string]interface{}{"task": "load"})
+	o.AssertTaskAttempts("load", 1)
+	o.AssertWorkflowStatus("pipeline", WorkflowStatusCompleted)
+	if executions := o.Executions(); len(executions) != 3 {
+		t.Errorf("Executions() = %+v, want 3 attempts", executions)
+	}
+}
+
+func TestTasksWithoutHandlerWaitForTheTest(t *testing.T) {
+	o := New(t)
+	o.SubmitYAML([]byte(pipeline))
+
+	o.AssertTaskStatus("extract", TaskStatusRunning)
+	o.AssertTaskNotRan("transform")
+
+	o.CompleteTask("extract", map[string]interface{}{"rows": 10})
+	o.AssertTaskResult("extract", map[string]interface{}{"rows": 10})
+	o.AssertTaskStatus("transform", TaskStatusRunning)
+
+	o.FailTask("transform", "bad input")
+	o.AssertTaskStatus("transform", TaskStatusRetrying)
+	for i := 0; i < 3; i++ {
+		o.AdvanceTime(time.Minute)
+		o.AssertTaskStatus("transform", TaskStatusRunning)
+		o.FailTask("transform", "bad input")
+	}
+	o.AssertTaskAttempts("transform", 4)
+	o.AssertTaskStatus("transform", TaskStatusFailed)
+	o.AssertTaskStatus("load", TaskStatusSkipped)
+	o.AssertWorkflowStatus("pipeline", WorkflowStatusFailed)
+}
+
+func TestRetriesOnTheClock(t *testing.T) {
+	o := New(t)
+	failures := 1
+	o.Handle("etl", func(ctx context.Context, task *Task) (map[string]interface{}, error) {
+		if failures > 0 {
+			failures--
+			return nil, errors.New("flaky")
+		}
+		return nil, nil
+	})
+
+	o.SubmitYAML([]byte(`
+name: flaky
+tasks:
+  - name: extract
+    type: etl
+    max_retries: 1
+`))
+	o.AssertTaskStatus("extract", TaskStatusRetrying)
+	o.AssertTaskAttempts("extract", 1)
+
+	o.AdvanceTime(time.Minute)
+	o.AssertTaskStatus("extract", TaskStatusCompleted)
+	o.AssertTaskAttempts("extract", 2)
+	if attempt := o.Executions()[1].Attempt; attempt != 2 {
+		t.Errorf("second execution is attempt %d, want 2", attempt)
+	}
+	o.AssertWorkflowStatus("flaky", WorkflowStatusCompleted)
+}
+
+func TestWaitTasksFinishWhenTheClockReachesThem(t *testing.T) {
+	o := New(t)
+	o.Handle("etl", func(ctx context.Context, task *Task) (map[string]interface{}, error) {
+		return nil, nil
+	})
+
+	o.SubmitYAML([]byte(`
+name: delayed
+tasks:
+  - name: pause
+    type: wait
+    payload:
+      duration: 10m
+  - name: load
+    type: etl
+    depends_on: [pause]
+`))
+	o.AssertTaskStatus("pause", TaskStatusRunning)
+
+	o.AdvanceTime(5 * time.Minute)
+	o.AssertTaskNotRan("load")
+
+	o.AdvanceTime(5 * time.Minute)
+	o.AssertTaskStatus("pause", TaskStatusCompleted)
+	o.AssertTaskRan("load")
+	o.AssertWorkflowStatus("delayed", WorkflowStatusCompleted)
+}
+
+func TestTimeouts(t *testing.T) {
+	o := New(t)
+	o.SubmitYAML([]byte(`
+name: slow
+config:
+  timeout: 1h
+tasks:
+  - name: extract
+    type: etl
+    timeout: 10m
+  - name: load
+    type: etl
+    depends_on: [extract]
+`))
+
+	o.AdvanceTime(9 * time.Minute)
+	o.AssertTaskStatus("extract", TaskStatusRunning)
+	o.AdvanceTime(time.Minute)
+	o.AssertTaskStatus("extract", TaskStatusRetrying)
+
+	o.AdvanceTime(time.Minute)
+	o.AssertTaskStatus("extract", TaskStatusRunning)
+	o.AssertTaskAttempts("extract", 2)
+
+	o.AdvanceTime(49 * time.Minute)
+	o.AssertTaskStatus("extract", TaskStatusCancelled)
+	o.AssertTaskStatus("load", TaskStatusCancelled)
+	if workflow := o.Workflow("slow"); workflow.Status != WorkflowStatusFailed || workflow.Error != "workflow timed out after 1h0m0s" {
+		t.Errorf("workflow is %s (%q), want failed on its timeout", workflow.Status, workflow.Error)
+	}
+}
+
+func TestWorkflowTimeoutCancelsRetries(t *testing.T) {
+	o := New(t)
+	o.Handle("etl", func(ctx context.Context, task *Task) (map[string]interface{}, error) {
+		return nil, errors.New("down")
+	})
+
+	o.SubmitYAML([]byte(`
+name: stuck
+config:
+  timeout: 30m
+  retry_policy:
+    initial_delay: 1h
+    max_delay: 1h
+tasks:
+  - name: extract
+    type: etl
+    max_retries: 3
+`))
+	o.AssertTaskStatus("extract", TaskStatusRetrying)
+
+	o.AdvanceTime(30 * time.Minute)
+	o.AssertWorkflowStatus("stuck", WorkflowStatusFailed)
+	o.AssertTaskStatus("extract", TaskStatusCancelled)
+
+	o.AdvanceTime(2 * time.Hour)
+	o.AssertTaskStatus("extract", TaskStatusCancelled)
+	o.AssertTaskAttempts("extract", 1)
+}
+
+func TestApprovalTasksWaitForTheTest(t *testing.T) {
+	o := New(t)
+	o.Handle("deploy", func(ctx context.Context, task *Task) (map[string]interface{}, error) {
+		return nil, nil
+	})
+
+	o.SubmitYAML([]byte(`
+name: release
+tasks:
+  - name: approve
+    type: approval
+  - name: deploy
+    type: deploy
+    depends_on: [approve]
+`))
+	o.AssertTaskStatus("approve", TaskStatusRunning)
+	o.AssertTaskNotRan("deploy")
+
+	o.CompleteTask("approve", map[string]interface{}{"approved": true})
+	o.AssertTaskRan("deploy")
+	o.AssertWorkflowStatus("release", WorkflowStatusCompleted)
+}
+
+func TestIdempotentTasksReuseResults(t *testing.T) {
+	o := New(t)
+	runs := 0
+	o.Handle("etl", func(ctx context.Context, task *Task) (map[string]interface{}, error) {
+		runs++
+		return map[string]interface{}{"run": runs}, nil
+	})
+
+	definition := `
+name: %s
+tasks:
+  - name: extract
+    type: etl
+    idempotency_key: nightly
+`
+	first := o.SubmitYAML([]byte(fmt.Sprintf(definition, "first")))
+	second := o.SubmitYAML([]byte(fmt.Sprintf(definition, "second")))
+
+	if runs != 1 {
+		t.Errorf("handler ran %d times, want once", runs)
+	}
+	o.AssertTaskResult(second.Tasks[0].ID, first.Tasks[0].Result)
+	o.AssertWorkflowStatus(second.ID, WorkflowStatusCompleted)
+}
@@ -0,0 +1,301 @@
+package flowtest
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"flowctl/internal/core"
+)
+
+// memoryStore is the core.Store an Orchestrator's scheduler keeps its
+// workflows in. It holds each submitted workflow itself and updates it in
+// place, so the workflows and tasks a test holds reflect the run. Methods
+// the scheduler does not call on the paths an Orchestrator drives are left
+// to the embedded nil Store, so calling one panics.
+type memoryStore struct {
+	core.Store
+
+	now       func() time.Time
+	workflows []*Workflow
+	keys      map[string]string
+	attempts  []Execution
+	// changes counts writes, so the Orchestrator can tell when a pass of
+	// the scheduler changed nothing.
+	changes int
+}
+
+func newMemoryStore(now func() time.Time) *memoryStore {
+	return &memoryStore{now: now, keys: make(map[string]string)}
+}
+
+func (st *memoryStore) workflow(id string) *Workflow {
+	for _, workflow := range st.workflows {
+		if workflow.ID == id {
+			return workflow
+		}
+	}
+	return nil
+}
+
+func (st *memoryStore) task(id string) (*Workflow, *Task) {
+	for _, workflow := range st.workflows {
+		for i := range workflow.Tasks {
+			if workflow.Tasks[i].ID == id {
+				return workflow, &workflow.Tasks[i]
+			}
+		}
+	}
+	return nil, nil
+}
+
+// copyWorkflow copies a workflow and its tasks, as reading it back from
+// Postgres would, so the scheduler cannot change the stored one.
+func copyWorkflow(workflow *Workflow) *Workflow {
+	copied := *workflow
+	copied.Tasks = append([]Task(nil), workflow.Tasks...)
+	return &copied
+}
+
+func (st *memoryStore) CreateWorkflowWithTasks(workflow *Workflow) error {
+	now := st.now()
+	workflow.CreatedAt = now
+	workflow.UpdatedAt = now
+	for i := range workflow.Tasks {
+		workflow.Tasks[i].CreatedAt = now
+		workflow.Tasks[i].UpdatedAt = now
+	}
+	st.workflows = append(st.workflows, workflow)
+	st.changes++
+	return nil
+}
+
+func (st *memoryStore) GetWorkflow(id string) (*Workflow, error) {
+	workflow := st.workflow(id)
+	if workflow == nil {
+		return nil, fmt.Errorf("workflow not found: %s", id)
+	}
+	return copyWorkflow(workflow), nil
+}
+
+func (st *memoryStore) WorkflowExists(id string) (bool, error) {
+	return st.workflow(id) != nil, nil
+}
+
+func (st *memoryStore) WorkflowNamespace(id string) (string, bool, error) {
+	workflow := st.workflow(id)
+	if workflow == nil {
+		return "", false, nil
+	}
+	return workflow.Namespace, true, nil
+}
+
+func (st *memoryStore) GetRunningWorkflows() ([]Workflow, error) {
+	var running []Workflow
+	for _, workflow := range st.workflows {
+		if workflow.Status == WorkflowStatusRunning {
+			copied := *workflow
+			copied.Tasks = nil
+			running = append(running, copied)
+		}
+	}
+	return running, nil
+}
+
+func (st *memoryStore) UpdateWorkflowStatus(id string, status WorkflowStatus) error {
+	workflow := st.workflow(id)
+	if workflow == nil {
+		return fmt.Errorf("workflow not found: %s", id)
+	}
+
+	now := st.now()
+	workflow.Status = status
+	workflow.UpdatedAt = now
+	switch {
+	case status == WorkflowStatusRunning:
+		workflow.StartedAt = &now
+	case status.IsTerminal():
+		workflow.CompletedAt = &now
+	}
+	st.changes++
+	return nil
+}
+
+func (st *memoryStore) FailWorkflow(id, errorMsg string) error {
+	if err := st.UpdateWorkflowStatus(id, WorkflowStatusFailed); err != nil {
+		return err
+	}
+	st.workflow(id).Error = errorMsg
+	return nil
+}
+
+func (st *memoryStore) RetryBudgetUsage(workflowID string) (int, int, error) {
+	workflow := st.workflow(workflowID)
+	if workflow == nil {
+		return 0, 0, fmt.Errorf("workflow not found: %s", workflowID)
+	}
+	used := 0
+	for _, task := range workflow.Tasks {
+		used += task.RetryCount
+	}
+	return workflow.Config.RetryBudget, used, nil
+}
+
+func (st *memoryStore) GetTask(id string) (*Task, error) {
+	task, _ := st.FindTask(id)
+	if task == nil {
+		return nil, fmt.Errorf("task not found: %s", id)
+	}
+	return task, nil
+}
+
+func (st *memoryStore) FindTask(id string) (*Task, error) {
+	_, task := st.task(id)
+	if task == nil {
+		return nil, nil
+	}
+	copied := *task
+	return &copied, nil
+}
+
+func (st *memoryStore) TaskNamespace(id string) (string, bool, error) {
+	workflow, _ := st.task(id)
+	if workflow == nil {
+		return "", false, nil
+	}
+	return workflow.Namespace, true, nil
+}
+
+func (st *memoryStore) ExistingTaskIDs(ids []string) ([]string, error) {
+	var existing []string
+	for _, id := range ids {
+		if _, task := st.task(id); task != nil {
+			existing = append(existing, id)
+		}
+	}
+	return existing, nil
+}
+
+func (st *memoryStore) GetTasksByWorkflow(workflowID string) ([]Task, error) {
+	workflow := st.workflow(workflowID)
+	if workflow == nil {
+		return nil, nil
+	}
+	return append([]Task(nil), workflow.Tasks...), nil
+}
+
+// GetPendingTasks returns the pending tasks highest priority first, then
+// in submission order, as Postgres does.
+func (st *memoryStore) GetPendingTasks() ([]Task, error) {
+	var pending []Task
+	for _, workflow := range st.workflows {
+		for _, task := range workflow.Tasks {
+			if task.Status == TaskStatusPending {
+				pending = append(pending, task)
+			}
+		}
+	}
+	sort.SliceStable(pending, func(i, j int) bool {
+		return pending[i].Priority > pending[j].Priority
+	})
+	return pending, nil
+}
+
+func (st *memoryStore) GetExpiredTasks(now time.Time, limit int) ([]Task, error) {
+	var expired []Task
+	for _, workflow := range st.workflows {
+		for _, task := range workflow.Tasks {
+			switch task.Status {
+			case TaskStatusPending, core.TaskStatusQueued, TaskStatusRetrying:
+				if task.Expired(now) && len(expired) < limit {
+					expired = append(expired, task)
+				}
+			}
+		}
+	}
+	return expired, nil
+}
+
+func (st *memoryStore) ClaimIdempotencyKey(namespace, key, taskID, stale string) (string, error) {
+	claim := namespace + "/" + key
+	if holder, ok := st.keys[claim]; ok && holder != stale && holder != taskID {
+		return holder, nil
+	}
+	st.keys[claim] = taskID
+	return taskID, nil
+}
+
+// UpdateTaskStatus records a status the way the Postgres store does: a
+// running task gets its start time, a finished one its result or error and
+// completion time, and a retrying one counts the retry.
+func (st *memoryStore) UpdateTaskStatus(id string, status TaskStatus, result map[string]interface{}, errorMsg string) error {
+	_, task := st.task(id)
+	if task == nil {
+		return fmt.Errorf("task not found: %s", id)
+	}
+
+	now := st.now()
+	task.Status = status
+	task.UpdatedAt = now
+	switch status {
+	case TaskStatusRunning:
+		task.StartedAt = &now
+	case TaskStatusCompleted:
+		task.Result = result
+		task.CompletedAt = &now
+	case TaskStatusFailed, TaskStatusCancelled, TaskStatusSkipped, TaskStatusExpired:
+		task.Error = errorMsg
+		task.CompletedAt = &now
+	case TaskStatusRetrying:
+		task.RetryCount++
+	}
+	st.changes++
+	return nil
+}
+
+func (st *memoryStore) UpdateTasksStatus(ids []string, status TaskStatus) error {
+	for _, id := range ids {
+		if err := st.UpdateTaskStatus(id, status, nil, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (st *memoryStore) SetTaskDuplicateOf(id, originalID string) error {
+	_, task := st.task(id)
+	if task == nil {
+		return fmt.Errorf("task not found: %s", id)
+	}
+	task.DuplicateOf = originalID
+	st.changes++
+	return nil
+}
+
+func (st *memoryStore) SetTaskUsage(id string, usage core.ResourceUsage) error {
+	_, task := st.task(id)
+	if task == nil {
+		return fmt.Errorf("task not found: %s", id)
+	}
+	task.Usage = &usage
+	return nil
+}
+
+// AddTaskAttempt records an attempt on its task and in the order attempts
+// started, for Executions.
+func (st *memoryStore) AddTaskAttempt(id string, env core.ExecutionEnvironment) error {
+	workflow, task := st.task(id)
+	if task == nil {
+		return fmt.Errorf("task not found: %s", id)
+	}
+	task.Attempts = append(task.Attempts, env)
+	st.attempts = append(st.attempts, Execution{
+		WorkflowID: workflow.ID,
+		TaskID:     task.ID,
+		TaskName:   task.Name,
+		Attempt:    env.Attempt,
+		StartedAt:  env.StartedAt,
+	})
+	st.changes++
+	return nil
+}
@@ -0,0 +1,71 @@
+import React, { useState, useEffect, useRef } from 'react';
+
+// Executors whose task output workers stream.
+export const STREAMING_EXECUTORS = ['shell', 'docker', 'k8s'];
+
+// Whether workers stream the task's output: script tasks always do.
+export const streamsOutput = (task) =>
+  task.type === 'script' || STREAMING_EXECUTORS.includes(task.executor);
+
+// Shows the live stdout and stderr of a task's latest attempt.
+const TaskOutput = ({ task, onClose }) => {
+  const [chunks, setChunks] = useState([]);
+  const [ended, setEnded] = useState(null);
+  const bottomRef = useRef(null);
+
+  useEffect(() => {
+    setChunks([]);
+    setEnded(null);
+
+    const source = new EventSource(`/api/v1/tasks/${task.id}/output`);
+    source.addEventListener('task.output', (event) => {
+      const chunk = JSON.parse(event.data);
+      if (chunk.eof) {
+        setEnded(chunk);
+        source.close();
+        return;
+      }
+      setChunks(previous => [...previous, chunk]);
+    });
+    // The server closes the stream once the attempt is over.
+    source.onerror = () => source.close();
+
+    return () => source.close();
+  }, [task.id]);
+
+  useEffect(() => {
+    bottomRef.current?.scrollIntoView({ block: 'nearest' });
+  }, [chunks]);
+
+  return (
+    <div className="card" style={{ marginTop: '2rem' }}>
+      <div style={{ display: 'flex', justifyContent: 'space-between', alignItems: 'center', marginBottom: '1rem' }}>
+        <h2 style={{ color: '#4a5568' }}>Output of {task.name} (attempt {task.retry_count + 1})</h2>
+        <button className="btn" onClick={onClose}>Close</button>
+      </div>
+      <pre style={{
+        backgroundColor: '#1a202c',
+        color: '#e2e8f0',
+        padding: '1rem',
+        borderRadius: '4px',
+        maxHeight: '400px',
+        overflow: 'auto',
+        fontSize: '0.8rem'
+      }}>
+        {chunks.map(chunk => (
+          <span key={chunk.id} style={{ color: chunk.stream === 'stderr' ? '#fc8181' : undefined }}>
+            {chunk.data}
+          </span>
+        ))}
+        <span ref={bottomRef} />
+      </pre>
+      {ended && (ended.dropped > 0 || ended.truncated) && (
+        <p style={{ color: '#718096', fontSize: '0.9rem' }}>
+          {ended.truncated ? 'Output was truncated. ' : ''}{ended.dropped} bytes were not streamed.
+        </p>
+      )}
+    </div>
+  );
+};
+
+export default TaskOutput;
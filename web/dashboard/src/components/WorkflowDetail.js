@@ -1,11 +1,13 @@
 import React, { useState, useEffect } from 'react';
 import { useParams, Link } from 'react-router-dom';
 import axios from 'axios';
+import TaskOutput, { streamsOutput } from './TaskOutput';
 
 const WorkflowDetail = () => {
   const { id } = useParams();
   const [workflow, setWorkflow] = useState(null);
   const [loading, setLoading] = useState(true);
+  const [outputTask, setOutputTask] = useState(null);
 
   useEffect(() => {
     fetchWorkflow();
@@ -113,6 +115,7 @@ const WorkflowDetail = () => {
                     <th>Retries</th>
                     <th>Duration</th>
                     <th>Dependencies</th>
+                    <th></th>
                   </tr>
                 </thead>
                 <tbody>
@@ -143,12 +146,19 @@ const WorkflowDetail = () => {
                       <td style={{ color: '#718096' }}>
                         {task.dependencies?.length > 0 ? task.dependencies.join(', ') : 'None'}
                       </td>
+                      <td>
+                        {streamsOutput(task) && (
+                          <button className="btn" onClick={() => setOutputTask(task)}>Output</button>
+                        )}
+                      </td>
                     </tr>
                   ))}
                 </tbody>
               </table>
             )}
           </div>
+
+          {outputTask && <TaskOutput task={outputTask} onClose={() => setOutputTask(null)} />}
         </div>
 
         <div>